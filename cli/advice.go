@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+)
+
+// advise prints a hint unless advice.<name> switches it off. Every hint
+// that suggests a next step goes through here, so each can be silenced.
+func advise(advice config.Advice, print func()) {
+	if config.AdviceEnabled(advice) {
+		print()
+	}
+}
+
+// statusHint prints an indented "(use ...)" line of status output
+func statusHint(hint string) {
+	advise(config.AdviceStatusHints, func() {
+		fmt.Printf("  %s\n", colors.Dim(hint))
+	})
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// maxAliasDepth bounds how many aliases may expand into one another
+const maxAliasDepth = 10
+
+// expandAliases replaces a leading alias in args with its configured
+// arguments. Aliases may refer to other aliases; a cycle is an error.
+// Built-in commands win over aliases of the same name unless
+// core.aliasshadow is set, in which case an alias may still expand to the
+// built-in it shadows (e.g. alias.log = "log --author me").
+func expandAliases(root *cobra.Command, args []string) ([]string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return args, nil
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil || len(cfg.Alias) == 0 {
+		return args, nil
+	}
+
+	var chain []string
+	for {
+		name := args[0]
+		expansion, ok := cfg.Alias[name]
+		builtin := isBuiltinCommand(root, name)
+		if !ok || (builtin && !cfg.Core.AliasShadow) {
+			return args, nil
+		}
+
+		for _, seen := range chain {
+			if seen == name {
+				if builtin {
+					return args, nil
+				}
+				return nil, fmt.Errorf("alias loop detected: %s -> %s", strings.Join(chain, " -> "), name)
+			}
+		}
+		chain = append(chain, name)
+		if len(chain) > maxAliasDepth {
+			return nil, fmt.Errorf("alias '%s' expands through more than %d aliases", chain[0], maxAliasDepth)
+		}
+
+		words, err := splitAliasArgs(expansion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alias.%s: %w", name, err)
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("alias.%s is empty", name)
+		}
+
+		args = append(words, args[1:]...)
+	}
+}
+
+// isBuiltinCommand reports whether name is a command or command alias
+// provided by Ivaldi itself
+func isBuiltinCommand(root *cobra.Command, name string) bool {
+	// cobra adds these lazily during Execute
+	if name == "help" || name == "completion" {
+		return true
+	}
+	for _, cmd := range root.Commands() {
+		if cmd.Name() == name || cmd.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAliasArgs splits an alias expansion into arguments. Words are
+// separated by whitespace; single or double quotes group words and a
+// backslash escapes the next character outside single quotes.
+func splitAliasArgs(s string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			}
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
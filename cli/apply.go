@@ -0,0 +1,404 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyCheck    bool
+	applyThreeWay bool
+	applyReverse  bool
+)
+
+// applyFuzz is how many context lines at each end of a hunk may differ
+// from the file, as with the default fuzz factor of patch(1)
+const applyFuzz = 2
+
+// minBlobIDPrefix is the shortest abbreviated blob ID --3way looks up
+const minBlobIDPrefix = 7
+
+var applyCmd = &cobra.Command{
+	Use:   "apply [--check] [--3way] [--reverse] [<patch>...]",
+	Short: "Apply a unified diff to the working directory",
+	Long: `Apply patches in unified diff format to the files in the working directory,
+without gathering or sealing anything. Patches are read from the given files,
+or from standard input when none is given or the name is -.
+
+Both 'diff --git' patches, as written by 'ivaldi export-patch' and Git, and
+the plain ---/+++ diffs of 'diff -u' are accepted. Paths lose their first
+component (a/ and b/). Each hunk is placed at the line its header names or the
+nearest line where its context matches; up to two context lines at either end
+of a hunk may differ, as with the fuzz factor of patch(1).
+
+A patch applies as a whole or not at all. If any hunk does not apply, no file
+is changed, the hunks that failed are written next to their file as
+<file>.rej, and the remaining patches are skipped.
+
+With --3way, a file whose hunks do not apply is merged instead. The patch's
+index line names the file it was made from; that version is looked up in the
+seals of the current timeline, the patch is applied to it, and the result is
+merged into the working file. Overlapping changes are written between conflict
+markers in the style of merge.conflictStyle.
+
+Examples:
+  ivaldi apply fix.patch              # Apply a patch
+  ivaldi apply --check fix.patch      # Only check that it applies
+  ivaldi apply -R fix.patch           # Undo a patch applied earlier
+  ivaldi apply --3way fix.patch       # Merge files the patch no longer fits
+  git diff | ivaldi apply             # Apply a patch from standard input`,
+	RunE: runApply,
+}
+
+func init() {
+	applyCmd.Flags().BoolVar(&applyCheck, "check", false, "Check that the patches apply without changing anything")
+	applyCmd.Flags().BoolVarP(&applyThreeWay, "3way", "3", false, "Merge files whose hunks do not apply with the version the patch was made from")
+	applyCmd.Flags().BoolVarP(&applyReverse, "reverse", "R", false, "Apply the patches in reverse")
+}
+
+// appliedFile is the new state of a file a patch changes
+type appliedFile struct {
+	path      string
+	content   []byte
+	removed   bool
+	merged    bool // Merged with --3way
+	conflicts int  // Conflicts written with markers by the merge
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	if err := requireWorkTree("apply"); err != nil {
+		return err
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	// Parse everything up front so a malformed patch fails before anything is changed
+	names := make([]string, len(args))
+	patches := make([][]patchFileDiff, len(args))
+	for i, arg := range args {
+		files, err := readApplyPatch(arg)
+		if err != nil {
+			return err
+		}
+		if applyReverse {
+			for j := range files {
+				files[j] = reverseFileDiff(files[j])
+			}
+		}
+		names[i], patches[i] = arg, files
+		if arg == "-" {
+			names[i] = "<stdin>"
+		}
+	}
+
+	var bases *patchBases
+	if applyThreeWay {
+		if bases, err = newPatchBases(ivaldiDir); err != nil {
+			return err
+		}
+	}
+
+	conflicted := 0
+	for i, files := range patches {
+		applied, err := applyFileDiffs(workDir, names[i], files, bases)
+		if err != nil {
+			if i > 0 && !applyCheck {
+				fmt.Printf("%d patch(es) applied before the failure remain applied\n", i)
+			}
+			return err
+		}
+
+		for _, file := range applied {
+			switch {
+			case file.conflicts > 0:
+				conflicted++
+				fmt.Printf("  %s: merged with %d conflict(s)\n", file.path, file.conflicts)
+			case file.merged:
+				fmt.Printf("  %s: merged cleanly\n", file.path)
+			}
+		}
+		if applyCheck {
+			fmt.Printf("%s %s applies (%d file(s))\n", colors.SuccessText("[OK]"), names[i], len(applied))
+			continue
+		}
+		if err := writeAppliedFiles(workDir, applied); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s (%d file(s))\n", colors.SuccessText("Applied"), names[i], len(applied))
+	}
+
+	if conflicted > 0 && !applyCheck {
+		return fmt.Errorf("%d file(s) merged with conflicts; resolve the conflict markers", conflicted)
+	}
+	return nil
+}
+
+// readApplyPatch reads and parses a patch file, or standard input for -
+func readApplyPatch(arg string) ([]patchFileDiff, error) {
+	var r io.Reader = os.Stdin
+	name := "standard input"
+	if arg != "-" {
+		f, err := os.Open(argPath(arg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", arg, err)
+		}
+		defer f.Close()
+		r, name = f, arg
+	}
+
+	files, err := parseFileDiffs(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file diffs found in %s", name)
+	}
+	return files, nil
+}
+
+// reverseFileDiff returns the file diff that undoes fileDiff
+func reverseFileDiff(fileDiff patchFileDiff) patchFileDiff {
+	reversed := fileDiff
+	reversed.IsNew, reversed.IsDeleted = fileDiff.IsDeleted, fileDiff.IsNew
+	reversed.OldID, reversed.NewID = fileDiff.NewID, fileDiff.OldID
+	reversed.Hunks = make([]diffmerge.Hunk, len(fileDiff.Hunks))
+	for i, hunk := range fileDiff.Hunks {
+		reversed.Hunks[i] = hunk.Reverse()
+	}
+	if fileDiff.Binary != nil {
+		reversed.Binary = &diffmerge.BinaryPatch{Forward: fileDiff.Binary.Reverse, Reverse: fileDiff.Binary.Forward}
+	}
+	return reversed
+}
+
+// applyFileDiffs applies the file diffs of one patch to the working
+// directory in memory. If any of them does not apply, the failures are
+// reported, rejected hunks are written to .rej files unless only checking,
+// and an error is returned.
+func applyFileDiffs(workDir, name string, files []patchFileDiff, bases *patchBases) ([]appliedFile, error) {
+	var applied []appliedFile
+	var conflicts []string
+	var rejectFiles []string
+
+	for _, fileDiff := range files {
+		path := fileDiff.Path
+		current, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(path)))
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		switch {
+		case fileDiff.IsBinary && fileDiff.Binary == nil:
+			conflicts = append(conflicts, fmt.Sprintf("%s: binary changes are not included in the patch", path))
+			continue
+		case fileDiff.IsNew && exists:
+			conflicts = append(conflicts, fmt.Sprintf("%s: already exists in working directory", path))
+			continue
+		case !fileDiff.IsNew && !exists:
+			conflicts = append(conflicts, fmt.Sprintf("%s: does not exist in working directory", path))
+			continue
+		}
+
+		file := appliedFile{path: path, removed: fileDiff.IsDeleted}
+		if fileDiff.Binary != nil {
+			if file.content, err = applyBinaryPatch(fileDiff, current, exists); err != nil {
+				conflicts = append(conflicts, fmt.Sprintf("%s: %v", path, err))
+				continue
+			}
+			applied = append(applied, file)
+			continue
+		}
+
+		lines, failed := diffmerge.ApplyHunksFuzz(diffmerge.SplitLines(current), fileDiff.Hunks, applyFuzz)
+		file.content = []byte(strings.Join(lines, ""))
+		if len(failed) > 0 && bases != nil {
+			merged, n, err := bases.merge(fileDiff, current)
+			if err == nil {
+				file.content, file.merged, file.conflicts, failed = merged, true, n, nil
+			} else {
+				conflicts = append(conflicts, fmt.Sprintf("%s: cannot merge: %v", path, err))
+			}
+		}
+		if len(failed) > 0 {
+			var rejected []diffmerge.Hunk
+			for _, idx := range failed {
+				hunk := fileDiff.Hunks[idx]
+				rejected = append(rejected, hunk)
+				conflicts = append(conflicts, fmt.Sprintf("%s: hunk #%d %s does not apply", path, idx+1, hunk.Header()))
+			}
+			if !applyCheck {
+				if err := writeRejects(filepath.Join(workDir, filepath.FromSlash(path)+".rej"), path, rejected); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to write rejected hunks for %s: %v\n", path, err)
+				} else {
+					rejectFiles = append(rejectFiles, path+".rej")
+				}
+			}
+			continue
+		}
+
+		if fileDiff.IsDeleted && len(file.content) != 0 {
+			conflicts = append(conflicts, fmt.Sprintf("%s: content differs from the deleted file", path))
+			continue
+		}
+		applied = append(applied, file)
+	}
+
+	if len(conflicts) > 0 {
+		fmt.Printf("%s %s does not apply:\n", colors.Red("Error:"), name)
+		for _, conflict := range conflicts {
+			fmt.Printf("  %s\n", conflict)
+		}
+		for _, rejFile := range rejectFiles {
+			fmt.Printf("Rejected hunks written to %s\n", rejFile)
+		}
+		return nil, fmt.Errorf("failed to apply %s: %d conflict(s)", name, len(conflicts))
+	}
+	return applied, nil
+}
+
+// writeAppliedFiles writes the new state of patched files to the working
+// directory, keeping the permissions of files that already exist
+func writeAppliedFiles(workDir string, files []appliedFile) error {
+	for _, file := range files {
+		fullPath := filepath.Join(workDir, filepath.FromSlash(file.path))
+		if file.removed {
+			if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", file.path, err)
+			}
+			continue
+		}
+
+		perm := os.FileMode(0644)
+		if info, err := os.Stat(fullPath); err == nil {
+			perm = info.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", file.path, err)
+		}
+		if err := os.WriteFile(fullPath, file.content, perm); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.path, err)
+		}
+	}
+	return nil
+}
+
+// patchBases finds the versions of files that patches were made from among
+// the seals of the current timeline, for --3way
+type patchBases struct {
+	casStore cas.CAS
+	seals    map[cas.Hash]bool // HEAD and its ancestors
+	files    map[cas.Hash]map[string]filechunk.NodeRef
+	checked  map[cas.Hash]string // Git blob IDs of the contents read
+}
+
+// newPatchBases reads the history of the current timeline. A timeline
+// without seals has no bases to offer.
+func newPatchBases(ivaldiDir string) (*patchBases, error) {
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	bases := &patchBases{
+		casStore: casStore,
+		files:    make(map[cas.Hash]map[string]filechunk.NodeRef),
+		checked:  make(map[cas.Hash]string),
+	}
+	head, err := resolveCommitRef(casStore, refsManager, "HEAD")
+	if err != nil {
+		return bases, nil
+	}
+	if bases.seals, err = commit.NewCommitReader(casStore).Ancestors(head); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return bases, nil
+}
+
+// find returns the content of path in a seal whose Git blob ID starts with
+// id, as written on the index line of a patch
+func (b *patchBases) find(path, id string) ([]byte, error) {
+	if len(id) < minBlobIDPrefix || strings.Trim(id, "0") == "" {
+		return nil, fmt.Errorf("the patch does not name the file it was made from")
+	}
+
+	loader := filechunk.NewLoader(b.casStore)
+	for seal := range b.seals {
+		files, ok := b.files[seal]
+		if !ok {
+			var err error
+			if files, err = getCommitFileRefs(b.casStore, seal); err != nil {
+				return nil, err
+			}
+			b.files[seal] = files
+		}
+		ref, ok := files[path]
+		if !ok {
+			continue
+		}
+		blobID, ok := b.checked[ref.Hash]
+		if ok && !strings.HasPrefix(blobID, id) {
+			continue
+		}
+		content, err := loader.ReadAll(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		blobID = diffmerge.GitBlobID(content, true)
+		b.checked[ref.Hash] = blobID
+		if strings.HasPrefix(blobID, id) {
+			return content, nil
+		}
+	}
+	return nil, fmt.Errorf("no seal has the version %s the patch was made from", id)
+}
+
+// merge applies the hunks of a file diff to the version of the file it was
+// made from and merges the result into current. It returns the merged
+// content and the number of conflicts written with markers.
+func (b *patchBases) merge(fileDiff patchFileDiff, current []byte) ([]byte, int, error) {
+	base, err := b.find(fileDiff.Path, fileDiff.OldID)
+	if err != nil {
+		return nil, 0, err
+	}
+	lines, failed := diffmerge.ApplyHunks(diffmerge.SplitLines(base), fileDiff.Hunks)
+	if len(failed) > 0 {
+		return nil, 0, fmt.Errorf("the patch does not apply to the version it was made from")
+	}
+
+	style := diffmerge.ConflictStyle(config.ConflictStyle())
+	labels := diffmerge.MarkerLabels{Ours: "ours", Base: "base", Theirs: "theirs"}
+	merged, conflicts := diffmerge.MergeText(base, current, []byte(strings.Join(lines, "")), style, labels)
+	return merged, conflicts, nil
+}
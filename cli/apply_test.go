@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const applyTestPatch = `Some text before the diff is skipped.
+--- a/f.txt	2026-01-02 03:04:05.000000000 +0000
++++ b/f.txt	2026-01-02 03:04:06.000000000 +0000
+@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+--- /dev/null
++++ b/dir/new.txt
+@@ -0,0 +1 @@
++new
+`
+
+func TestApplyFileDiffs(t *testing.T) {
+	workDir := t.TempDir()
+	original := "1\n2\n3\n4\n5\n"
+	if err := os.WriteFile(filepath.Join(workDir, "f.txt"), []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write f.txt: %v", err)
+	}
+
+	files, err := parseFileDiffs(bufio.NewReader(strings.NewReader(applyTestPatch)))
+	if err != nil {
+		t.Fatalf("parseFileDiffs failed: %v", err)
+	}
+	if len(files) != 2 || files[0].Path != "f.txt" || files[1].Path != "dir/new.txt" || !files[1].IsNew {
+		t.Fatalf("Unexpected file diffs %+v", files)
+	}
+
+	apply := func(files []patchFileDiff) error {
+		applied, err := applyFileDiffs(workDir, "test.patch", files, nil)
+		if err != nil {
+			return err
+		}
+		return writeAppliedFiles(workDir, applied)
+	}
+	if err := apply(files); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(workDir, "f.txt")); string(content) != "1\n2\nthree\n4\n5\n" {
+		t.Errorf("Unexpected f.txt after apply: %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(workDir, "dir", "new.txt")); string(content) != "new\n" {
+		t.Errorf("Unexpected dir/new.txt after apply: %q", content)
+	}
+
+	// Applying it in reverse undoes it
+	reversed := make([]patchFileDiff, len(files))
+	for i, file := range files {
+		reversed[i] = reverseFileDiff(file)
+	}
+	if err := apply(reversed); err != nil {
+		t.Fatalf("reverse apply failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(workDir, "f.txt")); string(content) != original {
+		t.Errorf("Unexpected f.txt after reverse apply: %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "dir", "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected dir/new.txt to be removed, got %v", err)
+	}
+
+	// A patch that does not apply changes nothing and leaves a .rej file
+	if err := os.WriteFile(filepath.Join(workDir, "f.txt"), []byte("1\n2\nTHREE\n4\n5\n"), 0644); err != nil {
+		t.Fatalf("Failed to write f.txt: %v", err)
+	}
+	if err := apply(files); err == nil {
+		t.Fatal("Expected the patch not to apply")
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "dir", "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be created by a failed patch, got %v", err)
+	}
+	if rej, err := os.ReadFile(filepath.Join(workDir, "f.txt.rej")); err != nil || !strings.Contains(string(rej), "+three\n") {
+		t.Errorf("Expected the rejected hunk in f.txt.rej, got %q, %v", rej, err)
+	}
+}
+
+func TestParseFileDiffsUnsafePaths(t *testing.T) {
+	tests := []struct {
+		header string
+		path   string // "" when the path is refused
+	}{
+		{"+++ b/dir/./f.txt", "dir/f.txt"},
+		{"+++ b/../escaped.txt", ""},
+		{"+++ b/dir/../../escaped.txt", ""},
+		{"+++ b/.ivaldi/config", ""},
+		{"+++ b/./.ivaldi/HEAD", ""},
+		{"+++ b/dir/.ivaldi", "dir/.ivaldi"},
+		{"diff --git a/../esc2.txt b/../esc2.txt", ""},
+		{"diff --git a/.ivaldi/HEAD b/.ivaldi/HEAD", ""},
+		{"diff --git a/dir/f.txt b/dir/f.txt", "dir/f.txt"},
+	}
+	for _, tt := range tests {
+		patch := "--- /dev/null\n" + tt.header + "\n@@ -0,0 +1 @@\n+pwned\n"
+		if strings.HasPrefix(tt.header, "diff --git ") {
+			// import-patch reads the path from the diff --git line
+			patch = tt.header + "\nnew file mode 100644\n--- /dev/null\n+++ b/whatever\n@@ -0,0 +1 @@\n+pwned\n"
+		}
+		files, err := parseFileDiffs(bufio.NewReader(strings.NewReader(patch)))
+		if tt.path == "" {
+			if err == nil {
+				t.Errorf("%s: expected the path to be refused, got %+v", tt.header, files)
+			}
+			continue
+		}
+		if err != nil || len(files) != 1 || files[0].Path != tt.path {
+			t.Errorf("%s: expected %s, got %+v, %v", tt.header, tt.path, files, err)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/auth"
+	"github.com/javanhut/Ivaldi-vcs/internal/httpclient"
 	"github.com/spf13/cobra"
 )
 
@@ -73,6 +74,9 @@ var authStatusCmd = &cobra.Command{
 			if authMethod.Name == "ivaldi" {
 				fmt.Println("\nTry logging in again:")
 				fmt.Println("  ivaldi auth login")
+			} else if authMethod.Name == "credential" {
+				fmt.Println("\nStore a new token with:")
+				fmt.Println("  ivaldi login")
 			} else if authMethod.Name == "gh-cli" {
 				fmt.Println("\nTry re-authenticating with GitHub CLI:")
 				fmt.Println("  gh auth login")
@@ -93,7 +97,7 @@ var authStatusCmd = &cobra.Command{
 		fmt.Printf("Account type: %s\n", user.Type)
 
 		// Show additional info based on auth method
-		if authMethod.Name != "ivaldi" {
+		if authMethod.Name != "ivaldi" && authMethod.Name != "credential" {
 			fmt.Println("\nNote: You're using an external authentication method.")
 			fmt.Println("To use Ivaldi's built-in OAuth, run:")
 			fmt.Println("  ivaldi auth login")
@@ -124,7 +128,10 @@ func getAuthenticatedUser(token string) (*GitHubUser, error) {
 	req.Header.Set("Authorization", fmt.Sprintf("token %s", token))
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
-	client := &http.Client{}
+	client, err := httpclient.New(0)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
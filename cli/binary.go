@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// binarySniffLen is how much of a file is scanned for a NUL byte
+const binarySniffLen = 8000
+
+// showText is set by --text: binary content is then printed like text
+var showText bool
+
+// addTextFlag adds --text to a command that prints file content
+func addTextFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&showText, "text", "a", false, "Print binary files as text instead of summarizing them")
+}
+
+// isBinaryContent reports whether content looks like binary data: it has
+// a NUL byte near the start
+func isBinaryContent(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLen)], 0) != -1
+}
+
+// showsAsBinary reports whether any of contents is binary and should be
+// summarized rather than printed, which --text overrides. Every command
+// that prints file content checks here first, since raw bytes can corrupt
+// the terminal.
+func showsAsBinary(contents ...[]byte) bool {
+	if showText {
+		return false
+	}
+	for _, content := range contents {
+		if isBinaryContent(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// binaryNote is printed in place of the content of a binary file
+func binaryNote(size int64) string {
+	return fmt.Sprintf("Binary file (%d bytes)", size)
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	catFileType   bool
+	catFileSize   bool
+	catFilePretty bool
+)
+
+// minObjectPrefix is the shortest hash prefix cat-file looks up among all
+// stored objects; shorter names are only resolved as seal references
+const minObjectPrefix = 4
+
+var catFileCmd = &cobra.Command{
+	Use:   "cat-file (-t | -s | -p) <object>",
+	Short: "Report the type, size or content of a stored object",
+	Long: `Inspect any stored object by hash. The object is a full hash, a unique
+hash prefix of at least 4 digits, or a seal reference such as HEAD or a
+timeline name.
+
+  -t  print the object's type: commit, tag, tree, wsindex or blob
+  -s  print the object's stored size in bytes
+  -p  pretty-print the object: the content of a file for a blob, and the
+      decoded fields for anything else, as 'ivaldi show --raw' does. A
+      binary file is only noted, unless --text is given
+
+The type is detected with the same decoders 'ivaldi show --raw' uses. A
+blob is the root node of a file's content. Objects that no decoder accepts,
+and prefixes that match more than one object, are reported as errors.
+
+Examples:
+  ivaldi cat-file -t 1a2b3c4d
+  ivaldi cat-file -s HEAD
+  ivaldi cat-file -p --text 1a2b3c4d > file.bin`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCatFile,
+}
+
+func init() {
+	catFileCmd.Flags().BoolVarP(&catFileType, "type", "t", false, "Print the object's type")
+	catFileCmd.Flags().BoolVarP(&catFileSize, "size", "s", false, "Print the object's stored size")
+	catFileCmd.Flags().BoolVarP(&catFilePretty, "pretty", "p", false, "Pretty-print the object's content")
+	catFileCmd.MarkFlagsOneRequired("type", "size", "pretty")
+	catFileCmd.MarkFlagsMutuallyExclusive("type", "size", "pretty")
+	addTextFlag(catFileCmd)
+}
+
+func runCatFile(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	hash, err := resolveAnyObject(casStore, refsManager, args[0])
+	if err != nil {
+		return err
+	}
+	data, err := casStore.Get(hash)
+	if err != nil {
+		return fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+
+	if catFileSize {
+		fmt.Println(len(data))
+		return nil
+	}
+
+	decoder, print := detectObject(data)
+	if decoder == nil {
+		return fmt.Errorf("object %s is not a commit, tag, tree, workspace index or file node", hash)
+	}
+	if catFileType {
+		fmt.Println(decoder.kind)
+		return nil
+	}
+	if decoder.kind == "blob" {
+		return writeBlob(casStore, hash, data)
+	}
+	print()
+	return nil
+}
+
+// resolveAnyObject resolves a full hash or a hash prefix to any stored
+// object, and anything else as a seal reference
+func resolveAnyObject(casStore *cas.FileCAS, refsManager *refs.RefsManager, ref string) (cas.Hash, error) {
+	if len(ref) == 2*len(cas.Hash{}) {
+		return resolveObjectHash(casStore, refsManager, ref)
+	}
+	if len(ref) < minObjectPrefix || strings.Trim(strings.ToLower(ref), "0123456789abcdef") != "" {
+		return resolveCommitRef(casStore, refsManager, ref)
+	}
+
+	matches, err := casStore.FindPrefix(ref)
+	if err != nil {
+		return cas.Hash{}, err
+	}
+	switch len(matches) {
+	case 0:
+		// The name may still be a timeline or seal name made of hex digits
+		if hash, err := resolveCommitRef(casStore, refsManager, ref); err == nil {
+			return hash, nil
+		}
+		return cas.Hash{}, fmt.Errorf("object %s not found", ref)
+	case 1:
+		return matches[0], nil
+	}
+	candidates := make([]string, len(matches))
+	for i, match := range matches {
+		candidates[i] = "  " + match.String()
+	}
+	return cas.Hash{}, fmt.Errorf("object prefix %s is ambiguous; it matches:\n%s", ref, strings.Join(candidates, "\n"))
+}
+
+// detectObject returns the first decoder that accepts data, with the
+// printer it decoded, or nil if none does
+func detectObject(data []byte) (*rawDecoder, func()) {
+	for i := range rawDecoders {
+		if print, err := rawDecoders[i].print(data); err == nil {
+			return &rawDecoders[i], print
+		}
+	}
+	return nil, nil
+}
+
+// writeBlob writes the whole content of the file rooted at a file node to
+// standard output. Binary content is only noted, unless --text is given.
+func writeBlob(casStore cas.CAS, hash cas.Hash, data []byte) error {
+	node, err := filechunk.DecodeNode(data)
+	if err != nil {
+		return err
+	}
+	size := node.Size
+	if node.Kind == filechunk.Leaf {
+		size = int64(len(node.Chunk))
+	}
+	loader := filechunk.NewLoader(casStore)
+	r, err := loader.Reader(filechunk.NodeRef{Hash: hash, Kind: node.Kind, Size: size})
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	defer r.Close()
+
+	br := bufio.NewReaderSize(r, binarySniffLen)
+	head, err := br.Peek(binarySniffLen)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	if showsAsBinary(head) {
+		fmt.Printf("%s (use --text to print it)\n", binaryNote(size))
+		return nil
+	}
+	if _, err := io.Copy(os.Stdout, br); err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	return nil
+}
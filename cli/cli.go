@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/converter"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/spf13/cobra"
@@ -27,17 +30,36 @@ var rootCmd = &cobra.Command{
 }
 
 var initialCmd = &cobra.Command{
-	Use:   "forge",
-	Short: "Initialize",
-	Long:  "Initializes a new ivaldi managed repository",
-	Run:   forgeCommand,
+	Use:     "forge",
+	Aliases: []string{"init"},
+	Short:   "Initialize",
+	Long: `Initializes a new ivaldi managed repository in the current directory.
+
+Forge sets up the object store, the timeline system and the first timeline,
+which is named main unless --timeline says otherwise, and seals any files
+already present. In a Git repository, the Git refs and objects are imported
+instead. Forge refuses to run where a .ivaldi directory already exists.
+
+Examples:
+  ivaldi forge                    # Initialize with the main timeline
+  ivaldi init --timeline trunk    # Name the first timeline trunk`,
+	Run: forgeCommand,
 }
 
+var forgeTimeline string
+
 func Execute() {
-	err := rootCmd.Execute()
+	args, err := expandAliases(rootCmd, os.Args[1:])
 	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	rootCmd.SetArgs(args)
+
+	err = rootCmd.Execute()
+	if err != nil {
+		os.Exit(exitCodeOf(err))
+	}
 }
 
 var version bool
@@ -45,11 +67,15 @@ var version bool
 func init() {
 	// Core commands
 	rootCmd.Flags().BoolVar(&version, "version", false, "Use this to get the Version of Ivaldi")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output (overrides color.ui)")
+	cobra.OnInitialize(applyColorConfig, applyWalkLimits)
+	rootCmd.PersistentPreRunE = setupRepository
+	initialCmd.Flags().StringVar(&forgeTimeline, "timeline", "main", "Name of the first timeline of a new repository")
 	rootCmd.AddCommand(initialCmd)
 
 	// Timeline management commands
 	rootCmd.AddCommand(timelineCmd)
-	timelineCmd.AddCommand(createTimelineCmd, switchTimelineCmd, listTimelineCmd, removeTimelineCmd)
+	timelineCmd.AddCommand(createTimelineCmd, switchTimelineCmd, listTimelineCmd, removeTimelineCmd, describeTimelineCmd)
 
 	// File and commit management commands
 	rootCmd.AddCommand(gatherCmd)
@@ -58,10 +84,17 @@ func init() {
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(whereamiCmd)
 	rootCmd.AddCommand(excludeCommand)
+	rootCmd.AddCommand(validateIgnoreCmd)
 
 	// Remote repository commands (now with GitHub integration)
 	rootCmd.AddCommand(uploadCmd)
 	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(verifyCloneCmd)
+
+	// Credential storage commands
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+	rootCmd.AddCommand(whoamiCmd)
 
 	// Portal commands for repository connection management
 	rootCmd.AddCommand(portalCmd)
@@ -70,23 +103,46 @@ func init() {
 	// Remote timeline discovery and harvesting commands
 	rootCmd.AddCommand(scoutCmd)
 	rootCmd.AddCommand(harvestCmd)
+	rootCmd.AddCommand(fetchCmd)
 
 	// Configuration command
 	rootCmd.AddCommand(configCmd)
 
 	// History and comparison commands
 	rootCmd.AddCommand(logCmd)
+	rootCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(catFileCmd)
+	rootCmd.AddCommand(verifyCommitCmd)
+	rootCmd.AddCommand(mergeBaseCmd)
+	rootCmd.AddCommand(revParseCmd)
+	rootCmd.AddCommand(showRefCmd)
 	rootCmd.AddCommand(diffCmd)
 	rootCmd.AddCommand(resetCmd)
+	rootCmd.AddCommand(stashCmd)
 
 	// Merge command
 	rootCmd.AddCommand(fuseCmd)
 
 	// Time travel command
 	rootCmd.AddCommand(travelCmd)
+	rootCmd.AddCommand(materializeCmd)
 
 	// Sync command
 	rootCmd.AddCommand(syncCmd)
+
+	// Patch exchange commands
+	rootCmd.AddCommand(exportPatchCmd)
+	rootCmd.AddCommand(importPatchCmd)
+	rootCmd.AddCommand(applyCmd)
+
+	// Maintenance commands
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(pruneCacheCmd)
+	rootCmd.AddCommand(reflogCmd)
+
+	// Submodule commands
+	rootCmd.AddCommand(submoduleCmd)
 }
 
 func forgeCommand(cmd *cobra.Command, args []string) {
@@ -96,20 +152,41 @@ func forgeCommand(cmd *cobra.Command, args []string) {
 		log.Fatal(errMsg)
 	}
 
-	ivaldiDir := ".ivaldi"
+	ivaldiDir := config.IvaldiDir()
 	workDir, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("Get working directory: %v", err)
 	}
 
-	// Create Ivaldi directory
+	if strings.TrimSpace(forgeTimeline) == "" {
+		log.Fatal("Timeline name cannot be empty")
+	}
+
+	// Create Ivaldi directory, refusing to initialize a repository twice
 	err = os.Mkdir(ivaldiDir, os.ModePerm)
-	if err != nil && !os.IsExist(err) {
+	if os.IsExist(err) {
+		log.Fatalf("Ivaldi repository already exists in %s", workDir)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Ivaldi repository initialized")
 
+	// Seed the repository from init.templateDir before anything else is
+	// written, so the template's files are only kept where nothing else is
+	if err := copyTemplateRepo(ivaldiDir); err != nil {
+		log.Printf("Warning: Failed to copy template: %v", err)
+	}
+	if err := copyTemplateWorkTree(workDir); err != nil {
+		log.Printf("Warning: Failed to copy template: %v", err)
+	}
+
+	// Record how objects are named, so later versions can tell
+	if err := config.SetValue("core.hashAlgo", cas.HashAlgorithm, false); err != nil {
+		log.Printf("Warning: Failed to record core.hashAlgo: %v", err)
+	}
+
 	// Initialize refs system
 	log.Println("Initializing timeline management system...")
 	refsManager, err := refs.NewRefsManager(ivaldiDir)
@@ -175,12 +252,12 @@ func forgeCommand(cmd *cobra.Command, args []string) {
 			}
 		} else {
 			// Initialize default timeline for new repository
-			log.Println("Creating default 'main' timeline...")
+			log.Printf("Creating default '%s' timeline...", forgeTimeline)
 
-			// Initially create main timeline with zero hashes
+			// Initially create the timeline with zero hashes
 			var zeroHash [32]byte
 			err = refsManager.CreateTimeline(
-				"main",
+				forgeTimeline,
 				refs.LocalTimeline,
 				zeroHash, // blake3Hash
 				zeroHash, // sha256Hash
@@ -188,13 +265,13 @@ func forgeCommand(cmd *cobra.Command, args []string) {
 				"Initial empty repository",
 			)
 			if err != nil {
-				log.Printf("Warning: Failed to create main timeline: %v", err)
+				log.Printf("Warning: Failed to create %s timeline: %v", forgeTimeline, err)
 			} else {
-				log.Println("Successfully created main timeline")
+				log.Printf("Successfully created %s timeline", forgeTimeline)
 			}
 
-			// Set main as current timeline
-			if err := refsManager.SetCurrentTimeline("main"); err != nil {
+			// Set it as current timeline
+			if err := refsManager.SetCurrentTimeline(forgeTimeline); err != nil {
 				log.Printf("Warning: Failed to set current timeline: %v", err)
 			}
 		}
@@ -227,8 +304,8 @@ func forgeCommand(cmd *cobra.Command, args []string) {
 			if err != nil {
 				log.Printf("Warning: Failed to create initial commit: %v", err)
 			} else if commitHash != nil {
-				// Update main timeline to point to the initial commit
-				log.Println("Updating main timeline with initial commit...")
+				// Update the timeline to point to the initial commit
+				log.Printf("Updating %s timeline with initial commit...", forgeTimeline)
 
 				// Re-open refs manager to update the timeline
 				refsManager2, err := refs.NewRefsManager(ivaldiDir)
@@ -237,18 +314,18 @@ func forgeCommand(cmd *cobra.Command, args []string) {
 				} else {
 					defer refsManager2.Close()
 
-					// Update main timeline with the commit hash
+					// Update the timeline with the commit hash
 					err = refsManager2.UpdateTimeline(
-						"main",
+						forgeTimeline,
 						refs.LocalTimeline,
 						*commitHash, // Use the actual commit hash
 						[32]byte{},  // No SHA256 for now
 						"",          // No Git SHA1
 					)
 					if err != nil {
-						log.Printf("Warning: Failed to update main timeline with initial commit: %v", err)
+						log.Printf("Warning: Failed to update %s timeline with initial commit: %v", forgeTimeline, err)
 					} else {
-						log.Println("Successfully updated main timeline with initial commit")
+						log.Printf("Successfully updated %s timeline with initial commit", forgeTimeline)
 					}
 				}
 			}
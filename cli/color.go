@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+)
+
+// noColor disables colored output regardless of color.ui
+var noColor bool
+
+// applyColorConfig sets up colored output from --no-color and the color.*
+// settings before a command runs. Invalid settings are reported and
+// otherwise left at their defaults.
+func applyColorConfig() {
+	if noColor {
+		colors.SetColorEnabled(false)
+		return
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return
+	}
+	if err := colors.SetMode(string(cfg.Color.UI)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: color.ui: %v\n", err)
+	}
+
+	colors.ResetPalette()
+	for slot, spec := range cfg.Color.Slots {
+		if err := colors.SetSlot(slot, spec); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: color.%s: %v\n", slot, err)
+		}
+	}
+	if !cfg.Color.Status {
+		colors.DisableCategory("status")
+	}
+	if !cfg.Color.Diff {
+		colors.DisableCategory("diff")
+	}
+}
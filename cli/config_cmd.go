@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
 	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/spf13/cobra"
 )
@@ -26,7 +28,12 @@ Examples:
   ivaldi config user.email "you@example.com"
   ivaldi config --global user.name "Your Name"
   ivaldi config --list
-  ivaldi config user.name`,
+  ivaldi config user.name
+  ivaldi config alias.st "status"        # 'ivaldi st' runs 'ivaldi status'
+  ivaldi config alias.st ""              # Remove the alias
+  ivaldi config merge.defaultStrategy union  # Strategy for fuse without --strategy
+  ivaldi config merge.conflictStyle diff3    # Show base content in fuse --markers conflicts
+  ivaldi config gc.auto background         # Pack loose objects after seals`,
 	RunE: runConfig,
 }
 
@@ -82,6 +89,9 @@ func listConfig() error {
 	} else {
 		fmt.Printf("  user.email = %s\n", colors.Gray("(not set)"))
 	}
+	if cfg.User.SigningKey != "" {
+		fmt.Printf("  user.signingkey = %s\n", colors.InfoText(cfg.User.SigningKey))
+	}
 
 	fmt.Println()
 	fmt.Println(colors.SectionHeader("Core Configuration:"))
@@ -96,12 +106,300 @@ func listConfig() error {
 		fmt.Printf("  core.pager = %s\n", colors.Gray("(not set)"))
 	}
 	fmt.Printf("  core.autoshelf = %s\n", colors.InfoText(fmt.Sprintf("%t", cfg.Core.AutoShelf)))
+	if cfg.Core.Bare {
+		fmt.Printf("  core.bare = %s\n", colors.InfoText("true"))
+	}
+	if cfg.Core.AliasShadow {
+		fmt.Printf("  core.aliasshadow = %s\n", colors.InfoText("true"))
+	}
+	if cfg.Core.Whitespace != "" {
+		fmt.Printf("  core.whitespace = %s\n", colors.InfoText(cfg.Core.Whitespace))
+	} else {
+		fmt.Printf("  core.whitespace = %s\n", colors.Gray("(default: trailing-space,mixed-indent,missing-newline)"))
+	}
+	if cfg.Core.PrecomposeUnicode != "" {
+		fmt.Printf("  core.precomposeunicode = %s\n", colors.InfoText(cfg.Core.PrecomposeUnicode))
+	} else {
+		fmt.Printf("  core.precomposeunicode = %s\n", colors.Gray("(default: true on macOS, false elsewhere)"))
+	}
+	if cfg.Core.Symlinks != "" {
+		fmt.Printf("  core.symlinks = %s\n", colors.InfoText(cfg.Core.Symlinks))
+	} else {
+		fmt.Printf("  core.symlinks = %s\n", colors.Gray("(default: false on Windows, true elsewhere)"))
+	}
+	fmt.Printf("  core.ignoremtime = %s\n", colors.InfoText(fmt.Sprintf("%t", cfg.Core.IgnoreMtime)))
+	fmt.Printf("  core.snapshotstaging = %s\n", colors.InfoText(fmt.Sprintf("%t", cfg.Core.SnapshotStaging)))
+	if cfg.Core.FileMode != "" {
+		fmt.Printf("  core.filemode = %s\n", colors.InfoText(cfg.Core.FileMode))
+	} else {
+		fmt.Printf("  core.filemode = %s\n", colors.Gray("(default: true)"))
+	}
+	if cfg.Core.NestedRepos != "" {
+		fmt.Printf("  core.nestedrepos = %s\n", colors.InfoText(cfg.Core.NestedRepos))
+	} else {
+		fmt.Printf("  core.nestedrepos = %s\n", colors.Gray("(default: skip)"))
+	}
+	if cfg.Core.HashAlgo != "" {
+		fmt.Printf("  core.hashalgo = %s\n", colors.InfoText(cfg.Core.HashAlgo))
+	} else {
+		fmt.Printf("  core.hashalgo = %s\n", colors.Gray("(default: blake3)"))
+	}
+	if cfg.Core.MaxWalkCommits > 0 {
+		fmt.Printf("  core.maxwalkcommits = %s\n", colors.InfoText(fmt.Sprintf("%d", cfg.Core.MaxWalkCommits)))
+	} else {
+		fmt.Printf("  core.maxwalkcommits = %s\n", colors.Gray(fmt.Sprintf("(default: %d)", commit.DefaultMaxWalkCommits)))
+	}
+	if cfg.Core.MaxWalkDepth > 0 {
+		fmt.Printf("  core.maxwalkdepth = %s\n", colors.InfoText(fmt.Sprintf("%d", cfg.Core.MaxWalkDepth)))
+	} else {
+		fmt.Printf("  core.maxwalkdepth = %s\n", colors.Gray(fmt.Sprintf("(default: %d)", commit.DefaultMaxWalkDepth)))
+	}
+	if cfg.Core.BigFileThreshold != "" {
+		fmt.Printf("  core.bigfilethreshold = %s\n", colors.InfoText(cfg.Core.BigFileThreshold))
+	} else {
+		fmt.Printf("  core.bigfilethreshold = %s\n", colors.Gray("(default: 512m)"))
+	}
+	if cfg.Core.WorkTree != "" {
+		fmt.Printf("  core.worktree = %s\n", colors.InfoText(cfg.Core.WorkTree))
+	}
 
 	fmt.Println()
 	fmt.Println(colors.SectionHeader("Color Configuration:"))
-	fmt.Printf("  color.ui = %s\n", colors.InfoText(fmt.Sprintf("%t", cfg.Color.UI)))
+	fmt.Printf("  color.ui = %s\n", colors.InfoText(string(cfg.Color.UI)))
 	fmt.Printf("  color.status = %s\n", colors.InfoText(fmt.Sprintf("%t", cfg.Color.Status)))
 	fmt.Printf("  color.diff = %s\n", colors.InfoText(fmt.Sprintf("%t", cfg.Color.Diff)))
+	slots := make([]string, 0, len(cfg.Color.Slots))
+	for slot := range cfg.Color.Slots {
+		slots = append(slots, slot)
+	}
+	sort.Strings(slots)
+	for _, slot := range slots {
+		fmt.Printf("  color.%s = %s\n", slot, colors.Slot(slot, cfg.Color.Slots[slot]))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Security Configuration:"))
+	if patterns := cfg.Security.SecretPatterns; patterns != nil && len(*patterns) > 0 {
+		fmt.Printf("  security.secretpatterns = %s\n", colors.InfoText(strings.Join(*patterns, ",")))
+	} else if patterns != nil {
+		fmt.Printf("  security.secretpatterns = %s\n", colors.Gray("(none)"))
+	} else {
+		fmt.Printf("  security.secretpatterns = %s\n", colors.Gray("(default: "+strings.Join(autoExcludePatterns, ",")+")"))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Push Configuration:"))
+	if cfg.Push.Default != "" {
+		fmt.Printf("  push.default = %s\n", colors.InfoText(cfg.Push.Default))
+	} else {
+		fmt.Printf("  push.default = %s\n", colors.Gray("(default: "+config.PushUpstream+")"))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Fetch Configuration:"))
+	if cfg.Fetch.PruneTags != "" {
+		fmt.Printf("  fetch.pruneTags = %s\n", colors.InfoText(cfg.Fetch.PruneTags))
+	} else {
+		fmt.Printf("  fetch.pruneTags = %s\n", colors.Gray("(default: false)"))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Status Configuration:"))
+	if cfg.Status.ShowStash != "" {
+		fmt.Printf("  status.showStash = %s\n", colors.InfoText(cfg.Status.ShowStash))
+	} else {
+		fmt.Printf("  status.showStash = %s\n", colors.Gray("(default: false)"))
+	}
+	if cfg.Status.RefreshUpstream != "" {
+		fmt.Printf("  status.refreshUpstream = %s\n", colors.InfoText(cfg.Status.RefreshUpstream))
+	} else {
+		fmt.Printf("  status.refreshUpstream = %s\n", colors.Gray("(default: false)"))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Pull Configuration:"))
+	if cfg.Pull.Rebase != "" {
+		fmt.Printf("  pull.rebase = %s\n", colors.InfoText(cfg.Pull.Rebase))
+	} else {
+		fmt.Printf("  pull.rebase = %s\n", colors.Gray("(default: false)"))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Transfer Configuration:"))
+	if cfg.Transfer.FsckObjects != "" {
+		fmt.Printf("  transfer.fsckObjects = %s\n", colors.InfoText(cfg.Transfer.FsckObjects))
+	} else {
+		fmt.Printf("  transfer.fsckObjects = %s\n", colors.Gray("(default: true)"))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Advice Configuration:"))
+	for _, advice := range []struct{ key, value string }{
+		{"advice.mergeConflict", cfg.Advice.MergeConflict},
+		{"advice.statusHints", cfg.Advice.StatusHints},
+	} {
+		if advice.value != "" {
+			fmt.Printf("  %s = %s\n", advice.key, colors.InfoText(advice.value))
+		} else {
+			fmt.Printf("  %s = %s\n", advice.key, colors.Gray("(default: true)"))
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Init Configuration:"))
+	if cfg.Init.TemplateDir != "" {
+		fmt.Printf("  init.templateDir = %s\n", colors.InfoText(cfg.Init.TemplateDir))
+	} else {
+		fmt.Printf("  init.templateDir = %s\n", colors.Gray("(not set)"))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Merge Configuration:"))
+	if cfg.Merge.DefaultStrategy != "" {
+		fmt.Printf("  merge.defaultStrategy = %s\n", colors.InfoText(cfg.Merge.DefaultStrategy))
+	} else {
+		fmt.Printf("  merge.defaultStrategy = %s\n", colors.Gray("(default: auto)"))
+	}
+	if cfg.Merge.ConflictStyle != "" {
+		fmt.Printf("  merge.conflictStyle = %s\n", colors.InfoText(cfg.Merge.ConflictStyle))
+	} else {
+		fmt.Printf("  merge.conflictStyle = %s\n", colors.Gray("(default: "+config.ConflictStyleMerge+")"))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("GC Configuration:"))
+	if cfg.GC.Auto != "" {
+		fmt.Printf("  gc.auto = %s\n", colors.InfoText(cfg.GC.Auto))
+	} else {
+		fmt.Printf("  gc.auto = %s\n", colors.Gray("(default: "+config.GCAutoOff+")"))
+	}
+	if cfg.GC.AutoThreshold > 0 {
+		fmt.Printf("  gc.autoThreshold = %s\n", colors.InfoText(fmt.Sprintf("%d", cfg.GC.AutoThreshold)))
+	} else {
+		fmt.Printf("  gc.autoThreshold = %s\n", colors.Gray(fmt.Sprintf("(default: %d)", config.DefaultGCAutoThreshold)))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Diff Configuration:"))
+	if cfg.Diff.RenameLimit > 0 {
+		fmt.Printf("  diff.renameLimit = %s\n", colors.InfoText(fmt.Sprintf("%d", cfg.Diff.RenameLimit)))
+	} else {
+		fmt.Printf("  diff.renameLimit = %s\n", colors.Gray(fmt.Sprintf("(default: %d)", config.DefaultDiffRenameLimit)))
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Reflog Configuration:"))
+	if cfg.Reflog.Expire != "" {
+		fmt.Printf("  reflog.expire = %s\n", colors.InfoText(cfg.Reflog.Expire))
+	} else {
+		fmt.Printf("  reflog.expire = %s\n", colors.Gray("(default: "+config.DefaultReflogExpire+")"))
+	}
+	if cfg.Reflog.ExpireUnreachable != "" {
+		fmt.Printf("  reflog.expireUnreachable = %s\n", colors.InfoText(cfg.Reflog.ExpireUnreachable))
+	} else {
+		fmt.Printf("  reflog.expireUnreachable = %s\n", colors.Gray("(default: "+config.DefaultReflogExpireUnreachable+")"))
+	}
+
+	if cfg.Commit != (config.CommitConfig{}) {
+		fmt.Println()
+		fmt.Println(colors.SectionHeader("Commit Configuration:"))
+		if cfg.Commit.GPGSign != "" {
+			fmt.Printf("  commit.gpgsign = %s\n", colors.InfoText(cfg.Commit.GPGSign))
+		}
+		if cfg.Commit.SSHSign != "" {
+			fmt.Printf("  commit.sshsign = %s\n", colors.InfoText(cfg.Commit.SSHSign))
+		}
+	}
+
+	if cfg.GitHub != (config.GitHubConfig{}) {
+		fmt.Println()
+		fmt.Println(colors.SectionHeader("GitHub Configuration:"))
+		if cfg.GitHub.Host != "" {
+			fmt.Printf("  github.host = %s\n", colors.InfoText(cfg.GitHub.Host))
+		}
+		if cfg.GitHub.APIURL != "" {
+			fmt.Printf("  github.apiurl = %s\n", colors.InfoText(cfg.GitHub.APIURL))
+		}
+		if cfg.GitHub.RawURL != "" {
+			fmt.Printf("  github.rawurl = %s\n", colors.InfoText(cfg.GitHub.RawURL))
+		}
+	}
+
+	if cfg.HTTP != (config.HTTPConfig{}) {
+		fmt.Println()
+		fmt.Println(colors.SectionHeader("HTTP Configuration:"))
+		if cfg.HTTP.Proxy != "" {
+			fmt.Printf("  http.proxy = %s\n", colors.InfoText(cfg.HTTP.Proxy))
+		}
+		if cfg.HTTP.SSLCAInfo != "" {
+			fmt.Printf("  http.sslCAInfo = %s\n", colors.InfoText(cfg.HTTP.SSLCAInfo))
+		}
+	}
+
+	if cfg.Credential.Helper != "" {
+		fmt.Println()
+		fmt.Println(colors.SectionHeader("Credential Configuration:"))
+		fmt.Printf("  credential.helper = %s\n", colors.InfoText(cfg.Credential.Helper))
+	}
+
+	if len(cfg.Alias) > 0 {
+		names := make([]string, 0, len(cfg.Alias))
+		for name := range cfg.Alias {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println()
+		fmt.Println(colors.SectionHeader("Aliases:"))
+		for _, name := range names {
+			fmt.Printf("  alias.%s = %s\n", name, colors.InfoText(cfg.Alias[name]))
+		}
+	}
+
+	if len(cfg.Difftool) > 0 {
+		names := make([]string, 0, len(cfg.Difftool))
+		for name := range cfg.Difftool {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println()
+		fmt.Println(colors.SectionHeader("Diff Tools:"))
+		for _, name := range names {
+			fmt.Printf("  difftool.%s.cmd = %s\n", name, colors.InfoText(cfg.Difftool[name].Cmd))
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(colors.SectionHeader("Branch Configuration:"))
+	if cfg.BranchSetup.AutoSetupMerge != "" {
+		fmt.Printf("  branch.autoSetupMerge = %s\n", colors.InfoText(cfg.BranchSetup.AutoSetupMerge))
+	} else {
+		fmt.Printf("  branch.autoSetupMerge = %s\n", colors.Gray("(default: true)"))
+	}
+
+	if len(cfg.Branch) > 0 {
+		names := make([]string, 0, len(cfg.Branch))
+		for name := range cfg.Branch {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println()
+		fmt.Println(colors.SectionHeader("Timeline Settings:"))
+		for _, name := range names {
+			branch := cfg.Branch[name]
+			if branch.Remote != "" {
+				fmt.Printf("  branch.%s.remote = %s\n", name, colors.InfoText(branch.Remote))
+			}
+			if branch.Merge != "" {
+				fmt.Printf("  branch.%s.merge = %s\n", name, colors.InfoText(branch.Merge))
+			}
+			if branch.MergeStrategy != "" {
+				fmt.Printf("  branch.%s.mergeStrategy = %s\n", name, colors.InfoText(branch.MergeStrategy))
+			}
+		}
+	}
 
 	return nil
 }
@@ -122,6 +420,16 @@ func getConfigValue(key string) error {
 }
 
 func setConfigValue(key, value string, global bool) error {
+	if name, ok := strings.CutPrefix(key, "alias."); ok && value != "" && isBuiltinCommand(rootCmd, name) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if !cfg.Core.AliasShadow {
+			return fmt.Errorf("alias '%s' would shadow the built-in command; run 'ivaldi config core.aliasshadow true' to allow it", name)
+		}
+	}
+
 	err := config.SetValue(key, value, global)
 	if err != nil {
 		return err
@@ -132,6 +440,11 @@ func setConfigValue(key, value string, global bool) error {
 		scope = "global"
 	}
 
+	if strings.HasPrefix(key, "alias.") && value == "" {
+		fmt.Printf("%s %s config: %s\n", colors.SuccessText("Removed"), scope, colors.Bold(key))
+		return nil
+	}
+
 	fmt.Printf("%s %s config: %s = %s\n",
 		colors.SuccessText("Set"),
 		scope,
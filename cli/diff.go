@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/colors"
-	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
 	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
@@ -18,7 +20,7 @@ import (
 )
 
 var diffCmd = &cobra.Command{
-	Use:   "diff [options] [<commit>] [<commit>]",
+	Use:   "diff [options] [<commit>|<timeline>] [<commit>|<timeline>]",
 	Short: "Show differences between commits or working directory",
 	Long: `Show changes between commits, commit and working directory, or staging area.
 
@@ -27,27 +29,113 @@ Examples:
   ivaldi diff --staged            # Staged vs HEAD
   ivaldi diff <seal>              # Working directory vs commit
   ivaldi diff <seal1> <seal2>     # Between two commits
-  ivaldi diff --stat              # Show summary statistics only`,
+  ivaldi diff main feature        # Between the heads of two timelines
+  ivaldi diff --binary main feature  # Include applyable binary patches
+  ivaldi diff --stat              # Show summary statistics only
+  ivaldi diff --check             # Check gathered changes for whitespace errors
+  ivaldi diff -w main feature     # Ignore all whitespace when comparing lines
+  ivaldi diff -b --ignore-blank-lines  # Ignore reindentation and blank lines
+  ivaldi diff -U0 main feature    # Show changed lines without context
+  ivaldi diff --inter-hunk-context=5 main feature  # Join hunks up to 5 lines apart
+  ivaldi diff --color-moved main feature  # Mark blocks moved within or between files
+  ivaldi diff --quiet main~1 main # Exit with 1 if the seals differ, 0 if not
+  ivaldi diff --raw -M main feature  # Modes, object hashes and status per file
+  ivaldi diff --raw -C main~1 main   # Also report added files copied from others
+  ivaldi diff --tool meld main~1  # Open each changed file in difftool.meld.cmd`,
 	RunE: runDiff,
 }
 
 var (
 	diffStaged bool
 	diffStat   bool
+	diffCheck  bool
+	diffBinary bool
+
+	diffWhitespace diffmerge.WhitespaceOptions
+	diffHunks      diffmerge.HunkOptions
+	diffColorMoved bool
+
+	diffExitCode bool
+	diffQuiet    bool
+
+	diffRaw         bool
+	diffFindRenames string
+	diffFindCopies  string
+
+	diffTool string
+
+	// diffFoundChanges records whether the compared sides differ
+	diffFoundChanges bool
 )
 
 func init() {
 	diffCmd.Flags().BoolVar(&diffStaged, "staged", false, "Show diff of staged changes")
 	diffCmd.Flags().BoolVar(&diffStat, "stat", false, "Show only statistics")
+	diffCmd.Flags().BoolVar(&diffCheck, "check", false, "Check gathered or changed files for whitespace errors (see core.whitespace)")
+	diffCmd.Flags().BoolVar(&diffBinary, "binary", false, "Show binary changes as applyable binary patches")
+	addTextFlag(diffCmd)
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "Exit with 1 if there are differences and 0 if there are none (2 on errors)")
+	diffCmd.Flags().BoolVarP(&diffQuiet, "quiet", "q", false, "Print nothing; implies --exit-code")
+	diffCmd.Flags().BoolVar(&diffColorMoved, "color-moved", false, "Show removed lines added back elsewhere as moved (< and >) instead of as - and +")
+	diffCmd.Flags().BoolVar(&diffRaw, "raw", false, "Show modes, object hashes and a status letter per changed file")
+	diffCmd.Flags().StringVarP(&diffFindRenames, "find-renames", "M", "", "With --raw, detect renames of files at least this similar (default 50%)")
+	diffCmd.Flags().Lookup("find-renames").NoOptDefVal = "50%"
+	diffCmd.Flags().StringVarP(&diffFindCopies, "find-copies", "C", "", "With --raw, also detect copies of unchanged or modified files at least this similar (default 50%); implies --find-renames")
+	diffCmd.Flags().Lookup("find-copies").NoOptDefVal = "50%"
+	diffCmd.Flags().StringVar(&diffTool, "tool", "", "Open each changed file in the external diff tool configured as difftool.<name>.cmd")
+	addWhitespaceFlags(diffCmd, &diffWhitespace)
+	addContextFlags(diffCmd, &diffHunks)
+}
+
+// addWhitespaceFlags registers the options that make line diffs ignore
+// whitespace. Lines are compared in normalized form but shown as they are.
+func addWhitespaceFlags(cmd *cobra.Command, ws *diffmerge.WhitespaceOptions) {
+	cmd.Flags().BoolVarP(&ws.IgnoreAll, "ignore-whitespace", "w", false, "Ignore all whitespace when comparing lines")
+	cmd.Flags().BoolVar(&ws.IgnoreAll, "ignore-all-space", false, "Same as --ignore-whitespace")
+	cmd.Flags().MarkHidden("ignore-all-space")
+	cmd.Flags().BoolVarP(&ws.IgnoreChange, "ignore-space-change", "b", false, "Ignore changes in the amount of whitespace and whitespace at line ends")
+	cmd.Flags().BoolVar(&ws.IgnoreBlankLines, "ignore-blank-lines", false, "Ignore changes that only add or remove blank lines")
+}
+
+// addContextFlags registers the options that set how much unchanged text
+// surrounds the hunks of a unified diff
+func addContextFlags(cmd *cobra.Command, hunks *diffmerge.HunkOptions) {
+	cmd.Flags().IntVarP(&hunks.Context, "unified", "U", diffmerge.DefaultContext, "Show this many lines of context around each change")
+	cmd.Flags().IntVar(&hunks.InterHunkContext, "inter-hunk-context", 0, "Join hunks separated by at most this many unchanged lines besides their context")
 }
 
 func runDiff(cmd *cobra.Command, args []string) error {
+	if diffCheck && (diffExitCode || diffQuiet) {
+		return fmt.Errorf("--check cannot be combined with --exit-code or --quiet")
+	}
+	if diffRaw && (diffCheck || diffStat) {
+		return fmt.Errorf("--raw cannot be combined with --check or --stat")
+	}
+	if diffFindRenames != "" && !diffRaw {
+		return fmt.Errorf("--find-renames is only supported with --raw")
+	}
+	if diffFindCopies != "" && !diffRaw {
+		return fmt.Errorf("--find-copies is only supported with --raw")
+	}
+	if diffTool != "" && (diffRaw || diffStat || diffCheck || diffQuiet) {
+		return fmt.Errorf("--tool cannot be combined with --raw, --stat, --check or --quiet")
+	}
+	err := runDiffCompare(args)
+	return withExitCode(cmd, diffExitCode || diffQuiet, diffFoundChanges, err)
+}
+
+// runDiffCompare dispatches to the comparison selected by the arguments
+func runDiffCompare(args []string) error {
 	// Check if we're in an Ivaldi repository
-	ivaldiDir := ".ivaldi"
+	ivaldiDir := config.IvaldiDir()
 	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 	}
 
+	if err := requireWorkTree("diff"); err != nil {
+		return err
+	}
+
 	workDir, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get working directory: %w", err)
@@ -60,6 +148,10 @@ func runDiff(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
+	if diffCheck {
+		return runDiffCheck(casStore, ivaldiDir, workDir)
+	}
+
 	// Determine what to compare based on arguments
 	switch len(args) {
 	case 0:
@@ -69,6 +161,11 @@ func runDiff(cmd *cobra.Command, args []string) error {
 		// One arg: working directory vs specified commit
 		return diffWorkingVsCommit(casStore, ivaldiDir, workDir, args[0])
 	case 2:
+		// Two timelines: compare their heads. Raw output and diff tools
+		// need the workspace indexes the commit comparison works on.
+		if !diffRaw && diffTool == "" && isLocalTimeline(ivaldiDir, args[0]) && isLocalTimeline(ivaldiDir, args[1]) {
+			return diffTimelines(casStore, ivaldiDir, args[0], args[1])
+		}
 		// Two args: compare two commits
 		return diffCommitVsCommit(casStore, ivaldiDir, args[0], args[1])
 	default:
@@ -96,9 +193,14 @@ func diffWorkingOrStaged(casStore cas.CAS, ivaldiDir, workDir string) error {
 		return fmt.Errorf("failed to get staged files: %w", err)
 	}
 
+	intent, err := getIntentToAddFiles(ivaldiDir)
+	if err != nil {
+		return err
+	}
+
 	if len(stagedFiles) == 0 {
 		// No staged files, compare with HEAD
-		return diffWorkingVsHead(casStore, ivaldiDir, currentIndex)
+		return diffWorkingVsHead(casStore, ivaldiDir, currentIndex, intent)
 	}
 
 	// Build index from staged files
@@ -120,6 +222,10 @@ func diffWorkingOrStaged(casStore cas.CAS, ivaldiDir, workDir string) error {
 			stagedMetadata = append(stagedMetadata, file)
 		}
 	}
+	stagedMetadata, err = withStagedSnapshots(ivaldiDir, stagedFiles, stagedMetadata)
+	if err != nil {
+		return err
+	}
 
 	// Build staged index
 	wsBuilder := wsindex.NewBuilder(casStore)
@@ -128,8 +234,14 @@ func diffWorkingOrStaged(casStore cas.CAS, ivaldiDir, workDir string) error {
 		return fmt.Errorf("failed to build staged index: %w", err)
 	}
 
+	// Intent-to-add files count as staged with empty content
+	stagedIndex, err = withIntentToAdd(casStore, stagedIndex, intent)
+	if err != nil {
+		return err
+	}
+
 	// Show diff
-	return showDiff(casStore, stagedIndex, currentIndex, "staged", "working directory")
+	return showDiff(casStore, stagedIndex, currentIndex, "staged", "working directory", intent)
 }
 
 // diffStagedVsHead shows diff of staged changes vs HEAD
@@ -147,7 +259,9 @@ func diffStagedVsHead(casStore cas.CAS, ivaldiDir, workDir string) error {
 	}
 
 	if len(stagedFiles) == 0 {
-		fmt.Println("No staged files.")
+		if !diffQuiet && !diffRaw {
+			fmt.Println("No staged files.")
+		}
 		return nil
 	}
 
@@ -176,6 +290,10 @@ func diffStagedVsHead(casStore cas.CAS, ivaldiDir, workDir string) error {
 			stagedMetadata = append(stagedMetadata, file)
 		}
 	}
+	stagedMetadata, err = withStagedSnapshots(ivaldiDir, stagedFiles, stagedMetadata)
+	if err != nil {
+		return err
+	}
 
 	// Build staged index
 	wsBuilder := wsindex.NewBuilder(casStore)
@@ -184,17 +302,23 @@ func diffStagedVsHead(casStore cas.CAS, ivaldiDir, workDir string) error {
 		return fmt.Errorf("failed to build staged index: %w", err)
 	}
 
-	return showDiff(casStore, headIndex, stagedIndex, "HEAD", "staged")
+	return showDiff(casStore, headIndex, stagedIndex, "HEAD", "staged", nil)
 }
 
-// diffWorkingVsHead shows working directory vs HEAD
-func diffWorkingVsHead(casStore cas.CAS, ivaldiDir string, currentIndex wsindex.IndexRef) error {
+// diffWorkingVsHead shows working directory vs HEAD, with intent-to-add
+// files tracked as empty
+func diffWorkingVsHead(casStore cas.CAS, ivaldiDir string, currentIndex wsindex.IndexRef, intent []string) error {
 	headIndex, err := getHeadIndex(casStore, ivaldiDir)
 	if err != nil {
 		return err
 	}
 
-	return showDiff(casStore, headIndex, currentIndex, "HEAD", "working directory")
+	headIndex, err = withIntentToAdd(casStore, headIndex, intent)
+	if err != nil {
+		return err
+	}
+
+	return showDiff(casStore, headIndex, currentIndex, "HEAD", "working directory", intent)
 }
 
 // diffWorkingVsCommit shows working directory vs specified commit
@@ -212,7 +336,7 @@ func diffWorkingVsCommit(casStore cas.CAS, ivaldiDir, workDir, commitRef string)
 		return fmt.Errorf("failed to scan workspace: %w", err)
 	}
 
-	return showDiff(casStore, commitIndex, workingIndex, commitRef, "working directory")
+	return showDiff(casStore, commitIndex, workingIndex, commitRef, "working directory", nil)
 }
 
 // diffCommitVsCommit shows diff between two commits
@@ -227,46 +351,186 @@ func diffCommitVsCommit(casStore cas.CAS, ivaldiDir, ref1, ref2 string) error {
 		return err
 	}
 
-	return showDiff(casStore, index1, index2, ref1, ref2)
+	return showDiff(casStore, index1, index2, ref1, ref2, nil)
+}
+
+// runDiffCheck reports whitespace errors in the files that would be sealed.
+// Staged files are checked when anything is gathered, in their staged
+// snapshots with core.snapshotStaging; otherwise the files changed since
+// HEAD are checked. Returns an error if any problem is found,
+// so it can be used from a pre-seal hook.
+func runDiffCheck(casStore cas.CAS, ivaldiDir, workDir string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	rules, err := parseWhitespaceRules(cfg.Core.Whitespace)
+	if err != nil {
+		return err
+	}
+
+	// Scan the workspace the same way seal does so we check the gathered content
+	materializer := workspace.NewMaterializer(casStore, ivaldiDir, workDir)
+	currentIndex, err := materializer.ScanWorkspace()
+	if err != nil {
+		return fmt.Errorf("failed to scan workspace: %w", err)
+	}
+
+	stagedFiles, err := getStagedFilesList(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to get staged files: %w", err)
+	}
+
+	var filesToCheck []wsindex.FileMetadata
+	if len(stagedFiles) > 0 {
+		stagedMap := make(map[string]bool)
+		for _, f := range stagedFiles {
+			stagedMap[f] = true
+		}
+
+		wsLoader := wsindex.NewLoader(casStore)
+		allFiles, err := wsLoader.ListAll(currentIndex)
+		if err != nil {
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+
+		for _, file := range allFiles {
+			if stagedMap[file.Path] {
+				filesToCheck = append(filesToCheck, file)
+			}
+		}
+		// With snapshot staging seal commits the gathered content, not the
+		// working tree
+		filesToCheck, err = withStagedSnapshots(ivaldiDir, stagedFiles, filesToCheck)
+		if err != nil {
+			return err
+		}
+	} else {
+		headIndex, err := getHeadIndex(casStore, ivaldiDir)
+		if err != nil {
+			return err
+		}
+
+		differ := diffmerge.NewDiffer(casStore)
+		diff, err := differ.DiffWorkspaces(headIndex, currentIndex)
+		if err != nil {
+			return fmt.Errorf("failed to compute diff: %w", err)
+		}
+
+		for _, change := range diff.FileChanges {
+			if change.Type != diffmerge.Removed && change.NewFile != nil {
+				filesToCheck = append(filesToCheck, *change.NewFile)
+			}
+		}
+	}
+
+	problemCount := 0
+	for i := range filesToCheck {
+		file := &filesToCheck[i]
+		if isBigFile(file.FileRef.Size) {
+			continue
+		}
+
+		content, err := readFileContent(casStore, file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+
+		if showsAsBinary(content) {
+			continue
+		}
+
+		for _, problem := range checkWhitespace(content, rules) {
+			fmt.Printf("%s: %s.\n", colors.Bold(fmt.Sprintf("%s:%d", file.Path, problem.Line)), problem.Message)
+			if problem.Content != "" {
+				fmt.Printf("%s%s\n", colors.Green("+"), colors.Red(problem.Content))
+			}
+			problemCount++
+		}
+	}
+
+	if problemCount > 0 {
+		return fmt.Errorf("found %d whitespace error(s)", problemCount)
+	}
+
+	return nil
 }
 
 // showDiff displays the diff between two workspace indexes
-func showDiff(casStore cas.CAS, oldIndex, newIndex wsindex.IndexRef, oldName, newName string) error {
-	differ := diffmerge.NewDiffer(casStore)
+func showDiff(casStore cas.CAS, oldIndex, newIndex wsindex.IndexRef, oldName, newName string, intent []string) error {
+	// Trees record neither modification times nor modes, so a file whose
+	// content is unchanged is not a difference
+	differ := &diffmerge.Differ{CAS: casStore, IgnoreModTime: true, IgnoreMode: true}
 	diff, err := differ.DiffWorkspaces(oldIndex, newIndex)
 	if err != nil {
 		return fmt.Errorf("failed to compute diff: %w", err)
 	}
+	markIntentToAddAdded(diff, intent)
+
+	diffFoundChanges = len(diff.FileChanges) > 0
+	if diffQuiet {
+		return nil
+	}
+
+	if diffRaw {
+		return showRawDiff(casStore, oldIndex, diff)
+	}
 
 	if len(diff.FileChanges) == 0 {
 		fmt.Println("No differences.")
 		return nil
 	}
 
+	if diffTool != "" {
+		if toolCmd := config.DifftoolCmd(diffTool); toolCmd != "" {
+			return runDifftool(casStore, diff, toolCmd, newName == "working directory")
+		}
+		fmt.Fprintf(os.Stderr, "%s difftool.%s.cmd is not set; showing the built-in diff\n\n",
+			colors.Yellow("Warning:"), diffTool)
+	}
+
 	// Show statistics if requested
 	if diffStat {
 		return showDiffStats(diff, oldName, newName)
 	}
 
+	// Compute the line diffs up front so moves between files can be found
+	scripts, binary := lineDiffs(casStore, diff.FileChanges)
+	var moved map[int][]bool
+	if diffColorMoved {
+		moved = detectMovedLines(scripts)
+	}
+
 	// Show full diff
-	fmt.Printf("Diff between %s and %s:\n\n", colors.Cyan(oldName), colors.Cyan(newName))
+	fmt.Printf("Diff between %s and %s:\n\n", colors.Slot(colors.DiffMeta, oldName), colors.Slot(colors.DiffMeta, newName))
 
-	for _, change := range diff.FileChanges {
+	for i, change := range diff.FileChanges {
 		switch change.Type {
 		case diffmerge.Added:
-			fmt.Printf("%s %s\n", colors.Green("+++"), colors.Bold(change.Path))
-			if change.NewFile != nil {
+			fmt.Printf("%s %s\n", colors.Slot(colors.DiffAdded, "+++"), colors.Bold(change.Path))
+			switch {
+			case change.NewFile == nil:
+			case change.OldFile != nil:
+				showIntentToAddContent(casStore, change.NewFile)
+			default:
 				showFileContent(casStore, change.NewFile, true)
 			}
 		case diffmerge.Removed:
-			fmt.Printf("%s %s\n", colors.Red("---"), colors.Bold(change.Path))
+			fmt.Printf("%s %s\n", colors.Slot(colors.DiffRemoved, "---"), colors.Bold(change.Path))
 			if change.OldFile != nil {
 				showFileContent(casStore, change.OldFile, false)
 			}
 		case diffmerge.Modified:
-			fmt.Printf("%s %s\n", colors.Blue("M  "), colors.Bold(change.Path))
-			if change.OldFile != nil && change.NewFile != nil {
-				showFileDiff(casStore, change.OldFile, change.NewFile)
+			fmt.Printf("%s %s\n", colors.Slot(colors.DiffModified, "M  "), colors.Bold(change.Path))
+			if ops, ok := scripts[i]; ok {
+				showFileDiff(ops, moved[i])
+			} else if isBigFile(change.OldFile.FileRef.Size) || isBigFile(change.NewFile.FileRef.Size) {
+				fmt.Printf("  %s\n", colors.Gray("(large file, not compared line by line)"))
+			} else if binary[i] {
+				fmt.Printf("  %s\n", colors.Gray(binaryNote(change.NewFile.FileRef.Size)+" differs"))
+			} else {
+				fmt.Printf("  %s\n", colors.Gray("(read error)"))
 			}
 		}
 		fmt.Println()
@@ -275,6 +539,25 @@ func showDiff(casStore cas.CAS, oldIndex, newIndex wsindex.IndexRef, oldName, ne
 	return nil
 }
 
+// markIntentToAddAdded turns the changes of intent-to-add files, which are
+// tracked with empty content, into additions. The empty placeholder is
+// kept as OldFile.
+func markIntentToAddAdded(diff *diffmerge.WorkspaceDiff, intent []string) {
+	if len(intent) == 0 {
+		return
+	}
+	marked := make(map[string]bool, len(intent))
+	for _, path := range intent {
+		marked[path] = true
+	}
+	for i := range diff.FileChanges {
+		change := &diff.FileChanges[i]
+		if change.Type == diffmerge.Modified && marked[change.Path] && change.OldFile.FileRef.Size == 0 {
+			change.Type = diffmerge.Added
+		}
+	}
+}
+
 // showDiffStats shows summary statistics of changes
 func showDiffStats(diff *diffmerge.WorkspaceDiff, oldName, newName string) error {
 	added := 0
@@ -294,12 +577,12 @@ func showDiffStats(diff *diffmerge.WorkspaceDiff, oldName, newName string) error
 
 	total := added + modified + removed
 
-	fmt.Printf("Diff between %s and %s:\n\n", colors.Cyan(oldName), colors.Cyan(newName))
+	fmt.Printf("Diff between %s and %s:\n\n", colors.Slot(colors.DiffMeta, oldName), colors.Slot(colors.DiffMeta, newName))
 	fmt.Printf("  %s changed: %s added, %s modified, %s removed\n",
 		colors.Bold(fmt.Sprintf("%d files", total)),
-		colors.Green(fmt.Sprintf("%d", added)),
-		colors.Blue(fmt.Sprintf("%d", modified)),
-		colors.Red(fmt.Sprintf("%d", removed)))
+		colors.Slot(colors.DiffAdded, fmt.Sprintf("%d", added)),
+		colors.Slot(colors.DiffModified, fmt.Sprintf("%d", modified)),
+		colors.Slot(colors.DiffRemoved, fmt.Sprintf("%d", removed)))
 
 	return nil
 }
@@ -307,64 +590,125 @@ func showDiffStats(diff *diffmerge.WorkspaceDiff, oldName, newName string) error
 // showFileContent shows the content of a file (for added/removed files)
 func showFileContent(casStore cas.CAS, file *wsindex.FileMetadata, added bool) {
 	// For simplicity, just show file size
-	prefix := colors.Red("- ")
+	prefix := colors.Slot(colors.DiffRemoved, "- ")
 	if added {
-		prefix = colors.Green("+ ")
+		prefix = colors.Slot(colors.DiffAdded, "+ ")
 	}
 	fmt.Printf("%sFile size: %d bytes\n", prefix, file.FileRef.Size)
 }
 
-// showFileDiff shows line-by-line diff for modified files
-func showFileDiff(casStore cas.CAS, oldFile, newFile *wsindex.FileMetadata) {
-	// Read file contents
-	oldContent, err := readFileContent(casStore, oldFile)
-	if err != nil {
-		fmt.Printf("  %s\n", colors.Gray("(binary file or read error)"))
+// showIntentToAddContent shows every line of an intent-to-add file as added
+func showIntentToAddContent(casStore cas.CAS, file *wsindex.FileMetadata) {
+	if isBigFile(file.FileRef.Size) {
+		fmt.Printf("  %s\n", colors.Gray("(large file, not compared line by line)"))
 		return
 	}
-
-	newContent, err := readFileContent(casStore, newFile)
+	content, err := readFileContent(casStore, file)
 	if err != nil {
-		fmt.Printf("  %s\n", colors.Gray("(binary file or read error)"))
+		fmt.Printf("  %s\n", colors.Gray("(read error)"))
 		return
 	}
+	if showsAsBinary(content) {
+		fmt.Printf("  %s\n", colors.Gray(binaryNote(file.FileRef.Size)))
+		return
+	}
+	for _, line := range diffmerge.SplitLines(content) {
+		fmt.Printf("%s %s\n", colors.Slot(colors.DiffAdded, "+"), strings.TrimSuffix(line, "\n"))
+	}
+}
+
+// lineDiffs computes the line diff of each modified file, keyed by its
+// position in changes. Files that cannot be read are left out, as are
+// binary files, which are marked in binary.
+func lineDiffs(casStore cas.CAS, changes []diffmerge.FileChange) (scripts map[int][]diffmerge.LineOp, binary map[int]bool) {
+	scripts, binary = make(map[int][]diffmerge.LineOp), make(map[int]bool)
+	for i, change := range changes {
+		if change.Type != diffmerge.Modified || change.OldFile == nil || change.NewFile == nil {
+			continue
+		}
+		if isBigFile(change.OldFile.FileRef.Size) || isBigFile(change.NewFile.FileRef.Size) {
+			continue
+		}
+		oldContent, err := readFileContent(casStore, change.OldFile)
+		if err != nil {
+			continue
+		}
+		newContent, err := readFileContent(casStore, change.NewFile)
+		if err != nil {
+			continue
+		}
+		if showsAsBinary(oldContent, newContent) {
+			binary[i] = true
+			continue
+		}
+		scripts[i] = diffWhitespace.DiffLines(diffmerge.SplitLines(oldContent), diffmerge.SplitLines(newContent))
+	}
+	return scripts, binary
+}
 
-	// Simple line-by-line diff
-	oldLines := strings.Split(string(oldContent), "\n")
-	newLines := strings.Split(string(newContent), "\n")
+// detectMovedLines marks the lines of each line diff that belong to a block
+// moved within or between files
+func detectMovedLines(scripts map[int][]diffmerge.LineOp) map[int][]bool {
+	var keys []int
+	for i := range scripts {
+		keys = append(keys, i)
+	}
+	sort.Ints(keys)
+
+	ordered := make([][]diffmerge.LineOp, len(keys))
+	for k, i := range keys {
+		ordered[k] = scripts[i]
+	}
 
-	// Show up to 20 lines of diff
+	marks := diffmerge.DetectMoves(ordered, diffWhitespace.Normalize)
+	moved := make(map[int][]bool, len(keys))
+	for k, i := range keys {
+		moved[i] = marks[k]
+	}
+	return moved
+}
+
+// showFileDiff shows line-by-line diff for modified files. Lines marked in
+// moved are shown with < where they were removed and > where they were added.
+func showFileDiff(ops []diffmerge.LineOp, moved []bool) {
+	// Show up to 20 changed lines, skipping those the whitespace options hide
 	maxLines := 20
 	shown := 0
 
-	for i := 0; i < len(oldLines) || i < len(newLines); i++ {
+	for i, op := range ops {
+		if op.Type == diffmerge.LineEqual || diffWhitespace.Ignores(op) {
+			continue
+		}
 		if shown >= maxLines {
 			fmt.Printf("  %s\n", colors.Gray("... (diff truncated)"))
 			break
 		}
 
-		oldLine := ""
-		newLine := ""
-		if i < len(oldLines) {
-			oldLine = oldLines[i]
-		}
-		if i < len(newLines) {
-			newLine = newLines[i]
-		}
-
-		if oldLine != newLine {
-			if oldLine != "" && i < len(oldLines) {
-				fmt.Printf("%s %s\n", colors.Red("-"), oldLine)
-				shown++
-			}
-			if newLine != "" && i < len(newLines) {
-				fmt.Printf("%s %s\n", colors.Green("+"), newLine)
-				shown++
-			}
+		line := strings.TrimSuffix(op.Text, "\n")
+		switch {
+		case moved != nil && moved[i] && op.Type == diffmerge.LineDelete:
+			fmt.Printf("%s %s\n", colors.Slot(colors.DiffMovedFrom, "<"), colors.Slot(colors.DiffMovedFrom, line))
+		case moved != nil && moved[i]:
+			fmt.Printf("%s %s\n", colors.Slot(colors.DiffMovedTo, ">"), colors.Slot(colors.DiffMovedTo, line))
+		case op.Type == diffmerge.LineDelete:
+			fmt.Printf("%s %s\n", colors.Slot(colors.DiffRemoved, "-"), line)
+		default:
+			fmt.Printf("%s %s\n", colors.Slot(colors.DiffAdded, "+"), line)
 		}
+		shown++
 	}
 }
 
+// bigFileThreshold is core.bigFileThreshold, read once
+var bigFileThreshold = sync.OnceValue(config.BigFileThreshold)
+
+// isBigFile reports whether a file of the given size is above
+// core.bigFileThreshold. Diffs note changes to such files like binary
+// ones, without reading them.
+func isBigFile(size int64) bool {
+	return size > bigFileThreshold()
+}
+
 // readFileContent reads the content of a file from its metadata
 func readFileContent(casStore cas.CAS, file *wsindex.FileMetadata) ([]byte, error) {
 	loader := filechunk.NewLoader(casStore)
@@ -400,27 +744,12 @@ func getHeadIndex(casStore cas.CAS, ivaldiDir string) (wsindex.IndexRef, error)
 
 // getCommitIndex returns the workspace index for a commit
 func getCommitIndex(casStore cas.CAS, commitHash [32]byte) (wsindex.IndexRef, error) {
-	var hash cas.Hash
-	copy(hash[:], commitHash[:])
-
-	commitReader := commit.NewCommitReader(casStore)
-	commitObj, err := commitReader.ReadCommit(hash)
-	if err != nil {
-		return wsindex.IndexRef{}, fmt.Errorf("failed to read commit: %w", err)
-	}
-
-	_, err = commitReader.ReadTree(commitObj)
-	if err != nil {
-		return wsindex.IndexRef{}, fmt.Errorf("failed to read tree: %w", err)
-	}
-
-	// Build workspace index from tree files
-	// This is a simplified version - in reality we'd need to properly convert tree entries to FileMetadata
-	wsBuilder := wsindex.NewBuilder(casStore)
-	return wsBuilder.Build(nil) // TODO: Convert tree to FileMetadata
+	materializer := &workspace.Materializer{CAS: casStore}
+	return materializer.CreateTargetIndex(refs.Timeline{Blake3Hash: commitHash})
 }
 
-// getCommitIndexByRef resolves a ref (seal name or hash) to a workspace index
+// getCommitIndexByRef resolves a ref (HEAD, timeline, seal name or hash
+// prefix, optionally with ~N) to a workspace index
 func getCommitIndexByRef(casStore cas.CAS, ivaldiDir, ref string) (wsindex.IndexRef, error) {
 	refsManager, err := refs.NewRefsManager(ivaldiDir)
 	if err != nil {
@@ -428,14 +757,11 @@ func getCommitIndexByRef(casStore cas.CAS, ivaldiDir, ref string) (wsindex.Index
 	}
 	defer refsManager.Close()
 
-	// Try to resolve as seal name first
-	commitHash, _, _, err := refsManager.GetSealByName(ref)
-	if err == nil {
-		return getCommitIndex(casStore, commitHash)
+	commitHash, err := resolveCommitRef(casStore, refsManager, ref)
+	if err != nil {
+		return wsindex.IndexRef{}, err
 	}
-
-	// Try as short hash (TODO: implement hash prefix resolution)
-	return wsindex.IndexRef{}, fmt.Errorf("commit not found: %s", ref)
+	return getCommitIndex(casStore, commitHash)
 }
 
 // getStagedFilesList returns the list of staged files
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// rawEntry is one line of diff --raw output
+type rawEntry struct {
+	oldFile, newFile *wsindex.FileMetadata
+	status           string
+	paths            []string
+}
+
+// showRawDiff prints one line per changed file in the format of git diff
+// --raw, with the modes and content hashes of both sides taken from the
+// compared workspace indexes:
+//
+//	:<old mode> <new mode> <old hash> <new hash> <status>\t<path>[\t<new path>]
+//
+// The side a file is missing from has mode 000000 and an all-zero hash.
+// With --find-renames, removed and added files that are renames show as
+// R<score> with both paths. With --find-copies, added files that copy a
+// file of oldIndex that is still present show as C<score> with the source
+// and the new path; it implies --find-renames. Above diff.renameLimit only
+// exact renames and copies are detected, and a warning says so.
+func showRawDiff(casStore cas.CAS, oldIndex wsindex.IndexRef, diff *diffmerge.WorkspaceDiff) error {
+	var renames []diffmerge.RenameDetection
+	var copies []diffmerge.CopyDetection
+	oldFiles := make(map[string]*wsindex.FileMetadata)
+	if diffFindRenames != "" || diffFindCopies != "" {
+		renameFlag, renameValue := "--find-renames", diffFindRenames
+		if renameValue == "" {
+			renameFlag, renameValue = "--find-copies", diffFindCopies
+		}
+		threshold, err := parseRenameThreshold(renameFlag, renameValue)
+		if err != nil {
+			return err
+		}
+		analyzer := diffmerge.NewAnalyzer(casStore)
+		analyzer.RenameLimit = config.DiffRenameLimit()
+		renames = analyzer.DetectRenames(diff, threshold)
+		if diffFindCopies != "" {
+			copyThreshold, err := parseRenameThreshold("--find-copies", diffFindCopies)
+			if err != nil {
+				return err
+			}
+			base, err := wsindex.NewLoader(casStore).ListAll(oldIndex)
+			if err != nil {
+				return fmt.Errorf("failed to list files: %w", err)
+			}
+			copies = analyzer.DetectCopies(diff, base, renames, copyThreshold)
+			for i := range base {
+				oldFiles[base[i].Path] = &base[i]
+			}
+		}
+		if analyzer.RenameLimitNeeded > 0 {
+			fmt.Fprintf(os.Stderr, "%s inexact rename detection was skipped due to too many files.\n", colors.Yellow("Warning:"))
+			fmt.Fprintf(os.Stderr, "%s you may want to set diff.renameLimit to at least %d and retry the command.\n",
+				colors.Yellow("Warning:"), analyzer.RenameLimitNeeded)
+		}
+	}
+
+	renamedFrom := make(map[string]diffmerge.RenameDetection, len(renames))
+	renamedTo := make(map[string]diffmerge.RenameDetection, len(renames))
+	for _, rename := range renames {
+		renamedFrom[rename.OldPath] = rename
+		renamedTo[rename.NewPath] = rename
+	}
+	copiedTo := make(map[string]diffmerge.CopyDetection, len(copies))
+	for _, cp := range copies {
+		copiedTo[cp.NewPath] = cp
+	}
+	removedFiles := make(map[string]*wsindex.FileMetadata)
+	for _, change := range diff.FileChanges {
+		if change.Type == diffmerge.Removed {
+			removedFiles[change.Path] = change.OldFile
+		}
+	}
+
+	var entries []rawEntry
+	for _, change := range diff.FileChanges {
+		switch change.Type {
+		case diffmerge.Added:
+			if rename, ok := renamedTo[change.Path]; ok {
+				entries = append(entries, rawEntry{
+					oldFile: removedFiles[rename.OldPath],
+					newFile: change.NewFile,
+					status:  fmt.Sprintf("R%03d", int(rename.Similarity*100)),
+					paths:   []string{rename.OldPath, rename.NewPath},
+				})
+				continue
+			}
+			if cp, ok := copiedTo[change.Path]; ok {
+				entries = append(entries, rawEntry{
+					oldFile: oldFiles[cp.OldPath],
+					newFile: change.NewFile,
+					status:  fmt.Sprintf("C%03d", int(cp.Similarity*100)),
+					paths:   []string{cp.OldPath, cp.NewPath},
+				})
+				continue
+			}
+			entries = append(entries, rawEntry{newFile: change.NewFile, status: "A", paths: []string{change.Path}})
+		case diffmerge.Removed:
+			if _, ok := renamedFrom[change.Path]; ok {
+				continue
+			}
+			entries = append(entries, rawEntry{oldFile: change.OldFile, status: "D", paths: []string{change.Path}})
+		case diffmerge.Modified:
+			entries = append(entries, rawEntry{oldFile: change.OldFile, newFile: change.NewFile, status: "M", paths: []string{change.Path}})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].paths[0] < entries[j].paths[0]
+	})
+
+	for _, entry := range entries {
+		fmt.Printf(":%s %s %s %s %s\t%s\n",
+			rawMode(entry.oldFile), rawMode(entry.newFile),
+			rawHash(entry.oldFile), rawHash(entry.newFile),
+			entry.status, strings.Join(entry.paths, "\t"))
+	}
+	return nil
+}
+
+// rawMode returns the Git mode of a file, or 000000 for a missing side.
+// Seals do not record modes, so their files show as regular files.
+func rawMode(file *wsindex.FileMetadata) string {
+	switch {
+	case file == nil:
+		return "000000"
+	case os.FileMode(file.Mode)&os.ModeSymlink != 0:
+		return "120000"
+	case file.Mode&0111 != 0:
+		return "100755"
+	default:
+		return "100644"
+	}
+}
+
+// rawHash returns the content hash of a file, or zeros for a missing side
+func rawHash(file *wsindex.FileMetadata) string {
+	if file == nil {
+		return strings.Repeat("0", 2*len(cas.Hash{}))
+	}
+	return file.FileRef.Hash.String()
+}
+
+// parseRenameThreshold parses the value of --find-renames or --find-copies,
+// named by flag, like Git does: "90%" is 90 percent, and bare digits are the
+// fraction after the decimal point, so "9" and "90" are 90 percent as well
+func parseRenameThreshold(flag, value string) (float64, error) {
+	invalid := fmt.Errorf("invalid %s value %q (expected e.g. 50%% or 5)", flag, value)
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		n, err := strconv.Atoi(percent)
+		if err != nil || n < 0 || n > 100 {
+			return 0, invalid
+		}
+		return float64(n) / 100, nil
+	}
+	if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+		return 0, invalid
+	}
+	threshold, err := strconv.ParseFloat("0."+value, 64)
+	if err != nil {
+		return 0, invalid
+	}
+	return threshold, nil
+}
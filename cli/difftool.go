@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// runDifftool opens the changed files one at a time in an external diff
+// tool, waiting for it to exit before moving on. The tool command runs
+// through the shell with $LOCAL naming the old version and $REMOTE the
+// new one. Versions that only exist in the object store are written to
+// temporary files, which are removed afterwards; when the new side is the
+// working directory, $REMOTE is the working file itself so edits made in
+// the tool are kept.
+func runDifftool(casStore cas.CAS, diff *diffmerge.WorkspaceDiff, toolCmd string, newIsWorkTree bool) error {
+	tmpDir, err := os.MkdirTemp("", "ivaldi-difftool-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for i, change := range diff.FileChanges {
+		local, err := writeDifftoolSide(casStore, tmpDir, "old", change.Path, change.OldFile)
+		if err != nil {
+			return err
+		}
+
+		var remote string
+		if newIsWorkTree && change.Type != diffmerge.Removed {
+			if remote, err = filepath.Abs(change.Path); err != nil {
+				return fmt.Errorf("failed to resolve %s: %w", change.Path, err)
+			}
+		} else if remote, err = writeDifftoolSide(casStore, tmpDir, "new", change.Path, change.NewFile); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s %s\n", colors.Gray(fmt.Sprintf("Viewing (%d/%d):", i+1, len(diff.FileChanges))), colors.Bold(change.Path))
+		tool := exec.Command("sh", "-c", toolCmd)
+		tool.Env = append(os.Environ(), "LOCAL="+local, "REMOTE="+remote, "MERGED="+change.Path)
+		tool.Stdin = os.Stdin
+		tool.Stdout = os.Stdout
+		tool.Stderr = os.Stderr
+		// Diff programs commonly exit non-zero when the files differ, so
+		// only a tool that cannot be started is an error
+		var exitErr *exec.ExitError
+		if err := tool.Run(); err != nil && !errors.As(err, &exitErr) {
+			return fmt.Errorf("failed to run difftool: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeDifftoolSide writes one version of a file under tmpDir/side and
+// returns its path. A missing version, as for added or removed files, is
+// written as an empty file.
+func writeDifftoolSide(casStore cas.CAS, tmpDir, side, path string, file *wsindex.FileMetadata) (string, error) {
+	// Keep the file name so tools can pick a syntax from the extension
+	target := filepath.Join(tmpDir, side, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	var content []byte
+	if file != nil {
+		var err error
+		if content, err = readFileContent(casStore, file); err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	if err := os.WriteFile(target, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return target, nil
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+// Exit statuses of commands run with --exit-code; 0 means no differences
+const (
+	exitDifferences = 1 // Differences found
+	exitFailure     = 2 // The command failed
+)
+
+// exitCodeError makes Execute exit with a specific status. Without an
+// underlying error nothing is printed.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+// exitCodeOf returns the status Execute should exit with for an error
+func exitCodeOf(err error) int {
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return 1
+}
+
+// withExitCode applies --exit-code to the outcome of a command: failures
+// exit with 2 so scripts can tell them from differences, which exit with 1
+// without printing anything more
+func withExitCode(cmd *cobra.Command, enabled, changed bool, err error) error {
+	if !enabled {
+		return err
+	}
+	if err != nil {
+		return &exitCodeError{code: exitFailure, err: err}
+	}
+	if changed {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &exitCodeError{code: exitDifferences}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/github"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fetchDepth int
+	fetchPrune bool
+	fetchAll   bool
+	fetchJobs  int
+
+	fetchNoTags    bool
+	fetchPruneTags bool
+)
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [timeline...]",
+	Short: "Import the commit history of remote timelines",
+	Long: `Fetch imports the history of remote timelines (branches) from GitHub commit
+by commit, keeping each commit's author, time, message and parents.
+
+Commits imported before, by an earlier fetch or another command, are
+recognized by their Git SHA and not downloaded again, and only files that
+changed between commits are downloaded.
+
+Without arguments, the upstream branch and repository of the current
+timeline are fetched (see 'ivaldi upload --set-upstream'), or the branch
+named after the timeline when no upstream is recorded.
+
+The remote timeline is moved to the fetched tip. The local timeline of the
+same name is created if it does not exist and fast-forwarded if it has no
+seals of its own; the checked-out timeline and diverged timelines are left
+where they are. The working directory is never changed.
+
+With --all, every local timeline with a recorded upstream is fetched from
+its upstream branch, up to --jobs at a time, and fast-forwarded when it has
+no seals of its own. Timelines with uncommitted changes are skipped: the
+checked-out timeline when the workspace has changes, and other timelines
+when they have auto-shelved changes. A clean checked-out timeline is
+fast-forwarded too, and the workspace updated to match. The files each
+timeline gained, changed and lost are reported.
+
+With --prune, remote timelines whose branch has been deleted on GitHub are
+removed after fetching. Local timelines are never removed.
+
+Tags of the repository are fetched too, into local tags that 'show', 'diff'
+and 'log' accept like timelines but that are listed separately. A tag is
+only fetched once the commit it points at has been fetched; annotated tags
+keep their tagger and message. Tags that moved upstream are updated, but
+local tags of the same name are never overwritten. With --prune-tags, or
+fetch.pruneTags set to true, fetched tags deleted upstream are removed.
+--no-tags skips tags altogether.
+
+Examples:
+  ivaldi fetch                   # Fetch the current timeline's upstream
+  ivaldi fetch main feature-x    # Fetch specific timelines
+  ivaldi fetch --depth 50 main   # Only the last 50 generations of history
+  ivaldi fetch --prune           # Also drop remote timelines deleted upstream
+  ivaldi fetch --prune-tags      # Also drop tags deleted upstream
+  ivaldi fetch --all --jobs 8    # Fetch and fast-forward all tracked timelines`,
+	RunE: runFetch,
+}
+
+func init() {
+	fetchCmd.Flags().IntVar(&fetchDepth, "depth", 0, "Limit history to this many generations from the tip (0 for all)")
+	fetchCmd.Flags().BoolVar(&fetchPrune, "prune", false, "Remove remote timelines whose branch no longer exists on GitHub")
+	fetchCmd.Flags().BoolVar(&fetchAll, "all", false, "Fetch every timeline with an upstream and fast-forward it")
+	fetchCmd.Flags().IntVarP(&fetchJobs, "jobs", "j", 4, "Number of timelines to fetch at a time with --all")
+	fetchCmd.Flags().BoolVar(&fetchNoTags, "no-tags", false, "Do not fetch tags")
+	fetchCmd.Flags().BoolVarP(&fetchPruneTags, "prune-tags", "P", false, "Remove fetched tags that no longer exist on GitHub (fetch.pruneTags)")
+}
+
+func runFetch(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+	if fetchDepth < 0 {
+		return fmt.Errorf("--depth must not be negative")
+	}
+	if fetchAll && len(args) > 0 {
+		return fmt.Errorf("--all cannot be combined with timeline arguments")
+	}
+	if fetchJobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1")
+	}
+	if fetchNoTags && fetchPruneTags {
+		return fmt.Errorf("--prune-tags cannot be used with --no-tags")
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	if fetchAll {
+		return runFetchAll(ivaldiDir, workDir)
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	owner, repo, timelines, err := fetchTargets(refsManager, args)
+	refsManager.Close()
+	if err != nil {
+		return err
+	}
+
+	syncer, err := github.NewRepoSyncer(ivaldiDir, workDir)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub syncer: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	failed := 0
+	for _, name := range timelines {
+		fmt.Printf("Fetching %s from %s/%s...\n", colors.Bold(name), owner, repo)
+
+		result, err := syncer.FetchHistory(ctx, owner, repo, name, fetchDepth)
+		if err != nil {
+			fmt.Printf("%s %v\n", colors.Red("Error:"), err)
+			failed++
+			continue
+		}
+		printFetchResult(name, name, result)
+	}
+
+	if fetchPrune {
+		if err := pruneFetched(ctx, syncer, owner, repo); err != nil {
+			return err
+		}
+	}
+
+	if !fetchNoTags {
+		if err := fetchRemoteTags(ctx, syncer, owner, repo); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to fetch %d timeline(s)", failed)
+	}
+	return nil
+}
+
+// fetchTargets returns the repository and the timelines to fetch. Without
+// arguments the current timeline's upstream is fetched, or the branch of the
+// same name when it has none.
+func fetchTargets(refsManager *refs.RefsManager, args []string) (owner, repo string, timelines []string, err error) {
+	timelines = args
+	if len(timelines) == 0 {
+		current, err := refsManager.GetCurrentTimeline()
+		if err != nil {
+			return "", "", nil, fmt.Errorf("failed to get current timeline: %w", err)
+		}
+		timelines = []string{current}
+
+		remote, merge, err := config.GetUpstream(current)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("failed to read upstream of timeline '%s': %w", current, err)
+		}
+		if remote != "" {
+			if owner, repo, err = splitUpstreamRemote(current, remote); err != nil {
+				return "", "", nil, err
+			}
+		}
+		if merge != "" {
+			timelines = []string{merge}
+		}
+	}
+
+	if owner == "" {
+		if owner, repo, err = refsManager.GetGitHubRepository(); err != nil {
+			return "", "", nil, fmt.Errorf("no GitHub repository configured. Use 'ivaldi portal add owner/repo' or download from GitHub first")
+		}
+	}
+	return owner, repo, timelines, nil
+}
+
+// pruneFetched removes the remote timelines of branches deleted from a
+// repository and reports them
+func pruneFetched(ctx context.Context, syncer *github.RepoSyncer, owner, repo string) error {
+	pruned, err := syncer.PruneRemoteTimelines(ctx, owner, repo)
+	for _, name := range pruned {
+		fmt.Printf("%s remote timeline %s\n", colors.Yellow("Pruned"), colors.Bold(name))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune remote timelines: %w", err)
+	}
+	if len(pruned) == 0 {
+		fmt.Println("No remote timelines to prune")
+	}
+	return nil
+}
+
+// fetchRemoteTags imports the tags of a repository and reports what changed
+func fetchRemoteTags(ctx context.Context, syncer *github.RepoSyncer, owner, repo string) error {
+	result, err := syncer.FetchTags(ctx, owner, repo, fetchPruneTags || config.PruneTags())
+	if err != nil {
+		return fmt.Errorf("failed to fetch tags from %s/%s: %w", owner, repo, err)
+	}
+
+	for _, name := range result.Created {
+		fmt.Printf("%s New tag %s\n", colors.SuccessText("[OK]"), colors.Bold(name))
+	}
+	for _, name := range result.Updated {
+		fmt.Printf("%s Updated tag %s\n", colors.SuccessText("[OK]"), colors.Bold(name))
+	}
+	for _, name := range result.Pruned {
+		fmt.Printf("%s tag %s\n", colors.Yellow("Pruned"), colors.Bold(name))
+	}
+	for _, name := range result.Conflicts {
+		fmt.Printf("%s Local tag %s points elsewhere and was left in place\n", colors.Yellow("Note:"), colors.Bold(name))
+	}
+	if len(result.Unfetched) > 0 {
+		fmt.Printf("%s\n", colors.Dim(fmt.Sprintf("%d tag(s) point at commits that have not been fetched: %s",
+			len(result.Unfetched), summarizeNames(result.Unfetched, 5))))
+	}
+	return nil
+}
+
+// summarizeNames joins up to limit names, noting how many were left out
+func summarizeNames(names []string, limit int) string {
+	if len(names) <= limit {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:limit], ", "), len(names)-limit)
+}
+
+// splitUpstreamRemote splits the branch.<timeline>.remote value of a
+// timeline into owner and repository
+func splitUpstreamRemote(timeline, remote string) (owner, repo string, err error) {
+	owner, repo, ok := strings.Cut(remote, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", "", fmt.Errorf("invalid branch.%s.remote value: %s (expected owner/repo)", timeline, remote)
+	}
+	return owner, repo, nil
+}
+
+// printFetchResult reports the outcome of fetching a branch into the local
+// timeline name
+func printFetchResult(name, branch string, result *github.FetchResult) {
+	if result.Imported == 0 {
+		fmt.Printf("  No new commits, tip %s\n", result.TipSHA[:7])
+	} else {
+		fmt.Printf("  Imported %d commit(s), tip %s\n", result.Imported, result.TipSHA[:7])
+	}
+	if result.Shallow {
+		fmt.Printf("  %s\n", colors.Dim(fmt.Sprintf("History cut off at depth %d", fetchDepth)))
+	}
+
+	switch result.Local {
+	case github.LocalCreated:
+		fmt.Printf("  %s Created timeline %s\n", colors.SuccessText("[OK]"), colors.Bold(name))
+	case github.LocalFastForwarded:
+		fmt.Printf("  %s Fast-forwarded timeline %s\n", colors.SuccessText("[OK]"), colors.Bold(name))
+	case github.LocalUpToDate:
+		fmt.Printf("  Timeline %s is up to date\n", colors.Bold(name))
+	case github.LocalCheckedOut:
+		fmt.Printf("  %s Timeline %s is checked out and was left in place; the fetched tip is on remote timeline %s\n",
+			colors.Yellow("Note:"), colors.Bold(name), colors.Bold(branch))
+	case github.LocalDiverged:
+		fmt.Printf("  %s Timeline %s has seals that are not on the remote and was left in place\n",
+			colors.Yellow("Note:"), colors.Bold(name))
+	}
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/github"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/shelf"
+	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
+)
+
+// skippedTimeline is a timeline fetch --all leaves alone, with the reason
+type skippedTimeline struct {
+	name   string
+	reason string
+}
+
+// runFetchAll fetches the upstream of every local timeline that has one and
+// fast-forwards the timelines, updating the workspace when the checked-out
+// timeline moves
+func runFetchAll(ivaldiDir, workDir string) error {
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	materializer := workspace.NewMaterializer(casStore, ivaldiDir, workDir)
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	current, _ := refsManager.GetCurrentTimeline()
+	targets, skipped, err := upstreamTargets(refsManager, materializer, current)
+	refsManager.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range skipped {
+		fmt.Printf("%s %s: %s\n", colors.Yellow("Skipping"), colors.Bold(s.name), s.reason)
+	}
+	if len(targets) == 0 {
+		if len(skipped) == 0 {
+			fmt.Println("No timelines have an upstream. Record one with 'ivaldi upload --set-upstream'")
+		}
+		return nil
+	}
+
+	syncer, err := github.NewRepoSyncer(ivaldiDir, workDir)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub syncer: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	results := syncer.FetchUpstreams(ctx, targets, fetchDepth, fetchJobs)
+	fmt.Println()
+
+	failed := 0
+	for _, result := range results {
+		target := result.Target
+		fmt.Printf("%s (%s/%s %s)\n", colors.Bold(target.Timeline), target.Owner, target.Repo, target.Branch)
+		if result.Err != nil {
+			fmt.Printf("  %s %v\n", colors.Red("Error:"), result.Err)
+			failed++
+			continue
+		}
+		printFetchResult(target.Timeline, target.Branch, result.Fetch)
+		if result.Delta != nil {
+			printFetchDelta(result.Delta)
+		}
+
+		if target.Timeline == current && result.Fetch.Local == github.LocalFastForwarded && !isBareRepository() {
+			if err := materializer.MaterializeTimelineWithAutoShelf(current, false); err != nil {
+				fmt.Printf("  %s failed to update the workspace: %v\n", colors.Red("Error:"), err)
+				failed++
+				continue
+			}
+			fmt.Printf("  Updated the workspace to the new tip\n")
+		}
+	}
+
+	// Each repository is pruned and has its tags fetched once
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		key := target.Owner + "/" + target.Repo
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if fetchPrune {
+			if err := pruneFetched(ctx, syncer, target.Owner, target.Repo); err != nil {
+				return err
+			}
+		}
+		if !fetchNoTags {
+			if err := fetchRemoteTags(ctx, syncer, target.Owner, target.Repo); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to fetch %d timeline(s)", failed)
+	}
+	return nil
+}
+
+// upstreamTargets returns the local timelines with a recorded upstream,
+// sorted by name, and those of them that must be skipped because they have
+// uncommitted changes
+func upstreamTargets(refsManager *refs.RefsManager, materializer *workspace.Materializer, current string) ([]github.UpstreamTarget, []skippedTimeline, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	names := make([]string, 0, len(cfg.Branch))
+	for name, branch := range cfg.Branch {
+		if branch.Remote == "" && branch.Merge == "" {
+			continue
+		}
+		if refsManager.TimelineExists(name, refs.LocalTimeline) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	shelfManager := shelf.NewShelfManager(materializer.CAS, materializer.IvaldiDir)
+
+	var targets []github.UpstreamTarget
+	var skipped []skippedTimeline
+	for _, name := range names {
+		branch := cfg.Branch[name]
+		target := github.UpstreamTarget{Timeline: name, Branch: branch.Merge}
+		if target.Branch == "" {
+			target.Branch = name
+		}
+		if branch.Remote != "" {
+			if target.Owner, target.Repo, err = splitUpstreamRemote(name, branch.Remote); err != nil {
+				return nil, nil, err
+			}
+		} else if target.Owner, target.Repo, err = refsManager.GetGitHubRepository(); err != nil {
+			skipped = append(skipped, skippedTimeline{name, "no GitHub repository configured"})
+			continue
+		}
+
+		if name == current {
+			dirty, err := workspaceHasChanges(materializer)
+			if err != nil {
+				return nil, nil, err
+			}
+			if dirty {
+				skipped = append(skipped, skippedTimeline{name, "the workspace has uncommitted changes"})
+				continue
+			}
+			target.MoveCheckedOut = true
+		} else if autoShelf, err := shelfManager.GetAutoShelf(name); err == nil && autoShelf != nil {
+			skipped = append(skipped, skippedTimeline{name, fmt.Sprintf("has auto-shelved changes (shelf: %s)", autoShelf.ID)})
+			continue
+		}
+
+		targets = append(targets, target)
+	}
+	return targets, skipped, nil
+}
+
+// workspaceHasChanges reports whether the workspace has staged files or
+// differs from the checked-out timeline. A bare repository has no changes.
+func workspaceHasChanges(materializer *workspace.Materializer) (bool, error) {
+	if isBareRepository() {
+		return false, nil
+	}
+	stagedFiles, err := getStagedFilesList(materializer.IvaldiDir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read staged files: %w", err)
+	}
+	if len(stagedFiles) > 0 {
+		return true, nil
+	}
+	status, err := materializer.GetWorkspaceStatus()
+	if err != nil {
+		return false, fmt.Errorf("failed to get workspace status: %w", err)
+	}
+	return !status.Clean, nil
+}
+
+// printFetchDelta reports the files a fast-forward added, modified and
+// deleted
+func printFetchDelta(delta *github.TimelineDelta) {
+	if delta.NoChanges {
+		fmt.Println("  No file changes")
+		return
+	}
+	fmt.Printf("  Files: %s added, %s modified, %s deleted\n",
+		colors.Green(fmt.Sprintf("%d", len(delta.AddedFiles))),
+		colors.Blue(fmt.Sprintf("%d", len(delta.ModifiedFiles))),
+		colors.Red(fmt.Sprintf("%d", len(delta.DeletedFiles))))
+}
@@ -2,6 +2,8 @@ package cli
 
 import (
 	"bufio"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,7 +12,9 @@ import (
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/colors"
 	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
 	"github.com/javanhut/Ivaldi-vcs/internal/history"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/javanhut/Ivaldi-vcs/internal/seals"
@@ -30,8 +34,12 @@ Examples:
   ivaldi fuse main                          # Fuse main into current timeline (auto strategy)
   ivaldi fuse main to new_tl                # Fuse main into new_tl
   ivaldi fuse feature-x                     # Fuse feature-x into current timeline
+  ivaldi fuse --plan feature-x              # Show what fusing feature-x would do
   ivaldi fuse --strategy=theirs feature     # Accept all source changes
   ivaldi fuse --strategy=ours feature       # Keep all target changes
+  ivaldi fuse --markers feature             # Write conflict markers into conflicted files
+  ivaldi fuse --resolve src/app.go          # Record your edited file as resolved
+  ivaldi fuse --resolve --strategy=theirs a.go  # Resolve a file with the source version
   ivaldi fuse --continue                    # Continue merge after resolving conflicts
   ivaldi fuse --abort                       # Abort current merge
 
@@ -40,42 +48,92 @@ Strategies:
   ours    - Keep target timeline version
   theirs  - Accept source timeline version
   union   - Combine both versions
-  base    - Revert to common ancestor`,
+  base    - Revert to common ancestor
+
+Without --strategy, fuse uses branch.<target>.mergeStrategy, then
+merge.defaultStrategy, then auto:
+  ivaldi config merge.defaultStrategy union
+  ivaldi config branch.release.mergeStrategy ours
+
+With --plan, fuse works out the merge and stops before applying it: it
+reports whether the target would be fast-forwarded or merged, the merge
+base, and the files that would change or conflict. No timeline, workspace
+file or merge state is touched.
+
+Conflicts are normally kept out of the working directory. With --markers,
+conflicted text files are written with the changes of both sides merged
+and the overlapping ones between conflict markers, to be edited and
+gathered. merge.conflictStyle selects the markers: "merge" writes the two
+sides, "diff3" also the base content between ||||||| and =======. Markers
+are only written when fusing into the current timeline, and 'fuse --abort'
+restores the files.`,
 	RunE: runFuse,
 }
 
 var (
 	fuseContinue bool
 	fuseAbort    bool
+	fuseResolve  bool
 	fuseStrategy string
+	fuseSignoff  bool
+	fuseTrailers []string
+	fusePlan     bool
+	fuseMarkers  bool
 )
 
 func init() {
 	fuseCmd.Flags().BoolVar(&fuseContinue, "continue", false, "Continue merge after resolving conflicts")
 	fuseCmd.Flags().BoolVar(&fuseAbort, "abort", false, "Abort current merge")
-	fuseCmd.Flags().StringVar(&fuseStrategy, "strategy", "auto", "Merge strategy (auto, ours, theirs, union, base)")
+	fuseCmd.Flags().BoolVar(&fuseResolve, "resolve", false, "Record resolutions for the given conflicted files")
+	fuseCmd.Flags().StringVar(&fuseStrategy, "strategy", "auto", "Merge strategy (auto, ours, theirs, union, base; default: merge.defaultStrategy or auto)")
+	fuseCmd.Flags().BoolVarP(&fuseSignoff, "signoff", "s", false, "Add a Signed-off-by trailer to the merge seal")
+	fuseCmd.Flags().StringArrayVar(&fuseTrailers, "trailer", nil, "Add a \"Key: Value\" trailer to the merge seal message (repeatable)")
+	fuseCmd.Flags().BoolVar(&fusePlan, "plan", false, "Show what the fuse would do without changing anything")
+	fuseCmd.Flags().BoolVar(&fuseMarkers, "markers", false, "Write conflict markers into conflicted text files (style: merge.conflictStyle)")
 }
 
 func runFuse(cmd *cobra.Command, args []string) error {
 	// Check if we're in an Ivaldi repository
-	ivaldiDir := ".ivaldi"
+	ivaldiDir := config.IvaldiDir()
 	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 	}
 
+	if err := requireWorkTree("fuse"); err != nil {
+		return err
+	}
+
 	workDir, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get working directory: %w", err)
 	}
 
+	if fusePlan && (fuseAbort || fuseResolve || fuseContinue) {
+		return fmt.Errorf("--plan cannot be used with --abort, --resolve or --continue")
+	}
+
 	// Handle --abort flag
 	if fuseAbort {
-		return abortMerge(ivaldiDir)
+		return abortMerge(ivaldiDir, workDir)
+	}
+
+	// Check trailers before merging anything
+	if _, err := withTrailers("", fuseTrailers, fuseSignoff); err != nil {
+		return err
+	}
+
+	// Handle --resolve flag
+	if fuseResolve {
+		return resolveConflicts(ivaldiDir, workDir, args, fuseStrategy)
 	}
 
 	// Handle --continue flag
 	if fuseContinue {
-		return continueMerge(ivaldiDir, workDir)
+		if err := continueMerge(ivaldiDir, workDir); err != nil {
+			return err
+		}
+		autoGC(ivaldiDir)
+		return nil
 	}
 
 	// Check if merge is already in progress
@@ -115,13 +173,37 @@ func runFuse(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("cannot fuse timeline '%s' into itself", sourceTimeline)
 	}
 
-	fmt.Printf("%s Fusing %s into %s...\n\n",
+	strategySource := "default"
+	if cmd.Flags().Changed("strategy") {
+		strategySource = "--strategy"
+	} else {
+		strategy, key, err := config.GetMergeStrategy(targetTimeline)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if strategy != "" {
+			fuseStrategy, strategySource = strategy, key
+		}
+	}
+
+	fmt.Printf("%s Fusing %s into %s...\n",
 		colors.Cyan(">>"),
 		colors.Bold(sourceTimeline),
 		colors.Bold(targetTimeline))
+	fmt.Printf("   Strategy: %s %s\n", colors.Bold(fuseStrategy), colors.Dim("("+strategySource+")"))
+	if fusePlan {
+		fmt.Printf("   %s\n", colors.Dim("Plan only: nothing will be changed"))
+	}
+	fmt.Println()
 
 	// Perform the fuse
-	return performFuse(ivaldiDir, workDir, sourceTimeline, targetTimeline)
+	if err := performFuse(ivaldiDir, workDir, sourceTimeline, targetTimeline); err != nil {
+		return err
+	}
+	if !fusePlan {
+		autoGC(ivaldiDir)
+	}
+	return nil
 }
 
 func performFuse(ivaldiDir, workDir, sourceTimeline, targetTimeline string) error {
@@ -167,38 +249,52 @@ func performFuse(ivaldiDir, workDir, sourceTimeline, targetTimeline string) erro
 		return fmt.Errorf("failed to read target commit: %w", err)
 	}
 
-	// Check for fast-forward possibility
-	canFastForward := checkFastForward(targetCommit, sourceCommit)
+	// Find common ancestor (base). Unrelated histories have none.
+	baseHash, err := findMergeBase(ivaldiDir, casStore, targetHash, sourceHash)
+	hasBase := err == nil
+	if err != nil && !errors.Is(err, commit.ErrNoMergeBase) {
+		return fmt.Errorf("failed to find merge base: %w", err)
+	}
+	if fusePlan {
+		printPlannedMergeBase(refsManager, baseHash, hasBase)
+	}
 
-	if canFastForward {
-		return handleFastForward(ivaldiDir, refsManager, sourceTimeline, targetTimeline, sourceHash)
+	// Fast-forward is possible if target is an ancestor of source
+	if hasBase && baseHash == targetHash {
+		return handleFastForward(ivaldiDir, refsManager, sourceTimeline, targetTimeline, sourceHash, targetHash)
 	}
 
 	// Need to perform actual merge
-	return handleMerge(ivaldiDir, workDir, casStore, refsManager, sourceTimeline, targetTimeline, sourceCommit, targetCommit, sourceHash, targetHash)
+	return handleMerge(ivaldiDir, workDir, casStore, refsManager, sourceTimeline, targetTimeline, sourceCommit, targetCommit, sourceHash, targetHash, baseHash, hasBase)
 }
 
-func checkFastForward(targetCommit, sourceCommit *commit.CommitObject) bool {
-	// Fast-forward is possible if target is an ancestor of source
-	// For now, simple check: target has no commits after source's parent
-	// A proper implementation would walk the commit graph
-
-	// If target is in source's parent chain, we can fast-forward
-	for _, parent := range sourceCommit.Parents {
-		// Simple check - if target is direct parent
-		// TODO: Walk full parent chain
-		if len(targetCommit.Parents) > 0 && parent == targetCommit.Parents[0] {
-			return true
-		}
+// printPlannedMergeBase prints the merge base for --plan
+func printPlannedMergeBase(refsManager *refs.RefsManager, baseHash cas.Hash, hasBase bool) {
+	if !hasBase {
+		fmt.Printf("Merge base: %s\n\n", colors.Gray("none (unrelated histories, merged against an empty base)"))
+		return
 	}
-
-	return false
+	var hashArray [32]byte
+	copy(hashArray[:], baseHash[:])
+	base := hex.EncodeToString(baseHash[:4])
+	if sealName, err := refsManager.GetSealNameByHash(hashArray); err == nil && sealName != "" {
+		base = fmt.Sprintf("%s (%s)", sealName, base)
+	}
+	fmt.Printf("Merge base: %s\n\n", colors.Cyan(base))
 }
 
-func handleFastForward(ivaldiDir string, refsManager *refs.RefsManager, sourceTimeline, targetTimeline string, sourceHash cas.Hash) error {
+func handleFastForward(ivaldiDir string, refsManager *refs.RefsManager, sourceTimeline, targetTimeline string, sourceHash, targetHash cas.Hash) error {
 	fmt.Println(colors.Green("[OK] Fast-forward merge possible"))
 	fmt.Println()
 
+	if fusePlan {
+		fmt.Printf("Would fast-forward %s to %s (%s)\n",
+			colors.Bold(targetTimeline),
+			colors.Bold(sourceTimeline),
+			colors.Cyan(hex.EncodeToString(sourceHash[:4])))
+		return nil
+	}
+
 	// Ask for confirmation
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("Fast-forward %s to match %s? (y/N)> ", colors.Bold(targetTimeline), colors.Bold(sourceTimeline))
@@ -218,7 +314,7 @@ func handleFastForward(ivaldiDir string, refsManager *refs.RefsManager, sourceTi
 	var hashArray [32]byte
 	copy(hashArray[:], sourceHash[:])
 
-	err = refsManager.UpdateTimeline(targetTimeline, refs.LocalTimeline, hashArray, [32]byte{}, "")
+	err = refsManager.UpdateTimelineIf(targetTimeline, refs.LocalTimeline, targetHash, hashArray, [32]byte{}, "")
 	if err != nil {
 		return fmt.Errorf("failed to update timeline: %w", err)
 	}
@@ -234,46 +330,17 @@ func handleFastForward(ivaldiDir string, refsManager *refs.RefsManager, sourceTi
 
 func handleMerge(ivaldiDir, workDir string, casStore cas.CAS, refsManager *refs.RefsManager,
 	sourceTimeline, targetTimeline string, sourceCommit, targetCommit *commit.CommitObject,
-	sourceHash, targetHash cas.Hash) error {
+	sourceHash, targetHash, baseHash cas.Hash, hasBase bool) error {
 
 	fmt.Println(colors.Yellow("[MERGE] Three-way merge required"))
 	fmt.Println()
 
-	// Get workspace indexes for both commits
-	sourceIndex, err := getCommitWorkspaceIndex(casStore, sourceCommit)
-	if err != nil {
-		return fmt.Errorf("failed to get source workspace: %w", err)
-	}
-
-	targetIndex, err := getCommitWorkspaceIndex(casStore, targetCommit)
-	if err != nil {
-		return fmt.Errorf("failed to get target workspace: %w", err)
-	}
-
-	// Find common ancestor (base)
-	// For now, use target's parent as base (simplified)
-	var baseIndex wsindex.IndexRef
-	if len(targetCommit.Parents) > 0 {
-		baseCommit, err := commit.NewCommitReader(casStore).ReadCommit(targetCommit.Parents[0])
-		if err == nil {
-			baseIndex, _ = getCommitWorkspaceIndex(casStore, baseCommit)
-		}
-	}
-
-	// If no base, use empty workspace
-	if baseIndex.Count == 0 {
-		wsBuilder := wsindex.NewBuilder(casStore)
-		baseIndex, _ = wsBuilder.Build(nil)
-	}
-
 	// Parse merge strategy
 	strategy := diffmerge.StrategyType(fuseStrategy)
 
-	// Perform three-way merge with intelligent strategy
-	merger := diffmerge.NewMerger(casStore)
-	mergeResult, err := merger.MergeWorkspacesWithStrategy(baseIndex, targetIndex, sourceIndex, strategy)
+	targetIndex, mergeResult, err := mergeCommits(casStore, workDir, sourceCommit, targetCommit, baseHash, hasBase, strategy)
 	if err != nil {
-		return fmt.Errorf("failed to merge: %w", err)
+		return err
 	}
 
 	// Check for conflicts
@@ -288,37 +355,67 @@ func handleMerge(ivaldiDir, workDir string, casStore cas.CAS, refsManager *refs.
 		fmt.Printf("%s %d file(s) with conflicts\n", colors.Yellow(">>"), len(mergeResult.Conflicts))
 		fmt.Println()
 
+		if fusePlan {
+			fmt.Println(colors.Gray("The fuse would stop here for the conflicts to be resolved."))
+			return nil
+		}
+
 		// With intelligent conflict resolution, we DON'T write markers to files
 		// Instead, we save the merge state and offer resolution options
-
-		// Save merge state
-		mergeState := &MergeState{
+		err := pauseMerge(ivaldiDir, &MergeState{
 			SourceTimeline: sourceTimeline,
 			TargetTimeline: targetTimeline,
 			SourceHash:     sourceHash,
 			TargetHash:     targetHash,
 			Conflicts:      mergeResult.Conflicts,
+		}, strategy)
+		if err != nil {
+			return err
 		}
 
-		if err := saveMergeState(ivaldiDir, mergeState); err != nil {
-			return fmt.Errorf("failed to save merge state: %w", err)
+		var markerFiles []string
+		if fuseMarkers {
+			currentTimeline, err := refsManager.GetCurrentTimeline()
+			if err != nil {
+				return fmt.Errorf("failed to get current timeline: %w", err)
+			}
+			if currentTimeline != targetTimeline {
+				fmt.Printf("%s Conflict markers are only written when fusing into the current timeline\n\n", colors.Yellow("Warning:"))
+			} else {
+				labels := diffmerge.MarkerLabels{Ours: targetTimeline, Base: "base", Theirs: sourceTimeline}
+				if hasBase {
+					labels.Base = "base " + baseHash.String()[:8]
+				}
+				markerFiles, err = writeConflictMarkers(ivaldiDir, workDir, casStore, mergeResult.Conflicts, labels)
+				if err != nil {
+					return err
+				}
+				if len(markerFiles) > 0 {
+					fmt.Printf("Wrote conflict markers (%s) to:\n", config.ConflictStyle())
+					for _, path := range markerFiles {
+						fmt.Printf("  %s\n", colors.Bold(path))
+					}
+					fmt.Println()
+				}
+			}
 		}
 
-		// Save resolution metadata
-		resStorage := diffmerge.NewResolutionStorage(ivaldiDir)
-		resolution := diffmerge.CreateResolution(sourceTimeline, targetTimeline, sourceHash, targetHash, strategy)
-		if err := resStorage.Save(resolution); err != nil {
-			return fmt.Errorf("failed to save resolution: %w", err)
+		advise(config.AdviceMergeConflict, func() {
+			fmt.Println(colors.Bold("Resolution options:"))
+			fmt.Printf("  %s - Record an edited file as resolved\n", colors.Cyan("ivaldi gather <file>"))
+			fmt.Printf("  %s - Resolve a file with the source version\n", colors.Cyan("ivaldi fuse --resolve --strategy=theirs <file>"))
+			fmt.Printf("  %s - Create the merge seal once all files are resolved\n", colors.Cyan("ivaldi fuse --continue"))
+			fmt.Printf("  %s - Accept all source changes\n", colors.Blue("ivaldi fuse --strategy=theirs "+sourceTimeline))
+			fmt.Printf("  %s - Keep all target changes\n", colors.Green("ivaldi fuse --strategy=ours "+sourceTimeline))
+			fmt.Printf("  %s - Abort merge\n", colors.Red("ivaldi fuse --abort"))
+			fmt.Println()
+		})
+		if len(markerFiles) > 0 {
+			fmt.Println(colors.Yellow("Note: Edit the conflict markers out of the files above, then gather them"))
+		} else {
+			fmt.Println(colors.Yellow("Note: Workspace files are NOT modified - conflicts are resolved separately"))
 		}
 
-		fmt.Println(colors.Bold("Resolution options:"))
-		fmt.Printf("  %s - Use interactive resolver\n", colors.Cyan("ivaldi fuse --continue"))
-		fmt.Printf("  %s - Accept all source changes\n", colors.Blue("ivaldi fuse --strategy=theirs "+sourceTimeline))
-		fmt.Printf("  %s - Keep all target changes\n", colors.Green("ivaldi fuse --strategy=ours "+sourceTimeline))
-		fmt.Printf("  %s - Abort merge\n", colors.Red("ivaldi fuse --abort"))
-		fmt.Println()
-		fmt.Println(colors.Yellow("Note: Workspace files are NOT modified - conflicts are resolved separately"))
-
 		return nil // Don't return error - merge is paused
 	}
 
@@ -327,6 +424,7 @@ func handleMerge(ivaldiDir, workDir string, casStore cas.CAS, refsManager *refs.
 	fmt.Println()
 
 	differ := diffmerge.NewDiffer(casStore)
+	differ.IgnoreModTime = true
 	diff, err := differ.DiffWorkspaces(targetIndex, *mergeResult.MergedIndex)
 	if err != nil {
 		return fmt.Errorf("failed to compute diff: %w", err)
@@ -340,6 +438,16 @@ func handleMerge(ivaldiDir, workDir string, casStore cas.CAS, refsManager *refs.
 
 	fmt.Println()
 
+	if fusePlan {
+		if len(diff.FileChanges) > 0 {
+			fmt.Println(colors.SectionHeader("Diff summary:"))
+			showMergeChangesDetail(diff)
+			fmt.Println()
+		}
+		fmt.Printf("Would create a merge seal on %s\n", colors.Bold(targetTimeline))
+		return nil
+	}
+
 	// Ask for confirmation
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("Apply merge from %s to %s? (y/N)> ", colors.Bold(sourceTimeline), colors.Bold(targetTimeline))
@@ -364,11 +472,120 @@ func handleMerge(ivaldiDir, workDir string, casStore cas.CAS, refsManager *refs.
 		return fmt.Errorf("failed to get author: %w", err)
 	}
 
+	message, err := withTrailers(fmt.Sprintf("Fuse %s into %s", sourceTimeline, targetTimeline), fuseTrailers, fuseSignoff)
+	if err != nil {
+		return err
+	}
+
+	sealName, err := sealMerge(ivaldiDir, casStore, refsManager, targetTimeline, *mergeResult.MergedIndex, targetHash, sourceHash, author, message)
+	if err != nil {
+		return err
+	}
+	if err := applyMergeToWorkspace(casStore, ivaldiDir, workDir, refsManager, targetTimeline, diff); err != nil {
+		return fmt.Errorf("merge seal %s was created but the workspace was not updated: %w", sealName, err)
+	}
+
+	// Clean up resolution storage (merge succeeded)
+	resStorage := diffmerge.NewResolutionStorage(ivaldiDir)
+	if res, _ := resStorage.Load(); res != nil {
+		res.MarkCompleted()
+		resStorage.SaveHistory(res) // Archive for reference
+	}
+	resStorage.Delete()
+
+	fmt.Println()
+	fmt.Printf("%s Changes from %s fused into %s!\n",
+		colors.SuccessText("[OK]"),
+		colors.Bold(sourceTimeline),
+		colors.Bold(targetTimeline))
+	fmt.Printf("  Merge seal: %s\n", colors.Cyan(sealName))
+
+	// Show detailed diff
+	if len(diff.FileChanges) > 0 {
+		fmt.Println()
+		fmt.Println(colors.SectionHeader("Diff summary:"))
+		showMergeChangesDetail(diff)
+	}
+
+	return nil
+}
+
+// mergeCommits merges the files of source into those of target with a
+// strategy. Unrelated histories merge against an empty base. It returns the
+// workspace index of target along with the result.
+func mergeCommits(casStore cas.CAS, workDir string, sourceCommit, targetCommit *commit.CommitObject,
+	baseHash cas.Hash, hasBase bool, strategy diffmerge.StrategyType) (wsindex.IndexRef, *diffmerge.MergeResult, error) {
+
+	// Get workspace indexes for both commits
+	sourceIndex, err := getCommitWorkspaceIndex(casStore, sourceCommit)
+	if err != nil {
+		return wsindex.IndexRef{}, nil, fmt.Errorf("failed to get source workspace: %w", err)
+	}
+
+	targetIndex, err := getCommitWorkspaceIndex(casStore, targetCommit)
+	if err != nil {
+		return wsindex.IndexRef{}, nil, fmt.Errorf("failed to get target workspace: %w", err)
+	}
+
+	var baseIndex wsindex.IndexRef
+	if hasBase {
+		baseCommit, err := commit.NewCommitReader(casStore).ReadCommit(baseHash)
+		if err != nil {
+			return wsindex.IndexRef{}, nil, fmt.Errorf("failed to read merge base: %w", err)
+		}
+		if baseIndex, err = getCommitWorkspaceIndex(casStore, baseCommit); err != nil {
+			return wsindex.IndexRef{}, nil, fmt.Errorf("failed to get base workspace: %w", err)
+		}
+	}
+
+	// If no base, use empty workspace
+	if baseIndex.Count == 0 {
+		wsBuilder := wsindex.NewBuilder(casStore)
+		baseIndex, _ = wsBuilder.Build(nil)
+	}
+
+	// Perform three-way merge with intelligent strategy
+	merger := diffmerge.NewMerger(casStore)
+	merger.BigFileThreshold = config.BigFileThreshold()
+	if merger.Chunking, err = filechunk.LoadProfileRules(workDir); err != nil {
+		return wsindex.IndexRef{}, nil, fmt.Errorf("failed to load chunk profiles: %w", err)
+	}
+	mergeResult, err := merger.MergeWorkspacesWithStrategy(baseIndex, targetIndex, sourceIndex, strategy)
+	if err != nil {
+		return wsindex.IndexRef{}, nil, fmt.Errorf("failed to merge: %w", err)
+	}
+	return targetIndex, mergeResult, nil
+}
+
+// pauseMerge records a merge stopped by conflicts, so that they can be
+// resolved and the merge concluded with 'ivaldi fuse --continue'
+func pauseMerge(ivaldiDir string, state *MergeState, strategy diffmerge.StrategyType) error {
+	if err := saveMergeState(ivaldiDir, state); err != nil {
+		return fmt.Errorf("failed to save merge state: %w", err)
+	}
+
+	// Save resolution metadata
+	resStorage := diffmerge.NewResolutionStorage(ivaldiDir)
+	resolution := diffmerge.CreateResolution(state.SourceTimeline, state.TargetTimeline, state.SourceHash, state.TargetHash, strategy)
+	for _, conflict := range state.Conflicts {
+		resolution.AddConflict(conflict.Path)
+	}
+	if err := resStorage.Save(resolution); err != nil {
+		return fmt.Errorf("failed to save resolution: %w", err)
+	}
+	return nil
+}
+
+// sealMerge creates a merge seal of the merged files with target and source
+// as parents, moves the target timeline to it and returns its name
+func sealMerge(ivaldiDir string, casStore cas.CAS, refsManager *refs.RefsManager, targetTimeline string,
+	merged wsindex.IndexRef, targetHash, sourceHash cas.Hash, author, message string) (string, error) {
+
 	// Get merged files
 	wsLoader := wsindex.NewLoader(casStore)
-	mergedFiles, err := wsLoader.ListAll(*mergeResult.MergedIndex)
+	mergedFiles, err := wsLoader.ListAll(merged)
 	if err != nil {
-		return fmt.Errorf("failed to list merged files: %w", err)
+		return "", fmt.Errorf("failed to list merged files: %w", err)
 	}
 
 	// Initialize MMR
@@ -385,10 +602,10 @@ func handleMerge(ivaldiDir, workDir string, casStore cas.CAS, refsManager *refs.
 		[]cas.Hash{targetHash, sourceHash}, // Both parents
 		author,
 		author,
-		fmt.Sprintf("Fuse %s into %s", sourceTimeline, targetTimeline),
+		message,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create merge commit: %w", err)
+		return "", fmt.Errorf("failed to create merge commit: %w", err)
 	}
 
 	// Get merge commit hash
@@ -397,54 +614,49 @@ func handleMerge(ivaldiDir, workDir string, casStore cas.CAS, refsManager *refs.
 	copy(mergeHashArray[:], mergeHash[:])
 
 	// Update target timeline
-	err = refsManager.UpdateTimeline(targetTimeline, refs.LocalTimeline, mergeHashArray, [32]byte{}, "")
+	err = refsManager.UpdateTimelineIf(targetTimeline, refs.LocalTimeline, targetHash, mergeHashArray, [32]byte{}, "")
 	if err != nil {
-		return fmt.Errorf("failed to update timeline: %w", err)
+		return "", fmt.Errorf("failed to update timeline: %w", err)
 	}
 
 	// Generate seal name
 	sealName := seals.GenerateSealName(mergeHashArray)
-	_ = refsManager.StoreSealName(sealName, mergeHashArray, fmt.Sprintf("Fuse %s into %s", sourceTimeline, targetTimeline))
-
-	// Clean up resolution storage (merge succeeded)
-	resStorage := diffmerge.NewResolutionStorage(ivaldiDir)
-	if res, _ := resStorage.Load(); res != nil {
-		res.MarkCompleted()
-		resStorage.SaveHistory(res) // Archive for reference
-	}
-	resStorage.Delete()
-
-	fmt.Println()
-	fmt.Printf("%s Changes from %s fused into %s!\n",
-		colors.SuccessText("[OK]"),
-		colors.Bold(sourceTimeline),
-		colors.Bold(targetTimeline))
-	fmt.Printf("  Merge seal: %s\n", colors.Cyan(sealName))
+	_ = refsManager.StoreSealName(sealName, mergeHashArray, message)
+	return sealName, nil
+}
 
-	// Show detailed diff
-	if len(diff.FileChanges) > 0 {
-		fmt.Println()
-		fmt.Println(colors.SectionHeader("Diff summary:"))
-		showMergeChangesDetail(diff)
+// applyMergeToWorkspace writes the files a merge changed to the working
+// directory, if the merge was into the current timeline. Other files,
+// including uncommitted changes to them, are left alone.
+func applyMergeToWorkspace(casStore cas.CAS, ivaldiDir, workDir string, refsManager *refs.RefsManager, targetTimeline string, diff *diffmerge.WorkspaceDiff) error {
+	currentTimeline, err := refsManager.GetCurrentTimeline()
+	if err != nil || currentTimeline != targetTimeline || len(diff.FileChanges) == 0 {
+		return nil
 	}
-
-	return nil
+	return workspace.NewMaterializer(casStore, ivaldiDir, workDir).ApplyChangesToWorkspace(diff)
 }
 
+// getCommitWorkspaceIndex builds a workspace index of the files of a commit
 func getCommitWorkspaceIndex(casStore cas.CAS, commitObj *commit.CommitObject) (wsindex.IndexRef, error) {
-	// Read tree and convert to workspace index
-	// This is simplified - in production you'd fully materialize the tree
-	commitReader := commit.NewCommitReader(casStore)
-	tree, err := commitReader.ReadTree(commitObj)
+	fileRefs, err := commit.NewCommitReader(casStore).FileRefs(commitObj)
 	if err != nil {
 		return wsindex.IndexRef{}, err
 	}
 
-	// For now, return empty index
-	// TODO: Properly convert tree to workspace index
-	_ = tree
-	wsBuilder := wsindex.NewBuilder(casStore)
-	return wsBuilder.Build(nil)
+	// Files are compared by content, so the checksum is the node hash as
+	// for merged files
+	files := make([]wsindex.FileMetadata, 0, len(fileRefs))
+	for path, ref := range fileRefs {
+		files = append(files, wsindex.FileMetadata{
+			Path:     path,
+			FileRef:  ref,
+			ModTime:  commitObj.CommitTime,
+			Mode:     0644,
+			Size:     ref.Size,
+			Checksum: ref.Hash,
+		})
+	}
+	return wsindex.NewBuilder(casStore).Build(files)
 }
 
 func showMergeDiffSummary(diff *diffmerge.WorkspaceDiff) {
@@ -558,14 +770,51 @@ func loadMergeState(ivaldiDir string) (*MergeState, error) {
 		TargetTimeline: lines[1],
 	}
 
-	// Parse hashes (simplified - assumes hex encoding)
-	// In production, use proper hash parsing
-	copy(state.SourceHash[:], []byte(lines[2])[:32])
-	copy(state.TargetHash[:], []byte(lines[3])[:32])
+	sourceBytes, err := hex.DecodeString(lines[2])
+	if err != nil || len(sourceBytes) != len(state.SourceHash) {
+		return nil, fmt.Errorf("invalid source hash in merge info file")
+	}
+	targetBytes, err := hex.DecodeString(lines[3])
+	if err != nil || len(targetBytes) != len(state.TargetHash) {
+		return nil, fmt.Errorf("invalid target hash in merge info file")
+	}
+	copy(state.SourceHash[:], sourceBytes)
+	copy(state.TargetHash[:], targetBytes)
 
 	return state, nil
 }
 
+// loadMergeConflicts returns the paths that conflicted in the merge in
+// progress, in the order they were recorded
+func loadMergeConflicts(ivaldiDir string) ([]string, error) {
+	return readMergePaths(filepath.Join(ivaldiDir, "MERGE_CONFLICTS"))
+}
+
+// loadMergeMarkers returns the paths 'fuse --markers' wrote conflict
+// markers to in the merge in progress
+func loadMergeMarkers(ivaldiDir string) ([]string, error) {
+	return readMergePaths(filepath.Join(ivaldiDir, "MERGE_MARKERS"))
+}
+
+// readMergePaths reads a list of paths, one per line, of the merge state
+func readMergePaths(listPath string) ([]string, error) {
+	data, err := os.ReadFile(listPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths, nil
+}
+
 // isMergeInProgress checks if a merge is currently in progress
 func isMergeInProgress(ivaldiDir string) bool {
 	mergeHeadPath := filepath.Join(ivaldiDir, "MERGE_HEAD")
@@ -573,25 +822,164 @@ func isMergeInProgress(ivaldiDir string) bool {
 	return err == nil
 }
 
+// mergeToSeal returns the merge in progress that a seal on timeline
+// concludes, with its recorded resolution, or nil if no merge is in
+// progress. It refuses while conflicts are unresolved or when the merge
+// targets another timeline, as the seal could not record it correctly.
+func mergeToSeal(ivaldiDir, timeline string) (*MergeState, *diffmerge.MergeResolution, error) {
+	if !isMergeInProgress(ivaldiDir) {
+		return nil, nil, nil
+	}
+
+	state, err := loadMergeState(ivaldiDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load merge state: %w", err)
+	}
+	if state.TargetTimeline != timeline {
+		return nil, nil, fmt.Errorf("a fuse of %s into %s is in progress. Switch to %s to conclude it, or run 'ivaldi fuse --abort'",
+			state.SourceTimeline, state.TargetTimeline, state.TargetTimeline)
+	}
+
+	resolution, err := diffmerge.NewResolutionStorage(ivaldiDir).Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load resolution: %w", err)
+	}
+	if resolution != nil && !resolution.IsFullyResolved() {
+		return nil, nil, fmt.Errorf("a fuse of %s into %s still has unresolved conflicts: %s. Gather the resolved files first",
+			state.SourceTimeline, state.TargetTimeline, strings.Join(resolution.GetUnresolvedFiles(), ", "))
+	}
+
+	return state, resolution, nil
+}
+
+// mergeSealFiles returns the index of the target of a merge and the files
+// of the seal that concludes it. The two seals are merged again, which
+// gives the files that merged cleanly; the files in fromWorkspace are taken
+// from workspaceFiles instead, and left out when they are not there.
+func mergeSealFiles(casStore cas.CAS, ivaldiDir, workDir string, state *MergeState, resolution *diffmerge.MergeResolution, fromWorkspace map[string]bool, workspaceFiles []wsindex.FileMetadata) (wsindex.IndexRef, []wsindex.FileMetadata, error) {
+	commitReader := commit.NewCommitReader(casStore)
+	targetCommit, err := commitReader.ReadCommit(state.TargetHash)
+	if err != nil {
+		return wsindex.IndexRef{}, nil, fmt.Errorf("failed to read target commit: %w", err)
+	}
+	sourceCommit, err := commitReader.ReadCommit(state.SourceHash)
+	if err != nil {
+		return wsindex.IndexRef{}, nil, fmt.Errorf("failed to read source commit: %w", err)
+	}
+	baseHash, err := findMergeBase(ivaldiDir, casStore, state.TargetHash, state.SourceHash)
+	hasBase := err == nil
+	if err != nil && !errors.Is(err, commit.ErrNoMergeBase) {
+		return wsindex.IndexRef{}, nil, fmt.Errorf("failed to find merge base: %w", err)
+	}
+	strategy := diffmerge.StrategyAuto
+	if resolution != nil && resolution.Strategy != "" {
+		strategy = resolution.Strategy
+	}
+	targetIndex, mergeResult, err := mergeCommits(casStore, workDir, sourceCommit, targetCommit, baseHash, hasBase, strategy)
+	if err != nil {
+		return wsindex.IndexRef{}, nil, err
+	}
+
+	cleanFiles, err := wsindex.NewLoader(casStore).ListAll(*mergeResult.MergedIndex)
+	if err != nil {
+		return wsindex.IndexRef{}, nil, fmt.Errorf("failed to list merged files: %w", err)
+	}
+
+	var mergedFiles []wsindex.FileMetadata
+	for _, file := range cleanFiles {
+		if !fromWorkspace[file.Path] {
+			mergedFiles = append(mergedFiles, file)
+		}
+	}
+	for _, file := range workspaceFiles {
+		if fromWorkspace[file.Path] {
+			mergedFiles = append(mergedFiles, file)
+		}
+	}
+	return targetIndex, mergedFiles, nil
+}
+
+// applyCleanMerge writes the files that merged cleanly to the working
+// directory; the files in fromWorkspace already hold their resolution
+func applyCleanMerge(casStore cas.CAS, ivaldiDir, workDir string, refsManager *refs.RefsManager, state *MergeState, targetIndex wsindex.IndexRef, mergedFiles []wsindex.FileMetadata, fromWorkspace map[string]bool) error {
+	cleanIndex, err := wsindex.NewBuilder(casStore).Build(mergedFiles)
+	if err != nil {
+		return fmt.Errorf("failed to build merged index: %w", err)
+	}
+	differ := diffmerge.NewDiffer(casStore)
+	differ.IgnoreModTime = true
+	diff, err := differ.DiffWorkspaces(targetIndex, cleanIndex)
+	if err != nil {
+		return fmt.Errorf("failed to compute diff: %w", err)
+	}
+	var cleanChanges diffmerge.WorkspaceDiff
+	for _, change := range diff.FileChanges {
+		if !fromWorkspace[change.Path] {
+			cleanChanges.FileChanges = append(cleanChanges.FileChanges, change)
+		}
+	}
+	return applyMergeToWorkspace(casStore, ivaldiDir, workDir, refsManager, state.TargetTimeline, &cleanChanges)
+}
+
+// finishMerge removes the state of a concluded merge and archives its
+// resolution, if any
+func finishMerge(ivaldiDir string, resolution *diffmerge.MergeResolution) {
+	os.Remove(filepath.Join(ivaldiDir, "MERGE_HEAD"))
+	os.Remove(filepath.Join(ivaldiDir, "MERGE_INFO"))
+	os.Remove(filepath.Join(ivaldiDir, "MERGE_CONFLICTS"))
+	os.Remove(filepath.Join(ivaldiDir, "MERGE_MARKERS"))
+
+	resStorage := diffmerge.NewResolutionStorage(ivaldiDir)
+	if resolution != nil {
+		resolution.MarkCompleted()
+		resStorage.SaveHistory(resolution) // Archive for reference
+	}
+	resStorage.Delete()
+}
+
 // abortMerge aborts the current merge
-func abortMerge(ivaldiDir string) error {
+func abortMerge(ivaldiDir, workDir string) error {
 	if !isMergeInProgress(ivaldiDir) {
 		return fmt.Errorf("no merge in progress")
 	}
 
 	fmt.Println(colors.Yellow("Aborting merge..."))
 
+	// Files given conflict markers go back to the target version
+	markerFiles, err := loadMergeMarkers(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to read conflict marker list: %w", err)
+	}
+	if len(markerFiles) > 0 {
+		state, err := loadMergeState(ivaldiDir)
+		if err != nil {
+			return fmt.Errorf("failed to load merge state: %w", err)
+		}
+		if err := checkoutMergeSide(ivaldiDir, workDir, state.TargetHash, markerFiles); err != nil {
+			return err
+		}
+	}
+
 	// Remove merge state files
 	os.Remove(filepath.Join(ivaldiDir, "MERGE_HEAD"))
 	os.Remove(filepath.Join(ivaldiDir, "MERGE_INFO"))
 	os.Remove(filepath.Join(ivaldiDir, "MERGE_CONFLICTS"))
+	os.Remove(filepath.Join(ivaldiDir, "MERGE_MARKERS"))
 
-	// Remove resolution storage
+	// Archive the decisions recorded so far, then remove resolution storage
 	resStorage := diffmerge.NewResolutionStorage(ivaldiDir)
+	if res, _ := resStorage.Load(); res != nil {
+		res.MarkAborted()
+		resStorage.SaveHistory(res) // Keep for reference
+	}
 	resStorage.Delete()
 
 	fmt.Println(colors.SuccessText("[OK] Merge aborted"))
-	fmt.Println(colors.Dim("Workspace remains clean - no files were modified during merge attempt."))
+	if len(markerFiles) > 0 {
+		fmt.Println(colors.Dim(fmt.Sprintf("Restored %d file(s) written with conflict markers.", len(markerFiles))))
+	} else {
+		fmt.Println(colors.Dim("Workspace remains clean - no files were modified during merge attempt."))
+	}
 
 	return nil
 }
@@ -620,18 +1008,19 @@ func continueMerge(ivaldiDir, workDir string) error {
 		return fmt.Errorf("failed to load resolution: %w", err)
 	}
 
-	// If resolution exists and has conflicts, use interactive resolver
+	// The merge seal is only created once every conflict has a recorded resolution
 	if resolution != nil && !resolution.IsFullyResolved() {
-		fmt.Println(colors.Cyan("Using interactive conflict resolver..."))
+		unresolved := resolution.GetUnresolvedFiles()
+		fmt.Printf("%s %d conflicted file(s) still need a resolution:\n", colors.Yellow(">>"), len(unresolved))
+		for _, path := range unresolved {
+			fmt.Printf("  %s %s\n", colors.Red("CONFLICT:"), colors.Bold(path))
+		}
 		fmt.Println()
-
-		// TODO: Implement interactive resolution using the ConflictResolver
-		// For now, we'll require the user to use a strategy
-		fmt.Println(colors.Yellow("Interactive resolution not yet complete."))
-		fmt.Println("Please rerun fuse with a strategy:")
-		fmt.Printf("  %s - Accept source changes\n", colors.Blue("ivaldi fuse --strategy=theirs "+state.SourceTimeline))
-		fmt.Printf("  %s - Keep target changes\n", colors.Green("ivaldi fuse --strategy=ours "+state.SourceTimeline))
-		return fmt.Errorf("conflicts not resolved - use a strategy")
+		fmt.Println("Record a resolution for each file, then continue:")
+		fmt.Printf("  %s - Record an edited file as resolved\n", colors.Cyan("ivaldi gather <file>"))
+		fmt.Printf("  %s - Accept the source version\n", colors.Blue("ivaldi fuse --resolve --strategy=theirs <file>"))
+		fmt.Printf("  %s - Keep the target version\n", colors.Green("ivaldi fuse --resolve --strategy=ours <file>"))
+		return fmt.Errorf("%d conflict(s) not resolved", len(unresolved))
 	}
 
 	// Create merge commit
@@ -654,47 +1043,45 @@ func continueMerge(ivaldiDir, workDir string) error {
 		return fmt.Errorf("failed to get author: %w", err)
 	}
 
-	// Get staged files (or all files if none staged)
+	stageLock, err := lockStage(ivaldiDir)
+	if err != nil {
+		return err
+	}
+	defer stageLock.Release()
+
+	// Conflicted files and any other gathered files are taken from the
+	// working directory
 	stageFile := filepath.Join(ivaldiDir, "stage", "files")
-	var stagedFiles []string
+	fromWorkspace := make(map[string]bool)
 	if data, err := os.ReadFile(stageFile); err == nil {
 		for _, line := range strings.Split(string(data), "\n") {
-			line = strings.TrimSpace(line)
-			if line != "" {
-				stagedFiles = append(stagedFiles, line)
+			if line = strings.TrimSpace(line); line != "" {
+				fromWorkspace[line] = true
 			}
 		}
 	}
-
-	if len(stagedFiles) == 0 {
-		return fmt.Errorf("no files staged. Stage resolved files with 'ivaldi gather <file>...'")
+	conflictPaths, err := loadMergeConflicts(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to read conflict list: %w", err)
+	}
+	for _, path := range conflictPaths {
+		fromWorkspace[path] = true
 	}
 
-	// Scan workspace for staged files
 	materializer := workspace.NewMaterializer(casStore, ivaldiDir, workDir)
-
 	wsIndex, err := materializer.ScanWorkspace()
 	if err != nil {
 		return fmt.Errorf("failed to scan workspace: %w", err)
 	}
-
-	wsLoader := wsindex.NewLoader(casStore)
-	allFiles, err := wsLoader.ListAll(wsIndex)
+	allFiles, err := wsindex.NewLoader(casStore).ListAll(wsIndex)
 	if err != nil {
 		return fmt.Errorf("failed to list files: %w", err)
 	}
 
-	// Filter to staged files
-	var mergedFiles []wsindex.FileMetadata
-	stagedMap := make(map[string]bool)
-	for _, f := range stagedFiles {
-		stagedMap[f] = true
-	}
-
-	for _, file := range allFiles {
-		if stagedMap[file.Path] {
-			mergedFiles = append(mergedFiles, file)
-		}
+	// Everything else comes from merging the two seals again
+	targetIndex, mergedFiles, err := mergeSealFiles(casStore, ivaldiDir, workDir, state, resolution, fromWorkspace, allFiles)
+	if err != nil {
+		return err
 	}
 
 	// Initialize MMR
@@ -704,6 +1091,11 @@ func continueMerge(ivaldiDir, workDir string) error {
 	}
 	defer mmr.Close()
 
+	message, err := withTrailers(fmt.Sprintf("Fuse %s into %s", state.SourceTimeline, state.TargetTimeline), fuseTrailers, fuseSignoff)
+	if err != nil {
+		return err
+	}
+
 	// Create merge commit
 	commitBuilder := commit.NewCommitBuilder(casStore, mmr.MMR)
 	mergeCommit, err := commitBuilder.CreateCommit(
@@ -711,7 +1103,7 @@ func continueMerge(ivaldiDir, workDir string) error {
 		[]cas.Hash{state.TargetHash, state.SourceHash},
 		author,
 		author,
-		fmt.Sprintf("Fuse %s into %s", state.SourceTimeline, state.TargetTimeline),
+		message,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create merge commit: %w", err)
@@ -723,28 +1115,22 @@ func continueMerge(ivaldiDir, workDir string) error {
 	copy(mergeHashArray[:], mergeHash[:])
 
 	// Update target timeline
-	err = refsManager.UpdateTimeline(state.TargetTimeline, refs.LocalTimeline, mergeHashArray, [32]byte{}, "")
+	err = refsManager.UpdateTimelineIf(state.TargetTimeline, refs.LocalTimeline, state.TargetHash, mergeHashArray, [32]byte{}, "")
 	if err != nil {
 		return fmt.Errorf("failed to update timeline: %w", err)
 	}
 
 	// Generate seal name
 	sealName := seals.GenerateSealName(mergeHashArray)
-	_ = refsManager.StoreSealName(sealName, mergeHashArray, fmt.Sprintf("Fuse %s into %s", state.SourceTimeline, state.TargetTimeline))
-
-	// Clean up merge state
-	os.Remove(filepath.Join(ivaldiDir, "MERGE_HEAD"))
-	os.Remove(filepath.Join(ivaldiDir, "MERGE_INFO"))
-	os.Remove(filepath.Join(ivaldiDir, "MERGE_CONFLICTS"))
-	os.Remove(stageFile)
+	_ = refsManager.StoreSealName(sealName, mergeHashArray, message)
 
-	// Clean up and archive resolution
-	resStorage = diffmerge.NewResolutionStorage(ivaldiDir)
-	if resolution != nil {
-		resolution.MarkCompleted()
-		resStorage.SaveHistory(resolution) // Archive for reference
+	if err := applyCleanMerge(casStore, ivaldiDir, workDir, refsManager, state, targetIndex, mergedFiles, fromWorkspace); err != nil {
+		return fmt.Errorf("merge seal %s was created but the workspace was not updated: %w", sealName, err)
 	}
-	resStorage.Delete()
+
+	// Clean up merge state and archive resolution
+	os.Remove(stageFile)
+	finishMerge(ivaldiDir, resolution)
 
 	fmt.Println()
 	fmt.Printf("%s Merge completed successfully!\n", colors.SuccessText("[OK]"))
@@ -0,0 +1,443 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/lockfile"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/shelf"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gcAggressive   bool
+	gcDryRun       bool
+	gcAuto         bool
+	gcPruneShelves bool
+)
+
+// autoGCBudget bounds how long a synchronous auto gc may hold up the
+// command that triggered it
+const autoGCBudget = 2 * time.Second
+
+// gcPruneGrace is how old an unreachable object must be before gc prunes
+// it. Commands store objects before a reference points at them, so a
+// command running alongside gc must not lose what it just wrote.
+const gcPruneGrace = time.Hour
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Pack objects to reduce repository size",
+	Long: `Move loose objects into a pack, combining earlier packs into one.
+
+With --aggressive, objects are also split into content-defined segments
+inside the pack, so that runs of bytes shared between files or between
+versions of a file are stored once. Only the on-disk layout changes: every
+seal, tree and file keeps its hash.
+
+Before packing, reflog entries are expired as by 'ivaldi reflog expire
+--all'. Objects that nothing references any more are then pruned: seals,
+trees, workspace indexes and file chunks alike. An object is kept while it
+is reachable from a timeline, tag, stash, reflog entry, shelf, staged
+snapshot or the stat cache, so a seal stays recoverable through a reflog
+entry until that entry expires. Objects written in the last hour are kept
+too, and nothing is pruned while a fuse is in progress.
+
+With --prune-shelves, shelves of timelines that no longer exist, and
+auto-shelves replaced by a newer one for the same timeline, are removed
+first so that the objects only they reference can be pruned.
+
+With --auto, only loose objects are packed, into a pack of their own, and
+only once there are at least gc.autoThreshold of them. Seal, fuse and travel
+overwrite run this automatically when gc.auto is "true" or "background".
+
+Examples:
+  ivaldi gc                       # Pack loose objects
+  ivaldi gc --aggressive          # Also deduplicate shared content
+  ivaldi gc --aggressive --dry-run  # Report the savings without writing
+  ivaldi gc --prune-shelves       # Also drop shelves that cannot be restored
+  ivaldi gc --auto                # Pack loose objects if there are many`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+}
+
+func init() {
+	gcCmd.Flags().BoolVar(&gcAggressive, "aggressive", false, "Re-chunk objects with content-defined chunking to deduplicate shared content")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "Show what would be packed without changing anything")
+	gcCmd.Flags().BoolVar(&gcAuto, "auto", false, "Pack loose objects only if gc.autoThreshold of them have accumulated")
+	gcCmd.Flags().BoolVar(&gcPruneShelves, "prune-shelves", false, "Remove shelves of deleted timelines and superseded auto-shelves before pruning")
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	if gcAuto {
+		if gcAggressive || gcDryRun || gcPruneShelves {
+			return fmt.Errorf("--auto cannot be combined with --aggressive, --dry-run or --prune-shelves")
+		}
+		return runAutoGC(ivaldiDir)
+	}
+
+	lock, err := lockfile.Acquire(filepath.Join(ivaldiDir, "gc.lock"), lockfile.DefaultTimeout)
+	if errors.Is(err, lockfile.ErrLocked) {
+		return fmt.Errorf("another gc is running")
+	}
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	expired, err := expireAllReflogs(ivaldiDir, casStore, gcDryRun)
+	if err != nil {
+		return err
+	}
+	if expired > 0 && gcDryRun {
+		fmt.Printf("Would expire %d reflog entries\n", expired)
+	} else if expired > 0 {
+		fmt.Printf("Expired %d reflog entries\n", expired)
+	}
+
+	if gcPruneShelves {
+		removed, err := pruneShelves(ivaldiDir, casStore, gcDryRun)
+		if err != nil {
+			return err
+		}
+		if removed > 0 && gcDryRun {
+			fmt.Printf("Would remove %d shelf(s)\n", removed)
+		} else if removed > 0 {
+			fmt.Printf("Removed %d shelf(s)\n", removed)
+		}
+	}
+
+	var prune *objectPruner
+	if isMergeInProgress(ivaldiDir) {
+		fmt.Println("Fuse in progress; not pruning unreachable objects.")
+	} else {
+		reachable, err := reachableObjects(ivaldiDir, casStore)
+		if err != nil {
+			return fmt.Errorf("failed to find reachable objects: %w", err)
+		}
+		prune = newObjectPruner(reachable, time.Now().Add(-gcPruneGrace))
+	}
+
+	if gcAggressive {
+		fmt.Println("Packing objects with content-defined chunking...")
+	} else {
+		fmt.Println("Packing objects...")
+	}
+
+	opts := cas.RepackOptions{ContentDefined: gcAggressive, DryRun: gcDryRun}
+	if prune != nil {
+		opts.Keep = prune.keep
+	}
+	stats, err := casStore.Repack(opts)
+	if err != nil {
+		return fmt.Errorf("failed to pack objects: %w", err)
+	}
+
+	if stats.Objects == 0 && stats.Pruned == 0 {
+		fmt.Println("Nothing to pack.")
+		return nil
+	}
+
+	fmt.Printf("  Objects:  %d (%d loose, %d already packed)\n", stats.Objects, stats.LooseObjects, stats.PackedObjects)
+	if stats.Pruned > 0 {
+		verb := "Pruned:  "
+		if gcDryRun {
+			verb = "Prunable:"
+		}
+		fmt.Printf("  %s %d unreachable (%s)\n", verb, stats.Pruned, prune.summary())
+	}
+	if gcAggressive {
+		fmt.Printf("  Segments: %d\n", stats.Segments)
+	}
+	if stats.Skipped > 0 {
+		fmt.Printf("  Skipped:  %d loose file(s) not stored by content hash\n", stats.Skipped)
+	}
+
+	fmt.Printf("  Size:     %s -> %s", formatByteSize(stats.SizeBefore), formatByteSize(stats.SizeAfter))
+	if stats.SizeBefore > 0 && stats.SizeAfter != stats.SizeBefore {
+		change := float64(stats.SizeAfter-stats.SizeBefore) * 100 / float64(stats.SizeBefore)
+		if change <= 0 {
+			fmt.Printf(" (%.1f%% smaller)", -change)
+		} else {
+			fmt.Printf(" (%.1f%% larger)", change)
+		}
+	}
+	fmt.Println()
+
+	if gcDryRun {
+		fmt.Println(colors.Dim("Dry run: nothing was changed"))
+		return nil
+	}
+	fmt.Printf("%s Objects packed\n", colors.SuccessText("[OK]"))
+	return nil
+}
+
+// objectPruner decides which objects gc keeps, and counts the pruned ones
+// by type
+type objectPruner struct {
+	reachable map[cas.Hash]bool
+	cutoff    time.Time
+	pruned    map[string]int
+}
+
+func newObjectPruner(reachable map[cas.Hash]bool, cutoff time.Time) *objectPruner {
+	return &objectPruner{reachable: reachable, cutoff: cutoff, pruned: make(map[string]int)}
+}
+
+// keep implements cas.RepackOptions.Keep: reachable objects and objects
+// written after the cutoff are kept
+func (p *objectPruner) keep(hash cas.Hash, content []byte, written time.Time) bool {
+	if p.reachable[hash] || written.After(p.cutoff) {
+		return true
+	}
+	p.pruned[objectKind(content)]++
+	return false
+}
+
+// summary lists the pruned objects per type, in the order of rawDecoders
+func (p *objectPruner) summary() string {
+	var parts []string
+	for _, decoder := range rawDecoders {
+		if n := p.pruned[decoder.name]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, decoder.name))
+		}
+	}
+	if n := p.pruned["other"]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d other", n))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// objectKind names the kind of a stored object as the first of rawDecoders
+// that decodes it, or "other"
+func objectKind(data []byte) string {
+	for _, decoder := range rawDecoders {
+		if _, err := decoder.print(data); err == nil {
+			return decoder.name
+		}
+	}
+	return "other"
+}
+
+// pruneShelves removes the shelves that can no longer be restored: those of
+// timelines that no longer exist, and auto-shelves replaced by a newer one
+// for the same timeline. It returns how many were (or would be) removed.
+func pruneShelves(ivaldiDir string, casStore cas.CAS, dryRun bool) (int, error) {
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	shelfManager := shelf.NewShelfManager(casStore, ivaldiDir)
+	shelves, err := shelfManager.ListShelves()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	latest := make(map[string]bool)
+	for _, s := range shelves { // Newest first
+		_, err := refsManager.GetTimeline(s.TimelineName, refs.LocalTimeline)
+		superseded := s.AutoCreated && latest[s.TimelineName]
+		if s.AutoCreated {
+			latest[s.TimelineName] = true
+		}
+		if err == nil && !superseded {
+			continue
+		}
+		if !dryRun {
+			if err := shelfManager.RemoveShelf(s.ID); err != nil {
+				return removed, err
+			}
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// expireAllReflogs applies reflog.expire and reflog.expireUnreachable to
+// every reflog, as 'ivaldi reflog expire --all' does
+func expireAllReflogs(ivaldiDir string, casStore cas.CAS, dryRun bool) (int, error) {
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	targets, err := refsManager.ListReflogs()
+	if err != nil {
+		return 0, err
+	}
+	expire, expireUnreachable := config.ReflogExpiry()
+	return expireReflogs(refsManager, casStore, targets, expire, expireUnreachable, dryRun)
+}
+
+// runAutoGC implements 'gc --auto': it packs the loose objects into a new
+// pack once gc.autoThreshold of them have accumulated, leaving existing
+// packs alone
+func runAutoGC(ivaldiDir string) error {
+	if isMergeInProgress(ivaldiDir) {
+		fmt.Println("Fuse in progress; not packing objects.")
+		return nil
+	}
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	_, threshold := config.GCAuto()
+	count, err := casStore.CountLooseObjects()
+	if err != nil {
+		return err
+	}
+	if count < threshold {
+		fmt.Printf("%d loose objects, below gc.autoThreshold (%d); nothing to do.\n", count, threshold)
+		return nil
+	}
+
+	fmt.Printf("Packing %d loose objects...\n", count)
+	stats, err := packLooseObjects(ivaldiDir, casStore, time.Time{})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s Packed %d objects (%s -> %s)\n", colors.SuccessText("[OK]"),
+		stats.LooseObjects, formatByteSize(stats.SizeBefore), formatByteSize(stats.SizeAfter))
+	return nil
+}
+
+// packLooseObjects moves the loose objects into a pack of their own under
+// the gc lock, giving up unchanged if deadline (when set) passes first. It
+// fails with lockfile.ErrLocked right away if another gc is running.
+func packLooseObjects(ivaldiDir string, casStore *cas.FileCAS, deadline time.Time) (*cas.RepackStats, error) {
+	lock, err := lockfile.Acquire(filepath.Join(ivaldiDir, "gc.lock"), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	stats, err := casStore.Repack(cas.RepackOptions{LooseOnly: true, Deadline: deadline})
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack objects: %w", err)
+	}
+	return stats, nil
+}
+
+// gcLogPath is where auto gc records each run and where a background gc
+// writes its output
+func gcLogPath(ivaldiDir string) string {
+	return filepath.Join(ivaldiDir, "gc.log")
+}
+
+// logAutoGC appends a line to the auto gc log
+func logAutoGC(ivaldiDir, format string, args ...interface{}) {
+	f, err := os.OpenFile(gcLogPath(ivaldiDir), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+// autoGC packs loose objects after a command that wrote new ones, as
+// configured by gc.auto and gc.autoThreshold. It never runs while a fuse is
+// in progress, and problems are only reported: the command that triggered it
+// has already succeeded.
+func autoGC(ivaldiDir string) {
+	mode, threshold := config.GCAuto()
+	if mode == config.GCAutoOff || isMergeInProgress(ivaldiDir) {
+		return
+	}
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return
+	}
+	count, err := casStore.CountLooseObjects()
+	if err != nil || count < threshold {
+		return
+	}
+
+	if mode == config.GCAutoBackground {
+		if err := startBackgroundGC(ivaldiDir); err != nil {
+			log.Printf("Warning: Failed to start auto gc: %v", err)
+			return
+		}
+		logAutoGC(ivaldiDir, "auto gc: %d loose objects, packing in the background", count)
+		fmt.Println(colors.Dim(fmt.Sprintf("Auto gc: packing %d loose objects in the background (see %s)", count, gcLogPath(ivaldiDir))))
+		return
+	}
+
+	fmt.Println(colors.Dim(fmt.Sprintf("Auto gc: packing %d loose objects...", count)))
+	stats, err := packLooseObjects(ivaldiDir, casStore, time.Now().Add(autoGCBudget))
+	switch {
+	case errors.Is(err, lockfile.ErrLocked):
+		logAutoGC(ivaldiDir, "auto gc: %d loose objects, skipped because another gc is running", count)
+	case errors.Is(err, cas.ErrRepackDeadline):
+		logAutoGC(ivaldiDir, "auto gc: %d loose objects, gave up after %s", count, autoGCBudget)
+		fmt.Println(colors.Dim(fmt.Sprintf("Auto gc: not finished within %s; run 'ivaldi gc' or set gc.auto to background", autoGCBudget)))
+	case err != nil:
+		logAutoGC(ivaldiDir, "auto gc: %d loose objects, failed: %v", count, err)
+		log.Printf("Warning: Auto gc failed: %v", err)
+	default:
+		logAutoGC(ivaldiDir, "auto gc: packed %d loose objects (%s -> %s)", stats.LooseObjects,
+			formatByteSize(stats.SizeBefore), formatByteSize(stats.SizeAfter))
+	}
+}
+
+// startBackgroundGC starts 'ivaldi gc --auto' without waiting for it. Its
+// output goes to the gc log.
+func startBackgroundGC(ivaldiDir string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(gcLogPath(ivaldiDir), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	gcProcess := exec.Command(executable, "gc", "--auto")
+	gcProcess.Stdout = logFile
+	gcProcess.Stderr = logFile
+	gcProcess.Env = append(os.Environ(), "NO_COLOR=1")
+	if err := gcProcess.Start(); err != nil {
+		return err
+	}
+	return gcProcess.Process.Release()
+}
+
+// formatByteSize renders a byte count with a binary unit
+func formatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
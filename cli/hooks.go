@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// runHook executes the repository hook .ivaldi/hooks/<name> if it exists.
+// A non-zero exit status from the hook aborts the calling operation.
+func runHook(ivaldiDir, name string) error {
+	hookPath := filepath.Join(ivaldiDir, "hooks", name)
+
+	info, err := os.Stat(hookPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s hook: %w", name, err)
+	}
+
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		log.Printf("Warning: %s hook is not executable, skipping", name)
+		return nil
+	}
+
+	absHookPath, err := filepath.Abs(hookPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s hook: %w", name, err)
+	}
+
+	hookCmd := exec.Command(absHookPath)
+	hookCmd.Stdin = os.Stdin
+	hookCmd.Stdout = os.Stdout
+	hookCmd.Stderr = os.Stderr
+	hookCmd.Env = append(os.Environ(), "IVALDI_DIR="+ivaldiDir)
+
+	if err := hookCmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+
+	return nil
+}
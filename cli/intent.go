@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// intentToAddFile lists paths recorded with 'gather --intent-to-add'. Such
+// files are tracked with empty content: diff shows their whole content as
+// added, but seal leaves them out until they are gathered for real.
+func intentToAddFile(ivaldiDir string) string {
+	return filepath.Join(ivaldiDir, "stage", "intent")
+}
+
+// getIntentToAddFiles returns the paths marked as intent-to-add
+func getIntentToAddFiles(ivaldiDir string) ([]string, error) {
+	data, err := os.ReadFile(intentToAddFile(ivaldiDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read intent-to-add list: %w", err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// writeIntentToAddFiles replaces the intent-to-add list. An empty list
+// removes the file. Callers must hold the stage lock.
+func writeIntentToAddFiles(ivaldiDir string, files []string) error {
+	path := intentToAddFile(ivaldiDir)
+	if len(files) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove intent-to-add list: %w", err)
+		}
+		return nil
+	}
+
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+	content := strings.Join(sorted, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write intent-to-add list: %w", err)
+	}
+	return nil
+}
+
+// dropIntentToAdd removes paths from the intent-to-add list, typically
+// because they were gathered or reset
+func dropIntentToAdd(ivaldiDir string, paths []string) error {
+	intent, err := getIntentToAddFiles(ivaldiDir)
+	if err != nil || len(intent) == 0 {
+		return err
+	}
+
+	drop := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		drop[path] = true
+	}
+
+	var remaining []string
+	for _, path := range intent {
+		if !drop[path] {
+			remaining = append(remaining, path)
+		}
+	}
+	if len(remaining) == len(intent) {
+		return nil
+	}
+	return writeIntentToAddFiles(ivaldiDir, remaining)
+}
+
+// withIntentToAdd returns index extended with an empty entry for every
+// intent-to-add path it does not already contain, so that diffing it
+// against the working directory shows those files' content as additions
+func withIntentToAdd(casStore cas.CAS, index wsindex.IndexRef, intent []string) (wsindex.IndexRef, error) {
+	if len(intent) == 0 {
+		return index, nil
+	}
+
+	files, err := wsindex.NewLoader(casStore).ListAll(index)
+	if err != nil {
+		return wsindex.IndexRef{}, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file.Path] = true
+	}
+
+	emptyRef, err := filechunk.NewBuilder(casStore, filechunk.DefaultParams()).Build(nil)
+	if err != nil {
+		return wsindex.IndexRef{}, fmt.Errorf("failed to store empty file: %w", err)
+	}
+
+	for _, path := range intent {
+		if present[path] {
+			continue
+		}
+		files = append(files, wsindex.FileMetadata{
+			Path:     path,
+			FileRef:  emptyRef,
+			Mode:     0644,
+			Checksum: cas.SumB3(nil),
+		})
+	}
+
+	return wsindex.NewBuilder(casStore).Build(files)
+}
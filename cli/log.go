@@ -2,28 +2,50 @@ package cli
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/colors"
 	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/pathspec"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/spf13/cobra"
 )
 
 var logCmd = &cobra.Command{
-	Use:   "log [options]",
+	Use:   "log [options] [paths...]",
 	Short: "Show commit history",
 	Long: `Display the commit history for the current timeline.
 
+With paths, only seals that changed a file at or below one of the paths are
+shown. With --patch, each seal is followed by its diff against its first
+parent; the first seal shows every file as added.
+
 Examples:
   ivaldi log                  # Show all commits
   ivaldi log --oneline        # Show concise one-line format
   ivaldi log --limit 10       # Show only last 10 commits
-  ivaldi log --all            # Show commits from all timelines`,
+  ivaldi log --all            # Show commits from all timelines
+  ivaldi log --since "2 weeks ago" --author alice
+  ivaldi log --since 2024-01-01 --until 2024-02-01
+  ivaldi log --merges         # Show only merge seals
+  ivaldi log --no-merges      # Leave merge seals out
+  ivaldi log -p               # Show the patch of each seal
+  ivaldi log -p src/main.go   # Show only the changes to one file
+  ivaldi log -p -w            # Show patches without whitespace-only changes
+  ivaldi log -p -U1           # Show patches with one line of context
+  ivaldi log --show-signature # Verify the signature of each seal
+
+Signatures are only checked with --show-signature, as verifying runs gpg or
+ssh-keygen for every signed seal.`,
 	RunE: runLog,
 }
 
@@ -31,12 +53,33 @@ var (
 	logOneline bool
 	logLimit   int
 	logAll     bool
+	logSince   string
+	logUntil   string
+	logAuthor  string
+	logPatch   bool
+
+	logMerges   bool
+	logNoMerges bool
+
+	logShowSignature bool
+
+	logWhitespace diffmerge.WhitespaceOptions
+	logHunks      diffmerge.HunkOptions
 )
 
 func init() {
 	logCmd.Flags().BoolVar(&logOneline, "oneline", false, "Show one line per commit")
 	logCmd.Flags().IntVar(&logLimit, "limit", 0, "Limit number of commits to show")
 	logCmd.Flags().BoolVar(&logAll, "all", false, "Show commits from all timelines")
+	logCmd.Flags().StringVar(&logSince, "since", "", "Show commits more recent than a date (RFC3339, YYYY-MM-DD, or e.g. \"2 weeks ago\")")
+	logCmd.Flags().StringVar(&logUntil, "until", "", "Show commits older than a date (RFC3339, YYYY-MM-DD, or e.g. \"yesterday\")")
+	logCmd.Flags().StringVar(&logAuthor, "author", "", "Show commits whose author contains the given text")
+	logCmd.Flags().BoolVar(&logMerges, "merges", false, "Show only merge commits, which have two or more parents")
+	logCmd.Flags().BoolVar(&logNoMerges, "no-merges", false, "Show no merge commits")
+	logCmd.Flags().BoolVarP(&logPatch, "patch", "p", false, "Show the diff of each commit against its first parent")
+	logCmd.Flags().BoolVar(&logShowSignature, "show-signature", false, "Verify and show the signature of each signed seal")
+	addWhitespaceFlags(logCmd, &logWhitespace)
+	addContextFlags(logCmd, &logHunks)
 }
 
 type commitInfo struct {
@@ -44,15 +87,22 @@ type commitInfo struct {
 	Commit   *commit.CommitObject
 	SealName string
 	Timeline string
+	// ShowSignature verifies and reports the signature of the seal
+	ShowSignature bool
 }
 
 func runLog(cmd *cobra.Command, args []string) error {
 	// Check if we're in an Ivaldi repository
-	ivaldiDir := ".ivaldi"
+	ivaldiDir := config.IvaldiDir()
 	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 	}
 
+	filter, err := buildLogFilter()
+	if err != nil {
+		return err
+	}
+
 	// Initialize refs manager
 	refsManager, err := refs.NewRefsManager(ivaldiDir)
 	if err != nil {
@@ -67,17 +117,24 @@ func runLog(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
-	var commits []commitInfo
+	// Paths are given from wherever log was started
+	printer := &logPrinter{
+		casStore: casStore,
+		reader:   commit.NewCommitReader(casStore),
+		paths:    pathspec.Within(workPrefix, args),
+	}
 
 	if logAll {
-		// Get commits from all timelines
+		// Commits of all timelines are interleaved by time, so they are read
+		// before any is printed
 		timelines, err := refsManager.ListTimelines(refs.LocalTimeline)
 		if err != nil {
 			return fmt.Errorf("failed to list timelines: %w", err)
 		}
 
+		var commits []commitInfo
 		for _, timeline := range timelines {
-			timelineCommits, err := getTimelineCommits(casStore, refsManager, timeline.Name, timeline.Blake3Hash)
+			timelineCommits, err := getTimelineCommits(casStore, refsManager, timeline.Name, timeline.Blake3Hash, filter)
 			if err != nil {
 				continue // Skip timelines with errors
 			}
@@ -86,6 +143,16 @@ func runLog(cmd *cobra.Command, args []string) error {
 
 		// Sort commits by time (newest first)
 		sortCommitsByTime(commits)
+
+		for _, info := range commits {
+			more, err := printer.show(info)
+			if err != nil {
+				return err
+			}
+			if !more {
+				break
+			}
+		}
 	} else {
 		// Get current timeline
 		currentTimeline, err := refsManager.GetCurrentTimeline()
@@ -99,83 +166,131 @@ func runLog(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to get timeline info: %w", err)
 		}
 
-		// Get commits for current timeline
-		commits, err = getTimelineCommits(casStore, refsManager, currentTimeline, timeline.Blake3Hash)
-		if err != nil {
+		// Print commits as they are read rather than after the whole walk
+		if err := streamTimelineCommits(refsManager, currentTimeline, timeline.Blake3Hash, filter, printer); err != nil {
 			return fmt.Errorf("failed to get commits: %w", err)
 		}
 	}
 
-	if len(commits) == 0 {
-		fmt.Println("No commits yet.")
-		return nil
+	if printer.shown == 0 {
+		if filter != (commit.Filter{}) || len(args) > 0 {
+			fmt.Println("No commits match the given filters.")
+		} else {
+			fmt.Println("No commits yet.")
+		}
 	}
 
-	// Apply limit if specified
-	if logLimit > 0 && len(commits) > logLimit {
-		commits = commits[:logLimit]
+	return nil
+}
+
+// buildLogFilter builds the commit filter from the --since, --until, --author,
+// --merges and --no-merges flags
+func buildLogFilter() (commit.Filter, error) {
+	filter := commit.Filter{Author: logAuthor, Merges: logMerges, NoMerges: logNoMerges}
+	now := time.Now()
+
+	if logMerges && logNoMerges {
+		return filter, fmt.Errorf("--merges and --no-merges cannot be used together")
 	}
 
-	// Display commits
-	if logOneline {
-		displayCommitsOneline(commits)
-	} else {
-		displayCommitsFull(commits)
+	if logSince != "" {
+		since, err := commit.ParseDate(logSince, now)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --since: %w", err)
+		}
+		filter.Since = since
 	}
 
-	return nil
+	if logUntil != "" {
+		until, err := commit.ParseDate(logUntil, now)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --until: %w", err)
+		}
+		filter.Until = until
+	}
+
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Until.Before(filter.Since) {
+		return filter, fmt.Errorf("--until is earlier than --since")
+	}
+
+	return filter, nil
 }
 
-// getTimelineCommits retrieves all commits for a timeline starting from HEAD
-func getTimelineCommits(casStore cas.CAS, refsManager *refs.RefsManager, timelineName string, headHash [32]byte) ([]commitInfo, error) {
+// getTimelineCommits retrieves the commits of a timeline matching filter, starting from HEAD
+func getTimelineCommits(casStore cas.CAS, refsManager *refs.RefsManager, timelineName string, headHash [32]byte, filter commit.Filter) ([]commitInfo, error) {
 	if headHash == [32]byte{} {
 		return nil, nil // No commits yet
 	}
 
 	var commits []commitInfo
-	visited := make(map[cas.Hash]bool)
 
 	commitReader := commit.NewCommitReader(casStore)
 
-	// Start from HEAD and walk back through parents
-	var currentHash cas.Hash
-	copy(currentHash[:], headHash[:])
-
-	for {
-		// Avoid cycles
-		if visited[currentHash] {
-			break
-		}
-		visited[currentHash] = true
-
-		// Read commit
-		commitObj, err := commitReader.ReadCommit(currentHash)
-		if err != nil {
-			break // Stop on error
-		}
+	// Start from HEAD and walk back through first parents (linear history)
+	var head cas.Hash
+	copy(head[:], headHash[:])
 
+	err := commitReader.WalkFirstParent(head, filter, func(hash cas.Hash, commitObj *commit.CommitObject) bool {
 		// Get seal name if available
 		var hashArray [32]byte
-		copy(hashArray[:], currentHash[:])
+		copy(hashArray[:], hash[:])
 		sealName, _ := refsManager.GetSealNameByHash(hashArray)
 
 		commits = append(commits, commitInfo{
-			Hash:     currentHash,
+			Hash:     hash,
 			Commit:   commitObj,
 			SealName: sealName,
 			Timeline: timelineName,
 		})
+		return true
+	})
+	if err != nil && (len(commits) == 0 || errors.Is(err, commit.ErrWalkLimit)) {
+		return nil, err
+	}
+	// Otherwise show what was read before the broken link
 
-		// Move to parent
-		if len(commitObj.Parents) == 0 {
-			break // No more parents
-		}
+	return commits, nil
+}
 
-		// Follow first parent (for linear history)
-		currentHash = commitObj.Parents[0]
+// streamTimelineCommits walks the commits of a timeline matching filter
+// from HEAD, handing each to printer as soon as it is read
+func streamTimelineCommits(refsManager *refs.RefsManager, timelineName string, headHash [32]byte, filter commit.Filter, printer *logPrinter) error {
+	if headHash == [32]byte{} {
+		return nil // No commits yet
 	}
 
-	return commits, nil
+	var head cas.Hash
+	copy(head[:], headHash[:])
+
+	read := 0
+	var showErr error
+	err := printer.reader.WalkFirstParent(head, filter, func(hash cas.Hash, commitObj *commit.CommitObject) bool {
+		read++
+		var hashArray [32]byte
+		copy(hashArray[:], hash[:])
+		sealName, _ := refsManager.GetSealNameByHash(hashArray)
+
+		more, err := printer.show(commitInfo{
+			Hash:     hash,
+			Commit:   commitObj,
+			SealName: sealName,
+			Timeline: timelineName,
+		})
+		if err != nil {
+			showErr = err
+			return false
+		}
+		return more
+	})
+	if showErr != nil {
+		return showErr
+	}
+	if err != nil && (read == 0 || errors.Is(err, commit.ErrWalkLimit)) {
+		return err
+	}
+	// Otherwise what was read before the broken link has been shown
+
+	return nil
 }
 
 // sortCommitsByTime sorts commits by commit time (newest first)
@@ -190,67 +305,143 @@ func sortCommitsByTime(commits []commitInfo) {
 	}
 }
 
-// displayCommitsFull displays commits in full format
-func displayCommitsFull(commits []commitInfo) {
-	for i, info := range commits {
-		// Seal name or short hash
-		if info.SealName != "" {
-			fmt.Printf("%s %s\n", colors.Cyan("seal"), colors.Bold(info.SealName))
-		} else {
-			shortHash := hex.EncodeToString(info.Hash[:4])
-			fmt.Printf("%s %s\n", colors.Cyan("commit"), colors.Bold(shortHash))
-		}
+// logPrinter prints commits one at a time, restricted to the path
+// arguments and the --limit flag
+type logPrinter struct {
+	casStore cas.CAS
+	reader   *commit.CommitReader
+	paths    []string
+	shown    int
+}
 
-		// Author
-		fmt.Printf("Author: %s\n", colors.InfoText(info.Commit.Author))
+// show prints a commit, followed by its patch with --patch. Commits that
+// change none of the paths are skipped. It reports whether more commits
+// should be shown.
+func (p *logPrinter) show(info commitInfo) (bool, error) {
+	var parentFiles, files map[string]filechunk.NodeRef
+	var changed []string
+	if logPatch || len(p.paths) > 0 {
+		var err error
+		files, err = p.reader.FileRefs(info.Commit)
+		if err != nil {
+			return false, fmt.Errorf("failed to read files of %s: %w", hex.EncodeToString(info.Hash[:4]), err)
+		}
 
-		// Date
-		relTime := getRelativeTime(info.Commit.CommitTime)
-		fmt.Printf("Date:   %s (%s)\n",
-			info.Commit.CommitTime.Format("Mon Jan 2 15:04:05 2006"),
-			colors.Gray(relTime))
+		// The root commit is compared against an empty tree
+		var parent cas.Hash
+		if len(info.Commit.Parents) > 0 {
+			parent = info.Commit.Parents[0]
+		}
+		parentFiles, err = getCommitFileRefs(p.casStore, parent)
+		if err != nil {
+			return false, fmt.Errorf("failed to read parent of %s: %w", hex.EncodeToString(info.Hash[:4]), err)
+		}
 
-		// Timeline (if showing all)
-		if logAll {
-			fmt.Printf("Timeline: %s\n", colors.InfoText(info.Timeline))
+		changed = changedFilePaths(parentFiles, files)
+		if len(p.paths) > 0 {
+			var matching []string
+			for _, path := range changed {
+				if matchesAnyPath(path, p.paths) {
+					matching = append(matching, path)
+				}
+			}
+			if len(matching) == 0 {
+				return true, nil
+			}
+			changed = matching
 		}
+	}
 
-		// Message
-		fmt.Printf("\n    %s\n", info.Commit.Message)
+	info.ShowSignature = logShowSignature
+	if logOneline {
+		displayCommitOneline(info)
+	} else {
+		if p.shown > 0 {
+			fmt.Println()
+		}
+		displayCommitFull(info)
+	}
+	p.shown++
 
-		// Separator
-		if i < len(commits)-1 {
+	if logPatch && len(changed) > 0 {
+		if !logOneline {
 			fmt.Println()
 		}
+		loader := filechunk.NewLoader(p.casStore)
+		for _, path := range changed {
+			oldRef, inOld := parentFiles[path]
+			newRef, inNew := files[path]
+			if err := printFileRefDiff(loader, path, oldRef, newRef, inOld, inNew, false, logWhitespace, logHunks); err != nil {
+				return false, err
+			}
+		}
 	}
+
+	return logLimit <= 0 || p.shown < logLimit, nil
 }
 
-// displayCommitsOneline displays commits in one-line format
-func displayCommitsOneline(commits []commitInfo) {
-	for _, info := range commits {
-		// Hash or seal name
-		var id string
-		if info.SealName != "" {
-			id = colors.Cyan(info.SealName[:20]) // Truncate long names
-		} else {
-			shortHash := hex.EncodeToString(info.Hash[:4])
-			id = colors.Cyan(shortHash)
-		}
+// displayCommitFull displays a commit in full format
+func displayCommitFull(info commitInfo) {
+	// Seal name or short hash
+	if info.SealName != "" {
+		fmt.Printf("%s %s\n", colors.Cyan("seal"), colors.Bold(info.SealName))
+	} else {
+		shortHash := hex.EncodeToString(info.Hash[:4])
+		fmt.Printf("%s %s\n", colors.Cyan("commit"), colors.Bold(shortHash))
+	}
 
-		// Message (first line only)
-		message := info.Commit.Message
-		if len(message) > 60 {
-			message = message[:57] + "..."
-		}
+	if info.ShowSignature {
+		printSignature(info.Commit)
+	}
 
-		// Timeline indicator (if showing all)
-		timeline := ""
-		if logAll {
-			timeline = colors.Gray(fmt.Sprintf(" [%s]", info.Timeline))
-		}
+	// Author
+	fmt.Printf("Author: %s\n", colors.InfoText(info.Commit.Author))
+
+	// Date
+	relTime := getRelativeTime(info.Commit.CommitTime)
+	fmt.Printf("Date:   %s (%s)\n",
+		info.Commit.CommitTime.Format("Mon Jan 2 15:04:05 2006 -0700"),
+		colors.Gray(relTime))
+
+	// Timeline (if showing all)
+	if logAll {
+		fmt.Printf("Timeline: %s\n", colors.InfoText(info.Timeline))
+	}
+
+	// Message, indented line by line so trailers line up with the body
+	fmt.Println()
+	for _, line := range strings.Split(info.Commit.Message, "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// displayCommitOneline displays a commit in one-line format
+func displayCommitOneline(info commitInfo) {
+	// Hash or seal name
+	var id string
+	if info.SealName != "" {
+		id = colors.Cyan(info.SealName[:20]) // Truncate long names
+	} else {
+		shortHash := hex.EncodeToString(info.Hash[:4])
+		id = colors.Cyan(shortHash)
+	}
+
+	// Message (first line only)
+	message := info.Commit.Message
+	if len(message) > 60 {
+		message = message[:57] + "..."
+	}
+
+	// Timeline indicator (if showing all)
+	timeline := ""
+	if logAll {
+		timeline = colors.Gray(fmt.Sprintf(" [%s]", info.Timeline))
+	}
 
-		fmt.Printf("%s %s%s\n", id, message, timeline)
+	if info.ShowSignature {
+		printSignature(info.Commit)
 	}
+	fmt.Printf("%s %s%s\n", id, message, timeline)
 }
 
 // getRelativeTime returns a human-readable relative time string
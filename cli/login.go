@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/auth"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/github"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	loginHost      string
+	loginWithToken bool
+	logoutHost     string
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Store a GitHub access token for a host",
+	Long: `Store a personal access token so that Ivaldi can reach a GitHub server.
+
+The token is checked against the server before it is saved. By default it is
+kept encrypted in ~/.config/ivaldi/credentials.json; set credential.helper to
+hand it to a git credential helper such as an OS keyring instead. Saved tokens
+are used before any other authentication source.
+
+Examples:
+  ivaldi login                                   # Prompt for a github.com token
+  ivaldi login --host ghe.example.com            # Token for a GitHub Enterprise server
+  echo "$TOKEN" | ivaldi login --with-token      # Read the token from stdin`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endpoints := loginEndpoints(loginHost)
+
+		token, err := readLoginToken(endpoints.Host)
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return fmt.Errorf("no token provided")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client := github.NewClientWithToken(endpoints, token)
+		if err := client.TestAuth(ctx); err != nil {
+			return fmt.Errorf("token rejected by %s: %w", endpoints.Host, err)
+		}
+
+		store, err := auth.ConfiguredCredentialStore()
+		if err != nil {
+			return fmt.Errorf("failed to open credential store: %w", err)
+		}
+		if err := store.Store(endpoints.Host, token); err != nil {
+			return fmt.Errorf("failed to store token: %w", err)
+		}
+
+		fmt.Printf("%s Logged in to %s\n", colors.SuccessText("✓"), colors.Bold(endpoints.Host))
+		fmt.Printf("Token saved in %s\n", store.Describe())
+		return nil
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the stored access token for a host",
+	Long: `Remove the access token saved by 'ivaldi login' for a host.
+
+Other authentication sources, such as GITHUB_TOKEN or the GitHub CLI, are not
+affected.
+
+Examples:
+  ivaldi logout                          # Forget the github.com token
+  ivaldi logout --host ghe.example.com   # Forget a GitHub Enterprise token`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host := loginEndpoints(logoutHost).Host
+
+		store, err := auth.ConfiguredCredentialStore()
+		if err != nil {
+			return fmt.Errorf("failed to open credential store: %w", err)
+		}
+
+		token, err := store.Get(host)
+		if err != nil {
+			return fmt.Errorf("failed to read credential store: %w", err)
+		}
+		if token == "" {
+			fmt.Printf("No token stored for %s\n", host)
+			return nil
+		}
+
+		if err := store.Erase(host); err != nil {
+			return fmt.Errorf("failed to remove token: %w", err)
+		}
+
+		fmt.Printf("%s Logged out of %s\n", colors.SuccessText("✓"), colors.Bold(host))
+		return nil
+	},
+}
+
+// loginEndpoints returns the endpoints for an explicit --host, or those
+// configured for the current repository
+func loginEndpoints(host string) github.Endpoints {
+	if host != "" {
+		return github.EndpointsForHost(host)
+	}
+	return github.ResolveEndpoints()
+}
+
+// readLoginToken reads the token from stdin with --with-token, or prompts
+// for it without echo when stdin is a terminal
+func readLoginToken(host string) (string, error) {
+	if loginWithToken {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		// Accept a single piped line as a convenience
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read token: %w", err)
+		}
+		return strings.TrimSpace(line), nil
+	}
+
+	fmt.Printf("Paste an access token for %s: ", host)
+	data, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func init() {
+	loginCmd.Flags().StringVar(&loginHost, "host", "", "GitHub host to log in to (default: configured github.host or github.com)")
+	loginCmd.Flags().BoolVar(&loginWithToken, "with-token", false, "Read the token from standard input")
+	logoutCmd.Flags().StringVar(&logoutHost, "host", "", "GitHub host to log out of (default: configured github.host or github.com)")
+}
@@ -1,24 +1,28 @@
 package cli
 
 import (
-	"bufio"
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/colors"
 	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/converter"
 	"github.com/javanhut/Ivaldi-vcs/internal/github"
 	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/ignore"
+	"github.com/javanhut/Ivaldi-vcs/internal/pathspec"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/javanhut/Ivaldi-vcs/internal/seals"
 	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
@@ -26,15 +30,29 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// gitHubHosts returns the hosts recognized as GitHub: public GitHub plus
+// the configured Enterprise server, if any
+func gitHubHosts() []string {
+	hosts := []string{github.DefaultHost}
+	if endpoints := github.ResolveEndpoints(); endpoints.IsEnterprise() {
+		hosts = append(hosts, endpoints.Host)
+	}
+	return hosts
+}
+
 // isGitHubURL checks if the given URL is a GitHub repository URL
 func isGitHubURL(rawURL string) bool {
 	// Handle various GitHub URL formats
-	patterns := []string{
-		`^https?://github\.com/[\w-]+/[\w-]+`,
-		`^git@github\.com:[\w-]+/[\w-]+`,
-		`^github\.com/[\w-]+/[\w-]+`,
-		`^[\w-]+/[\w-]+$`, // Simple owner/repo format
+	var patterns []string
+	for _, host := range gitHubHosts() {
+		quoted := regexp.QuoteMeta(host)
+		patterns = append(patterns,
+			`^https?://`+quoted+`/[\w-]+/[\w-]+`,
+			`^git@`+quoted+`:[\w-]+/[\w-]+`,
+			`^`+quoted+`/[\w-]+/[\w-]+`,
+		)
 	}
+	patterns = append(patterns, `^[\w-]+/[\w-]+$`) // Simple owner/repo format
 
 	for _, pattern := range patterns {
 		matched, _ := regexp.MatchString(pattern, rawURL)
@@ -45,6 +63,20 @@ func isGitHubURL(rawURL string) bool {
 	return false
 }
 
+// gitHubURLHost returns the GitHub host named in a repository URL, or ""
+// for the plain owner/repo form and URLs of unknown hosts
+func gitHubURLHost(rawURL string) string {
+	for _, host := range gitHubHosts() {
+		for _, prefix := range []string{"https://", "http://", "git@", ""} {
+			rest, ok := strings.CutPrefix(rawURL, prefix+host)
+			if ok && (strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, ":")) {
+				return host
+			}
+		}
+	}
+	return ""
+}
+
 // parseGitHubURL extracts owner and repo from various GitHub URL formats
 func parseGitHubURL(rawURL string) (owner, repo string, err error) {
 	// Remove .git suffix if present
@@ -56,28 +88,28 @@ func parseGitHubURL(rawURL string) (owner, repo string, err error) {
 		return parts[0], parts[1], nil
 	}
 
-	// Handle full URLs
-	parsedURL, err := url.Parse(rawURL)
-	if err != nil {
-		// Try adding https:// if not present
-		if !strings.HasPrefix(rawURL, "http") && !strings.HasPrefix(rawURL, "git@") {
-			parsedURL, err = url.Parse("https://" + rawURL)
-			if err != nil {
-				return "", "", fmt.Errorf("invalid URL: %s", rawURL)
-			}
-		} else if strings.HasPrefix(rawURL, "git@github.com:") {
-			// Handle git@github.com:owner/repo format
-			path := strings.TrimPrefix(rawURL, "git@github.com:")
+	// Handle git@host:owner/repo format
+	for _, host := range gitHubHosts() {
+		if path, ok := strings.CutPrefix(rawURL, "git@"+host+":"); ok {
 			parts := strings.Split(path, "/")
 			if len(parts) == 2 {
 				return parts[0], parts[1], nil
 			}
 			return "", "", fmt.Errorf("invalid git URL format: %s", rawURL)
-		} else {
-			return "", "", err
 		}
 	}
 
+	// Add a scheme to host/owner/repo so the host is not taken as the owner
+	if !strings.Contains(rawURL, "://") && gitHubURLHost(rawURL) != "" {
+		rawURL = "https://" + rawURL
+	}
+
+	// Handle full URLs
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid URL: %s", rawURL)
+	}
+
 	// Extract path and parse owner/repo
 	path := strings.TrimPrefix(parsedURL.Path, "/")
 	parts := strings.Split(path, "/")
@@ -88,6 +120,25 @@ func parseGitHubURL(rawURL string) (owner, repo string, err error) {
 	return parts[0], parts[1], nil
 }
 
+// rememberGitHubHost records the host named in a repository URL in the
+// repository config, so later commands keep talking to that server. A
+// public GitHub URL clears a previously recorded Enterprise host.
+func rememberGitHubHost(rawURL string) {
+	host := gitHubURLHost(rawURL)
+	if host == "" {
+		return
+	}
+	if strings.EqualFold(host, github.DefaultHost) {
+		if current, err := config.GetValue("github.host"); err != nil || current == "" {
+			return
+		}
+		host = ""
+	}
+	if err := config.SetValue("github.host", host, false); err != nil {
+		log.Printf("Warning: Failed to record GitHub host: %v", err)
+	}
+}
+
 // handleGitHubDownload handles downloading/cloning from GitHub
 func handleGitHubDownload(rawURL string, args []string) error {
 	// Parse GitHub URL
@@ -118,10 +169,16 @@ func handleGitHubDownload(rawURL string, args []string) error {
 	}
 
 	// Initialize Ivaldi repository
-	ivaldiDir := ".ivaldi"
+	ivaldiDir := config.IvaldiDir()
 	if err := os.Mkdir(ivaldiDir, os.ModePerm); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to create .ivaldi directory: %w", err)
 	}
+	if err := copyTemplateRepo(ivaldiDir); err != nil {
+		log.Printf("Warning: Failed to copy template: %v", err)
+	}
+	if err := config.SetValue("core.hashAlgo", cas.HashAlgorithm, false); err != nil {
+		return fmt.Errorf("failed to record core.hashAlgo: %w", err)
+	}
 
 	log.Println("Ivaldi repository initialized")
 
@@ -157,6 +214,7 @@ func handleGitHubDownload(rawURL string, args []string) error {
 	} else {
 		fmt.Printf("Configured repository for GitHub: %s/%s\n", owner, repo)
 	}
+	rememberGitHubHost(rawURL)
 
 	// Create syncer and clone
 	syncer, err := github.NewRepoSyncer(ivaldiDir, workDir)
@@ -167,11 +225,32 @@ func handleGitHubDownload(rawURL string, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
+	if downloadBare {
+		fmt.Printf("Downloading from GitHub: %s/%s (bare)...\n", owner, repo)
+		if err := syncer.CloneRepositoryBare(ctx, owner, repo); err != nil {
+			return fmt.Errorf("failed to clone repository: %w", err)
+		}
+
+		// Mark the repository as bare so commands needing a working tree refuse to run
+		if err := config.SetValue("core.bare", "true", false); err != nil {
+			return fmt.Errorf("failed to mark repository as bare: %w", err)
+		}
+
+		fmt.Printf("Successfully downloaded repository from GitHub (no working tree)\n")
+		return nil
+	}
+
 	fmt.Printf("Downloading from GitHub: %s/%s...\n", owner, repo)
 	if err := syncer.CloneRepository(ctx, owner, repo); err != nil {
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
 
+	// Template files come after the clone, so the repository's own files
+	// take precedence
+	if err := copyTemplateWorkTree(workDir); err != nil {
+		log.Printf("Warning: Failed to copy template: %v", err)
+	}
+
 	// Automatically detect and convert Git submodules (enabled by default)
 	if recurseSubmodules {
 		gitmodulesPath := filepath.Join(workDir, ".gitmodules")
@@ -216,8 +295,14 @@ func handleGitHubDownload(rawURL string, args []string) error {
 	return nil
 }
 
+var (
+	uploadVerify      bool
+	uploadTags        bool
+	uploadSetUpstream bool
+)
+
 var uploadCmd = &cobra.Command{
-	Use:     "upload [branch]",
+	Use:     "upload [branch | tag:<name>...]",
 	Aliases: []string{"push"},
 	Short:   "Upload current timeline to GitHub",
 	Long: `Uploads the current timeline to the configured GitHub repository. The repository is automatically detected from the configuration set during 'ivaldi download'.
@@ -225,10 +310,21 @@ Examples:
   ivaldi upload                           # Upload current timeline to GitHub
   ivaldi upload main                      # Upload to specific branch on GitHub
   ivaldi upload github:owner/repo         # Upload to different GitHub repository (current timeline)
-  ivaldi upload github:owner/repo main    # Upload to different GitHub repository and branch`,
+  ivaldi upload github:owner/repo main    # Upload to different GitHub repository and branch
+  ivaldi upload --verify                  # Check the uploaded tree before moving the branch
+  ivaldi upload -u bugfix/login           # Upload and make bugfix/login the upstream
+  ivaldi upload --tags                    # Upload all local tags
+  ivaldi upload tag:v1.0                  # Upload a single tag
+
+The first upload of a timeline records its repository and branch as the
+timeline's upstream; with --set-upstream the upstream is replaced by the
+target of this upload. Later uploads and fetches without arguments use it.
+
+Tags point at the GitHub commit their seal was uploaded as, so upload the
+timeline first. Existing tags on GitHub are never moved.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -251,9 +347,29 @@ Examples:
 			return fmt.Errorf("failed to get current timeline: %w", err)
 		}
 
+		// tag:<name> arguments upload tags instead of the timeline
+		var tagNames []string
+		var rest []string
+		for _, arg := range args {
+			if name, ok := strings.CutPrefix(arg, "tag:"); ok {
+				tagNames = append(tagNames, name)
+			} else {
+				rest = append(rest, arg)
+			}
+		}
+		args = rest
+		tagMode := uploadTags || len(tagNames) > 0
+		if tagMode && (len(args) > 1 || len(args) == 1 && !strings.HasPrefix(args[0], "github:")) {
+			return fmt.Errorf("cannot upload a branch and tags at once")
+		}
+		if tagMode && uploadSetUpstream {
+			return fmt.Errorf("--set-upstream cannot be used when uploading tags")
+		}
+
 		// Auto-detect GitHub repository and branch
 		var owner, repo, branch string
-		branch = currentTimeline // Default branch to current timeline name
+		upstreamRemote, upstreamBranch := uploadTarget(currentTimeline)
+		branch = upstreamBranch // Default branch from upstream mapping or timeline name
 
 		// Check if GitHub repository is specified in arguments
 		if len(args) > 0 && strings.HasPrefix(args[0], "github:") {
@@ -269,6 +385,17 @@ Examples:
 			if len(args) > 1 {
 				branch = args[1]
 			}
+		} else if upstreamRemote != "" {
+			// Use the timeline's configured upstream repository
+			parts := strings.Split(upstreamRemote, "/")
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid branch.%s.remote value: %s (expected owner/repo)", currentTimeline, upstreamRemote)
+			}
+			owner, repo = parts[0], parts[1]
+
+			if len(args) > 0 {
+				branch = args[0]
+			}
 		} else {
 			// Try to auto-detect GitHub repository from configuration
 			var err error
@@ -283,6 +410,10 @@ Examples:
 			}
 		}
 
+		if tagMode {
+			return uploadTagsTo(ivaldiDir, workDir, refsManager, owner, repo, tagNames)
+		}
+
 		// Get current timeline's latest commit
 		timeline, err := refsManager.GetTimeline(currentTimeline, refs.LocalTimeline)
 		if err != nil {
@@ -307,16 +438,114 @@ Examples:
 		defer cancel()
 
 		fmt.Printf("Uploading to GitHub: %s/%s (branch: %s)...\n", owner, repo, branch)
-		if err := syncer.PushCommit(ctx, owner, repo, branch, commitHash); err != nil {
+		if err := syncer.PushCommit(ctx, owner, repo, branch, commitHash, uploadVerify); err != nil {
 			return fmt.Errorf("failed to push to GitHub: %w", err)
 		}
 
 		fmt.Printf("Successfully uploaded to GitHub\n")
+
+		// Remember where this timeline was pushed the first time, or every
+		// time with --set-upstream
+		remote, merge, err := config.GetUpstream(currentTimeline)
+		if err != nil {
+			log.Printf("Warning: Failed to read upstream for timeline '%s': %v", currentTimeline, err)
+			return nil
+		}
+		if uploadSetUpstream || remote == "" && merge == "" {
+			if err := config.SetUpstream(currentTimeline, owner+"/"+repo, branch); err != nil {
+				if uploadSetUpstream {
+					return fmt.Errorf("failed to record upstream for timeline '%s': %w", currentTimeline, err)
+				}
+				log.Printf("Warning: Failed to record upstream for timeline '%s': %v", currentTimeline, err)
+			} else {
+				fmt.Printf("Timeline '%s' set up to track %s/%s branch '%s'\n", currentTimeline, owner, repo, branch)
+			}
+		}
+
 		return nil
 	},
 }
 
+// uploadTagsTo uploads the named local tags, or all of them when names is
+// empty. Every tag is attempted; failures are reported together at the end.
+func uploadTagsTo(ivaldiDir, workDir string, refsManager *refs.RefsManager, owner, repo string, names []string) error {
+	var tags []refs.Timeline
+	if len(names) == 0 {
+		all, err := refsManager.ListTimelines(refs.TagTimeline)
+		if err != nil {
+			return fmt.Errorf("failed to list tags: %w", err)
+		}
+		if len(all) == 0 {
+			fmt.Println("No tags to upload.")
+			return nil
+		}
+		tags = all
+	} else {
+		for _, name := range names {
+			tag, err := refsManager.GetTimeline(name, refs.TagTimeline)
+			if err != nil {
+				return fmt.Errorf("tag '%s' not found", name)
+			}
+			tags = append(tags, *tag)
+		}
+	}
+
+	syncer, err := github.NewRepoSyncer(ivaldiDir, workDir)
+	if err != nil {
+		return fmt.Errorf("failed to create syncer: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	fmt.Printf("Uploading %d tag(s) to GitHub: %s/%s...\n", len(tags), owner, repo)
+	failed := 0
+	for _, tag := range tags {
+		var commitHash cas.Hash
+		copy(commitHash[:], tag.Blake3Hash[:])
+
+		// Local tags are lightweight; they record no annotation to upload
+		created, err := syncer.PushTag(ctx, owner, repo, github.TagPush{
+			Name:   tag.Name,
+			Commit: commitHash,
+			GitSHA: tag.GitSHA1Hash,
+		})
+		switch {
+		case err != nil:
+			fmt.Printf("%s %v\n", colors.Red("Rejected:"), err)
+			failed++
+		case created:
+			fmt.Printf("%s %s\n", colors.Green("Created:"), tag.Name)
+		default:
+			fmt.Printf("Up to date: %s\n", tag.Name)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to upload %d of %d tag(s)", failed, len(tags))
+	}
+	return nil
+}
+
+// uploadTarget returns the upstream repository (owner/repo, possibly empty)
+// and remote branch that upload uses for a timeline. The branch comes from
+// branch.<timeline>.merge unless push.default is "current", and falls back
+// to the timeline name.
+func uploadTarget(timeline string) (string, string) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return "", timeline
+	}
+
+	upstream := cfg.Branch[timeline]
+	if cfg.Push.Default == config.PushCurrent || upstream.Merge == "" {
+		return upstream.Remote, timeline
+	}
+	return upstream.Remote, upstream.Merge
+}
+
 var recurseSubmodules bool
+var downloadBare bool
 var statusVerbose bool
 
 var downloadCmd = &cobra.Command{
@@ -356,7 +585,8 @@ var downloadCmd = &cobra.Command{
 	},
 }
 
-// Auto-excluded patterns that are always ignored for security
+// Auto-excluded patterns that are always ignored for security.
+// These can be overridden with the security.secretpatterns config key.
 var autoExcludePatterns = []string{
 	".env",
 	".env.*",
@@ -364,32 +594,102 @@ var autoExcludePatterns = []string{
 	".venv/",
 }
 
+// getAutoExcludePatterns returns the configured secret patterns, falling
+// back to the defaults when security.secretpatterns is not set. A value
+// set to empty turns the patterns off.
+func getAutoExcludePatterns() []string {
+	cfg, err := config.LoadConfig()
+	if err == nil && cfg.Security.SecretPatterns != nil {
+		return *cfg.Security.SecretPatterns
+	}
+	return autoExcludePatterns
+}
+
+var (
+	sealAllowSecrets bool
+	sealNoVerify     bool
+	sealSignoff      bool
+	sealSign         bool
+	sealNoSign       bool
+	sealTrailers     []string
+	sealDate         string
+	sealAuthorDate   string
+)
+
+var (
+	gatherAllowAll    bool
+	gatherIntentToAdd bool
+	gatherAll         bool
+	gatherRemoved     bool
+	gatherQuiet       int
+)
+
+// gatherListLimit is the most already-staged files gather lists by name;
+// more are reported as a count
+const gatherListLimit = 20
+
+// gatherWarnf logs a gather warning unless it was silenced with -qq
+func gatherWarnf(format string, args ...any) {
+	if gatherQuiet < 2 {
+		log.Printf(format, args...)
+	}
+}
+
 var gatherCmd = &cobra.Command{
 	Use:   "gather [files...]",
 	Short: "Stage files for the next seal/commit",
-	Long:  `Gathers (stages) specified files or all modified files that will be included in the next seal operation`,
+	Long: `Gathers (stages) specified files or all modified files that will be included in the next seal operation
+
+Tracked files that were deleted are staged as removals: all of them when no
+files are given (or with --all), otherwise those at or below the given
+paths. --removed stages only removals and no file content.
+
+Paths may be glob patterns, expanded by gather itself rather than the shell:
+* and ? match within a directory, ** spans directories, and a pattern
+without a slash matches at any depth. Arguments starting with :! or :^
+exclude the files they match. Ignore rules apply to the matched files.
+Quote patterns so the shell leaves them alone.
+
+Each gathered file is listed; files that were already staged are listed too
+when there are only a few, and counted otherwise. --quiet prints only the
+summary, while warnings about hidden and excluded files still show; give it
+twice (-qq) to silence those as well.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
 
+		if err := requireWorkTree("gather"); err != nil {
+			return err
+		}
+		secretPatterns := getAutoExcludePatterns()
+
+		if gatherIntentToAdd && len(args) == 0 {
+			return fmt.Errorf("--intent-to-add requires the files to mark")
+		}
+		// Paths are given from wherever gather was started
+		args = pathspec.Within(workPrefix, args)
+		if gatherAll && len(args) > 0 {
+			return fmt.Errorf("--all cannot be combined with file arguments")
+		}
+		if gatherIntentToAdd && gatherRemoved {
+			return fmt.Errorf("--intent-to-add cannot be combined with --removed")
+		}
+
 		workDir, err := os.Getwd()
 		if err != nil {
 			return fmt.Errorf("failed to get working directory: %w", err)
 		}
 
-		// Get --allow-all flag
-		allowAll, err := cmd.Flags().GetBool("allow-all")
-		if err != nil {
-			return fmt.Errorf("failed to get allow-all flag: %w", err)
-		}
+		allowAll := gatherAllowAll
 
-		// Load ignore patterns from .ivaldiignore
-		ignorePatterns, err := loadIgnorePatternsForGather(workDir)
+		// Load the allowlist from .ivaldiinclude and ignore patterns from .ivaldiignore
+		ignoreRules, err := ignore.Load(workDir)
 		if err != nil {
 			log.Printf("Warning: Failed to load ignore patterns: %v", err)
+			ignoreRules = &ignore.Rules{}
 		}
 
 		// Create staging area directory
@@ -398,11 +698,50 @@ var gatherCmd = &cobra.Command{
 			return fmt.Errorf("failed to create staging directory: %w", err)
 		}
 
+		// Paths of the arguments relative to the working directory, in the
+		// form tracked files are recorded in
+		var relArgs, missingArgs []string
+		for _, arg := range args {
+			absPath := arg
+			if !filepath.IsAbs(arg) {
+				absPath = filepath.Join(workDir, arg)
+			}
+			relArg, err := filepath.Rel(workDir, absPath)
+			if err != nil {
+				relArg = arg
+			}
+			if config.PrecomposeUnicode() {
+				relArg = workspace.NormalizePath(relArg)
+			}
+			relArgs = append(relArgs, relArg)
+			if pathspec.IsPattern(arg) {
+				continue
+			}
+			if _, err := os.Lstat(absPath); os.IsNotExist(err) {
+				missingArgs = append(missingArgs, relArg)
+			}
+		}
+
+		// Globs and exclusions select from every file of the working
+		// directory, together with any plain paths given with them
+		var spec *pathspec.Spec
+		if pathspec.HasPatterns(args) {
+			spec = pathspec.Parse(relArgs)
+		}
+
 		var filesToGather []string
 
-		if len(args) == 0 {
+		// Files of repositories nested in the workspace belong to those
+		// repositories and are not gathered
+		nested := workspace.NewNestedRepos(workDir, config.SkipNestedRepos())
+
+		if gatherRemoved {
+			// Only removals are staged
+		} else if len(args) == 0 || spec != nil {
 			// If no arguments, gather all modified files
-			fmt.Println("No files specified, gathering all files in working directory...")
+			if gatherQuiet == 0 && spec == nil {
+				fmt.Println("No files specified, gathering all files in working directory...")
+			}
 			err := filepath.Walk(workDir, func(path string, info os.FileInfo, err error) error {
 				if err != nil {
 					return err
@@ -414,24 +753,36 @@ var gatherCmd = &cobra.Command{
 					return err
 				}
 
-				// Skip directories
+				// Skip directories, except the .ivaldi directory and nested
+				// repositories, which are not descended into
 				if info.IsDir() {
+					if info.Name() == ".ivaldi" {
+						return filepath.SkipDir
+					}
+					if nested.Skip(path) {
+						gatherWarnf("Skipping nested repository: %s", relPath)
+						return filepath.SkipDir
+					}
 					return nil
 				}
 
-				// Skip .ivaldi directory
-				if strings.HasPrefix(relPath, ".ivaldi"+string(filepath.Separator)) || relPath == ".ivaldi" {
+				if spec != nil && !spec.Matches(filepath.ToSlash(relPath)) {
 					return nil
 				}
 
 				// Check if file is auto-excluded (.env, .venv, etc.)
-				if isAutoExcluded(relPath) {
-					log.Printf("Auto-excluded for security: %s", relPath)
+				if isAutoExcluded(relPath, secretPatterns) {
+					gatherWarnf("Auto-excluded for security: %s", relPath)
 					return nil
 				}
 
-				// Skip hidden files/dirs EXCEPT .ivaldiignore
-				if filepath.Base(path)[0] == '.' && relPath != ".ivaldiignore" {
+				// With an .ivaldiinclude, skip files it does not list
+				if !ignoreRules.Included(relPath) {
+					return nil
+				}
+
+				// Skip hidden files/dirs EXCEPT .ivaldiignore and .ivaldiinclude
+				if filepath.Base(path)[0] == '.' && relPath != ignore.IgnoreFile && relPath != ignore.IncludeFile {
 					// Prompt user for dot files unless --allow-all is set
 					if !allowAll {
 						if shouldGatherDotFile(relPath) {
@@ -440,12 +791,12 @@ var gatherCmd = &cobra.Command{
 						return nil
 					} else {
 						// With --allow-all, still warn about dot files
-						fmt.Printf("Warning: Gathering hidden file: %s\n", relPath)
+						gatherWarnf("Warning: Gathering hidden file: %s", relPath)
 					}
 				}
 
 				// Skip ignored files (but never ignore .ivaldiignore itself)
-				if isFileIgnored(relPath, ignorePatterns) {
+				if ignoreRules.Ignored(relPath) {
 					return nil
 				}
 
@@ -466,7 +817,12 @@ var gatherCmd = &cobra.Command{
 
 				info, err := os.Stat(absPath)
 				if os.IsNotExist(err) {
-					log.Printf("Warning: File '%s' does not exist, skipping", arg)
+					// Deleted tracked files are staged as removals below
+					continue
+				}
+
+				if repo, ok := nested.Within(absPath); ok {
+					gatherWarnf("Warning: '%s' is inside the nested repository %s, skipping", arg, repo.Path)
 					continue
 				}
 
@@ -477,8 +833,14 @@ var gatherCmd = &cobra.Command{
 							return err
 						}
 
-						// Skip directories
+						// Skip directories, and do not descend into nested
+						// repositories
 						if info.IsDir() {
+							if nested.Skip(path) {
+								relDir, _ := filepath.Rel(workDir, path)
+								gatherWarnf("Skipping nested repository: %s", relDir)
+								return filepath.SkipDir
+							}
 							return nil
 						}
 
@@ -499,8 +861,8 @@ var gatherCmd = &cobra.Command{
 						}
 
 						// Check if file is auto-excluded
-						if isAutoExcluded(relPath) {
-							log.Printf("Auto-excluded for security: %s", relPath)
+						if isAutoExcluded(relPath, secretPatterns) {
+							gatherWarnf("Auto-excluded for security: %s", relPath)
 							return nil
 						}
 
@@ -512,13 +874,13 @@ var gatherCmd = &cobra.Command{
 								}
 								return nil
 							} else {
-								fmt.Printf("Warning: Gathering hidden file: %s\n", relPath)
+								gatherWarnf("Warning: Gathering hidden file: %s", relPath)
 							}
 						}
 
 						// Skip ignored files (but never ignore .ivaldiignore itself)
-						if isFileIgnored(relPath, ignorePatterns) {
-							log.Printf("Skipping ignored file: %s", relPath)
+						if ignoreRules.Ignored(relPath) {
+							gatherWarnf("Skipping ignored file: %s", relPath)
 							return nil
 						}
 
@@ -526,7 +888,7 @@ var gatherCmd = &cobra.Command{
 						return nil
 					})
 					if err != nil {
-						log.Printf("Warning: Failed to walk directory '%s': %v", arg, err)
+						gatherWarnf("Warning: Failed to walk directory '%s': %v", arg, err)
 					}
 				} else {
 					// It's a file, get relative path
@@ -537,25 +899,25 @@ var gatherCmd = &cobra.Command{
 					}
 
 					// Check if file is auto-excluded
-					if isAutoExcluded(relPath) {
-						log.Printf("Warning: File '%s' is auto-excluded for security, skipping", relPath)
+					if isAutoExcluded(relPath, secretPatterns) {
+						gatherWarnf("Warning: File '%s' is auto-excluded for security, skipping", relPath)
 						continue
 					}
 
-					// Check for dot files (except .ivaldiignore)
-					if (filepath.Base(relPath)[0] == '.' || strings.Contains(relPath, "/.")) && relPath != ".ivaldiignore" {
+					// Check for dot files (except .ivaldiignore and .ivaldiinclude)
+					if (filepath.Base(relPath)[0] == '.' || strings.Contains(relPath, "/.")) && relPath != ignore.IgnoreFile && relPath != ignore.IncludeFile {
 						if !allowAll {
 							if !shouldGatherDotFile(relPath) {
 								continue
 							}
 						} else {
-							fmt.Printf("Warning: Gathering hidden file: %s\n", relPath)
+							gatherWarnf("Warning: Gathering hidden file: %s", relPath)
 						}
 					}
 
 					// Check if file is ignored
-					if isFileIgnored(relPath, ignorePatterns) {
-						log.Printf("Warning: File '%s' is in .ivaldiignore, skipping", relPath)
+					if ignoreRules.Ignored(relPath) {
+						gatherWarnf("Warning: File '%s' is in .ivaldiignore, skipping", relPath)
 						continue
 					}
 
@@ -564,11 +926,67 @@ var gatherCmd = &cobra.Command{
 			}
 		}
 
-		if len(filesToGather) == 0 {
-			fmt.Println("No files to gather.")
+		// Deleted tracked files are staged as removals, everywhere when
+		// gathering all files and otherwise at or below the given paths
+		var removals []string
+		if !gatherIntentToAdd && spec != nil {
+			deleted, err := findRemovedFiles(ivaldiDir, workDir, nil)
+			if err != nil {
+				return err
+			}
+			for _, file := range deleted {
+				if spec.Matches(file) {
+					removals = append(removals, file)
+				}
+			}
+		} else if !gatherIntentToAdd {
+			removals, err = findRemovedFiles(ivaldiDir, workDir, relArgs)
+			if err != nil {
+				return err
+			}
+		}
+		if spec != nil {
+			for _, pattern := range spec.Unmatched() {
+				gatherWarnf("Warning: '%s' did not match any files", pattern)
+			}
+		}
+		for _, arg := range missingArgs {
+			found := false
+			for _, file := range removals {
+				if matchesAnyPath(file, []string{arg}) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				gatherWarnf("Warning: File '%s' does not exist, skipping", arg)
+			}
+		}
+
+		if len(filesToGather) == 0 && len(removals) == 0 {
+			if gatherQuiet < 2 {
+				fmt.Println("No files to gather.")
+			}
 			return nil
 		}
 
+		// Stage names in the form the workspace scan records them
+		if config.PrecomposeUnicode() {
+			for i, file := range filesToGather {
+				filesToGather[i] = workspace.NormalizePath(file)
+			}
+		}
+
+		stageLock, err := lockStage(ivaldiDir)
+		if err != nil {
+			return err
+		}
+		defer stageLock.Release()
+
+		if gatherIntentToAdd {
+			return gatherIntent(ivaldiDir, filesToGather)
+		}
+
 		// Read existing staged files
 		stageFile := filepath.Join(stageDir, "files")
 		existingStaged := make(map[string]bool)
@@ -586,6 +1004,9 @@ var gatherCmd = &cobra.Command{
 		for _, file := range filesToGather {
 			existingStaged[file] = true
 		}
+		for _, file := range removals {
+			delete(existingStaged, file)
+		}
 
 		// Write all staged files
 		f, err := os.Create(stageFile)
@@ -594,27 +1015,93 @@ var gatherCmd = &cobra.Command{
 		}
 		defer f.Close()
 
+		gathered := make(map[string]bool, len(filesToGather))
+		for _, file := range filesToGather {
+			gathered[file] = true
+		}
+		var alreadyStaged []string
 		stagedCount := 0
 		for file := range existingStaged {
 			if _, err := f.WriteString(file + "\n"); err != nil {
 				return fmt.Errorf("failed to write to stage file: %w", err)
 			}
-			// Only print for newly gathered files
-			found := false
-			for _, newFile := range filesToGather {
-				if newFile == file {
+			if !gathered[file] {
+				alreadyStaged = append(alreadyStaged, file)
+			}
+			stagedCount++
+		}
+
+		if gatherQuiet == 0 {
+			for _, file := range filesToGather {
+				if gathered[file] {
 					fmt.Printf("Gathered: %s\n", file)
-					found = true
-					break
+					delete(gathered, file)
 				}
 			}
-			if !found {
-				fmt.Printf("Already staged: %s\n", file)
+			if len(alreadyStaged) > gatherListLimit {
+				fmt.Printf("Already staged: %d files\n", len(alreadyStaged))
+			} else {
+				sort.Strings(alreadyStaged)
+				for _, file := range alreadyStaged {
+					fmt.Printf("Already staged: %s\n", file)
+				}
 			}
-			stagedCount++
 		}
 
-		fmt.Printf("Successfully gathered %d files for staging (total staged: %d).\n", len(filesToGather), stagedCount)
+		// With snapshot staging the content is stored now, for seal to
+		// commit as gathered
+		if err := updateStagedSnapshots(ivaldiDir, workDir, filesToGather, removals); err != nil {
+			return err
+		}
+
+		// Gathered content replaces any intent-to-add marker
+		if err := dropIntentToAdd(ivaldiDir, filesToGather); err != nil {
+			log.Printf("Warning: Failed to update intent-to-add list: %v", err)
+		}
+
+		// A file gathered again is no longer removed
+		if err := dropStagedRemovals(ivaldiDir, filesToGather); err != nil {
+			return err
+		}
+		if err := addStagedRemovals(ivaldiDir, removals); err != nil {
+			return err
+		}
+		if gatherQuiet == 0 {
+			for _, file := range removals {
+				fmt.Printf("Removed: %s\n", file)
+			}
+		}
+
+		// Gathering a conflicted file during a fuse records it as resolved
+		resolved, err := recordGatheredResolutions(ivaldiDir, workDir, filesToGather)
+		if gatherQuiet == 0 {
+			for _, path := range resolved {
+				fmt.Printf("Resolved conflict: %s\n", path)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if gatherQuiet > 0 {
+			if len(filesToGather) > 0 {
+				fmt.Printf("Gathered %d files (total staged: %d).\n", len(filesToGather), stagedCount)
+			}
+			if len(removals) > 0 {
+				fmt.Printf("Staged the removal of %d deleted files.\n", len(removals))
+			}
+			if len(resolved) > 0 {
+				fmt.Printf("Resolved %d conflicts.\n", len(resolved))
+			}
+			return nil
+		}
+
+		if len(filesToGather) > 0 {
+			fmt.Printf("Successfully gathered %d files for staging (total staged: %d).\n", len(filesToGather), stagedCount)
+		}
+		if len(removals) > 0 {
+			fmt.Printf("Staged the removal of %d deleted files.\n", len(removals))
+		}
 		fmt.Println("Use 'ivaldi seal <message>' to create a commit with these files.")
 
 		return nil
@@ -625,31 +1112,80 @@ var sealCmd = &cobra.Command{
 	Use:   "seal <message>",
 	Short: "Create a sealed commit with gathered files",
 	Args:  cobra.ExactArgs(1),
-	Long:  `Creates a sealed commit (equivalent to git commit) with the files that were gathered (staged)`,
+	Long: `Creates a sealed commit (equivalent to git commit) with the files that were gathered (staged)
+
+Files of the last seal that were not gathered are carried over unchanged, and
+deleted files whose removal was gathered are left out.
+
+During a fuse that stopped on conflicts, seal concludes the fuse once every
+conflicted file has been gathered, as 'ivaldi fuse --continue' does: the
+seal gets the source timeline's seal as its second parent.
+
+--trailer adds "Key: Value" lines such as Co-authored-by to the end of the
+message, and --signoff adds a Signed-off-by line for user.name and
+user.email.
+
+--sign signs the seal with user.signingKey, using ssh-keygen for SSH keys
+and gpg otherwise. Setting commit.gpgSign or commit.sshSign to true signs
+every seal in that format without --sign; --no-sign skips it once.
+
+--date sets the time of the seal and --author-date the time its change was
+authored, for reproducible seals and back-dated imports. Both take RFC3339,
+YYYY-MM-DD, "YYYY-MM-DD HH:MM" or relative forms such as "2 days ago", and
+keep the timezone they are given in. --date alone sets both times.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		message := args[0]
+		message, err := withTrailers(args[0], sealTrailers, sealSignoff)
+		if err != nil {
+			return err
+		}
+		if sealSign && sealNoSign {
+			return fmt.Errorf("--sign and --no-sign cannot be used together")
+		}
+		authorTime, commitTime, err := sealTimes(time.Now())
+		if err != nil {
+			return err
+		}
 
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
 
-		// Check if there are staged files
-		stageFile := filepath.Join(ivaldiDir, "stage", "files")
-		if _, err := os.Stat(stageFile); os.IsNotExist(err) {
-			return fmt.Errorf("no files staged for commit. Use 'ivaldi gather' to stage files first")
+		if err := requireWorkTree("seal"); err != nil {
+			return err
 		}
 
-		// Read staged files
-		stageData, err := os.ReadFile(stageFile)
+		stageLock, err := lockStage(ivaldiDir)
+		if err != nil {
+			return err
+		}
+		defer stageLock.Release()
+
+		// Read staged files and removals
+		stageFile := filepath.Join(ivaldiDir, "stage", "files")
+		stagedFiles, err := getStagedFilesList(ivaldiDir)
 		if err != nil {
 			return fmt.Errorf("failed to read staged files: %w", err)
 		}
+		removals, err := getStagedRemovals(ivaldiDir)
+		if err != nil {
+			return err
+		}
+		if len(stagedFiles) == 0 && len(removals) == 0 {
+			return fmt.Errorf("no files staged for commit. Use 'ivaldi gather' to stage files first")
+		}
+
+		// Final safety check: refuse to seal files that look like secrets
+		if err := refuseSecrets(stagedFiles, sealAllowSecrets); err != nil {
+			return err
+		}
 
-		stagedFiles := strings.Fields(string(stageData))
-		if len(stagedFiles) == 0 {
-			return fmt.Errorf("no files staged for commit")
+		// Run the pre-seal hook, which can block the seal (e.g. 'ivaldi diff --check')
+		if !sealNoVerify {
+			if err := runHook(ivaldiDir, "pre-seal"); err != nil {
+				return fmt.Errorf("seal aborted: %w", err)
+			}
 		}
 
 		// Initialize refs manager
@@ -665,13 +1201,43 @@ var sealCmd = &cobra.Command{
 			return fmt.Errorf("failed to get current timeline: %w", err)
 		}
 
+		// A fuse stopped on conflicts and resolved by hand is concluded by
+		// the seal, which then records the source as its second parent
+		mergeState, resolution, err := mergeToSeal(ivaldiDir, currentTimeline)
+		if err != nil {
+			return err
+		}
+		// Its conflicted files are taken from the workspace, gathered or not
+		var conflicted map[string]bool
+		var workspacePaths []string
+		if mergeState != nil {
+			conflictPaths, err := loadMergeConflicts(ivaldiDir)
+			if err != nil {
+				return fmt.Errorf("failed to read conflict list: %w", err)
+			}
+			conflicted = make(map[string]bool, len(conflictPaths))
+			for _, path := range conflictPaths {
+				conflicted[path] = true
+			}
+			workspacePaths = conflictPaths
+		}
+		for _, path := range stagedFiles {
+			if !conflicted[path] {
+				workspacePaths = append(workspacePaths, path)
+			}
+		}
+
 		workDir, err := os.Getwd()
 		if err != nil {
 			return fmt.Errorf("failed to get working directory: %w", err)
 		}
 
 		// Create commit using the new commit system
-		fmt.Printf("Creating commit objects for %d staged files...\n", len(stagedFiles))
+		if len(removals) > 0 {
+			fmt.Printf("Creating commit objects for %d staged files and %d removals...\n", len(stagedFiles), len(removals))
+		} else {
+			fmt.Printf("Creating commit objects for %d staged files...\n", len(stagedFiles))
+		}
 
 		// Initialize storage system with persistent file-based CAS
 		objectsDir := filepath.Join(ivaldiDir, "objects")
@@ -685,33 +1251,65 @@ var sealCmd = &cobra.Command{
 		// Create materializer to scan workspace
 		materializer := workspace.NewMaterializer(casStore, ivaldiDir, workDir)
 
-		// Scan the current workspace to create file metadata
-		wsIndex, err := materializer.ScanWorkspace()
-		if err != nil {
-			return fmt.Errorf("failed to scan workspace: %w", err)
-		}
-
-		// Get workspace files
+		// Staged content comes from the snapshots taken by gather with
+		// snapshot staging, and otherwise from a scan of the workspace
 		wsLoader := wsindex.NewLoader(casStore)
-		allWorkspaceFiles, err := wsLoader.ListAll(wsIndex)
-		if err != nil {
-			return fmt.Errorf("failed to list workspace files: %w", err)
+		var allWorkspaceFiles []wsindex.FileMetadata
+		if config.SnapshotStaging() {
+			allWorkspaceFiles, err = stagedSnapshotFiles(materializer, ivaldiDir, workspacePaths)
+			if err != nil {
+				return err
+			}
+		} else {
+			wsIndex, err := materializer.ScanWorkspace()
+			if err != nil {
+				return fmt.Errorf("failed to scan workspace: %w", err)
+			}
+			allWorkspaceFiles, err = wsLoader.ListAll(wsIndex)
+			if err != nil {
+				return fmt.Errorf("failed to list workspace files: %w", err)
+			}
 		}
 
-		// Filter workspace files to only include staged files
-		stagedFileMap := make(map[string]bool)
-		for _, file := range stagedFiles {
-			stagedFileMap[file] = true
-		}
+		// Get parent commit from current timeline
+		var parents []cas.Hash
+		var parentFiles []wsindex.FileMetadata
+		var parentHead [32]byte
+		timeline, err := refsManager.GetTimeline(currentTimeline, refs.LocalTimeline)
+		if err == nil && timeline.Blake3Hash != [32]byte{} {
+			parentHead = timeline.Blake3Hash
+			// Timeline has a previous commit, use it as parent
+			var parentHash cas.Hash
+			copy(parentHash[:], timeline.Blake3Hash[:])
+			parents = append(parents, parentHash)
 
-		var workspaceFiles []wsindex.FileMetadata
-		for _, file := range allWorkspaceFiles {
-			if stagedFileMap[file.Path] {
-				workspaceFiles = append(workspaceFiles, file)
+			parentIndex, err := materializer.CreateTargetIndex(*timeline)
+			if err != nil {
+				return fmt.Errorf("failed to read parent seal: %w", err)
+			}
+			parentFiles, err = wsLoader.ListAll(parentIndex)
+			if err != nil {
+				return fmt.Errorf("failed to list parent seal files: %w", err)
+			}
+		}
+		// A seal concluding a fuse starts from what the fuse merged cleanly
+		var targetIndex wsindex.IndexRef
+		if mergeState != nil {
+			parents = append(parents, mergeState.SourceHash)
+			targetIndex, parentFiles, err = mergeSealFiles(casStore, ivaldiDir, workDir, mergeState, resolution, conflicted, allWorkspaceFiles)
+			if err != nil {
+				return err
 			}
 		}
 
-		fmt.Printf("Found %d files in workspace\n", len(workspaceFiles))
+		// Staged files replace the files of the parent seal and staged
+		// removals drop them; everything else is carried over
+		workspaceFiles, err := workspace.SealFiles(parentFiles, allWorkspaceFiles, stagedFiles, removals)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Sealing %d files\n", len(workspaceFiles))
 
 		// Get author from config
 		author, err := getAuthorFromConfig()
@@ -719,23 +1317,23 @@ var sealCmd = &cobra.Command{
 			return fmt.Errorf("failed to get author from config: %w\nPlease set user.name and user.email: ivaldi config user.name \"Your Name\"", err)
 		}
 
-		// Get parent commit from current timeline
-		var parents []cas.Hash
-		timeline, err := refsManager.GetTimeline(currentTimeline, refs.LocalTimeline)
-		if err == nil && timeline.Blake3Hash != [32]byte{} {
-			// Timeline has a previous commit, use it as parent
-			var parentHash cas.Hash
-			copy(parentHash[:], timeline.Blake3Hash[:])
-			parents = append(parents, parentHash)
+		signer, err := sealSigner(sealSign, sealNoSign)
+		if err != nil {
+			return err
+		}
+		if signer != nil {
+			commitBuilder.Sign = signer.Sign
 		}
 
 		// Create commit object
-		commitObj, err := commitBuilder.CreateCommit(
+		commitObj, err := commitBuilder.CreateCommitAt(
 			workspaceFiles,
 			parents,
 			author,
 			author,
 			message,
+			authorTime,
+			commitTime,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to create commit: %w", err)
@@ -748,6 +1346,16 @@ var sealCmd = &cobra.Command{
 		var commitHashArray [32]byte
 		copy(commitHashArray[:], commitHash[:])
 
+		// Update the timeline reference with commit hash, unless another
+		// command moved it since the parent was read
+		err = refsManager.UpdateTimelineIf(currentTimeline, refs.LocalTimeline, parentHead, commitHashArray, [32]byte{}, "")
+		if errors.Is(err, refs.ErrTimelineMoved) {
+			return fmt.Errorf("timeline '%s' was updated while sealing, so nothing was sealed; check its new seal and seal again", currentTimeline)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update timeline: %w", err)
+		}
+
 		// Generate and store seal name
 		sealName := seals.GenerateSealName(commitHashArray)
 		err = refsManager.StoreSealName(sealName, commitHashArray, message)
@@ -755,64 +1363,187 @@ var sealCmd = &cobra.Command{
 			log.Printf("Warning: Failed to store seal name: %v", err)
 		}
 
-		// Update the timeline reference with commit hash
-		err = refsManager.CreateTimeline(
-			currentTimeline,
-			refs.LocalTimeline,
-			commitHashArray,
-			[32]byte{}, // No SHA256 for now
-			"",         // No Git SHA1
-			fmt.Sprintf("Commit: %s", message),
-		)
-		if err != nil {
-			// Timeline already exists, this is expected - in a real system we'd update it
-			log.Printf("Note: Timeline update not yet implemented, but workspace state saved")
-		}
-
 		fmt.Printf("%s on timeline '%s'\n", colors.SuccessText("Successfully sealed commit"), colors.Bold(currentTimeline))
 		fmt.Printf("Created seal: %s (%s)\n", colors.Cyan(sealName), colors.Gray(hex.EncodeToString(commitHashArray[:4])))
 		fmt.Printf("Commit message: %s\n", colors.InfoText(message))
+		if signer != nil {
+			fmt.Printf("Signed with %s key %s\n", signer.Format, colors.Gray(signer.Key))
+		}
+		if mergeState != nil {
+			fromWorkspace := make(map[string]bool, len(workspacePaths)+len(removals))
+			for _, path := range append(workspacePaths, removals...) {
+				fromWorkspace[path] = true
+			}
+			if err := applyCleanMerge(casStore, ivaldiDir, workDir, refsManager, mergeState, targetIndex, workspaceFiles, fromWorkspace); err != nil {
+				log.Printf("Warning: Failed to write the merged files to the workspace: %v", err)
+			}
+			finishMerge(ivaldiDir, resolution)
+			fmt.Printf("Concluded fuse of %s into %s\n", colors.Bold(mergeState.SourceTimeline), colors.Bold(mergeState.TargetTimeline))
+		}
 
 		// Status tracking is now handled by the workspace system
 
 		// Clean up staging area
-		if err := os.Remove(stageFile); err != nil {
+		if err := os.Remove(stageFile); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Failed to clean up staging area: %v", err)
+		}
+		if err := writeStagedRemovals(ivaldiDir, nil); err != nil {
+			log.Printf("Warning: Failed to clean up staging area: %v", err)
+		}
+		if err := workspace.WriteStagedSnapshots(stagedSnapshotsFile(ivaldiDir), nil); err != nil {
 			log.Printf("Warning: Failed to clean up staging area: %v", err)
 		}
 
+		autoGC(ivaldiDir)
 		return nil
 	},
 }
 
+// sealTimes returns the author and commit times of a seal from --author-date
+// and --date, defaulting to now
+func sealTimes(now time.Time) (authorTime, commitTime time.Time, err error) {
+	commitTime = now
+	if sealDate != "" {
+		if commitTime, err = commit.ParseDate(sealDate, now); err != nil {
+			return authorTime, commitTime, fmt.Errorf("invalid --date: %w", err)
+		}
+	}
+	authorTime = commitTime
+	if sealAuthorDate != "" {
+		if authorTime, err = commit.ParseDate(sealAuthorDate, now); err != nil {
+			return authorTime, commitTime, fmt.Errorf("invalid --author-date: %w", err)
+		}
+	}
+	return authorTime, commitTime, nil
+}
+
+// gatherIntent records files as intent-to-add. Files that are already
+// tracked or staged are left alone, as with 'git add -N'.
+func gatherIntent(ivaldiDir string, files []string) error {
+	knownFiles, err := getKnownFiles(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to read tracked files: %w", err)
+	}
+	stagedFiles, err := getStagedFilesList(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to get staged files: %w", err)
+	}
+	intent, err := getIntentToAddFiles(ivaldiDir)
+	if err != nil {
+		return err
+	}
+
+	skip := make(map[string]bool)
+	for _, file := range stagedFiles {
+		skip[file] = true
+	}
+	for _, file := range intent {
+		skip[file] = true
+	}
+
+	added := 0
+	for _, file := range files {
+		if _, tracked := knownFiles[file]; tracked || skip[file] {
+			continue
+		}
+		intent = append(intent, file)
+		skip[file] = true
+		fmt.Printf("Intent to add: %s\n", file)
+		added++
+	}
+
+	if added == 0 {
+		fmt.Println("No new files to mark; they are already tracked, staged or marked.")
+		return nil
+	}
+
+	if err := writeIntentToAddFiles(ivaldiDir, intent); err != nil {
+		return err
+	}
+
+	fmt.Printf("Marked %d files as intent-to-add. 'ivaldi diff' now shows their content; gather them to include them in a seal.\n", added)
+	return nil
+}
+
 func init() {
+	gatherCmd.Flags().BoolVar(&gatherAllowAll, "allow-all", false, "Gather hidden files without prompting")
+	gatherCmd.Flags().BoolVarP(&gatherIntentToAdd, "intent-to-add", "N", false, "Record new files as tracked with empty content without staging them")
+	gatherCmd.Flags().BoolVarP(&gatherAll, "all", "a", false, "Gather all files and stage the removal of deleted tracked files")
+	gatherCmd.Flags().BoolVar(&gatherRemoved, "removed", false, "Only stage the removal of deleted tracked files")
+	gatherCmd.Flags().CountVarP(&gatherQuiet, "quiet", "q", "Print only the summary; repeat (-qq) to silence warnings too")
 	statusCmd.Flags().BoolVar(&statusVerbose, "verbose", false, "Show more detailed status information")
 	downloadCmd.Flags().BoolVar(&recurseSubmodules, "recurse-submodules", true, "Automatically clone and convert Git submodules (default: true)")
+	downloadCmd.Flags().BoolVar(&downloadBare, "bare", false, "Import objects and timelines without materializing a working tree")
+	uploadCmd.Flags().BoolVar(&uploadVerify, "verify", false, "Compare the uploaded tree with the local seal before updating the branch")
+	uploadCmd.Flags().BoolVar(&uploadTags, "tags", false, "Upload all local tags instead of the current timeline")
+	uploadCmd.Flags().BoolVarP(&uploadSetUpstream, "set-upstream", "u", false, "Record the repository and branch uploaded to as the timeline's upstream")
+	sealCmd.Flags().BoolVar(&sealNoVerify, "no-verify", false, "Bypass the pre-seal hook")
+	sealCmd.Flags().BoolVar(&sealAllowSecrets, "allow-secrets", false, "Seal even if staged files match secret patterns (security.secretpatterns)")
+	sealCmd.Flags().BoolVarP(&sealSignoff, "signoff", "s", false, "Add a Signed-off-by trailer for the configured identity")
+	sealCmd.Flags().BoolVarP(&sealSign, "sign", "S", false, "Sign the seal with user.signingkey")
+	sealCmd.Flags().BoolVar(&sealNoSign, "no-sign", false, "Do not sign the seal, even if commit.gpgsign or commit.sshsign is set")
+	sealCmd.Flags().StringArrayVar(&sealTrailers, "trailer", nil, "Add a \"Key: Value\" trailer to the message (repeatable)")
+	sealCmd.Flags().StringVar(&sealDate, "date", "", "Set the seal time (RFC3339, YYYY-MM-DD, or e.g. \"2 days ago\")")
+	sealCmd.Flags().StringVar(&sealAuthorDate, "author-date", "", "Set the time the change was authored (defaults to --date)")
 }
 
-// isAutoExcluded checks if a file matches auto-exclude patterns (.env, .venv, etc.)
-func isAutoExcluded(path string) bool {
-	baseName := filepath.Base(path)
-
-	for _, pattern := range autoExcludePatterns {
-		// Handle directory patterns
-		if strings.HasSuffix(pattern, "/") {
-			dirPattern := strings.TrimSuffix(pattern, "/")
-			if strings.HasPrefix(path, dirPattern+"/") || baseName == dirPattern {
-				return true
-			}
+// refuseSecrets fails if any staged file matches the secret patterns,
+// unless allow is set, listing the files that do
+func refuseSecrets(stagedFiles []string, allow bool) error {
+	if allow {
+		return nil
+	}
+	patterns := getAutoExcludePatterns()
+	var secretFiles []string
+	for _, file := range stagedFiles {
+		if isAutoExcluded(file, patterns) {
+			secretFiles = append(secretFiles, file)
 		}
+	}
+	if len(secretFiles) == 0 {
+		return nil
+	}
+	fmt.Printf("%s The following staged files match secret patterns:\n", colors.Yellow("Warning:"))
+	for _, file := range secretFiles {
+		fmt.Printf("  %s\n", colors.Bold(file))
+	}
+	fmt.Println(colors.Dim("Use 'ivaldi reset <file>' to unstage them, or pass --allow-secrets to seal anyway."))
+	return fmt.Errorf("refusing to seal %d file(s) matching secret patterns", len(secretFiles))
+}
 
-		// Try matching the basename
-		if matched, _ := filepath.Match(pattern, baseName); matched {
+// isAutoExcluded checks if a file matches one of the auto-exclude patterns
+// (.env, .venv, etc.)
+func isAutoExcluded(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if autoExcludeMatches(path, pattern) {
 			return true
 		}
+	}
+	return false
+}
 
-		// Try matching the full path
-		if matched, _ := filepath.Match(pattern, path); matched {
+// autoExcludeMatches checks if a file matches a single auto-exclude pattern
+func autoExcludeMatches(path, pattern string) bool {
+	baseName := filepath.Base(path)
+
+	// Handle directory patterns
+	if strings.HasSuffix(pattern, "/") {
+		dirPattern := strings.TrimSuffix(pattern, "/")
+		if strings.HasPrefix(path, dirPattern+"/") || baseName == dirPattern {
 			return true
 		}
 	}
 
+	// Try matching the basename
+	if matched, _ := filepath.Match(pattern, baseName); matched {
+		return true
+	}
+
+	// Try matching the full path
+	if matched, _ := filepath.Match(pattern, path); matched {
+		return true
+	}
+
 	return false
 }
 
@@ -835,90 +1566,6 @@ func shouldGatherDotFile(path string) bool {
 	return false
 }
 
-// loadIgnorePatternsForGather loads patterns from .ivaldiignore file
-func loadIgnorePatternsForGather(workDir string) ([]string, error) {
-	ignoreFile := filepath.Join(workDir, ".ivaldiignore")
-	if _, err := os.Stat(ignoreFile); os.IsNotExist(err) {
-		return []string{}, nil // No ignore file
-	}
-
-	file, err := os.Open(ignoreFile)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var patterns []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		// Skip empty lines and comments
-		if line != "" && !strings.HasPrefix(line, "#") {
-			patterns = append(patterns, line)
-		}
-	}
-
-	return patterns, scanner.Err()
-}
-
-// isFileIgnored checks if a file path matches any ignore patterns
-// IMPORTANT: .ivaldiignore itself is NEVER ignored
-func isFileIgnored(path string, patterns []string) bool {
-	// Never ignore .ivaldiignore itself
-	if path == ".ivaldiignore" || filepath.Base(path) == ".ivaldiignore" {
-		return false
-	}
-
-	for _, pattern := range patterns {
-		// Handle directory patterns (patterns ending with /)
-		if strings.HasSuffix(pattern, "/") {
-			dirPattern := strings.TrimSuffix(pattern, "/")
-			// Check if the path is within this directory
-			if strings.HasPrefix(path, dirPattern+"/") || path == dirPattern {
-				return true
-			}
-		}
-
-		// Try matching the full path
-		if matched, _ := filepath.Match(pattern, path); matched {
-			return true
-		}
-
-		// Try matching just the basename
-		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-			return true
-		}
-
-		// Handle patterns with directory separators
-		if strings.Contains(pattern, "/") {
-			if matched, _ := filepath.Match(pattern, path); matched {
-				return true
-			}
-		}
-
-		// Handle wildcards in directory paths (e.g., **/*.log)
-		if strings.Contains(pattern, "**") {
-			// Convert ** pattern to a simpler check
-			parts := strings.Split(pattern, "**")
-			if len(parts) == 2 {
-				prefix := strings.TrimPrefix(parts[0], "/")
-				suffix := strings.TrimPrefix(parts[1], "/")
-
-				if prefix != "" && !strings.HasPrefix(path, prefix) {
-					continue
-				}
-
-				if suffix != "" {
-					if matched, _ := filepath.Match(suffix, filepath.Base(path)); matched {
-						return true
-					}
-				}
-			}
-		}
-	}
-	return false
-}
-
 var excludeCommand = &cobra.Command{
 	Use:   "exclude",
 	Args:  cobra.MinimumNArgs(1),
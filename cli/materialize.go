@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	materializeTo    string
+	materializeForce bool
+)
+
+var materializeCmd = &cobra.Command{
+	Use:   "materialize [seal|timeline] --to <dir>",
+	Short: "Write a seal's files to a directory without switching to it",
+	Long: `Write the full tree of a seal to a directory of your choice.
+
+The current timeline, HEAD and the working directory are left untouched, so
+this is a way to look at, build or package an old seal while keeping your
+work in progress. The seal can be given as a seal name, hash prefix,
+timeline name or HEAD, optionally followed by ~N to step back N seals. It
+defaults to HEAD.
+
+Missing directories are created. A directory that is not empty is refused
+unless --force is given, in which case files of the seal overwrite existing
+files of the same name and other files are left alone.
+
+Examples:
+  ivaldi materialize --to /tmp/build             # Write HEAD to /tmp/build
+  ivaldi materialize v1-release --to ../v1       # Write a seal
+  ivaldi materialize feature~2 --to out --force  # Reuse a non-empty directory`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runMaterialize,
+}
+
+func init() {
+	materializeCmd.Flags().StringVar(&materializeTo, "to", "", "Directory to write the seal's files to (required)")
+	materializeCmd.Flags().BoolVar(&materializeForce, "force", false, "Write into a directory that is not empty")
+	materializeCmd.MarkFlagRequired("to")
+}
+
+func runMaterialize(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	ref := "HEAD"
+	if len(args) == 1 {
+		ref = args[0]
+	}
+
+	targetDir, err := filepath.Abs(argPath(materializeTo))
+	if err != nil {
+		return fmt.Errorf("failed to resolve target directory: %w", err)
+	}
+	if err := checkMaterializeTarget(ivaldiDir, targetDir); err != nil {
+		return err
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	commitHash, err := resolveCommitRef(casStore, refsManager, ref)
+	if err != nil {
+		return err
+	}
+
+	commitReader := commit.NewCommitReader(casStore)
+	commitObj, err := commitReader.ReadCommit(commitHash)
+	if err != nil {
+		return fmt.Errorf("failed to read commit: %w", err)
+	}
+	tree, err := commitReader.ReadTree(commitObj)
+	if err != nil {
+		return fmt.Errorf("failed to read tree: %w", err)
+	}
+	files, err := commitReader.ListFiles(tree)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	for _, filePath := range files {
+		if !filepath.IsLocal(filepath.FromSlash(filePath)) {
+			return fmt.Errorf("refusing to write %s outside the target directory", filePath)
+		}
+		content, err := commitReader.GetFileContent(tree, filePath)
+		if err != nil {
+			return fmt.Errorf("failed to get content for %s: %w", filePath, err)
+		}
+
+		fullPath := filepath.Join(targetDir, filepath.FromSlash(filePath))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", filePath, err)
+		}
+		// Trees record every file with the default mode, as the workspace does
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filePath, err)
+		}
+	}
+
+	sealName, err := refsManager.GetSealNameByHash(commitHash)
+	if err != nil || sealName == "" {
+		sealName = hex.EncodeToString(commitHash[:4])
+	}
+	fmt.Printf("%s Materialized %s (%d files) into %s\n", colors.SuccessText("[OK]"),
+		colors.Cyan(sealName), len(files), colors.Bold(targetDir))
+	return nil
+}
+
+// checkMaterializeTarget refuses target directories that would touch the
+// repository itself, and non-empty ones unless --force is given
+func checkMaterializeTarget(ivaldiDir, targetDir string) error {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if targetDir == workDir || strings.HasPrefix(workDir, targetDir+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to materialize over the working directory; use 'ivaldi travel' or 'ivaldi timeline switch' instead")
+	}
+	absIvaldiDir, err := filepath.Abs(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", ivaldiDir, err)
+	}
+	if targetDir == absIvaldiDir || strings.HasPrefix(targetDir, absIvaldiDir+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to materialize into the .ivaldi directory")
+	}
+
+	entries, err := os.ReadDir(targetDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read target directory: %w", err)
+	}
+	if len(entries) > 0 && !materializeForce {
+		return fmt.Errorf("target directory %s is not empty (use --force to write into it anyway)", targetDir)
+	}
+	return nil
+}
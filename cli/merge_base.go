@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var mergeBaseCmd = &cobra.Command{
+	Use:   "merge-base <a> <b>",
+	Short: "Show the common ancestor of two seals",
+	Long: `Print the seal name and hash of the best common ancestor of two seals or
+timelines: the base a fuse between them merges against.
+
+Each argument may be a timeline, a seal name, a hash prefix or any of these
+followed by ~N. The ancestor is found with the MMR skip table when both
+sides reach it along first parents without passing a merge, and by walking
+the full seal graph otherwise. Seals without a name print only the hash.
+Seals that share no history are reported as an error.
+
+Examples:
+  ivaldi merge-base main feature      # Where feature branched off main
+  ivaldi merge-base HEAD~3 feature~1`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMergeBase,
+}
+
+func runMergeBase(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	a, err := resolveCommitRef(casStore, refsManager, args[0])
+	if err != nil {
+		return err
+	}
+	b, err := resolveCommitRef(casStore, refsManager, args[1])
+	if err != nil {
+		return err
+	}
+
+	base, err := findMergeBase(ivaldiDir, casStore, a, b)
+	if errors.Is(err, commit.ErrNoMergeBase) {
+		return fmt.Errorf("%s and %s have no common ancestor", args[0], args[1])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find merge base: %w", err)
+	}
+
+	var hashArray [32]byte
+	copy(hashArray[:], base[:])
+	if sealName, err := refsManager.GetSealNameByHash(hashArray); err == nil && sealName != "" {
+		fmt.Printf("%s %s\n", sealName, base)
+	} else {
+		fmt.Println(base)
+	}
+	return nil
+}
+
+// findMergeBase returns the best common ancestor of two commits, using the
+// repository's MMR when it can be opened
+func findMergeBase(ivaldiDir string, casStore cas.CAS, a, b cas.Hash) (cas.Hash, error) {
+	var acc history.Accumulator
+	if mmr, err := history.NewPersistentMMR(casStore, ivaldiDir); err == nil {
+		defer mmr.Close()
+		acc = mmr.MMR
+	}
+	return commit.NewCommitReader(casStore).MergeBase(acc, a, b)
+}
@@ -0,0 +1,947 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/seals"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+	"github.com/spf13/cobra"
+)
+
+var exportPatchCmd = &cobra.Command{
+	Use:   "export-patch [<base>..<tip> | <base>]",
+	Short: "Export seals as a series of patch files",
+	Long: `Export a range of seals as a portable patch series, one file per seal.
+
+Each patch carries the author, date and message of the seal followed by a
+unified diff of every file it changed, so it can be emailed and applied
+elsewhere with 'ivaldi import-patch'.
+
+A range <base>..<tip> exports the seals after <base> up to and including
+<tip>. A single <base> exports everything after it up to HEAD. References
+can be seal names, hash prefixes, timeline names or HEAD, optionally
+followed by ~N to step back N seals.
+
+Binary files are noted but left out unless --binary is given, which writes
+them as Git binary patches that both import-patch and 'git apply' accept.
+
+Examples:
+  ivaldi export-patch                     # Export the last seal
+  ivaldi export-patch -n 3                # Export the last 3 seals
+  ivaldi export-patch main                # Export seals made since main
+  ivaldi export-patch HEAD~5..HEAD~2 -o out  # Export a range into out/
+  ivaldi export-patch --binary -n 2       # Include binary file changes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runExportPatch,
+}
+
+var importPatchCmd = &cobra.Command{
+	Use:   "import-patch <patch-file|directory>...",
+	Short: "Apply a patch series and seal each patch",
+	Long: `Apply patches created by 'ivaldi export-patch' onto the current timeline.
+
+Each patch becomes a new seal that keeps the original author and message.
+Patches are applied in order; directories are expanded to the *.patch files
+they contain, sorted by name.
+
+If a patch does not apply cleanly, nothing from that patch is sealed, the
+conflicting files and hunks are reported, rejected hunks are written next to
+the file as <file>.rej, and the remaining patches are skipped. Patches applied
+before the failure stay sealed.
+
+Examples:
+  ivaldi import-patch 0001-fix-typo.patch
+  ivaldi import-patch patches/`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runImportPatch,
+}
+
+var (
+	exportPatchCount  int
+	exportPatchOutput string
+	exportPatchBinary bool
+)
+
+func init() {
+	exportPatchCmd.Flags().IntVarP(&exportPatchCount, "number", "n", 0, "Export the last N seals")
+	exportPatchCmd.Flags().StringVarP(&exportPatchOutput, "output", "o", ".", "Directory to write patch files to")
+	exportPatchCmd.Flags().BoolVar(&exportPatchBinary, "binary", false, "Include binary file changes as applyable binary patches")
+}
+
+// patchFile is a single parsed patch from a series
+type patchFile struct {
+	Name    string
+	Author  string
+	Subject string
+	Message string
+	Files   []patchFileDiff
+}
+
+// patchFileDiff is the diff of one file inside a patch
+type patchFileDiff struct {
+	Path      string
+	IsNew     bool
+	IsDeleted bool
+	IsBinary  bool
+	Hunks     []diffmerge.Hunk
+	Binary    *diffmerge.BinaryPatch // Set for binary changes exported with --binary
+	OldID     string                 // Git blob IDs from the index line
+	NewID     string
+}
+
+func runExportPatch(cmd *cobra.Command, args []string) error {
+	// Check if we're in an Ivaldi repository
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	baseRef, tipRef := "", "HEAD"
+	if len(args) == 1 {
+		if exportPatchCount > 0 {
+			return fmt.Errorf("-n cannot be combined with a range")
+		}
+		if before, after, isRange := strings.Cut(args[0], ".."); isRange {
+			baseRef = before
+			if after != "" {
+				tipRef = after
+			}
+		} else {
+			baseRef = args[0]
+		}
+	}
+
+	limit := exportPatchCount
+	if len(args) == 0 && limit == 0 {
+		limit = 1
+	}
+
+	tipHash, err := resolveCommitRef(casStore, refsManager, tipRef)
+	if err != nil {
+		return err
+	}
+
+	var baseHash cas.Hash
+	if baseRef != "" {
+		baseHash, err = resolveCommitRef(casStore, refsManager, baseRef)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Walk first parents back from the tip until the base is reached
+	commitReader := commit.NewCommitReader(casStore)
+	var series []cas.Hash
+	var commits []*commit.CommitObject
+	currentHash := tipHash
+	foundBase := false
+	guard := commitReader.NewWalkGuard()
+	for currentHash != (cas.Hash{}) {
+		if baseRef != "" && currentHash == baseHash {
+			foundBase = true
+			break
+		}
+		if limit > 0 && len(series) == limit {
+			break
+		}
+		first, err := guard.Visit(currentHash, len(series))
+		if err != nil {
+			return err
+		}
+		if !first {
+			break
+		}
+
+		commitObj, err := commitReader.ReadCommit(currentHash)
+		if err != nil {
+			return fmt.Errorf("failed to read commit %s: %w", hex.EncodeToString(currentHash[:4]), err)
+		}
+		series = append(series, currentHash)
+		commits = append(commits, commitObj)
+
+		if len(commitObj.Parents) == 0 {
+			break
+		}
+		currentHash = commitObj.Parents[0]
+	}
+
+	if baseRef != "" && !foundBase {
+		return fmt.Errorf("'%s' is not an ancestor of '%s'", baseRef, tipRef)
+	}
+
+	if len(series) == 0 {
+		fmt.Println("No seals to export")
+		return nil
+	}
+
+	outputDir := argPath(exportPatchOutput)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	total := len(series)
+	for i := 0; i < total; i++ {
+		// Oldest seal first
+		hash := series[total-1-i]
+		commitObj := commits[total-1-i]
+
+		var hashArray [32]byte
+		copy(hashArray[:], hash[:])
+		sealName, _ := refsManager.GetSealNameByHash(hashArray)
+
+		var buf bytes.Buffer
+		if err := writePatch(&buf, casStore, hash, commitObj, sealName, i+1, total); err != nil {
+			return err
+		}
+
+		subject, _, _ := strings.Cut(commitObj.Message, "\n")
+		patchPath := filepath.Join(outputDir, fmt.Sprintf("%04d-%s.patch", i+1, patchSlug(subject)))
+		if err := os.WriteFile(patchPath, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", patchPath, err)
+		}
+
+		fmt.Println(patchPath)
+	}
+
+	return nil
+}
+
+// writePatch writes one seal as a patch: a mail-style header with the seal
+// metadata followed by the unified diff against its first parent.
+func writePatch(w io.Writer, casStore cas.CAS, hash cas.Hash, commitObj *commit.CommitObject, sealName string, index, total int) error {
+	subject, body, _ := strings.Cut(commitObj.Message, "\n")
+	body = strings.TrimSpace(body)
+
+	prefix := "[PATCH]"
+	if total > 1 {
+		prefix = fmt.Sprintf("[PATCH %d/%d]", index, total)
+	}
+
+	fmt.Fprintf(w, "From %s Mon Sep 17 00:00:00 2001\n", hex.EncodeToString(hash[:]))
+	fmt.Fprintf(w, "From: %s\n", commitObj.Author)
+	fmt.Fprintf(w, "Date: %s\n", commitObj.AuthorTime.Format(time.RFC1123Z))
+	fmt.Fprintf(w, "Subject: %s %s\n", prefix, subject)
+	if sealName != "" {
+		fmt.Fprintf(w, "X-Ivaldi-Seal: %s\n", sealName)
+	}
+	fmt.Fprintln(w)
+	if body != "" {
+		fmt.Fprintf(w, "%s\n\n", body)
+	}
+	fmt.Fprintln(w, "---")
+
+	var parentHash cas.Hash
+	if len(commitObj.Parents) > 0 {
+		parentHash = commitObj.Parents[0]
+	}
+
+	oldFiles, err := getCommitFileRefs(casStore, parentHash)
+	if err != nil {
+		return err
+	}
+	newFiles, err := getCommitFileRefs(casStore, hash)
+	if err != nil {
+		return err
+	}
+
+	pathSet := make(map[string]bool)
+	for path := range oldFiles {
+		pathSet[path] = true
+	}
+	for path := range newFiles {
+		pathSet[path] = true
+	}
+	paths := make([]string, 0, len(pathSet))
+	for path := range pathSet {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	loader := filechunk.NewLoader(casStore)
+	for _, path := range paths {
+		oldRef, inOld := oldFiles[path]
+		newRef, inNew := newFiles[path]
+		if inOld && inNew && oldRef.Hash == newRef.Hash {
+			continue
+		}
+
+		if (inOld && isBigFile(oldRef.Size)) || (inNew && isBigFile(newRef.Size)) {
+			writeBigFileDiff(w, path, inOld, inNew)
+			continue
+		}
+
+		var oldContent, newContent []byte
+		if inOld {
+			if oldContent, err = loader.ReadAll(oldRef); err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+		}
+		if inNew {
+			if newContent, err = loader.ReadAll(newRef); err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+		}
+
+		if err := writeFileDiff(w, path, oldContent, newContent, inOld, inNew, exportPatchBinary, diffmerge.WhitespaceOptions{}, diffmerge.HunkOptions{Context: diffmerge.DefaultContext}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "-- ")
+	fmt.Fprintf(w, "Ivaldi %s\n\n", IvaldiVersion)
+	return nil
+}
+
+// writeFileDiff writes the git-style diff of a single file. With binary set,
+// changes to binary files are written as an applyable binary patch instead
+// of a "Binary files differ" note. Lines are compared under ws.
+func writeFileDiff(w io.Writer, path string, oldContent, newContent []byte, inOld, inNew, binary bool, ws diffmerge.WhitespaceOptions, hunkOpts diffmerge.HunkOptions) error {
+	oldName, newName := writeDiffHeader(w, path, inOld, inNew)
+
+	if showsAsBinary(oldContent, newContent) {
+		if !binary {
+			fmt.Fprintf(w, "Binary files %s and %s differ\n", oldName, newName)
+			return nil
+		}
+		fmt.Fprintf(w, "index %s..%s\n", diffmerge.GitBlobID(oldContent, inOld), diffmerge.GitBlobID(newContent, inNew))
+		return diffmerge.WriteBinaryPatch(w, oldContent, newContent)
+	}
+
+	ops := ws.DiffLines(diffmerge.SplitLines(oldContent), diffmerge.SplitLines(newContent))
+	hunks := ws.MakeHunksWith(ops, hunkOpts)
+	if len(hunks) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(w, "--- %s\n", oldName)
+	fmt.Fprintf(w, "+++ %s\n", newName)
+	return diffmerge.WriteUnifiedHunks(w, hunks)
+}
+
+// writeBigFileDiff writes the diff of a file above core.bigFileThreshold,
+// which is noted like a binary file without reading either side
+func writeBigFileDiff(w io.Writer, path string, inOld, inNew bool) {
+	oldName, newName := writeDiffHeader(w, path, inOld, inNew)
+	fmt.Fprintf(w, "Binary files %s and %s differ\n", oldName, newName)
+}
+
+// writeDiffHeader writes the lines that open the diff of a file and returns
+// the names of its sides
+func writeDiffHeader(w io.Writer, path string, inOld, inNew bool) (oldName, newName string) {
+	oldName, newName = "a/"+path, "b/"+path
+	fmt.Fprintf(w, "diff --git %s %s\n", oldName, newName)
+	if !inOld {
+		fmt.Fprintln(w, "new file mode 100644")
+		oldName = "/dev/null"
+	}
+	if !inNew {
+		fmt.Fprintln(w, "deleted file mode 100644")
+		newName = "/dev/null"
+	}
+	return oldName, newName
+}
+
+// patchSlug turns a subject line into a file name fragment
+func patchSlug(subject string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(subject) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 52 {
+			break
+		}
+	}
+
+	slug := strings.Trim(b.String(), "-")
+	if slug == "" {
+		return "patch"
+	}
+	return slug
+}
+
+func runImportPatch(cmd *cobra.Command, args []string) error {
+	// Check if we're in an Ivaldi repository
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	if err := requireWorkTree("import-patch"); err != nil {
+		return err
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	for i, arg := range args {
+		args[i] = argPath(arg)
+	}
+	patchPaths, err := expandPatchArgs(args)
+	if err != nil {
+		return err
+	}
+
+	// Parse everything up front so a malformed file fails before anything is sealed
+	var patches []*patchFile
+	for _, path := range patchPaths {
+		patch, err := parsePatchFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		patches = append(patches, patch)
+	}
+
+	committer, err := getAuthorFromConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get author from config: %w\nPlease set user.name and user.email: ivaldi config user.name \"Your Name\"", err)
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	currentTimeline, err := refsManager.GetCurrentTimeline()
+	if err != nil {
+		return fmt.Errorf("failed to get current timeline: %w", err)
+	}
+
+	mmr, err := history.NewPersistentMMR(casStore, ivaldiDir)
+	if err != nil {
+		// Fall back to in-memory MMR if persistent fails
+		mmr = &history.PersistentMMR{MMR: history.NewMMR()}
+	}
+	defer mmr.Close()
+
+	commitBuilder := commit.NewCommitBuilder(casStore, mmr.MMR)
+
+	for i, patch := range patches {
+		fmt.Printf("Applying: %s\n", patch.Subject)
+
+		sealName, err := applyPatch(casStore, refsManager, commitBuilder, currentTimeline, workDir, patch, committer)
+		if err != nil {
+			if i > 0 {
+				fmt.Printf("%d patch(es) applied before the failure remain sealed\n", i)
+			}
+			return err
+		}
+
+		fmt.Printf("  Created seal: %s\n", colors.Cyan(sealName))
+	}
+
+	fmt.Printf("%s %d patch(es) onto timeline '%s'\n", colors.SuccessText("Applied"), len(patches), colors.Bold(currentTimeline))
+	return nil
+}
+
+// expandPatchArgs expands directory arguments to the patch files they contain
+func expandPatchArgs(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", arg, err)
+		}
+
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(arg, "*.patch"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no *.patch files found in %s", arg)
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// parsePatchFile parses a patch written by export-patch
+func parsePatchFile(path string) (*patchFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patch := &patchFile{Name: filepath.Base(path)}
+	r := bufio.NewReader(f)
+
+	readLine := func() (string, bool) {
+		line, err := r.ReadString('\n')
+		if line == "" && err != nil {
+			return "", false
+		}
+		return strings.TrimRight(line, "\r\n"), true
+	}
+
+	// Mail-style header
+	line, ok := readLine()
+	if !ok || !strings.HasPrefix(line, "From ") {
+		return nil, fmt.Errorf("not a patch file (missing 'From' line)")
+	}
+	lastHeader := ""
+	for {
+		line, ok = readLine()
+		if !ok {
+			return nil, fmt.Errorf("unexpected end of patch header")
+		}
+		if line == "" {
+			break
+		}
+
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			// Folded header continuation
+			if lastHeader == "Subject" {
+				patch.Subject += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		lastHeader = name
+		switch name {
+		case "From":
+			patch.Author = value
+		case "Subject":
+			patch.Subject = value
+		}
+	}
+
+	if patch.Author == "" {
+		return nil, fmt.Errorf("patch has no author")
+	}
+
+	// Strip the [PATCH n/m] prefix from the subject
+	if strings.HasPrefix(patch.Subject, "[") {
+		if end := strings.Index(patch.Subject, "]"); end != -1 {
+			patch.Subject = strings.TrimSpace(patch.Subject[end+1:])
+		}
+	}
+
+	// Message body up to the --- separator
+	var body []string
+	for {
+		line, ok = readLine()
+		if !ok {
+			return nil, fmt.Errorf("missing '---' separator")
+		}
+		if line == "---" {
+			break
+		}
+		body = append(body, line)
+	}
+
+	patch.Message = patch.Subject
+	if text := strings.TrimSpace(strings.Join(body, "\n")); text != "" {
+		patch.Message += "\n\n" + text
+	}
+
+	files, err := parseFileDiffs(r)
+	if err != nil {
+		return nil, err
+	}
+	patch.Files = files
+	return patch, nil
+}
+
+// parseFileDiffs parses the file diffs of a patch, up to its end or a mail
+// signature. A file diff starts with a diff --git line, or with the bare
+// ---/+++ lines diff -u writes; their paths lose their first component,
+// such as a/ and b/, and /dev/null marks a created or deleted file. Other
+// text between file diffs is skipped.
+func parseFileDiffs(r *bufio.Reader) ([]patchFileDiff, error) {
+	var files []patchFileDiff
+	var current *patchFileDiff
+	oldName := ""
+	pending := ""
+	for {
+		var line string
+		if pending != "" {
+			line, pending = strings.TrimRight(pending, "\r\n"), ""
+		} else {
+			read, err := r.ReadString('\n')
+			if read == "" && err != nil {
+				break
+			}
+			line = strings.TrimRight(read, "\r\n")
+		}
+
+		switch {
+		case line == "-- ":
+			// Signature marks the end of the diff
+			return files, nil
+		case strings.HasPrefix(line, "diff --git "):
+			path, err := parseDiffGitPath(strings.TrimPrefix(line, "diff --git "))
+			if err != nil {
+				return nil, err
+			}
+			if path, err = patchPath(path); err != nil {
+				return nil, err
+			}
+			files = append(files, patchFileDiff{Path: path})
+			current = &files[len(files)-1]
+		case strings.HasPrefix(line, "--- ") && (current == nil || len(current.Hunks) > 0):
+			// A file diff without a diff --git line
+			files = append(files, patchFileDiff{})
+			current = &files[len(files)-1]
+			oldName = strings.TrimPrefix(line, "--- ")
+		case current == nil:
+			// Diffstat or other text before the first diff
+		case strings.HasPrefix(line, "--- "):
+			oldName = strings.TrimPrefix(line, "--- ")
+		case strings.HasPrefix(line, "new file mode"):
+			current.IsNew = true
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.IsDeleted = true
+		case strings.HasPrefix(line, "Binary files "):
+			current.IsBinary = true
+		case strings.HasPrefix(line, "index "):
+			ids, _, _ := strings.Cut(strings.TrimPrefix(line, "index "), " ")
+			current.OldID, current.NewID, _ = strings.Cut(ids, "..")
+		case line == "GIT binary patch":
+			binaryPatch, err := diffmerge.ParseBinaryPatch(r)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", current.Path, err)
+			}
+			current.IsBinary = true
+			current.Binary = binaryPatch
+		case strings.HasPrefix(line, "+++ "):
+			if current.Path == "" {
+				oldPath, newPath := patchHeaderPath(oldName), patchHeaderPath(strings.TrimPrefix(line, "+++ "))
+				current.IsNew, current.IsDeleted = oldPath == "", newPath == ""
+				current.Path = newPath
+				if current.IsDeleted {
+					current.Path = oldPath
+				}
+				if current.Path == "" {
+					return nil, fmt.Errorf("invalid file header: %s", line)
+				}
+				var err error
+				if current.Path, err = patchPath(current.Path); err != nil {
+					return nil, err
+				}
+			}
+			hunks, rest, err := diffmerge.ParseUnifiedHunks(r)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", current.Path, err)
+			}
+			current.Hunks = hunks
+			pending = rest
+		}
+	}
+
+	return files, nil
+}
+
+// patchHeaderPath returns the path named on a ---/+++ line without its
+// first component and any timestamp, or "" for /dev/null
+func patchHeaderPath(name string) string {
+	name, _, _ = strings.Cut(name, "\t")
+	if name == "/dev/null" {
+		return ""
+	}
+	if _, rest, ok := strings.Cut(name, "/"); ok {
+		return rest
+	}
+	return name
+}
+
+// patchPath cleans a path named by a patch and checks that it stays inside
+// the working tree, outside the repository directory, so that a patch
+// cannot write anywhere else
+func patchPath(name string) (string, error) {
+	clean := path.Clean(name)
+	if !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return "", fmt.Errorf("patch names a path outside the working tree: %s", name)
+	}
+	if first, _, _ := strings.Cut(clean, "/"); first == ".ivaldi" {
+		return "", fmt.Errorf("patch names a path inside the repository directory: %s", name)
+	}
+	return clean, nil
+}
+
+// parseDiffGitPath extracts the path from "a/<path> b/<path>"
+func parseDiffGitPath(s string) (string, error) {
+	// Both sides name the same file, so the path is half of what remains
+	// after the prefixes; this keeps paths containing spaces intact.
+	if len(s) < 7 || (len(s)-5)%2 != 0 || !strings.HasPrefix(s, "a/") {
+		return "", fmt.Errorf("invalid diff header: diff --git %s", s)
+	}
+
+	n := (len(s) - 5) / 2
+	oldPath, newPath := s[2:2+n], s[2+n:]
+	if newPath != " b/"+oldPath {
+		return "", fmt.Errorf("renames are not supported: diff --git %s", s)
+	}
+	return oldPath, nil
+}
+
+// applyPatch applies a parsed patch on top of the current timeline head,
+// seals the result and updates the working directory. Conflicts are reported
+// per file and abort the patch without sealing anything.
+func applyPatch(casStore cas.CAS, refsManager *refs.RefsManager, commitBuilder *commit.CommitBuilder, timelineName, workDir string, patch *patchFile, committer string) (string, error) {
+	timeline, err := refsManager.GetTimeline(timelineName, refs.LocalTimeline)
+	if err != nil {
+		return "", fmt.Errorf("failed to get timeline: %w", err)
+	}
+
+	var headHash cas.Hash
+	copy(headHash[:], timeline.Blake3Hash[:])
+
+	headFiles, err := getCommitFileRefs(casStore, headHash)
+	if err != nil {
+		return "", err
+	}
+
+	loader := filechunk.NewLoader(casStore)
+	newContents := make(map[string][]byte)
+	removed := make(map[string]bool)
+	var conflicts []string
+	var rejectFiles []string
+
+	for _, fileDiff := range patch.Files {
+		path := fileDiff.Path
+		headRef, inHead := headFiles[path]
+
+		if fileDiff.IsBinary && fileDiff.Binary == nil {
+			conflicts = append(conflicts, fmt.Sprintf("%s: binary changes are not included (export the patch with --binary)", path))
+			continue
+		}
+		if fileDiff.IsNew && inHead {
+			conflicts = append(conflicts, fmt.Sprintf("%s: already exists", path))
+			continue
+		}
+		if !fileDiff.IsNew && !inHead {
+			conflicts = append(conflicts, fmt.Sprintf("%s: does not exist", path))
+			continue
+		}
+
+		var headContent []byte
+		if inHead {
+			if headContent, err = loader.ReadAll(headRef); err != nil {
+				return "", fmt.Errorf("failed to read %s: %w", path, err)
+			}
+		}
+
+		// Refuse to overwrite uncommitted work in the touched files
+		diskContent, err := os.ReadFile(filepath.Join(workDir, path))
+		switch {
+		case err == nil && (!inHead || !bytes.Equal(diskContent, headContent)):
+			conflicts = append(conflicts, fmt.Sprintf("%s: has local modifications", path))
+			continue
+		case err != nil && !os.IsNotExist(err):
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		case err != nil && inHead:
+			conflicts = append(conflicts, fmt.Sprintf("%s: deleted in working directory", path))
+			continue
+		}
+
+		if fileDiff.Binary != nil {
+			content, err := applyBinaryPatch(fileDiff, headContent, inHead)
+			if err != nil {
+				conflicts = append(conflicts, fmt.Sprintf("%s: %v", path, err))
+				continue
+			}
+			if fileDiff.IsDeleted {
+				removed[path] = true
+				continue
+			}
+			newContents[path] = content
+			continue
+		}
+
+		lines, failed := diffmerge.ApplyHunks(diffmerge.SplitLines(headContent), fileDiff.Hunks)
+		if len(failed) > 0 {
+			var rejected []diffmerge.Hunk
+			for _, idx := range failed {
+				hunk := fileDiff.Hunks[idx]
+				rejected = append(rejected, hunk)
+				conflicts = append(conflicts, fmt.Sprintf("%s: hunk #%d %s does not apply", path, idx+1, hunk.Header()))
+			}
+			rejPath := filepath.Join(workDir, path+".rej")
+			if err := writeRejects(rejPath, path, rejected); err != nil {
+				log.Printf("Warning: Failed to write rejected hunks for %s: %v", path, err)
+			} else {
+				rejectFiles = append(rejectFiles, path+".rej")
+			}
+			continue
+		}
+
+		content := []byte(strings.Join(lines, ""))
+		if fileDiff.IsDeleted {
+			if len(content) != 0 {
+				conflicts = append(conflicts, fmt.Sprintf("%s: content differs from the deleted file", path))
+				continue
+			}
+			removed[path] = true
+			continue
+		}
+		newContents[path] = content
+	}
+
+	if len(conflicts) > 0 {
+		fmt.Printf("%s %s does not apply:\n", colors.Red("Error:"), patch.Name)
+		for _, conflict := range conflicts {
+			fmt.Printf("  %s\n", conflict)
+		}
+		for _, rejFile := range rejectFiles {
+			fmt.Printf("Rejected hunks written to %s\n", rejFile)
+		}
+		return "", fmt.Errorf("failed to apply %s: %d conflict(s)", patch.Name, len(conflicts))
+	}
+
+	// Build the new file list from HEAD plus the patched files
+	chunkRules, err := filechunk.LoadProfileRules(workDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to load chunk profiles: %w", err)
+	}
+	var files []wsindex.FileMetadata
+	for path, ref := range headFiles {
+		if removed[path] {
+			continue
+		}
+		if _, changed := newContents[path]; changed {
+			continue
+		}
+		files = append(files, wsindex.FileMetadata{Path: path, FileRef: ref, Size: ref.Size})
+	}
+	for path, content := range newContents {
+		ref, err := filechunk.NewBuilder(casStore, chunkRules.Params(path)).Build(content)
+		if err != nil {
+			return "", fmt.Errorf("failed to store %s: %w", path, err)
+		}
+		files = append(files, wsindex.FileMetadata{
+			Path:     path,
+			FileRef:  ref,
+			ModTime:  time.Now(),
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Checksum: cas.SumB3(content),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	var parents []cas.Hash
+	if headHash != (cas.Hash{}) {
+		parents = append(parents, headHash)
+	}
+
+	commitObj, err := commitBuilder.CreateCommit(files, parents, patch.Author, committer, patch.Message)
+	if err != nil {
+		return "", fmt.Errorf("failed to create commit: %w", err)
+	}
+
+	commitHash := commitBuilder.GetCommitHash(commitObj)
+	var commitHashArray [32]byte
+	copy(commitHashArray[:], commitHash[:])
+
+	sealName := seals.GenerateSealName(commitHashArray)
+	if err := refsManager.StoreSealName(sealName, commitHashArray, patch.Message); err != nil {
+		log.Printf("Warning: Failed to store seal name: %v", err)
+	}
+
+	if err := refsManager.UpdateTimeline(timelineName, refs.LocalTimeline, commitHashArray, [32]byte{}, ""); err != nil {
+		return "", fmt.Errorf("failed to update timeline: %w", err)
+	}
+
+	// Bring the working directory in line with the new seal
+	for path, content := range newContents {
+		fullPath := filepath.Join(workDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	for path := range removed {
+		if err := os.Remove(filepath.Join(workDir, path)); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	return sealName, nil
+}
+
+// applyBinaryPatch applies the forward hunk of a binary patch, checking the
+// blob IDs from the index line when the patch carries them
+func applyBinaryPatch(fileDiff patchFileDiff, headContent []byte, inHead bool) ([]byte, error) {
+	if fileDiff.OldID != "" && diffmerge.GitBlobID(headContent, inHead) != fileDiff.OldID {
+		return nil, fmt.Errorf("binary content differs from the patch's original")
+	}
+
+	content, err := fileDiff.Binary.Forward.Apply(headContent)
+	if err != nil {
+		return nil, fmt.Errorf("binary patch does not apply: %w", err)
+	}
+
+	if fileDiff.NewID != "" && diffmerge.GitBlobID(content, !fileDiff.IsDeleted) != fileDiff.NewID {
+		return nil, fmt.Errorf("binary patch produced unexpected content")
+	}
+	if fileDiff.IsDeleted && len(content) != 0 {
+		return nil, fmt.Errorf("content differs from the deleted file")
+	}
+	return content, nil
+}
+
+// writeRejects writes hunks that failed to apply to a .rej file
+func writeRejects(rejPath, path string, hunks []diffmerge.Hunk) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- a/%s\n", path)
+	fmt.Fprintf(&buf, "+++ b/%s\n", path)
+	if err := diffmerge.WriteUnifiedHunks(&buf, hunks); err != nil {
+		return err
+	}
+
+	return os.WriteFile(rejPath, buf.Bytes(), 0644)
+}
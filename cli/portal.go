@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/spf13/cobra"
 )
@@ -25,7 +26,7 @@ Examples:
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -36,6 +37,16 @@ Examples:
 		// Remove github: prefix if present
 		repoArg, _ = strings.CutPrefix(repoArg, "github:")
 
+		// Accept repository URLs, including those of a GitHub Enterprise server
+		if gitHubURLHost(repoArg) != "" {
+			owner, repo, err := parseGitHubURL(repoArg)
+			if err != nil {
+				return err
+			}
+			rememberGitHubHost(repoArg)
+			repoArg = owner + "/" + repo
+		}
+
 		// Validate format
 		parts := strings.Split(repoArg, "/")
 		if len(parts) != 2 {
@@ -67,7 +78,7 @@ var portalListCmd = &cobra.Command{
 	Long:  `List the current GitHub repository connections for this Ivaldi repository.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -108,7 +119,7 @@ var portalRemoveCmd = &cobra.Command{
 	Long:  `Remove the current GitHub repository connection for this Ivaldi repository.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
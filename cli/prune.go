@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneDryRun bool
+	pruneExpire string
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old unreachable loose objects",
+	Long: `Remove loose objects that nothing references and that are older than the
+reflog expiry window, without packing anything. It is a quicker and safer
+cleanup than 'ivaldi gc', suitable for frequent automatic runs.
+
+An object is kept while it is reachable from a timeline, tag, stash, reflog
+entry, shelf, staged snapshot or the stat cache. Reflog entries are not
+expired first, so a seal stays recoverable for as long as its reflog entry
+lasts. Unreachable objects are only removed once they are older than
+reflog.expireUnreachable (30 days by default): no reflog entry can still
+lead to them by then. --expire sets another window; objects written in the
+last hour are always kept. Packed objects are left to 'ivaldi gc', and
+nothing is pruned while a fuse is in progress.
+
+Examples:
+  ivaldi prune                    # Remove old unreachable loose objects
+  ivaldi prune --dry-run          # Report what would be removed
+  ivaldi prune --expire "7 days"  # Use a shorter window`,
+	Args: cobra.NoArgs,
+	RunE: runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Report what would be removed without removing it")
+	pruneCmd.Flags().StringVar(&pruneExpire, "expire", "", "Only remove objects older than this, e.g. \"7 days\" (default: reflog.expireUnreachable)")
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	_, window := config.ReflogExpiry()
+	if pruneExpire != "" {
+		var err error
+		if window, err = config.ParseExpiry(pruneExpire); err != nil {
+			return err
+		}
+	}
+	if window == config.ExpireNever {
+		fmt.Println("Unreachable objects never expire; nothing to prune.")
+		return nil
+	}
+
+	if isMergeInProgress(ivaldiDir) {
+		fmt.Println("Fuse in progress; not pruning unreachable objects.")
+		return nil
+	}
+
+	lock, err := lockfile.Acquire(filepath.Join(ivaldiDir, "gc.lock"), lockfile.DefaultTimeout)
+	if errors.Is(err, lockfile.ErrLocked) {
+		return fmt.Errorf("a gc is running")
+	}
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	reachable, err := reachableObjects(ivaldiDir, casStore)
+	if err != nil {
+		return fmt.Errorf("failed to find reachable objects: %w", err)
+	}
+	prune := newObjectPruner(reachable, time.Now().Add(-max(window, gcPruneGrace)))
+
+	stats, err := casStore.PruneLoose(prune.keep, pruneDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to prune objects: %w", err)
+	}
+	if stats.Pruned == 0 {
+		fmt.Printf("Nothing to prune (%d loose objects are reachable or recent).\n", stats.Objects)
+		return nil
+	}
+
+	if pruneDryRun {
+		fmt.Printf("Would prune %d of %d loose objects (%s), reclaiming %s\n",
+			stats.Pruned, stats.Objects, prune.summary(), formatByteSize(stats.Size))
+		fmt.Println(colors.Dim("Dry run: nothing was changed"))
+		return nil
+	}
+	fmt.Printf("%s Pruned %d of %d loose objects (%s), reclaiming %s\n",
+		colors.SuccessText("[OK]"), stats.Pruned, stats.Objects, prune.summary(), formatByteSize(stats.Size))
+	return nil
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/github"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var pruneCacheClear bool
+
+var pruneCacheCmd = &cobra.Command{
+	Use:   "prune-cache",
+	Short: "Evict stale entries from the GitHub response cache",
+	Long: `Evict stale entries from the GitHub response cache in .ivaldi/github-cache.
+
+Commands that talk to GitHub keep API responses with their ETags so they can
+be revalidated without using up the rate limit. Entries not used for 30 days
+are evicted, then the least recently used ones until the cache is under
+64 MiB. With --clear, every entry is evicted.
+
+Entries for the connected repository's latest known remote heads are always
+kept, so the next fetch or sync can still revalidate them.
+
+Examples:
+  ivaldi prune-cache              # Apply the age and size limits
+  ivaldi prune-cache --clear      # Evict everything that can be evicted`,
+	Args: cobra.NoArgs,
+	RunE: runPruneCache,
+}
+
+func init() {
+	pruneCacheCmd.Flags().BoolVar(&pruneCacheClear, "clear", false, "Evict all entries except those for the latest remote heads")
+}
+
+func runPruneCache(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	keep, err := latestRemoteHeadsKeeper(ivaldiDir)
+	if err != nil {
+		return err
+	}
+
+	policy := github.DefaultPrunePolicy()
+	policy.Clear = pruneCacheClear
+
+	stats, err := github.NewResponseCache(github.CacheDir(ivaldiDir)).Prune(policy, keep)
+	if err != nil {
+		return fmt.Errorf("failed to prune cache: %w", err)
+	}
+
+	if stats.Entries == 0 {
+		fmt.Println("The GitHub response cache is empty.")
+		return nil
+	}
+
+	fmt.Printf("%s Removed %d of %d cache entries, reclaiming %s\n",
+		colors.SuccessText("[OK]"), stats.Removed, stats.Entries, formatByteSize(stats.BytesReclaimed))
+	if stats.Kept > 0 {
+		fmt.Printf("  Kept %d entries for the latest remote heads\n", stats.Kept)
+	}
+	fmt.Printf("  Cache size: %s\n", formatByteSize(stats.BytesRemaining))
+	return nil
+}
+
+// latestRemoteHeadsKeeper returns a function reporting whether a cached URL
+// belongs to the connected repository's remote timelines: their branch
+// endpoints, or anything naming the Git SHA of their heads. It returns nil
+// when no repository is connected.
+func latestRemoteHeadsKeeper(ivaldiDir string) (func(string) bool, error) {
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	owner, repo, err := refsManager.GetGitHubRepository()
+	if err != nil {
+		return nil, nil
+	}
+	remotes, err := refsManager.ListRemoteTimelines()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote timelines: %w", err)
+	}
+
+	repoPath := fmt.Sprintf("/repos/%s/%s", owner, repo)
+	return func(url string) bool {
+		path, _, _ := strings.Cut(url, "?")
+		idx := strings.Index(path, repoPath)
+		if idx < 0 {
+			return false
+		}
+		rest := path[idx+len(repoPath):]
+		if rest == "" {
+			return true
+		}
+		if !strings.HasPrefix(rest, "/") {
+			return false
+		}
+		for _, remote := range remotes {
+			if rest == "/branches/"+remote.Name {
+				return true
+			}
+			if remote.GitSHA1Hash != "" && strings.Contains(url, remote.GitSHA1Hash) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/hamtdir"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/shelf"
+	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// reachability collects the objects that live references need. Objects
+// missing from the store are skipped, so repositories with partial history
+// can still be collected; objects that fail to decode are errors, since
+// pruning without knowing what they reference could lose data.
+type reachability struct {
+	casStore cas.CAS
+	seen     map[cas.Hash]bool
+}
+
+// reachableObjects returns every object reachable from the timelines,
+// tags, stashes, reflog entries, shelves, staged snapshots and the stat
+// cache of a repository
+func reachableObjects(ivaldiDir string, casStore cas.CAS) (map[cas.Hash]bool, error) {
+	r := &reachability{casStore: casStore, seen: make(map[cas.Hash]bool)}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	for _, timelineType := range []refs.TimelineType{refs.LocalTimeline, refs.RemoteTimeline, refs.TagTimeline} {
+		timelines, err := refsManager.ListTimelines(timelineType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list timelines: %w", err)
+		}
+		for _, timeline := range timelines {
+			// Stashes are tags that point at a workspace index
+			if timelineType == refs.TagTimeline && strings.HasPrefix(timeline.Name, "stash/") {
+				err = r.index(timeline.Blake3Hash)
+			} else {
+				err = r.commitOrTag(timeline.Blake3Hash)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to walk %s: %w", timeline.Name, err)
+			}
+		}
+	}
+
+	reflogs, err := refsManager.ListReflogs()
+	if err != nil {
+		return nil, err
+	}
+	for _, reflog := range reflogs {
+		entries, err := refsManager.ReadReflog(reflog.Name, reflog.Type)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			for _, hash := range [][32]byte{entry.Old, entry.New} {
+				if err := r.commitOrTag(hash); err != nil {
+					return nil, fmt.Errorf("failed to walk reflog of %s: %w", reflog.Name, err)
+				}
+			}
+		}
+	}
+
+	shelves, err := shelf.NewShelfManager(casStore, ivaldiDir).ListShelves()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range shelves {
+		for _, index := range []wsindex.IndexRef{s.WorkspaceIndex, s.BaseIndex} {
+			if err := r.index(index.Hash); err != nil {
+				return nil, fmt.Errorf("failed to walk shelf %s: %w", s.ID, err)
+			}
+		}
+	}
+
+	snapshots, err := workspace.ReadStagedSnapshots(stagedSnapshotsFile(ivaldiDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged snapshots: %w", err)
+	}
+	for path, file := range snapshots {
+		if err := r.file(file.FileRef.Hash); err != nil {
+			return nil, fmt.Errorf("failed to walk the staged snapshot of %s: %w", path, err)
+		}
+	}
+
+	for _, fileRef := range workspace.LoadStatCache(ivaldiDir).FileRefs() {
+		if err := r.file(fileRef); err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", filepath.Join(ivaldiDir, "statcache"), err)
+		}
+	}
+
+	return r.seen, nil
+}
+
+// visit marks an object and returns its content, or nil when it was
+// already seen or is not stored
+func (r *reachability) visit(hash cas.Hash) ([]byte, error) {
+	if hash == (cas.Hash{}) || r.seen[hash] {
+		return nil, nil
+	}
+	if has, err := r.casStore.Has(hash); err != nil || !has {
+		return nil, err
+	}
+	r.seen[hash] = true
+	data, err := r.casStore.Get(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	return data, nil
+}
+
+// commitOrTag walks a tag object or a commit and all of its ancestors
+func (r *reachability) commitOrTag(hash cas.Hash) error {
+	pending := []cas.Hash{hash}
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		data, err := r.visit(hash)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		if tag, err := commit.DecodeTag(data); err == nil {
+			pending = append(pending, tag.Target)
+			continue
+		}
+		commitObj, err := commit.DecodeCommit(data)
+		if err != nil {
+			return fmt.Errorf("object %s is not a commit: %w", hash, err)
+		}
+		if err := r.tree(commitObj.TreeHash); err != nil {
+			return err
+		}
+		pending = append(pending, commitObj.Parents...)
+	}
+	return nil
+}
+
+// tree walks a directory node, its subdirectories and their files
+func (r *reachability) tree(hash cas.Hash) error {
+	data, err := r.visit(hash)
+	if err != nil || data == nil {
+		return err
+	}
+	node, err := hamtdir.DecodeNode(data)
+	if err != nil {
+		return fmt.Errorf("object %s is not a tree node: %w", hash, err)
+	}
+
+	for _, child := range node.Children {
+		if err := r.tree(child); err != nil {
+			return err
+		}
+	}
+	for _, entry := range node.Entries {
+		switch entry.Type {
+		case hamtdir.FileEntry:
+			err = r.file(entry.File.Hash)
+		case hamtdir.DirEntry:
+			err = r.tree(entry.Dir.Hash)
+		case hamtdir.SubmoduleEntry:
+			_, err = r.visit(entry.Submodule.NodeHash)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// index walks a workspace index node and the files it lists
+func (r *reachability) index(hash cas.Hash) error {
+	data, err := r.visit(hash)
+	if err != nil || data == nil {
+		return err
+	}
+	node, err := wsindex.DecodeNode(data)
+	if err != nil {
+		return fmt.Errorf("object %s is not an index node: %w", hash, err)
+	}
+
+	for _, child := range node.Children {
+		if err := r.index(child); err != nil {
+			return err
+		}
+	}
+	for _, file := range node.Entries {
+		if err := r.file(file.FileRef.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// file walks the chunk nodes of a file's content. Leaves reference
+// nothing, so only their marker is read: a big file is a single leaf that
+// is never loaded whole.
+func (r *reachability) file(hash cas.Hash) error {
+	if hash == (cas.Hash{}) || r.seen[hash] {
+		return nil
+	}
+	if has, err := r.casStore.Has(hash); err != nil || !has {
+		return err
+	}
+	r.seen[hash] = true
+
+	reader, err := cas.GetReader(r.casStore, hash)
+	if err != nil {
+		return fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	marker := make([]byte, 1)
+	_, err = io.ReadFull(reader, marker)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	if marker[0] == 0x00 {
+		return nil
+	}
+
+	data, err := r.casStore.Get(hash)
+	if err != nil {
+		return fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	node, err := filechunk.DecodeNode(data)
+	if err != nil {
+		return fmt.Errorf("object %s is not a file node: %w", hash, err)
+	}
+	for _, child := range node.Children {
+		if err := r.file(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
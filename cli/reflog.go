@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reflogExpireAll         bool
+	reflogExpireDryRun      bool
+	reflogExpireValue       string
+	reflogExpireUnreachable string
+)
+
+var reflogCmd = &cobra.Command{
+	Use:   "reflog [timeline]",
+	Short: "Show where a timeline has pointed",
+	Long: `List the seals a timeline has pointed at, newest first. Every seal, fuse,
+reset, travel overwrite or fetch that moves a timeline adds an entry, so a
+seal that no timeline reaches any more can still be found and recovered:
+
+  ivaldi reflog                   # Moves of the current timeline
+  ivaldi show main@{2}            # Where main pointed two moves ago
+  ivaldi timeline create rescue main@{2}
+
+Without an argument the current timeline is shown. Remote timelines have a
+reflog too. Entries expire after reflog.expire (default 90 days), or after
+reflog.expireUnreachable (default 30 days) once their seal is no longer
+reachable from the timeline; see 'ivaldi reflog expire'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReflogShow,
+}
+
+var reflogExpireCmd = &cobra.Command{
+	Use:   "expire [timeline...]",
+	Short: "Remove old reflog entries",
+	Long: `Remove reflog entries older than reflog.expire, and entries older than
+reflog.expireUnreachable whose seal is no longer reachable from the
+timeline's head. The newest entry of each timeline is always kept.
+
+Expiry values are a number of days or weeks ("90 days", "90.days", "12w"),
+"never" or "now". 'ivaldi gc' expires all reflogs the same way before
+packing.
+
+Examples:
+  ivaldi reflog expire --all                     # Apply the configured expiry
+  ivaldi reflog expire --expire-unreachable=now main
+  ivaldi reflog expire --all --expire=30.days --dry-run`,
+	RunE: runReflogExpire,
+}
+
+func init() {
+	reflogCmd.AddCommand(reflogExpireCmd)
+	reflogExpireCmd.Flags().BoolVar(&reflogExpireAll, "all", false, "Expire the reflogs of all timelines")
+	reflogExpireCmd.Flags().BoolVarP(&reflogExpireDryRun, "dry-run", "n", false, "Show how many entries would be removed without removing them")
+	reflogExpireCmd.Flags().StringVar(&reflogExpireValue, "expire", "", "Remove entries older than this (default: reflog.expire)")
+	reflogExpireCmd.Flags().StringVar(&reflogExpireUnreachable, "expire-unreachable", "", "Remove unreachable entries older than this (default: reflog.expireUnreachable)")
+}
+
+func runReflogShow(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	name, timelineType, err := reflogTimeline(refsManager, name)
+	if err != nil {
+		return err
+	}
+	entries, err := refsManager.ReadReflog(name, timelineType)
+	if err != nil {
+		return fmt.Errorf("failed to read reflog: %w", err)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No reflog entries for %s\n", name)
+		return nil
+	}
+
+	commitReader := commit.NewCommitReader(casStore)
+	for i, entry := range entries {
+		id := hex.EncodeToString(entry.New[:4])
+		if sealName, err := refsManager.GetSealNameByHash(entry.New); err == nil && sealName != "" {
+			id = sealName
+		}
+		message := entry.Message
+		if commitObj, err := commitReader.ReadCommit(cas.Hash(entry.New)); err == nil {
+			// Seals already record their subject in the entry
+			if subject, _, _ := strings.Cut(commitObj.Message, "\n"); !strings.Contains(message, subject) {
+				message += ": " + subject
+			}
+		}
+		fmt.Printf("%s %s %s %s\n",
+			colors.Cyan(id),
+			colors.Bold(fmt.Sprintf("%s@{%d}:", name, i)),
+			message,
+			colors.Gray("("+formatTimeAgo(entry.Time)+")"))
+	}
+	return nil
+}
+
+func runReflogExpire(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+	if reflogExpireAll == (len(args) > 0) {
+		return fmt.Errorf("name the timelines to expire, or use --all")
+	}
+
+	expire, expireUnreachable := config.ReflogExpiry()
+	var err error
+	if reflogExpireValue != "" {
+		if expire, err = config.ParseExpiry(reflogExpireValue); err != nil {
+			return fmt.Errorf("invalid --expire: %w", err)
+		}
+	}
+	if reflogExpireUnreachable != "" {
+		if expireUnreachable, err = config.ParseExpiry(reflogExpireUnreachable); err != nil {
+			return fmt.Errorf("invalid --expire-unreachable: %w", err)
+		}
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	var targets []refs.ReflogRef
+	if reflogExpireAll {
+		if targets, err = refsManager.ListReflogs(); err != nil {
+			return err
+		}
+	}
+	for _, arg := range args {
+		name, timelineType, err := reflogTimeline(refsManager, arg)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, refs.ReflogRef{Name: name, Type: timelineType})
+	}
+
+	removed, err := expireReflogs(refsManager, casStore, targets, expire, expireUnreachable, reflogExpireDryRun)
+	if err != nil {
+		return err
+	}
+	switch {
+	case removed == 0:
+		fmt.Println("No reflog entries expired.")
+	case reflogExpireDryRun:
+		fmt.Printf("Would expire %d reflog entries\n", removed)
+		fmt.Println(colors.Dim("Dry run: nothing was changed"))
+	default:
+		fmt.Printf("%s Expired %d reflog entries\n", colors.SuccessText("[OK]"), removed)
+	}
+	return nil
+}
+
+// expireReflogs removes the expired entries of the given reflogs and
+// returns how many were removed. An entry is unreachable when its seal
+// cannot be reached from the timeline's current head; if the history
+// cannot be walked, every entry counts as reachable.
+func expireReflogs(refsManager *refs.RefsManager, casStore cas.CAS, targets []refs.ReflogRef,
+	expire, expireUnreachable time.Duration, dryRun bool) (int, error) {
+	now := time.Now()
+	opts := refs.ExpireOptions{DryRun: dryRun}
+	if expire != config.ExpireNever {
+		opts.Expire = now.Add(-expire)
+	}
+	if expireUnreachable != config.ExpireNever {
+		opts.ExpireUnreachable = now.Add(-expireUnreachable)
+	}
+
+	commitReader := commit.NewCommitReader(casStore)
+	total := 0
+	for _, target := range targets {
+		// The history is only walked once an entry is old enough to ask
+		var reachable map[cas.Hash]bool
+		walked := false
+		opts.Reachable = func(hash [32]byte) bool {
+			if !walked {
+				walked = true
+				if timeline, err := refsManager.GetTimeline(target.Name, target.Type); err == nil {
+					reachable, _ = commitReader.Ancestors(cas.Hash(timeline.Blake3Hash))
+				}
+			}
+			return reachable == nil || reachable[cas.Hash(hash)]
+		}
+
+		removed, _, err := refsManager.ExpireReflog(target.Name, target.Type, opts)
+		if err != nil {
+			return total, err
+		}
+		total += removed
+	}
+	return total, nil
+}
+
+// reflogTimeline finds the timeline whose reflog a name refers to: the
+// current timeline when name is empty, then a local timeline, then a
+// remote one
+func reflogTimeline(refsManager *refs.RefsManager, name string) (string, refs.TimelineType, error) {
+	if name == "" || name == "HEAD" {
+		current, err := refsManager.GetCurrentTimeline()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get current timeline: %w", err)
+		}
+		return current, refs.LocalTimeline, nil
+	}
+	if refsManager.TimelineExists(name, refs.LocalTimeline) {
+		return name, refs.LocalTimeline, nil
+	}
+	if refsManager.TimelineExists(name, refs.RemoteTimeline) {
+		return name, refs.RemoteTimeline, nil
+	}
+	return "", "", fmt.Errorf("timeline '%s' not found", name)
+}
+
+// resolveReflogRef resolves <timeline>@{n}: the seal the timeline pointed
+// at n moves ago. An empty timeline name means the current timeline.
+func resolveReflogRef(refsManager *refs.RefsManager, ref string) (cas.Hash, error) {
+	at := strings.LastIndex(ref, "@{")
+	n, err := strconv.Atoi(strings.TrimSuffix(ref[at+2:], "}"))
+	if err != nil || n < 0 {
+		return cas.Hash{}, fmt.Errorf("invalid reflog reference: %s", ref)
+	}
+	name, timelineType, err := reflogTimeline(refsManager, ref[:at])
+	if err != nil {
+		return cas.Hash{}, err
+	}
+	entries, err := refsManager.ReadReflog(name, timelineType)
+	if err != nil {
+		return cas.Hash{}, fmt.Errorf("failed to read reflog: %w", err)
+	}
+	if n >= len(entries) {
+		return cas.Hash{}, fmt.Errorf("'%s' goes past the end of the reflog of %s", ref, name)
+	}
+	return cas.Hash(entries[n].New), nil
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// stagedRemovalsFile lists tracked paths whose deletion was gathered. Seal
+// leaves them out of the new tree; every other file of the last seal is
+// carried over unless it was gathered again.
+func stagedRemovalsFile(ivaldiDir string) string {
+	return filepath.Join(ivaldiDir, "stage", "removed")
+}
+
+// getStagedRemovals returns the paths staged for removal
+func getStagedRemovals(ivaldiDir string) ([]string, error) {
+	data, err := os.ReadFile(stagedRemovalsFile(ivaldiDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged removals: %w", err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// writeStagedRemovals replaces the list of staged removals. An empty list
+// removes the file. Callers must hold the stage lock.
+func writeStagedRemovals(ivaldiDir string, files []string) error {
+	path := stagedRemovalsFile(ivaldiDir)
+	if len(files) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove staged removals: %w", err)
+		}
+		return nil
+	}
+
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+	content := strings.Join(sorted, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write staged removals: %w", err)
+	}
+	return nil
+}
+
+// addStagedRemovals stages paths for removal
+func addStagedRemovals(ivaldiDir string, paths []string) error {
+	removals, err := getStagedRemovals(ivaldiDir)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(removals))
+	for _, path := range removals {
+		known[path] = true
+	}
+	for _, path := range paths {
+		if !known[path] {
+			removals = append(removals, path)
+			known[path] = true
+		}
+	}
+	return writeStagedRemovals(ivaldiDir, removals)
+}
+
+// dropStagedRemovals unstages the removal of paths, typically because they
+// were gathered again or reset
+func dropStagedRemovals(ivaldiDir string, paths []string) error {
+	removals, err := getStagedRemovals(ivaldiDir)
+	if err != nil || len(removals) == 0 {
+		return err
+	}
+
+	drop := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		drop[path] = true
+	}
+
+	var remaining []string
+	for _, path := range removals {
+		if !drop[path] {
+			remaining = append(remaining, path)
+		}
+	}
+	if len(remaining) == len(removals) {
+		return nil
+	}
+	return writeStagedRemovals(ivaldiDir, remaining)
+}
+
+// findRemovedFiles returns the files of the last seal that no longer exist
+// in the working directory, sorted. With paths, only files equal to or
+// below one of them are returned.
+func findRemovedFiles(ivaldiDir, workDir string, paths []string) ([]string, error) {
+	knownFiles, err := getKnownFiles(ivaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tracked files: %w", err)
+	}
+
+	var removed []string
+	for file := range knownFiles {
+		if len(paths) > 0 && !matchesAnyPath(file, paths) {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(workDir, filepath.FromSlash(file))); os.IsNotExist(err) {
+			removed = append(removed, file)
+		}
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+// matchesAnyPath reports whether file is one of paths or lies below one of
+// them. The path "." matches every file.
+func matchesAnyPath(file string, paths []string) bool {
+	for _, path := range paths {
+		path = filepath.ToSlash(filepath.Clean(path))
+		if path == "." || file == path || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,9 @@ import (
 	"strings"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/pathspec"
+	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
 	"github.com/spf13/cobra"
 )
 
@@ -35,24 +38,115 @@ func init() {
 
 func runReset(cmd *cobra.Command, args []string) error {
 	// Check if we're in an Ivaldi repository
-	ivaldiDir := ".ivaldi"
+	ivaldiDir := config.IvaldiDir()
 	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 	}
 
+	if err := requireWorkTree("reset"); err != nil {
+		return err
+	}
+
 	// Handle --hard flag (dangerous operation)
 	if resetHard {
 		return resetHardMode(ivaldiDir)
 	}
 
+	stageLock, err := lockStage(ivaldiDir)
+	if err != nil {
+		return err
+	}
+	defer stageLock.Release()
+
+	// Paths are given from wherever reset was started
+	args = pathspec.Within(workPrefix, args)
+	if err := resetIntentToAdd(ivaldiDir, args); err != nil {
+		return err
+	}
+	if err := resetRemovals(ivaldiDir, args); err != nil {
+		return err
+	}
+
 	// Handle unstaging
 	if len(args) == 0 {
 		// Reset all staged files
-		return resetAll(ivaldiDir)
+		err = resetAll(ivaldiDir)
+	} else {
+		// Reset specific files
+		err = resetFiles(ivaldiDir, args)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Reset specific files
-	return resetFiles(ivaldiDir, args)
+	// Snapshots of unstaged files are no longer needed
+	return pruneStagedSnapshots(ivaldiDir)
+}
+
+// resetIntentToAdd clears intent-to-add markers matching paths, or all of
+// them when no paths are given
+func resetIntentToAdd(ivaldiDir string, paths []string) error {
+	intent, err := getIntentToAddFiles(ivaldiDir)
+	if err != nil || len(intent) == 0 {
+		return err
+	}
+
+	var cleared []string
+	for _, file := range intent {
+		if len(paths) == 0 {
+			cleared = append(cleared, file)
+			continue
+		}
+		for _, path := range paths {
+			cleanPath := filepath.Clean(path)
+			if file == cleanPath || strings.HasPrefix(file, cleanPath+"/") {
+				cleared = append(cleared, file)
+				break
+			}
+		}
+	}
+
+	if len(cleared) == 0 {
+		return nil
+	}
+	if err := dropIntentToAdd(ivaldiDir, cleared); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", colors.SuccessText("Cleared intent-to-add:"))
+	for _, file := range cleared {
+		fmt.Printf("  %s\n", colors.InfoText(file))
+	}
+	return nil
+}
+
+// resetRemovals unstages the removals matching paths, or all of them when no
+// paths are given
+func resetRemovals(ivaldiDir string, paths []string) error {
+	removals, err := getStagedRemovals(ivaldiDir)
+	if err != nil || len(removals) == 0 {
+		return err
+	}
+
+	var cleared []string
+	for _, file := range removals {
+		if len(paths) == 0 || matchesAnyPath(file, paths) {
+			cleared = append(cleared, file)
+		}
+	}
+
+	if len(cleared) == 0 {
+		return nil
+	}
+	if err := dropStagedRemovals(ivaldiDir, cleared); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", colors.SuccessText("Unstaged removals:"))
+	for _, file := range cleared {
+		fmt.Printf("  %s\n", colors.InfoText(file))
+	}
+	return nil
 }
 
 // resetAll unstages all files
@@ -199,6 +293,12 @@ func resetHardMode(ivaldiDir string) error {
 			return fmt.Errorf("failed to clear staging: %w", err)
 		}
 	}
+	if err := writeStagedRemovals(ivaldiDir, nil); err != nil {
+		return fmt.Errorf("failed to clear staging: %w", err)
+	}
+	if err := workspace.WriteStagedSnapshots(stagedSnapshotsFile(ivaldiDir), nil); err != nil {
+		return fmt.Errorf("failed to clear staging: %w", err)
+	}
 
 	fmt.Println(colors.SuccessText("Cleared staging area."))
 	fmt.Println()
@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// resolveConflicts records resolutions for conflicted files of the fuse in
+// progress. With the ours or theirs strategy the file is first replaced by
+// the target or source version; otherwise the working copy is taken as the
+// resolution. Decisions are saved one file at a time, so a fuse can be
+// resolved over several sessions before 'fuse --continue'.
+func resolveConflicts(ivaldiDir, workDir string, paths []string, strategy string) error {
+	if !isMergeInProgress(ivaldiDir) {
+		return fmt.Errorf("no merge in progress")
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no files given. Use: ivaldi fuse --resolve [--strategy=ours|theirs] <file>...")
+	}
+
+	var side cas.Hash
+	switch diffmerge.StrategyType(strategy) {
+	case diffmerge.StrategyAuto:
+		strategy = "manual"
+	case diffmerge.StrategyOurs, diffmerge.StrategyTheirs:
+		state, err := loadMergeState(ivaldiDir)
+		if err != nil {
+			return fmt.Errorf("failed to load merge state: %w", err)
+		}
+		side = state.TargetHash
+		if diffmerge.StrategyType(strategy) == diffmerge.StrategyTheirs {
+			side = state.SourceHash
+		}
+	default:
+		return fmt.Errorf("--resolve supports --strategy=ours or --strategy=theirs; edit the file and gather it for other resolutions")
+	}
+
+	stageLock, err := lockStage(ivaldiDir)
+	if err != nil {
+		return err
+	}
+	defer stageLock.Release()
+
+	resStorage := diffmerge.NewResolutionStorage(ivaldiDir)
+	resolution, err := resStorage.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load resolution: %w", err)
+	}
+	if resolution == nil {
+		return fmt.Errorf("no conflict resolution in progress")
+	}
+
+	// Check every path before touching the workspace
+	for i, path := range paths {
+		paths[i] = filepath.ToSlash(filepath.Clean(path))
+		if _, ok := resolution.Files[paths[i]]; !ok {
+			return fmt.Errorf("%s has no conflict in this merge", paths[i])
+		}
+	}
+
+	if side != (cas.Hash{}) {
+		if err := checkoutMergeSide(ivaldiDir, workDir, side, paths); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range paths {
+		if err := recordResolution(resStorage, workDir, path, strategy); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s (%s)\n", colors.Green("Resolved:"), path, strategy)
+	}
+
+	return printRemainingConflicts(resStorage)
+}
+
+// checkoutMergeSide replaces paths in the working directory with their
+// version in the given commit, removing files that do not exist there
+func checkoutMergeSide(ivaldiDir, workDir string, commitHash cas.Hash, paths []string) error {
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	files, err := getCommitFileRefs(casStore, commitHash)
+	if err != nil {
+		return err
+	}
+
+	loader := filechunk.NewLoader(casStore)
+	for _, path := range paths {
+		fullPath := filepath.Join(workDir, filepath.FromSlash(path))
+
+		ref, ok := files[path]
+		if !ok {
+			if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			continue
+		}
+
+		content, err := loader.ReadAll(ref)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// recordResolution saves the working copy of path as its resolution. A
+// file missing from the working directory is recorded as deleted, with the
+// zero hash.
+func recordResolution(resStorage *diffmerge.ResolutionStorage, workDir, path, strategy string) error {
+	var resultHash cas.Hash
+	content, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(path)))
+	if err == nil {
+		resultHash = cas.SumB3(content)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	resolvedBy, _ := config.GetAuthor()
+	if err := resStorage.RecordFile(path, strategy, resultHash, resolvedBy); err != nil {
+		return fmt.Errorf("failed to record resolution for %s: %w", path, err)
+	}
+	return nil
+}
+
+// recordGatheredResolutions marks gathered files that conflicted in the fuse
+// in progress as resolved with their working copy, returning the paths it
+// recorded. Callers must hold the stage lock.
+func recordGatheredResolutions(ivaldiDir, workDir string, paths []string) ([]string, error) {
+	if !isMergeInProgress(ivaldiDir) {
+		return nil, nil
+	}
+
+	resStorage := diffmerge.NewResolutionStorage(ivaldiDir)
+	resolution, err := resStorage.Load()
+	if err != nil || resolution == nil {
+		return nil, err
+	}
+
+	var recorded []string
+	for _, path := range paths {
+		if _, ok := resolution.Files[path]; !ok {
+			continue
+		}
+		if err := recordResolution(resStorage, workDir, path, "manual"); err != nil {
+			return recorded, err
+		}
+		recorded = append(recorded, path)
+	}
+	return recorded, nil
+}
+
+// printRemainingConflicts tells the user what is left before the fuse can
+// be concluded
+func printRemainingConflicts(resStorage *diffmerge.ResolutionStorage) error {
+	resolution, err := resStorage.Load()
+	if err != nil || resolution == nil {
+		return err
+	}
+
+	unresolved := resolution.GetUnresolvedFiles()
+	if len(unresolved) == 0 {
+		fmt.Printf("%s All conflicts resolved. Run %s to create the merge seal.\n",
+			colors.SuccessText("[OK]"), colors.Cyan("ivaldi fuse --continue"))
+		return nil
+	}
+
+	fmt.Printf("%s %d conflicted file(s) remaining\n", colors.Yellow(">>"), len(unresolved))
+	return nil
+}
+
+// writeConflictMarkers writes the conflicted text files of a paused fuse
+// to the working directory: lines changed by one side are merged, and
+// lines changed by both are written between conflict markers in the style
+// of merge.conflictStyle. Binary and big files, and files deleted on one
+// side, are left alone. The paths written are recorded in MERGE_MARKERS,
+// so that 'ivaldi fuse --abort' can restore them.
+func writeConflictMarkers(ivaldiDir, workDir string, casStore cas.CAS, conflicts []diffmerge.Conflict, labels diffmerge.MarkerLabels) ([]string, error) {
+	style := diffmerge.ConflictStyle(config.ConflictStyle())
+	threshold := config.BigFileThreshold()
+	loader := filechunk.NewLoader(casStore)
+	read := func(file *wsindex.FileMetadata) ([]byte, error) {
+		if file == nil {
+			return nil, nil
+		}
+		return loader.ReadAll(file.FileRef)
+	}
+
+	var written []string
+	for _, conflict := range conflicts {
+		if conflict.Type != diffmerge.FileFileConflict || conflict.LeftFile == nil || conflict.RightFile == nil {
+			continue
+		}
+		if threshold > 0 && (conflict.LeftFile.FileRef.Size > threshold || conflict.RightFile.FileRef.Size > threshold ||
+			(conflict.BaseFile != nil && conflict.BaseFile.FileRef.Size > threshold)) {
+			continue
+		}
+
+		sides := make([][]byte, 3)
+		for i, file := range []*wsindex.FileMetadata{conflict.BaseFile, conflict.LeftFile, conflict.RightFile} {
+			content, err := read(file)
+			if err != nil {
+				return written, fmt.Errorf("failed to read %s: %w", conflict.Path, err)
+			}
+			sides[i] = content
+		}
+		if isBinaryContent(sides[0]) || isBinaryContent(sides[1]) || isBinaryContent(sides[2]) {
+			continue
+		}
+
+		merged, _ := diffmerge.MergeText(sides[0], sides[1], sides[2], style, labels)
+		fullPath := filepath.Join(workDir, filepath.FromSlash(conflict.Path))
+		if err := os.WriteFile(fullPath, merged, 0644); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", conflict.Path, err)
+		}
+		written = append(written, conflict.Path)
+	}
+
+	if len(written) > 0 {
+		markersPath := filepath.Join(ivaldiDir, "MERGE_MARKERS")
+		if err := os.WriteFile(markersPath, []byte(strings.Join(written, "\n")), 0644); err != nil {
+			return written, fmt.Errorf("failed to record conflict markers: %w", err)
+		}
+	}
+	return written, nil
+}
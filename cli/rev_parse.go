@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var revParseShort int
+
+var revParseCmd = &cobra.Command{
+	Use:   "rev-parse <selector>...",
+	Short: "Resolve seal selectors to full hashes",
+	Long: `Print the full hash of the seal each selector names, one per line in the
+order given. A selector is HEAD, a timeline, a tag, a reflog entry such as
+main@{2}, a seal name or a unique hash prefix, optionally followed by ~N to
+go back N first parents: the same selectors every other command accepts.
+Any selector that does not resolve is an error, and nothing is printed.
+
+Examples:
+  ivaldi rev-parse HEAD
+  ivaldi rev-parse main feature~2
+  ivaldi rev-parse --short 12 swift-eagle`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runRevParse,
+}
+
+func init() {
+	revParseCmd.Flags().IntVar(&revParseShort, "short", 0, "Print only the first N hex digits of each hash")
+}
+
+func runRevParse(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+	if revParseShort < 0 {
+		return fmt.Errorf("--short must not be negative")
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	hashes := make([]string, 0, len(args))
+	for _, arg := range args {
+		hash, err := resolveCommitRef(casStore, refsManager, arg)
+		if err != nil {
+			return err
+		}
+		full := hash.String()
+		if revParseShort > 0 {
+			full = full[:min(revParseShort, len(full))]
+		}
+		hashes = append(hashes, full)
+	}
+
+	for _, hash := range hashes {
+		fmt.Println(hash)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/github"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/spf13/cobra"
@@ -24,7 +25,7 @@ Examples:
   ivaldi scout --refresh          # Refresh remote timeline cache`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+func TestMergeToSeal(t *testing.T) {
+	ivaldiDir := t.TempDir()
+
+	// Without a merge in progress a seal has a single parent
+	state, _, err := mergeToSeal(ivaldiDir, "main")
+	if err != nil || state != nil {
+		t.Fatalf("Expected no merge, got %v, %v", state, err)
+	}
+
+	source, target := cas.SumB3([]byte("source")), cas.SumB3([]byte("target"))
+	err = saveMergeState(ivaldiDir, &MergeState{
+		SourceTimeline: "feature",
+		TargetTimeline: "main",
+		SourceHash:     source,
+		TargetHash:     target,
+		Conflicts:      []diffmerge.Conflict{{Path: "a.txt"}},
+	})
+	if err != nil {
+		t.Fatalf("saveMergeState failed: %v", err)
+	}
+	resStorage := diffmerge.NewResolutionStorage(ivaldiDir)
+	resolution := diffmerge.CreateResolution("feature", "main", source, target, diffmerge.StrategyAuto)
+	resolution.AddConflict("a.txt")
+	if err := resStorage.Save(resolution); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if _, _, err := mergeToSeal(ivaldiDir, "other"); err == nil || !strings.Contains(err.Error(), "Switch to main") {
+		t.Errorf("Expected a seal on another timeline to be refused, got %v", err)
+	}
+	if _, _, err := mergeToSeal(ivaldiDir, "main"); err == nil || !strings.Contains(err.Error(), "a.txt") {
+		t.Errorf("Expected unresolved a.txt to block the seal, got %v", err)
+	}
+
+	// Gathering the file resolves it, and the seal concludes the merge
+	if err := resolution.ResolveFile("a.txt", "manual", cas.SumB3([]byte("merged")), "test"); err != nil {
+		t.Fatalf("ResolveFile failed: %v", err)
+	}
+	if err := resStorage.Save(resolution); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	state, resolution, err = mergeToSeal(ivaldiDir, "main")
+	if err != nil {
+		t.Fatalf("mergeToSeal failed: %v", err)
+	}
+	if state == nil || state.SourceHash != source || resolution == nil {
+		t.Fatalf("Expected the merge of %s with its resolution, got %+v", source, state)
+	}
+
+	finishMerge(ivaldiDir, resolution)
+	if isMergeInProgress(ivaldiDir) {
+		t.Error("Merge should no longer be in progress")
+	}
+	for _, name := range []string{"MERGE_INFO", "MERGE_CONFLICTS", "MERGE_RESOLUTION"} {
+		if _, err := os.Stat(filepath.Join(ivaldiDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", name, err)
+		}
+	}
+}
+
+func TestRefuseSecrets(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+
+	staged := []string{"main.go", ".env", "config/.env.local"}
+	err := refuseSecrets(staged, false)
+	if err == nil || !strings.Contains(err.Error(), "2 file(s)") {
+		t.Errorf("Expected the default patterns to refuse 2 files, got %v", err)
+	}
+	if err := refuseSecrets(staged, true); err != nil {
+		t.Errorf("Expected --allow-secrets to seal anyway, got %v", err)
+	}
+
+	// Configured patterns replace the defaults, and are seen at once
+	if err := config.SetValue("security.secretpatterns", "*.pem", false); err != nil {
+		t.Fatalf("SetValue failed: %v", err)
+	}
+	if err := refuseSecrets(staged, false); err != nil {
+		t.Errorf("Expected .env to be allowed by *.pem, got %v", err)
+	}
+	if err := refuseSecrets([]string{"key.pem"}, false); err == nil {
+		t.Error("Expected key.pem to be refused")
+	}
+
+	// An empty value turns the patterns off instead of restoring the defaults
+	if err := config.SetValue("security.secretpatterns", "", false); err != nil {
+		t.Fatalf("SetValue failed: %v", err)
+	}
+	if err := refuseSecrets(append(staged, "key.pem"), false); err != nil {
+		t.Errorf("Expected no patterns to refuse nothing, got %v", err)
+	}
+}
+
+func TestDiffCheckStagedSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+
+	ivaldiDir := filepath.Join(dir, ".ivaldi")
+	if err := os.MkdirAll(filepath.Join(ivaldiDir, "stage"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(ivaldiDir, "stage", "files"), []byte("a.txt\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := config.SetValue("core.snapshotstaging", "true", false); err != nil {
+		t.Fatalf("SetValue failed: %v", err)
+	}
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		t.Fatalf("NewFileCAS failed: %v", err)
+	}
+
+	gather := func(gathered, working string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(gathered), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if err := updateStagedSnapshots(ivaldiDir, dir, []string{"a.txt"}, nil); err != nil {
+			t.Fatalf("updateStagedSnapshots failed: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(working), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	// The gathered content is checked, not the working tree
+	gather("clean\n", "trailing \n")
+	if err := runDiffCheck(casStore, ivaldiDir, dir); err != nil {
+		t.Errorf("Expected the clean snapshot to pass, got %v", err)
+	}
+	gather("trailing \n", "clean\n")
+	if err := runDiffCheck(casStore, ivaldiDir, dir); err == nil || !strings.Contains(err.Error(), "1 whitespace error") {
+		t.Errorf("Expected the snapshot's whitespace error, got %v", err)
+	}
+}
+
+func TestSealConcludesFuse(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	t.Chdir(root)
+
+	run := func(args ...string) {
+		t.Helper()
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("ivaldi %v failed: %v", args, err)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("forge")
+	run("config", "user.name", "Test")
+	run("config", "user.email", "test@example.com")
+	write("a.txt", "base\n")
+	run("gather", "a.txt")
+	run("seal", "base")
+
+	// The source changes a.txt and adds c.txt, which merges cleanly
+	run("timeline", "create", "feature")
+	run("timeline", "switch", "feature")
+	write("a.txt", "feature\n")
+	write("c.txt", "new\n")
+	run("gather", "a.txt", "c.txt")
+	run("seal", "feature")
+	run("timeline", "switch", "main")
+	write("a.txt", "main\n")
+	run("gather", "a.txt")
+	run("seal", "main")
+
+	run("fuse", "feature")
+	if !isMergeInProgress(filepath.Join(root, ".ivaldi")) {
+		t.Fatal("Expected the fuse to stop on the a.txt conflict")
+	}
+	write("a.txt", "resolved\n")
+	run("gather", "a.txt")
+	run("seal", "merge")
+
+	casStore, err := cas.NewFileCAS(filepath.Join(root, ".ivaldi", "objects"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refsManager, err := refs.NewRefsManager(filepath.Join(root, ".ivaldi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer refsManager.Close()
+	head, err := resolveCommitRef(casStore, refsManager, "HEAD")
+	if err != nil {
+		t.Fatalf("resolveCommitRef failed: %v", err)
+	}
+	files, err := getCommitFileRefs(casStore, head)
+	if err != nil {
+		t.Fatalf("getCommitFileRefs failed: %v", err)
+	}
+	loader := filechunk.NewLoader(casStore)
+	for path, want := range map[string]string{"a.txt": "resolved\n", "c.txt": "new\n"} {
+		ref, ok := files[path]
+		if !ok {
+			t.Errorf("Expected %s in the merge seal", path)
+			continue
+		}
+		if content, err := loader.ReadAll(ref); err != nil || string(content) != want {
+			t.Errorf("Expected %s to be sealed as %q, got %q, %v", path, want, content, err)
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(root, "c.txt")); err != nil || string(content) != "new\n" {
+		t.Errorf("Expected c.txt to be written to the workspace, got %q, %v", content, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/spf13/cobra"
 )
@@ -28,7 +29,7 @@ var sealsListCmd = &cobra.Command{
 	Long:    `List all seals in the repository with their generated names, timestamps, and messages.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -112,7 +113,7 @@ name prefix, or hash.`,
 		sealRef := args[0]
 
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -0,0 +1,402 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/hamtdir"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+	"github.com/spf13/cobra"
+)
+
+var (
+	showRaw           bool
+	showShowSignature bool
+	showWhitespace    diffmerge.WhitespaceOptions
+	showHunks         diffmerge.HunkOptions
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show [ref]",
+	Short: "Show a seal or a stored object",
+	Long: `Show a seal with its diff against its first parent. The ref defaults to
+HEAD and may be a timeline, a seal name, a hash prefix or any of these
+followed by ~N.
+
+With --raw, the argument may also be the full hash of any stored object.
+The object is decoded as a commit, a tree node, a workspace index node or
+a file chunk node, and its fields are printed as they are encoded. Objects
+that none of these decoders accept are reported as an error. An object
+whose bytes are valid in more than one encoding, such as an empty node,
+lists the other readings as well.
+
+Examples:
+  ivaldi show                 # Show the last seal and its changes
+  ivaldi show main~2          # Show the seal two before the tip of main
+  ivaldi show v1.0            # Show a tag's annotation and seal
+  ivaldi show -w              # Hide changes that only touch whitespace
+  ivaldi show -U10 main~1     # Show ten lines of context around changes
+  ivaldi show --show-signature # Verify the signature of the last seal
+  ivaldi show --raw HEAD      # Dump the commit object of HEAD
+  ivaldi show --raw 3f2a...   # Dump the object with the given full hash`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runShow,
+}
+
+func init() {
+	showCmd.Flags().BoolVar(&showRaw, "raw", false, "Decode and print a stored object instead of a seal")
+	showCmd.Flags().BoolVar(&showShowSignature, "show-signature", false, "Verify and show the signature of the seal")
+	addTextFlag(showCmd)
+	addWhitespaceFlags(showCmd, &showWhitespace)
+	addContextFlags(showCmd, &showHunks)
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	ref := "HEAD"
+	if len(args) > 0 {
+		ref = args[0]
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	if showRaw {
+		hash, err := resolveObjectHash(casStore, refsManager, ref)
+		if err != nil {
+			return err
+		}
+		return showRawObject(casStore, hash)
+	}
+
+	commitHash, err := resolveCommitRef(casStore, refsManager, ref)
+	if err != nil {
+		return err
+	}
+	if !refsManager.TimelineExists(ref, refs.LocalTimeline) {
+		showTagAnnotation(casStore, refsManager, ref)
+	}
+	return showSeal(casStore, refsManager, commitHash)
+}
+
+// showTagAnnotation prints the tagger and message of an annotated tag.
+// Lightweight tags and other refs print nothing.
+func showTagAnnotation(casStore cas.CAS, refsManager *refs.RefsManager, name string) {
+	tag, err := refsManager.GetTimeline(name, refs.TagTimeline)
+	if err != nil || tag.GitSHA1Hash == "" {
+		return
+	}
+	// Annotated tags are recorded under the Git SHA of their tag object
+	hash, _, err := refsManager.LookupByGitHash(tag.GitSHA1Hash)
+	if err != nil {
+		return
+	}
+	tagObj, err := commit.ReadTag(casStore, cas.Hash(hash))
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("%s %s\n", colors.Cyan("tag"), colors.Bold(tagObj.Name))
+	if tagObj.Tagger != "" {
+		fmt.Printf("Tagger: %s\n", colors.InfoText(tagObj.Tagger))
+		fmt.Printf("Date:   %s\n", tagObj.TagTime.Format("Mon Jan 2 15:04:05 2006"))
+	}
+	fmt.Println()
+	for _, line := range strings.Split(tagObj.Message, "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+	fmt.Println()
+}
+
+// resolveObjectHash accepts a full object hash, which need not belong to a
+// commit, or any commit reference
+func resolveObjectHash(casStore cas.CAS, refsManager *refs.RefsManager, ref string) (cas.Hash, error) {
+	var hash cas.Hash
+	if decoded, err := hex.DecodeString(ref); err == nil && len(decoded) == len(hash) {
+		copy(hash[:], decoded)
+		if exists, err := casStore.Has(hash); err == nil && exists {
+			return hash, nil
+		}
+		return hash, fmt.Errorf("object %s not found", ref)
+	}
+	return resolveCommitRef(casStore, refsManager, ref)
+}
+
+// showSeal prints a commit followed by its patch against its first parent
+func showSeal(casStore cas.CAS, refsManager *refs.RefsManager, commitHash cas.Hash) error {
+	reader := commit.NewCommitReader(casStore)
+	commitObj, err := reader.ReadCommit(commitHash)
+	if err != nil {
+		return fmt.Errorf("failed to read commit: %w", err)
+	}
+
+	var hashArray [32]byte
+	copy(hashArray[:], commitHash[:])
+	sealName, _ := refsManager.GetSealNameByHash(hashArray)
+	displayCommitFull(commitInfo{Hash: commitHash, Commit: commitObj, SealName: sealName, ShowSignature: showShowSignature})
+
+	files, err := reader.FileRefs(commitObj)
+	if err != nil {
+		return fmt.Errorf("failed to read files: %w", err)
+	}
+
+	// The root commit is compared against an empty tree
+	var parent cas.Hash
+	if len(commitObj.Parents) > 0 {
+		parent = commitObj.Parents[0]
+	}
+	parentFiles, err := getCommitFileRefs(casStore, parent)
+	if err != nil {
+		return fmt.Errorf("failed to read parent: %w", err)
+	}
+
+	changed := changedFilePaths(parentFiles, files)
+	if len(changed) == 0 {
+		return nil
+	}
+	fmt.Println()
+	loader := filechunk.NewLoader(casStore)
+	for _, path := range changed {
+		oldRef, inOld := parentFiles[path]
+		newRef, inNew := files[path]
+		if err := printFileRefDiff(loader, path, oldRef, newRef, inOld, inNew, false, showWhitespace, showHunks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawDecoder decodes one kind of stored object and prints its fields
+type rawDecoder struct {
+	name string
+	// kind is the object type 'ivaldi cat-file -t' reports
+	kind  string
+	print func(data []byte) (func(), error)
+}
+
+// rawDecoders are tried in order. Commits and tags are text and cannot be
+// confused with the binary nodes, whose markers overlap, so every decoder
+// rejects data that is not exactly one of its nodes.
+var rawDecoders = []rawDecoder{
+	{"commit", "commit", decodeRawCommit},
+	{"tag", "tag", decodeRawTag},
+	{"tree node", "tree", decodeRawTreeNode},
+	{"index node", "wsindex", decodeRawIndexNode},
+	{"file node", "blob", decodeRawFileNode},
+}
+
+// showRawObject prints the decoded structure of a stored object
+func showRawObject(casStore cas.CAS, hash cas.Hash) error {
+	data, err := casStore.Get(hash)
+	if err != nil {
+		return fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+
+	var printFn func()
+	var kinds []string
+	for _, decoder := range rawDecoders {
+		fn, err := decoder.print(data)
+		if err != nil {
+			continue
+		}
+		if printFn == nil {
+			printFn = fn
+		}
+		kinds = append(kinds, decoder.name)
+	}
+	if printFn == nil {
+		return fmt.Errorf("object %s (%d bytes) is not a commit, tree, index or file node", hash, len(data))
+	}
+
+	fmt.Printf("%s %s\n", colors.Cyan("object"), colors.Bold(hash.String()))
+	fmt.Printf("type:   %s\n", kinds[0])
+	fmt.Printf("size:   %d bytes\n", len(data))
+	if len(kinds) > 1 {
+		fmt.Printf("%s\n", colors.Gray("also decodes as: "+strings.Join(kinds[1:], ", ")))
+	}
+	fmt.Println()
+	printFn()
+	return nil
+}
+
+func decodeRawCommit(data []byte) (func(), error) {
+	commitObj, err := commit.DecodeCommit(data)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		fmt.Printf("tree      %s\n", commitObj.TreeHash)
+		for _, parent := range commitObj.Parents {
+			fmt.Printf("parent    %s\n", parent)
+		}
+		fmt.Printf("author    %s %s\n", commitObj.Author, formatRawTime(commitObj.AuthorTime))
+		fmt.Printf("committer %s %s\n", commitObj.Committer, formatRawTime(commitObj.CommitTime))
+		if commitObj.MMRPosition != 0 {
+			fmt.Printf("mmr-position %d\n", commitObj.MMRPosition)
+		}
+		if commitObj.Signature != "" {
+			fmt.Printf("gpgsig    %s\n", strings.ReplaceAll(commitObj.Signature, "\n", "\n          "))
+		}
+		fmt.Println()
+		for _, line := range strings.Split(commitObj.Message, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}, nil
+}
+
+func decodeRawTag(data []byte) (func(), error) {
+	tag, err := commit.DecodeTag(data)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		fmt.Printf("object    %s\n", tag.Target)
+		fmt.Printf("tag       %s\n", tag.Name)
+		if tag.Tagger != "" {
+			fmt.Printf("tagger    %s %s\n", tag.Tagger, formatRawTime(tag.TagTime))
+		}
+		fmt.Println()
+		for _, line := range strings.Split(tag.Message, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}, nil
+}
+
+func decodeRawTreeNode(data []byte) (func(), error) {
+	node, err := hamtdir.DecodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if !node.IsLeaf {
+			if node.ChildSizes == nil {
+				fmt.Println("internal node (legacy, no subtree sizes)")
+			} else {
+				fmt.Println("internal node")
+			}
+			fmt.Printf("bitmap %08x\n", node.Bitmap)
+			for bit := 0; bit < 32; bit++ {
+				child, ok := node.Children[bit]
+				if !ok {
+					continue
+				}
+				if node.ChildSizes != nil {
+					fmt.Printf("  [%2d] %s %d entries\n", bit, child, node.ChildSizes[bit])
+				} else {
+					fmt.Printf("  [%2d] %s\n", bit, child)
+				}
+			}
+			return
+		}
+
+		fmt.Printf("leaf node, %d entries\n", len(node.Entries))
+		for _, entry := range node.Entries {
+			switch {
+			case entry.Type == hamtdir.FileEntry && entry.File != nil:
+				fmt.Printf("  file %s %s %d bytes  %s\n", entry.File.Hash, fileNodeKindName(entry.File.Kind), entry.File.Size, entry.Name)
+			case entry.Type == hamtdir.DirEntry && entry.Dir != nil:
+				fmt.Printf("  dir  %s %d entries  %s/\n", entry.Dir.Hash, entry.Dir.Size, entry.Name)
+			default:
+				fmt.Printf("  type %d  %s\n", entry.Type, entry.Name)
+			}
+		}
+	}, nil
+}
+
+func decodeRawIndexNode(data []byte) (func(), error) {
+	node, err := wsindex.DecodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if !node.IsLeaf {
+			fmt.Printf("internal node, %d children\n", len(node.Children))
+			for i, child := range node.Children {
+				fmt.Printf("  %s\n", child)
+				if i < len(node.Separators) {
+					fmt.Printf("  -- %s\n", node.Separators[i])
+				}
+			}
+			return
+		}
+
+		fmt.Printf("leaf node, %d entries\n", len(node.Entries))
+		for _, entry := range node.Entries {
+			fmt.Printf("  %s\n", entry.Path)
+			fmt.Printf("    content  %s %s %d bytes\n", entry.FileRef.Hash, fileNodeKindName(entry.FileRef.Kind), entry.FileRef.Size)
+			fmt.Printf("    mtime    %s\n", formatRawTime(entry.ModTime))
+			fmt.Printf("    mode     %o\n", entry.Mode)
+			fmt.Printf("    size     %d\n", entry.Size)
+			fmt.Printf("    checksum %s\n", entry.Checksum)
+		}
+	}, nil
+}
+
+func decodeRawFileNode(data []byte) (func(), error) {
+	node, err := filechunk.DecodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if node.Kind == filechunk.Node {
+			fmt.Printf("internal node, %d children, %d bytes\n", len(node.Children), node.Size)
+			for _, child := range node.Children {
+				fmt.Printf("  %s\n", child)
+			}
+			return
+		}
+
+		fmt.Printf("leaf node, %d bytes\n", node.Size)
+		if len(node.Chunk) == 0 {
+			return
+		}
+		fmt.Println()
+		if isBinaryContent(node.Chunk) {
+			fmt.Print(hex.Dump(node.Chunk))
+			return
+		}
+		fmt.Print(string(node.Chunk))
+		if !strings.HasSuffix(string(node.Chunk), "\n") {
+			fmt.Println()
+		}
+	}, nil
+}
+
+// fileNodeKindName names the kind of a file's root node
+func fileNodeKindName(kind filechunk.NodeKind) string {
+	switch kind {
+	case filechunk.Leaf:
+		return "leaf"
+	case filechunk.Node:
+		return "node"
+	}
+	return fmt.Sprintf("kind(%d)", kind)
+}
+
+// formatRawTime prints a stored timestamp exactly, in its stored timezone
+func formatRawTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	showRefHeads bool
+	showRefTags  bool
+)
+
+var showRefCmd = &cobra.Command{
+	Use:   "show-ref",
+	Short: "List references and the hashes they point at",
+	Long: `Print one line per reference: the full hash it points at and its name,
+sorted by name. Local timelines are listed as refs/heads/<name>, remote
+timelines as refs/remotes/<name> and tags as refs/tags/<name>. Timelines
+without seals are left out. The output is meant for scripts and does not
+change between versions.
+
+Examples:
+  ivaldi show-ref            # Every reference
+  ivaldi show-ref --heads    # Only local timelines
+  ivaldi show-ref --tags     # Only tags`,
+	Args: cobra.NoArgs,
+	RunE: runShowRef,
+}
+
+func init() {
+	showRefCmd.Flags().BoolVar(&showRefHeads, "heads", false, "List local timelines")
+	showRefCmd.Flags().BoolVar(&showRefTags, "tags", false, "List tags")
+}
+
+func runShowRef(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	kinds := []struct {
+		timelineType refs.TimelineType
+		prefix       string
+		listed       bool
+	}{
+		{refs.LocalTimeline, "refs/heads/", showRefHeads || !showRefTags},
+		{refs.RemoteTimeline, "refs/remotes/", !showRefHeads && !showRefTags},
+		{refs.TagTimeline, "refs/tags/", showRefTags || !showRefHeads},
+	}
+
+	type ref struct {
+		name string
+		hash cas.Hash
+	}
+	var listed []ref
+	for _, kind := range kinds {
+		if !kind.listed {
+			continue
+		}
+		timelines, err := refsManager.ListTimelines(kind.timelineType)
+		if err != nil {
+			return fmt.Errorf("failed to list timelines: %w", err)
+		}
+		for _, timeline := range timelines {
+			if timeline.Blake3Hash == ([32]byte{}) {
+				continue
+			}
+			listed = append(listed, ref{name: kind.prefix + timeline.Name, hash: cas.Hash(timeline.Blake3Hash)})
+		}
+	}
+	sort.Slice(listed, func(i, j int) bool { return listed[i].name < listed[j].name })
+
+	for _, r := range listed {
+		fmt.Printf("%s %s\n", r.hash, r.name)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/signing"
+)
+
+// sealSigner returns the signer of a new seal, or nil when the seal is not
+// signed. Seals are signed when commit.sshSign or commit.gpgSign is set, or
+// when sign is true, in which case the format follows the configured key.
+// noSign overrides both.
+func sealSigner(sign, noSign bool) (*signing.Signer, error) {
+	if noSign {
+		return nil, nil
+	}
+	format := config.SignSeals()
+	if format == "" && !sign {
+		return nil, nil
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	key := cfg.User.SigningKey
+	if format == "" {
+		format = signing.FormatForKey(key)
+	}
+
+	switch {
+	case format == signing.FormatSSH && key == "":
+		return nil, fmt.Errorf("SSH signing needs a key: ivaldi config user.signingkey ~/.ssh/id_ed25519.pub")
+	case format == signing.FormatOpenPGP && key == "":
+		// gpg picks the key of the committer, as Git does
+		key = cfg.User.Email
+	}
+	return &signing.Signer{Format: format, Key: key}, nil
+}
+
+// verifySeal checks the signature of a seal against the configured key
+func verifySeal(commitObj *commit.CommitObject) signing.Verification {
+	key := ""
+	if cfg, err := config.LoadConfig(); err == nil {
+		key = cfg.User.SigningKey
+	}
+	return signing.Verify(commit.SignedPayload(commitObj), commitObj.Signature, key)
+}
+
+// printSignature prints the outcome of verifying the signature of a seal,
+// for --show-signature
+func printSignature(commitObj *commit.CommitObject) {
+	if commitObj.Signature == "" {
+		fmt.Printf("%s\n", colors.Gray("No signature"))
+		return
+	}
+
+	printVerification(verifySeal(commitObj))
+}
+
+// printVerification prints the status of a signature, followed by what the
+// verifier reported unless it is good
+func printVerification(result signing.Verification) {
+	format := result.Format
+	if format == "" {
+		format = "unrecognized"
+	}
+	signer := ""
+	if result.Signer != "" {
+		signer = " from " + result.Signer
+	}
+	switch result.Status {
+	case signing.Good:
+		fmt.Printf("%s\n", colors.Green(fmt.Sprintf("Good %s signature%s", format, signer)))
+	case signing.Bad:
+		fmt.Printf("%s\n", colors.Red(fmt.Sprintf("BAD %s signature%s", format, signer)))
+	default:
+		fmt.Printf("%s\n", colors.Yellow(fmt.Sprintf("Unknown %s signature%s", format, signer)))
+	}
+	if result.Status != signing.Good && result.Output != "" {
+		fmt.Printf("%s\n", colors.Gray(result.Output))
+	}
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// stagedSnapshotsFile records the content gather stored for staged files
+// with core.snapshotStaging on. Seal then commits that content even if the
+// files changed after they were gathered, as git commits its index.
+func stagedSnapshotsFile(ivaldiDir string) string {
+	return filepath.Join(ivaldiDir, "stage", "snapshots")
+}
+
+// updateStagedSnapshots records the gathered files. With snapshot staging
+// their current content is stored and recorded; without it any snapshot
+// left from an earlier gather is dropped, so a later seal does not commit
+// stale content. Snapshots of removed files are dropped as well. Callers
+// must hold the stage lock.
+func updateStagedSnapshots(ivaldiDir, workDir string, gathered, removed []string) error {
+	path := stagedSnapshotsFile(ivaldiDir)
+	snapshots, err := workspace.ReadStagedSnapshots(path)
+	if err != nil {
+		return fmt.Errorf("failed to read staged snapshots: %w", err)
+	}
+	if len(snapshots) == 0 && !config.SnapshotStaging() {
+		return nil
+	}
+
+	for _, file := range gathered {
+		delete(snapshots, file)
+	}
+	for _, file := range removed {
+		delete(snapshots, file)
+	}
+
+	if config.SnapshotStaging() && len(gathered) > 0 {
+		casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+		if err != nil {
+			return fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		materializer := workspace.NewMaterializer(casStore, ivaldiDir, workDir)
+		files, err := materializer.SnapshotFiles(gathered)
+		if err != nil {
+			return fmt.Errorf("failed to snapshot gathered files: %w", err)
+		}
+		for _, file := range files {
+			snapshots[file.Path] = file
+		}
+	}
+
+	if err := workspace.WriteStagedSnapshots(path, snapshots); err != nil {
+		return fmt.Errorf("failed to write staged snapshots: %w", err)
+	}
+	return nil
+}
+
+// pruneStagedSnapshots drops the snapshots of files that are no longer
+// staged. Callers must hold the stage lock.
+func pruneStagedSnapshots(ivaldiDir string) error {
+	path := stagedSnapshotsFile(ivaldiDir)
+	snapshots, err := workspace.ReadStagedSnapshots(path)
+	if err != nil {
+		return fmt.Errorf("failed to read staged snapshots: %w", err)
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	stagedFiles, err := getStagedFilesList(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to read staged files: %w", err)
+	}
+	staged := make(map[string]bool, len(stagedFiles))
+	for _, file := range stagedFiles {
+		staged[file] = true
+	}
+	for file := range snapshots {
+		if !staged[file] {
+			delete(snapshots, file)
+		}
+	}
+
+	if err := workspace.WriteStagedSnapshots(path, snapshots); err != nil {
+		return fmt.Errorf("failed to write staged snapshots: %w", err)
+	}
+	return nil
+}
+
+// stagedSnapshotFiles returns the content to seal for the staged files
+// with snapshot staging, without scanning the workspace. Files gathered
+// before snapshot staging was turned on have no snapshot and are read from
+// the workspace; staged files missing from both are left out, for
+// SealFiles to report.
+func stagedSnapshotFiles(materializer *workspace.Materializer, ivaldiDir string, stagedFiles []string) ([]wsindex.FileMetadata, error) {
+	snapshots, err := workspace.ReadStagedSnapshots(stagedSnapshotsFile(ivaldiDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged snapshots: %w", err)
+	}
+
+	var files []wsindex.FileMetadata
+	var unsnapshotted []string
+	for _, path := range stagedFiles {
+		if file, ok := snapshots[path]; ok {
+			files = append(files, file)
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(materializer.WorkDir, path)); err == nil {
+			unsnapshotted = append(unsnapshotted, path)
+		}
+	}
+
+	current, err := materializer.SnapshotFiles(unsnapshotted)
+	if err != nil {
+		return nil, err
+	}
+	return append(files, current...), nil
+}
+
+// withStagedSnapshots replaces the workspace content of staged files with
+// their snapshots when snapshot staging is on, so that diffs show what seal
+// would commit
+func withStagedSnapshots(ivaldiDir string, stagedFiles []string, files []wsindex.FileMetadata) ([]wsindex.FileMetadata, error) {
+	if !config.SnapshotStaging() {
+		return files, nil
+	}
+	snapshots, err := workspace.ReadStagedSnapshots(stagedSnapshotsFile(ivaldiDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged snapshots: %w", err)
+	}
+	if len(snapshots) == 0 {
+		return files, nil
+	}
+
+	byPath := make(map[string]wsindex.FileMetadata, len(files))
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	for _, path := range stagedFiles {
+		if file, ok := snapshots[path]; ok {
+			byPath[path] = file
+		}
+	}
+	return workspace.StagedSnapshots(byPath).Files(), nil
+}
@@ -0,0 +1,344 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/pathspec"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stashMessage          string
+	stashKeepIndex        bool
+	stashIncludeUntracked bool
+)
+
+var stashCmd = &cobra.Command{
+	Use:   "stash [path...]",
+	Short: "Set local changes aside",
+	Long: `Save local changes in a stash and revert them in the working directory:
+modified files get their sealed content back, deleted files are restored and
+newly gathered files are removed. Run without a subcommand, stash acts as
+'ivaldi stash push'.
+
+By default changes to sealed files and staged files are stashed, and
+untracked files are left alone. --include-untracked stashes them too, except
+ignored ones. --keep-index leaves staged files in place, and paths limit the
+stash to the files equal to or below them. Stashed files are unstaged.
+
+Each stash records exactly which files it took, and apply and pop restore
+only those. They refuse to overwrite a file that has changed since.
+
+Examples:
+  ivaldi stash                         # Stash all tracked changes
+  ivaldi stash -u -m "spike"           # Include untracked files
+  ivaldi stash --keep-index            # Stash what is not staged
+  ivaldi stash src/parser.go docs      # Stash only these paths
+  ivaldi stash list
+  ivaldi stash pop                     # Restore the newest stash`,
+	RunE: runStashPush,
+}
+
+var stashPushCmd = &cobra.Command{
+	Use:   "push [path...]",
+	Short: "Stash local changes",
+	RunE:  runStashPush,
+}
+
+var stashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stashes, newest first",
+	Args:  cobra.NoArgs,
+	RunE:  runStashList,
+}
+
+var stashApplyCmd = &cobra.Command{
+	Use:   "apply [name]",
+	Short: "Restore a stash and keep it",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStashRestore(args, false)
+	},
+}
+
+var stashPopCmd = &cobra.Command{
+	Use:   "pop [name]",
+	Short: "Restore a stash and drop it",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStashRestore(args, true)
+	},
+}
+
+var stashDropCmd = &cobra.Command{
+	Use:   "drop [name]",
+	Short: "Delete a stash",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runStashDrop,
+}
+
+func init() {
+	stashCmd.AddCommand(stashPushCmd, stashListCmd, stashApplyCmd, stashPopCmd, stashDropCmd)
+	addStashPushFlags(stashCmd)
+	addStashPushFlags(stashPushCmd)
+}
+
+// addStashPushFlags registers the flags of 'stash push', which 'stash' on
+// its own accepts as well
+func addStashPushFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&stashMessage, "message", "m", "", "Describe the stash")
+	cmd.Flags().BoolVar(&stashKeepIndex, "keep-index", false, "Leave staged files out of the stash")
+	cmd.Flags().BoolVarP(&stashIncludeUntracked, "include-untracked", "u", false, "Also stash untracked files that are not ignored")
+}
+
+// newStashManager opens the stash manager of the repository in the current
+// directory
+func newStashManager() (*workspace.StashManager, string, error) {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	return workspace.NewStashManager(workspace.NewMaterializer(casStore, ivaldiDir, workDir)), ivaldiDir, nil
+}
+
+func runStashPush(cmd *cobra.Command, args []string) error {
+	stashManager, ivaldiDir, err := newStashManager()
+	if err != nil {
+		return err
+	}
+
+	stageLock, err := lockStage(ivaldiDir)
+	if err != nil {
+		return err
+	}
+	defer stageLock.Release()
+
+	var staged []string
+	for _, read := range []func(string) ([]string, error){getStagedFiles, getStagedRemovals, getIntentToAddFiles} {
+		files, err := read(ivaldiDir)
+		if err != nil {
+			return fmt.Errorf("failed to read staged files: %w", err)
+		}
+		staged = append(staged, files...)
+	}
+	ignorePatterns, err := loadIgnorePatterns(stashManager.Materializer.WorkDir)
+	if err != nil {
+		return fmt.Errorf("failed to load ignore patterns: %w", err)
+	}
+
+	stashes, err := stashManager.Stashes()
+	if err != nil {
+		return err
+	}
+	name := nextStashName(stashes)
+	message := stashMessage
+	if message == "" {
+		message = "WIP"
+		if refsManager, err := refs.NewRefsManager(ivaldiDir); err == nil {
+			if timeline, err := refsManager.GetCurrentTimeline(); err == nil {
+				message = "WIP on " + timeline
+			}
+			refsManager.Close()
+		}
+	}
+
+	stash, err := stashManager.StashChanges(name, message, workspace.StashOptions{
+		Paths:            pathspec.Within(workPrefix, args),
+		Staged:           staged,
+		KeepIndex:        stashKeepIndex,
+		IncludeUntracked: stashIncludeUntracked,
+		Ignored: func(path string) bool {
+			return path == ".ivaldiignore" || isIgnored(path, ignorePatterns)
+		},
+	})
+	if errors.Is(err, workspace.ErrNothingToStash) {
+		fmt.Println("No local changes to stash.")
+		return nil
+	}
+	if stash == nil {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("stash %s was saved but the working directory was not fully reverted: %w", name, err)
+	}
+
+	// What was stashed is no longer there to seal
+	taken := append(append([]string(nil), stash.Paths...), stash.Deleted...)
+	if err := unstageStashed(ivaldiDir, taken); err != nil {
+		return fmt.Errorf("stash %s was saved but its files are still staged: %w", name, err)
+	}
+
+	fmt.Printf("%s Saved %s to stash %s: %s\n", colors.SuccessText("[OK]"),
+		countFiles(len(taken)), colors.Bold(name), message)
+	return nil
+}
+
+// nextStashName returns the first free name of the form stash-N
+func nextStashName(stashes []workspace.Stash) string {
+	used := make(map[string]bool, len(stashes))
+	for _, stash := range stashes {
+		used[stash.Name] = true
+	}
+	for n := len(stashes) + 1; ; n++ {
+		if name := fmt.Sprintf("stash-%d", n); !used[name] {
+			return name
+		}
+	}
+}
+
+// countFiles formats a number of files
+func countFiles(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
+
+// unstageStashed removes stashed paths from the stage. Callers must hold
+// the stage lock.
+func unstageStashed(ivaldiDir string, paths []string) error {
+	stashed := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		stashed[path] = true
+	}
+
+	stagedFiles, err := getStagedFiles(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to read staged files: %w", err)
+	}
+	var remaining []string
+	for _, file := range stagedFiles {
+		if !stashed[file] {
+			remaining = append(remaining, file)
+		}
+	}
+	if len(remaining) != len(stagedFiles) {
+		stageFile := filepath.Join(ivaldiDir, "stage", "files")
+		if len(remaining) == 0 {
+			err = os.Remove(stageFile)
+		} else {
+			err = os.WriteFile(stageFile, []byte(strings.Join(remaining, "\n")+"\n"), 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update staging file: %w", err)
+		}
+	}
+
+	if err := dropStagedRemovals(ivaldiDir, paths); err != nil {
+		return err
+	}
+	if err := dropIntentToAdd(ivaldiDir, paths); err != nil {
+		return err
+	}
+	return pruneStagedSnapshots(ivaldiDir)
+}
+
+func runStashList(cmd *cobra.Command, args []string) error {
+	stashManager, _, err := newStashManager()
+	if err != nil {
+		return err
+	}
+	stashes, err := stashManager.Stashes()
+	if err != nil {
+		return err
+	}
+	if len(stashes) == 0 {
+		fmt.Println("No stashes.")
+		return nil
+	}
+
+	for _, stash := range stashes {
+		details := "whole workspace"
+		if !stash.Created.IsZero() {
+			details = fmt.Sprintf("%s, %s", countFiles(len(stash.Paths)+len(stash.Deleted)), formatTimeAgo(stash.Created))
+		}
+		fmt.Printf("%s %s %s\n", colors.Bold(stash.Name+":"), stash.Description, colors.Gray("("+details+")"))
+	}
+	return nil
+}
+
+// stashName returns the stash named in args, or the newest one
+func stashName(stashManager *workspace.StashManager, args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	stashes, err := stashManager.Stashes()
+	if err != nil {
+		return "", err
+	}
+	if len(stashes) == 0 {
+		return "", fmt.Errorf("no stashes")
+	}
+	return stashes[0].Name, nil
+}
+
+func runStashRestore(args []string, drop bool) error {
+	stashManager, _, err := newStashManager()
+	if err != nil {
+		return err
+	}
+	name, err := stashName(stashManager, args)
+	if err != nil {
+		return err
+	}
+
+	if drop {
+		err = stashManager.PopStash(name)
+	} else {
+		err = stashManager.ApplyStash(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s Restored stash %s\n", colors.SuccessText("[OK]"), colors.Bold(name))
+	if drop {
+		fmt.Printf("Dropped stash %s\n", name)
+	}
+	return nil
+}
+
+func runStashDrop(cmd *cobra.Command, args []string) error {
+	stashManager, _, err := newStashManager()
+	if err != nil {
+		return err
+	}
+	name, err := stashName(stashManager, args)
+	if err != nil {
+		return err
+	}
+	stashes, err := stashManager.ListStashes()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, stash := range stashes {
+		found = found || stash == name
+	}
+	if !found {
+		return fmt.Errorf("stash %s not found", name)
+	}
+
+	if err := stashManager.DropStash(name); err != nil {
+		return err
+	}
+	fmt.Printf("%s Dropped stash %s\n", colors.SuccessText("[OK]"), colors.Bold(name))
+	return nil
+}
@@ -2,18 +2,27 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/colors"
 	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/github"
 	"github.com/javanhut/Ivaldi-vcs/internal/objects"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/shelf"
+	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
 	"github.com/spf13/cobra"
 )
 
@@ -21,13 +30,15 @@ import (
 type FileStatus int
 
 const (
-	StatusUnknown   FileStatus = iota
-	StatusUntracked            // File exists but not in any previous commit
-	StatusAdded                // File is staged for commit (new file)
-	StatusModified             // File is modified from last commit
-	StatusDeleted              // File was deleted from working directory
-	StatusStaged               // File is staged for commit (modified)
-	StatusIgnored              // File is ignored by .ivaldiignore
+	StatusUnknown     FileStatus = iota
+	StatusUntracked              // File exists but not in any previous commit
+	StatusAdded                  // File is staged for commit (new file)
+	StatusModified               // File is modified from last commit
+	StatusDeleted                // File was deleted from working directory
+	StatusStaged                 // File is staged for commit (modified)
+	StatusIgnored                // File is ignored by .ivaldiignore
+	StatusIntentToAdd            // New file marked with 'gather -N' but not gathered
+	StatusRemoved                // Deleted file whose removal is staged
 )
 
 // FileStatusInfo holds information about a file's status
@@ -41,182 +52,304 @@ type FileStatusInfo struct {
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show the working directory status",
-	Long:  `Shows files that are staged, modified, deleted, untracked, or ignored`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
-		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
-			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
-		}
+	Long: `Shows files that are staged, modified, deleted, untracked, or ignored
 
-		workDir, err := os.Getwd()
-		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
-		}
+With --exit-code, status exits with 1 if any file is staged, modified,
+deleted or untracked and 0 if the working directory is clean; failures exit
+with 2. --quiet prints nothing and implies --exit-code.
 
-		// Initialize refs manager
-		refsManager, err := refs.NewRefsManager(ivaldiDir)
-		if err != nil {
-			return fmt.Errorf("failed to initialize refs manager: %w", err)
-		}
-		defer refsManager.Close()
+The upstream line compares with the remote head recorded at the last
+upload, download, sync or harvest, without network access. --refresh, or
+status.refreshUpstream, reads the current head from GitHub first.
 
-		// Get current timeline
-		currentTimeline, err := refsManager.GetCurrentTimeline()
-		if err != nil {
-			return fmt.Errorf("failed to get current timeline: %w", err)
-		}
+With --ahead-of-remote, the upstream line is an estimate that needs no
+network: it counts the seals made since the remote head recorded at the last
+upload, download, sync or harvest.
 
-		// Load ignore patterns
-		ignorePatterns, err := loadIgnorePatterns(workDir)
-		if err != nil {
-			log.Printf("Warning: Failed to load ignore patterns: %v", err)
-		}
+With --fast, files of the last seal are compared by size and modification
+time against the stat cache that every full status fills, and only files
+whose stat changed are read. Files the cache has not seen yet are judged by
+their size alone, and status says when its result is such an approximation.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		changed, err := runStatus(cmd, args)
+		return withExitCode(cmd, statusExitCode || statusQuiet, changed, err)
+	},
+}
 
-		// Get file statuses
-		fileStatuses, err := getFileStatuses(workDir, ivaldiDir, ignorePatterns)
-		if err != nil {
-			return fmt.Errorf("failed to get file statuses: %w", err)
-		}
+// runStatus prints the status of the working directory and reports whether
+// any file is staged, modified, deleted or untracked
+func runStatus(cmd *cobra.Command, args []string) (bool, error) {
+	// Check if we're in an Ivaldi repository
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return false, fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
 
-		// Display status
-		fmt.Printf("On timeline %s\n", colors.Bold(currentTimeline))
+	workDir, err := os.Getwd()
+	if err != nil {
+		return false, fmt.Errorf("failed to get working directory: %w", err)
+	}
 
-		// Show information about the last seal if available
-		err = displayLastSealInfo(refsManager, currentTimeline, ivaldiDir)
-		if err != nil {
-			// Don't fail if we can't get seal info
-		}
+	// Initialize refs manager
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return false, fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
 
-		if len(fileStatuses) == 0 {
-			fmt.Println(colors.SuccessText("Working directory clean"))
-			return nil
+	// Get current timeline
+	currentTimeline, err := refsManager.GetCurrentTimeline()
+	if err != nil {
+		return false, fmt.Errorf("failed to get current timeline: %w", err)
+	}
+
+	// Bare repositories have no working tree to compare against
+	if isBareRepository() {
+		if statusQuiet {
+			return false, nil
 		}
+		fmt.Printf("On timeline %s\n", colors.Bold(currentTimeline))
+		displayLastSealInfo(refsManager, currentTimeline, ivaldiDir)
+		displayUpstreamLine(refsManager, ivaldiDir, workDir, currentTimeline)
+		fmt.Println(colors.Dim("Bare repository: no working tree"))
+		return false, nil
+	}
 
-		// Group files by status
-		var staged []FileStatusInfo
-		var modified []FileStatusInfo
-		var deleted []FileStatusInfo
-		var untracked []FileStatusInfo
-		var ignored []FileStatusInfo
+	// Load ignore patterns
+	ignorePatterns, err := loadIgnorePatterns(workDir)
+	if err != nil {
+		log.Printf("Warning: Failed to load ignore patterns: %v", err)
+	}
 
+	// Only the exit status is wanted
+	if statusQuiet {
+		fileStatuses, _, err := getFileStatuses(workDir, ivaldiDir, ignorePatterns, nil)
+		var counts statusCounts
 		for _, fileInfo := range fileStatuses {
-			switch fileInfo.Status {
-			case StatusStaged, StatusAdded:
-				staged = append(staged, fileInfo)
-			case StatusModified:
-				modified = append(modified, fileInfo)
-			case StatusDeleted:
-				deleted = append(deleted, fileInfo)
-			case StatusUntracked:
-				untracked = append(untracked, fileInfo)
-			case StatusIgnored:
-				ignored = append(ignored, fileInfo)
-			}
+			counts.add(fileInfo.Status)
 		}
+		return counts.total() > 0, err
+	}
 
-		// Display staged files
-		if len(staged) > 0 {
-			fmt.Printf("\n%s\n", colors.SectionHeader("Files staged for seal:"))
-			for _, file := range staged {
-				if file.Status == StatusAdded {
-					fmt.Printf("  %s   %s\n", colors.Added("new file:"), colors.Green(file.Path))
-				} else {
-					fmt.Printf("  %s   %s\n", colors.Staged("modified:"), colors.Blue(file.Path))
-				}
-			}
-		}
+	// Display status
+	fmt.Printf("On timeline %s\n", colors.Bold(currentTimeline))
 
-		// Display modified files
-		if len(modified) > 0 {
-			fmt.Printf("\n%s\n", colors.SectionHeader("Files not staged for seal:"))
-			fmt.Printf("  %s\n", colors.Dim("(use \"ivaldi gather <file>...\" to stage for seal)"))
-			for _, file := range modified {
-				fmt.Printf("  %s   %s\n", colors.Modified("modified:"), colors.Blue(file.Path))
-			}
-		}
+	// Show information about the last seal if available
+	err = displayLastSealInfo(refsManager, currentTimeline, ivaldiDir)
+	if err != nil {
+		// Don't fail if we can't get seal info
+	}
 
-		// Display deleted files
-		if len(deleted) > 0 {
-			fmt.Printf("\n%s\n", colors.SectionHeader("Deleted files:"))
-			fmt.Printf("  %s\n", colors.Dim("(use \"ivaldi gather <file>...\" to stage deletion)"))
-			for _, file := range deleted {
-				fmt.Printf("  %s    %s\n", colors.Deleted("deleted:"), colors.Red(file.Path))
-			}
-		}
+	// Show how the timeline compares to its upstream branch
+	displayUpstreamLine(refsManager, ivaldiDir, workDir, currentTimeline)
 
-		// Display untracked files
-		if len(untracked) > 0 {
-			fmt.Printf("\n%s\n", colors.SectionHeader("Untracked files:"))
-			fmt.Printf("  %s\n", colors.Dim("(use \"ivaldi gather <file>...\" to include in what will be sealed)"))
-			for _, file := range untracked {
-				fmt.Printf("  %s\n", colors.Yellow(file.Path))
-			}
-		}
+	// Remind the user of a paused operation before anything else
+	displayOperationInProgress(ivaldiDir)
+	displayStashSummary(ivaldiDir, workDir)
 
-		// Display a summary
-		fmt.Printf("\n%s ", colors.SectionHeader("Status summary:"))
-		var parts []string
-		if len(staged) > 0 {
-			parts = append(parts, colors.Green(fmt.Sprintf("%d staged", len(staged))))
-		}
-		if len(modified) > 0 {
-			parts = append(parts, colors.Blue(fmt.Sprintf("%d modified", len(modified))))
+	verbose, _ := cmd.Flags().GetBool("ignored")
+	nested := workspace.NewNestedRepos(workDir, config.SkipNestedRepos())
+	if statusStream {
+		return streamStatus(workDir, ivaldiDir, ignorePatterns, verbose, statusLimit, nested)
+	}
+
+	// Get file statuses
+	fileStatuses, mismatches, err := getFileStatuses(workDir, ivaldiDir, ignorePatterns, nested)
+	if err != nil {
+		return false, fmt.Errorf("failed to get file statuses: %w", err)
+	}
+
+	if len(fileStatuses) == 0 {
+		fmt.Println(colors.SuccessText("Working directory clean"))
+		printNestedRepos(nested.Found())
+		printApproximateNote()
+		return false, nil
+	}
+
+	// Group files by status
+	var staged []FileStatusInfo
+	var modified []FileStatusInfo
+	var deleted []FileStatusInfo
+	var untracked []FileStatusInfo
+	var ignored []FileStatusInfo
+	var counts statusCounts
+
+	for _, fileInfo := range fileStatuses {
+		counts.add(fileInfo.Status)
+		switch fileInfo.Status {
+		case StatusStaged, StatusAdded, StatusRemoved:
+			staged = append(staged, fileInfo)
+		case StatusModified, StatusIntentToAdd:
+			modified = append(modified, fileInfo)
+		case StatusDeleted:
+			deleted = append(deleted, fileInfo)
+		case StatusUntracked:
+			untracked = append(untracked, fileInfo)
+		case StatusIgnored:
+			ignored = append(ignored, fileInfo)
 		}
-		if len(untracked) > 0 {
-			parts = append(parts, colors.Yellow(fmt.Sprintf("%d untracked", len(untracked))))
+	}
+
+	limiter := &statusLimiter{limit: statusLimit}
+
+	// Display staged files
+	printStatusSection(limiter, "Files staged for seal:", "", staged, func(file FileStatusInfo) string {
+		if file.Status == StatusAdded {
+			return fmt.Sprintf("  %s   %s", colors.Added("new file:"), colors.Added(file.Path))
 		}
-		if len(deleted) > 0 {
-			parts = append(parts, colors.Red(fmt.Sprintf("%d deleted", len(deleted))))
+		if file.Status == StatusRemoved {
+			return fmt.Sprintf("  %s    %s", colors.Deleted("deleted:"), colors.Deleted(file.Path))
 		}
+		return fmt.Sprintf("  %s   %s", colors.Staged("modified:"), colors.Modified(file.Path))
+	})
 
-		if len(parts) > 0 {
-			fmt.Printf("%s\n", strings.Join(parts, ", "))
-		} else {
-			fmt.Printf("%s\n", colors.SuccessText("clean"))
+	// Display modified files
+	printStatusSection(limiter, "Files not staged for seal:", "(use \"ivaldi gather <file>...\" to stage for seal)", modified, func(file FileStatusInfo) string {
+		if file.Status == StatusIntentToAdd {
+			return fmt.Sprintf("  %s   %s", colors.Added("new file:"), colors.Modified(file.Path))
 		}
+		return fmt.Sprintf("  %s   %s", colors.Modified("modified:"), colors.Modified(file.Path))
+	})
 
-		// Display ignored files (only if verbose flag is set)
-		verbose, _ := cmd.Flags().GetBool("ignored")
-		if verbose && len(ignored) > 0 {
-			fmt.Println("\nIgnored files:")
-			for _, file := range ignored {
-				fmt.Printf("  %s\n", file.Path)
-			}
+	// Display deleted files
+	printStatusSection(limiter, "Deleted files:", "(use \"ivaldi gather <file>...\" to stage deletion)", deleted, func(file FileStatusInfo) string {
+		return fmt.Sprintf("  %s    %s", colors.Deleted("deleted:"), colors.Deleted(file.Path))
+	})
+
+	// Display untracked files
+	printStatusSection(limiter, "Untracked files:", "(use \"ivaldi gather <file>...\" to include in what will be sealed)", untracked, func(file FileStatusInfo) string {
+		return fmt.Sprintf("  %s", colors.Untracked(file.Path))
+	})
+
+	limiter.printHidden()
+
+	// List the nested repositories that were left out
+	printNestedRepos(nested.Found())
+
+	// Warn about names that differ from the last seal only in spelling
+	printPathMismatches(mismatches)
+
+	// Display a summary
+	counts.printSummary()
+	printApproximateNote()
+
+	// Display ignored files (only if verbose flag is set)
+	if verbose && len(ignored) > 0 {
+		fmt.Println("\nIgnored files:")
+		for _, file := range ignored {
+			fmt.Printf("  %s\n", file.Path)
 		}
+	}
 
-		return nil
-	},
+	return counts.total() > 0, nil
 }
 
 func init() {
 	statusCmd.Flags().BoolP("ignored", "i", false, "Show ignored files")
+	statusCmd.Flags().BoolVar(&statusStream, "stream", false, "Print changes as they are found, for very large workspaces")
+	statusCmd.Flags().IntVar(&statusLimit, "limit", 0, "Show at most this many files (0 for no limit)")
+	statusCmd.Flags().BoolVar(&statusExitCode, "exit-code", false, "Exit with 1 if there are changes and 0 if the working directory is clean (2 on errors)")
+	statusCmd.Flags().BoolVarP(&statusQuiet, "quiet", "q", false, "Print nothing; implies --exit-code")
+	statusCmd.Flags().BoolVar(&statusAheadOfRemote, "ahead-of-remote", false, "Estimate unpushed seals from the last known remote head, without network access")
+	statusCmd.Flags().BoolVar(&statusRefresh, "refresh", false, "Read the upstream branch head from GitHub before comparing with it")
+	statusCmd.Flags().BoolVar(&statusFast, "fast", false, "Compare files by size and modification time using the stat cache, reading only files whose stat changed")
 }
 
-// getFileStatuses analyzes the working directory and returns file status information
-func getFileStatuses(workDir, ivaldiDir string, ignorePatterns []string) ([]FileStatusInfo, error) {
+// getFileStatuses analyzes the working directory and returns file status
+// information and the path name mismatches against the last seal
+func getFileStatuses(workDir, ivaldiDir string, ignorePatterns []string, nested *workspace.NestedRepos) ([]FileStatusInfo, []workspace.PathMismatch, error) {
 	var fileStatuses []FileStatusInfo
+	mismatches, err := walkFileStatuses(workDir, ivaldiDir, ignorePatterns, nested, func(info FileStatusInfo) error {
+		fileStatuses = append(fileStatuses, info)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fileStatuses, mismatches, nil
+}
+
+// walkFileStatuses analyzes the working directory, passing each file that is
+// not unchanged to emit as soon as its status is known. Deleted files are
+// reported last, in path order. When set, onScan receives the number of files
+// scanned so far. Nested repositories are skipped and recorded in nested;
+// a nil nested detects them with the configured setting. It returns the new
+// files whose names match a file of the last seal except for Unicode
+// normalization or case.
+func walkFileStatuses(workDir, ivaldiDir string, ignorePatterns []string, nested *workspace.NestedRepos, emit func(FileStatusInfo) error, onScan func(scanned int)) ([]workspace.PathMismatch, error) {
+	if nested == nil {
+		nested = workspace.NewNestedRepos(workDir, config.SkipNestedRepos())
+	}
 
 	// Get staged files
-	stagedFiles, err := getStagedFiles(ivaldiDir)
+	stagedList, err := getStagedFiles(ivaldiDir)
 	if err != nil {
 		log.Printf("Warning: Failed to get staged files: %v", err)
 	}
+	stagedFiles := make(map[string]bool, len(stagedList))
+	for _, file := range stagedList {
+		stagedFiles[file] = true
+	}
 
-	// Get known files from last snapshot (if any)
-	knownFiles, err := getKnownFiles(ivaldiDir)
+	// The stat cache lets --fast skip files a full status found unchanged
+	statChecker, err := loadStatChecker(ivaldiDir)
 	if err != nil {
+		if statusFast {
+			return nil, err
+		}
+		log.Printf("Warning: %v", err)
+	}
+
+	// Get known files from last snapshot (if any). With --fast only the
+	// seal's tree is read, not the content of its files.
+	var knownFiles map[string][32]byte
+	if statusFast {
+		knownFiles = make(map[string][32]byte, len(statChecker.Sealed))
+		for path := range statChecker.Sealed {
+			knownFiles[path] = [32]byte{}
+		}
+	} else if knownFiles, err = getKnownFiles(ivaldiDir); err != nil {
 		log.Printf("Warning: Failed to get known files: %v", err)
 	}
 
+	// Get deleted files whose removal is staged
+	removedFiles, err := getStagedRemovals(ivaldiDir)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	stagedRemovals := make(map[string]bool, len(removedFiles))
+	for _, file := range removedFiles {
+		stagedRemovals[file] = true
+	}
+
+	// Get files marked intent-to-add
+	intentFiles, err := getIntentToAddFiles(ivaldiDir)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	intentToAdd := make(map[string]bool, len(intentFiles))
+	for _, file := range intentFiles {
+		intentToAdd[file] = true
+	}
+
+	// Names are compared in the form the workspace scan records them
+	precompose := config.PrecomposeUnicode()
+
 	// Walk the working directory
+	scanned := 0
+	seen := make(map[string]bool, len(knownFiles))
+	var unknown []string
 	err = filepath.Walk(workDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories
+		// Skip the .ivaldi directory and nested repositories, but not
+		// files such as .ivaldiignore
 		if info.IsDir() {
+			if info.Name() == ".ivaldi" || nested.Skip(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -226,116 +359,152 @@ func getFileStatuses(workDir, ivaldiDir string, ignorePatterns []string) ([]File
 			return err
 		}
 
-		// Skip .ivaldi directory
-		if strings.HasPrefix(relPath, ".ivaldi") {
-			return nil
+		if precompose {
+			relPath = workspace.NormalizePath(relPath)
+		}
+
+		scanned++
+		if onScan != nil {
+			onScan(scanned)
 		}
 
 		// Check if file is ignored
 		if isIgnored(relPath, ignorePatterns) {
-			fileStatuses = append(fileStatuses, FileStatusInfo{
+			return emit(FileStatusInfo{
 				Path:   relPath,
 				Status: StatusIgnored,
 			})
-			return nil
-		}
-
-		// Check if file is staged
-		isStaged := false
-		for _, stagedFile := range stagedFiles {
-			if stagedFile == relPath {
-				isStaged = true
-				break
-			}
 		}
 
 		// Check if file was known in previous snapshot
-		wasKnown := false
-		var knownHash [32]byte
-		for filePath, hash := range knownFiles {
-			if filePath == relPath {
-				wasKnown = true
-				knownHash = hash
-				break
-			}
+		knownHash, wasKnown := knownFiles[relPath]
+		if wasKnown {
+			seen[relPath] = true
+		} else {
+			unknown = append(unknown, relPath)
 		}
 
-		if isStaged {
+		if stagedFiles[relPath] {
 			// File is staged - determine if it's new or modified
 			if wasKnown {
-				fileStatuses = append(fileStatuses, FileStatusInfo{
+				return emit(FileStatusInfo{
 					Path:   relPath,
 					Status: StatusStaged, // Modified and staged
 				})
-			} else {
-				fileStatuses = append(fileStatuses, FileStatusInfo{
-					Path:   relPath,
-					Status: StatusAdded, // New file staged
-				})
 			}
-		} else {
-			// File is not staged
-			if wasKnown {
-				// Check if file has been modified since last snapshot
+			return emit(FileStatusInfo{
+				Path:   relPath,
+				Status: StatusAdded, // New file staged
+			})
+		}
+
+		// File is not staged
+		if wasKnown {
+			// Check if file has been modified since last snapshot
+			var modified bool
+			if statusFast {
+				if modified, err = statChecker.Modified(relPath, path, info); err != nil {
+					log.Printf("Warning: %v", err)
+					return nil
+				}
+			} else {
 				currentHash, err := computeFileHash(path)
 				if err != nil {
 					log.Printf("Warning: Failed to compute hash for %s: %v", relPath, err)
 					return nil
 				}
-
-				if currentHash != knownHash {
-					fileStatuses = append(fileStatuses, FileStatusInfo{
-						Path:   relPath,
-						Status: StatusModified, // Modified but not staged
-					})
+				modified = currentHash != knownHash
+				if statChecker != nil {
+					statChecker.Record(relPath, info, !modified)
 				}
-				// If hashes match, file is unchanged (don't add to status)
-			} else {
-				// File is new and not staged
-				fileStatuses = append(fileStatuses, FileStatusInfo{
+			}
+
+			if modified {
+				return emit(FileStatusInfo{
 					Path:   relPath,
-					Status: StatusUntracked,
+					Status: StatusModified, // Modified but not staged
 				})
 			}
+			// If hashes match, file is unchanged (don't add to status)
+			return nil
 		}
 
-		return nil
+		if intentToAdd[relPath] {
+			// File is new and marked intent-to-add
+			return emit(FileStatusInfo{
+				Path:   relPath,
+				Status: StatusIntentToAdd,
+			})
+		}
+
+		// File is new and not staged
+		return emit(FileStatusInfo{
+			Path:   relPath,
+			Status: StatusUntracked,
+		})
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	if statChecker != nil {
+		if err := statChecker.Cache.Save(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		statusApproximate = statChecker.Approximate
+	}
+
 	// Check for deleted files (files that were known but no longer exist)
+	var deleted, unseen []string
 	for filePath := range knownFiles {
-		fullPath := filepath.Join(workDir, filePath)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			// File was deleted
-			isStaged := false
-			for _, stagedFile := range stagedFiles {
-				if stagedFile == filePath {
-					isStaged = true
-					break
-				}
-			}
+		if seen[filePath] {
+			continue
+		}
+		unseen = append(unseen, filePath)
+		if _, err := os.Stat(filepath.Join(workDir, filePath)); os.IsNotExist(err) {
+			deleted = append(deleted, filePath)
+		}
+	}
+	sort.Strings(deleted)
 
-			if isStaged {
-				// Deletion is staged
-				fileStatuses = append(fileStatuses, FileStatusInfo{
-					Path:   filePath,
-					Status: StatusStaged, // Deletion staged
-				})
-			} else {
-				// Deletion not staged
-				fileStatuses = append(fileStatuses, FileStatusInfo{
-					Path:   filePath,
-					Status: StatusDeleted,
-				})
-			}
+	for _, filePath := range deleted {
+		status := StatusDeleted
+		if stagedRemovals[filePath] {
+			status = StatusRemoved // Deletion staged
+		}
+		if err := emit(FileStatusInfo{Path: filePath, Status: status}); err != nil {
+			return nil, err
 		}
 	}
 
-	return fileStatuses, nil
+	return workspace.FindPathMismatches(unknown, unseen), nil
+}
+
+// printPathMismatches warns about new files that are the same as a sealed
+// file except for Unicode normalization or case. Such names show up as a
+// phantom add/remove pair, typically after moving between macOS and other
+// platforms.
+func printPathMismatches(mismatches []workspace.PathMismatch) {
+	if len(mismatches) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s\n", colors.SectionHeader("Path name mismatches:"))
+	fmt.Printf("  %s\n", colors.Dim("(these files match a sealed file whose name is spelled differently)"))
+	normalization := false
+	for _, mismatch := range mismatches {
+		label := "case:         "
+		if mismatch.Kind == workspace.MismatchNormalization {
+			label = "normalization:"
+			normalization = true
+		}
+		fmt.Printf("  %s %s %s\n", colors.Yellow(label), mismatch.Path,
+			colors.Gray(fmt.Sprintf("(sealed as %s)", mismatch.Committed)))
+	}
+	if normalization && !config.PrecomposeUnicode() {
+		statusHint("(use \"ivaldi config core.precomposeUnicode true\" to record names in NFC form)")
+	}
 }
 
 // getStagedFiles returns a list of files that are currently staged
@@ -457,9 +626,64 @@ func getKnownFiles(ivaldiDir string) (map[string][32]byte, error) {
 	return knownFiles, nil
 }
 
-// computeFileHash computes the BLAKE3 hash of a file
+// loadStatChecker prepares comparing workspace files with the last seal of
+// the current timeline through the stat cache
+func loadStatChecker(ivaldiDir string) (*workspace.StatChecker, error) {
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	sealed, err := getSealedFileRefs(casStore, ivaldiDir)
+	if err != nil {
+		return nil, err
+	}
+	return workspace.NewStatChecker(casStore, ivaldiDir, sealed), nil
+}
+
+// getSealedFileRefs returns the content references of the files of the
+// last seal on the current timeline, without reading the files
+func getSealedFileRefs(casStore cas.CAS, ivaldiDir string) (map[string]filechunk.NodeRef, error) {
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	currentTimeline, err := refsManager.GetCurrentTimeline()
+	if err != nil {
+		return nil, nil // No current timeline
+	}
+	timeline, err := refsManager.GetTimeline(currentTimeline, refs.LocalTimeline)
+	if err != nil || timeline.Blake3Hash == [32]byte{} {
+		return nil, nil // No seals yet
+	}
+
+	commitReader := commit.NewCommitReader(casStore)
+	commitObj, err := commitReader.ReadCommit(cas.Hash(timeline.Blake3Hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last seal: %w", err)
+	}
+	return commitReader.FileRefs(commitObj)
+}
+
+// printApproximateNote tells that --fast judged some files by size alone
+func printApproximateNote() {
+	if statusApproximate == 0 {
+		return
+	}
+	files := "files"
+	if statusApproximate == 1 {
+		files = "file"
+	}
+	fmt.Printf("\n%s\n", colors.Dim(fmt.Sprintf(
+		"Approximate: %d %s not yet in the stat cache compared by size only.\n"+
+			"Run 'ivaldi status' without --fast once to fill the cache.", statusApproximate, files)))
+}
+
+// computeFileHash computes the BLAKE3 hash of a file, or of the target of
+// a symbolic link
 func computeFileHash(filePath string) ([32]byte, error) {
-	content, err := os.ReadFile(filePath)
+	content, err := workspace.ReadFileContent(filePath)
 	if err != nil {
 		return [32]byte{}, err
 	}
@@ -489,8 +713,12 @@ func displayLastSealInfo(refsManager *refs.RefsManager, currentTimeline, ivaldiD
 		fmt.Printf("Last seal: %s\n", colors.Cyan(shortHash))
 	}
 
-	// Get files from the last commit
-	knownFiles, err := getKnownFiles(ivaldiDir)
+	// Count the files of the last commit from its tree alone
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return err
+	}
+	knownFiles, err := getSealedFileRefs(casStore, ivaldiDir)
 	if err != nil {
 		return err
 	}
@@ -502,6 +730,269 @@ func displayLastSealInfo(refsManager *refs.RefsManager, currentTimeline, ivaldiD
 	return nil
 }
 
+// upstreamRefreshTimeout bounds how long status waits for the remote branch head
+const upstreamRefreshTimeout = 3 * time.Second
+
+// displayUpstreamStatus reports how many seals the timeline is ahead of and
+// behind its upstream branch. Nothing is shown until a remote head has been
+// recorded by download, upload, sync or harvest. That head is used as is,
+// unless --refresh or status.refreshUpstream asks to read it from GitHub;
+// offline the last known head is used then.
+func displayUpstreamStatus(refsManager *refs.RefsManager, ivaldiDir, workDir, currentTimeline string) {
+	upstreamRemote, branch := uploadTarget(currentTimeline)
+
+	var owner, repo string
+	if parts := strings.Split(upstreamRemote, "/"); len(parts) == 2 {
+		owner, repo = parts[0], parts[1]
+	} else {
+		var err error
+		owner, repo, err = refsManager.GetGitHubRepository()
+		if err != nil {
+			return
+		}
+	}
+
+	recorded, err := refsManager.GetTimeline(branch, refs.RemoteTimeline)
+	if err != nil || recorded.GitSHA1Hash == "" {
+		return
+	}
+
+	local, err := refsManager.GetTimeline(currentTimeline, refs.LocalTimeline)
+	if err != nil || local.Blake3Hash == [32]byte{} {
+		return
+	}
+
+	upstreamName := fmt.Sprintf("%s/%s:%s", owner, repo, branch)
+
+	// Refresh the remote head if asked to, falling back to the recorded one
+	remoteSHA := recorded.GitSHA1Hash
+	note := ""
+	if statusRefresh || config.StatusRefreshUpstream() {
+		offline := true
+		if syncer, err := github.NewRepoSyncer(ivaldiDir, workDir); err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), upstreamRefreshTimeout)
+			defer cancel()
+			if sha, err := syncer.RefreshRemoteHead(ctx, owner, repo, branch); err == nil {
+				remoteSHA = sha
+				offline = false
+			}
+		}
+		if offline {
+			note = colors.Gray(" (offline, using last known remote head)")
+		}
+	}
+
+	// Find the local commit the remote head corresponds to
+	var remoteHash [32]byte
+	if remoteSHA == recorded.GitSHA1Hash && recorded.Blake3Hash != [32]byte{} {
+		remoteHash = recorded.Blake3Hash
+	} else if hash, _, err := refsManager.LookupByGitHash(remoteSHA); err == nil {
+		remoteHash = hash
+	} else {
+		fmt.Printf("Upstream %s: %s%s\n", colors.Bold(upstreamName),
+			colors.Yellow(fmt.Sprintf("has new commits (run 'ivaldi harvest %s' to fetch them)", branch)), note)
+		return
+	}
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return
+	}
+
+	var localHead, remoteHead cas.Hash
+	copy(localHead[:], local.Blake3Hash[:])
+	copy(remoteHead[:], remoteHash[:])
+
+	commitReader := commit.NewCommitReader(casStore)
+	ahead, behind, err := commitReader.AheadBehind(localHead, remoteHead)
+	if err != nil {
+		fmt.Printf("Upstream %s: %s%s\n", colors.Bold(upstreamName), colors.Gray("unable to compare history"), note)
+		return
+	}
+
+	if ahead == 0 && behind == 0 {
+		fmt.Printf("Upstream %s: %s%s\n", colors.Bold(upstreamName), colors.SuccessText("up to date"), note)
+		return
+	}
+
+	fmt.Printf("Upstream %s: ahead %s, behind %s%s\n", colors.Bold(upstreamName),
+		colors.Green(fmt.Sprintf("%d", ahead)), colors.Red(fmt.Sprintf("%d", behind)), note)
+}
+
+// displayUpstreamLine shows the upstream line, checked against GitHub or,
+// with --ahead-of-remote, estimated offline
+func displayUpstreamLine(refsManager *refs.RefsManager, ivaldiDir, workDir, currentTimeline string) {
+	if statusAheadOfRemote {
+		displayOfflineAheadStatus(refsManager, ivaldiDir, currentTimeline)
+		return
+	}
+	displayUpstreamStatus(refsManager, ivaldiDir, workDir, currentTimeline)
+}
+
+// displayOfflineAheadStatus reports the seals made on the timeline since the
+// remote head recorded when it was last uploaded or synced. Nothing is read
+// from the network, so commits pushed by others are not known.
+func displayOfflineAheadStatus(refsManager *refs.RefsManager, ivaldiDir, currentTimeline string) {
+	local, err := refsManager.GetTimeline(currentTimeline, refs.LocalTimeline)
+	if err != nil || local.Blake3Hash == [32]byte{} {
+		return
+	}
+
+	// The remote head of the upstream branch, or else the SHA the timeline
+	// itself recorded at its last upload
+	_, branch := uploadTarget(currentTimeline)
+	var gitSHA string
+	var remoteHash [32]byte
+	if recorded, err := refsManager.GetTimeline(branch, refs.RemoteTimeline); err == nil && recorded.GitSHA1Hash != "" {
+		gitSHA, remoteHash = recorded.GitSHA1Hash, recorded.Blake3Hash
+	} else if local.GitSHA1Hash != "" {
+		gitSHA = local.GitSHA1Hash
+	}
+
+	label := colors.Gray("(based on last-known remote state)")
+	if gitSHA == "" {
+		fmt.Printf("Remote %s: %s\n", colors.Bold(branch), colors.Gray("no remote state recorded yet"))
+		return
+	}
+	remoteName := fmt.Sprintf("%s@%s", branch, gitSHA[:min(7, len(gitSHA))])
+
+	if remoteHash == [32]byte{} {
+		hash, _, err := refsManager.LookupByGitHash(gitSHA)
+		if err != nil {
+			fmt.Printf("Remote %s: %s %s\n", colors.Bold(remoteName), colors.Yellow("not mapped to a local seal"), label)
+			return
+		}
+		remoteHash = hash
+	}
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return
+	}
+
+	var localHead, remoteHead cas.Hash
+	copy(localHead[:], local.Blake3Hash[:])
+	copy(remoteHead[:], remoteHash[:])
+
+	ahead, behind, err := commit.NewCommitReader(casStore).AheadBehind(localHead, remoteHead)
+	if err != nil {
+		fmt.Printf("Remote %s: %s %s\n", colors.Bold(remoteName), colors.Gray("unable to compare history"), label)
+		return
+	}
+
+	switch {
+	case ahead == 0 && behind == 0:
+		fmt.Printf("Remote %s: %s %s\n", colors.Bold(remoteName), colors.SuccessText("no unpushed seals"), label)
+	case ahead == 0:
+		fmt.Printf("Remote %s: %s %s\n", colors.Bold(remoteName),
+			colors.Yellow(fmt.Sprintf("local is %d seal(s) behind", behind)), label)
+	default:
+		diverged := ""
+		if behind > 0 {
+			diverged = fmt.Sprintf(", and lacks %d seal(s) of the remote", behind)
+		}
+		fmt.Printf("Remote %s: %s %s\n", colors.Bold(remoteName),
+			colors.Green(fmt.Sprintf("local has unpushed changes, %d seal(s) since the last push or sync%s", ahead, diverged)), label)
+	}
+}
+
+// displayOperationInProgress reports a fuse that stopped on conflicts,
+// listing the conflicted files and the commands that finish or cancel it
+func displayOperationInProgress(ivaldiDir string) {
+	if !isMergeInProgress(ivaldiDir) {
+		return
+	}
+
+	fmt.Println()
+	if state, err := loadMergeState(ivaldiDir); err == nil {
+		fmt.Printf("%s fusing %s into %s\n", colors.Yellow("Fuse in progress:"),
+			colors.Bold(state.SourceTimeline), colors.Bold(state.TargetTimeline))
+	} else {
+		fmt.Println(colors.Yellow("Fuse in progress"))
+	}
+
+	conflicts, err := loadMergeConflicts(ivaldiDir)
+	if err != nil {
+		log.Printf("Warning: Failed to read merge conflicts: %v", err)
+	}
+
+	// Files resolved since the fuse stopped are recorded in the resolution
+	resolved := make(map[string]bool)
+	if resolution, err := diffmerge.NewResolutionStorage(ivaldiDir).Load(); err == nil && resolution != nil {
+		for path, file := range resolution.Files {
+			if file.Resolved {
+				resolved[path] = true
+			}
+		}
+	}
+
+	var unresolved []string
+	for _, path := range conflicts {
+		if !resolved[path] {
+			unresolved = append(unresolved, path)
+		}
+	}
+
+	if len(unresolved) > 0 {
+		statusHint("(resolve the conflicts and run \"ivaldi fuse --continue\")")
+	} else {
+		statusHint("(all conflicts resolved: run \"ivaldi fuse --continue\" to conclude the fuse)")
+	}
+	statusHint("(use \"ivaldi fuse --abort\" to cancel the fuse)")
+
+	if len(unresolved) > 0 {
+		fmt.Printf("\n%s\n", colors.SectionHeader("Unresolved conflicts:"))
+		for _, path := range unresolved {
+			fmt.Printf("  %s   %s\n", colors.Slot(colors.StatusUnmerged, "both modified:"), colors.Slot(colors.StatusUnmerged, path))
+		}
+	}
+}
+
+// displayStashSummary reminds the user of the stashes and auto-shelves
+// that are kept, if status.showStash is set
+func displayStashSummary(ivaldiDir, workDir string) {
+	if !config.StatusShowStash() {
+		return
+	}
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		log.Printf("Warning: Failed to initialize storage: %v", err)
+		return
+	}
+	stashes, err := workspace.NewStashManager(workspace.NewMaterializer(casStore, ivaldiDir, workDir)).ListStashes()
+	if err != nil {
+		log.Printf("Warning: Failed to list stashes: %v", err)
+	}
+	shelves, err := shelf.NewShelfManager(casStore, ivaldiDir).ListShelves()
+	if err != nil {
+		log.Printf("Warning: Failed to list shelves: %v", err)
+	}
+
+	// Each timeline keeps at most one auto-shelf that is restored
+	shelved := make(map[string]bool)
+	var timelines []string
+	for _, s := range shelves {
+		if s.AutoCreated && !shelved[s.TimelineName] {
+			shelved[s.TimelineName] = true
+			timelines = append(timelines, s.TimelineName)
+		}
+	}
+	sort.Strings(timelines)
+
+	if len(stashes) > 0 {
+		noun := "stashes"
+		if len(stashes) == 1 {
+			noun = "stash"
+		}
+		fmt.Printf("You have %d %s %s\n", len(stashes), noun, colors.Dim("(use \"ivaldi stash list\" for details)"))
+	}
+	if len(timelines) > 0 {
+		fmt.Printf("Auto-shelved changes on %s %s\n", strings.Join(timelines, ", "),
+			colors.Dim("(restored when switching to the timeline)"))
+	}
+}
+
 // isIgnored checks if a file path matches any ignore patterns
 func isIgnored(path string, patterns []string) bool {
 	for _, pattern := range patterns {
@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
+	"golang.org/x/term"
+)
+
+var (
+	statusStream   bool
+	statusLimit    int
+	statusExitCode bool
+	statusQuiet    bool
+
+	statusAheadOfRemote bool
+	statusRefresh       bool
+
+	statusFast bool
+	// statusApproximate counts the files --fast judged by size alone
+	statusApproximate int
+)
+
+// statusCounts tallies file statuses for the summary line
+type statusCounts struct {
+	staged    int
+	modified  int
+	deleted   int
+	untracked int
+}
+
+func (c *statusCounts) add(status FileStatus) {
+	switch status {
+	case StatusStaged, StatusAdded, StatusRemoved:
+		c.staged++
+	case StatusModified, StatusIntentToAdd:
+		c.modified++
+	case StatusDeleted:
+		c.deleted++
+	case StatusUntracked:
+		c.untracked++
+	}
+}
+
+func (c *statusCounts) total() int {
+	return c.staged + c.modified + c.deleted + c.untracked
+}
+
+// printSummary prints the one-line status summary
+func (c *statusCounts) printSummary() {
+	fmt.Printf("\n%s ", colors.SectionHeader("Status summary:"))
+	var parts []string
+	if c.staged > 0 {
+		parts = append(parts, colors.Added(fmt.Sprintf("%d staged", c.staged)))
+	}
+	if c.modified > 0 {
+		parts = append(parts, colors.Modified(fmt.Sprintf("%d modified", c.modified)))
+	}
+	if c.untracked > 0 {
+		parts = append(parts, colors.Untracked(fmt.Sprintf("%d untracked", c.untracked)))
+	}
+	if c.deleted > 0 {
+		parts = append(parts, colors.Deleted(fmt.Sprintf("%d deleted", c.deleted)))
+	}
+
+	if len(parts) > 0 {
+		fmt.Printf("%s\n", strings.Join(parts, ", "))
+	} else {
+		fmt.Printf("%s\n", colors.SuccessText("clean"))
+	}
+}
+
+// statusLimiter caps the number of file entries status prints. A limit of
+// zero or less prints everything.
+type statusLimiter struct {
+	limit  int
+	shown  int
+	hidden int
+}
+
+// full reports whether no more entries may be printed
+func (l *statusLimiter) full() bool {
+	return l.limit > 0 && l.shown >= l.limit
+}
+
+// allow records one entry, reporting whether it may be printed
+func (l *statusLimiter) allow() bool {
+	if l.full() {
+		l.hidden++
+		return false
+	}
+	l.shown++
+	return true
+}
+
+// printHidden notes how many entries were left out
+func (l *statusLimiter) printHidden() {
+	if l.hidden > 0 {
+		fmt.Printf("\n%s\n", colors.Dim(fmt.Sprintf("... %d more file(s) not shown (use --limit 0 to show all)", l.hidden)))
+	}
+}
+
+// printNestedRepos lists the nested repositories a status walk left out.
+// Their files belong to their own repository and are neither tracked nor
+// counted as changes.
+func printNestedRepos(repos []workspace.NestedRepo) {
+	if len(repos) == 0 {
+		return
+	}
+	fmt.Printf("\n%s\n", colors.SectionHeader("Nested repositories:"))
+	fmt.Printf("  %s\n", colors.Dim("(not scanned; set core.nestedRepos to include to track their files)"))
+	for _, repo := range repos {
+		kind := repo.Kind + " repository"
+		if repo.Submodule {
+			kind = "submodule"
+		}
+		fmt.Printf("  %s %s\n", colors.Slot(colors.StatusUntracked, repo.Path+"/"), colors.Dim("("+kind+")"))
+	}
+}
+
+// printStatusSection prints a titled group of files, subject to the limiter.
+// A group with no room left is counted as hidden without printing its title.
+func printStatusSection(limiter *statusLimiter, title, hint string, files []FileStatusInfo, format func(FileStatusInfo) string) {
+	if len(files) == 0 {
+		return
+	}
+	if limiter.full() {
+		limiter.hidden += len(files)
+		return
+	}
+
+	fmt.Printf("\n%s\n", colors.SectionHeader(title))
+	if hint != "" {
+		statusHint(hint)
+	}
+	for _, file := range files {
+		if limiter.allow() {
+			fmt.Println(format(file))
+		}
+	}
+}
+
+// shortStatus returns a two-column status code: the first column describes
+// the staging area, the second the working directory
+func shortStatus(status FileStatus) string {
+	switch status {
+	case StatusAdded:
+		return colors.Added("A ")
+	case StatusStaged:
+		return colors.Added("M ")
+	case StatusRemoved:
+		return colors.Added("D ")
+	case StatusModified:
+		return colors.Modified(" M")
+	case StatusIntentToAdd:
+		return colors.Modified(" A")
+	case StatusDeleted:
+		return colors.Deleted(" D")
+	case StatusUntracked:
+		return colors.Untracked("??")
+	case StatusIgnored:
+		return colors.Ignored("!!")
+	}
+	return "  "
+}
+
+// statusProgress shows a running count on stderr while the workspace is
+// scanned. It is nil, and does nothing, when stderr is not a terminal.
+type statusProgress struct {
+	last   time.Time
+	active bool
+}
+
+func newStatusProgress() *statusProgress {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	return &statusProgress{}
+}
+
+func (p *statusProgress) update(scanned, changes int) {
+	if p == nil || time.Since(p.last) < 100*time.Millisecond {
+		return
+	}
+	p.last = time.Now()
+	fmt.Fprintf(os.Stderr, "\rScanning: %d files, %d changes", scanned, changes)
+	p.active = true
+}
+
+// clear erases the progress line so regular output starts on a clean line
+func (p *statusProgress) clear() {
+	if p == nil || !p.active {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K")
+	p.active = false
+}
+
+// streamStatus prints changes as the workspace scan finds them instead of
+// collecting them first, so large workspaces give immediate feedback and
+// memory does not grow with the number of changes. It reports whether any
+// file is staged, modified, deleted or untracked.
+func streamStatus(workDir, ivaldiDir string, ignorePatterns []string, showIgnored bool, limit int, nested *workspace.NestedRepos) (bool, error) {
+	limiter := &statusLimiter{limit: limit}
+	progress := newStatusProgress()
+	var counts statusCounts
+	headerShown := false
+
+	emit := func(info FileStatusInfo) error {
+		if info.Status == StatusIgnored && !showIgnored {
+			return nil
+		}
+		counts.add(info.Status)
+		if !limiter.allow() {
+			return nil
+		}
+
+		progress.clear()
+		if !headerShown {
+			fmt.Printf("\n%s\n", colors.SectionHeader("Changes:"))
+			headerShown = true
+		}
+		fmt.Printf("  %s %s\n", shortStatus(info.Status), info.Path)
+		return nil
+	}
+
+	mismatches, err := walkFileStatuses(workDir, ivaldiDir, ignorePatterns, nested, emit, func(scanned int) {
+		progress.update(scanned, counts.total())
+	})
+	progress.clear()
+	if err != nil {
+		return false, fmt.Errorf("failed to get file statuses: %w", err)
+	}
+
+	if counts.total() == 0 && !headerShown {
+		fmt.Println(colors.SuccessText("Working directory clean"))
+		printNestedRepos(nested.Found())
+		printApproximateNote()
+		return false, nil
+	}
+
+	limiter.printHidden()
+	printNestedRepos(nested.Found())
+	printPathMismatches(mismatches)
+	counts.printSummary()
+	printApproximateNote()
+	return counts.total() > 0, nil
+}
@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/converter"
+	"github.com/javanhut/Ivaldi-vcs/internal/submodule"
+	"github.com/spf13/cobra"
+)
+
+var submoduleCmd = &cobra.Command{
+	Use:   "submodule",
+	Short: "List, update and sync submodules",
+	Long: `Manage the submodules declared in .ivaldimodules.
+
+Submodules are converted from Git automatically when a repository with a
+.gitmodules file is downloaded or forged. These commands keep them up to date
+afterwards. The settings of this clone are kept in .ivaldi/submodules; like the
+submodule entries of .git/config they start as a copy of .ivaldimodules, and a
+URL recorded there overrides the declared one.`,
+}
+
+var submoduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the submodules and their pinned commits",
+	Long: `List the submodules with the commit each is pinned to. The first column
+shows the state of the checkout:
+
+  ' '  the pinned commit is checked out
+  '+'  a different commit is checked out
+  '-'  the submodule is not checked out (see 'ivaldi submodule update')`,
+	Args: cobra.NoArgs,
+	RunE: runSubmoduleList,
+}
+
+var submoduleUpdateCmd = &cobra.Command{
+	Use:   "update [path...]",
+	Short: "Check out submodules at their pinned commits",
+	Long: `Update clones submodules that are not checked out, fetches the others, and
+checks out the commit each is pinned to. The history of each updated submodule
+is then converted into .ivaldi/modules/<path>. Without paths all submodules are
+updated; frozen submodules are only cloned when missing.`,
+	RunE: runSubmoduleUpdate,
+}
+
+var submoduleSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Copy submodule URLs from .ivaldimodules into this clone",
+	Long: `Sync replaces the submodule settings of this clone with those declared in
+.ivaldimodules, and points the checkout of each submodule at its declared URL.
+Run it after the URL of a submodule changed upstream.`,
+	Args: cobra.NoArgs,
+	RunE: runSubmoduleSync,
+}
+
+func init() {
+	submoduleCmd.AddCommand(submoduleListCmd, submoduleUpdateCmd, submoduleSyncCmd)
+}
+
+func runSubmoduleList(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	configs, err := submodule.Load(ivaldiDir, workDir)
+	if err != nil {
+		return fmt.Errorf("failed to load submodules: %w", err)
+	}
+	if len(configs) == 0 {
+		fmt.Println("No submodules configured")
+		return nil
+	}
+
+	for _, cfg := range configs {
+		marker, pinned := " ", pinnedCommit(cfg)
+		submodulePath := filepath.Join(workDir, filepath.FromSlash(cfg.Path))
+		if _, err := os.Stat(filepath.Join(submodulePath, ".git")); err == nil {
+			head, err := converter.GitSubmoduleHead(submodulePath)
+			if err == nil && cfg.GitCommit != "" && head != cfg.GitCommit {
+				marker = colors.Yellow("+")
+			}
+		} else if _, err := os.Stat(filepath.Join(submodulePath, ".ivaldi")); err != nil {
+			marker = colors.Red("-")
+		}
+
+		line := fmt.Sprintf("%s%s %s %s", marker, pinned, colors.Bold(cfg.Path), colors.Dim("("+cfg.URL+")"))
+		if cfg.Freeze {
+			line += " " + colors.Dim("[frozen]")
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func runSubmoduleUpdate(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+	if err := requireWorkTree("submodule update"); err != nil {
+		return err
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	configs, err := submodule.Load(ivaldiDir, workDir)
+	if err != nil {
+		return fmt.Errorf("failed to load submodules: %w", err)
+	}
+	if len(configs) == 0 {
+		fmt.Println("No submodules configured")
+		return nil
+	}
+
+	// The first update records the settings of this clone
+	if _, err := os.Stat(submodule.LocalConfigPath(ivaldiDir)); os.IsNotExist(err) {
+		if err := submodule.SaveLocal(ivaldiDir, configs); err != nil {
+			return err
+		}
+	}
+
+	selected := make(map[string]bool, len(args))
+	for _, arg := range args {
+		selected[filepath.ToSlash(filepath.Clean(arg))] = true
+	}
+
+	failed := 0
+	for _, cfg := range configs {
+		if len(selected) > 0 && !selected[cfg.Path] {
+			continue
+		}
+		delete(selected, cfg.Path)
+
+		submodulePath := filepath.Join(workDir, filepath.FromSlash(cfg.Path))
+		_, statErr := os.Stat(filepath.Join(submodulePath, ".git"))
+		checkedOut := statErr == nil
+		if cfg.Freeze && checkedOut {
+			fmt.Printf("Submodule '%s' is frozen, skipping\n", cfg.Path)
+			continue
+		}
+		if cfg.GitCommit == "" && cfg.Commit != "" {
+			fmt.Printf("%s submodule '%s' is pinned to an Ivaldi seal; only Git submodules can be updated\n",
+				colors.Red("Error:"), cfg.Path)
+			failed++
+			continue
+		}
+
+		cloned, err := converter.UpdateGitSubmodule(cfg.URL, cfg.Path, workDir, cfg.GitCommit)
+		if err != nil {
+			fmt.Printf("%s failed to update submodule '%s': %v\n", colors.Red("Error:"), cfg.Path, err)
+			failed++
+			continue
+		}
+		if cloned {
+			fmt.Printf("Cloned submodule '%s' from %s\n", cfg.Path, cfg.URL)
+		}
+
+		if err := converter.ImportGitSubmodule(submodulePath, submodule.ModuleDir(ivaldiDir, cfg.Path)); err != nil {
+			fmt.Printf("%s failed to import submodule '%s': %v\n", colors.Red("Error:"), cfg.Path, err)
+			failed++
+			continue
+		}
+
+		head, err := converter.GitSubmoduleHead(submodulePath)
+		if err != nil {
+			head = cfg.GitCommit
+		}
+		fmt.Printf("%s Submodule '%s' at %s\n", colors.SuccessText("[OK]"), cfg.Path, shortCommit(head))
+	}
+
+	for path := range selected {
+		fmt.Printf("%s '%s' is not a submodule\n", colors.Red("Error:"), path)
+		failed++
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to update %d submodule(s)", failed)
+	}
+	return nil
+}
+
+func runSubmoduleSync(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	changes, configs, err := submodule.Sync(ivaldiDir, workDir)
+	if err != nil {
+		return fmt.Errorf("failed to sync submodules: %w", err)
+	}
+
+	for _, change := range changes {
+		switch {
+		case change.OldURL == "":
+			fmt.Printf("Registered submodule '%s' (%s)\n", change.Path, change.NewURL)
+		case change.NewURL == "":
+			fmt.Printf("Dropped submodule '%s', no longer declared\n", change.Path)
+		default:
+			fmt.Printf("Synchronized URL of submodule '%s': %s\n", change.Path, change.NewURL)
+		}
+	}
+
+	// Point existing checkouts at the declared URLs
+	for _, cfg := range configs {
+		submodulePath := filepath.Join(workDir, filepath.FromSlash(cfg.Path))
+		if _, err := os.Stat(filepath.Join(submodulePath, ".git")); err != nil {
+			continue
+		}
+		if err := converter.SetGitSubmoduleURL(submodulePath, cfg.URL); err != nil {
+			return fmt.Errorf("failed to update the URL of submodule '%s': %w", cfg.Path, err)
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("Submodules are in sync")
+	}
+	return nil
+}
+
+// pinnedCommit returns the short commit a submodule is pinned to
+func pinnedCommit(cfg submodule.Config) string {
+	switch {
+	case cfg.GitCommit != "":
+		return shortCommit(cfg.GitCommit)
+	case cfg.Commit != "":
+		return shortCommit(cfg.Commit)
+	default:
+		return colors.Dim("(unpinned)")
+	}
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
@@ -2,14 +2,21 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"time"
 
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
 	"github.com/javanhut/Ivaldi-vcs/internal/github"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
 	"github.com/spf13/cobra"
 )
 
@@ -20,16 +27,31 @@ var syncCmd = &cobra.Command{
 Downloads only the delta of changes (added/removed files) from the remote
 and updates the local timeline to match.
 
+When the timeline has seals that are not on the remote and the remote has
+moved on as well, the two have diverged. pull.rebase selects what sync does
+then: false, the default, imports the remote history and fuses it into the
+timeline with a merge seal, using the timeline's merge strategy as fuse
+does. Conflicts stop the merge for 'ivaldi fuse --resolve' and
+'ivaldi fuse --continue'. true would rebase the local seals onto the remote
+head, which ivaldi cannot do yet, so sync refuses to run with it.
+
+A timeline that only has seals of its own is left alone; upload them with
+'ivaldi upload'.
+
 Examples:
   ivaldi sync                    # Sync current timeline with remote
   ivaldi sync main               # Sync specific timeline with remote`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
 
+		if err := requireWorkTree("sync"); err != nil {
+			return err
+		}
+
 		workDir, err := os.Getwd()
 		if err != nil {
 			return fmt.Errorf("failed to get working directory: %w", err)
@@ -79,6 +101,24 @@ Examples:
 		fmt.Printf("Syncing timeline '%s' with %s/%s...\n\n",
 			colors.Bold(timelineToSync), owner, repo)
 
+		divergence, err := syncer.CheckDivergence(ctx, owner, repo, timelineToSync, cas.Hash(timeline.Blake3Hash))
+		if err != nil {
+			return fmt.Errorf("failed to sync timeline: %w", err)
+		}
+		if divergence.Diverged() {
+			// Rebasing is not available; fast-forwards need neither
+			if config.PullRebase() {
+				return fmt.Errorf("pull.rebase is true, but ivaldi cannot rebase seals yet. Run 'ivaldi config pull.rebase false' to merge diverged timelines instead")
+			}
+			return syncMerge(ctx, syncer, ivaldiDir, workDir, owner, repo, timelineToSync, cas.Hash(timeline.Blake3Hash), divergence.RemoteSHA)
+		}
+		if divergence.LocalAhead {
+			fmt.Printf("%s Timeline '%s' has seals that are not on the remote, and the remote has nothing new\n",
+				colors.Green("✓"), colors.Bold(timelineToSync))
+			fmt.Println("Use 'ivaldi upload' to push them")
+			return nil
+		}
+
 		// Perform sync and get delta information
 		delta, err := syncer.SyncTimeline(ctx, owner, repo, timelineToSync, timeline.Blake3Hash)
 		if err != nil {
@@ -130,3 +170,120 @@ Examples:
 		return nil
 	},
 }
+
+// syncMerge fuses the history of a remote branch into a local timeline that
+// has diverged from it, the merge mode of pull.rebase
+func syncMerge(ctx context.Context, syncer *github.RepoSyncer, ivaldiDir, workDir, owner, repo, timelineName string, localHash cas.Hash, remoteSHA string) error {
+	fmt.Printf("Timeline '%s' and %s/%s have diverged; %s (pull.rebase is false)\n\n",
+		colors.Bold(timelineName), owner, repo, colors.Bold("merging"))
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	// The merge is made in the workspace, so it must be the timeline's own
+	// and hold nothing that would be lost
+	if current, err := refsManager.GetCurrentTimeline(); err != nil || current != timelineName {
+		return fmt.Errorf("timeline '%s' must be checked out to merge its remote changes", timelineName)
+	}
+	if isMergeInProgress(ivaldiDir) {
+		return fmt.Errorf("merge already in progress. Use 'ivaldi fuse --continue' or 'ivaldi fuse --abort'")
+	}
+	materializer := workspace.NewMaterializer(casStore, ivaldiDir, workDir)
+	if dirty, err := workspaceHasChanges(materializer); err != nil {
+		return err
+	} else if dirty {
+		return fmt.Errorf("the workspace has uncommitted changes. Seal or stash them before syncing a diverged timeline")
+	}
+
+	fetched, err := syncer.FetchHistory(ctx, owner, repo, timelineName, 0)
+	if err != nil {
+		return fmt.Errorf("failed to import remote history: %w", err)
+	}
+	fmt.Printf("Imported %d remote commit(s), tip %s\n", fetched.Imported, shortSHA(fetched.TipSHA))
+	remoteHash := fetched.TipHash
+
+	commitReader := commit.NewCommitReader(casStore)
+	localCommit, err := commitReader.ReadCommit(localHash)
+	if err != nil {
+		return fmt.Errorf("failed to read local commit: %w", err)
+	}
+	remoteCommit, err := commitReader.ReadCommit(remoteHash)
+	if err != nil {
+		return fmt.Errorf("failed to read remote commit: %w", err)
+	}
+	baseHash, err := findMergeBase(ivaldiDir, casStore, localHash, remoteHash)
+	hasBase := err == nil
+	if err != nil && !errors.Is(err, commit.ErrNoMergeBase) {
+		return fmt.Errorf("failed to find merge base: %w", err)
+	}
+
+	strategy := diffmerge.StrategyType("auto")
+	if configured, _, err := config.GetMergeStrategy(timelineName); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	} else if configured != "" {
+		strategy = diffmerge.StrategyType(configured)
+	}
+
+	source := fmt.Sprintf("%s/%s@%s", owner, repo, shortSHA(remoteSHA))
+	_, mergeResult, err := mergeCommits(casStore, workDir, remoteCommit, localCommit, baseHash, hasBase, strategy)
+	if err != nil {
+		return err
+	}
+	if !mergeResult.Success {
+		err := pauseMerge(ivaldiDir, &MergeState{
+			SourceTimeline: source,
+			TargetTimeline: timelineName,
+			SourceHash:     remoteHash,
+			TargetHash:     localHash,
+			Conflicts:      mergeResult.Conflicts,
+		}, strategy)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n%s Merge conflicts detected:\n\n", colors.Yellow("[CONFLICTS]"))
+		for _, conflict := range mergeResult.Conflicts {
+			fmt.Printf("  %s %s\n", colors.Red("CONFLICT:"), colors.Bold(conflict.Path))
+		}
+		advise(config.AdviceMergeConflict, func() {
+			fmt.Println()
+			fmt.Printf("  %s - Record an edited file as resolved\n", colors.Cyan("ivaldi gather <file>"))
+			fmt.Printf("  %s - Resolve a file with the remote version\n", colors.Cyan("ivaldi fuse --resolve --strategy=theirs <file>"))
+			fmt.Printf("  %s - Create the merge seal once all files are resolved\n", colors.Cyan("ivaldi fuse --continue"))
+			fmt.Printf("  %s - Abort the merge\n", colors.Red("ivaldi fuse --abort"))
+		})
+		return nil
+	}
+
+	author, err := getAuthorFromConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get author: %w", err)
+	}
+	sealName, err := sealMerge(ivaldiDir, casStore, refsManager, timelineName, *mergeResult.MergedIndex,
+		localHash, remoteHash, author, fmt.Sprintf("Fuse %s into %s", source, timelineName))
+	if err != nil {
+		return err
+	}
+	if err := materializer.MaterializeTimelineWithAutoShelf(timelineName, false); err != nil {
+		return fmt.Errorf("merge seal %s was created but the workspace was not updated: %w", sealName, err)
+	}
+
+	fmt.Printf("\n%s Merged %s into '%s'\n", colors.Green("✓"), source, colors.Bold(timelineName))
+	fmt.Printf("  Merge seal: %s\n", colors.Cyan(sealName))
+	fmt.Println("Use 'ivaldi upload' to push the merge")
+	return nil
+}
+
+// shortSHA abbreviates a Git commit SHA for display
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+)
+
+// templateWorkTree is the subdirectory of init.templateDir that is copied
+// into the working directory rather than into .ivaldi
+const templateWorkTree = "worktree"
+
+// copyTemplateRepo copies init.templateDir, apart from its worktree
+// subdirectory, into the .ivaldi directory of a new repository. It does
+// nothing when no template is configured.
+func copyTemplateRepo(ivaldiDir string) error {
+	templateDir := config.TemplateDir()
+	if templateDir == "" {
+		return nil
+	}
+	return copyTemplateTree(templateDir, ivaldiDir, templateWorkTree)
+}
+
+// copyTemplateWorkTree copies the worktree subdirectory of
+// init.templateDir, such as a default .ivaldiignore, into the working
+// directory of a new repository
+func copyTemplateWorkTree(workDir string) error {
+	templateDir := config.TemplateDir()
+	if templateDir == "" {
+		return nil
+	}
+	src := filepath.Join(templateDir, templateWorkTree)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return copyTemplateTree(src, workDir, "")
+}
+
+// copyTemplateTree copies the files and directories below src into dst,
+// keeping their permissions, e.g. so hooks stay executable. Files that
+// already exist in dst are left alone, as is the top-level entry named
+// skip. Anything other than a regular file or directory is ignored.
+func copyTemplateTree(src, dst, skip string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to read template directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("template %s is not a directory", src)
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if skip != "" && rel == skip {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode().IsRegular():
+			return copyTemplateFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// copyTemplateFile copies one template file, unless target exists
+func copyTemplateFile(path, target string, perm fs.FileMode) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if os.IsExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -7,7 +7,9 @@ import (
 	"path/filepath"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
 	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/history"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/javanhut/Ivaldi-vcs/internal/seals"
@@ -32,7 +34,7 @@ var createTimelineCmd = &cobra.Command{
 		name := args[0]
 
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -59,6 +61,13 @@ var createTimelineCmd = &cobra.Command{
 		if currentTimeline == "" {
 			// No current timeline, create from scratch with zero hashes
 			log.Printf("No current timeline found, creating new timeline from scratch")
+		} else if isBareRepository() {
+			// Bare repository: branch from the parent's committed state, there is no workspace to capture
+			timeline, err := refsManager.GetTimeline(currentTimeline, refs.LocalTimeline)
+			if err == nil {
+				baseHashes[0] = timeline.Blake3Hash
+				baseHashes[1] = timeline.SHA256Hash
+			}
 		} else {
 			log.Printf("Creating timeline '%s' branched from '%s'", name, currentTimeline)
 
@@ -141,13 +150,21 @@ var createTimelineCmd = &cobra.Command{
 	},
 }
 
+var listTimelineTags bool
+
 var listTimelineCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"ls"},
 	Short:   "List all timelines",
+	Long: `List local and remote timelines. Tags, including those fetched from
+GitHub, are only counted unless --tags is given.
+
+A local timeline with an upstream branch shows it as [tracks owner/repo:branch].
+Timelines created from a remote branch track it automatically unless
+branch.autoSetupMerge is false; see 'ivaldi upload --set-upstream' to set it.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -183,6 +200,11 @@ var listTimelineCmd = &cobra.Command{
 			log.Printf("Warning: Failed to list tags: %v", err)
 		}
 
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			cfg = config.DefaultConfig()
+		}
+
 		// Display results
 		if len(localTimelines) > 0 {
 			fmt.Println("Local Timelines:")
@@ -191,7 +213,7 @@ var listTimelineCmd = &cobra.Command{
 				if currentTimeline == timeline.Name {
 					marker = "* " // Mark current timeline
 				}
-				fmt.Printf("%s%s\t%s\n", marker, timeline.Name, timeline.Description)
+				fmt.Printf("%s%s\t%s%s\n", marker, timeline.Name, timeline.Description, upstreamLabel(cfg.Branch[timeline.Name]))
 			}
 		} else {
 			fmt.Println("No local timelines found.")
@@ -204,17 +226,36 @@ var listTimelineCmd = &cobra.Command{
 			}
 		}
 
-		if len(tags) > 0 {
+		switch {
+		case len(tags) > 0 && listTimelineTags:
 			fmt.Println("\nTags:")
 			for _, timeline := range tags {
 				fmt.Printf("  %s\t%s\n", timeline.Name, timeline.Description)
 			}
+		case len(tags) > 0:
+			fmt.Printf("\n%d tag(s); use --tags to list them\n", len(tags))
 		}
 
 		return nil
 	},
 }
 
+func init() {
+	listTimelineCmd.Flags().BoolVar(&listTimelineTags, "tags", false, "List tags as well")
+}
+
+// upstreamLabel describes the upstream branch a timeline tracks, or is
+// empty when it has none
+func upstreamLabel(branch config.BranchConfig) string {
+	switch {
+	case branch.Merge == "":
+		return ""
+	case branch.Remote == "":
+		return colors.Dim(fmt.Sprintf(" [tracks %s]", branch.Merge))
+	}
+	return colors.Dim(fmt.Sprintf(" [tracks %s:%s]", branch.Remote, branch.Merge))
+}
+
 var switchTimelineCmd = &cobra.Command{
 	Use:     "switch <name>",
 	Aliases: []string{"sw"},
@@ -224,7 +265,7 @@ var switchTimelineCmd = &cobra.Command{
 		name := args[0]
 
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -237,18 +278,29 @@ var switchTimelineCmd = &cobra.Command{
 		defer refsManager.Close()
 
 		// Check if the target timeline exists
-		_, err = refsManager.GetTimeline(name, refs.LocalTimeline)
+		timeline, err := refsManager.GetTimeline(name, refs.LocalTimeline)
 		if err != nil {
 			return fmt.Errorf("timeline '%s' does not exist: %w", name, err)
 		}
 
 		// Get current timeline
 		currentTimeline, err := refsManager.GetCurrentTimeline()
-		if err == nil && currentTimeline == name {
+		alreadyOn := err == nil && currentTimeline == name
+		bare := isBareRepository()
+		if alreadyOn && bare {
 			fmt.Printf("Already on timeline '%s'\n", name)
 			return nil
 		}
 
+		// Bare repositories only move HEAD, there are no workspace files to update
+		if bare {
+			if err := refsManager.SetCurrentTimeline(name); err != nil {
+				return fmt.Errorf("failed to update current timeline: %w", err)
+			}
+			fmt.Printf("Switched to timeline '%s' (bare repository, no files materialized)\n", name)
+			return nil
+		}
+
 		// Check for uncommitted changes
 		objectsDir := filepath.Join(ivaldiDir, "objects")
 		casStore, err := cas.NewFileCAS(objectsDir)
@@ -263,6 +315,24 @@ var switchTimelineCmd = &cobra.Command{
 
 		materializer := workspace.NewMaterializer(casStore, ivaldiDir, workDir)
 
+		// Switching to the current timeline only materializes it when none of
+		// its files are checked out, as in a repository that used to be bare
+		if alreadyOn {
+			missing, err := workTreeMissing(casStore, timeline.Blake3Hash, workDir)
+			if err != nil {
+				return err
+			}
+			if !missing {
+				fmt.Printf("Already on timeline '%s'\n", name)
+				return nil
+			}
+			if err := materializer.MaterializeTimelineWithAutoShelf(name, false); err != nil {
+				return fmt.Errorf("failed to materialize timeline '%s': %w", name, err)
+			}
+			fmt.Printf("Materialized timeline '%s' into the working directory\n", name)
+			return nil
+		}
+
 		// Materialize the target timeline with auto-shelving enabled
 		// This will automatically stash uncommitted changes and restore any existing shelf
 		err = materializer.MaterializeTimelineWithAutoShelf(name, true)
@@ -277,6 +347,24 @@ var switchTimelineCmd = &cobra.Command{
 	},
 }
 
+// workTreeMissing reports whether none of the files sealed in a commit
+// exist in the working directory
+func workTreeMissing(casStore cas.CAS, commitHash [32]byte, workDir string) (bool, error) {
+	fileRefs, err := getCommitFileRefs(casStore, cas.Hash(commitHash))
+	if err != nil {
+		return false, fmt.Errorf("failed to read timeline files: %w", err)
+	}
+	if len(fileRefs) == 0 {
+		return false, nil
+	}
+	for path := range fileRefs {
+		if _, err := os.Lstat(filepath.Join(workDir, path)); err == nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 var removeTimelineCmd = &cobra.Command{
 	Use:     "remove <name>",
 	Aliases: []string{"rm"},
@@ -286,7 +374,7 @@ var removeTimelineCmd = &cobra.Command{
 		name := args[0]
 
 		// Check if we're in an Ivaldi repository
-		ivaldiDir := ".ivaldi"
+		ivaldiDir := config.IvaldiDir()
 		if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 			return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 		}
@@ -311,8 +399,7 @@ var removeTimelineCmd = &cobra.Command{
 		}
 
 		// Remove timeline file
-		refPath := fmt.Sprintf("%s/refs/heads/%s", ivaldiDir, name)
-		err = os.Remove(refPath)
+		err = refsManager.RemoveTimeline(name, refs.LocalTimeline)
 		if err != nil {
 			return fmt.Errorf("failed to remove timeline file: %w", err)
 		}
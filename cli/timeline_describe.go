@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var describeAbbrev int
+
+var describeTimelineCmd = &cobra.Command{
+	Use:   "describe [seal]",
+	Short: "Name a seal after its nearest tag",
+	Long: `Print a label for a seal made from the nearest tag it descends from,
+suitable as a build version:
+
+  v1.2            the seal is tagged v1.2
+  v1.2-5-g1a2b3c4 the seal is 5 seals past v1.2; 1a2b3c4 is its hash
+
+The seal defaults to HEAD and may be anything 'ivaldi show' accepts. The
+distance is the length of the shortest parent path to the tag. When no
+ancestor is tagged, the seal's name is printed, or its short hash if it has
+none.
+
+Examples:
+  ivaldi timeline describe                # Label the current timeline's head
+  ivaldi timeline describe feature~2
+  ivaldi timeline describe --abbrev 12    # Longer hash
+  ivaldi timeline describe --abbrev 0     # Only the tag name`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDescribe,
+}
+
+func init() {
+	describeTimelineCmd.Flags().IntVar(&describeAbbrev, "abbrev", 7, "Number of hash digits in the label; 0 prints only the tag name")
+}
+
+func runDescribe(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+	if describeAbbrev < 0 {
+		return fmt.Errorf("--abbrev must not be negative")
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	ref := ""
+	if len(args) > 0 {
+		ref = args[0]
+	}
+	hash, err := resolveCommitRef(casStore, refsManager, ref)
+	if err != nil {
+		return err
+	}
+
+	label, err := describeSeal(casStore, refsManager, hash, describeAbbrev)
+	if err != nil {
+		return err
+	}
+	fmt.Println(label)
+	return nil
+}
+
+// describeSeal labels a seal after the nearest tagged seal it descends
+// from, walking parents breadth first
+func describeSeal(casStore cas.CAS, refsManager *refs.RefsManager, hash cas.Hash, abbrev int) (string, error) {
+	tags, err := refsManager.ListTimelines(refs.TagTimeline)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags: %w", err)
+	}
+	tagged := make(map[cas.Hash]string)
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	for _, tag := range tags {
+		// Stashes are tags of workspace indexes, not seals
+		if strings.HasPrefix(tag.Name, "stash/") {
+			continue
+		}
+		if _, ok := tagged[cas.Hash(tag.Blake3Hash)]; !ok {
+			tagged[cas.Hash(tag.Blake3Hash)] = tag.Name
+		}
+	}
+
+	short := hash.String()[:min(abbrev, len(hash.String()))]
+
+	reader := commit.NewCommitReader(casStore)
+	seen := map[cas.Hash]bool{hash: true}
+	level := []cas.Hash{hash}
+	for distance := 0; len(level) > 0; distance++ {
+		var next []cas.Hash
+		for _, h := range level {
+			if name, ok := tagged[h]; ok {
+				if distance == 0 || abbrev == 0 {
+					return name, nil
+				}
+				return fmt.Sprintf("%s-%d-g%s", name, distance, short), nil
+			}
+			commitObj, err := reader.ReadCommit(h)
+			if err != nil {
+				return "", fmt.Errorf("failed to read commit %s: %w", h.String()[:8], err)
+			}
+			for _, parent := range commitObj.Parents {
+				if !seen[parent] {
+					seen[parent] = true
+					next = append(next, parent)
+				}
+			}
+		}
+		level = next
+	}
+
+	if sealName, err := refsManager.GetSealNameByHash([32]byte(hash)); err == nil && sealName != "" {
+		return sealName, nil
+	}
+	if abbrev == 0 {
+		return "", fmt.Errorf("no tag describes %s", hash.String()[:8])
+	}
+	return short, nil
+}
@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+// isLocalTimeline reports whether name is a local timeline of the repository
+func isLocalTimeline(ivaldiDir, name string) bool {
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return false
+	}
+	defer refsManager.Close()
+
+	return refsManager.TimelineExists(name, refs.LocalTimeline)
+}
+
+// timelineFileRefs returns the files at the head of a local timeline. A
+// timeline without seals yields an empty map.
+func timelineFileRefs(casStore cas.CAS, refsManager *refs.RefsManager, name string) (map[string]filechunk.NodeRef, error) {
+	timeline, err := refsManager.GetTimeline(name, refs.LocalTimeline)
+	if err != nil {
+		return nil, fmt.Errorf("timeline '%s' not found: %w", name, err)
+	}
+
+	var head cas.Hash
+	copy(head[:], timeline.Blake3Hash[:])
+
+	files, err := getCommitFileRefs(casStore, head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read head of '%s': %w", name, err)
+	}
+	return files, nil
+}
+
+// diffTimelines shows the net difference between the heads of two
+// timelines, independent of the working directory
+func diffTimelines(casStore cas.CAS, ivaldiDir, oldName, newName string) error {
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs: %w", err)
+	}
+	defer refsManager.Close()
+
+	oldFiles, err := timelineFileRefs(casStore, refsManager, oldName)
+	if err != nil {
+		return err
+	}
+	newFiles, err := timelineFileRefs(casStore, refsManager, newName)
+	if err != nil {
+		return err
+	}
+
+	paths := changedFilePaths(oldFiles, newFiles)
+	diffFoundChanges = len(paths) > 0
+	if diffQuiet {
+		return nil
+	}
+
+	if len(paths) == 0 {
+		fmt.Println("No differences.")
+		return nil
+	}
+
+	if !diffStat {
+		fmt.Printf("Diff between %s and %s:\n\n", colors.Cyan(oldName), colors.Cyan(newName))
+	}
+
+	loader := filechunk.NewLoader(casStore)
+	var stats []fileDiffStat
+	for _, path := range paths {
+		oldRef, inOld := oldFiles[path]
+		newRef, inNew := newFiles[path]
+
+		if diffStat {
+			oldContent, newContent, big, err := readFileRefPair(loader, path, oldRef, newRef, inOld, inNew)
+			if err != nil {
+				return err
+			}
+			if big {
+				stats = append(stats, fileDiffStat{Path: path, Binary: true})
+				continue
+			}
+			stats = append(stats, countFileDiff(path, oldContent, newContent, diffWhitespace))
+			continue
+		}
+
+		if err := printFileRefDiff(loader, path, oldRef, newRef, inOld, inNew, diffBinary, diffWhitespace, diffHunks); err != nil {
+			return err
+		}
+	}
+
+	if diffStat {
+		printDiffStat(stats)
+	}
+	return nil
+}
+
+// changedFilePaths returns the sorted paths that were added, removed or
+// modified between two sets of files
+func changedFilePaths(oldFiles, newFiles map[string]filechunk.NodeRef) []string {
+	var paths []string
+	for path, ref := range oldFiles {
+		if newRef, ok := newFiles[path]; !ok || newRef.Hash != ref.Hash {
+			paths = append(paths, path)
+		}
+	}
+	for path := range newFiles {
+		if _, ok := oldFiles[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// readFileRefPair reads both sides of a changed file; a missing side is
+// empty. Neither side is read if one is above core.bigFileThreshold, which
+// big reports.
+func readFileRefPair(loader *filechunk.Loader, path string, oldRef, newRef filechunk.NodeRef, inOld, inNew bool) (oldContent, newContent []byte, big bool, err error) {
+	if (inOld && isBigFile(oldRef.Size)) || (inNew && isBigFile(newRef.Size)) {
+		return nil, nil, true, nil
+	}
+	if inOld {
+		if oldContent, err = loader.ReadAll(oldRef); err != nil {
+			return nil, nil, false, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	if inNew {
+		if newContent, err = loader.ReadAll(newRef); err != nil {
+			return nil, nil, false, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	return oldContent, newContent, false, nil
+}
+
+// printFileRefDiff prints the coloured unified diff of a single changed
+// file, ignoring the whitespace differences selected by ws
+func printFileRefDiff(loader *filechunk.Loader, path string, oldRef, newRef filechunk.NodeRef, inOld, inNew, binary bool, ws diffmerge.WhitespaceOptions, hunkOpts diffmerge.HunkOptions) error {
+	oldContent, newContent, big, err := readFileRefPair(loader, path, oldRef, newRef, inOld, inNew)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if big {
+		writeBigFileDiff(&buf, path, inOld, inNew)
+	} else if err := writeFileDiff(&buf, path, oldContent, newContent, inOld, inNew, binary, ws, hunkOpts); err != nil {
+		return err
+	}
+	printColoredDiff(buf.String())
+	return nil
+}
+
+// fileDiffStat counts the lines a file gained and lost
+type fileDiffStat struct {
+	Path      string
+	Additions int
+	Deletions int
+	Binary    bool
+}
+
+// countFileDiff computes the line counts of a single file's change
+func countFileDiff(path string, oldContent, newContent []byte, ws diffmerge.WhitespaceOptions) fileDiffStat {
+	stat := fileDiffStat{Path: path}
+	if showsAsBinary(oldContent, newContent) {
+		stat.Binary = true
+		return stat
+	}
+
+	for _, op := range ws.DiffLines(diffmerge.SplitLines(oldContent), diffmerge.SplitLines(newContent)) {
+		if ws.Ignores(op) {
+			continue
+		}
+		switch op.Type {
+		case diffmerge.LineInsert:
+			stat.Additions++
+		case diffmerge.LineDelete:
+			stat.Deletions++
+		}
+	}
+	return stat
+}
+
+// diffStatWidth is the widest +/- bar printed by printDiffStat
+const diffStatWidth = 40
+
+// printDiffStat prints a per-file summary of changed lines followed by totals
+func printDiffStat(stats []fileDiffStat) {
+	pathWidth, maxChanges := 0, 0
+	for _, stat := range stats {
+		pathWidth = max(pathWidth, len(stat.Path))
+		maxChanges = max(maxChanges, stat.Additions+stat.Deletions)
+	}
+	countWidth := len(fmt.Sprint(maxChanges))
+
+	insertions, deletions := 0, 0
+	for _, stat := range stats {
+		insertions += stat.Additions
+		deletions += stat.Deletions
+
+		if stat.Binary {
+			fmt.Printf(" %-*s | %s\n", pathWidth, stat.Path, colors.Gray("Bin"))
+			continue
+		}
+
+		plus, minus := stat.Additions, stat.Deletions
+		if maxChanges > diffStatWidth {
+			plus = (plus*diffStatWidth + maxChanges - 1) / maxChanges
+			minus = (minus*diffStatWidth + maxChanges - 1) / maxChanges
+		}
+		fmt.Printf(" %-*s | %*d %s%s\n", pathWidth, stat.Path, countWidth, stat.Additions+stat.Deletions,
+			colors.Green(strings.Repeat("+", plus)), colors.Red(strings.Repeat("-", minus)))
+	}
+
+	fmt.Printf(" %d file(s) changed, %s, %s\n", len(stats),
+		colors.Green(fmt.Sprintf("%d insertion(s)(+)", insertions)),
+		colors.Red(fmt.Sprintf("%d deletion(s)(-)", deletions)))
+}
+
+// printColoredDiff prints unified diff text, colouring it line by line
+func printColoredDiff(text string) {
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "diff --git"), strings.HasPrefix(line, "--- a/"), strings.HasPrefix(line, "+++ b/"),
+			strings.HasPrefix(line, "--- /dev/null"), strings.HasPrefix(line, "+++ /dev/null"):
+			fmt.Println(colors.Bold(trimmed))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Println(colors.Cyan(trimmed))
+		case strings.HasPrefix(line, "+"):
+			fmt.Println(colors.Green(trimmed))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println(colors.Red(trimmed))
+		default:
+			fmt.Println(trimmed)
+		}
+	}
+}
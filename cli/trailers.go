@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+)
+
+// withTrailers appends the trailers given with --trailer to a message,
+// followed with signoff by a Signed-off-by trailer for the configured
+// identity
+func withTrailers(message string, trailerArgs []string, signoff bool) (string, error) {
+	var trailers []commit.Trailer
+	for _, arg := range trailerArgs {
+		trailer, err := commit.ParseTrailer(arg)
+		if err != nil {
+			return "", err
+		}
+		trailers = append(trailers, trailer)
+	}
+
+	if signoff {
+		author, err := getAuthorFromConfig()
+		if err != nil {
+			return "", fmt.Errorf("failed to sign off: %w", err)
+		}
+		trailers = append(trailers, commit.Trailer{Key: commit.SignedOffBy, Value: author})
+	}
+
+	return commit.AddTrailers(message, trailers), nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/colors"
 	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
@@ -31,7 +32,10 @@ From there, you can either:
 Flags:
   --limit N     Show only the N most recent seals (default: 20)
   --all         Show all seals (no pagination)
-  --search TEXT Search for seals containing TEXT in message`,
+  --search TEXT Search for seals containing TEXT in message, author, name or tag
+
+Seals that tags point at, such as release tags fetched from GitHub, are
+labeled with the tag names.`,
 	RunE: runTravel,
 }
 
@@ -48,16 +52,21 @@ type SealInfo struct {
 	Message   string
 	Author    string
 	Timestamp string
-	Position  int // Position in history (0 = current, 1 = previous, etc.)
+	Position  int      // Position in history (0 = current, 1 = previous, etc.)
+	Tags      []string // Tags pointing at the seal, e.g. fetched release tags
 }
 
 func runTravel(cmd *cobra.Command, args []string) error {
 	// Check if we're in an Ivaldi repository
-	ivaldiDir := ".ivaldi"
+	ivaldiDir := config.IvaldiDir()
 	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
 		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
 	}
 
+	if err := requireWorkTree("travel"); err != nil {
+		return err
+	}
+
 	workDir, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get working directory: %w", err)
@@ -155,7 +164,11 @@ func runTravel(cmd *cobra.Command, args []string) error {
 	case "diverge":
 		return createDivergentTimeline(casStore, refsManager, ivaldiDir, workDir, currentTimeline, newTimelineName, selectedSeal)
 	case "overwrite":
-		return overwriteTimeline(casStore, refsManager, ivaldiDir, workDir, currentTimeline, selectedSeal)
+		if err := overwriteTimeline(casStore, refsManager, ivaldiDir, workDir, currentTimeline, selectedSeal); err != nil {
+			return err
+		}
+		autoGC(ivaldiDir)
+		return nil
 	case "cancel":
 		fmt.Println("Travel cancelled.")
 		return nil
@@ -173,14 +186,25 @@ func getCommitHistory(casStore cas.CAS, refsManager *refs.RefsManager, headHash
 	copy(currentHash[:], headHash[:])
 	position := 0
 
-	visited := make(map[cas.Hash]bool)
+	guard := commitReader.NewWalkGuard()
+
+	// Tags are shown next to the seals they point at
+	tagsByHash := make(map[[32]byte][]string)
+	if tags, err := refsManager.ListTimelines(refs.TagTimeline); err == nil {
+		for _, tag := range tags {
+			tagsByHash[tag.Blake3Hash] = append(tagsByHash[tag.Blake3Hash], tag.Name)
+		}
+	}
 
 	for {
-		// Check for cycles
-		if visited[currentHash] {
+		// Stop at a cycle, and fail on a chain too long to be real
+		first, err := guard.Visit(currentHash, position)
+		if err != nil {
+			return nil, err
+		}
+		if !first {
 			break
 		}
-		visited[currentHash] = true
 
 		// Read commit
 		commitObj, err := commitReader.ReadCommit(currentHash)
@@ -204,6 +228,7 @@ func getCommitHistory(casStore cas.CAS, refsManager *refs.RefsManager, headHash
 			Author:    commitObj.Author,
 			Timestamp: commitObj.CommitTime.Format("2006-01-02 15:04:05"),
 			Position:  position,
+			Tags:      tagsByHash[hashArray],
 		}
 		seals = append(seals, seal)
 
@@ -338,15 +363,19 @@ func displaySealsWithCursor(seals []SealInfo, timelineName string, startIdx, end
 		sealHash := hex.EncodeToString(seal.Hash[:4])
 		message := seal.Message
 		authorTime := fmt.Sprintf("%s • %s", seal.Author, seal.Timestamp)
+		tags := ""
+		if len(seal.Tags) > 0 {
+			tags = " " + colors.Yellow("(tag: "+strings.Join(seal.Tags, ", ")+")")
+		}
 
 		if i == cursorIdx {
 			// Highlighted/selected line
-			fmt.Printf("%s%d. %s (%s)\n", prefix, i+1, colors.Bold(colors.Cyan(sealName)), colors.Bold(colors.Gray(sealHash)))
+			fmt.Printf("%s%d. %s (%s)%s\n", prefix, i+1, colors.Bold(colors.Cyan(sealName)), colors.Bold(colors.Gray(sealHash)), tags)
 			fmt.Printf("     %s\n", colors.Bold(message))
 			fmt.Printf("     %s\n", colors.Bold(colors.Gray(authorTime)))
 		} else {
 			// Normal line
-			fmt.Printf("%s%d. %s (%s)\n", prefix, i+1, colors.Cyan(sealName), colors.Gray(sealHash))
+			fmt.Printf("%s%d. %s (%s)%s\n", prefix, i+1, colors.Cyan(sealName), colors.Gray(sealHash), tags)
 			fmt.Printf("     %s\n", message)
 			fmt.Printf("     %s\n", colors.Gray(authorTime))
 		}
@@ -474,7 +503,8 @@ func filterSeals(seals []SealInfo, searchTerm string) []SealInfo {
 	for _, seal := range seals {
 		if strings.Contains(strings.ToLower(seal.Message), searchLower) ||
 			strings.Contains(strings.ToLower(seal.Author), searchLower) ||
-			strings.Contains(strings.ToLower(seal.SealName), searchLower) {
+			strings.Contains(strings.ToLower(seal.SealName), searchLower) ||
+			strings.Contains(strings.ToLower(strings.Join(seal.Tags, " ")), searchLower) {
 			filtered = append(filtered, seal)
 		}
 	}
@@ -609,7 +639,7 @@ func overwriteTimeline(casStore cas.CAS, refsManager *refs.RefsManager, ivaldiDi
 	}
 
 	// Apply changes
-	differ := diffmerge.NewDiffer(casStore)
+	differ := materializer.NewDiffer()
 	diff, err := differ.DiffWorkspaces(currentState.Index, targetIndex)
 	if err != nil {
 		return fmt.Errorf("failed to compute diff: %w", err)
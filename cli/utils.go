@@ -4,17 +4,21 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/commit"
 	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
 	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/lockfile"
 	"github.com/javanhut/Ivaldi-vcs/internal/objects"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/javanhut/Ivaldi-vcs/internal/seals"
 	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
 	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+	"github.com/spf13/cobra"
 )
 
 // updateLastSnapshot updates the snapshot file with current file hashes for status tracking
@@ -27,13 +31,17 @@ func updateLastSnapshot(workDir, ivaldiDir string) error {
 	defer f.Close()
 
 	// Walk the working directory and record all files
+	nested := workspace.NewNestedRepos(workDir, config.SkipNestedRepos())
 	err = filepath.Walk(workDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories
+		// Skip the .ivaldi directory and nested repositories
 		if info.IsDir() {
+			if info.Name() == ".ivaldi" || nested.Skip(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -43,13 +51,8 @@ func updateLastSnapshot(workDir, ivaldiDir string) error {
 			return err
 		}
 
-		// Skip .ivaldi directory
-		if strings.HasPrefix(relPath, ".ivaldi") {
-			return nil
-		}
-
 		// Compute file hash
-		content, err := os.ReadFile(path)
+		content, err := workspace.ReadFileContent(path)
 		if err != nil {
 			return err
 		}
@@ -144,8 +147,159 @@ func createInitialCommit(ivaldiDir, workDir string) (*[32]byte, error) {
 	return &hashArray, nil
 }
 
+// isBareRepository reports whether the repository has no working tree
+// (core.bare). A working tree set by core.worktree or $IVALDI_WORK_TREE
+// overrides core.bare.
+func isBareRepository() bool {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return false
+	}
+	return cfg.Core.Bare && !hasDetachedWorkTree()
+}
+
+// requireWorkTree returns an error if the repository is bare
+func requireWorkTree(operation string) error {
+	if isBareRepository() {
+		return fmt.Errorf("'%s' requires a working tree, but this repository is bare.\nRun 'ivaldi config core.bare false', then 'ivaldi timeline switch <current timeline>' to materialize one", operation)
+	}
+	return nil
+}
+
+// checkHashAlgo refuses to run in a repository whose objects are named by a
+// hash this build does not use, as it would misread them and could write
+// objects the rest of the repository cannot find. config stays available to
+// inspect the setting.
+func checkHashAlgo(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(config.IvaldiDir()); err != nil {
+		return nil
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == configCmd {
+			return nil
+		}
+	}
+	if algo := config.HashAlgo(); algo != cas.HashAlgorithm {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("this repository's objects are hashed with %s (core.hashAlgo), but this build of Ivaldi only supports %s.\nUse an Ivaldi version that supports %s, or migrate the repository with one, before running other commands on it", algo, cas.HashAlgorithm, algo)
+	}
+	return nil
+}
+
+// applyWalkLimits bounds history walks by core.maxWalkCommits and
+// core.maxWalkDepth, keeping the defaults for unset values
+func applyWalkLimits() {
+	maxCommits, maxDepth := config.WalkLimits()
+	if maxCommits > 0 {
+		commit.DefaultWalkLimits.MaxCommits = maxCommits
+	}
+	if maxDepth > 0 {
+		commit.DefaultWalkLimits.MaxDepth = maxDepth
+	}
+}
+
 // getAuthorFromConfig retrieves the author string from configuration
 // Returns "Name <email>" format or error if not configured
 func getAuthorFromConfig() (string, error) {
 	return config.GetAuthor()
 }
+
+// resolveCommitRef resolves a commit reference to a commit hash. It accepts
+// HEAD (or an empty string), a local timeline name, a tag name, a reflog
+// entry such as main@{2}, or a seal name, prefix, or hash prefix,
+// optionally followed by ~N to walk back N first parents. Timelines take
+// precedence over tags of the same name.
+func resolveCommitRef(casStore cas.CAS, refsManager *refs.RefsManager, ref string) (cas.Hash, error) {
+	var commitHash cas.Hash
+
+	steps := 0
+	if idx := strings.LastIndex(ref, "~"); idx != -1 {
+		n, err := strconv.Atoi(ref[idx+1:])
+		if err != nil || n < 0 {
+			return commitHash, fmt.Errorf("invalid commit reference: %s", ref)
+		}
+		steps = n
+		ref = ref[:idx]
+	}
+
+	switch {
+	case ref == "" || ref == "HEAD":
+		currentTimeline, err := refsManager.GetCurrentTimeline()
+		if err != nil {
+			return commitHash, fmt.Errorf("failed to get current timeline: %w", err)
+		}
+		timeline, err := refsManager.GetTimeline(currentTimeline, refs.LocalTimeline)
+		if err != nil {
+			return commitHash, fmt.Errorf("failed to get timeline: %w", err)
+		}
+		copy(commitHash[:], timeline.Blake3Hash[:])
+	case refsManager.TimelineExists(ref, refs.LocalTimeline):
+		timeline, err := refsManager.GetTimeline(ref, refs.LocalTimeline)
+		if err != nil {
+			return commitHash, fmt.Errorf("failed to get timeline: %w", err)
+		}
+		copy(commitHash[:], timeline.Blake3Hash[:])
+	case strings.HasSuffix(ref, "}") && strings.Contains(ref, "@{"):
+		hash, err := resolveReflogRef(refsManager, ref)
+		if err != nil {
+			return commitHash, err
+		}
+		commitHash = hash
+	case refsManager.TimelineExists(ref, refs.TagTimeline):
+		tag, err := refsManager.GetTimeline(ref, refs.TagTimeline)
+		if err != nil {
+			return commitHash, fmt.Errorf("failed to get tag: %w", err)
+		}
+		copy(commitHash[:], tag.Blake3Hash[:])
+	default:
+		_, hash, _, _, err := resolveSealReference(refsManager, ref)
+		if err != nil {
+			return commitHash, err
+		}
+		copy(commitHash[:], hash[:])
+	}
+
+	if commitHash == (cas.Hash{}) {
+		return commitHash, fmt.Errorf("no commits found for '%s'", ref)
+	}
+
+	commitReader := commit.NewCommitReader(casStore)
+	for i := 0; i < steps; i++ {
+		commitObj, err := commitReader.ReadCommit(commitHash)
+		if err != nil {
+			return commitHash, fmt.Errorf("failed to read commit: %w", err)
+		}
+		if len(commitObj.Parents) == 0 {
+			return commitHash, fmt.Errorf("'%s~%d' goes past the first commit", ref, steps)
+		}
+		commitHash = commitObj.Parents[0]
+	}
+
+	return commitHash, nil
+}
+
+// lockStage takes the staging area lock so that concurrent gather, seal
+// and reset runs do not lose each other's updates to the stage file.
+func lockStage(ivaldiDir string) (*lockfile.Lock, error) {
+	stageDir := filepath.Join(ivaldiDir, "stage")
+	if err := os.MkdirAll(stageDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create stage directory: %w", err)
+	}
+	return lockfile.Acquire(filepath.Join(stageDir, "files.lock"), lockfile.DefaultTimeout)
+}
+
+// getCommitFileRefs returns the file references stored in a commit's tree,
+// keyed by path. The zero hash yields an empty map.
+func getCommitFileRefs(casStore cas.CAS, commitHash cas.Hash) (map[string]filechunk.NodeRef, error) {
+	if commitHash == (cas.Hash{}) {
+		return make(map[string]filechunk.NodeRef), nil
+	}
+
+	commitReader := commit.NewCommitReader(casStore)
+	commitObj, err := commitReader.ReadCommit(commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit: %w", err)
+	}
+
+	return commitReader.FileRefs(commitObj)
+}
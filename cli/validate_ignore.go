@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/ignore"
+	"github.com/javanhut/Ivaldi-vcs/internal/pathspec"
+	"github.com/spf13/cobra"
+)
+
+var validateIgnoreCmd = &cobra.Command{
+	Use:   "validate-ignore <path>...",
+	Short: "Show which ignore rules match a path and whether gather takes it",
+	Long: `Explain why a path is or is not gathered.
+
+For each path, every rule that matches it is listed with where it comes
+from: an auto-exclude pattern (the security.secretpatterns setting, or the
+built-in .env and .venv patterns when it is not set), a line of the
+repository's .ivaldiinclude allowlist, or a line of its .ivaldiignore. The
+decision gather makes follows. Auto-exclude patterns are checked first, and
+the first one that matches decides. Next, with an .ivaldiinclude, a path
+none of its lines match is left out. Last, the last matching line of
+.ivaldiignore decides: a line starting with ! gathers what earlier lines
+ignore.
+
+Paths are relative to the repository root and need not exist.
+
+Examples:
+  ivaldi validate-ignore build/app.log
+  ivaldi validate-ignore .env src/main.go`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runValidateIgnore,
+}
+
+// ignoreRule is one pattern and the place it was read from
+type ignoreRule struct {
+	Pattern string
+	Source  string
+	Line    int // 0 for rules that do not come from a file
+	Auto    bool
+}
+
+func (r ignoreRule) location() string {
+	if r.Line > 0 {
+		return fmt.Sprintf("%s:%d", r.Source, r.Line)
+	}
+	return r.Source
+}
+
+func runValidateIgnore(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	autoRules := autoExcludeRules()
+	includeRules, err := loadIgnoreRules(workDir, ignore.IncludeFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", ignore.IncludeFile, err)
+	}
+	ignoreRules, err := loadIgnoreRules(workDir, ignore.IgnoreFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", ignore.IgnoreFile, err)
+	}
+
+	// Decisions are made by the same rules gather uses
+	gatherRules, err := ignore.Load(workDir)
+	if err != nil {
+		return fmt.Errorf("failed to read ignore rules: %w", err)
+	}
+
+	for i, arg := range pathspec.Within(workPrefix, args) {
+		relPath := arg
+		if filepath.IsAbs(arg) {
+			if rel, err := filepath.Rel(workDir, arg); err == nil {
+				relPath = rel
+			}
+		}
+		relPath = filepath.Clean(relPath)
+
+		if i > 0 {
+			fmt.Println()
+		}
+		explainIgnore(relPath, gatherRules, autoRules, includeRules, ignoreRules)
+	}
+	return nil
+}
+
+// explainIgnore prints the rules matching a path and the decision gather
+// makes for it, in the order gather checks them. ignoreRules holds the
+// lines of gatherRules.Ignore in the same order.
+func explainIgnore(path string, gatherRules *ignore.Rules, autoRules, includeRules, ignoreRules []ignoreRule) {
+	fmt.Println(colors.Bold(path))
+
+	if path == ".ivaldi" || strings.HasPrefix(path, ".ivaldi"+string(filepath.Separator)) {
+		fmt.Printf("  %s repository metadata is never gathered\n", colors.Red("excluded:"))
+		return
+	}
+
+	base := filepath.Base(path)
+	isPatternFile := base == ignore.IgnoreFile || base == ignore.IncludeFile
+
+	var matched []ignoreRule
+	for _, rule := range autoRules {
+		if autoExcludeMatches(path, rule.Pattern) {
+			matched = append(matched, rule)
+		}
+	}
+	autoExcluded := len(matched) > 0
+	if !isPatternFile {
+		for _, rule := range includeRules {
+			if ignore.Match(path, rule.Pattern) {
+				matched = append(matched, rule)
+			}
+		}
+		for _, rule := range ignoreRules {
+			if ignore.Match(path, strings.TrimPrefix(rule.Pattern, "!")) {
+				matched = append(matched, rule)
+			}
+		}
+	}
+
+	// The rule that decides, when a single one does
+	var decider *ignoreRule
+	notIncluded := !autoExcluded && !gatherRules.Included(path)
+	line, ignored := gatherRules.Decide(path)
+	switch {
+	case autoExcluded:
+		decider = &matched[0]
+	case notIncluded || isPatternFile:
+	case line >= 0:
+		decider = &ignoreRules[line]
+	}
+
+	if len(matched) == 0 {
+		fmt.Printf("  %s\n", colors.Gray("no rule matches"))
+	}
+	for _, rule := range matched {
+		note := ""
+		if decider != nil && rule == *decider {
+			note = colors.Gray(" (decides)")
+		}
+		fmt.Printf("  %s  %s%s\n", colors.Cyan(rule.location()), rule.Pattern, note)
+	}
+
+	switch {
+	case autoExcluded:
+		fmt.Printf("  %s auto-excluded for security\n", colors.Red("excluded:"))
+	case notIncluded:
+		fmt.Printf("  %s not listed in %s\n", colors.Red("excluded:"), ignore.IncludeFile)
+	case isPatternFile:
+		fmt.Printf("  %s %s is never ignored\n", colors.Green("gathered:"), base)
+	case ignored:
+		fmt.Printf("  %s ignored by %s\n", colors.Yellow("ignored:"), decider.location())
+	case decider != nil && isHiddenPath(path):
+		fmt.Printf("  %s re-included by %s; hidden file, gathered after confirmation or with --allow-all\n", colors.Green("gathered:"), decider.location())
+	case decider != nil:
+		fmt.Printf("  %s re-included by %s\n", colors.Green("gathered:"), decider.location())
+	case isHiddenPath(path):
+		fmt.Printf("  %s hidden file, gathered after confirmation or with --allow-all\n", colors.Green("gathered:"))
+	default:
+		fmt.Printf("  %s\n", colors.Green("gathered"))
+	}
+}
+
+// isHiddenPath reports whether any component of path starts with a dot
+func isHiddenPath(path string) bool {
+	for _, part := range strings.Split(path, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// autoExcludeRules returns the auto-exclude patterns with their source
+func autoExcludeRules() []ignoreRule {
+	source := "auto-exclude (built-in)"
+	if cfg, err := config.LoadConfig(); err == nil && cfg.Security.SecretPatterns != nil {
+		source = "auto-exclude (security.secretpatterns)"
+	}
+
+	var rules []ignoreRule
+	for _, pattern := range getAutoExcludePatterns() {
+		rules = append(rules, ignoreRule{Pattern: pattern, Source: source, Auto: true})
+	}
+	return rules
+}
+
+// loadIgnoreRules reads the patterns of a pattern file with their line
+// numbers, skipping the same lines ignore.LoadFile skips
+func loadIgnoreRules(workDir, name string) ([]ignoreRule, error) {
+	file, err := os.Open(filepath.Join(workDir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			rules = append(rules, ignoreRule{Pattern: line, Source: name, Line: lineNum})
+		}
+	}
+	return rules, scanner.Err()
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/github"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+var verifyCloneFix bool
+
+var verifyCloneCmd = &cobra.Command{
+	Use:   "verify-clone [timeline]",
+	Short: "Check the working directory against its GitHub branch",
+	Long: `Compare every file of the working directory with the tree of the GitHub
+branch it was downloaded from, and report files whose content differs, files
+of the branch that are missing locally and local files the branch does not
+have. Files are compared by their Git blob SHA, so a truncated tree or a
+failed download is caught without downloading anything.
+
+The branch is the upstream of the current timeline, or the branch of the
+same name, as for 'ivaldi fetch'; a timeline argument names the branch
+instead. The tree compared is the one of the commit last downloaded, pulled
+or fetched for the branch, or of its current head when none is recorded.
+
+With --fix, mismatched and missing files are downloaded again. This
+overwrites local changes to those files. Extra files are never removed.
+
+The command exits with a non-zero status unless the working directory
+matches the branch, after any fixes.
+
+Examples:
+  ivaldi verify-clone            # Verify against the current timeline's branch
+  ivaldi verify-clone develop    # Verify against the develop branch
+  ivaldi verify-clone --fix      # Download mismatched and missing files again`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runVerifyClone,
+}
+
+func init() {
+	verifyCloneCmd.Flags().BoolVar(&verifyCloneFix, "fix", false, "Download mismatched and missing files again")
+}
+
+func runVerifyClone(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	owner, repo, branches, err := fetchTargets(refsManager, args)
+	refsManager.Close()
+	if err != nil {
+		return err
+	}
+	branch := branches[0]
+
+	syncer, err := github.NewRepoSyncer(ivaldiDir, workDir)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub syncer: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	result, err := syncer.VerifyClone(ctx, owner, repo, branch, verifyCloneFix)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Verified %d files against %s/%s %s (%s)\n", result.Files, owner, repo,
+		colors.Bold(branch), colors.Gray(result.CommitSHA[:min(7, len(result.CommitSHA))]))
+	printVerifyCloneFiles(colors.Yellow("Mismatched:"), result.Mismatched)
+	printVerifyCloneFiles(colors.Red("Missing:"), result.Missing)
+	printVerifyCloneFiles(colors.Cyan("Extra:"), result.Extra)
+
+	if result.Matches() {
+		fmt.Printf("%s Working directory matches the remote tree\n", colors.SuccessText("[OK]"))
+		return nil
+	}
+
+	remaining := len(result.Mismatched) + len(result.Missing) + len(result.Extra)
+	if verifyCloneFix {
+		for _, err := range result.FixErrors {
+			fmt.Printf("%s %v\n", colors.Red("Error:"), err)
+		}
+		if len(result.Fixed) > 0 {
+			fmt.Printf("%s Downloaded %s again\n", colors.SuccessText("[OK]"), countFiles(len(result.Fixed)))
+		}
+		remaining -= len(result.Fixed)
+	} else if len(result.Mismatched)+len(result.Missing) > 0 {
+		fmt.Println("Use 'ivaldi verify-clone --fix' to download mismatched and missing files again")
+	}
+	if remaining == 0 {
+		return nil
+	}
+	cmd.SilenceUsage = true
+	return fmt.Errorf("%s of the working directory do not match the remote tree", countFiles(remaining))
+}
+
+// printVerifyCloneFiles lists the paths of one kind of difference
+func printVerifyCloneFiles(label string, paths []string) {
+	for _, path := range paths {
+		fmt.Printf("  %s %s\n", label, path)
+	}
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/signing"
+	"github.com/spf13/cobra"
+)
+
+var verifyCommitVerbose bool
+
+var verifyCommitCmd = &cobra.Command{
+	Use:   "verify-commit <seal>...",
+	Short: "Check the signatures of seals",
+	Long: `Verify the signature of each seal and print who made it and how far the
+key is trusted. Each argument may be a timeline, a seal name, a hash prefix
+or any of these followed by ~N.
+
+The signature covers the seal without its gpgsig header. OpenPGP signatures
+are checked with gpg against your keyring; SSH signatures with ssh-keygen,
+where only signatures made by user.signingKey are good.
+
+The command exits with a non-zero status unless every seal has a good
+signature, so a missing, bad or unverifiable signature fails a CI job.
+
+Examples:
+  ivaldi verify-commit HEAD
+  ivaldi verify-commit -v main feature~2`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runVerifyCommit,
+}
+
+func init() {
+	verifyCommitCmd.Flags().BoolVarP(&verifyCommitVerbose, "verbose", "v", false, "Print the verifier's output for good signatures too")
+}
+
+func runVerifyCommit(cmd *cobra.Command, args []string) error {
+	ivaldiDir := config.IvaldiDir()
+	if _, err := os.Stat(ivaldiDir); os.IsNotExist(err) {
+		return fmt.Errorf("not in an Ivaldi repository (no .ivaldi directory found)")
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	casStore, err := cas.NewFileCAS(filepath.Join(ivaldiDir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	reader := commit.NewCommitReader(casStore)
+
+	// All seals are resolved first, so a typo fails before anything is
+	// verified
+	hashes := make([]cas.Hash, len(args))
+	for i, ref := range args {
+		if hashes[i], err = resolveCommitRef(casStore, refsManager, ref); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for i, hash := range hashes {
+		commitObj, err := reader.ReadCommit(hash)
+		if err != nil {
+			return fmt.Errorf("failed to read seal %s: %w", args[i], err)
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		var hashArray [32]byte
+		copy(hashArray[:], hash[:])
+		if sealName, err := refsManager.GetSealNameByHash(hashArray); err == nil && sealName != "" {
+			fmt.Printf("%s %s (%s)\n", colors.Cyan("seal"), colors.Bold(sealName), colors.Gray(hex.EncodeToString(hash[:4])))
+		} else {
+			fmt.Printf("%s %s\n", colors.Cyan("commit"), colors.Bold(hex.EncodeToString(hash[:4])))
+		}
+
+		if commitObj.Signature == "" {
+			fmt.Printf("%s\n", colors.Red("No signature"))
+			failed++
+			continue
+		}
+
+		result := verifySeal(commitObj)
+		printVerification(result)
+		if result.Trust != "" {
+			fmt.Printf("Trust: %s\n", result.Trust)
+		}
+		if verifyCommitVerbose && result.Status == signing.Good && result.Output != "" {
+			fmt.Printf("%s\n", colors.Gray(result.Output))
+		}
+		if result.Status != signing.Good {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d of %d seals failed signature verification", failed, len(hashes))
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Whitespace rules understood by core.whitespace
+const (
+	wsTrailingSpace  = "trailing-space"
+	wsMixedIndent    = "mixed-indent"
+	wsMissingNewline = "missing-newline"
+)
+
+// whitespaceRules holds the enabled whitespace checks
+type whitespaceRules struct {
+	TrailingSpace  bool
+	MixedIndent    bool
+	MissingNewline bool
+}
+
+// whitespaceProblem describes a single whitespace error in a file
+type whitespaceProblem struct {
+	Line    int
+	Message string
+	Content string
+}
+
+// parseWhitespaceRules parses a core.whitespace value such as
+// "trailing-space,-missing-newline". All rules are enabled by default;
+// a leading '-' disables a rule.
+func parseWhitespaceRules(value string) (whitespaceRules, error) {
+	rules := whitespaceRules{
+		TrailingSpace:  true,
+		MixedIndent:    true,
+		MissingNewline: true,
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		enabled := true
+		if strings.HasPrefix(item, "-") {
+			enabled = false
+			item = strings.TrimPrefix(item, "-")
+		}
+
+		switch item {
+		case wsTrailingSpace:
+			rules.TrailingSpace = enabled
+		case wsMixedIndent:
+			rules.MixedIndent = enabled
+		case wsMissingNewline:
+			rules.MissingNewline = enabled
+		default:
+			return rules, fmt.Errorf("unknown core.whitespace rule: %s", item)
+		}
+	}
+
+	return rules, nil
+}
+
+// checkWhitespace returns the whitespace problems found in content
+func checkWhitespace(content []byte, rules whitespaceRules) []whitespaceProblem {
+	var problems []whitespaceProblem
+	if len(content) == 0 {
+		return problems
+	}
+
+	lines := strings.Split(string(content), "\n")
+	// A trailing newline produces an empty final element that isn't a real line
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+
+		if rules.TrailingSpace && line != strings.TrimRight(line, " \t") {
+			problems = append(problems, whitespaceProblem{
+				Line:    i + 1,
+				Message: "trailing whitespace",
+				Content: line,
+			})
+		}
+
+		if rules.MixedIndent {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			if strings.Contains(indent, " ") && strings.Contains(indent, "\t") {
+				problems = append(problems, whitespaceProblem{
+					Line:    i + 1,
+					Message: "indent mixes tabs and spaces",
+					Content: line,
+				})
+			}
+		}
+	}
+
+	if rules.MissingNewline && content[len(content)-1] != '\n' {
+		problems = append(problems, whitespaceProblem{
+			Line:    len(lines),
+			Message: "no newline at end of file",
+		})
+	}
+
+	return problems
+}
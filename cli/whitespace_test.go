@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWhitespaceRules(t *testing.T) {
+	all := whitespaceRules{TrailingSpace: true, MixedIndent: true, MissingNewline: true}
+	tests := []struct {
+		value string
+		want  whitespaceRules
+	}{
+		{"", all},
+		{" , ,", all},
+		{"trailing-space,mixed-indent,missing-newline", all},
+		{"-trailing-space", whitespaceRules{MixedIndent: true, MissingNewline: true}},
+		{"-mixed-indent", whitespaceRules{TrailingSpace: true, MissingNewline: true}},
+		{"-missing-newline", whitespaceRules{TrailingSpace: true, MixedIndent: true}},
+		{"trailing-space, -mixed-indent ,-missing-newline", whitespaceRules{TrailingSpace: true}},
+		{"-trailing-space,trailing-space", all},
+		{"trailing-space,-trailing-space", whitespaceRules{MixedIndent: true, MissingNewline: true}},
+	}
+	for _, tt := range tests {
+		got, err := parseWhitespaceRules(tt.value)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %+v, got %+v", tt.value, tt.want, got)
+		}
+	}
+
+	for _, value := range []string{"tab-in-indent", "-blank-at-eof", "trailing-space,bogus", "--trailing-space"} {
+		if _, err := parseWhitespaceRules(value); err == nil {
+			t.Errorf("%q: expected an unknown rule error", value)
+		}
+	}
+}
+
+func TestCheckWhitespace(t *testing.T) {
+	all := whitespaceRules{TrailingSpace: true, MixedIndent: true, MissingNewline: true}
+	tests := []struct {
+		name    string
+		content string
+		rules   whitespaceRules
+		want    []whitespaceProblem
+	}{
+		{"empty", "", all, nil},
+		{"clean", "a\n\tb\n    c\n", all, nil},
+		{"clean crlf", "a\r\nb\r\n", all, nil},
+		{"trailing space", "a \nb\n", all, []whitespaceProblem{
+			{Line: 1, Message: "trailing whitespace", Content: "a "},
+		}},
+		{"trailing tab", "a\nb\t\n", all, []whitespaceProblem{
+			{Line: 2, Message: "trailing whitespace", Content: "b\t"},
+		}},
+		{"trailing space before crlf", "a \r\n", all, []whitespaceProblem{
+			{Line: 1, Message: "trailing whitespace", Content: "a "},
+		}},
+		{"whitespace only line", "a\n  \n", all, []whitespaceProblem{
+			{Line: 2, Message: "trailing whitespace", Content: "  "},
+		}},
+		{"trailing space disabled", "a \n", whitespaceRules{MixedIndent: true, MissingNewline: true}, nil},
+		{"space before tab", " \tx\n", all, []whitespaceProblem{
+			{Line: 1, Message: "indent mixes tabs and spaces", Content: " \tx"},
+		}},
+		{"tab before space", "a\n\t x\n", all, []whitespaceProblem{
+			{Line: 2, Message: "indent mixes tabs and spaces", Content: "\t x"},
+		}},
+		{"tab and space after text", "x \ty\n", all, nil},
+		{"mixed indent disabled", " \tx\n", whitespaceRules{TrailingSpace: true, MissingNewline: true}, nil},
+		{"missing newline", "a\nb", all, []whitespaceProblem{
+			{Line: 2, Message: "no newline at end of file"},
+		}},
+		{"missing newline disabled", "a\nb", whitespaceRules{TrailingSpace: true, MixedIndent: true}, nil},
+		{"blank lines at end", "a\n\n\n", all, nil},
+		{"several problems", " \tx \ny", all, []whitespaceProblem{
+			{Line: 1, Message: "trailing whitespace", Content: " \tx "},
+			{Line: 1, Message: "indent mixes tabs and spaces", Content: " \tx "},
+			{Line: 2, Message: "no newline at end of file"},
+		}},
+		{"no rules", " \tx \ny", whitespaceRules{}, nil},
+	}
+	for _, tt := range tests {
+		got := checkWhitespace([]byte(tt.content), tt.rules)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
+	"github.com/javanhut/Ivaldi-vcs/internal/github"
+	"github.com/spf13/cobra"
+)
+
+var whoamiHost string
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show which GitHub account and token source are in use",
+	Long: `Diagnose GitHub authentication for a server.
+
+Reports where the token was found, the account it belongs to, the scopes it
+grants and the current API rate limit. The token itself is never printed.
+Without a token, lists every place that was searched.
+
+Examples:
+  ivaldi whoami                            # The configured GitHub server
+  ivaldi whoami --host ghe.example.com     # A GitHub Enterprise server`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endpoints := loginEndpoints(whoamiHost)
+		fmt.Printf("Host:        %s (%s)\n", colors.Bold(endpoints.Host), endpoints.APIURL)
+
+		method := github.ResolveAuth(endpoints)
+		if method == nil {
+			fmt.Printf("Token:       %s\n", colors.Red("none found"))
+			fmt.Println("\nSearched, in order:")
+			for _, label := range github.AuthSourceLabels(endpoints) {
+				fmt.Printf("  - %s\n", label)
+			}
+			fmt.Printf("\nStore a token with %s\n", colors.Cyan(loginHint(endpoints)))
+			return fmt.Errorf("not authenticated with %s", endpoints.Host)
+		}
+
+		source := strings.TrimPrefix(method.Description, "Authenticated via ")
+		fmt.Printf("Token from:  %s\n", source)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client := github.NewClientWithToken(endpoints, method.Token)
+		user, err := client.GetAuthenticatedUser(ctx)
+		if err != nil {
+			var apiErr *github.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+				fmt.Printf("Account:     %s\n", colors.Yellow("unknown"))
+				return fmt.Errorf("could not reach %s to verify the token: %w", endpoints.Host, err)
+			}
+
+			fmt.Printf("Account:     %s\n", colors.Red("token rejected"))
+			fmt.Printf("\nReplace or remove the token in %s, or store a new one with %s\n",
+				source, colors.Cyan(loginHint(endpoints)))
+			return fmt.Errorf("%s rejected the token", endpoints.Host)
+		}
+
+		account := colors.Green(user.Login)
+		if user.Name != "" {
+			account += fmt.Sprintf(" (%s)", user.Name)
+		}
+		fmt.Printf("Account:     %s\n", account)
+
+		if len(user.Scopes) > 0 {
+			fmt.Printf("Scopes:      %s\n", strings.Join(user.Scopes, ", "))
+		}
+
+		if limit := client.GetRateLimit(); limit.Limit > 0 {
+			line := fmt.Sprintf("%d/%d requests remaining", limit.Remaining, limit.Limit)
+			if !limit.Reset.IsZero() {
+				line += fmt.Sprintf(", resets at %s", limit.Reset.Local().Format("15:04:05"))
+			}
+			if limit.Remaining == 0 {
+				line = colors.Red(line)
+			}
+			fmt.Printf("Rate limit:  %s\n", line)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	whoamiCmd.Flags().StringVar(&whoamiHost, "host", "", "GitHub host to check (default: github.host or github.com)")
+}
+
+// loginHint returns the login command for a server
+func loginHint(endpoints github.Endpoints) string {
+	if endpoints.Host == github.ResolveEndpoints().Host {
+		return "ivaldi login"
+	}
+	return "ivaldi login --host " + endpoints.Host
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// setupRepository runs before every command: it moves into the working
+// tree and then checks that this build can read the repository's objects
+func setupRepository(cmd *cobra.Command, args []string) error {
+	if err := enterWorkTree(cmd); err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+	return checkHashAlgo(cmd, args)
+}
+
+// startDir is the directory the command was started in, and workPrefix
+// the same directory relative to the top of the working tree, with
+// slashes. workPrefix is empty at the top or outside the working tree.
+var startDir, workPrefix string
+
+// enterWorkTree makes the top of the working tree the current directory,
+// so commands can keep treating it as the working directory. The working
+// tree is $IVALDI_WORK_TREE, or core.worktree, or else the directory
+// holding the .ivaldi directory. The .ivaldi directory is $IVALDI_DIR, or
+// the first .ivaldi found in the current directory or one of its parents;
+// it is made absolute in the environment before moving, so later lookups
+// and hooks still find it. Both must exist, except for forge and download,
+// which create the repository here.
+func enterWorkTree(cmd *cobra.Command) error {
+	creating := cmd == initialCmd || cmd == downloadCmd
+
+	var err error
+	startDir, err = os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	workPrefix = ""
+	if !creating && os.Getenv(config.EnvIvaldiDir) == "" {
+		if root, ok := findRepoRoot(startDir); ok && root != startDir {
+			if err := os.Chdir(root); err != nil {
+				return fmt.Errorf("failed to enter repository: %w", err)
+			}
+		}
+	}
+	defer setWorkPrefix()
+
+	ivaldiDir, err := filepath.Abs(config.IvaldiDir())
+	if err != nil {
+		return fmt.Errorf("failed to resolve the .ivaldi directory: %w", err)
+	}
+	if os.Getenv(config.EnvIvaldiDir) != "" {
+		if err := os.Setenv(config.EnvIvaldiDir, ivaldiDir); err != nil {
+			return err
+		}
+		if info, err := os.Stat(ivaldiDir); !creating && (err != nil || !info.IsDir()) {
+			return fmt.Errorf("%s=%s is not an Ivaldi repository directory", config.EnvIvaldiDir, ivaldiDir)
+		}
+	}
+	if creating {
+		return nil
+	}
+
+	workTree, source := os.Getenv(config.EnvWorkTree), config.EnvWorkTree
+	if workTree == "" {
+		workTree, source = config.WorkTree(), "core.worktree"
+	}
+	if workTree == "" {
+		return nil
+	}
+	if info, err := os.Stat(workTree); err != nil || !info.IsDir() {
+		return fmt.Errorf("working tree %s (from %s) does not exist or is not a directory", workTree, source)
+	}
+
+	if err := os.Setenv(config.EnvIvaldiDir, ivaldiDir); err != nil {
+		return err
+	}
+	if err := os.Chdir(workTree); err != nil {
+		return fmt.Errorf("failed to enter working tree: %w", err)
+	}
+	return nil
+}
+
+// findRepoRoot returns the nearest of dir and its parents that holds an
+// .ivaldi directory
+func findRepoRoot(dir string) (string, bool) {
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".ivaldi")); err == nil && info.IsDir() {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// setWorkPrefix records where the command was started relative to the
+// working tree it now runs in
+func setWorkPrefix() {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel(workDir, startDir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	workPrefix = filepath.ToSlash(rel)
+}
+
+// argPath resolves a path given on the command line against the directory
+// the command was started in, since the command itself runs at the top of
+// the working tree
+func argPath(arg string) string {
+	if filepath.IsAbs(arg) || startDir == "" {
+		return arg
+	}
+	return filepath.Join(startDir, arg)
+}
+
+// hasDetachedWorkTree reports whether the working tree is set apart from
+// the .ivaldi directory, by core.worktree or $IVALDI_WORK_TREE
+func hasDetachedWorkTree() bool {
+	return os.Getenv(config.EnvWorkTree) != "" || config.WorkTree() != ""
+}
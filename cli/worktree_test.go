@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCommandsFromSubdirectory(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	t.Chdir(root)
+
+	run := func(args ...string) {
+		t.Helper()
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("ivaldi %v failed: %v", args, err)
+		}
+	}
+	run("forge")
+	run("config", "user.name", "Test")
+	run("config", "user.email", "test@example.com")
+
+	nested := filepath.Join(root, "src", "pkg")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"src/pkg/main.go", "src/pkg/util.go", "top.go"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Paths are taken from the nested directory and stored from the root
+	t.Chdir(nested)
+	run("gather", "main.go", "../../top.go")
+	staged, err := getStagedFiles(filepath.Join(root, ".ivaldi"))
+	if err != nil {
+		t.Fatalf("getStagedFiles failed: %v", err)
+	}
+	sort.Strings(staged)
+	if want := []string{"src/pkg/main.go", "top.go"}; !reflect.DeepEqual(staged, want) {
+		t.Errorf("Expected %v to be staged, got %v", want, staged)
+	}
+	if wd, _ := os.Getwd(); wd != root || workPrefix != "src/pkg" {
+		t.Errorf("Expected to run at %s from src/pkg, ran at %s from %q", root, wd, workPrefix)
+	}
+
+	t.Chdir(nested)
+	run("seal", "from a subdirectory")
+	t.Chdir(nested)
+	run("status")
+	if _, err := os.Stat(filepath.Join(nested, ".ivaldi")); !os.IsNotExist(err) {
+		t.Error("Expected no repository to be created in the subdirectory")
+	}
+
+	// A seal outside the subdirectory is left out of its log
+	t.Chdir(root)
+	if err := os.WriteFile(filepath.Join(root, "top.go"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("gather", "top.go")
+	run("seal", "outside the subdirectory")
+	t.Chdir(nested)
+	out := captureStdout(t, func() { run("log", "main.go") })
+	if !strings.Contains(out, "from a subdirectory") || strings.Contains(out, "outside the subdirectory") {
+		t.Errorf("Expected log of main.go from src/pkg to show only its seal, got:\n%s", out)
+	}
+
+	// Stash takes paths from the subdirectory too
+	for _, name := range []string{"src/pkg/main.go", "top.go"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("edited"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(nested)
+	run("stash", "push", "main.go")
+	if content, _ := os.ReadFile(filepath.Join(root, "src/pkg/main.go")); string(content) != "src/pkg/main.go" {
+		t.Errorf("Expected src/pkg/main.go to be stashed, got %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "top.go")); string(content) != "edited" {
+		t.Errorf("Expected top.go to be left alone, got %q", content)
+	}
+}
+
+// captureStdout returns what fn prints to standard output
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
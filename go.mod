@@ -6,7 +6,9 @@ require (
 	github.com/klauspost/compress v1.18.0
 	github.com/spf13/cobra v1.10.1
 	go.etcd.io/bbolt v1.4.3
+	go.yaml.in/yaml/v3 v3.0.4
 	golang.org/x/term v0.36.0
+	golang.org/x/text v0.30.0
 	lukechampine.com/blake3 v1.4.1
 )
 
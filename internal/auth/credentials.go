@@ -0,0 +1,339 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+)
+
+// CredentialStore saves access tokens keyed by host
+type CredentialStore interface {
+	// Get returns the token for host, or "" if none is stored
+	Get(host string) (string, error)
+	// Store saves the token for host, replacing any previous one
+	Store(host, token string) error
+	// Erase removes the token for host; erasing a missing token is not an error
+	Erase(host string) error
+	// Describe names the store for messages
+	Describe() string
+}
+
+// HelperStore is the credential.helper value selecting Ivaldi's own
+// encrypted credential file, which is also the default
+const HelperStore = "store"
+
+// NewCredentialStore returns the store selected by a credential.helper
+// value. Any value other than "" or "store" names an external helper
+// speaking git's credential protocol: a bare name such as "osxkeychain"
+// runs 'git credential-osxkeychain', a value starting with "!" runs as a
+// shell command, and anything else is run as given.
+func NewCredentialStore(helper string) (CredentialStore, error) {
+	helper = strings.TrimSpace(helper)
+	if helper == "" || helper == HelperStore {
+		dir, err := ivaldiConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		return newFileCredentialStore(dir), nil
+	}
+	return newHelperCredentialStore(helper), nil
+}
+
+// ConfiguredCredentialStore returns the store selected by the
+// credential.helper config key
+func ConfiguredCredentialStore() (CredentialStore, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewCredentialStore(cfg.Credential.Helper)
+}
+
+// CredentialToken returns the token saved by 'ivaldi login' for host, or
+// "" if there is none or the store cannot be read
+func CredentialToken(host string) string {
+	store, err := ConfiguredCredentialStore()
+	if err != nil {
+		return ""
+	}
+	token, err := store.Get(host)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// ivaldiConfigDir returns Ivaldi's per-user config directory, creating it
+func ivaldiConfigDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	dir := filepath.Join(home, ".config", "ivaldi")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return dir, nil
+}
+
+// fileCredentialStore keeps tokens in credentials.json, encrypted with
+// AES-256-GCM under a random key in credentials.key. Both files are
+// readable only by the user; the encryption keeps tokens out of plain
+// sight in backups and file listings rather than protecting them from
+// someone who can read the user's files.
+type fileCredentialStore struct {
+	path    string
+	keyPath string
+}
+
+// credentialFile is the on-disk layout of credentials.json
+type credentialFile struct {
+	Hosts map[string]storedCredential `json:"hosts"`
+}
+
+// storedCredential is one encrypted token
+type storedCredential struct {
+	Token     string    `json:"token"` // base64 of nonce followed by ciphertext
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newFileCredentialStore(dir string) *fileCredentialStore {
+	return &fileCredentialStore{
+		path:    filepath.Join(dir, "credentials.json"),
+		keyPath: filepath.Join(dir, "credentials.key"),
+	}
+}
+
+func (s *fileCredentialStore) Describe() string {
+	return s.path
+}
+
+func (s *fileCredentialStore) Get(host string) (string, error) {
+	file, err := s.load()
+	if err != nil {
+		return "", err
+	}
+
+	cred, ok := file.Hosts[host]
+	if !ok {
+		return "", nil
+	}
+
+	key, err := s.key(false)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(cred.Token)
+	if err != nil {
+		return "", fmt.Errorf("corrupt credential for %s: %w", host, err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("corrupt credential for %s", host)
+	}
+
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(host))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt credential for %s: %w", host, err)
+	}
+	return string(plain), nil
+}
+
+func (s *fileCredentialStore) Store(host, token string) error {
+	file, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	key, err := s.key(true)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(token), []byte(host))
+
+	file.Hosts[host] = storedCredential{
+		Token:     base64.StdEncoding.EncodeToString(sealed),
+		CreatedAt: time.Now(),
+	}
+	return s.save(file)
+}
+
+func (s *fileCredentialStore) Erase(host string) error {
+	file, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := file.Hosts[host]; !ok {
+		return nil
+	}
+
+	delete(file.Hosts, host)
+	return s.save(file)
+}
+
+func (s *fileCredentialStore) load() (*credentialFile, error) {
+	file := &credentialFile{Hosts: make(map[string]storedCredential)}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	if file.Hosts == nil {
+		file.Hosts = make(map[string]storedCredential)
+	}
+	return file, nil
+}
+
+func (s *fileCredentialStore) save(file *credentialFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	return nil
+}
+
+// key returns the encryption key, generating it first if create is set
+func (s *fileCredentialStore) key(create bool) ([]byte, error) {
+	key, err := os.ReadFile(s.keyPath)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid credential key in %s", s.keyPath)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("failed to read credential key: %w", err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate credential key: %w", err)
+	}
+	if err := os.WriteFile(s.keyPath, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write credential key: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// helperUsername is the user name Ivaldi stores its tokens under, keeping
+// them apart from the user's own git credentials for the same host
+const helperUsername = "ivaldi"
+
+// helperCredentialStore delegates to an external program speaking git's
+// credential helper protocol, such as an OS keyring integration
+type helperCredentialStore struct {
+	helper string
+}
+
+func newHelperCredentialStore(helper string) *helperCredentialStore {
+	return &helperCredentialStore{helper: helper}
+}
+
+func (s *helperCredentialStore) Describe() string {
+	return fmt.Sprintf("credential helper '%s'", s.helper)
+}
+
+func (s *helperCredentialStore) Get(host string) (string, error) {
+	output, err := s.run("get", host, "")
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if password, ok := strings.CutPrefix(line, "password="); ok {
+			return password, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *helperCredentialStore) Store(host, token string) error {
+	_, err := s.run("store", host, token)
+	return err
+}
+
+func (s *helperCredentialStore) Erase(host string) error {
+	_, err := s.run("erase", host, "")
+	return err
+}
+
+// run invokes the helper with an action and a credential description on
+// stdin, returning its output
+func (s *helperCredentialStore) run(action, host, token string) ([]byte, error) {
+	var cmd *exec.Cmd
+	switch {
+	case strings.HasPrefix(s.helper, "!"):
+		cmd = exec.Command("sh", "-c", strings.TrimPrefix(s.helper, "!")+" "+action)
+	default:
+		fields := strings.Fields(s.helper)
+		if !strings.ContainsRune(fields[0], filepath.Separator) {
+			fields = append([]string{"git", "credential-" + fields[0]}, fields[1:]...)
+		}
+		cmd = exec.Command(fields[0], append(fields[1:], action)...)
+	}
+
+	var input bytes.Buffer
+	fmt.Fprintf(&input, "protocol=https\nhost=%s\nusername=%s\n", host, helperUsername)
+	if token != "" {
+		fmt.Fprintf(&input, "password=%s\n", token)
+	}
+	input.WriteString("\n")
+	cmd.Stdin = &input
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("credential helper '%s %s' failed: %s", s.helper, action, msg)
+	}
+	return output, nil
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileCredentialStore(t *testing.T) {
+	dir := t.TempDir()
+	store := newFileCredentialStore(dir)
+
+	if token, err := store.Get("github.com"); err != nil || token != "" {
+		t.Fatalf("Expected no token in empty store, got %q, %v", token, err)
+	}
+
+	if err := store.Store("github.com", "ghp_public"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if err := store.Store("ghe.example.com", "ghp_enterprise"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// A fresh store over the same directory must read the saved tokens
+	store = newFileCredentialStore(dir)
+	for host, want := range map[string]string{"github.com": "ghp_public", "ghe.example.com": "ghp_enterprise"} {
+		got, err := store.Get(host)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", host, err)
+		}
+		if got != want {
+			t.Errorf("Get(%s) = %q, want %q", host, got, want)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "credentials.json"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if strings.Contains(string(data), "ghp_") {
+		t.Error("Token stored in plaintext")
+	}
+
+	for _, name := range []string{"credentials.json", "credentials.key"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("%s has mode %o, want 600", name, perm)
+		}
+	}
+
+	if err := store.Erase("github.com"); err != nil {
+		t.Fatalf("Erase failed: %v", err)
+	}
+	if token, _ := store.Get("github.com"); token != "" {
+		t.Errorf("Expected erased token to be gone, got %q", token)
+	}
+	if token, _ := store.Get("ghe.example.com"); token != "ghp_enterprise" {
+		t.Errorf("Erase removed another host's token, got %q", token)
+	}
+	if err := store.Erase("github.com"); err != nil {
+		t.Errorf("Erasing a missing token failed: %v", err)
+	}
+}
+
+func TestFileCredentialStoreTampered(t *testing.T) {
+	dir := t.TempDir()
+	store := newFileCredentialStore(dir)
+
+	if err := store.Store("github.com", "ghp_public"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// Moving a token to another host must not decrypt, since the host is
+	// bound to the ciphertext
+	path := filepath.Join(dir, "credentials.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	var file credentialFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	file.Hosts["evil.example.com"] = file.Hosts["github.com"]
+	data, _ = json.Marshal(file)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := store.Get("evil.example.com"); err == nil {
+		t.Error("Expected error decrypting a token copied to another host")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "credentials.key"), make([]byte, 32), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := store.Get("github.com"); err == nil {
+		t.Error("Expected error decrypting with the wrong key")
+	}
+}
+
+func TestHelperCredentialStore(t *testing.T) {
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "saved")
+
+	// A minimal helper that keeps one password in a file
+	script := filepath.Join(dir, "helper.sh")
+	content := `#!/bin/sh
+input=$(cat)
+case "$1" in
+get) [ -f "` + saved + `" ] && echo "password=$(cat "` + saved + `")" ;;
+store) echo "$input" | sed -n 's/^password=//p' > "` + saved + `" ;;
+erase) rm -f "` + saved + `" ;;
+esac
+exit 0
+`
+	if err := os.WriteFile(script, []byte(content), 0700); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	store, err := NewCredentialStore("!" + script)
+	if err != nil {
+		t.Fatalf("NewCredentialStore failed: %v", err)
+	}
+
+	if token, err := store.Get("github.com"); err != nil || token != "" {
+		t.Fatalf("Expected no token, got %q, %v", token, err)
+	}
+	if err := store.Store("github.com", "ghp_helper"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if token, err := store.Get("github.com"); err != nil || token != "ghp_helper" {
+		t.Errorf("Get = %q, %v; want ghp_helper", token, err)
+	}
+	if err := store.Erase("github.com"); err != nil {
+		t.Fatalf("Erase failed: %v", err)
+	}
+	if token, _ := store.Get("github.com"); token != "" {
+		t.Errorf("Expected erased token to be gone, got %q", token)
+	}
+
+	failing, _ := NewCredentialStore("!exit 1")
+	if err := failing.Store("github.com", "ghp_helper"); err == nil {
+		t.Error("Expected error from a failing helper")
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// GitHubHost is the host whose GitHub CLI credentials Ivaldi uses
+const GitHubHost = "github.com"
+
+// ghHostConfig is one host entry of the GitHub CLI's hosts.yml
+type ghHostConfig struct {
+	OAuthToken string `yaml:"oauth_token"`
+	User       string `yaml:"user"`
+	// Users holds per-account entries written by gh 2.40 and later when
+	// several accounts are logged in to the same host
+	Users map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	} `yaml:"users"`
+}
+
+// ghHostsPath returns the location of the GitHub CLI's hosts.yml, following
+// the same lookup order as gh itself
+func ghHostsPath() (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml"), nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gh", "hosts.yml"), nil
+}
+
+// GHCLIToken returns the token stored by 'gh auth login' for host, or ""
+// if the GitHub CLI is not set up for it. Tokens kept in the system
+// keyring rather than hosts.yml are not available.
+func GHCLIToken(host string) string {
+	path, err := ghHostsPath()
+	if err != nil {
+		return ""
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	token, err := parseGHHostsToken(content, host)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// parseGHHostsToken extracts the OAuth token for host from the contents of
+// a hosts.yml file. Host names are matched case-insensitively; tokens of
+// other hosts, such as GitHub Enterprise servers, are never returned.
+func parseGHHostsToken(content []byte, host string) (string, error) {
+	var hosts map[string]ghHostConfig
+	if err := yaml.Unmarshal(content, &hosts); err != nil {
+		return "", fmt.Errorf("parse gh hosts file: %w", err)
+	}
+
+	for name, cfg := range hosts {
+		if !strings.EqualFold(strings.TrimSpace(name), host) {
+			continue
+		}
+		if cfg.OAuthToken != "" {
+			return cfg.OAuthToken, nil
+		}
+		if user, ok := cfg.Users[cfg.User]; ok && user.OAuthToken != "" {
+			return user.OAuthToken, nil
+		}
+		return "", nil
+	}
+
+	return "", nil
+}
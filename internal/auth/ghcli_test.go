@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGHHostsToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "single host",
+			content: `github.com:
+    user: octocat
+    oauth_token: gho_single
+    git_protocol: https
+`,
+			want: "gho_single",
+		},
+		{
+			name: "enterprise host listed first",
+			content: `ghe.example.com:
+    user: work
+    oauth_token: gho_enterprise
+github.com:
+    user: octocat
+    oauth_token: gho_public
+`,
+			want: "gho_public",
+		},
+		{
+			name: "enterprise host only",
+			content: `ghe.example.com:
+    oauth_token: gho_enterprise
+    git_protocol: ssh
+`,
+			want: "",
+		},
+		{
+			name: "two-space indentation and quoted values",
+			content: `"github.com":
+  oauth_token: "gho_quoted"
+  user: 'octocat'
+`,
+			want: "gho_quoted",
+		},
+		{
+			name: "token key ordering and comments",
+			content: `# written by gh
+github.com:
+    git_protocol: https # protocol
+    users:
+        octocat:
+            oauth_token: gho_nested
+    user: octocat
+    oauth_token: gho_top
+`,
+			want: "gho_top",
+		},
+		{
+			name: "multi-account entry",
+			content: `github.com:
+    git_protocol: https
+    users:
+        other:
+            oauth_token: gho_other
+        octocat:
+            oauth_token: gho_active
+    user: octocat
+`,
+			want: "gho_active",
+		},
+		{
+			name: "token in keyring",
+			content: `github.com:
+    git_protocol: https
+    users:
+        octocat:
+    user: octocat
+`,
+			want: "",
+		},
+		{
+			name:    "host name case",
+			content: "GitHub.com:\n    oauth_token: gho_case\n",
+			want:    "gho_case",
+		},
+		{
+			name:    "empty file",
+			content: "",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGHHostsToken([]byte(tt.content), GitHubHost)
+			if err != nil {
+				t.Fatalf("parseGHHostsToken failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected token %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseGHHostsTokenInvalid(t *testing.T) {
+	if _, err := parseGHHostsToken([]byte("github.com: [unclosed"), GitHubHost); err == nil {
+		t.Error("Expected error for malformed YAML")
+	}
+}
+
+func TestGHCLITokenConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GH_CONFIG_DIR", dir)
+
+	if token := GHCLIToken(GitHubHost); token != "" {
+		t.Errorf("Expected no token without hosts.yml, got %q", token)
+	}
+
+	content := "github.com:\n    oauth_token: gho_from_file\n"
+	if err := os.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if token := GHCLIToken(GitHubHost); token != "gho_from_file" {
+		t.Errorf("Expected token from hosts.yml, got %q", token)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "hosts.yml"), []byte("\t: bad"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if token := GHCLIToken(GitHubHost); token != "" {
+		t.Errorf("Expected no token from malformed hosts.yml, got %q", token)
+	}
+}
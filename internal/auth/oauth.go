@@ -12,6 +12,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/httpclient"
 )
 
 const (
@@ -127,7 +129,10 @@ func RequestDeviceCode(ctx context.Context) (*DeviceCodeResponse, error) {
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client, err := httpclient.New(30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to request device code: %w", err)
@@ -190,7 +195,10 @@ func checkAccessToken(ctx context.Context, deviceCode string) (*Token, error) {
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client, err := httpclient.New(30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to request access token: %w", err)
@@ -251,6 +259,15 @@ type AuthMethod struct {
 
 // GetAuthMethod returns the active authentication method
 func GetAuthMethod() *AuthMethod {
+	// Check the credential store written by 'ivaldi login'
+	if token := CredentialToken(GitHubHost); token != "" {
+		return &AuthMethod{
+			Name:        "credential",
+			Description: "Authenticated via 'ivaldi login'",
+			Token:       token,
+		}
+	}
+
 	// 1. Check Ivaldi OAuth token
 	if token, err := GetToken(); err == nil && token != "" {
 		return &AuthMethod{
@@ -366,33 +383,7 @@ func getNetrcToken(machine string) string {
 }
 
 func getGHCLIToken() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ""
-	}
-
-	ghConfigPath := filepath.Join(home, ".config", "gh", "hosts.yml")
-	content, err := os.ReadFile(ghConfigPath)
-	if err != nil {
-		return ""
-	}
-
-	lines := strings.Split(string(content), "\n")
-	for i, line := range lines {
-		if strings.Contains(line, "oauth_token:") {
-			parts := strings.Split(line, ":")
-			if len(parts) >= 2 {
-				return strings.TrimSpace(parts[1])
-			}
-		} else if strings.Contains(line, "token:") && i > 0 && strings.Contains(lines[i-1], "github.com") {
-			parts := strings.Split(line, ":")
-			if len(parts) >= 2 {
-				return strings.TrimSpace(parts[1])
-			}
-		}
-	}
-
-	return ""
+	return GHCLIToken(GitHubHost)
 }
 
 // Login performs the OAuth device flow login
@@ -17,6 +17,10 @@ func (h Hash) String() string {
 	return hex.EncodeToString(h[:])
 }
 
+// HashAlgorithm names the hash objects are stored under, as recorded in a
+// repository's core.hashAlgo.
+const HashAlgorithm = "blake3"
+
 // SumB3 computes the BLAKE3 hash of the given data.
 func SumB3(data []byte) Hash {
 	return blake3.Sum256(data)
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package cas
+
+import "os"
+
+// diskUsage returns the length of a file where block usage is unavailable.
+func diskUsage(info os.FileInfo) int64 {
+	return info.Size()
+}
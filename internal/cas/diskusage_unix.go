@@ -0,0 +1,17 @@
+//go:build unix
+
+package cas
+
+import (
+	"os"
+	"syscall"
+)
+
+// diskUsage returns the space a file occupies on disk, which for small
+// files is a whole block rather than their length.
+func diskUsage(info os.FileInfo) int64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(stat.Blocks) * 512
+	}
+	return info.Size()
+}
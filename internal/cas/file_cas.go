@@ -7,11 +7,18 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 // FileCAS implements CAS using file system storage.
 type FileCAS struct {
 	root string
+
+	// Packs written by Repack, loaded on first use
+	packMu        sync.Mutex
+	packs         []*packFile
+	packSignature string
+	packsLoaded   bool
 }
 
 // NewFileCAS creates a new file-based CAS in the given directory.
@@ -55,6 +62,9 @@ func (f *FileCAS) Put(hash Hash, data []byte) error {
 	if _, err := os.Stat(path); err == nil {
 		return nil // Already exists, nothing to do
 	}
+	if p, err := f.findPacked(hash); err == nil && p != nil {
+		return nil // Already packed
+	}
 	
 	// Write to temporary file first, then rename (atomic operation)
 	tmpPath := path + ".tmp"
@@ -92,7 +102,7 @@ func (f *FileCAS) Get(hash Hash) ([]byte, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("hash not found: %s", hash.String())
+			return f.getFromPack(hash)
 		}
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
@@ -119,10 +129,26 @@ func (f *FileCAS) Has(hash Hash) (bool, error) {
 	_, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return false, nil
+			p, err := f.findPacked(hash)
+			return p != nil, err
 		}
 		return false, fmt.Errorf("failed to check file: %w", err)
 	}
 	
 	return true, nil
-}
\ No newline at end of file
+}
+
+// getFromPack reads an object that is not stored loose.
+func (f *FileCAS) getFromPack(hash Hash) ([]byte, error) {
+	data, err := f.getPacked(hash)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("hash not found: %s", hash.String())
+	}
+	if SumB3(data) != hash {
+		return nil, fmt.Errorf("corrupted data: hash mismatch for %s", hash.String())
+	}
+	return data, nil
+}
@@ -0,0 +1,833 @@
+package cas
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Packs hold objects moved out of loose storage by Repack. A pack is a data
+// file of segments and an index listing, for every object, the segments
+// whose concatenation is the object's bytes. A segment shared by several
+// objects is stored once. Objects keep their hashes: packing changes only
+// how bytes are laid out on disk, never what they are.
+//
+// Index layout (little endian):
+//
+//	"IVPK" | u32 version
+//	u32 segmentCount | (hash[32] | u64 offset | u32 length) * segmentCount
+//	u32 objectCount  | (hash[32] | u32 n | u32 segmentOrdinal * n) * objectCount
+//	BLAKE3 of everything above
+const (
+	packDirName = "pack"
+	packMagic   = "IVPK"
+	packVersion = 1
+)
+
+// packSegment locates a segment in a pack's data file.
+type packSegment struct {
+	Hash   Hash
+	Offset int64
+	Length uint32
+}
+
+// packFile is a loaded pack index. The data file is read on demand.
+type packFile struct {
+	name     string
+	dataPath string
+	segments []packSegment
+	objects  map[Hash][]uint32 // object hash -> segment ordinals
+}
+
+// read assembles the bytes of an object stored in the pack.
+func (p *packFile) read(hash Hash) ([]byte, error) {
+	ordinals, ok := p.objects[hash]
+	if !ok {
+		return nil, fmt.Errorf("hash not found: %s", hash.String())
+	}
+
+	file, err := os.Open(p.dataPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var size int
+	for _, ordinal := range ordinals {
+		size += int(p.segments[ordinal].Length)
+	}
+
+	data := make([]byte, 0, size)
+	for _, ordinal := range ordinals {
+		seg := p.segments[ordinal]
+		buf := make([]byte, seg.Length)
+		if _, err := file.ReadAt(buf, seg.Offset); err != nil {
+			return nil, fmt.Errorf("failed to read pack %s: %w", p.name, err)
+		}
+		data = append(data, buf...)
+	}
+	return data, nil
+}
+
+// encodePackIndex serializes segments and objects into the index format.
+func encodePackIndex(segments []packSegment, objects map[Hash][]uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(packMagic)
+	binary.Write(&buf, binary.LittleEndian, uint32(packVersion))
+
+	binary.Write(&buf, binary.LittleEndian, uint32(len(segments)))
+	for _, seg := range segments {
+		buf.Write(seg.Hash[:])
+		binary.Write(&buf, binary.LittleEndian, uint64(seg.Offset))
+		binary.Write(&buf, binary.LittleEndian, seg.Length)
+	}
+
+	// Sort objects so the same content always yields the same index
+	hashes := make([]Hash, 0, len(objects))
+	for hash := range objects {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+
+	binary.Write(&buf, binary.LittleEndian, uint32(len(hashes)))
+	for _, hash := range hashes {
+		ordinals := objects[hash]
+		buf.Write(hash[:])
+		binary.Write(&buf, binary.LittleEndian, uint32(len(ordinals)))
+		for _, ordinal := range ordinals {
+			binary.Write(&buf, binary.LittleEndian, ordinal)
+		}
+	}
+
+	sum := SumB3(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes()
+}
+
+// decodePackIndex parses an index written by encodePackIndex.
+func decodePackIndex(data []byte) ([]packSegment, map[Hash][]uint32, error) {
+	if len(data) < len(packMagic)+4+32 {
+		return nil, nil, fmt.Errorf("pack index too short")
+	}
+
+	body, trailer := data[:len(data)-32], data[len(data)-32:]
+	if sum := SumB3(body); !bytes.Equal(sum[:], trailer) {
+		return nil, nil, fmt.Errorf("pack index checksum mismatch")
+	}
+	if string(body[:len(packMagic)]) != packMagic {
+		return nil, nil, fmt.Errorf("not a pack index")
+	}
+
+	r := bytes.NewReader(body[len(packMagic):])
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, nil, err
+	}
+	if version != packVersion {
+		return nil, nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+
+	var segmentCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &segmentCount); err != nil {
+		return nil, nil, err
+	}
+	if int64(segmentCount)*44 > int64(r.Len()) {
+		return nil, nil, fmt.Errorf("pack index truncated")
+	}
+	segments := make([]packSegment, segmentCount)
+	for i := range segments {
+		var offset uint64
+		if _, err := io.ReadFull(r, segments[i].Hash[:]); err != nil {
+			return nil, nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &offset); err != nil {
+			return nil, nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &segments[i].Length); err != nil {
+			return nil, nil, err
+		}
+		segments[i].Offset = int64(offset)
+	}
+
+	var objectCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &objectCount); err != nil {
+		return nil, nil, err
+	}
+	objects := make(map[Hash][]uint32, objectCount)
+	for i := uint32(0); i < objectCount; i++ {
+		var hash Hash
+		var n uint32
+		if _, err := io.ReadFull(r, hash[:]); err != nil {
+			return nil, nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, nil, err
+		}
+		if int64(n)*4 > int64(r.Len()) {
+			return nil, nil, fmt.Errorf("pack index truncated")
+		}
+		ordinals := make([]uint32, n)
+		if err := binary.Read(r, binary.LittleEndian, ordinals); err != nil {
+			return nil, nil, err
+		}
+		for _, ordinal := range ordinals {
+			if ordinal >= segmentCount {
+				return nil, nil, fmt.Errorf("pack index references missing segment %d", ordinal)
+			}
+		}
+		objects[hash] = ordinals
+	}
+
+	return segments, objects, nil
+}
+
+// loadPackFile reads the index of the pack with the given base path.
+func loadPackFile(base string) (*packFile, error) {
+	data, err := os.ReadFile(base + ".idx")
+	if err != nil {
+		return nil, err
+	}
+
+	segments, objects, err := decodePackIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pack %s: %w", filepath.Base(base), err)
+	}
+
+	return &packFile{
+		name:     filepath.Base(base),
+		dataPath: base + ".dat",
+		segments: segments,
+		objects:  objects,
+	}, nil
+}
+
+// packDir returns the directory holding the CAS's packs.
+func (f *FileCAS) packDir() string {
+	return filepath.Join(f.root, packDirName)
+}
+
+// listPackNames returns the base names of all published packs, sorted.
+func (f *FileCAS) listPackNames() ([]string, error) {
+	entries, err := os.ReadDir(f.packDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packs: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".idx"); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// reloadPacks refreshes the loaded packs if the set on disk has changed,
+// reporting whether it did. Callers must hold packMu.
+func (f *FileCAS) reloadPacks() (bool, error) {
+	names, err := f.listPackNames()
+	if err != nil {
+		return false, err
+	}
+
+	signature := strings.Join(names, ",")
+	if f.packsLoaded && signature == f.packSignature {
+		return false, nil
+	}
+
+	loaded := make(map[string]*packFile, len(f.packs))
+	for _, p := range f.packs {
+		loaded[p.name] = p
+	}
+
+	packs := make([]*packFile, 0, len(names))
+	for _, name := range names {
+		if p, ok := loaded[name]; ok {
+			packs = append(packs, p)
+			continue
+		}
+		p, err := loadPackFile(filepath.Join(f.packDir(), name))
+		if os.IsNotExist(err) {
+			continue // Removed by a concurrent repack
+		}
+		if err != nil {
+			return false, err
+		}
+		packs = append(packs, p)
+	}
+
+	f.packs = packs
+	f.packSignature = signature
+	f.packsLoaded = true
+	return true, nil
+}
+
+// findPacked returns the pack holding hash, reloading the pack list once if
+// it is not found in the packs already loaded.
+func (f *FileCAS) findPacked(hash Hash) (*packFile, error) {
+	f.packMu.Lock()
+	defer f.packMu.Unlock()
+
+	if f.packsLoaded {
+		if p := f.packHolding(hash); p != nil {
+			return p, nil
+		}
+	}
+
+	// Packs may have been written or replaced since they were loaded
+	changed, err := f.reloadPacks()
+	if err != nil || !changed {
+		return nil, err
+	}
+	return f.packHolding(hash), nil
+}
+
+// packHolding searches the loaded packs. Callers must hold packMu.
+func (f *FileCAS) packHolding(hash Hash) *packFile {
+	for _, p := range f.packs {
+		if _, ok := p.objects[hash]; ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// getPacked reads an object from the packs, or returns nil if no pack has it.
+func (f *FileCAS) getPacked(hash Hash) ([]byte, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		p, err := f.findPacked(hash)
+		if err != nil || p == nil {
+			return nil, err
+		}
+
+		data, err := p.read(hash)
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		// The pack was replaced by a concurrent repack; look again
+		f.packMu.Lock()
+		f.packsLoaded = false
+		f.packMu.Unlock()
+	}
+	return nil, nil
+}
+
+// RepackOptions control FileCAS.Repack.
+type RepackOptions struct {
+	// ContentDefined splits objects into content-defined segments so that
+	// objects sharing runs of bytes, such as successive versions of a
+	// large file, store those bytes once
+	ContentDefined bool
+	// DryRun computes the result without writing or removing anything
+	DryRun bool
+	// LooseOnly packs only the loose objects into a new pack and leaves
+	// existing packs alone, which keeps the cost proportional to the
+	// number of loose objects
+	LooseOnly bool
+	// Deadline, when set, makes Repack give up with ErrRepackDeadline once
+	// it has passed. Nothing is changed unless the new pack was published.
+	Deadline time.Time
+	// Keep, when set, is asked about every object with its content and
+	// when it was written: the modification time of its loose file or of
+	// its pack. Objects it rejects are pruned, left out of the new pack
+	// and removed along with what the pack replaces.
+	Keep func(hash Hash, content []byte, written time.Time) bool
+}
+
+// ErrRepackDeadline is returned by Repack when RepackOptions.Deadline passed
+// before the new pack was complete.
+var ErrRepackDeadline = errors.New("repack did not finish before its deadline")
+
+// RepackStats describes the outcome of FileCAS.Repack.
+type RepackStats struct {
+	Objects       int   // Objects in the new pack
+	LooseObjects  int   // Loose objects moved into the pack
+	PackedObjects int   // Objects carried over from earlier packs
+	Skipped       int   // Loose files left in place because they are not CAS objects
+	Pruned        int   // Objects rejected by RepackOptions.Keep
+	Segments      int   // Distinct segments stored
+	SizeBefore    int64 // Disk space used by loose objects and packs before repacking
+	SizeAfter     int64 // Disk space used by the new pack
+}
+
+// looseObject is a loose file whose name matches its content hash.
+type looseObject struct {
+	hash    Hash
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// CountLooseObjects returns the number of loose files named like objects.
+// Unlike Repack it does not read them, so files not stored by content hash
+// are counted too.
+func (f *FileCAS) CountLooseObjects() (int, error) {
+	dirs, err := os.ReadDir(f.root)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	count := 0
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(f.root, dir.Name()))
+		if err != nil {
+			return 0, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && len(entry.Name()) == 62 {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// FindPrefix returns the hashes of the loose and packed objects whose hex
+// form starts with prefix, sorted. The prefix must be at least two hex
+// digits.
+func (f *FileCAS) FindPrefix(prefix string) ([]Hash, error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < 2 || len(prefix) > 2*len(Hash{}) || strings.Trim(prefix, "0123456789abcdef") != "" {
+		return nil, fmt.Errorf("invalid object prefix %q", prefix)
+	}
+
+	found := make(map[Hash]bool)
+	entries, err := os.ReadDir(filepath.Join(f.root, prefix[:2]))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	for _, entry := range entries {
+		name := prefix[:2] + entry.Name()
+		if entry.IsDir() || len(name) != 2*len(Hash{}) || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		raw, err := hex.DecodeString(name)
+		if err != nil {
+			continue
+		}
+		found[Hash(raw)] = true
+	}
+
+	f.packMu.Lock()
+	_, err = f.reloadPacks()
+	if err == nil {
+		for _, p := range f.packs {
+			for hash := range p.objects {
+				if strings.HasPrefix(hash.String(), prefix) {
+					found[hash] = true
+				}
+			}
+		}
+	}
+	f.packMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]Hash, 0, len(found))
+	for hash := range found {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+	return hashes, nil
+}
+
+// listLooseObjects returns the loose objects under the CAS root, and the
+// number of files that look like objects but fail verification.
+func (f *FileCAS) listLooseObjects() ([]looseObject, int, error) {
+	dirs, err := os.ReadDir(f.root)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var loose []looseObject
+	skipped := 0
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(f.root, dir.Name()))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || len(entry.Name()) != 62 {
+				continue // Temp files and anything else that is not an object
+			}
+
+			raw, err := hex.DecodeString(dir.Name() + entry.Name())
+			if err != nil {
+				continue
+			}
+			var hash Hash
+			copy(hash[:], raw)
+
+			path := filepath.Join(f.root, dir.Name(), entry.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to read object %s: %w", hash.String(), err)
+			}
+			// Files stored under another naming scheme, such as objects
+			// imported from Git, stay loose
+			if SumB3(data) != hash {
+				skipped++
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to read object %s: %w", hash.String(), err)
+			}
+			loose = append(loose, looseObject{hash: hash, path: path, size: diskUsage(info), modTime: info.ModTime()})
+		}
+	}
+
+	return loose, skipped, nil
+}
+
+// PruneStats describes the outcome of FileCAS.PruneLoose.
+type PruneStats struct {
+	Objects int   // Loose objects examined
+	Pruned  int   // Loose objects rejected by keep and removed
+	Size    int64 // Disk space the pruned objects used
+}
+
+// PruneLoose removes the loose objects that keep rejects, leaving packs
+// alone. keep is asked about every loose object as in RepackOptions.Keep.
+// With dryRun nothing is removed.
+func (f *FileCAS) PruneLoose(keep func(hash Hash, content []byte, written time.Time) bool, dryRun bool) (*PruneStats, error) {
+	loose, _, err := f.listLooseObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &PruneStats{Objects: len(loose)}
+	for _, obj := range loose {
+		content, err := os.ReadFile(obj.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read object %s: %w", obj.hash.String(), err)
+		}
+		if keep(obj.hash, content, obj.modTime) {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(obj.path); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to remove loose object: %w", err)
+			}
+			os.Remove(filepath.Dir(obj.path)) // Only succeeds once the directory is empty
+		}
+		stats.Pruned++
+		stats.Size += obj.size
+	}
+	return stats, nil
+}
+
+// Repack moves every loose object and the contents of all existing packs
+// into a single new pack, then removes what it replaced. Objects remain
+// readable by their original hashes throughout, so concurrent readers are
+// unaffected.
+func (f *FileCAS) Repack(opts RepackOptions) (*RepackStats, error) {
+	f.packMu.Lock()
+	_, err := f.reloadPacks()
+	oldPacks := append([]*packFile(nil), f.packs...)
+	f.packMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if opts.LooseOnly {
+		oldPacks = nil
+	}
+
+	expired := func() bool {
+		return !opts.Deadline.IsZero() && time.Now().After(opts.Deadline)
+	}
+
+	loose, skipped, err := f.listLooseObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &RepackStats{Skipped: skipped}
+	for _, obj := range loose {
+		stats.SizeBefore += obj.size
+	}
+	for _, p := range oldPacks {
+		for _, ext := range []string{".idx", ".dat"} {
+			if info, err := os.Stat(filepath.Join(f.packDir(), p.name+ext)); err == nil {
+				stats.SizeBefore += diskUsage(info)
+			}
+		}
+	}
+
+	var data *os.File
+	if !opts.DryRun {
+		if err := os.MkdirAll(f.packDir(), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create pack directory: %w", err)
+		}
+		data, err = os.CreateTemp(f.packDir(), "tmp-*.dat")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create pack: %w", err)
+		}
+		defer func() {
+			data.Close()
+			os.Remove(data.Name())
+		}()
+	}
+
+	var segments []packSegment
+	segmentOrdinals := make(map[Hash]uint32)
+	objects := make(map[Hash][]uint32)
+	pruned := make(map[Hash]bool)
+	var offset int64
+
+	add := func(hash Hash, content []byte, written time.Time) error {
+		if _, done := objects[hash]; done || pruned[hash] {
+			return nil
+		}
+		if opts.Keep != nil && !opts.Keep(hash, content, written) {
+			pruned[hash] = true
+			return nil
+		}
+
+		parts := [][]byte{content}
+		if opts.ContentDefined {
+			parts = splitContentDefined(content)
+		}
+
+		ordinals := make([]uint32, 0, len(parts))
+		for _, part := range parts {
+			segHash := SumB3(part)
+			ordinal, ok := segmentOrdinals[segHash]
+			if !ok {
+				if data != nil {
+					if _, err := data.Write(part); err != nil {
+						return fmt.Errorf("failed to write pack: %w", err)
+					}
+				}
+				ordinal = uint32(len(segments))
+				segments = append(segments, packSegment{Hash: segHash, Offset: offset, Length: uint32(len(part))})
+				segmentOrdinals[segHash] = ordinal
+				offset += int64(len(part))
+			}
+			ordinals = append(ordinals, ordinal)
+		}
+		objects[hash] = ordinals
+		return nil
+	}
+
+	for _, obj := range loose {
+		if expired() {
+			return nil, ErrRepackDeadline
+		}
+		content, err := os.ReadFile(obj.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read object %s: %w", obj.hash.String(), err)
+		}
+		if err := add(obj.hash, content, obj.modTime); err != nil {
+			return nil, err
+		}
+		if _, kept := objects[obj.hash]; kept {
+			stats.LooseObjects++
+		}
+	}
+
+	for _, p := range oldPacks {
+		var written time.Time
+		if info, err := os.Stat(p.dataPath); err == nil {
+			written = info.ModTime()
+		}
+		hashes := make([]Hash, 0, len(p.objects))
+		for hash := range p.objects {
+			hashes = append(hashes, hash)
+		}
+		sort.Slice(hashes, func(i, j int) bool {
+			return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+		})
+
+		for _, hash := range hashes {
+			if _, done := objects[hash]; done || pruned[hash] {
+				continue
+			}
+			if expired() {
+				return nil, ErrRepackDeadline
+			}
+			content, err := p.read(hash)
+			if err != nil {
+				return nil, err
+			}
+			if SumB3(content) != hash {
+				return nil, fmt.Errorf("corrupted data: hash mismatch for %s in pack %s", hash.String(), p.name)
+			}
+			if err := add(hash, content, written); err != nil {
+				return nil, err
+			}
+			if _, kept := objects[hash]; kept {
+				stats.PackedObjects++
+			}
+		}
+	}
+
+	stats.Objects = len(objects)
+	stats.Segments = len(segments)
+	stats.Pruned = len(pruned)
+	if stats.Objects == 0 && stats.Pruned == 0 {
+		return stats, nil
+	}
+
+	index := encodePackIndex(segments, objects)
+	if opts.DryRun {
+		// Estimate with the usual 4 KiB block size
+		if stats.Objects > 0 {
+			stats.SizeAfter = roundToBlock(offset) + roundToBlock(int64(len(index)))
+		}
+		return stats, nil
+	}
+
+	if expired() {
+		return nil, ErrRepackDeadline
+	}
+	var name string
+	if stats.Objects > 0 {
+		var err error
+		if name, err = f.publishPack(data, index); err != nil {
+			return nil, err
+		}
+		for _, ext := range []string{".idx", ".dat"} {
+			if info, err := os.Stat(filepath.Join(f.packDir(), name+ext)); err == nil {
+				stats.SizeAfter += diskUsage(info)
+			}
+		}
+	}
+
+	// Everything is now in the new pack or pruned; drop what it replaces
+	for _, obj := range loose {
+		if err := os.Remove(obj.path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove loose object: %w", err)
+		}
+		os.Remove(filepath.Dir(obj.path)) // Only succeeds once the directory is empty
+	}
+	for _, p := range oldPacks {
+		if p.name == name {
+			continue
+		}
+		oldBase := filepath.Join(f.packDir(), p.name)
+		if err := os.Remove(oldBase + ".idx"); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove old pack: %w", err)
+		}
+		os.Remove(oldBase + ".dat")
+	}
+
+	f.packMu.Lock()
+	f.packsLoaded = false
+	f.packMu.Unlock()
+
+	return stats, nil
+}
+
+// publishPack makes a written pack visible: the data file is moved into
+// place first, then the index that makes it readable. It returns the name
+// of the pack.
+func (f *FileCAS) publishPack(data *os.File, index []byte) (string, error) {
+	checksum := SumB3(index)
+	name := "pack-" + hex.EncodeToString(checksum[:16])
+	base := filepath.Join(f.packDir(), name)
+
+	if err := data.Sync(); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+	if err := data.Close(); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+	if err := os.Rename(data.Name(), base+".dat"); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+
+	tmpIndex := base + ".idx.tmp"
+	if err := os.WriteFile(tmpIndex, index, 0644); err != nil {
+		return "", fmt.Errorf("failed to write pack index: %w", err)
+	}
+	if err := os.Rename(tmpIndex, base+".idx"); err != nil {
+		os.Remove(tmpIndex)
+		return "", fmt.Errorf("failed to write pack index: %w", err)
+	}
+	return name, nil
+}
+
+// roundToBlock rounds size up to a whole number of 4 KiB blocks.
+func roundToBlock(size int64) int64 {
+	const block = 4096
+	return (size + block - 1) / block * block
+}
+
+// Content-defined segment sizes used by Repack. Boundaries depend only on
+// the bytes just before them, so inserting data into an object moves the
+// segments around it without changing them.
+const (
+	cdcMinSize = 2 * 1024
+	cdcMaxSize = 64 * 1024
+	cdcMask    = uint64(1<<13-1) << (64 - 13) // ~8 KiB average
+)
+
+// gearTable maps bytes to pseudo-random values for the rolling gear hash.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x9E3779B97F4A7C15)
+	for i := range table {
+		// xorshift64*
+		state ^= state >> 12
+		state ^= state << 25
+		state ^= state >> 27
+		table[i] = state * 0x2545F4914F6CDD1D
+	}
+	return table
+}()
+
+// splitContentDefined splits data into segments at content-defined
+// boundaries using a gear rolling hash.
+func splitContentDefined(data []byte) [][]byte {
+	var segments [][]byte
+	for len(data) > 0 {
+		n := cdcCut(data)
+		segments = append(segments, data[:n])
+		data = data[n:]
+	}
+	return segments
+}
+
+// cdcCut returns the length of the first segment of data.
+func cdcCut(data []byte) int {
+	if len(data) <= cdcMinSize {
+		return len(data)
+	}
+
+	limit := min(len(data), cdcMaxSize)
+	var h uint64
+	for i := cdcMinSize; i < limit; i++ {
+		h = (h << 1) + gearTable[data[i]]
+		if h&cdcMask == 0 {
+			return i + 1
+		}
+	}
+	return limit
+}
@@ -0,0 +1,351 @@
+package cas
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func putAll(t *testing.T, store *FileCAS, blobs [][]byte) []Hash {
+	t.Helper()
+	hashes := make([]Hash, len(blobs))
+	for i, data := range blobs {
+		hashes[i] = SumB3(data)
+		if err := store.Put(hashes[i], data); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	return hashes
+}
+
+func checkAll(t *testing.T, store *FileCAS, hashes []Hash, blobs [][]byte) {
+	t.Helper()
+	for i, hash := range hashes {
+		got, err := store.Get(hash)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if !bytes.Equal(got, blobs[i]) {
+			t.Errorf("Object %d changed after repack", i)
+		}
+		if has, err := store.Has(hash); err != nil || !has {
+			t.Errorf("Has(%d) = %v, %v", i, has, err)
+		}
+	}
+}
+
+func TestRepack(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileCAS(dir)
+	if err != nil {
+		t.Fatalf("NewFileCAS failed: %v", err)
+	}
+
+	blobs := [][]byte{[]byte("first"), []byte("second"), bytes.Repeat([]byte("x"), 100000)}
+	hashes := putAll(t, store, blobs)
+
+	// A file that does not hash to its name, as left by the Git importer
+	foreign := filepath.Join(dir, "ab", "cdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789")
+	os.MkdirAll(filepath.Dir(foreign), 0755)
+	if err := os.WriteFile(foreign, []byte("compressed"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	stats, err := store.Repack(RepackOptions{})
+	if err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if stats.LooseObjects != 3 || stats.Objects != 3 || stats.Skipped != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	for _, hash := range hashes {
+		if _, err := os.Stat(store.getPath(hash)); !os.IsNotExist(err) {
+			t.Errorf("Loose object %s was not removed", hash)
+		}
+	}
+	if _, err := os.Stat(foreign); err != nil {
+		t.Errorf("Foreign file was removed: %v", err)
+	}
+
+	// A fresh store must find the objects in the pack
+	store, _ = NewFileCAS(dir)
+	checkAll(t, store, hashes, blobs)
+
+	// Repacking again carries packed objects into a single new pack
+	more := append(blobs, []byte("third"))
+	hashes = append(hashes, putAll(t, store, more[3:])...)
+	stats, err = store.Repack(RepackOptions{})
+	if err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if stats.LooseObjects != 1 || stats.PackedObjects != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	names, _ := store.listPackNames()
+	if len(names) != 1 {
+		t.Errorf("Expected one pack, got %v", names)
+	}
+	checkAll(t, store, hashes, more)
+
+	// Storing a packed object must not write it loose again
+	if err := store.Put(hashes[0], blobs[0]); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := os.Stat(store.getPath(hashes[0])); !os.IsNotExist(err) {
+		t.Error("Put rewrote a packed object")
+	}
+}
+
+func TestRepackKeep(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileCAS(dir)
+
+	blobs := [][]byte{[]byte("kept"), []byte("packed garbage"), []byte("loose garbage")}
+	hashes := putAll(t, store, blobs[:2])
+	if _, err := store.Repack(RepackOptions{}); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	hashes = append(hashes, putAll(t, store, blobs[2:])...)
+
+	written := make(map[Hash]time.Time)
+	keep := func(hash Hash, content []byte, at time.Time) bool {
+		written[hash] = at
+		return hash == hashes[0]
+	}
+
+	// A dry run counts what would be pruned without removing it
+	stats, err := store.Repack(RepackOptions{Keep: keep, DryRun: true})
+	if err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if stats.Objects != 1 || stats.Pruned != 2 {
+		t.Errorf("Unexpected dry run stats: %+v", stats)
+	}
+	checkAll(t, store, hashes, blobs)
+	for i, hash := range hashes {
+		if written[hash].IsZero() {
+			t.Errorf("Keep was not given when object %d was written", i)
+		}
+	}
+
+	stats, err = store.Repack(RepackOptions{Keep: keep})
+	if err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if stats.Objects != 1 || stats.Pruned != 2 || stats.LooseObjects != 0 || stats.PackedObjects != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	store, _ = NewFileCAS(dir)
+	checkAll(t, store, hashes[:1], blobs[:1])
+	for _, hash := range hashes[1:] {
+		if has, _ := store.Has(hash); has {
+			t.Errorf("Pruned object %s is still stored", hash)
+		}
+	}
+
+	// Pruning everything leaves no pack behind
+	if _, err := store.Repack(RepackOptions{Keep: func(Hash, []byte, time.Time) bool { return false }}); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if names, _ := store.listPackNames(); len(names) != 0 {
+		t.Errorf("Expected no packs, got %v", names)
+	}
+}
+
+func TestPruneLoose(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileCAS(dir)
+
+	blobs := [][]byte{[]byte("packed garbage"), []byte("kept"), []byte("loose garbage")}
+	hashes := putAll(t, store, blobs[:1])
+	if _, err := store.Repack(RepackOptions{}); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	hashes = append(hashes, putAll(t, store, blobs[1:])...)
+
+	var asked []Hash
+	keep := func(hash Hash, content []byte, written time.Time) bool {
+		asked = append(asked, hash)
+		return hash == hashes[1]
+	}
+
+	stats, err := store.PruneLoose(keep, true)
+	if err != nil {
+		t.Fatalf("PruneLoose failed: %v", err)
+	}
+	if stats.Objects != 2 || stats.Pruned != 1 {
+		t.Errorf("Unexpected dry run stats: %+v", stats)
+	}
+	checkAll(t, store, hashes, blobs)
+
+	stats, err = store.PruneLoose(keep, false)
+	if err != nil {
+		t.Fatalf("PruneLoose failed: %v", err)
+	}
+	if stats.Pruned != 1 || stats.Size == 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if has, _ := store.Has(hashes[2]); has {
+		t.Error("Pruned loose object is still stored")
+	}
+	// Packs are left alone, so the packed object survives unasked
+	checkAll(t, store, hashes[:2], blobs[:2])
+	for _, hash := range asked {
+		if hash == hashes[0] {
+			t.Error("Expected packed objects not to be considered")
+		}
+	}
+}
+
+func TestRepackContentDefined(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileCAS(dir)
+
+	// Two large objects differing by a small insertion near the start
+	base := make([]byte, 512*1024)
+	rand.New(rand.NewSource(1)).Read(base)
+	edited := append(append(append([]byte{}, base[:1000]...), []byte("inserted")...), base[1000:]...)
+	blobs := [][]byte{base, edited}
+	hashes := putAll(t, store, blobs)
+
+	plain, err := store.Repack(RepackOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	stats, err := store.Repack(RepackOptions{ContentDefined: true})
+	if err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+
+	if stats.SizeAfter > plain.SizeAfter*6/10 {
+		t.Errorf("Expected shared segments to be stored once: plain %d, content-defined %d",
+			plain.SizeAfter, stats.SizeAfter)
+	}
+	if stats.SizeAfter >= stats.SizeBefore {
+		t.Errorf("Expected repack to shrink storage: %d -> %d", stats.SizeBefore, stats.SizeAfter)
+	}
+
+	store, _ = NewFileCAS(dir)
+	checkAll(t, store, hashes, blobs)
+}
+
+func TestRepackDryRun(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileCAS(dir)
+	hashes := putAll(t, store, [][]byte{[]byte("one"), []byte("two")})
+
+	stats, err := store.Repack(RepackOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if stats.Objects != 2 || stats.SizeAfter == 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	for _, hash := range hashes {
+		if _, err := os.Stat(store.getPath(hash)); err != nil {
+			t.Errorf("Dry run removed a loose object: %v", err)
+		}
+	}
+	if _, err := os.Stat(store.packDir()); !os.IsNotExist(err) {
+		t.Error("Dry run wrote a pack")
+	}
+}
+
+func TestRepackLooseOnly(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileCAS(dir)
+	blobs := [][]byte{[]byte("one"), []byte("two")}
+	hashes := putAll(t, store, blobs[:1])
+	if _, err := store.Repack(RepackOptions{}); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	hashes = append(hashes, putAll(t, store, blobs[1:])...)
+
+	if count, err := store.CountLooseObjects(); err != nil || count != 1 {
+		t.Errorf("CountLooseObjects = %d, %v; expected 1", count, err)
+	}
+
+	stats, err := store.Repack(RepackOptions{LooseOnly: true})
+	if err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if stats.LooseObjects != 1 || stats.PackedObjects != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	names, _ := store.listPackNames()
+	if len(names) != 2 {
+		t.Errorf("Expected the earlier pack to be kept next to the new one, got %v", names)
+	}
+	if count, _ := store.CountLooseObjects(); count != 0 {
+		t.Errorf("Expected no loose objects after repack, got %d", count)
+	}
+
+	store, _ = NewFileCAS(dir)
+	checkAll(t, store, hashes, blobs)
+}
+
+func TestRepackDeadline(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileCAS(dir)
+	hashes := putAll(t, store, [][]byte{[]byte("one"), []byte("two")})
+
+	_, err := store.Repack(RepackOptions{Deadline: time.Now().Add(-time.Second)})
+	if err != ErrRepackDeadline {
+		t.Fatalf("Expected ErrRepackDeadline, got %v", err)
+	}
+
+	for _, hash := range hashes {
+		if _, err := os.Stat(store.getPath(hash)); err != nil {
+			t.Errorf("Expired repack removed a loose object: %v", err)
+		}
+	}
+	if names, _ := store.listPackNames(); len(names) != 0 {
+		t.Errorf("Expired repack published a pack: %v", names)
+	}
+}
+
+func TestSplitContentDefined(t *testing.T) {
+	data := make([]byte, 300*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	segments := splitContentDefined(data)
+	if !bytes.Equal(bytes.Join(segments, nil), data) {
+		t.Fatal("Segments do not reassemble the input")
+	}
+	for i, seg := range segments {
+		if len(seg) > cdcMaxSize || (len(seg) < cdcMinSize && i != len(segments)-1) {
+			t.Errorf("Segment %d has size %d", i, len(seg))
+		}
+	}
+}
+
+func TestFindPrefix(t *testing.T) {
+	store, err := NewFileCAS(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileCAS failed: %v", err)
+	}
+	packed := putAll(t, store, [][]byte{[]byte("packed")})[0]
+	if _, err := store.Repack(RepackOptions{}); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	loose := putAll(t, store, [][]byte{[]byte("loose")})[0]
+
+	for _, hash := range []Hash{packed, loose} {
+		found, err := store.FindPrefix(hash.String()[:9])
+		if err != nil || len(found) != 1 || found[0] != hash {
+			t.Errorf("FindPrefix(%s) = %v, %v", hash.String()[:9], found, err)
+		}
+	}
+	if found, err := store.FindPrefix(strings.ToUpper(loose.String()[:8])); err != nil || len(found) != 1 {
+		t.Errorf("Expected prefixes to be case insensitive, got %v, %v", found, err)
+	}
+	if found, err := store.FindPrefix("zz"); err == nil {
+		t.Errorf("Expected an invalid prefix to be rejected, got %v", found)
+	}
+}
@@ -0,0 +1,164 @@
+package cas
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"lukechampine.com/blake3"
+)
+
+// StreamCAS is a CAS that can store and read objects as streams, without
+// holding a whole object in memory.
+type StreamCAS interface {
+	CAS
+
+	// PutReader stores the content read from r under hash. The content is
+	// hashed as it is read, and nothing is stored if it does not match.
+	PutReader(hash Hash, r io.Reader) error
+
+	// GetReader opens the object stored under hash. Reading it to the end
+	// fails if the content does not match the hash.
+	GetReader(hash Hash) (io.ReadCloser, error)
+}
+
+// PutReader stores the content read from r under hash, streaming it when
+// the store supports it and reading it into memory otherwise.
+func PutReader(c CAS, hash Hash, r io.Reader) error {
+	if s, ok := c.(StreamCAS); ok {
+		return s.PutReader(hash, r)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+	return c.Put(hash, data)
+}
+
+// GetReader opens the object stored under hash, streaming it when the
+// store supports it.
+func GetReader(c CAS, hash Hash) (io.ReadCloser, error) {
+	if s, ok := c.(StreamCAS); ok {
+		return s.GetReader(hash)
+	}
+	data, err := c.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// PutReader implements StreamCAS.PutReader. The content is read once,
+// into a temporary file that is renamed into place once its hash checks
+// out.
+func (f *FileCAS) PutReader(hash Hash, r io.Reader) error {
+	path := f.getPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil // Already exists, nothing to do
+	}
+	if p, err := f.findPacked(hash); err == nil && p != nil {
+		return nil // Already packed
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	// A unique temporary name, as the same object may be streamed in by
+	// several writers at once
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := file.Name()
+
+	hasher := blake3.New(32, nil)
+	_, err = io.Copy(io.MultiWriter(file, hasher), r)
+	closeErr := file.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+	if closeErr != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close file: %w", closeErr)
+	}
+
+	var computed Hash
+	copy(computed[:], hasher.Sum(nil))
+	if computed != hash {
+		os.Remove(tmpPath)
+		return fmt.Errorf("hash mismatch: expected %s, got %s", hash.String(), computed.String())
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return nil
+}
+
+// GetReader implements StreamCAS.GetReader. Loose objects are streamed
+// from disk; packed objects are read from their pack first.
+func (f *FileCAS) GetReader(hash Hash) (io.ReadCloser, error) {
+	file, err := os.Open(f.getPath(hash))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		data, err := f.getFromPack(hash)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return &verifyingReader{file: file, hash: hash, hasher: blake3.New(32, nil)}, nil
+}
+
+// PutReader implements StreamCAS.PutReader. The memory store keeps objects
+// in memory anyway, so the content is read in full.
+func (m *MemoryCAS) PutReader(hash Hash, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+	return m.Put(hash, data)
+}
+
+// GetReader implements StreamCAS.GetReader.
+func (m *MemoryCAS) GetReader(hash Hash) (io.ReadCloser, error) {
+	m.mu.RLock()
+	data, exists := m.data[hash]
+	m.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("hash not found: %s", hash)
+	}
+	// Stored data is never modified, so it can be read without a copy
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// verifyingReader reads a loose object and checks its hash at the end
+type verifyingReader struct {
+	file   *os.File
+	hash   Hash
+	hasher *blake3.Hasher
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.file.Read(p)
+	v.hasher.Write(p[:n])
+	if err == io.EOF {
+		var computed Hash
+		copy(computed[:], v.hasher.Sum(nil))
+		if computed != v.hash {
+			return n, fmt.Errorf("corrupted data: hash mismatch for %s", v.hash.String())
+		}
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.file.Close()
+}
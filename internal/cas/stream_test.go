@@ -0,0 +1,79 @@
+package cas
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCASStreaming(t *testing.T) {
+	store, err := NewFileCAS(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileCAS failed: %v", err)
+	}
+	data := bytes.Repeat([]byte("streamed content "), 10000)
+	hash := SumB3(data)
+
+	// A mismatched hash stores nothing and leaves no temporary file
+	wrongHash := SumB3([]byte("different data"))
+	if err := store.PutReader(wrongHash, bytes.NewReader(data)); err == nil {
+		t.Error("PutReader should fail with mismatched hash")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(store.root, "*", "*")); len(leftovers) != 0 {
+		t.Errorf("Expected no files after a failed PutReader, got %v", leftovers)
+	}
+
+	if err := store.PutReader(hash, bytes.NewReader(data)); err != nil {
+		t.Fatalf("PutReader failed: %v", err)
+	}
+	if got, err := store.Get(hash); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get after PutReader = %d bytes, %v", len(got), err)
+	}
+
+	reader, err := store.GetReader(hash)
+	if err != nil {
+		t.Fatalf("GetReader failed: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("GetReader read %d bytes, %v", len(got), err)
+	}
+
+	// Corruption is reported when the stream reaches its end
+	if err := os.WriteFile(store.getPath(hash), []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reader, err = store.GetReader(hash)
+	if err != nil {
+		t.Fatalf("GetReader failed: %v", err)
+	}
+	defer reader.Close()
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Error("Reading a corrupted object should fail")
+	}
+}
+
+func TestStreamHelpersFallBack(t *testing.T) {
+	// A CAS without streaming support is read and written in full
+	var store CAS = struct{ CAS }{NewMemoryCAS()}
+	data := []byte("test data")
+	hash := SumB3(data)
+
+	if err := PutReader(store, hash, bytes.NewReader(data)); err != nil {
+		t.Fatalf("PutReader failed: %v", err)
+	}
+	reader, err := GetReader(store, hash)
+	if err != nil {
+		t.Fatalf("GetReader failed: %v", err)
+	}
+	defer reader.Close()
+	if got, err := io.ReadAll(reader); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("GetReader read %q, %v", got, err)
+	}
+	if _, err := GetReader(store, SumB3([]byte("missing"))); err == nil {
+		t.Error("GetReader should fail on missing hash")
+	}
+}
@@ -5,6 +5,7 @@
 // - Functions to colorize text based on file status
 // - Automatic color detection and fallback for non-color terminals
 // - Consistent color scheme across all Ivaldi commands
+// - Color slots that configuration can override (see palette.go)
 package colors
 
 import (
@@ -94,7 +95,7 @@ func IsColorEnabled() bool {
 
 // colorize applies color to text if colors are enabled
 func colorize(text, color string) string {
-	if !colorEnabled {
+	if !colorEnabled || color == "" {
 		return text
 	}
 	return color + text + ColorReset
@@ -102,27 +103,27 @@ func colorize(text, color string) string {
 
 // Status-based coloring functions
 func Added(text string) string {
-	return colorize(text, BrightGreen)
+	return Slot(StatusAdded, text)
 }
 
 func Modified(text string) string {
-	return colorize(text, BrightBlue)
+	return Slot(StatusModified, text)
 }
 
 func Deleted(text string) string {
-	return colorize(text, BrightRed)
+	return Slot(StatusDeleted, text)
 }
 
 func Untracked(text string) string {
-	return colorize(text, BrightYellow)
+	return Slot(StatusUntracked, text)
 }
 
 func Ignored(text string) string {
-	return colorize(text, ColorGray)
+	return Slot(StatusIgnored, text)
 }
 
 func Staged(text string) string {
-	return colorize(text, ColorGreen)
+	return Slot(StatusStaged, text)
 }
 
 // Generic color functions
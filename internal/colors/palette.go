@@ -0,0 +1,240 @@
+package colors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Color slots name what a color is used for, so that the color.<slot>
+// config keys can override it. Each slot belongs to a category, the part
+// before the dot, which color.<category> can turn off as a whole.
+const (
+	StatusAdded     = "status.added"     // Files staged for the next seal
+	StatusModified  = "status.modified"  // Modified files that are not staged
+	StatusDeleted   = "status.deleted"   // Deleted files
+	StatusUntracked = "status.untracked" // Untracked files
+	StatusIgnored   = "status.ignored"   // Ignored files
+	StatusStaged    = "status.staged"    // Labels of staged modifications
+	StatusUnmerged  = "status.unmerged"  // Files with unresolved fuse conflicts
+
+	DiffAdded     = "diff.added"     // Added lines and files
+	DiffRemoved   = "diff.removed"   // Removed lines and files
+	DiffModified  = "diff.modified"  // Headers of modified files
+	DiffMeta      = "diff.meta"      // Names of the compared sides and notes
+	DiffMovedFrom = "diff.movedfrom" // Lines moved away, with --color-moved
+	DiffMovedTo   = "diff.movedto"   // Lines moved here, with --color-moved
+)
+
+// Values of color.ui
+const (
+	ModeAuto   = "auto"   // Color when writing to a terminal
+	ModeAlways = "always" // Always color, even when piped
+	ModeNever  = "never"  // Never color
+)
+
+// defaultPalette holds the colors of the slots when not configured
+var defaultPalette = map[string]string{
+	StatusAdded:     BrightGreen,
+	StatusModified:  BrightBlue,
+	StatusDeleted:   BrightRed,
+	StatusUntracked: BrightYellow,
+	StatusIgnored:   ColorGray,
+	StatusStaged:    ColorGreen,
+	StatusUnmerged:  BrightRed,
+
+	DiffAdded:     BrightGreen,
+	DiffRemoved:   BrightRed,
+	DiffModified:  BrightBlue,
+	DiffMeta:      BrightCyan,
+	DiffMovedFrom: BrightMagenta,
+	DiffMovedTo:   BrightCyan,
+}
+
+// palette holds the escape sequence of each slot; an empty one leaves the
+// slot uncolored
+var palette = copyPalette(defaultPalette)
+
+func copyPalette(p map[string]string) map[string]string {
+	c := make(map[string]string, len(p))
+	for slot, code := range p {
+		c[slot] = code
+	}
+	return c
+}
+
+// IsSlot reports whether slot names a color slot. Slot names are lower
+// case.
+func IsSlot(slot string) bool {
+	_, ok := defaultPalette[slot]
+	return ok
+}
+
+// IsCategory reports whether category groups color slots, like "status"
+func IsCategory(category string) bool {
+	for slot := range defaultPalette {
+		if strings.HasPrefix(slot, category+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMode applies color.ui: auto detects the terminal as on startup,
+// always and never force color on or off
+func SetMode(mode string) error {
+	switch mode {
+	case ModeAuto, "":
+		colorEnabled = shouldUseColor()
+	case ModeAlways:
+		colorEnabled = true
+	case ModeNever:
+		colorEnabled = false
+	default:
+		return fmt.Errorf("invalid color mode: %s (expected %s, %s or %s)", mode, ModeAuto, ModeAlways, ModeNever)
+	}
+	return nil
+}
+
+// SetSlot overrides the color of a slot with a color spec (see ParseColor)
+func SetSlot(slot, spec string) error {
+	if !IsSlot(slot) {
+		return fmt.Errorf("unknown color slot: %s", slot)
+	}
+	code, err := ParseColor(spec)
+	if err != nil {
+		return err
+	}
+	palette[slot] = code
+	return nil
+}
+
+// DisableCategory leaves all slots of a category uncolored
+func DisableCategory(category string) {
+	for slot := range palette {
+		if strings.HasPrefix(slot, category+".") {
+			palette[slot] = ""
+		}
+	}
+}
+
+// ResetPalette restores the default colors of all slots
+func ResetPalette() {
+	palette = copyPalette(defaultPalette)
+}
+
+// Slot colors text with the color configured for slot
+func Slot(slot, text string) string {
+	return colorize(text, palette[slot])
+}
+
+var namedColors = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+var attributes = map[string]int{
+	"bold":      1,
+	"dim":       2,
+	"italic":    3,
+	"ul":        4,
+	"underline": 4,
+	"blink":     5,
+	"reverse":   7,
+}
+
+// ParseColor turns a color spec into an ANSI escape sequence. A spec is a
+// list of words, as in git: the first color is the foreground and a second
+// one the background, and attributes such as bold or ul may be mixed in.
+// Colors are named (red, brightred or bright-red, gray), 256-color numbers
+// from 0 to 255, or truecolor #rrggbb values; normal keeps the terminal's
+// color in its place. off, none and plain disable the slot, which yields
+// an empty sequence.
+func ParseColor(spec string) (string, error) {
+	words := strings.Fields(strings.ToLower(spec))
+	if len(words) == 0 {
+		return "", fmt.Errorf("empty color")
+	}
+	if len(words) == 1 {
+		switch words[0] {
+		case "off", "none", "plain":
+			return "", nil
+		}
+	}
+
+	var codes []string
+	colorsSeen := 0
+	for _, word := range words {
+		if attr, ok := attributes[word]; ok {
+			codes = append(codes, strconv.Itoa(attr))
+			continue
+		}
+
+		colorsSeen++
+		if colorsSeen > 2 {
+			return "", fmt.Errorf("invalid color %q: at most a foreground and a background color", spec)
+		}
+		background := colorsSeen == 2
+		if word == "normal" || word == "default" {
+			continue
+		}
+		code, err := colorCode(word, background)
+		if err != nil {
+			return "", fmt.Errorf("invalid color %q: %w", spec, err)
+		}
+		codes = append(codes, code)
+	}
+
+	if len(codes) == 0 {
+		return "", nil
+	}
+	return "\033[" + strings.Join(codes, ";") + "m", nil
+}
+
+// colorCode returns the SGR parameters for one color word
+func colorCode(word string, background bool) (string, error) {
+	base, extended := 30, "38"
+	if background {
+		base, extended = 40, "48"
+	}
+
+	if strings.HasPrefix(word, "#") {
+		if len(word) != 7 {
+			return "", fmt.Errorf("%s is not a #rrggbb color", word)
+		}
+		rgb, err := strconv.ParseUint(word[1:], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("%s is not a #rrggbb color", word)
+		}
+		return fmt.Sprintf("%s;2;%d;%d;%d", extended, rgb>>16, (rgb>>8)&0xff, rgb&0xff), nil
+	}
+
+	if n, err := strconv.Atoi(word); err == nil {
+		if n < 0 || n > 255 {
+			return "", fmt.Errorf("color number %d is not between 0 and 255", n)
+		}
+		return fmt.Sprintf("%s;5;%d", extended, n), nil
+	}
+
+	if word == "gray" || word == "grey" {
+		return strconv.Itoa(base + 60), nil
+	}
+	bright := false
+	if name, ok := strings.CutPrefix(word, "bright"); ok {
+		word, bright = strings.TrimPrefix(name, "-"), true
+	}
+	n, ok := namedColors[word]
+	if !ok {
+		return "", fmt.Errorf("unknown color %s", word)
+	}
+	if bright {
+		n += 60
+	}
+	return strconv.Itoa(base + n), nil
+}
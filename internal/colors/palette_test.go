@@ -0,0 +1,89 @@
+package colors
+
+import "testing"
+
+func TestParseColor(t *testing.T) {
+	cases := map[string]string{
+		"red":             "\033[31m",
+		"bright-red":      "\033[91m",
+		"brightblue":      "\033[94m",
+		"grey":            "\033[90m",
+		"bold green":      "\033[1;32m",
+		"yellow blue":     "\033[33;44m",
+		"normal red":      "\033[41m",
+		"208":             "\033[38;5;208m",
+		"0 255":           "\033[38;5;0;48;5;255m",
+		"#ff8000":         "\033[38;2;255;128;0m",
+		"ul #00FF00 dim":  "\033[4;38;2;0;255;0;2m",
+		"off":             "",
+		"normal":          "",
+		"BOLD Bright-Red": "\033[1;91m",
+	}
+	for spec, want := range cases {
+		got, err := ParseColor(spec)
+		if err != nil {
+			t.Errorf("ParseColor(%q) failed: %v", spec, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseColor(%q): expected %q, got %q", spec, want, got)
+		}
+	}
+
+	for _, spec := range []string{"", "purple", "256", "#fff", "#gg0000", "red green blue", "brightgray"} {
+		if _, err := ParseColor(spec); err == nil {
+			t.Errorf("ParseColor(%q): expected an error", spec)
+		}
+	}
+}
+
+func TestSlotOverrides(t *testing.T) {
+	defer ResetPalette()
+	defer SetColorEnabled(IsColorEnabled())
+	SetColorEnabled(true)
+
+	if got := Added("x"); got != BrightGreen+"x"+ColorReset {
+		t.Errorf("Expected the default color for status.added, got %q", got)
+	}
+
+	if err := SetSlot(StatusAdded, "bold 33"); err != nil {
+		t.Fatalf("SetSlot failed: %v", err)
+	}
+	if got := Added("x"); got != "\033[1;38;5;33m"+"x"+ColorReset {
+		t.Errorf("Expected the configured color for status.added, got %q", got)
+	}
+	if err := SetSlot("status.bogus", "red"); err == nil {
+		t.Error("Expected an error for an unknown slot")
+	}
+
+	// Turning off a category leaves its slots plain but not others
+	DisableCategory("diff")
+	if got := Slot(DiffAdded, "x"); got != "x" {
+		t.Errorf("Expected diff.added to be plain, got %q", got)
+	}
+	if got := Deleted("x"); got == "x" {
+		t.Error("Expected status colors to stay on")
+	}
+
+	ResetPalette()
+	if got := Added("x"); got != BrightGreen+"x"+ColorReset {
+		t.Errorf("Expected ResetPalette to restore the default, got %q", got)
+	}
+}
+
+func TestSetMode(t *testing.T) {
+	defer SetColorEnabled(IsColorEnabled())
+
+	if err := SetMode(ModeAlways); err != nil || !IsColorEnabled() {
+		t.Errorf("Expected always to enable color, got %v, %v", IsColorEnabled(), err)
+	}
+	if err := SetMode(ModeNever); err != nil || IsColorEnabled() {
+		t.Errorf("Expected never to disable color, got %v, %v", IsColorEnabled(), err)
+	}
+	if got := Added("x"); got != "x" {
+		t.Errorf("Expected plain text with color off, got %q", got)
+	}
+	if err := SetMode("sometimes"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
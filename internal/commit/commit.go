@@ -16,6 +16,7 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
@@ -35,6 +36,9 @@ type CommitObject struct {
 	CommitTime  time.Time   // When the commit was created
 	Message     string      // Commit message
 	MMRPosition uint64      // Position in the MMR history
+	// Signature is the armored signature over the rest of the commit, see
+	// SignedPayload; empty for unsigned commits
+	Signature string
 }
 
 // TreeObject represents a tree (directory) in the repository.
@@ -64,6 +68,9 @@ const (
 type CommitBuilder struct {
 	CAS     cas.CAS
 	History *history.MMR
+	// Sign, when set, signs the payload of each new commit and returns
+	// the armored signature
+	Sign func(payload []byte) (string, error)
 }
 
 // NewCommitBuilder creates a new CommitBuilder.
@@ -80,6 +87,18 @@ func (cb *CommitBuilder) CreateCommit(
 	parents []cas.Hash,
 	author, committer, message string,
 ) (*CommitObject, error) {
+	now := time.Now()
+	return cb.CreateCommitAt(workspaceFiles, parents, author, committer, message, now, now)
+}
+
+// CreateCommitAt creates a new commit from workspace files with the given
+// author and commit times, for commits imported from elsewhere.
+func (cb *CommitBuilder) CreateCommitAt(
+	workspaceFiles []wsindex.FileMetadata,
+	parents []cas.Hash,
+	author, committer, message string,
+	authorTime, commitTime time.Time,
+) (*CommitObject, error) {
 	
 	// Step 1: Build tree structure from workspace files
 	treeHash, err := cb.buildTreeFromWorkspace(workspaceFiles)
@@ -88,14 +107,13 @@ func (cb *CommitBuilder) CreateCommit(
 	}
 
 	// Step 2: Create commit object
-	now := time.Now()
 	commit := &CommitObject{
 		TreeHash:   treeHash,
 		Parents:    parents,
 		Author:     author,
 		Committer:  committer,
-		AuthorTime: now,
-		CommitTime: now,
+		AuthorTime: authorTime,
+		CommitTime: commitTime,
 		Message:    message,
 	}
 
@@ -127,7 +145,16 @@ func (cb *CommitBuilder) CreateCommit(
 		commit.MMRPosition = position
 	}
 
-	// Step 4: Store commit object in CAS
+	// Step 4: Sign the commit, now that everything it covers is known
+	if cb.Sign != nil {
+		signature, err := cb.Sign(SignedPayload(commit))
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign commit: %w", err)
+		}
+		commit.Signature = strings.TrimRight(signature, "\n")
+	}
+
+	// Step 5: Store commit object in CAS
 	commitData := cb.encodeCommit(commit)
 	commitHash := cas.SumB3(commitData)
 	
@@ -150,7 +177,11 @@ func (cb *CommitBuilder) buildTreeFromWorkspace(files []wsindex.FileMetadata) (c
 	dirStructure := cb.groupFilesByDirectory(files)
 	
 	// Build tree recursively
-	return cb.buildTreeRecursive("", dirStructure)
+	dirRef, err := cb.buildTreeRecursive("", dirStructure)
+	if err != nil {
+		return cas.Hash{}, err
+	}
+	return dirRef.Hash, nil
 }
 
 // DirectoryNode represents a directory in the tree structure.
@@ -190,7 +221,7 @@ func (cb *CommitBuilder) groupFilesByDirectory(files []wsindex.FileMetadata) *Di
 }
 
 // buildTreeRecursive recursively builds trees for directories.
-func (cb *CommitBuilder) buildTreeRecursive(path string, node *DirectoryNode) (cas.Hash, error) {
+func (cb *CommitBuilder) buildTreeRecursive(path string, node *DirectoryNode) (hamtdir.DirRef, error) {
 	var entries []hamtdir.Entry
 
 	// Add files as blob entries
@@ -210,15 +241,9 @@ func (cb *CommitBuilder) buildTreeRecursive(path string, node *DirectoryNode) (c
 			subPath = path + "/" + dirName
 		}
 
-		subTreeHash, err := cb.buildTreeRecursive(subPath, subNode)
+		subDirRef, err := cb.buildTreeRecursive(subPath, subNode)
 		if err != nil {
-			return cas.Hash{}, fmt.Errorf("failed to build subtree %s: %w", subPath, err)
-		}
-
-		// Create a dummy DirRef for the subtree
-		subDirRef := hamtdir.DirRef{
-			Hash: subTreeHash,
-			Size: len(subNode.Files), // Approximate size
+			return hamtdir.DirRef{}, fmt.Errorf("failed to build subtree %s: %w", subPath, err)
 		}
 
 		entry := hamtdir.Entry{
@@ -233,10 +258,10 @@ func (cb *CommitBuilder) buildTreeRecursive(path string, node *DirectoryNode) (c
 	hamtBuilder := hamtdir.NewBuilder(cb.CAS)
 	dirRef, err := hamtBuilder.Build(entries)
 	if err != nil {
-		return cas.Hash{}, fmt.Errorf("failed to build HAMT for directory %s: %w", path, err)
+		return hamtdir.DirRef{}, fmt.Errorf("failed to build HAMT for directory %s: %w", path, err)
 	}
 
-	return dirRef.Hash, nil
+	return dirRef, nil
 }
 
 // buildEmptyTree creates an empty tree object.
@@ -269,15 +294,15 @@ func (cb *CommitBuilder) encodeCommit(commit *CommitObject) []byte {
 	buf.WriteString("author ")
 	buf.WriteString(commit.Author)
 	buf.WriteByte(' ')
-	buf.WriteString(fmt.Sprintf("%d", commit.AuthorTime.Unix()))
-	buf.WriteString(" +0000\n") // UTC timezone
+	buf.WriteString(formatTimestamp(commit.AuthorTime))
+	buf.WriteByte('\n')
 
 	// Write committer
 	buf.WriteString("committer ")
 	buf.WriteString(commit.Committer)
 	buf.WriteByte(' ')
-	buf.WriteString(fmt.Sprintf("%d", commit.CommitTime.Unix()))
-	buf.WriteString(" +0000\n") // UTC timezone
+	buf.WriteString(formatTimestamp(commit.CommitTime))
+	buf.WriteByte('\n')
 
 	// Write MMR position if available
 	if commit.MMRPosition > 0 {
@@ -286,6 +311,13 @@ func (cb *CommitBuilder) encodeCommit(commit *CommitObject) []byte {
 		buf.WriteByte('\n')
 	}
 
+	// Write signature, continuing its lines with a leading space
+	if commit.Signature != "" {
+		buf.WriteString("gpgsig ")
+		buf.WriteString(strings.ReplaceAll(commit.Signature, "\n", "\n "))
+		buf.WriteByte('\n')
+	}
+
 	// Empty line before message
 	buf.WriteByte('\n')
 
@@ -301,11 +333,13 @@ func (cb *CommitBuilder) encodeCommit(commit *CommitObject) []byte {
 // CommitReader reads commit objects and trees.
 type CommitReader struct {
 	CAS cas.CAS
+	// Limits bound the history walks of the reader
+	Limits WalkLimits
 }
 
-// NewCommitReader creates a new CommitReader.
+// NewCommitReader creates a new CommitReader with DefaultWalkLimits.
 func NewCommitReader(casStore cas.CAS) *CommitReader {
-	return &CommitReader{CAS: casStore}
+	return &CommitReader{CAS: casStore, Limits: DefaultWalkLimits}
 }
 
 // ReadCommit reads a commit object by hash.
@@ -318,14 +352,29 @@ func (cr *CommitReader) ReadCommit(commitHash cas.Hash) (*CommitObject, error) {
 	return cr.parseCommit(data)
 }
 
+// DecodeCommit parses the canonical encoding of a commit object. Data that
+// does not start with a tree line is rejected, so other objects are not
+// mistaken for commits.
+func DecodeCommit(data []byte) (*CommitObject, error) {
+	if !bytes.HasPrefix(data, []byte("tree ")) {
+		return nil, fmt.Errorf("not a commit object")
+	}
+	return (&CommitReader{}).parseCommit(data)
+}
+
 // ReadTree reads the tree object for a commit.
 func (cr *CommitReader) ReadTree(commit *CommitObject) (*TreeObject, error) {
 	// Load the HAMT directory
 	loader := hamtdir.NewLoader(cr.CAS)
 	
+	size, err := loader.Count(hamtdir.DirRef{Hash: commit.TreeHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree: %w", err)
+	}
+
 	dirRef := hamtdir.DirRef{
 		Hash: commit.TreeHash,
-		Size: 0, // Size will be determined when loading
+		Size: size,
 	}
 
 	entries, err := loader.List(dirRef)
@@ -412,13 +461,33 @@ func (cr *CommitReader) GetFileContent(tree *TreeObject, filePath string) ([]byt
 	return nil, fmt.Errorf("unexpected error in GetFileContent")
 }
 
-// ListFiles lists all files in the tree recursively.
+// ListFiles lists all files in the tree recursively, sorted by path.
 func (cr *CommitReader) ListFiles(tree *TreeObject) ([]string, error) {
 	var files []string
 	err := cr.listFilesRecursive(tree, "", &files)
+	// Depth-first order puts "a/b" before "a.txt"; sort by full path
+	sort.Strings(files)
 	return files, err
 }
 
+// FileRefs returns the content reference of every file in a commit's tree,
+// keyed by path. Files are not read, so comparing two commits this way only
+// touches tree nodes.
+func (cr *CommitReader) FileRefs(commit *CommitObject) (map[string]filechunk.NodeRef, error) {
+	files := make(map[string]filechunk.NodeRef)
+	loader := hamtdir.NewLoader(cr.CAS)
+	err := loader.WalkEntries(hamtdir.DirRef{Hash: commit.TreeHash}, func(path string, entry hamtdir.Entry) error {
+		if entry.Type == hamtdir.FileEntry && entry.File != nil {
+			files[path] = *entry.File
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree: %w", err)
+	}
+	return files, nil
+}
+
 // listFilesRecursive recursively lists files in a tree.
 func (cr *CommitReader) listFilesRecursive(tree *TreeObject, prefix string, files *[]string) error {
 	for _, entry := range tree.Entries {
@@ -486,6 +555,7 @@ func (cr *CommitReader) parseCommit(data []byte) (*CommitObject, error) {
 	commit := &CommitObject{}
 	
 	var messageStart int
+	var signature []string
 	for i, line := range lines {
 		if len(line) == 0 {
 			// Empty line indicates start of message
@@ -493,6 +563,14 @@ func (cr *CommitReader) parseCommit(data []byte) (*CommitObject, error) {
 			break
 		}
 
+		// A leading space continues the signature
+		if line[0] == ' ' {
+			if signature != nil {
+				signature = append(signature, string(line[1:]))
+			}
+			continue
+		}
+
 		parts := bytes.SplitN(line, []byte{' '}, 2)
 		if len(parts) < 2 {
 			continue
@@ -520,7 +598,7 @@ func (cr *CommitReader) parseCommit(data []byte) (*CommitObject, error) {
 			parts := bytes.Fields(parts[1])
 			if len(parts) >= 2 {
 				commit.Author = string(bytes.Join(parts[:len(parts)-2], []byte{' '}))
-				if timestamp, err := parseTimestamp(string(parts[len(parts)-2])); err == nil {
+				if timestamp, err := parseTimestamp(string(parts[len(parts)-2]), string(parts[len(parts)-1])); err == nil {
 					commit.AuthorTime = timestamp
 				}
 			}
@@ -529,7 +607,7 @@ func (cr *CommitReader) parseCommit(data []byte) (*CommitObject, error) {
 			parts := bytes.Fields(parts[1])
 			if len(parts) >= 2 {
 				commit.Committer = string(bytes.Join(parts[:len(parts)-2], []byte{' '}))
-				if timestamp, err := parseTimestamp(string(parts[len(parts)-2])); err == nil {
+				if timestamp, err := parseTimestamp(string(parts[len(parts)-2]), string(parts[len(parts)-1])); err == nil {
 					commit.CommitTime = timestamp
 				}
 			}
@@ -538,8 +616,12 @@ func (cr *CommitReader) parseCommit(data []byte) (*CommitObject, error) {
 			if pos, err := parseUint64(value); err == nil {
 				commit.MMRPosition = pos
 			}
+
+		case "gpgsig":
+			signature = []string{value}
 		}
 	}
+	commit.Signature = strings.Join(signature, "\n")
 
 	// Extract message
 	if messageStart < len(lines) {
@@ -610,13 +692,27 @@ func parseHexByte(s string) (byte, error) {
 	return result, nil
 }
 
-func parseTimestamp(s string) (time.Time, error) {
+// formatTimestamp encodes a time as Unix seconds followed by its UTC
+// offset, such as "1700000000 +0200"
+func formatTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d %s", t.Unix(), t.Format("-0700"))
+}
+
+// parseTimestamp decodes Unix seconds and a UTC offset written by
+// formatTimestamp. The time keeps its offset, so that encoding it again
+// gives the same bytes.
+func parseTimestamp(s, zone string) (time.Time, error) {
 	var timestamp int64
 	n, err := fmt.Sscanf(s, "%d", &timestamp)
 	if err != nil || n != 1 {
 		return time.Time{}, fmt.Errorf("invalid timestamp: %s", s)
 	}
-	return time.Unix(timestamp, 0), nil
+	offset, err := time.Parse("-0700", zone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone: %s", zone)
+	}
+	_, seconds := offset.Zone()
+	return time.Unix(timestamp, 0).In(time.FixedZone(zone, seconds)), nil
 }
 
 func parseUint64(s string) (uint64, error) {
@@ -628,6 +724,14 @@ func parseUint64(s string) (uint64, error) {
 	return value, nil
 }
 
+// SignedPayload returns the data a commit signature covers: the encoded
+// commit without its signature.
+func SignedPayload(commit *CommitObject) []byte {
+	unsigned := *commit
+	unsigned.Signature = ""
+	return (&CommitBuilder{}).encodeCommit(&unsigned)
+}
+
 // GetCommitHash computes the hash of a commit object.
 func (cb *CommitBuilder) GetCommitHash(commit *CommitObject) cas.Hash {
 	data := cb.encodeCommit(commit)
@@ -1,6 +1,8 @@
 package commit
 
 import (
+	"fmt"
+	"sort"
 	"testing"
 	"time"
 
@@ -327,6 +329,170 @@ func TestListFiles(t *testing.T) {
 	}
 }
 
+func TestListFilesSorted(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+	reader := NewCommitReader(casStore)
+	fileBuilder := filechunk.NewBuilder(casStore, filechunk.DefaultParams())
+
+	// Enough files in one directory to split it over internal HAMT nodes,
+	// and a file that sorts between a directory and its contents
+	paths := []string{"a.txt", "a/b.txt", "z.txt"}
+	for i := 0; i < 60; i++ {
+		paths = append(paths, fmt.Sprintf("dir/file%02d.txt", i))
+	}
+
+	var files []wsindex.FileMetadata
+	for _, path := range paths {
+		fileRef, err := fileBuilder.Build([]byte(path))
+		if err != nil {
+			t.Fatalf("Failed to store %s: %v", path, err)
+		}
+		files = append(files, wsindex.FileMetadata{Path: path, FileRef: fileRef, Mode: 0644})
+	}
+
+	commit, err := builder.CreateCommit(files, nil, "Test Author <test@example.com>", "Test Author <test@example.com>", "Test commit")
+	if err != nil {
+		t.Fatalf("CreateCommit failed: %v", err)
+	}
+	tree, err := reader.ReadTree(commit)
+	if err != nil {
+		t.Fatalf("ReadTree failed: %v", err)
+	}
+
+	fileList, err := reader.ListFiles(tree)
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+	if len(fileList) != len(paths) {
+		t.Fatalf("Expected %d files, got %d", len(paths), len(fileList))
+	}
+	if !sort.StringsAreSorted(fileList) {
+		t.Errorf("Expected files sorted by path, got %v", fileList)
+	}
+}
+
+func TestFileRefs(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+	reader := NewCommitReader(casStore)
+
+	files := createTestWorkspaceFiles(casStore)
+	commit, err := builder.CreateCommit(files, nil, "Test Author <test@example.com>", "Test Committer <test@example.com>", "Test commit")
+	if err != nil {
+		t.Fatalf("CreateCommit failed: %v", err)
+	}
+
+	refs, err := reader.FileRefs(commit)
+	if err != nil {
+		t.Fatalf("FileRefs failed: %v", err)
+	}
+
+	if len(refs) != len(files) {
+		t.Errorf("Expected %d files, got %d", len(files), len(refs))
+	}
+	for _, file := range files {
+		ref, ok := refs[file.Path]
+		if !ok {
+			t.Errorf("Expected file %s not found", file.Path)
+			continue
+		}
+		if ref.Hash != file.FileRef.Hash {
+			t.Errorf("File %s has ref %s, want %s", file.Path, ref.Hash, file.FileRef.Hash)
+		}
+	}
+}
+
+func TestCreateCommitAt(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+	reader := NewCommitReader(casStore)
+
+	authorTime := time.Unix(1700000000, 0)
+	commitTime := time.Unix(1700003600, 0)
+	commit, err := builder.CreateCommitAt(createTestWorkspaceFiles(casStore), nil,
+		"Test Author <test@example.com>", "Test Committer <test@example.com>", "Imported commit",
+		authorTime, commitTime)
+	if err != nil {
+		t.Fatalf("CreateCommitAt failed: %v", err)
+	}
+
+	read, err := reader.ReadCommit(builder.GetCommitHash(commit))
+	if err != nil {
+		t.Fatalf("ReadCommit failed: %v", err)
+	}
+	if !read.AuthorTime.Equal(authorTime) || !read.CommitTime.Equal(commitTime) {
+		t.Errorf("Expected times %v/%v, got %v/%v", authorTime, commitTime, read.AuthorTime, read.CommitTime)
+	}
+}
+
+func TestCommitTimeZones(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+	reader := NewCommitReader(casStore)
+
+	authorTime := time.Date(2023, 11, 14, 9, 30, 0, 0, time.FixedZone("", -7*3600))
+	commitTime := time.Date(2023, 11, 15, 18, 0, 0, 0, time.FixedZone("", 5*3600+1800))
+	commit, err := builder.CreateCommitAt(createTestWorkspaceFiles(casStore), nil,
+		"Test Author <test@example.com>", "Test Committer <test@example.com>", "Dated commit",
+		authorTime, commitTime)
+	if err != nil {
+		t.Fatalf("CreateCommitAt failed: %v", err)
+	}
+	hash := builder.GetCommitHash(commit)
+
+	read, err := reader.ReadCommit(hash)
+	if err != nil {
+		t.Fatalf("ReadCommit failed: %v", err)
+	}
+	if !read.AuthorTime.Equal(authorTime) || !read.CommitTime.Equal(commitTime) {
+		t.Errorf("Expected times %v/%v, got %v/%v", authorTime, commitTime, read.AuthorTime, read.CommitTime)
+	}
+	if _, offset := read.AuthorTime.Zone(); offset != -7*3600 {
+		t.Errorf("Expected author offset -0700, got %d seconds", offset)
+	}
+	if _, offset := read.CommitTime.Zone(); offset != 5*3600+1800 {
+		t.Errorf("Expected commit offset +0530, got %d seconds", offset)
+	}
+	if builder.GetCommitHash(read) != hash {
+		t.Error("Expected the commit read back to encode to the same hash")
+	}
+}
+
+func TestSignedCommit(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+	reader := NewCommitReader(casStore)
+
+	var signed []byte
+	builder.Sign = func(payload []byte) (string, error) {
+		signed = payload
+		return "-----BEGIN TEST SIGNATURE-----\nabc\n-----END TEST SIGNATURE-----\n", nil
+	}
+	commit, err := builder.CreateCommit(createTestWorkspaceFiles(casStore), nil,
+		"Test Author <test@example.com>", "Test Author <test@example.com>", "Signed commit\n\nWith a body")
+	if err != nil {
+		t.Fatalf("CreateCommit failed: %v", err)
+	}
+
+	read, err := reader.ReadCommit(builder.GetCommitHash(commit))
+	if err != nil {
+		t.Fatalf("ReadCommit failed: %v", err)
+	}
+	want := "-----BEGIN TEST SIGNATURE-----\nabc\n-----END TEST SIGNATURE-----"
+	if read.Signature != want {
+		t.Errorf("Expected signature %q, got %q", want, read.Signature)
+	}
+	if read.Message != "Signed commit\n\nWith a body" {
+		t.Errorf("Expected message to survive the signature, got %q", read.Message)
+	}
+
+	// The signature covers the commit as it reads without it
+	if payload := SignedPayload(read); string(payload) != string(signed) {
+		t.Errorf("Expected signed payload %q, got %q", signed, payload)
+	}
+}
+
 func TestEmptyCommit(t *testing.T) {
 	casStore := cas.NewMemoryCAS()
 	mmr := history.NewMMR()
@@ -498,4 +664,35 @@ func BenchmarkReadCommit(b *testing.B) {
 			b.Fatalf("ReadCommit failed: %v", err)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestDecodeCommit(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+
+	original, err := builder.CreateCommit(createTestWorkspaceFiles(casStore), nil,
+		"Test Author <test@example.com>", "Test Committer <test@example.com>", "Test commit message")
+	if err != nil {
+		t.Fatalf("CreateCommit failed: %v", err)
+	}
+	data, err := casStore.Get(builder.GetCommitHash(original))
+	if err != nil {
+		t.Fatalf("Get commit failed: %v", err)
+	}
+
+	decoded, err := DecodeCommit(data)
+	if err != nil {
+		t.Fatalf("DecodeCommit failed: %v", err)
+	}
+	if decoded.TreeHash != original.TreeHash || decoded.Message != original.Message {
+		t.Errorf("Decoded commit does not match: %+v", decoded)
+	}
+
+	// The tree a commit points at is not a commit
+	treeData, err := casStore.Get(original.TreeHash)
+	if err != nil {
+		t.Fatalf("Get tree failed: %v", err)
+	}
+	if _, err := DecodeCommit(treeData); err == nil {
+		t.Error("Expected a tree object to be rejected")
+	}
+}
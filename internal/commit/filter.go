@@ -0,0 +1,145 @@
+package commit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+// Filter selects commits by commit time, author and number of parents.
+// Zero values disable the corresponding check; all set checks must match.
+type Filter struct {
+	Since    time.Time // Only commits at or after this time
+	Until    time.Time // Only commits at or before this time
+	Author   string    // Case-insensitive substring of the author
+	Merges   bool      // Only merge commits, which have two or more parents
+	NoMerges bool      // Only commits with at most one parent
+}
+
+// Match reports whether a commit passes the filter.
+func (f Filter) Match(commit *CommitObject) bool {
+	if !f.Since.IsZero() && commit.CommitTime.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && commit.CommitTime.After(f.Until) {
+		return false
+	}
+	if f.Author != "" && !strings.Contains(strings.ToLower(commit.Author), strings.ToLower(f.Author)) {
+		return false
+	}
+	if f.Merges && len(commit.Parents) < 2 || f.NoMerges && len(commit.Parents) > 1 {
+		return false
+	}
+	return true
+}
+
+// Exhausted reports whether a commit is older than the filter's range.
+func (f Filter) Exhausted(commit *CommitObject) bool {
+	return !f.Since.IsZero() && commit.CommitTime.Before(f.Since)
+}
+
+// exhaustedSlop is how many commits in a row must be older than the
+// filter's range before a walk stops, as in Git. Commit times usually
+// decrease along a first-parent chain, but a commit made with a skewed
+// clock must not hide the commits in range behind it.
+const exhaustedSlop = 5
+
+// WalkFirstParent walks the first-parent chain starting at head, calling fn
+// for every commit that matches filter. Commits the filter rejects are
+// still walked through. The walk ends at the root commit, after
+// exhaustedSlop commits in a row older than the filter's range, when fn
+// returns false, or when a commit repeats. It
+// fails with ErrWalkLimit if the chain is longer than the reader's limits.
+func (cr *CommitReader) WalkFirstParent(head cas.Hash, filter Filter, fn func(hash cas.Hash, commit *CommitObject) bool) error {
+	guard := cr.NewWalkGuard()
+	current := head
+	exhausted := 0
+
+	for depth := 0; current != (cas.Hash{}); depth++ {
+		first, err := guard.Visit(current, depth)
+		if err != nil {
+			return err
+		}
+		if !first {
+			return nil
+		}
+
+		commit, err := cr.ReadCommit(current)
+		if err != nil {
+			return err
+		}
+
+		if filter.Exhausted(commit) {
+			if exhausted++; exhausted == exhaustedSlop {
+				return nil
+			}
+		} else {
+			exhausted = 0
+		}
+
+		if filter.Match(commit) && !fn(current, commit) {
+			return nil
+		}
+
+		if len(commit.Parents) == 0 {
+			return nil
+		}
+		current = commit.Parents[0]
+	}
+
+	return nil
+}
+
+// relativeUnits maps the units accepted in "N <unit>s ago" to a function
+// that moves a time back by n units.
+var relativeUnits = map[string]func(t time.Time, n int) time.Time{
+	"second": func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Second) },
+	"minute": func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Minute) },
+	"hour":   func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Hour) },
+	"day":    func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -n) },
+	"week":   func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -7*n) },
+	"month":  func(t time.Time, n int) time.Time { return t.AddDate(0, -n, 0) },
+	"year":   func(t time.Time, n int) time.Time { return t.AddDate(-n, 0, 0) },
+}
+
+// ParseDate parses a date for history filtering. It accepts RFC3339
+// timestamps, "2006-01-02" and "2006-01-02 15:04" in local time, "now",
+// "today", "yesterday", and relative forms such as "2 weeks ago" or
+// "3.days.ago", which are resolved against now.
+func ParseDate(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(value) {
+	case "now":
+		return now, nil
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+
+	fields := strings.Fields(strings.ReplaceAll(strings.ToLower(value), ".", " "))
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		if err == nil && n >= 0 {
+			if back, ok := relativeUnits[strings.TrimSuffix(fields[1], "s")]; ok {
+				return back(now, n), nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q (use RFC3339, YYYY-MM-DD, or a relative form like \"2 weeks ago\")", value)
+}
@@ -0,0 +1,274 @@
+package commit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+)
+
+// buildSyntheticHistory stores a linear chain of commits one day apart,
+// oldest first, and returns their hashes in the same order. The root
+// commit's parent is rootParent, which need not exist in the store.
+func buildSyntheticHistory(t *testing.T, casStore cas.CAS, start time.Time, authors []string, rootParent *cas.Hash) []cas.Hash {
+	t.Helper()
+
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+	treeHash, err := builder.buildEmptyTree()
+	if err != nil {
+		t.Fatalf("buildEmptyTree failed: %v", err)
+	}
+
+	var hashes []cas.Hash
+	for i, author := range authors {
+		commit := &CommitObject{
+			TreeHash:   treeHash,
+			Author:     author,
+			Committer:  author,
+			AuthorTime: start.AddDate(0, 0, i),
+			CommitTime: start.AddDate(0, 0, i),
+			Message:    "commit",
+		}
+		if i > 0 {
+			commit.Parents = []cas.Hash{hashes[i-1]}
+		} else if rootParent != nil {
+			commit.Parents = []cas.Hash{*rootParent}
+		}
+
+		data := builder.encodeCommit(commit)
+		hash := cas.SumB3(data)
+		if err := casStore.Put(hash, data); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes
+}
+
+func collect(t *testing.T, reader *CommitReader, head cas.Hash, filter Filter) []time.Time {
+	t.Helper()
+
+	var times []time.Time
+	err := reader.WalkFirstParent(head, filter, func(hash cas.Hash, commit *CommitObject) bool {
+		times = append(times, commit.CommitTime)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("WalkFirstParent failed: %v", err)
+	}
+	return times
+}
+
+func TestWalkFirstParentFilters(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	authors := []string{
+		"Alice <alice@example.com>",
+		"Bob <bob@example.com>",
+		"Alice <alice@example.com>",
+		"Bob <bob@example.com>",
+		"Alice <alice@example.com>",
+		"Bob <bob@example.com>",
+	}
+	hashes := buildSyntheticHistory(t, casStore, start, authors, nil)
+	head := hashes[len(hashes)-1]
+	reader := NewCommitReader(casStore)
+
+	day := func(i int) time.Time { return start.AddDate(0, 0, i) }
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []time.Time
+	}{
+		{"no filter", Filter{}, []time.Time{day(5), day(4), day(3), day(2), day(1), day(0)}},
+		{"since inclusive", Filter{Since: day(3)}, []time.Time{day(5), day(4), day(3)}},
+		{"until inclusive", Filter{Until: day(1)}, []time.Time{day(1), day(0)}},
+		{"range", Filter{Since: day(1), Until: day(3)}, []time.Time{day(3), day(2), day(1)}},
+		{"author case-insensitive", Filter{Author: "BOB"}, []time.Time{day(5), day(3), day(1)}},
+		{"author by email", Filter{Author: "alice@"}, []time.Time{day(4), day(2), day(0)}},
+		{"combined", Filter{Since: day(1), Until: day(4), Author: "alice"}, []time.Time{day(4), day(2)}},
+		{"empty range", Filter{Since: day(10)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(t, reader, head, tt.filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d commits, got %d", len(tt.want), len(got))
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("Commit %d: expected %v, got %v", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWalkFirstParentMerges(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	hashes := buildSyntheticHistory(t, casStore, start, []string{"alice", "bob", "alice"}, nil)
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+	base, err := NewCommitReader(casStore).ReadCommit(hashes[2])
+	if err != nil {
+		t.Fatalf("ReadCommit failed: %v", err)
+	}
+
+	// Merge a side commit into the chain and build one more on top
+	store := func(commit CommitObject) cas.Hash {
+		data := builder.encodeCommit(&commit)
+		hash := cas.SumB3(data)
+		if err := casStore.Put(hash, data); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		return hash
+	}
+	side := *base
+	side.Parents, side.Author, side.CommitTime = []cas.Hash{hashes[0]}, "carol", start.AddDate(0, 0, 3)
+	merge := *base
+	merge.Parents, merge.Author, merge.CommitTime = []cas.Hash{hashes[2], store(side)}, "bob", start.AddDate(0, 0, 4)
+	top := *base
+	top.Parents, top.Author, top.CommitTime = []cas.Hash{store(merge)}, "alice", start.AddDate(0, 0, 5)
+	head := store(top)
+
+	day := func(i int) time.Time { return start.AddDate(0, 0, i) }
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []time.Time
+	}{
+		{"merges", Filter{Merges: true}, []time.Time{day(4)}},
+		{"no merges", Filter{NoMerges: true}, []time.Time{day(5), day(2), day(1), day(0)}},
+		{"merges by author", Filter{Merges: true, Author: "alice"}, nil},
+		{"no merges by author", Filter{NoMerges: true, Author: "bob"}, []time.Time{day(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(t, NewCommitReader(casStore), head, tt.filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d commits, got %d", len(tt.want), len(got))
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("Commit %d: expected %v, got %v", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWalkFirstParentStopsEarly(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// The root points at a commit that is not in the store, so walking
+	// past the requested range would fail. The walk stops after
+	// exhaustedSlop commits older than the range, before the root.
+	missing := cas.SumB3([]byte("missing parent"))
+	authors := make([]string, exhaustedSlop+3)
+	for i := range authors {
+		authors[i] = "a"
+	}
+	hashes := buildSyntheticHistory(t, casStore, start, authors, &missing)
+	head := hashes[len(hashes)-1]
+	reader := NewCommitReader(casStore)
+
+	got := collect(t, reader, head, Filter{Since: start.AddDate(0, 0, exhaustedSlop+1)})
+	if len(got) != 2 {
+		t.Errorf("Expected 2 commits, got %d", len(got))
+	}
+
+	if err := reader.WalkFirstParent(head, Filter{}, func(cas.Hash, *CommitObject) bool { return true }); err == nil {
+		t.Error("Expected unfiltered walk to reach the missing parent")
+	}
+
+	// Returning false from the callback stops the walk as well
+	count := 0
+	err := reader.WalkFirstParent(head, Filter{}, func(cas.Hash, *CommitObject) bool {
+		count++
+		return count < 2
+	})
+	if err != nil || count != 2 {
+		t.Errorf("Expected walk to stop after 2 commits, got %d (err %v)", count, err)
+	}
+}
+
+func TestWalkFirstParentClockSkew(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	hashes := buildSyntheticHistory(t, casStore, start, []string{"a", "b", "c"}, nil)
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+	base, err := NewCommitReader(casStore).ReadCommit(hashes[2])
+	if err != nil {
+		t.Fatalf("ReadCommit failed: %v", err)
+	}
+
+	// A commit made with a clock a month behind sits between commits in range
+	store := func(commit CommitObject) cas.Hash {
+		data := builder.encodeCommit(&commit)
+		hash := cas.SumB3(data)
+		if err := casStore.Put(hash, data); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		return hash
+	}
+	skewed := *base
+	skewed.Parents, skewed.CommitTime = []cas.Hash{hashes[2]}, start.AddDate(0, -1, 0)
+	top := *base
+	top.Parents, top.CommitTime = []cas.Hash{store(skewed)}, start.AddDate(0, 0, 5)
+	head := store(top)
+
+	day := func(i int) time.Time { return start.AddDate(0, 0, i) }
+	got := collect(t, NewCommitReader(casStore), head, Filter{Since: day(1)})
+	want := []time.Time{day(5), day(2), day(1)}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d commits, got %d", len(want), len(got))
+	}
+	for i := range got {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("Commit %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2024, 6, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"2024-01-02T03:04:05Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-02 15:04", time.Date(2024, 1, 2, 15, 4, 0, 0, time.UTC)},
+		{"now", now},
+		{"today", time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{"yesterday", time.Date(2024, 6, 14, 0, 0, 0, 0, time.UTC)},
+		{"2 weeks ago", now.AddDate(0, 0, -14)},
+		{"1 day ago", now.AddDate(0, 0, -1)},
+		{"3.hours.ago", now.Add(-3 * time.Hour)},
+		{"6 months ago", now.AddDate(0, -6, 0)},
+		{"1 Year Ago", now.AddDate(-1, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDate(tt.input, now)
+		if err != nil {
+			t.Errorf("ParseDate(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "last tuesday", "two weeks ago", "5 fortnights ago", "2024-13-01"} {
+		if _, err := ParseDate(input, now); err == nil {
+			t.Errorf("ParseDate(%q) should fail", input)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package commit
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+)
+
+// ErrNoMergeBase is returned when two commits share no history.
+var ErrNoMergeBase = errors.New("no common ancestor")
+
+// AheadBehind counts the commits reachable from local but not from remote
+// (ahead) and the commits reachable from remote but not from local (behind),
+// following all parents.
+func (cr *CommitReader) AheadBehind(local, remote cas.Hash) (ahead, behind int, err error) {
+	if local == remote {
+		return 0, 0, nil
+	}
+
+	localAncestors, err := cr.ancestors(local)
+	if err != nil {
+		return 0, 0, err
+	}
+	remoteAncestors, err := cr.ancestors(remote)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for hash := range localAncestors {
+		if !remoteAncestors[hash] {
+			ahead++
+		}
+	}
+	for hash := range remoteAncestors {
+		if !localAncestors[hash] {
+			behind++
+		}
+	}
+
+	return ahead, behind, nil
+}
+
+// Ancestors returns the set of commits reachable from head, including head.
+// The zero hash has no ancestors.
+func (cr *CommitReader) Ancestors(head cas.Hash) (map[cas.Hash]bool, error) {
+	return cr.ancestors(head)
+}
+
+// ancestors returns the set of commits reachable from head, including head.
+func (cr *CommitReader) ancestors(head cas.Hash) (map[cas.Hash]bool, error) {
+	if head == (cas.Hash{}) {
+		return make(map[cas.Hash]bool), nil
+	}
+	return cr.walkAncestors(head, nil)
+}
+
+// IsAncestor reports whether ancestor is reachable from tip, following all
+// parents. A commit is its own ancestor.
+func (cr *CommitReader) IsAncestor(ancestor, tip cas.Hash) (bool, error) {
+	found := false
+	_, err := cr.walkAncestors(tip, func(hash cas.Hash) bool {
+		found = found || hash == ancestor
+		return !found
+	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+// MergeBase returns the best common ancestor of a and b: a commit reachable
+// from both that is not an ancestor of another such commit. When both are
+// recorded in acc, the MMR skip table finds the lowest common ancestor of
+// their first-parent chains. That answer is used when neither chain passes
+// through a merge on the way to it; otherwise the full commit graph is
+// searched, since MMR leaves only record first parents. acc may be nil.
+func (cr *CommitReader) MergeBase(acc history.Accumulator, a, b cas.Hash) (cas.Hash, error) {
+	if a == b {
+		return a, nil
+	}
+
+	if acc != nil {
+		if base, ok := cr.linearMergeBase(acc, a, b); ok {
+			return base, nil
+		}
+	}
+	return cr.graphMergeBase(a, b)
+}
+
+// linearMergeBase computes the merge base from the MMR skip table and checks
+// it against the commits. It reports false when the commits are not in the
+// MMR, disagree with it, or reach the base through a merge.
+func (cr *CommitReader) linearMergeBase(acc history.Accumulator, a, b cas.Hash) (cas.Hash, bool) {
+	commitA, err := cr.ReadCommit(a)
+	if err != nil {
+		return cas.Hash{}, false
+	}
+	commitB, err := cr.ReadCommit(b)
+	if err != nil {
+		return cas.Hash{}, false
+	}
+	// Position 0 is not written to commit objects, so it means unrecorded
+	if commitA.MMRPosition == 0 || commitB.MMRPosition == 0 {
+		return cas.Hash{}, false
+	}
+
+	table := history.NewSkipTable()
+	table.AddChain(commitA.MMRPosition, acc)
+	table.AddChain(commitB.MMRPosition, acc)
+	baseIdx, err := table.LCA(commitA.MMRPosition, commitB.MMRPosition, acc)
+	if err != nil || baseIdx == history.NoParent || baseIdx == 0 {
+		return cas.Hash{}, false
+	}
+
+	// Both chains must reach the same commit at that position without
+	// passing a merge, and the chains must not meet any earlier
+	pathA, baseA, ok := cr.firstParentPath(a, baseIdx)
+	if !ok {
+		return cas.Hash{}, false
+	}
+	pathB, baseB, ok := cr.firstParentPath(b, baseIdx)
+	if !ok || baseA != baseB {
+		return cas.Hash{}, false
+	}
+	for hash := range pathB {
+		if pathA[hash] {
+			return cas.Hash{}, false
+		}
+	}
+	return baseA, true
+}
+
+// firstParentPath follows first parents from head to the commit at MMR
+// position idx. It returns the commits passed before that one and the
+// commit itself, and reports false if a merge is passed or idx is not found.
+func (cr *CommitReader) firstParentPath(head cas.Hash, idx uint64) (map[cas.Hash]bool, cas.Hash, bool) {
+	path := make(map[cas.Hash]bool)
+	guard := cr.NewWalkGuard()
+	for current := head; ; {
+		if first, err := guard.Visit(current, len(path)); err != nil || !first {
+			return nil, cas.Hash{}, false
+		}
+		commit, err := cr.ReadCommit(current)
+		if err != nil {
+			return nil, cas.Hash{}, false
+		}
+		if commit.MMRPosition == idx {
+			return path, current, true
+		}
+		if len(commit.Parents) != 1 {
+			return nil, cas.Hash{}, false
+		}
+		path[current] = true
+		current = commit.Parents[0]
+	}
+}
+
+// graphMergeBase finds the best common ancestor by walking all parents.
+// Of several equally good ones, as after criss-cross merges, the most
+// recently committed wins.
+func (cr *CommitReader) graphMergeBase(a, b cas.Hash) (cas.Hash, error) {
+	ancestorsA, err := cr.ancestors(a)
+	if err != nil {
+		return cas.Hash{}, err
+	}
+
+	// Walk back from b, stopping at the first commits that a can reach
+	var candidates []cas.Hash
+	_, err = cr.walkAncestors(b, func(hash cas.Hash) bool {
+		if ancestorsA[hash] {
+			candidates = append(candidates, hash)
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return cas.Hash{}, err
+	}
+
+	// A candidate reachable from another candidate is not the best
+	var best []cas.Hash
+	for _, candidate := range candidates {
+		dominated := false
+		for _, other := range candidates {
+			if other == candidate {
+				continue
+			}
+			otherAncestors, err := cr.ancestors(other)
+			if err != nil {
+				return cas.Hash{}, err
+			}
+			if otherAncestors[candidate] {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			best = append(best, candidate)
+		}
+	}
+	if len(best) == 0 {
+		return cas.Hash{}, ErrNoMergeBase
+	}
+
+	result := best[0]
+	resultCommit, err := cr.ReadCommit(result)
+	if err != nil {
+		return cas.Hash{}, err
+	}
+	for _, candidate := range best[1:] {
+		commit, err := cr.ReadCommit(candidate)
+		if err != nil {
+			return cas.Hash{}, err
+		}
+		if commit.CommitTime.After(resultCommit.CommitTime) ||
+			(commit.CommitTime.Equal(resultCommit.CommitTime) && bytes.Compare(candidate[:], result[:]) < 0) {
+			result, resultCommit = candidate, commit
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,178 @@
+package commit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+)
+
+// storeGraphCommit stores a commit with the given parents and returns its hash
+func storeGraphCommit(t *testing.T, casStore cas.CAS, message string, parents ...cas.Hash) cas.Hash {
+	t.Helper()
+
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+	treeHash, err := builder.buildEmptyTree()
+	if err != nil {
+		t.Fatalf("buildEmptyTree failed: %v", err)
+	}
+
+	data := builder.encodeCommit(&CommitObject{
+		TreeHash:   treeHash,
+		Parents:    parents,
+		Author:     "Test <test@example.com>",
+		Committer:  "Test <test@example.com>",
+		AuthorTime: time.Unix(1700000000, 0),
+		CommitTime: time.Unix(1700000000, 0),
+		Message:    message,
+	})
+	hash := cas.SumB3(data)
+	if err := casStore.Put(hash, data); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	return hash
+}
+
+func TestAheadBehind(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	reader := NewCommitReader(casStore)
+
+	//   root - a1 - a2 - merge
+	//      \           /
+	//       b1 -------+
+	//         \
+	//          b2
+	root := storeGraphCommit(t, casStore, "root")
+	a1 := storeGraphCommit(t, casStore, "a1", root)
+	a2 := storeGraphCommit(t, casStore, "a2", a1)
+	b1 := storeGraphCommit(t, casStore, "b1", root)
+	b2 := storeGraphCommit(t, casStore, "b2", b1)
+	merge := storeGraphCommit(t, casStore, "merge", a2, b1)
+
+	tests := []struct {
+		name          string
+		local, remote cas.Hash
+		ahead, behind int
+	}{
+		{"same commit", a2, a2, 0, 0},
+		{"local ahead", a2, root, 2, 0},
+		{"local behind", root, b2, 0, 2},
+		{"diverged", a2, b2, 2, 2},
+		{"merge includes remote branch", merge, b1, 3, 0},
+		{"merge diverged from remote", merge, b2, 3, 1},
+		{"no remote commits", a1, cas.Hash{}, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ahead, behind, err := reader.AheadBehind(tt.local, tt.remote)
+			if err != nil {
+				t.Fatalf("AheadBehind failed: %v", err)
+			}
+			if ahead != tt.ahead || behind != tt.behind {
+				t.Errorf("Expected ahead %d, behind %d; got ahead %d, behind %d", tt.ahead, tt.behind, ahead, behind)
+			}
+		})
+	}
+}
+
+func TestAheadBehindMissingCommit(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	reader := NewCommitReader(casStore)
+
+	root := storeGraphCommit(t, casStore, "root")
+	missing := cas.SumB3([]byte("not stored"))
+
+	if _, _, err := reader.AheadBehind(root, missing); err == nil {
+		t.Error("Expected an error for a commit that is not in the store")
+	}
+}
+
+func TestMergeBase(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	reader := NewCommitReader(casStore)
+
+	//   root - a1 - a2 - merge
+	//      \           /
+	//       b1 -------+
+	//         \
+	//          b2
+	root := storeGraphCommit(t, casStore, "root")
+	a1 := storeGraphCommit(t, casStore, "a1", root)
+	a2 := storeGraphCommit(t, casStore, "a2", a1)
+	b1 := storeGraphCommit(t, casStore, "b1", root)
+	b2 := storeGraphCommit(t, casStore, "b2", b1)
+	merge := storeGraphCommit(t, casStore, "merge", a2, b1)
+	other := storeGraphCommit(t, casStore, "unrelated")
+
+	tests := []struct {
+		name string
+		a, b cas.Hash
+		want cas.Hash
+	}{
+		{"same commit", a2, a2, a2},
+		{"ancestor", a2, root, root},
+		{"diverged", a2, b2, root},
+		{"through a merge", merge, b2, b1},
+		{"symmetric", b2, merge, b1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := reader.MergeBase(nil, tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("MergeBase failed: %v", err)
+			}
+			if base != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, base)
+			}
+		})
+	}
+
+	if _, err := reader.MergeBase(nil, a2, other); !errors.Is(err, ErrNoMergeBase) {
+		t.Errorf("Expected ErrNoMergeBase for unrelated commits, got %v", err)
+	}
+}
+
+func TestMergeBaseWithMMR(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	mmr := history.NewMMR()
+	// Position 0 is never written to commits, so start the history at 1
+	if _, _, err := mmr.AppendLeaf(history.Leaf{}); err != nil {
+		t.Fatalf("AppendLeaf failed: %v", err)
+	}
+	builder := NewCommitBuilder(casStore, mmr)
+	reader := NewCommitReader(casStore)
+
+	create := func(message string, parents ...cas.Hash) cas.Hash {
+		t.Helper()
+		commit, err := builder.CreateCommit(createTestWorkspaceFiles(casStore), parents, "Test", "Test", message)
+		if err != nil {
+			t.Fatalf("CreateCommit failed: %v", err)
+		}
+		return builder.GetCommitHash(commit)
+	}
+
+	//   root - m1 - merge - m2
+	//      \       /
+	//       f1 ---+- f2
+	root := create("root")
+	m1 := create("m1", root)
+	f1 := create("f1", root)
+	merge := create("merge", m1, f1)
+	m2 := create("m2", merge)
+	f2 := create("f2", f1)
+
+	base, err := reader.MergeBase(mmr, m1, f2)
+	if err != nil || base != root {
+		t.Errorf("Expected root from the skip table, got %s, %v", base, err)
+	}
+
+	// The MMR only links first parents, so the merged-in f1 is found by
+	// walking the commit graph
+	base, err = reader.MergeBase(mmr, m2, f2)
+	if err != nil || base != f1 {
+		t.Errorf("Expected f1 through the merge, got %s, %v", base, err)
+	}
+}
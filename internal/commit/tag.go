@@ -0,0 +1,98 @@
+package commit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+// TagObject is an annotated tag: a commit given a name, together with who
+// tagged it, when and why. Lightweight tags have no object; their ref
+// points at the commit directly.
+type TagObject struct {
+	Target  cas.Hash  // Tagged commit
+	Name    string    // Tag name
+	Tagger  string    // "Name <email>", empty when unknown
+	TagTime time.Time // When the tag was made
+	Message string    // Annotation
+}
+
+// EncodeTag creates the canonical encoding of a tag object, laid out like a
+// commit so it is readable in 'ivaldi show --raw'
+func EncodeTag(tag *TagObject) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "object %s\n", tag.Target)
+	buf.WriteString("type commit\n")
+	fmt.Fprintf(&buf, "tag %s\n", tag.Name)
+	if tag.Tagger != "" {
+		fmt.Fprintf(&buf, "tagger %s %s\n", tag.Tagger, formatTimestamp(tag.TagTime))
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(tag.Message)
+	if !strings.HasSuffix(tag.Message, "\n") {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// DecodeTag parses an encoded tag object
+func DecodeTag(data []byte) (*TagObject, error) {
+	if !bytes.HasPrefix(data, []byte("object ")) {
+		return nil, fmt.Errorf("not a tag object")
+	}
+
+	header, message, _ := bytes.Cut(data, []byte("\n\n"))
+	tag := &TagObject{Message: string(bytes.TrimSuffix(message, []byte{'\n'}))}
+	for _, line := range strings.Split(string(header), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			hash, err := parseHash(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tag target: %w", err)
+			}
+			tag.Target = hash
+		case "type":
+			if value != "commit" {
+				return nil, fmt.Errorf("unsupported tag target type: %s", value)
+			}
+		case "tag":
+			tag.Name = value
+		case "tagger":
+			fields := strings.Fields(value)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("invalid tagger line: %s", value)
+			}
+			tag.Tagger = strings.Join(fields[:len(fields)-2], " ")
+			if timestamp, err := parseTimestamp(fields[len(fields)-2], fields[len(fields)-1]); err == nil {
+				tag.TagTime = timestamp
+			}
+		}
+	}
+	if tag.Target == (cas.Hash{}) || tag.Name == "" {
+		return nil, fmt.Errorf("tag object without target or name")
+	}
+	return tag, nil
+}
+
+// StoreTag stores a tag object and returns its hash
+func StoreTag(casStore cas.CAS, tag *TagObject) (cas.Hash, error) {
+	data := EncodeTag(tag)
+	hash := cas.SumB3(data)
+	if err := casStore.Put(hash, data); err != nil {
+		return cas.Hash{}, fmt.Errorf("failed to store tag %s: %w", tag.Name, err)
+	}
+	return hash, nil
+}
+
+// ReadTag reads a tag object
+func ReadTag(casStore cas.CAS, hash cas.Hash) (*TagObject, error) {
+	data, err := casStore.Get(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tag object: %w", err)
+	}
+	return DecodeTag(data)
+}
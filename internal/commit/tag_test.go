@@ -0,0 +1,37 @@
+package commit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+func TestTagRoundTrip(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	tag := &TagObject{
+		Target:  cas.SumB3([]byte("commit")),
+		Name:    "v1.0",
+		Tagger:  "Jane Doe <jane@example.com>",
+		TagTime: time.Unix(1700000000, 0),
+		Message: "Release 1.0\n\nFirst stable release",
+	}
+
+	hash, err := StoreTag(casStore, tag)
+	if err != nil {
+		t.Fatalf("StoreTag failed: %v", err)
+	}
+	read, err := ReadTag(casStore, hash)
+	if err != nil {
+		t.Fatalf("ReadTag failed: %v", err)
+	}
+	if read.Target != tag.Target || read.Name != tag.Name || read.Tagger != tag.Tagger ||
+		!read.TagTime.Equal(tag.TagTime) || read.Message != tag.Message {
+		t.Errorf("Expected %+v, got %+v", tag, read)
+	}
+
+	// Commits are not tags
+	if _, err := DecodeTag([]byte("tree " + tag.Target.String() + "\n\nmessage\n")); err == nil {
+		t.Error("Expected a commit to be rejected")
+	}
+}
@@ -0,0 +1,150 @@
+package commit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Trailer is a "Key: Value" line at the end of a commit message, such as
+// Signed-off-by or Co-authored-by.
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// String formats the trailer as it appears in a message
+func (t Trailer) String() string {
+	return t.Key + ": " + t.Value
+}
+
+// SignedOffBy is the trailer key of a Developer Certificate of Origin sign-off
+const SignedOffBy = "Signed-off-by"
+
+// Trailers returns the trailers at the end of the commit message
+func (c *CommitObject) Trailers() []Trailer {
+	return ParseTrailers(c.Message)
+}
+
+// ParseTrailer parses a "Key: Value" trailer. Keys consist of letters,
+// digits and dashes; the value may not be empty.
+func ParseTrailer(s string) (Trailer, error) {
+	key, value, ok := strings.Cut(s, ":")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !ok || !isTrailerKey(key) || value == "" || strings.ContainsAny(value, "\r\n") {
+		return Trailer{}, fmt.Errorf("invalid trailer %q (expected \"Key: Value\")", s)
+	}
+	return Trailer{Key: key, Value: value}, nil
+}
+
+// ParseTrailers returns the trailers of a message: the lines of its last
+// paragraph, if every line of it is a trailer. Lines starting with
+// whitespace continue the value of the trailer above. A message that is a
+// single paragraph has no trailers, since that paragraph is its subject.
+func ParseTrailers(message string) []Trailer {
+	paragraphs := splitParagraphs(message)
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	trailers, ok := parseTrailerBlock(paragraphs[len(paragraphs)-1])
+	if !ok {
+		return nil
+	}
+	return trailers
+}
+
+// AddTrailers appends trailers to a message. They join the trailer block
+// that already ends the message, or start one after a blank line. A
+// trailer the block already holds is not added again. Trailing blank lines
+// are dropped, so the message ends with the last trailer.
+func AddTrailers(message string, trailers []Trailer) string {
+	message = strings.TrimRight(message, " \t\r\n")
+	if len(trailers) == 0 {
+		return message
+	}
+
+	var existing []Trailer
+	paragraphs := splitParagraphs(message)
+	if len(paragraphs) >= 2 {
+		if block, ok := parseTrailerBlock(paragraphs[len(paragraphs)-1]); ok {
+			existing = block
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(message)
+	if existing == nil {
+		b.WriteString("\n")
+	}
+	for _, trailer := range trailers {
+		if containsTrailer(existing, trailer) {
+			continue
+		}
+		existing = append(existing, trailer)
+		b.WriteString("\n")
+		b.WriteString(trailer.String())
+	}
+	return b.String()
+}
+
+// splitParagraphs splits a message at blank lines, dropping empty
+// paragraphs
+func splitParagraphs(message string) [][]string {
+	var paragraphs [][]string
+	var current []string
+	for _, line := range strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			if current != nil {
+				paragraphs = append(paragraphs, current)
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if current != nil {
+		paragraphs = append(paragraphs, current)
+	}
+	return paragraphs
+}
+
+// parseTrailerBlock parses a paragraph in which every line is a trailer or
+// continues one
+func parseTrailerBlock(lines []string) ([]Trailer, bool) {
+	var trailers []Trailer
+	for _, line := range lines {
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(trailers) == 0 {
+				return nil, false
+			}
+			trailers[len(trailers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		trailer, err := ParseTrailer(line)
+		if err != nil {
+			return nil, false
+		}
+		trailers = append(trailers, trailer)
+	}
+	return trailers, true
+}
+
+func isTrailerKey(key string) bool {
+	if key == "" || key[0] == '-' {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func containsTrailer(trailers []Trailer, t Trailer) bool {
+	for _, existing := range trailers {
+		if strings.EqualFold(existing.Key, t.Key) && existing.Value == t.Value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package commit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+)
+
+func TestAddTrailers(t *testing.T) {
+	signoff := Trailer{Key: SignedOffBy, Value: "Jane Doe <jane@example.com>"}
+	coauthor := Trailer{Key: "Co-authored-by", Value: "Sam Roe <sam@example.com>"}
+
+	cases := []struct {
+		message  string
+		trailers []Trailer
+		want     string
+	}{
+		{"Fix parser", []Trailer{signoff}, "Fix parser\n\nSigned-off-by: Jane Doe <jane@example.com>"},
+		{"Fix parser\n\nExplain why.\n\n\n", []Trailer{coauthor, signoff},
+			"Fix parser\n\nExplain why.\n\nCo-authored-by: Sam Roe <sam@example.com>\nSigned-off-by: Jane Doe <jane@example.com>"},
+		// An existing trailer block is extended, without repeating a trailer
+		{"Fix parser\n\nCo-authored-by: Sam Roe <sam@example.com>\n", []Trailer{coauthor, signoff},
+			"Fix parser\n\nCo-authored-by: Sam Roe <sam@example.com>\nSigned-off-by: Jane Doe <jane@example.com>"},
+		// A subject that looks like a trailer is still the subject
+		{"Docs: fix typo", []Trailer{signoff}, "Docs: fix typo\n\nSigned-off-by: Jane Doe <jane@example.com>"},
+		{"Fix parser\n", nil, "Fix parser"},
+	}
+	for _, c := range cases {
+		if got := AddTrailers(c.message, c.trailers); got != c.want {
+			t.Errorf("AddTrailers(%q): expected %q, got %q", c.message, c.want, got)
+		}
+	}
+}
+
+func TestParseTrailers(t *testing.T) {
+	message := "Fix parser\n\nBody: not a trailer block,\nsince this line is prose.\n\nReviewed-by: A <a@x>\nNote: spans\n  two lines\n"
+	want := []Trailer{{Key: "Reviewed-by", Value: "A <a@x>"}, {Key: "Note", Value: "spans two lines"}}
+	if got := ParseTrailers(message); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	for _, message := range []string{"Subject: only", "Fix parser\n\nJust a body.", ""} {
+		if got := ParseTrailers(message); got != nil {
+			t.Errorf("ParseTrailers(%q): expected no trailers, got %v", message, got)
+		}
+	}
+
+	for _, s := range []string{"no separator", "Two words: value", "Key:", ": value"} {
+		if _, err := ParseTrailer(s); err == nil {
+			t.Errorf("ParseTrailer(%q): expected an error", s)
+		}
+	}
+}
+
+func TestTrailersSurviveCommitRoundTrip(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewCommitBuilder(casStore, history.NewMMR())
+
+	message := AddTrailers("Add feature\n\nLonger description.", []Trailer{
+		{Key: "Co-authored-by", Value: "Sam Roe <sam@example.com>"},
+		{Key: SignedOffBy, Value: "Jane Doe <jane@example.com>"},
+	})
+	created, err := builder.CreateCommit(createTestWorkspaceFiles(casStore), nil, "Jane Doe <jane@example.com>", "Jane Doe <jane@example.com>", message)
+	if err != nil {
+		t.Fatalf("CreateCommit failed: %v", err)
+	}
+
+	read, err := NewCommitReader(casStore).ReadCommit(builder.GetCommitHash(created))
+	if err != nil {
+		t.Fatalf("ReadCommit failed: %v", err)
+	}
+	if read.Message != message {
+		t.Errorf("Expected message %q, got %q", message, read.Message)
+	}
+	if got := read.Trailers(); !reflect.DeepEqual(got, created.Trailers()) || len(got) != 2 {
+		t.Errorf("Expected the trailers to survive, got %v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package commit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+// Defaults of WalkLimits, far beyond the size of real histories
+const (
+	DefaultMaxWalkCommits = 10000000
+	DefaultMaxWalkDepth   = 5000000
+)
+
+// WalkLimits bound how much history a single walk may visit, so that a
+// malformed history fails with an error rather than running unboundedly.
+// Zero means no limit.
+type WalkLimits struct {
+	MaxCommits int // Commits visited by one walk
+	MaxDepth   int // Parent links followed from the starting commit
+}
+
+// DefaultWalkLimits are the limits of readers created by NewCommitReader
+var DefaultWalkLimits = WalkLimits{MaxCommits: DefaultMaxWalkCommits, MaxDepth: DefaultMaxWalkDepth}
+
+// ErrWalkLimit is returned when a walk exceeds its limits.
+var ErrWalkLimit = errors.New("history walk limit exceeded")
+
+// WalkGuard tracks the commits one walk has visited, so that every walk
+// detects cycles the same way and stays within the reader's limits.
+type WalkGuard struct {
+	limits  WalkLimits
+	visited map[cas.Hash]bool
+}
+
+// NewWalkGuard starts tracking a walk over the reader's commits.
+func (cr *CommitReader) NewWalkGuard() *WalkGuard {
+	return &WalkGuard{limits: cr.Limits, visited: make(map[cas.Hash]bool)}
+}
+
+// Visit marks a commit reached depth parent links from the start of the
+// walk as visited. It reports false if the commit was visited before,
+// which on a first-parent walk means the history has a cycle, and fails
+// once the walk goes beyond its limits.
+func (g *WalkGuard) Visit(hash cas.Hash, depth int) (bool, error) {
+	if g.visited[hash] {
+		return false, nil
+	}
+	if g.limits.MaxCommits > 0 && len(g.visited) >= g.limits.MaxCommits {
+		return false, fmt.Errorf("%w: more than %d commits visited; the history may be malformed (see core.maxWalkCommits)", ErrWalkLimit, g.limits.MaxCommits)
+	}
+	if g.limits.MaxDepth > 0 && depth > g.limits.MaxDepth {
+		return false, fmt.Errorf("%w: more than %d parents deep at %s; the history may be malformed (see core.maxWalkDepth)", ErrWalkLimit, g.limits.MaxDepth, hash.String()[:8])
+	}
+	g.visited[hash] = true
+	return true, nil
+}
+
+// walkAncestors visits head and the commits reachable from it breadth
+// first, each once. visit is called for every commit before it is read,
+// and the parents of a commit are followed only if it returns true. It
+// returns the set of commits visited.
+func (cr *CommitReader) walkAncestors(head cas.Hash, visit func(hash cas.Hash) bool) (map[cas.Hash]bool, error) {
+	type entry struct {
+		hash  cas.Hash
+		depth int
+	}
+
+	guard := cr.NewWalkGuard()
+	if _, err := guard.Visit(head, 0); err != nil {
+		return nil, err
+	}
+	queue := []entry{{head, 0}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if visit != nil && !visit(current.hash) {
+			continue
+		}
+
+		commit, err := cr.ReadCommit(current.hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range commit.Parents {
+			first, err := guard.Visit(parent, current.depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if first {
+				queue = append(queue, entry{parent, current.depth + 1})
+			}
+		}
+	}
+	return guard.visited, nil
+}
@@ -0,0 +1,145 @@
+package commit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+)
+
+// forgingCAS serves objects under hashes that do not match their content,
+// which is the only way to build a history with a cycle
+type forgingCAS struct {
+	cas.CAS
+	forged map[cas.Hash][]byte
+}
+
+func (f *forgingCAS) Get(hash cas.Hash) ([]byte, error) {
+	if data, ok := f.forged[hash]; ok {
+		return data, nil
+	}
+	return f.CAS.Get(hash)
+}
+
+// buildCycle stores two commits that are each other's parent and returns
+// their hashes
+func buildCycle(t *testing.T, store *forgingCAS) (a, b cas.Hash) {
+	t.Helper()
+
+	builder := NewCommitBuilder(store, history.NewMMR())
+	treeHash, err := builder.buildEmptyTree()
+	if err != nil {
+		t.Fatalf("buildEmptyTree failed: %v", err)
+	}
+	encode := func(message string, parent cas.Hash) []byte {
+		return builder.encodeCommit(&CommitObject{
+			TreeHash:   treeHash,
+			Parents:    []cas.Hash{parent},
+			Author:     "Test <test@example.com>",
+			Committer:  "Test <test@example.com>",
+			AuthorTime: time.Unix(1700000000, 0),
+			CommitTime: time.Unix(1700000000, 0),
+			Message:    message,
+		})
+	}
+
+	// a's hash is chosen up front, so b can name it as its parent
+	a = cas.SumB3([]byte("forged commit"))
+	b = storeGraphCommit(t, store, "b", a)
+	store.forged[a] = encode("a", b)
+	return a, b
+}
+
+func TestWalksTerminateOnCycles(t *testing.T) {
+	store := &forgingCAS{CAS: cas.NewMemoryCAS(), forged: make(map[cas.Hash][]byte)}
+	reader := NewCommitReader(store)
+	a, b := buildCycle(t, store)
+	unrelated := storeGraphCommit(t, store, "unrelated")
+
+	var walked []cas.Hash
+	err := reader.WalkFirstParent(a, Filter{}, func(hash cas.Hash, commit *CommitObject) bool {
+		walked = append(walked, hash)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("WalkFirstParent failed: %v", err)
+	}
+	if len(walked) != 2 || walked[0] != a || walked[1] != b {
+		t.Errorf("Expected WalkFirstParent to visit a and b once, got %d commits", len(walked))
+	}
+
+	ancestors, err := reader.Ancestors(a)
+	if err != nil {
+		t.Fatalf("Ancestors failed: %v", err)
+	}
+	if len(ancestors) != 2 || !ancestors[a] || !ancestors[b] {
+		t.Errorf("Expected ancestors a and b, got %d commits", len(ancestors))
+	}
+
+	if ahead, behind, err := reader.AheadBehind(a, unrelated); err != nil || ahead != 2 || behind != 1 {
+		t.Errorf("Expected ahead 2, behind 1; got %d, %d (%v)", ahead, behind, err)
+	}
+
+	if ok, err := reader.IsAncestor(unrelated, a); err != nil || ok {
+		t.Errorf("Expected unrelated not to be an ancestor of a, got %v (%v)", ok, err)
+	}
+
+	if _, err := reader.MergeBase(nil, a, unrelated); !errors.Is(err, ErrNoMergeBase) {
+		t.Errorf("Expected ErrNoMergeBase, got %v", err)
+	}
+	if _, err := reader.MergeBase(nil, a, b); err != nil {
+		t.Errorf("MergeBase of the cycle failed: %v", err)
+	}
+}
+
+func TestWalkLimits(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	reader := NewCommitReader(casStore)
+
+	// root - c1 - c2 - c3 - c4, with a side branch merged into c4. Walking
+	// all parents reaches root through the side branch, so c1 is the
+	// deepest commit, three parents deep.
+	chain := []cas.Hash{storeGraphCommit(t, casStore, "root")}
+	for _, message := range []string{"c1", "c2", "c3"} {
+		chain = append(chain, storeGraphCommit(t, casStore, message, chain[len(chain)-1]))
+	}
+	side := storeGraphCommit(t, casStore, "side", chain[0])
+	head := storeGraphCommit(t, casStore, "c4", chain[len(chain)-1], side)
+
+	walk := func() error {
+		return reader.WalkFirstParent(head, Filter{}, func(cas.Hash, *CommitObject) bool { return true })
+	}
+	ancestors := func() error {
+		_, err := reader.Ancestors(head)
+		return err
+	}
+
+	tests := []struct {
+		name   string
+		limits WalkLimits
+		walk   func() error
+		exceed bool
+	}{
+		{"first-parent walk within limits", WalkLimits{MaxCommits: 5, MaxDepth: 4}, walk, false},
+		{"first-parent walk too deep", WalkLimits{MaxDepth: 3}, walk, true},
+		{"first-parent walk too long", WalkLimits{MaxCommits: 4}, walk, true},
+		{"ancestors within limits", WalkLimits{MaxCommits: 6, MaxDepth: 3}, ancestors, false},
+		{"ancestors too deep", WalkLimits{MaxDepth: 2}, ancestors, true},
+		{"ancestors too many", WalkLimits{MaxCommits: 5}, ancestors, true},
+		{"no limits", WalkLimits{}, ancestors, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader.Limits = tt.limits
+			err := tt.walk()
+			if tt.exceed && !errors.Is(err, ErrWalkLimit) {
+				t.Errorf("Expected ErrWalkLimit, got %v", err)
+			}
+			if !tt.exceed && err != nil {
+				t.Errorf("Expected the walk to succeed, got %v", err)
+			}
+		})
+	}
+}
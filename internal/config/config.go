@@ -3,22 +3,67 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/colors"
 )
 
 // Config represents Ivaldi configuration
 type Config struct {
-	User  UserConfig  `json:"user"`
-	Core  CoreConfig  `json:"core"`
-	Color ColorConfig `json:"color"`
+	User     UserConfig     `json:"user"`
+	Core     CoreConfig     `json:"core"`
+	Color    ColorConfig    `json:"color"`
+	Security SecurityConfig `json:"security"`
+	Push     PushConfig     `json:"push"`
+	Merge    MergeConfig    `json:"merge"`
+	GC       GCConfig       `json:"gc"`
+	GitHub   GitHubConfig   `json:"github"`
+	// Diff holds settings for 'ivaldi diff'
+	Diff DiffConfig `json:"diff"`
+	// Commit selects whether seals are signed without --sign
+	Commit CommitConfig `json:"commit"`
+	// Fetch holds settings for 'ivaldi fetch'
+	Fetch FetchConfig `json:"fetch"`
+	// Status holds settings for 'ivaldi status'
+	Status StatusConfig `json:"status"`
+	// Pull selects how 'ivaldi sync' joins diverged timelines
+	Pull PullConfig `json:"pull"`
+	// Transfer holds settings for objects received from GitHub
+	Transfer TransferConfig `json:"transfer"`
+	// Advice switches off individual hints printed after commands
+	Advice AdviceConfig `json:"advice"`
+	// Init holds settings for new repositories made by forge and download
+	Init InitConfig `json:"init"`
+	// Reflog sets how long reflog entries are kept
+	Reflog ReflogConfig `json:"reflog"`
+	// HTTP holds proxy and TLS settings for talking to GitHub
+	HTTP HTTPConfig `json:"http"`
+	// Credential selects where 'ivaldi login' keeps tokens
+	Credential CredentialConfig `json:"credential"`
+	// Branch maps a timeline name to its upstream branch
+	Branch map[string]BranchConfig `json:"branch,omitempty"`
+	// BranchSetup holds the branch.* settings that apply to new timelines
+	BranchSetup BranchSetupConfig `json:"branch_setup"`
+	// Alias maps a command alias to the arguments it expands to
+	Alias map[string]string `json:"alias,omitempty"`
+	// Difftool maps a tool name to the external diff program 'ivaldi diff
+	// --tool' runs
+	Difftool map[string]DifftoolConfig `json:"difftool,omitempty"`
 }
 
 // UserConfig holds user identity information
 type UserConfig struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	// SigningKey is the key seals are signed with: a gpg key ID, or the
+	// path of an SSH key file or a literal SSH public key
+	SigningKey string `json:"signing_key,omitempty"`
 }
 
 // CoreConfig holds core Ivaldi settings
@@ -26,13 +71,297 @@ type CoreConfig struct {
 	Editor    string `json:"editor,omitempty"`
 	Pager     string `json:"pager,omitempty"`
 	AutoShelf bool   `json:"auto_shelf"`
+	// Whitespace lists the whitespace rules checked by 'diff --check',
+	// e.g. "trailing-space,-missing-newline"
+	Whitespace string `json:"whitespace,omitempty"`
+	// Bare marks a repository without a working tree
+	Bare bool `json:"bare,omitempty"`
+	// AliasShadow lets aliases take precedence over built-in commands
+	AliasShadow bool `json:"alias_shadow,omitempty"`
+	// PrecomposeUnicode ("true" or "false") stores workspace path names in
+	// NFC form. Unset means true on macOS, whose file systems hand out
+	// decomposed names, and false elsewhere.
+	PrecomposeUnicode string `json:"precompose_unicode,omitempty"`
+	// Symlinks ("true" or "false") controls whether symbolic links are
+	// checked out as links. When false they are written as small plain files
+	// holding the link target. Unset means false on Windows, where creating
+	// links needs extra privileges, and true elsewhere.
+	Symlinks string `json:"symlinks,omitempty"`
+	// IgnoreMtime makes workspace comparisons treat files whose content is
+	// unchanged as unchanged, even if their modification time differs
+	IgnoreMtime bool `json:"ignore_mtime,omitempty"`
+	// FileMode ("true" or "false") controls whether a changed file mode
+	// alone makes a file differ. Unset means true.
+	FileMode string `json:"file_mode,omitempty"`
+	// SnapshotStaging makes gather store the content of each file it
+	// stages, so seal commits that content instead of rereading the files
+	SnapshotStaging bool `json:"snapshot_staging,omitempty"`
+	// NestedRepos ("skip" or "include") controls whether directories that
+	// hold a repository of their own are scanned. Unset means skip.
+	NestedRepos string `json:"nested_repos,omitempty"`
+	// HashAlgo records the hash the repository's objects are named by.
+	// Unset means blake3, which repositories created before it was
+	// recorded use.
+	HashAlgo string `json:"hash_algo,omitempty"`
+	// MaxWalkCommits and MaxWalkDepth bound how many commits, and how many
+	// parent links deep, a single history walk may go before it is taken
+	// to be malformed. Unset means the built-in defaults.
+	MaxWalkCommits int `json:"max_walk_commits,omitempty"`
+	MaxWalkDepth   int `json:"max_walk_depth,omitempty"`
+	// BigFileThreshold is the size, such as "512m", above which files are
+	// stored as a single chunk and never compared line by line. Unset
+	// means DefaultBigFileThreshold.
+	BigFileThreshold string `json:"big_file_threshold,omitempty"`
+	// WorkTree is the working directory of a repository whose .ivaldi
+	// directory lives elsewhere, relative to the .ivaldi directory unless
+	// absolute. Unset means the parent of the .ivaldi directory.
+	WorkTree string `json:"worktree,omitempty"`
 }
 
 // ColorConfig holds color settings
 type ColorConfig struct {
-	UI     bool `json:"ui"`
-	Status bool `json:"status"`
-	Diff   bool `json:"diff"`
+	UI     ColorMode `json:"ui"`
+	Status bool      `json:"status"`
+	Diff   bool      `json:"diff"`
+	// Slots overrides the colors of individual slots, keyed by slot name
+	// such as "status.added"
+	Slots map[string]string `json:"slots,omitempty"`
+}
+
+// ColorMode is the color.ui setting: auto, always or never. Older configs
+// stored a boolean, which reads as auto or never.
+type ColorMode string
+
+// UnmarshalJSON accepts both the mode and the older boolean
+func (m *ColorMode) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		*m = ColorMode(colors.ModeNever)
+		if enabled {
+			*m = ColorMode(colors.ModeAuto)
+		}
+		return nil
+	}
+	var mode string
+	if err := json.Unmarshal(data, &mode); err != nil {
+		return err
+	}
+	*m = ColorMode(mode)
+	return nil
+}
+
+// SecurityConfig holds settings for guarding against committing secrets
+type SecurityConfig struct {
+	// SecretPatterns overrides the built-in auto-exclude patterns (.env,
+	// .venv, ...). Nil means the defaults; an empty list means none.
+	SecretPatterns *[]string `json:"secret_patterns,omitempty"`
+}
+
+// Values accepted by push.default
+const (
+	// PushUpstream pushes to the timeline's configured upstream branch,
+	// falling back to the timeline name when none is configured
+	PushUpstream = "upstream"
+	// PushCurrent always pushes to a branch with the timeline's name
+	PushCurrent = "current"
+)
+
+// PushConfig holds settings for 'ivaldi upload'
+type PushConfig struct {
+	// Default selects how the remote branch is chosen (upstream or current)
+	Default string `json:"default,omitempty"`
+}
+
+// MergeStrategies lists the values accepted by merge.defaultStrategy and
+// branch.<timeline>.mergeStrategy, matching the strategies of 'ivaldi fuse'
+var MergeStrategies = []string{"auto", "ours", "theirs", "union", "base"}
+
+// Values accepted by merge.conflictStyle
+const (
+	// ConflictStyleMerge writes the two sides of a conflict
+	ConflictStyleMerge = "merge"
+	// ConflictStyleDiff3 also writes the base content of a conflict
+	ConflictStyleDiff3 = "diff3"
+)
+
+// MergeConfig holds settings for 'ivaldi fuse'
+type MergeConfig struct {
+	// DefaultStrategy is used when fuse is run without --strategy
+	DefaultStrategy string `json:"default_strategy,omitempty"`
+	// ConflictStyle is how 'ivaldi fuse --markers' writes conflicts
+	ConflictStyle string `json:"conflict_style,omitempty"`
+}
+
+// Values accepted by gc.auto
+const (
+	// GCAutoOff never packs objects automatically
+	GCAutoOff = "false"
+	// GCAutoSync packs loose objects before the command returns, giving up
+	// if that takes too long
+	GCAutoSync = "true"
+	// GCAutoBackground packs loose objects in a detached 'ivaldi gc --auto'
+	GCAutoBackground = "background"
+)
+
+// DefaultGCAutoThreshold is the number of loose objects that triggers an
+// automatic gc when gc.autoThreshold is unset
+const DefaultGCAutoThreshold = 1000
+
+// GCConfig holds settings for automatic 'ivaldi gc' runs after seal, fuse
+// and travel overwrite
+type GCConfig struct {
+	// Auto is GCAutoOff (the default), GCAutoSync or GCAutoBackground
+	Auto string `json:"auto,omitempty"`
+	// AutoThreshold is the number of loose objects above which auto gc runs
+	AutoThreshold int `json:"auto_threshold,omitempty"`
+}
+
+// DefaultDiffRenameLimit is the rename limit used when diff.renameLimit is
+// unset, as in Git
+const DefaultDiffRenameLimit = 1000
+
+// DiffConfig holds settings for 'ivaldi diff'
+type DiffConfig struct {
+	// RenameLimit skips inexact rename detection when the removed files
+	// times the added files exceed its square
+	RenameLimit int `json:"rename_limit,omitempty"`
+}
+
+// Signature formats selected by commit.gpgSign and commit.sshSign
+const (
+	SignOpenPGP = "openpgp"
+	SignSSH     = "ssh"
+)
+
+// CommitConfig holds settings for signing seals. The values are "true" or
+// "false", so a repository can turn off signing enabled globally.
+type CommitConfig struct {
+	// GPGSign signs every seal with gpg
+	GPGSign string `json:"gpg_sign,omitempty"`
+	// SSHSign signs every seal with ssh-keygen, and takes precedence
+	SSHSign string `json:"ssh_sign,omitempty"`
+}
+
+// FetchConfig holds settings for 'ivaldi fetch'
+type FetchConfig struct {
+	// PruneTags ("true" or "false") removes fetched tags that were deleted
+	// upstream, as --prune-tags does
+	PruneTags string `json:"prune_tags,omitempty"`
+}
+
+// StatusConfig holds settings for 'ivaldi status'
+type StatusConfig struct {
+	// ShowStash ("true" or "false") makes status count the stashes and
+	// auto-shelves that are kept
+	ShowStash string `json:"show_stash,omitempty"`
+	// RefreshUpstream ("true" or "false") makes status read the head of
+	// the upstream branch from GitHub, as --refresh does
+	RefreshUpstream string `json:"refresh_upstream,omitempty"`
+}
+
+// PullConfig holds settings for 'ivaldi sync'
+type PullConfig struct {
+	// Rebase ("true" or "false") makes sync rebase local seals onto the
+	// remote head when the timeline has diverged, instead of merging
+	Rebase string `json:"rebase,omitempty"`
+}
+
+// TransferConfig holds settings for objects received by download, sync and
+// fetch
+type TransferConfig struct {
+	// FsckObjects ("true" or "false") checks every received tree, commit
+	// and file against the hash the remote names it by before it is
+	// stored. Unset means true.
+	FsckObjects string `json:"fsck_objects,omitempty"`
+}
+
+// AdviceConfig switches the hints commands print to guide the next step.
+// Each field is "true" or "false"; unset means true.
+type AdviceConfig struct {
+	// MergeConflict is the list of resolution commands fuse prints when it
+	// stops on conflicts
+	MergeConflict string `json:"merge_conflict,omitempty"`
+	// StatusHints are the "(use ...)" lines in 'ivaldi status' output
+	StatusHints string `json:"status_hints,omitempty"`
+}
+
+// InitConfig holds settings for 'ivaldi forge' and 'ivaldi download'
+type InitConfig struct {
+	// TemplateDir is a directory whose files seed every new repository:
+	// its worktree subdirectory is copied into the working directory and
+	// everything else into .ivaldi
+	TemplateDir string `json:"template_dir,omitempty"`
+}
+
+// Reflog expiry defaults, as in Git
+const (
+	DefaultReflogExpire            = "90 days"
+	DefaultReflogExpireUnreachable = "30 days"
+)
+
+// ExpireNever is the expiry of reflog entries that are kept forever
+const ExpireNever time.Duration = -1
+
+// ReflogConfig holds the ages at which reflog entries expire, in the form
+// accepted by ParseExpiry
+type ReflogConfig struct {
+	// Expire is the age at which any entry expires
+	Expire string `json:"expire,omitempty"`
+	// ExpireUnreachable is the age at which entries whose seal is no longer
+	// reachable from the timeline expire
+	ExpireUnreachable string `json:"expire_unreachable,omitempty"`
+}
+
+// GitHubConfig points Ivaldi at a GitHub Enterprise server. Empty values
+// mean public GitHub.
+type GitHubConfig struct {
+	// Host is the web host of the server, e.g. "github.example.com"
+	Host string `json:"host,omitempty"`
+	// APIURL overrides the REST API base URL (default https://<host>/api/v3)
+	APIURL string `json:"api_url,omitempty"`
+	// RawURL overrides the raw file content base URL (default https://<host>/raw)
+	RawURL string `json:"raw_url,omitempty"`
+}
+
+// HTTPConfig holds network settings for requests to GitHub
+type HTTPConfig struct {
+	// Proxy is the proxy URL, e.g. "http://proxy.example.com:8080". Unset
+	// means the HTTPS_PROXY and HTTP_PROXY environment variables decide.
+	Proxy string `json:"proxy,omitempty"`
+	// SSLCAInfo is a PEM file of certificate authorities trusted in
+	// addition to the system ones
+	SSLCAInfo string `json:"ssl_ca_info,omitempty"`
+}
+
+// CredentialConfig holds settings for the credential store
+type CredentialConfig struct {
+	// Helper is "store" (the default encrypted file) or an external
+	// git-style credential helper such as "osxkeychain"
+	Helper string `json:"helper,omitempty"`
+}
+
+// BranchConfig holds the upstream mapping of a timeline
+type BranchConfig struct {
+	// Remote is the GitHub repository as owner/repo
+	Remote string `json:"remote,omitempty"`
+	// Merge is the branch on the remote
+	Merge string `json:"merge,omitempty"`
+	// MergeStrategy overrides merge.defaultStrategy when fusing into the timeline
+	MergeStrategy string `json:"merge_strategy,omitempty"`
+}
+
+// BranchSetupConfig holds settings for timelines as they are created
+type BranchSetupConfig struct {
+	// AutoSetupMerge ("true" or "false") records the remote branch a
+	// timeline is created from as its upstream. Unset means true.
+	AutoSetupMerge string `json:"auto_setup_merge,omitempty"`
+}
+
+// DifftoolConfig describes an external diff program
+type DifftoolConfig struct {
+	// Cmd is run by the shell with $LOCAL and $REMOTE set to the files
+	// holding the old and new versions, and $MERGED to the file's path
+	Cmd string `json:"cmd,omitempty"`
 }
 
 // DefaultConfig returns a config with sensible defaults
@@ -48,7 +377,7 @@ func DefaultConfig() *Config {
 			AutoShelf: true,
 		},
 		Color: ColorConfig{
-			UI:     true,
+			UI:     ColorMode(colors.ModeAuto),
 			Status: true,
 			Diff:   true,
 		},
@@ -64,9 +393,25 @@ func globalConfigPath() (string, error) {
 	return filepath.Join(home, ".ivaldiconfig"), nil
 }
 
+// Environment variables that place the repository's .ivaldi directory and
+// its working tree, overriding the current directory and core.worktree
+const (
+	EnvIvaldiDir = "IVALDI_DIR"
+	EnvWorkTree  = "IVALDI_WORK_TREE"
+)
+
+// IvaldiDir returns the repository's metadata directory: $IVALDI_DIR when
+// set, and .ivaldi otherwise
+func IvaldiDir() string {
+	if dir := os.Getenv(EnvIvaldiDir); dir != "" {
+		return dir
+	}
+	return ".ivaldi"
+}
+
 // repoConfigPath returns the path to the repository config file
 func repoConfigPath() string {
-	return filepath.Join(".ivaldi", "config")
+	return filepath.Join(IvaldiDir(), "config")
 }
 
 // LoadConfig loads configuration from both global and repository config files
@@ -138,13 +483,54 @@ func GetValue(key string) (string, error) {
 		return "", err
 	}
 
+	if strings.HasPrefix(key, "alias.") {
+		name, err := aliasName(key)
+		if err != nil {
+			return "", err
+		}
+		return cfg.Alias[name], nil
+	}
+
+	if slot, ok := colorSlot(key); ok {
+		if !colors.IsSlot(slot) {
+			return "", fmt.Errorf("unknown color slot: %s", key)
+		}
+		return cfg.Color.Slots[slot], nil
+	}
+
+	if strings.HasPrefix(key, "branch.") && strings.Count(key, ".") > 1 {
+		name, field, err := splitBranchKey(key)
+		if err != nil {
+			return "", err
+		}
+		branch := cfg.Branch[name]
+		switch field {
+		case "remote":
+			return branch.Remote, nil
+		case "merge":
+			return branch.Merge, nil
+		case "mergestrategy":
+			return branch.MergeStrategy, nil
+		default:
+			return "", fmt.Errorf("unknown branch config field: %s", field)
+		}
+	}
+
+	if strings.HasPrefix(key, "difftool.") {
+		name, err := difftoolName(key)
+		if err != nil {
+			return "", err
+		}
+		return cfg.Difftool[name].Cmd, nil
+	}
+
 	parts := strings.Split(key, ".")
 	if len(parts) != 2 {
 		return "", fmt.Errorf("invalid config key: %s (expected format: section.key)", key)
 	}
 
 	section := parts[0]
-	field := parts[1]
+	field := strings.ToLower(parts[1])
 
 	switch section {
 	case "user":
@@ -153,6 +539,8 @@ func GetValue(key string) (string, error) {
 			return cfg.User.Name, nil
 		case "email":
 			return cfg.User.Email, nil
+		case "signingkey":
+			return cfg.User.SigningKey, nil
 		default:
 			return "", fmt.Errorf("unknown user config field: %s", field)
 		}
@@ -164,13 +552,47 @@ func GetValue(key string) (string, error) {
 			return cfg.Core.Pager, nil
 		case "autoshelf":
 			return fmt.Sprintf("%t", cfg.Core.AutoShelf), nil
+		case "whitespace":
+			return cfg.Core.Whitespace, nil
+		case "bare":
+			return fmt.Sprintf("%t", cfg.Core.Bare), nil
+		case "aliasshadow":
+			return fmt.Sprintf("%t", cfg.Core.AliasShadow), nil
+		case "precomposeunicode":
+			return cfg.Core.PrecomposeUnicode, nil
+		case "symlinks":
+			return cfg.Core.Symlinks, nil
+		case "ignoremtime":
+			return fmt.Sprintf("%t", cfg.Core.IgnoreMtime), nil
+		case "filemode":
+			return cfg.Core.FileMode, nil
+		case "snapshotstaging":
+			return fmt.Sprintf("%t", cfg.Core.SnapshotStaging), nil
+		case "nestedrepos":
+			return cfg.Core.NestedRepos, nil
+		case "hashalgo":
+			return HashAlgo(), nil
+		case "maxwalkcommits":
+			if cfg.Core.MaxWalkCommits == 0 {
+				return "", nil
+			}
+			return strconv.Itoa(cfg.Core.MaxWalkCommits), nil
+		case "maxwalkdepth":
+			if cfg.Core.MaxWalkDepth == 0 {
+				return "", nil
+			}
+			return strconv.Itoa(cfg.Core.MaxWalkDepth), nil
+		case "bigfilethreshold":
+			return cfg.Core.BigFileThreshold, nil
+		case "worktree":
+			return cfg.Core.WorkTree, nil
 		default:
 			return "", fmt.Errorf("unknown core config field: %s", field)
 		}
 	case "color":
 		switch field {
 		case "ui":
-			return fmt.Sprintf("%t", cfg.Color.UI), nil
+			return string(cfg.Color.UI), nil
 		case "status":
 			return fmt.Sprintf("%t", cfg.Color.Status), nil
 		case "diff":
@@ -178,6 +600,152 @@ func GetValue(key string) (string, error) {
 		default:
 			return "", fmt.Errorf("unknown color config field: %s", field)
 		}
+	case "security":
+		switch field {
+		case "secretpatterns":
+			if cfg.Security.SecretPatterns == nil {
+				return "", nil
+			}
+			return strings.Join(*cfg.Security.SecretPatterns, ","), nil
+		default:
+			return "", fmt.Errorf("unknown security config field: %s", field)
+		}
+	case "push":
+		switch field {
+		case "default":
+			return cfg.Push.Default, nil
+		default:
+			return "", fmt.Errorf("unknown push config field: %s", field)
+		}
+	case "merge":
+		switch field {
+		case "defaultstrategy":
+			return cfg.Merge.DefaultStrategy, nil
+		case "conflictstyle":
+			return cfg.Merge.ConflictStyle, nil
+		default:
+			return "", fmt.Errorf("unknown merge config field: %s", field)
+		}
+	case "gc":
+		switch field {
+		case "auto":
+			return cfg.GC.Auto, nil
+		case "autothreshold":
+			if cfg.GC.AutoThreshold == 0 {
+				return "", nil
+			}
+			return strconv.Itoa(cfg.GC.AutoThreshold), nil
+		default:
+			return "", fmt.Errorf("unknown gc config field: %s", field)
+		}
+	case "diff":
+		switch field {
+		case "renamelimit":
+			if cfg.Diff.RenameLimit == 0 {
+				return "", nil
+			}
+			return strconv.Itoa(cfg.Diff.RenameLimit), nil
+		default:
+			return "", fmt.Errorf("unknown diff config field: %s", field)
+		}
+	case "commit":
+		switch field {
+		case "gpgsign":
+			return cfg.Commit.GPGSign, nil
+		case "sshsign":
+			return cfg.Commit.SSHSign, nil
+		default:
+			return "", fmt.Errorf("unknown commit config field: %s", field)
+		}
+	case "fetch":
+		switch field {
+		case "prunetags":
+			return cfg.Fetch.PruneTags, nil
+		default:
+			return "", fmt.Errorf("unknown fetch config field: %s", field)
+		}
+	case "status":
+		switch field {
+		case "showstash":
+			return cfg.Status.ShowStash, nil
+		case "refreshupstream":
+			return cfg.Status.RefreshUpstream, nil
+		default:
+			return "", fmt.Errorf("unknown status config field: %s", field)
+		}
+	case "pull":
+		switch field {
+		case "rebase":
+			return cfg.Pull.Rebase, nil
+		default:
+			return "", fmt.Errorf("unknown pull config field: %s", field)
+		}
+	case "transfer":
+		switch field {
+		case "fsckobjects":
+			return cfg.Transfer.FsckObjects, nil
+		default:
+			return "", fmt.Errorf("unknown transfer config field: %s", field)
+		}
+	case "advice":
+		switch field {
+		case "mergeconflict":
+			return cfg.Advice.MergeConflict, nil
+		case "statushints":
+			return cfg.Advice.StatusHints, nil
+		default:
+			return "", fmt.Errorf("unknown advice config field: %s", field)
+		}
+	case "branch":
+		switch field {
+		case "autosetupmerge":
+			return cfg.BranchSetup.AutoSetupMerge, nil
+		default:
+			return "", fmt.Errorf("unknown branch config field: %s (timeline settings are branch.<timeline>.<field>)", field)
+		}
+	case "init":
+		switch field {
+		case "templatedir":
+			return cfg.Init.TemplateDir, nil
+		default:
+			return "", fmt.Errorf("unknown init config field: %s", field)
+		}
+	case "reflog":
+		switch field {
+		case "expire":
+			return cfg.Reflog.Expire, nil
+		case "expireunreachable":
+			return cfg.Reflog.ExpireUnreachable, nil
+		default:
+			return "", fmt.Errorf("unknown reflog config field: %s", field)
+		}
+	case "github":
+		switch field {
+		case "host":
+			return cfg.GitHub.Host, nil
+		case "apiurl":
+			return cfg.GitHub.APIURL, nil
+		case "rawurl":
+			return cfg.GitHub.RawURL, nil
+		default:
+			return "", fmt.Errorf("unknown github config field: %s", field)
+		}
+	case "http":
+		switch field {
+		case "proxy":
+			return cfg.HTTP.Proxy, nil
+		case "sslcainfo":
+			return cfg.HTTP.SSLCAInfo, nil
+		default:
+			return "", fmt.Errorf("unknown http config field: %s", field)
+		}
+	case "credential":
+		switch field {
+		case "helper":
+			return cfg.Credential.Helper, nil
+		default:
+			return "", fmt.Errorf("unknown credential config field: %s", field)
+		}
 	default:
 		return "", fmt.Errorf("unknown config section: %s", section)
 	}
@@ -187,7 +755,6 @@ func GetValue(key string) (string, error) {
 func SetValue(key, value string, global bool) error {
 	// Load existing config
 	var cfg *Config
-	var err error
 
 	if global {
 		// For global, load from global config or use default
@@ -213,13 +780,93 @@ func SetValue(key, value string, global bool) error {
 		}
 	}
 
+	if strings.HasPrefix(key, "alias.") {
+		name, err := aliasName(key)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(value) == "" {
+			delete(cfg.Alias, name)
+		} else {
+			if cfg.Alias == nil {
+				cfg.Alias = make(map[string]string)
+			}
+			cfg.Alias[name] = value
+		}
+		return saveConfig(cfg, global)
+	}
+
+	if slot, ok := colorSlot(key); ok {
+		if !colors.IsSlot(slot) {
+			return fmt.Errorf("unknown color slot: %s", key)
+		}
+		if strings.TrimSpace(value) == "" {
+			delete(cfg.Color.Slots, slot)
+		} else {
+			if _, err := colors.ParseColor(value); err != nil {
+				return err
+			}
+			if cfg.Color.Slots == nil {
+				cfg.Color.Slots = make(map[string]string)
+			}
+			cfg.Color.Slots[slot] = value
+		}
+		return saveConfig(cfg, global)
+	}
+
+	if strings.HasPrefix(key, "branch.") && strings.Count(key, ".") > 1 {
+		name, field, err := splitBranchKey(key)
+		if err != nil {
+			return err
+		}
+		if cfg.Branch == nil {
+			cfg.Branch = make(map[string]BranchConfig)
+		}
+		branch := cfg.Branch[name]
+		switch field {
+		case "remote":
+			branch.Remote = value
+		case "merge":
+			branch.Merge = value
+		case "mergestrategy":
+			if err := validateMergeStrategy(key, value); err != nil {
+				return err
+			}
+			branch.MergeStrategy = value
+		default:
+			return fmt.Errorf("unknown branch config field: %s", field)
+		}
+		if branch == (BranchConfig{}) {
+			delete(cfg.Branch, name)
+		} else {
+			cfg.Branch[name] = branch
+		}
+		return saveConfig(cfg, global)
+	}
+
+	if strings.HasPrefix(key, "difftool.") {
+		name, err := difftoolName(key)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(value) == "" {
+			delete(cfg.Difftool, name)
+		} else {
+			if cfg.Difftool == nil {
+				cfg.Difftool = make(map[string]DifftoolConfig)
+			}
+			cfg.Difftool[name] = DifftoolConfig{Cmd: value}
+		}
+		return saveConfig(cfg, global)
+	}
+
 	parts := strings.Split(key, ".")
 	if len(parts) != 2 {
 		return fmt.Errorf("invalid config key: %s (expected format: section.key)", key)
 	}
 
 	section := parts[0]
-	field := parts[1]
+	field := strings.ToLower(parts[1])
 
 	// Set the value
 	switch section {
@@ -229,6 +876,8 @@ func SetValue(key, value string, global bool) error {
 			cfg.User.Name = value
 		case "email":
 			cfg.User.Email = value
+		case "signingkey":
+			cfg.User.SigningKey = strings.TrimSpace(value)
 		default:
 			return fmt.Errorf("unknown user config field: %s", field)
 		}
@@ -240,13 +889,88 @@ func SetValue(key, value string, global bool) error {
 			cfg.Core.Pager = value
 		case "autoshelf":
 			cfg.Core.AutoShelf = value == "true"
+		case "whitespace":
+			cfg.Core.Whitespace = value
+		case "bare":
+			cfg.Core.Bare = value == "true"
+		case "aliasshadow":
+			cfg.Core.AliasShadow = value == "true"
+		case "precomposeunicode":
+			if value != "" && value != "true" && value != "false" {
+				return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+			}
+			cfg.Core.PrecomposeUnicode = value
+		case "symlinks":
+			if value != "" && value != "true" && value != "false" {
+				return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+			}
+			cfg.Core.Symlinks = value
+		case "ignoremtime":
+			cfg.Core.IgnoreMtime = value == "true"
+		case "filemode":
+			if value != "" && value != "true" && value != "false" {
+				return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+			}
+			cfg.Core.FileMode = value
+		case "snapshotstaging":
+			cfg.Core.SnapshotStaging = value == "true"
+		case "nestedrepos":
+			if value != "" && value != NestedReposSkip && value != NestedReposInclude {
+				return fmt.Errorf("invalid %s value: %s (expected %s or %s)", key, value, NestedReposSkip, NestedReposInclude)
+			}
+			cfg.Core.NestedRepos = value
+		case "hashalgo":
+			if err := validateHashAlgo(key, value, cfg.Core.HashAlgo, global); err != nil {
+				return err
+			}
+			cfg.Core.HashAlgo = value
+		case "maxwalkcommits", "maxwalkdepth":
+			limit := 0
+			if value != "" {
+				var err error
+				limit, err = strconv.Atoi(value)
+				if err != nil || limit <= 0 {
+					return fmt.Errorf("invalid %s value: %s (expected a positive number)", key, value)
+				}
+			}
+			if field == "maxwalkcommits" {
+				cfg.Core.MaxWalkCommits = limit
+			} else {
+				cfg.Core.MaxWalkDepth = limit
+			}
+		case "bigfilethreshold":
+			if value != "" {
+				if size, err := ParseSize(value); err != nil || size <= 0 {
+					return fmt.Errorf("invalid %s value: %s (expected a positive size such as 512m)", key, value)
+				}
+			}
+			cfg.Core.BigFileThreshold = value
+		case "worktree":
+			if global {
+				return fmt.Errorf("%s belongs to a single repository and cannot be set globally", key)
+			}
+			if value != "" {
+				if err := checkWorkTree(resolveWorkTree(value)); err != nil {
+					return fmt.Errorf("invalid %s value: %w", key, err)
+				}
+			}
+			cfg.Core.WorkTree = value
 		default:
 			return fmt.Errorf("unknown core config field: %s", field)
 		}
 	case "color":
 		switch field {
 		case "ui":
-			cfg.Color.UI = value == "true"
+			switch value {
+			case "true":
+				value = colors.ModeAuto
+			case "false":
+				value = colors.ModeNever
+			case colors.ModeAuto, colors.ModeAlways, colors.ModeNever:
+			default:
+				return fmt.Errorf("invalid %s value: %s (expected %s, %s or %s)", key, value, colors.ModeAuto, colors.ModeAlways, colors.ModeNever)
+			}
+			cfg.Color.UI = ColorMode(value)
 		case "status":
 			cfg.Color.Status = value == "true"
 		case "diff":
@@ -254,18 +978,673 @@ func SetValue(key, value string, global bool) error {
 		default:
 			return fmt.Errorf("unknown color config field: %s", field)
 		}
+	case "security":
+		switch field {
+		case "secretpatterns":
+			patterns := splitList(value)
+			if patterns == nil {
+				patterns = []string{}
+			}
+			cfg.Security.SecretPatterns = &patterns
+		default:
+			return fmt.Errorf("unknown security config field: %s", field)
+		}
+	case "push":
+		switch field {
+		case "default":
+			if value != "" && value != PushUpstream && value != PushCurrent {
+				return fmt.Errorf("invalid push.default value: %s (expected %s or %s)", value, PushUpstream, PushCurrent)
+			}
+			cfg.Push.Default = value
+		default:
+			return fmt.Errorf("unknown push config field: %s", field)
+		}
+	case "merge":
+		switch field {
+		case "defaultstrategy":
+			if err := validateMergeStrategy(key, value); err != nil {
+				return err
+			}
+			cfg.Merge.DefaultStrategy = value
+		case "conflictstyle":
+			if value != "" && value != ConflictStyleMerge && value != ConflictStyleDiff3 {
+				return fmt.Errorf("invalid %s value: %s (expected %s or %s)", key, value, ConflictStyleMerge, ConflictStyleDiff3)
+			}
+			cfg.Merge.ConflictStyle = value
+		default:
+			return fmt.Errorf("unknown merge config field: %s", field)
+		}
+	case "gc":
+		switch field {
+		case "auto":
+			if value != "" && value != GCAutoOff && value != GCAutoSync && value != GCAutoBackground {
+				return fmt.Errorf("invalid %s value: %s (expected %s, %s or %s)", key, value, GCAutoSync, GCAutoOff, GCAutoBackground)
+			}
+			cfg.GC.Auto = value
+		case "autothreshold":
+			if value == "" {
+				cfg.GC.AutoThreshold = 0
+				break
+			}
+			threshold, err := strconv.Atoi(value)
+			if err != nil || threshold <= 0 {
+				return fmt.Errorf("invalid %s value: %s (expected a positive number of loose objects)", key, value)
+			}
+			cfg.GC.AutoThreshold = threshold
+		default:
+			return fmt.Errorf("unknown gc config field: %s", field)
+		}
+	case "diff":
+		switch field {
+		case "renamelimit":
+			if value == "" {
+				cfg.Diff.RenameLimit = 0
+				break
+			}
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit <= 0 {
+				return fmt.Errorf("invalid %s value: %s (expected a positive number of files)", key, value)
+			}
+			cfg.Diff.RenameLimit = limit
+		default:
+			return fmt.Errorf("unknown diff config field: %s", field)
+		}
+	case "commit":
+		if value != "" && value != "true" && value != "false" {
+			return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+		}
+		switch field {
+		case "gpgsign":
+			cfg.Commit.GPGSign = value
+		case "sshsign":
+			cfg.Commit.SSHSign = value
+		default:
+			return fmt.Errorf("unknown commit config field: %s", field)
+		}
+	case "fetch":
+		switch field {
+		case "prunetags":
+			if value != "" && value != "true" && value != "false" {
+				return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+			}
+			cfg.Fetch.PruneTags = value
+		default:
+			return fmt.Errorf("unknown fetch config field: %s", field)
+		}
+	case "status":
+		switch field {
+		case "showstash":
+			if value != "" && value != "true" && value != "false" {
+				return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+			}
+			cfg.Status.ShowStash = value
+		case "refreshupstream":
+			if value != "" && value != "true" && value != "false" {
+				return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+			}
+			cfg.Status.RefreshUpstream = value
+		default:
+			return fmt.Errorf("unknown status config field: %s", field)
+		}
+	case "pull":
+		switch field {
+		case "rebase":
+			if value != "" && value != "true" && value != "false" {
+				return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+			}
+			cfg.Pull.Rebase = value
+		default:
+			return fmt.Errorf("unknown pull config field: %s", field)
+		}
+	case "transfer":
+		switch field {
+		case "fsckobjects":
+			if value != "" && value != "true" && value != "false" {
+				return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+			}
+			cfg.Transfer.FsckObjects = value
+		default:
+			return fmt.Errorf("unknown transfer config field: %s", field)
+		}
+	case "advice":
+		if value != "" && value != "true" && value != "false" {
+			return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+		}
+		switch field {
+		case "mergeconflict":
+			cfg.Advice.MergeConflict = value
+		case "statushints":
+			cfg.Advice.StatusHints = value
+		default:
+			return fmt.Errorf("unknown advice config field: %s", field)
+		}
+	case "branch":
+		switch field {
+		case "autosetupmerge":
+			if value != "" && value != "true" && value != "false" {
+				return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+			}
+			cfg.BranchSetup.AutoSetupMerge = value
+		default:
+			return fmt.Errorf("unknown branch config field: %s (timeline settings are branch.<timeline>.<field>)", field)
+		}
+	case "init":
+		switch field {
+		case "templatedir":
+			cfg.Init.TemplateDir = value
+		default:
+			return fmt.Errorf("unknown init config field: %s", field)
+		}
+	case "reflog":
+		if value != "" {
+			if _, err := ParseExpiry(value); err != nil {
+				return fmt.Errorf("invalid %s value: %w", key, err)
+			}
+		}
+		switch field {
+		case "expire":
+			cfg.Reflog.Expire = value
+		case "expireunreachable":
+			cfg.Reflog.ExpireUnreachable = value
+		default:
+			return fmt.Errorf("unknown reflog config field: %s", field)
+		}
+	case "github":
+		switch field {
+		case "host":
+			cfg.GitHub.Host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(value, "https://"), "http://"), "/")
+		case "apiurl":
+			cfg.GitHub.APIURL = strings.TrimSuffix(value, "/")
+		case "rawurl":
+			cfg.GitHub.RawURL = strings.TrimSuffix(value, "/")
+		default:
+			return fmt.Errorf("unknown github config field: %s", field)
+		}
+	case "http":
+		switch field {
+		case "proxy":
+			cfg.HTTP.Proxy = strings.TrimSpace(value)
+		case "sslcainfo":
+			cfg.HTTP.SSLCAInfo = strings.TrimSpace(value)
+		default:
+			return fmt.Errorf("unknown http config field: %s", field)
+		}
+	case "credential":
+		switch field {
+		case "helper":
+			cfg.Credential.Helper = strings.TrimSpace(value)
+		default:
+			return fmt.Errorf("unknown credential config field: %s", field)
+		}
 	default:
 		return fmt.Errorf("unknown config section: %s", section)
 	}
 
-	// Save config
+	return saveConfig(cfg, global)
+}
+
+// saveConfig saves cfg to the global or repository config file
+func saveConfig(cfg *Config, global bool) error {
 	if global {
-		err = SaveGlobalConfig(cfg)
-	} else {
-		err = SaveRepoConfig(cfg)
+		return SaveGlobalConfig(cfg)
+	}
+	return SaveRepoConfig(cfg)
+}
+
+// validateMergeStrategy checks a merge strategy setting. An empty value
+// unsets it.
+func validateMergeStrategy(key, value string) error {
+	if value == "" {
+		return nil
+	}
+	for _, strategy := range MergeStrategies {
+		if value == strategy {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid %s value: %s (expected one of %s)", key, value, strings.Join(MergeStrategies, ", "))
+}
+
+// splitBranchKey splits "branch.<name>.<field>" into name and field.
+// Timeline names may themselves contain dots.
+func splitBranchKey(key string) (string, string, error) {
+	rest := strings.TrimPrefix(key, "branch.")
+	idx := strings.LastIndex(rest, ".")
+	if idx <= 0 || idx == len(rest)-1 {
+		return "", "", fmt.Errorf("invalid config key: %s (expected format: branch.<timeline>.remote, .merge or .mergeStrategy)", key)
+	}
+	return rest[:idx], strings.ToLower(rest[idx+1:]), nil
+}
+
+// colorSlot returns the slot of a "color.<category>.<slot>" key, lower
+// cased, and whether key has that form
+func colorSlot(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.ToLower(key), "color.")
+	if !ok || strings.Count(rest, ".") != 1 {
+		return "", false
+	}
+	return rest, true
+}
+
+// aliasName extracts the alias from "alias.<name>". Alias names are single
+// command words, so they may not contain dots or whitespace.
+func aliasName(key string) (string, error) {
+	name := strings.TrimPrefix(key, "alias.")
+	if name == "" || strings.ContainsAny(name, ". \t") || strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("invalid alias name in %s (expected format: alias.<name>)", key)
+	}
+	return name, nil
+}
+
+// difftoolName extracts the tool from "difftool.<name>.cmd", the only
+// setting of a difftool
+func difftoolName(key string) (string, error) {
+	rest := strings.TrimPrefix(key, "difftool.")
+	idx := strings.LastIndex(rest, ".")
+	if idx <= 0 || !strings.EqualFold(rest[idx+1:], "cmd") {
+		return "", fmt.Errorf("invalid config key: %s (expected format: difftool.<name>.cmd)", key)
+	}
+	return rest[:idx], nil
+}
+
+// DifftoolCmd returns the command of a difftool, or "" if it is not
+// configured
+func DifftoolCmd(name string) string {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return ""
+	}
+	return cfg.Difftool[name].Cmd
+}
+
+// GetMergeStrategy returns the configured strategy for fusing into a
+// timeline and the key it came from. Both are empty if none is configured.
+func GetMergeStrategy(timeline string) (strategy, key string, err error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return "", "", err
 	}
+	if strategy := cfg.Branch[timeline].MergeStrategy; strategy != "" {
+		return strategy, "branch." + timeline + ".mergeStrategy", nil
+	}
+	if cfg.Merge.DefaultStrategy != "" {
+		return cfg.Merge.DefaultStrategy, "merge.defaultStrategy", nil
+	}
+	return "", "", nil
+}
+
+// ConflictStyle returns merge.conflictStyle, defaulting to
+// ConflictStyleMerge
+func ConflictStyle() string {
+	cfg, err := LoadConfig()
+	if err != nil || cfg.Merge.ConflictStyle == "" {
+		return ConflictStyleMerge
+	}
+	return cfg.Merge.ConflictStyle
+}
+
+// PrecomposeUnicode reports whether workspace path names are stored in NFC
+// form, applying the platform default when core.precomposeUnicode is unset
+func PrecomposeUnicode() bool {
+	cfg, err := LoadConfig()
+	if err == nil && cfg.Core.PrecomposeUnicode != "" {
+		return cfg.Core.PrecomposeUnicode == "true"
+	}
+	return runtime.GOOS == "darwin"
+}
+
+// Symlinks reports whether symbolic links are checked out as links,
+// applying the platform default when core.symlinks is unset
+func Symlinks() bool {
+	cfg, err := LoadConfig()
+	if err == nil && cfg.Core.Symlinks != "" {
+		return cfg.Core.Symlinks == "true"
+	}
+	return runtime.GOOS != "windows"
+}
 
-	return err
+// WorkspaceCompare reports which file metadata workspace comparisons skip,
+// from core.ignoreMtime and core.fileMode
+func WorkspaceCompare() (ignoreModTime, ignoreMode bool) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return false, false
+	}
+	return cfg.Core.IgnoreMtime, cfg.Core.FileMode == "false"
+}
+
+// SnapshotStaging reports whether gather snapshots file content, from
+// core.snapshotStaging
+func SnapshotStaging() bool {
+	cfg, err := LoadConfig()
+	return err == nil && cfg.Core.SnapshotStaging
+}
+
+// Values of core.nestedRepos
+const (
+	NestedReposSkip    = "skip"
+	NestedReposInclude = "include"
+)
+
+// SkipNestedRepos reports whether workspace scans leave out directories
+// that hold a repository of their own, from core.nestedRepos
+func SkipNestedRepos() bool {
+	cfg, err := LoadConfig()
+	return err != nil || cfg.Core.NestedRepos != NestedReposInclude
+}
+
+// HashAlgoBLAKE3 is the hash objects are named by, and the default of
+// core.hashAlgo
+const HashAlgoBLAKE3 = "blake3"
+
+// HashAlgo returns the hash the repository's objects are named by, from
+// core.hashAlgo. Only the repository config is consulted, as the setting
+// describes the objects on disk rather than a preference.
+func HashAlgo() string {
+	data, err := os.ReadFile(repoConfigPath())
+	if err != nil {
+		return HashAlgoBLAKE3
+	}
+	var repoCfg Config
+	if err := json.Unmarshal(data, &repoCfg); err != nil || repoCfg.Core.HashAlgo == "" {
+		return HashAlgoBLAKE3
+	}
+	return repoCfg.Core.HashAlgo
+}
+
+// WorkTree returns the working directory set by core.worktree, resolved
+// against the .ivaldi directory, or "" when unset. Only the repository
+// config is consulted.
+func WorkTree() string {
+	data, err := os.ReadFile(repoConfigPath())
+	if err != nil {
+		return ""
+	}
+	var repoCfg Config
+	if err := json.Unmarshal(data, &repoCfg); err != nil || repoCfg.Core.WorkTree == "" {
+		return ""
+	}
+	return resolveWorkTree(repoCfg.Core.WorkTree)
+}
+
+// resolveWorkTree makes a core.worktree value relative to the .ivaldi
+// directory
+func resolveWorkTree(value string) string {
+	if filepath.IsAbs(value) {
+		return value
+	}
+	return filepath.Join(IvaldiDir(), value)
+}
+
+// checkWorkTree reports an error unless dir is an existing directory
+func checkWorkTree(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("working tree %s does not exist", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("working tree %s is not a directory", dir)
+	}
+	return nil
+}
+
+// validateHashAlgo checks a new core.hashAlgo value. The setting records
+// how existing objects were hashed, so it can be recorded for a repository
+// but never changed: that takes rewriting every object.
+func validateHashAlgo(key, value, current string, global bool) error {
+	if global {
+		return fmt.Errorf("%s describes a repository's objects and cannot be set globally", key)
+	}
+	if current != "" && value != current {
+		return fmt.Errorf("%s is %s; changing it does not rehash the repository's objects, so it cannot be changed with config", key, current)
+	}
+	if value != HashAlgoBLAKE3 {
+		return fmt.Errorf("invalid %s value: %s (only %s is supported)", key, value, HashAlgoBLAKE3)
+	}
+	return nil
+}
+
+// GCAuto returns the auto gc mode and the number of loose objects that
+// triggers it, applying the defaults for unset values
+func GCAuto() (mode string, threshold int) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return GCAutoOff, DefaultGCAutoThreshold
+	}
+	mode, threshold = cfg.GC.Auto, cfg.GC.AutoThreshold
+	if mode == "" {
+		mode = GCAutoOff
+	}
+	if threshold <= 0 {
+		threshold = DefaultGCAutoThreshold
+	}
+	return mode, threshold
+}
+
+// WalkLimits returns core.maxWalkCommits and core.maxWalkDepth, or 0 for
+// each one that is unset
+func WalkLimits() (maxCommits, maxDepth int) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Core.MaxWalkCommits, cfg.Core.MaxWalkDepth
+}
+
+// DefaultBigFileThreshold is the big file threshold used when
+// core.bigFileThreshold is unset, as in Git
+const DefaultBigFileThreshold = 512 << 20
+
+// BigFileThreshold returns the size in bytes above which files are stored
+// as a single chunk and never compared line by line, from
+// core.bigFileThreshold
+func BigFileThreshold() int64 {
+	cfg, err := LoadConfig()
+	if err != nil || cfg.Core.BigFileThreshold == "" {
+		return DefaultBigFileThreshold
+	}
+	size, err := ParseSize(cfg.Core.BigFileThreshold)
+	if err != nil || size <= 0 {
+		return DefaultBigFileThreshold
+	}
+	return size
+}
+
+// ParseSize parses a number of bytes with an optional k, m or g suffix for
+// KiB, MiB or GiB, as in "512m"
+func ParseSize(value string) (int64, error) {
+	number, shift := strings.ToLower(strings.TrimSpace(value)), 0
+	switch {
+	case strings.HasSuffix(number, "k"):
+		shift = 10
+	case strings.HasSuffix(number, "m"):
+		shift = 20
+	case strings.HasSuffix(number, "g"):
+		shift = 30
+	}
+	if shift > 0 {
+		number = number[:len(number)-1]
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return n << shift, nil
+}
+
+// DiffRenameLimit returns the rename limit of diffs, from diff.renameLimit
+func DiffRenameLimit() int {
+	cfg, err := LoadConfig()
+	if err != nil || cfg.Diff.RenameLimit <= 0 {
+		return DefaultDiffRenameLimit
+	}
+	return cfg.Diff.RenameLimit
+}
+
+// SignSeals returns the format seals are signed in by default, from
+// commit.sshSign and commit.gpgSign, or "" when they are not signed
+func SignSeals() string {
+	cfg, err := LoadConfig()
+	switch {
+	case err != nil:
+		return ""
+	case cfg.Commit.SSHSign == "true":
+		return SignSSH
+	case cfg.Commit.GPGSign == "true":
+		return SignOpenPGP
+	default:
+		return ""
+	}
+}
+
+// PruneTags reports whether fetch removes fetched tags that were deleted
+// upstream, from fetch.pruneTags
+func PruneTags() bool {
+	cfg, err := LoadConfig()
+	return err == nil && cfg.Fetch.PruneTags == "true"
+}
+
+// StatusShowStash reports whether status counts stashes and auto-shelves,
+// from status.showStash
+func StatusShowStash() bool {
+	cfg, err := LoadConfig()
+	return err == nil && cfg.Status.ShowStash == "true"
+}
+
+// StatusRefreshUpstream reports whether status reads the upstream branch
+// head from GitHub, from status.refreshUpstream
+func StatusRefreshUpstream() bool {
+	cfg, err := LoadConfig()
+	return err == nil && cfg.Status.RefreshUpstream == "true"
+}
+
+// PullRebase reports whether sync rebases diverged timelines rather than
+// merging them, from pull.rebase
+func PullRebase() bool {
+	cfg, err := LoadConfig()
+	return err == nil && cfg.Pull.Rebase == "true"
+}
+
+// TransferFsckObjects reports whether received objects are checked before
+// they are stored, from transfer.fsckObjects
+func TransferFsckObjects() bool {
+	cfg, err := LoadConfig()
+	return err != nil || cfg.Transfer.FsckObjects != "false"
+}
+
+// Advice names a hint that advice.<name> switches off
+type Advice string
+
+// The hints that can be switched off
+const (
+	AdviceMergeConflict Advice = "mergeConflict"
+	AdviceStatusHints   Advice = "statusHints"
+)
+
+// AdviceEnabled reports whether a hint is shown. Hints are shown unless
+// advice.<name> is false.
+func AdviceEnabled(advice Advice) bool {
+	value, err := GetValue("advice." + string(advice))
+	return err != nil || value != "false"
+}
+
+// TemplateDir returns the directory new repositories are seeded from, from
+// init.templateDir, with a leading ~ expanded. Empty means none.
+func TemplateDir() string {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return ""
+	}
+	dir := cfg.Init.TemplateDir
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[1:])
+		}
+	}
+	return dir
+}
+
+// ParseExpiry parses a reflog expiry: a number of days or weeks such as
+// "90 days", "90.days", "90d" or "12 weeks", "never" or "now"
+func ParseExpiry(value string) (time.Duration, error) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	switch v {
+	case "never", "false":
+		return ExpireNever, nil
+	case "now", "all":
+		return 0, nil
+	}
+
+	number := strings.TrimRight(v, "abcdefghijklmnopqrstuvwxyz. ")
+	unit := strings.Trim(v[len(number):], ". ")
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not an expiry (expected e.g. \"90 days\", \"never\" or \"now\")", value)
+	}
+	switch unit {
+	case "", "d", "day", "days":
+		return time.Duration(n) * 24 * time.Hour, nil
+	case "w", "week", "weeks":
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unknown unit %q in expiry %q (expected days or weeks)", unit, value)
+	}
+}
+
+// ReflogExpiry returns how old reflog entries, and entries whose seal is
+// unreachable, must be to expire, from reflog.expire and
+// reflog.expireUnreachable. ExpireNever means they are kept.
+func ReflogExpiry() (expire, expireUnreachable time.Duration) {
+	expireValue, unreachableValue := DefaultReflogExpire, DefaultReflogExpireUnreachable
+	if cfg, err := LoadConfig(); err == nil {
+		if cfg.Reflog.Expire != "" {
+			expireValue = cfg.Reflog.Expire
+		}
+		if cfg.Reflog.ExpireUnreachable != "" {
+			unreachableValue = cfg.Reflog.ExpireUnreachable
+		}
+	}
+	// Values are checked when set; a bad one in a hand-edited file falls
+	// back to the default
+	expire, err := ParseExpiry(expireValue)
+	if err != nil {
+		expire, _ = ParseExpiry(DefaultReflogExpire)
+	}
+	expireUnreachable, err = ParseExpiry(unreachableValue)
+	if err != nil {
+		expireUnreachable, _ = ParseExpiry(DefaultReflogExpireUnreachable)
+	}
+	return expire, expireUnreachable
+}
+
+// GetUpstream returns the configured upstream remote and branch of a timeline.
+// Both are empty if no upstream is configured.
+func GetUpstream(timeline string) (remote, merge string, err error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return "", "", err
+	}
+	branch := cfg.Branch[timeline]
+	return branch.Remote, branch.Merge, nil
+}
+
+// AutoSetupMerge reports whether a timeline created from a remote branch
+// records that branch as its upstream, per branch.autoSetupMerge
+func AutoSetupMerge() bool {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return true
+	}
+	return cfg.BranchSetup.AutoSetupMerge != "false"
+}
+
+// SetUpstream records the upstream remote and branch of a timeline in the
+// repository config
+func SetUpstream(timeline, remote, merge string) error {
+	if err := SetValue("branch."+timeline+".remote", remote, false); err != nil {
+		return err
+	}
+	return SetValue("branch."+timeline+".merge", merge, false)
 }
 
 // GetAuthor returns the formatted author string "Name <email>"
@@ -292,6 +1671,66 @@ func mergeConfig(dst, src *Config) {
 	if src.User.Email != "" {
 		dst.User.Email = src.User.Email
 	}
+	if src.User.SigningKey != "" {
+		dst.User.SigningKey = src.User.SigningKey
+	}
+
+	// Merge signing config
+	if src.Commit.GPGSign != "" {
+		dst.Commit.GPGSign = src.Commit.GPGSign
+	}
+	if src.Commit.SSHSign != "" {
+		dst.Commit.SSHSign = src.Commit.SSHSign
+	}
+
+	// Merge fetch config
+	if src.Fetch.PruneTags != "" {
+		dst.Fetch.PruneTags = src.Fetch.PruneTags
+	}
+
+	// Merge status config
+	if src.Status.ShowStash != "" {
+		dst.Status.ShowStash = src.Status.ShowStash
+	}
+	if src.Status.RefreshUpstream != "" {
+		dst.Status.RefreshUpstream = src.Status.RefreshUpstream
+	}
+
+	// Merge pull config
+	if src.Pull.Rebase != "" {
+		dst.Pull.Rebase = src.Pull.Rebase
+	}
+
+	// Merge transfer config
+	if src.Transfer.FsckObjects != "" {
+		dst.Transfer.FsckObjects = src.Transfer.FsckObjects
+	}
+
+	// Merge advice config
+	if src.Advice.MergeConflict != "" {
+		dst.Advice.MergeConflict = src.Advice.MergeConflict
+	}
+	if src.Advice.StatusHints != "" {
+		dst.Advice.StatusHints = src.Advice.StatusHints
+	}
+
+	// Merge branch setup config
+	if src.BranchSetup.AutoSetupMerge != "" {
+		dst.BranchSetup.AutoSetupMerge = src.BranchSetup.AutoSetupMerge
+	}
+
+	// Merge init config
+	if src.Init.TemplateDir != "" {
+		dst.Init.TemplateDir = src.Init.TemplateDir
+	}
+
+	// Merge reflog config
+	if src.Reflog.Expire != "" {
+		dst.Reflog.Expire = src.Reflog.Expire
+	}
+	if src.Reflog.ExpireUnreachable != "" {
+		dst.Reflog.ExpireUnreachable = src.Reflog.ExpireUnreachable
+	}
 
 	// Merge core config
 	if src.Core.Editor != "" {
@@ -300,11 +1739,154 @@ func mergeConfig(dst, src *Config) {
 	if src.Core.Pager != "" {
 		dst.Core.Pager = src.Core.Pager
 	}
+	if src.Core.Whitespace != "" {
+		dst.Core.Whitespace = src.Core.Whitespace
+	}
 	// AutoShelf is always merged (bool values)
 	dst.Core.AutoShelf = src.Core.AutoShelf
+	dst.Core.Bare = src.Core.Bare
+	if src.Core.AliasShadow {
+		dst.Core.AliasShadow = true
+	}
+	if src.Core.PrecomposeUnicode != "" {
+		dst.Core.PrecomposeUnicode = src.Core.PrecomposeUnicode
+	}
+	if src.Core.Symlinks != "" {
+		dst.Core.Symlinks = src.Core.Symlinks
+	}
+	if src.Core.IgnoreMtime {
+		dst.Core.IgnoreMtime = true
+	}
+	if src.Core.FileMode != "" {
+		dst.Core.FileMode = src.Core.FileMode
+	}
+	if src.Core.SnapshotStaging {
+		dst.Core.SnapshotStaging = true
+	}
+	if src.Core.NestedRepos != "" {
+		dst.Core.NestedRepos = src.Core.NestedRepos
+	}
+	if src.Core.HashAlgo != "" {
+		dst.Core.HashAlgo = src.Core.HashAlgo
+	}
+	if src.Core.MaxWalkCommits > 0 {
+		dst.Core.MaxWalkCommits = src.Core.MaxWalkCommits
+	}
+	if src.Core.MaxWalkDepth > 0 {
+		dst.Core.MaxWalkDepth = src.Core.MaxWalkDepth
+	}
+	if src.Core.BigFileThreshold != "" {
+		dst.Core.BigFileThreshold = src.Core.BigFileThreshold
+	}
+	if src.Core.WorkTree != "" {
+		dst.Core.WorkTree = src.Core.WorkTree
+	}
 
 	// Merge color config (bool values always merged)
-	dst.Color.UI = src.Color.UI
+	if src.Color.UI != "" {
+		dst.Color.UI = src.Color.UI
+	}
 	dst.Color.Status = src.Color.Status
 	dst.Color.Diff = src.Color.Diff
+	for slot, spec := range src.Color.Slots {
+		if dst.Color.Slots == nil {
+			dst.Color.Slots = make(map[string]string)
+		}
+		dst.Color.Slots[slot] = spec
+	}
+
+	// Merge security config
+	if src.Security.SecretPatterns != nil {
+		dst.Security.SecretPatterns = src.Security.SecretPatterns
+	}
+
+	// Merge push config
+	if src.Push.Default != "" {
+		dst.Push.Default = src.Push.Default
+	}
+
+	// Merge fuse config
+	if src.Merge.DefaultStrategy != "" {
+		dst.Merge.DefaultStrategy = src.Merge.DefaultStrategy
+	}
+	if src.Merge.ConflictStyle != "" {
+		dst.Merge.ConflictStyle = src.Merge.ConflictStyle
+	}
+
+	// Merge gc config
+	if src.GC.Auto != "" {
+		dst.GC.Auto = src.GC.Auto
+	}
+	if src.GC.AutoThreshold > 0 {
+		dst.GC.AutoThreshold = src.GC.AutoThreshold
+	}
+
+	// Merge diff config
+	if src.Diff.RenameLimit > 0 {
+		dst.Diff.RenameLimit = src.Diff.RenameLimit
+	}
+
+	// Merge GitHub server config
+	if src.GitHub.Host != "" {
+		dst.GitHub.Host = src.GitHub.Host
+	}
+	if src.GitHub.APIURL != "" {
+		dst.GitHub.APIURL = src.GitHub.APIURL
+	}
+	if src.GitHub.RawURL != "" {
+		dst.GitHub.RawURL = src.GitHub.RawURL
+	}
+
+	// Merge HTTP config
+	if src.HTTP.Proxy != "" {
+		dst.HTTP.Proxy = src.HTTP.Proxy
+	}
+	if src.HTTP.SSLCAInfo != "" {
+		dst.HTTP.SSLCAInfo = src.HTTP.SSLCAInfo
+	}
+
+	// Merge credential config
+	if src.Credential.Helper != "" {
+		dst.Credential.Helper = src.Credential.Helper
+	}
+
+	// Merge upstream mappings per timeline. A repository entry without a
+	// strategy keeps the global one.
+	for name, branch := range src.Branch {
+		if dst.Branch == nil {
+			dst.Branch = make(map[string]BranchConfig)
+		}
+		if branch.MergeStrategy == "" {
+			branch.MergeStrategy = dst.Branch[name].MergeStrategy
+		}
+		dst.Branch[name] = branch
+	}
+
+	// Merge aliases, repository aliases replacing global ones of the same name
+	for name, expansion := range src.Alias {
+		if dst.Alias == nil {
+			dst.Alias = make(map[string]string)
+		}
+		dst.Alias[name] = expansion
+	}
+
+	// Merge difftools, repository tools replacing global ones of the same name
+	for name, tool := range src.Difftool {
+		if dst.Difftool == nil {
+			dst.Difftool = make(map[string]DifftoolConfig)
+		}
+		dst.Difftool[name] = tool
+	}
+}
+
+// splitList splits a comma-separated config value into its trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
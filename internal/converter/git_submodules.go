@@ -48,36 +48,48 @@ func ConvertGitSubmodulesToIvaldi(
 		return result, fmt.Errorf("write .ivaldimodules: %w", err)
 	}
 
-	for _, gitsub := range gitmodules {
+	for i, gitsub := range gitmodules {
 		log.Printf("Converting Git submodule: %s", gitsub.Path)
 
 		submodulePath := filepath.Join(workDir, gitsub.Path)
 		submoduleGitDir := filepath.Join(submodulePath, ".git")
 
-		var commitHash string
+		// The commit the superproject pins, when its Git history is at hand
+		commitHash := gitlinkCommit(gitDir, gitsub.Path)
 
 		if _, err := os.Stat(submoduleGitDir); err == nil {
-			commitHash, err = getGitSubmoduleCommit(submodulePath)
+			head, err := getGitSubmoduleCommit(submodulePath)
 			if err != nil {
 				result.Errors = append(result.Errors,
 					fmt.Errorf("get commit for %s: %w", gitsub.Path, err))
 				result.Skipped++
 				continue
 			}
+			if commitHash == "" {
+				commitHash = head
+			}
 		} else {
 			log.Printf("Cloning submodule %s from %s", gitsub.Path, gitsub.URL)
 
-			commitHash, err = cloneGitSubmodule(gitsub.URL, gitsub.Path, workDir)
+			head, err := cloneGitSubmodule(gitsub.URL, gitsub.Path, workDir)
 			if err != nil {
 				result.Errors = append(result.Errors,
 					fmt.Errorf("clone submodule %s: %w", gitsub.Path, err))
 				result.Skipped++
 				continue
 			}
+			if commitHash == "" {
+				commitHash = head
+			} else if commitHash != head {
+				if err := checkoutGitCommit(submodulePath, commitHash); err != nil {
+					log.Printf("Warning: failed to check out pinned commit of %s: %v", gitsub.Path, err)
+				}
+			}
 			result.ClonedModules++
 		}
+		ivaldimodules[i].GitCommit = commitHash
 
-		submoduleIvaldiDir := filepath.Join(ivaldiDir, "modules", gitsub.Path)
+		submoduleIvaldiDir := submodule.ModuleDir(ivaldiDir, gitsub.Path)
 		if err := os.MkdirAll(submoduleIvaldiDir, 0755); err != nil {
 			result.Errors = append(result.Errors,
 				fmt.Errorf("create submodule ivaldi dir: %w", err))
@@ -101,6 +113,15 @@ func ConvertGitSubmodulesToIvaldi(
 		}
 	}
 
+	// Record the pinned commits, and keep the settings of this clone in
+	// .ivaldi for the submodule commands
+	if err := submodule.WriteIvaldimodules(ivaldimodulesPath, ivaldimodules); err != nil {
+		return result, fmt.Errorf("write .ivaldimodules: %w", err)
+	}
+	if err := submodule.SaveLocal(ivaldiDir, ivaldimodules); err != nil {
+		return result, err
+	}
+
 	return result, nil
 }
 
@@ -192,6 +213,102 @@ func cloneGitSubmodule(url, path, workDir string) (commitHash string, err error)
 	return getGitSubmoduleCommit(submodulePath)
 }
 
+// GitSubmoduleHead returns the commit checked out in a Git submodule
+func GitSubmoduleHead(submodulePath string) (string, error) {
+	return getGitSubmoduleCommit(submodulePath)
+}
+
+// UpdateGitSubmodule brings the Git submodule at path to commit: it clones
+// the submodule from url when it is not checked out, fetches otherwise, and
+// checks out the commit. An empty commit leaves a fresh clone on the default
+// branch and an existing checkout where it is. It reports whether the
+// submodule was cloned.
+func UpdateGitSubmodule(url, path, workDir, commit string) (bool, error) {
+	submodulePath := filepath.Join(workDir, filepath.FromSlash(path))
+	cloned := false
+	if _, err := os.Stat(filepath.Join(submodulePath, ".git")); os.IsNotExist(err) {
+		if _, err := cloneGitSubmodule(url, path, workDir); err != nil {
+			return false, err
+		}
+		cloned = true
+	} else if commit != "" {
+		if head, err := getGitSubmoduleCommit(submodulePath); err == nil && head == commit {
+			return false, nil
+		}
+		cmd := exec.Command("git", "fetch", "origin")
+		cmd.Dir = submodulePath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return false, fmt.Errorf("git fetch: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	if commit == "" {
+		return cloned, nil
+	}
+	return cloned, checkoutGitCommit(submodulePath, commit)
+}
+
+// SetGitSubmoduleURL points the origin remote of a Git submodule at url
+func SetGitSubmoduleURL(submodulePath, url string) error {
+	cmd := exec.Command("git", "remote", "set-url", "origin", url)
+	cmd.Dir = submodulePath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git remote set-url: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// ImportGitSubmodule converts the Git history of a submodule, and of the
+// submodules nested in it, into its directory in .ivaldi
+func ImportGitSubmodule(submodulePath, moduleDir string) error {
+	return initializeIvaldiInSubmodule(submodulePath, moduleDir, true)
+}
+
+// checkoutGitCommit detaches the checkout of a Git submodule at commit
+func checkoutGitCommit(submodulePath, commit string) error {
+	cmd := exec.Command("git", "checkout", "--quiet", "--detach", commit)
+	cmd.Dir = submodulePath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git checkout %s: %w: %s", commit, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// gitlinkCommit returns the commit the superproject's HEAD pins for the
+// submodule at path, or "" when its Git history is not available
+func gitlinkCommit(gitDir, path string) string {
+	if _, err := os.Stat(gitDir); err != nil {
+		return ""
+	}
+	output, err := exec.Command("git", "--git-dir", gitDir, "ls-tree", "HEAD", "--", path).Output()
+	if err != nil {
+		return ""
+	}
+	// <mode> commit <sha>\t<path>
+	fields := strings.Fields(string(output))
+	if len(fields) < 3 || fields[1] != "commit" {
+		return ""
+	}
+	return fields[2]
+}
+
+// gitSubmoduleDir returns the Git directory of a submodule. Checkouts made
+// by 'git submodule' hold a .git file pointing into the superproject's
+// .git/modules instead of a directory.
+func gitSubmoduleDir(submodulePath string) string {
+	gitDir := filepath.Join(submodulePath, ".git")
+	if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+		return gitDir
+	}
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir")
+	cmd.Dir = submodulePath
+	output, err := cmd.Output()
+	if err != nil {
+		return gitDir
+	}
+	return strings.TrimSpace(string(output))
+}
+
 func getGitSubmoduleCommit(submodulePath string) (string, error) {
 	cmd := exec.Command("git", "rev-parse", "HEAD")
 	cmd.Dir = submodulePath
@@ -209,7 +326,7 @@ func initializeIvaldiInSubmodule(submodulePath, ivaldiDir string, recursive bool
 		return fmt.Errorf("create .ivaldi dir: %w", err)
 	}
 
-	gitDir := filepath.Join(submodulePath, ".git")
+	gitDir := gitSubmoduleDir(submodulePath)
 	convResult, err := ConvertGitObjectsToIvaldiConcurrent(gitDir, ivaldiDir, 8)
 	if err != nil {
 		return fmt.Errorf("convert git objects: %w", err)
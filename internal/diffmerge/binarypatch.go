@@ -0,0 +1,418 @@
+package diffmerge
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Binary patches use Git's "GIT binary patch" format so that they can also
+// be applied with 'git apply'. Each patch holds a forward hunk turning the old
+// content into the new one and a reverse hunk undoing it. A hunk is either
+// the full new content ("literal") or a copy/insert delta against the other
+// side ("delta"), zlib-compressed and written in Git's base85 encoding.
+
+// BinaryHunkType says how a binary hunk stores its data.
+type BinaryHunkType string
+
+const (
+	BinaryLiteral BinaryHunkType = "literal"
+	BinaryDelta   BinaryHunkType = "delta"
+)
+
+// BinaryHunk is one direction of a binary patch.
+type BinaryHunk struct {
+	Type BinaryHunkType
+	Data []byte // Uncompressed content or delta
+}
+
+// BinaryPatch is a parsed "GIT binary patch" section.
+type BinaryPatch struct {
+	Forward BinaryHunk
+	Reverse BinaryHunk
+}
+
+// GitBlobID returns the Git object ID of content, as used on the index line
+// of a binary patch. Empty content that does not exist is the zero ID.
+func GitBlobID(content []byte, exists bool) string {
+	if !exists {
+		return strings.Repeat("0", 40)
+	}
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// WriteBinaryPatch writes the "GIT binary patch" section turning oldContent
+// into newContent. Each direction is written as a delta when that is smaller
+// than the full content.
+func WriteBinaryPatch(w io.Writer, oldContent, newContent []byte) error {
+	if _, err := fmt.Fprintln(w, "GIT binary patch"); err != nil {
+		return err
+	}
+	if err := writeBinaryHunk(w, oldContent, newContent); err != nil {
+		return err
+	}
+	return writeBinaryHunk(w, newContent, oldContent)
+}
+
+// writeBinaryHunk writes the hunk producing target from source.
+func writeBinaryHunk(w io.Writer, source, target []byte) error {
+	hunk := BinaryHunk{Type: BinaryLiteral, Data: target}
+	if len(source) > 0 && len(target) > 0 {
+		if delta := ComputeBinaryDelta(source, target); len(delta) < len(target) {
+			hunk = BinaryHunk{Type: BinaryDelta, Data: delta}
+		}
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(hunk.Data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %d\n", hunk.Type, len(hunk.Data))
+	data := compressed.Bytes()
+	for len(data) > 0 {
+		n := min(len(data), 52)
+		buf.WriteByte(base85LineLength(n))
+		buf.WriteString(encodeBase85(data[:n]))
+		buf.WriteByte('\n')
+		data = data[n:]
+	}
+	buf.WriteByte('\n')
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ParseBinaryPatch parses the hunks following a "GIT binary patch" line.
+// The reverse hunk is optional, as in Git.
+func ParseBinaryPatch(r *bufio.Reader) (*BinaryPatch, error) {
+	forward, err := parseBinaryHunk(r)
+	if err != nil {
+		return nil, err
+	}
+	patch := &BinaryPatch{Forward: *forward}
+
+	// A reverse hunk follows unless the next line starts something else
+	next, _ := r.Peek(len(BinaryLiteral) + 1)
+	if bytes.HasPrefix(next, []byte(BinaryLiteral+" ")) || bytes.HasPrefix(next, []byte(BinaryDelta+" ")) {
+		reverse, err := parseBinaryHunk(r)
+		if err != nil {
+			return nil, err
+		}
+		patch.Reverse = *reverse
+	}
+	return patch, nil
+}
+
+// parseBinaryHunk parses one hunk, up to and including its blank line.
+func parseBinaryHunk(r *bufio.Reader) (*BinaryHunk, error) {
+	header, err := r.ReadString('\n')
+	if header == "" && err != nil {
+		return nil, fmt.Errorf("truncated binary patch")
+	}
+	kind, sizeText, _ := strings.Cut(strings.TrimRight(header, "\r\n"), " ")
+	hunk := &BinaryHunk{Type: BinaryHunkType(kind)}
+	if hunk.Type != BinaryLiteral && hunk.Type != BinaryDelta {
+		return nil, fmt.Errorf("invalid binary hunk header: %s", strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(sizeText)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid binary hunk size: %s", strings.TrimSpace(header))
+	}
+
+	var compressed []byte
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+
+		n, ok := base85LineDecodedLength(line[0])
+		if !ok || len(line)-1 != (n+3)/4*5 {
+			return nil, fmt.Errorf("corrupt binary patch line: %s", line)
+		}
+		decoded, derr := decodeBase85(line[1:])
+		if derr != nil {
+			return nil, derr
+		}
+		compressed = append(compressed, decoded[:n]...)
+		if err != nil {
+			break
+		}
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("corrupt binary patch: %w", err)
+	}
+	hunk.Data, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt binary patch: %w", err)
+	}
+	if len(hunk.Data) != size {
+		return nil, fmt.Errorf("binary hunk size mismatch: expected %d, got %d", size, len(hunk.Data))
+	}
+	return hunk, nil
+}
+
+// Apply returns the content produced by applying the hunk to source.
+func (h BinaryHunk) Apply(source []byte) ([]byte, error) {
+	if h.Type == BinaryLiteral {
+		return h.Data, nil
+	}
+	return ApplyBinaryDelta(source, h.Data)
+}
+
+// Git's base85 alphabet
+const base85Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+
+var base85Values = func() [256]int {
+	var values [256]int
+	for i := range values {
+		values[i] = -1
+	}
+	for i := 0; i < len(base85Alphabet); i++ {
+		values[base85Alphabet[i]] = i
+	}
+	return values
+}()
+
+// encodeBase85 encodes data in groups of four bytes, padding the last group
+// with zeros.
+func encodeBase85(data []byte) string {
+	var out strings.Builder
+	for len(data) > 0 {
+		var group [4]byte
+		n := copy(group[:], data)
+		data = data[n:]
+
+		value := binary.BigEndian.Uint32(group[:])
+		var chars [5]byte
+		for i := 4; i >= 0; i-- {
+			chars[i] = base85Alphabet[value%85]
+			value /= 85
+		}
+		out.Write(chars[:])
+	}
+	return out.String()
+}
+
+// decodeBase85 reverses encodeBase85, including any padding.
+func decodeBase85(text string) ([]byte, error) {
+	if len(text)%5 != 0 {
+		return nil, fmt.Errorf("invalid base85 length %d", len(text))
+	}
+	out := make([]byte, 0, len(text)/5*4)
+	for i := 0; i < len(text); i += 5 {
+		var value uint64
+		for j := 0; j < 5; j++ {
+			digit := base85Values[text[i+j]]
+			if digit < 0 {
+				return nil, fmt.Errorf("invalid base85 character %q", text[i+j])
+			}
+			value = value*85 + uint64(digit)
+		}
+		if value > 0xffffffff {
+			return nil, fmt.Errorf("invalid base85 group %s", text[i:i+5])
+		}
+		out = binary.BigEndian.AppendUint32(out, uint32(value))
+	}
+	return out, nil
+}
+
+// base85LineLength returns the character giving the number of decoded bytes
+// on a line: A-Z for 1-26 and a-z for 27-52.
+func base85LineLength(n int) byte {
+	if n <= 26 {
+		return byte('A' + n - 1)
+	}
+	return byte('a' + n - 27)
+}
+
+func base85LineDecodedLength(c byte) (int, bool) {
+	switch {
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 1, true
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 27, true
+	}
+	return 0, false
+}
+
+// Deltas use Git's pack delta encoding: the source and target sizes as
+// varints, then instructions that either copy a range of the source or insert
+// up to 127 literal bytes.
+const (
+	deltaBlockSize  = 16
+	deltaMaxCopy    = 0x10000
+	deltaMaxLiteral = 0x7f
+)
+
+// ComputeBinaryDelta returns a delta that turns source into target. Matches
+// are found by indexing the source in fixed-size blocks, which is enough to
+// pick up content that was kept, moved or surrounded by insertions.
+func ComputeBinaryDelta(source, target []byte) []byte {
+	delta := binary.AppendUvarint(nil, uint64(len(source)))
+	delta = binary.AppendUvarint(delta, uint64(len(target)))
+
+	index := make(map[[deltaBlockSize]byte]int)
+	for offset := 0; offset+deltaBlockSize <= len(source); offset += deltaBlockSize {
+		var key [deltaBlockSize]byte
+		copy(key[:], source[offset:])
+		if _, ok := index[key]; !ok {
+			index[key] = offset
+		}
+	}
+
+	var literal []byte
+	flushLiteral := func() {
+		for len(literal) > 0 {
+			n := min(len(literal), deltaMaxLiteral)
+			delta = append(delta, byte(n))
+			delta = append(delta, literal[:n]...)
+			literal = literal[n:]
+		}
+	}
+
+	for pos := 0; pos < len(target); {
+		offset := -1
+		if pos+deltaBlockSize <= len(target) {
+			var key [deltaBlockSize]byte
+			copy(key[:], target[pos:])
+			if found, ok := index[key]; ok {
+				offset = found
+			}
+		}
+		if offset < 0 {
+			literal = append(literal, target[pos])
+			pos++
+			continue
+		}
+
+		length := deltaBlockSize
+		for offset+length < len(source) && pos+length < len(target) && source[offset+length] == target[pos+length] {
+			length++
+		}
+
+		flushLiteral()
+		pos += length
+		for length > 0 {
+			n := min(length, deltaMaxCopy)
+			delta = appendDeltaCopy(delta, offset, n)
+			offset += n
+			length -= n
+		}
+	}
+	flushLiteral()
+
+	return delta
+}
+
+// appendDeltaCopy encodes a copy instruction, omitting zero bytes of the
+// offset and size.
+func appendDeltaCopy(delta []byte, offset, size int) []byte {
+	opIndex := len(delta)
+	delta = append(delta, 0x80)
+	for i := 0; i < 4; i++ {
+		if b := byte(offset >> (8 * i)); b != 0 {
+			delta[opIndex] |= 1 << i
+			delta = append(delta, b)
+		}
+	}
+	if size != deltaMaxCopy { // A size of zero means 0x10000
+		for i := 0; i < 3; i++ {
+			if b := byte(size >> (8 * i)); b != 0 {
+				delta[opIndex] |= 0x10 << i
+				delta = append(delta, b)
+			}
+		}
+	}
+	return delta
+}
+
+// ApplyBinaryDelta applies a delta to source, checking that source has the
+// size the delta was made for.
+func ApplyBinaryDelta(source, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta)
+	sourceSize, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt delta: %w", err)
+	}
+	if sourceSize != uint64(len(source)) {
+		return nil, fmt.Errorf("delta expects %d bytes of original content, have %d", sourceSize, len(source))
+	}
+	targetSize, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt delta: %w", err)
+	}
+
+	target := make([]byte, 0, targetSize)
+	for {
+		op, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+
+		switch {
+		case op&0x80 != 0:
+			var offset, size int
+			for i := 0; i < 4; i++ {
+				if op&(1<<i) != 0 {
+					b, err := r.ReadByte()
+					if err != nil {
+						return nil, fmt.Errorf("corrupt delta: truncated copy")
+					}
+					offset |= int(b) << (8 * i)
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					b, err := r.ReadByte()
+					if err != nil {
+						return nil, fmt.Errorf("corrupt delta: truncated copy")
+					}
+					size |= int(b) << (8 * i)
+				}
+			}
+			if size == 0 {
+				size = deltaMaxCopy
+			}
+			if offset+size > len(source) {
+				return nil, fmt.Errorf("corrupt delta: copy outside of original content")
+			}
+			target = append(target, source[offset:offset+size]...)
+		case op != 0:
+			start := len(target)
+			target = append(target, make([]byte, op)...)
+			if _, err := io.ReadFull(r, target[start:]); err != nil {
+				return nil, fmt.Errorf("corrupt delta: truncated insert")
+			}
+		default:
+			return nil, fmt.Errorf("corrupt delta: reserved instruction")
+		}
+	}
+
+	if uint64(len(target)) != targetSize {
+		return nil, fmt.Errorf("corrupt delta: expected %d bytes, produced %d", targetSize, len(target))
+	}
+	return target, nil
+}
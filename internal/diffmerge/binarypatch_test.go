@@ -0,0 +1,126 @@
+package diffmerge
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func randomBytes(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func TestBinaryPatchRoundTrip(t *testing.T) {
+	base := randomBytes(1, 20000)
+	edited := append(append(append([]byte{}, base[:5000]...), []byte("\x00inserted\x00")...), base[5100:]...)
+
+	tests := []struct {
+		name     string
+		old, new []byte
+		forward  BinaryHunkType
+	}{
+		{"new file", nil, []byte("\x00\x01\x02binary"), BinaryLiteral},
+		{"deleted file", []byte("\x00\x01\x02binary"), nil, BinaryLiteral},
+		{"small edit", base, edited, BinaryDelta},
+		{"rewrite", []byte("\x00short"), randomBytes(2, 300), BinaryLiteral},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteBinaryPatch(&buf, tt.old, tt.new); err != nil {
+				t.Fatalf("WriteBinaryPatch failed: %v", err)
+			}
+
+			r := bufio.NewReader(&buf)
+			header, _ := r.ReadString('\n')
+			if header != "GIT binary patch\n" {
+				t.Fatalf("Unexpected header %q", header)
+			}
+
+			patch, err := ParseBinaryPatch(r)
+			if err != nil {
+				t.Fatalf("ParseBinaryPatch failed: %v", err)
+			}
+			if patch.Forward.Type != tt.forward {
+				t.Errorf("Forward hunk is %s, want %s", patch.Forward.Type, tt.forward)
+			}
+
+			got, err := patch.Forward.Apply(tt.old)
+			if err != nil {
+				t.Fatalf("Forward apply failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.new) {
+				t.Error("Forward hunk did not produce the new content")
+			}
+
+			back, err := patch.Reverse.Apply(tt.new)
+			if err != nil {
+				t.Fatalf("Reverse apply failed: %v", err)
+			}
+			if !bytes.Equal(back, tt.old) {
+				t.Error("Reverse hunk did not restore the old content")
+			}
+		})
+	}
+}
+
+func TestParseBinaryPatchStopsAtNextDiff(t *testing.T) {
+	var buf bytes.Buffer
+	WriteBinaryPatch(&buf, []byte("\x00old"), []byte("\x00new"))
+	buf.WriteString("diff --git a/next b/next\n")
+
+	r := bufio.NewReader(&buf)
+	r.ReadString('\n')
+	if _, err := ParseBinaryPatch(r); err != nil {
+		t.Fatalf("ParseBinaryPatch failed: %v", err)
+	}
+	rest, _ := r.ReadString('\n')
+	if rest != "diff --git a/next b/next\n" {
+		t.Errorf("Parser consumed the next diff, left %q", rest)
+	}
+}
+
+func TestApplyBinaryDeltaWrongSource(t *testing.T) {
+	source := randomBytes(3, 4096)
+	target := append(append([]byte{}, source...), []byte("tail")...)
+	delta := ComputeBinaryDelta(source, target)
+
+	if _, err := ApplyBinaryDelta(source[:4000], delta); err == nil {
+		t.Error("Expected error applying a delta to content of the wrong size")
+	}
+}
+
+func TestBase85(t *testing.T) {
+	for _, n := range []int{1, 4, 5, 52} {
+		data := randomBytes(int64(n), n)
+		decoded, err := decodeBase85(encodeBase85(data))
+		if err != nil {
+			t.Fatalf("decodeBase85 failed: %v", err)
+		}
+		if !bytes.Equal(decoded[:n], data) {
+			t.Errorf("Round trip of %d bytes failed", n)
+		}
+	}
+
+	if _, err := decodeBase85("ab\"de"); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("Expected invalid character error, got %v", err)
+	}
+}
+
+func TestGitBlobID(t *testing.T) {
+	// Known IDs from 'git hash-object'
+	if got := GitBlobID([]byte{}, true); got != "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391" {
+		t.Errorf("Empty blob ID = %s", got)
+	}
+	if got := GitBlobID([]byte("hello\n"), true); got != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("Blob ID = %s", got)
+	}
+	if got := GitBlobID(nil, false); got != strings.Repeat("0", 40) {
+		t.Errorf("Missing blob ID = %s", got)
+	}
+}
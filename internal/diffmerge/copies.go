@@ -0,0 +1,112 @@
+package diffmerge
+
+import (
+	"sort"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// CopyDetection represents an added file detected as a copy of a file that
+// is still present.
+type CopyDetection struct {
+	OldPath    string // File the copy was made from
+	NewPath    string
+	Similarity float64 // 0.0 to 1.0, where 1.0 is exact match
+}
+
+// DetectCopies detects added files that are copies of base files, base
+// being every file on the old side of diff. Only files that are still
+// present are sources: removed files are left to rename detection, and the
+// added files of renames are not copies. Each added file is a copy of at
+// most one source, the most similar one, while a source may be copied any
+// number of times. Similarity is scored and pruned as in DetectRenames, and
+// copies below threshold are dropped. The copies are sorted by new path.
+//
+// Comparing added files with every base file is more expensive than rename
+// detection. RenameLimit bounds it the same way: with too many added files
+// and sources left after exact copies are paired, only exact copies are
+// detected and RenameLimitNeeded is raised to the limit it needed.
+func (a *Analyzer) DetectCopies(diff *WorkspaceDiff, base []wsindex.FileMetadata, renames []RenameDetection, threshold float64) []CopyDetection {
+	renamed := make(map[string]bool, len(renames))
+	for _, rename := range renames {
+		renamed[rename.NewPath] = true
+	}
+	removed := make(map[string]bool)
+	var added []FileChange
+	for _, change := range diff.FileChanges {
+		switch change.Type {
+		case Added:
+			if change.NewFile != nil && !renamed[change.Path] {
+				added = append(added, change)
+			}
+		case Removed:
+			removed[change.Path] = true
+		}
+	}
+
+	var sources []wsindex.FileMetadata
+	for _, file := range base {
+		if !removed[file.Path] {
+			sources = append(sources, file)
+		}
+	}
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Path < sources[j].Path
+	})
+	if len(added) == 0 || len(sources) == 0 {
+		return nil
+	}
+
+	var copies []CopyDetection
+	copied := make(map[int]bool)
+
+	// Exact copies first, earlier sources winning ties
+	sourceByHash := make(map[cas.Hash]int)
+	for i := len(sources) - 1; i >= 0; i-- {
+		sourceByHash[sources[i].FileRef.Hash] = i
+	}
+	var rest []int
+	for j, ad := range added {
+		if i, ok := sourceByHash[ad.NewFile.FileRef.Hash]; ok {
+			copied[j] = true
+			copies = append(copies, CopyDetection{OldPath: sources[i].Path, NewPath: ad.Path, Similarity: 1.0})
+			continue
+		}
+		rest = append(rest, j)
+	}
+
+	if threshold >= 1.0 || a.CAS == nil || len(rest) == 0 {
+		return sortCopies(copies)
+	}
+	if limit := a.RenameLimit; limit > 0 && len(sources)*len(rest) > limit*limit {
+		a.RenameLimitNeeded = max(a.RenameLimitNeeded, max(len(sources), len(rest)))
+		return sortCopies(copies)
+	}
+
+	oldRefs := make([]filechunk.NodeRef, len(sources))
+	for i, source := range sources {
+		oldRefs[i] = source.FileRef
+	}
+	newRefs := make([]filechunk.NodeRef, len(rest))
+	for y, j := range rest {
+		newRefs[y] = added[j].NewFile.FileRef
+	}
+	for _, p := range a.scorePairs(oldRefs, newRefs, threshold) {
+		j := rest[p.new]
+		if !copied[j] {
+			copied[j] = true
+			copies = append(copies, CopyDetection{OldPath: sources[p.old].Path, NewPath: added[j].Path, Similarity: p.similarity})
+		}
+	}
+	return sortCopies(copies)
+}
+
+// sortCopies sorts copies by new path
+func sortCopies(copies []CopyDetection) []CopyDetection {
+	sort.Slice(copies, func(i, j int) bool {
+		return copies[i].NewPath < copies[j].NewPath
+	})
+	return copies
+}
@@ -18,6 +18,7 @@ import (
 	"sort"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
 	"github.com/javanhut/Ivaldi-vcs/internal/hamtdir"
 	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
 )
@@ -56,6 +57,10 @@ type WorkspaceDiff struct {
 // Differ computes differences between storage structures.
 type Differ struct {
 	CAS cas.CAS
+	// IgnoreModTime and IgnoreMode compare workspace files by content
+	// only; see wsindex.Loader
+	IgnoreModTime bool
+	IgnoreMode    bool
 }
 
 // NewDiffer creates a new Differ with the given CAS.
@@ -65,7 +70,7 @@ func NewDiffer(casStore cas.CAS) *Differ {
 
 // DiffWorkspaces computes differences between two workspace indexes.
 func (d *Differ) DiffWorkspaces(oldIndex, newIndex wsindex.IndexRef) (*WorkspaceDiff, error) {
-	loader := wsindex.NewLoader(d.CAS)
+	loader := &wsindex.Loader{CAS: d.CAS, IgnoreModTime: d.IgnoreModTime, IgnoreMode: d.IgnoreMode}
 	
 	wsIndexDiff, err := loader.Diff(oldIndex, newIndex)
 	if err != nil {
@@ -148,7 +153,16 @@ func (d *Differ) DiffDirectories(oldDir, newDir hamtdir.DirRef) ([]DirectoryChan
 		}
 
 		if oldEntry, exists := oldMap[newEntry.Name]; exists {
-			if oldEntry.Type == hamtdir.DirEntry && oldEntry.Dir.Hash != newEntry.Dir.Hash {
+			if oldEntry.Type != hamtdir.DirEntry {
+				continue
+			}
+			// Compare entries, not hashes: trees built before internal
+			// nodes carried child sizes hash differently
+			same, err := loader.Equal(*oldEntry.Dir, *newEntry.Dir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compare directory %s: %w", newEntry.Name, err)
+			}
+			if !same {
 				// Directory modified
 				changes = append(changes, DirectoryChange{
 					Type:   Modified,
@@ -209,13 +223,21 @@ type Conflict struct {
 // MergeResult represents the result of a merge operation.
 type MergeResult struct {
 	Success    bool
-	MergedIndex *wsindex.IndexRef // Result of merge (if successful)
+	MergedIndex *wsindex.IndexRef // Result of merge; with conflicts, only the files merged cleanly
 	Conflicts  []Conflict         // Conflicts that need resolution
 }
 
 // Merger performs three-way merges of storage structures.
 type Merger struct {
 	CAS cas.CAS
+
+	// Chunking selects the chunk profile of merged files; nil chooses by
+	// extension alone.
+	Chunking *filechunk.ProfileRules
+
+	// BigFileThreshold is the size above which files are merged whole,
+	// without reading them; 0 means no file is.
+	BigFileThreshold int64
 }
 
 // NewMerger creates a new Merger with the given CAS.
@@ -337,6 +359,16 @@ func (m *Merger) MergeWorkspacesWithStrategy(base, left, right wsindex.IndexRef,
 		leftFile := leftFiles[path]
 		rightFile := rightFiles[path]
 
+		if m.isBigFile(baseFile, leftFile, rightFile) {
+			conflict, mergedFile := m.mergeBigFile(strategy, path, baseFile, leftFile, rightFile)
+			if conflict != nil {
+				conflicts = append(conflicts, *conflict)
+			} else if mergedFile != nil {
+				mergedFiles = append(mergedFiles, *mergedFile)
+			}
+			continue
+		}
+
 		// Use strategy resolver
 		result, err := resolver.Resolve(strategy, path, baseFile, leftFile, rightFile)
 		if err != nil {
@@ -347,7 +379,7 @@ func (m *Merger) MergeWorkspacesWithStrategy(base, left, right wsindex.IndexRef,
 			// Successfully merged - build file metadata
 			if len(result.MergedChunks) > 0 {
 				// Rebuild file from merged chunks
-				fileRef, err := BuildMergedFile(m.CAS, result.MergedChunks, result.MergedSize)
+				fileRef, err := BuildMergedFile(m.CAS, result.MergedChunks, result.MergedSize, m.Chunking.Params(path))
 				if err != nil {
 					return nil, fmt.Errorf("failed to build merged file %s: %w", path, err)
 				}
@@ -389,14 +421,7 @@ func (m *Merger) MergeWorkspacesWithStrategy(base, left, right wsindex.IndexRef,
 		}
 	}
 
-	if len(conflicts) > 0 {
-		return &MergeResult{
-			Success:   false,
-			Conflicts: conflicts,
-		}, nil
-	}
-
-	// Build merged index
+	// Build merged index, without the conflicted files if there are any
 	builder := wsindex.NewBuilder(m.CAS)
 	mergedIndex, err := builder.Build(mergedFiles)
 	if err != nil {
@@ -404,11 +429,38 @@ func (m *Merger) MergeWorkspacesWithStrategy(base, left, right wsindex.IndexRef,
 	}
 
 	return &MergeResult{
-		Success:     true,
+		Success:     len(conflicts) == 0,
 		MergedIndex: &mergedIndex,
+		Conflicts:   conflicts,
 	}, nil
 }
 
+// isBigFile reports whether any version of a file is above the big file
+// threshold.
+func (m *Merger) isBigFile(files ...*wsindex.FileMetadata) bool {
+	for _, file := range files {
+		if file != nil && m.BigFileThreshold > 0 && file.FileRef.Size > m.BigFileThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeBigFile merges a file above the big file threshold without reading
+// it. Ours, theirs and base take their version whole; otherwise the side
+// that changed is taken, and a file changed on both sides conflicts.
+func (m *Merger) mergeBigFile(strategy StrategyType, path string, base, left, right *wsindex.FileMetadata) (*Conflict, *wsindex.FileMetadata) {
+	switch strategy {
+	case StrategyOurs:
+		return nil, left
+	case StrategyTheirs:
+		return nil, right
+	case StrategyBase:
+		return nil, base
+	}
+	return m.mergeFile(path, base, left, right)
+}
+
 // getFilesMap converts a workspace index to a map for easier processing.
 func (m *Merger) getFilesMap(loader *wsindex.Loader, index wsindex.IndexRef) (map[string]*wsindex.FileMetadata, error) {
 	if index.Count == 0 {
@@ -611,6 +663,14 @@ func (p *Patcher) ApplyPatch(index wsindex.IndexRef, patch *Patch) (wsindex.Inde
 // Analyzer provides higher-level analysis of diffs and merges.
 type Analyzer struct {
 	CAS cas.CAS
+	// RenameLimit bounds the work of inexact rename detection: when the
+	// removed files times the added files left after exact renames are
+	// paired exceed RenameLimit squared, only exact renames are detected.
+	// Zero means no limit.
+	RenameLimit int
+	// RenameLimitNeeded is set by DetectRenames when it skipped inexact
+	// detection, to the smallest RenameLimit that would have let it run
+	RenameLimitNeeded int
 }
 
 // NewAnalyzer creates a new Analyzer with the given CAS.
@@ -701,58 +761,3 @@ func (a *Analyzer) GetConflictSummary(conflicts []Conflict) map[string]interface
 
 	return summary
 }
-
-// DetectRenames detects if files were renamed between two workspace states.
-func (a *Analyzer) DetectRenames(diff *WorkspaceDiff, threshold float64) []RenameDetection {
-	var renames []RenameDetection
-
-	// Group changes by type
-	var added, removed []FileChange
-	for _, change := range diff.FileChanges {
-		switch change.Type {
-		case Added:
-			added = append(added, change)
-		case Removed:
-			removed = append(removed, change)
-		}
-	}
-
-	// Compare each removed file with each added file
-	for _, removedFile := range removed {
-		if removedFile.OldFile == nil {
-			continue
-		}
-
-		for _, addedFile := range added {
-			if addedFile.NewFile == nil {
-				continue
-			}
-
-			// Check if content is similar (same hash indicates exact match)
-			if removedFile.OldFile.FileRef.Hash == addedFile.NewFile.FileRef.Hash {
-				renames = append(renames, RenameDetection{
-					OldPath:    removedFile.Path,
-					NewPath:    addedFile.Path,
-					Similarity: 1.0, // Exact match
-				})
-			}
-		}
-	}
-
-	// Filter by threshold
-	var filtered []RenameDetection
-	for _, rename := range renames {
-		if rename.Similarity >= threshold {
-			filtered = append(filtered, rename)
-		}
-	}
-
-	return filtered
-}
-
-// RenameDetection represents a detected file rename.
-type RenameDetection struct {
-	OldPath    string
-	NewPath    string
-	Similarity float64 // 0.0 to 1.0, where 1.0 is exact match
-}
\ No newline at end of file
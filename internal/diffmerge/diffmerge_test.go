@@ -367,6 +367,65 @@ func TestMergeConflicts(t *testing.T) {
 	}
 }
 
+func TestMergeBigFiles(t *testing.T) {
+	// The content of these files is never stored, so merging them fails
+	// if anything tries to read it
+	casStore := cas.NewMemoryCAS()
+	merger := NewMerger(casStore)
+	merger.BigFileThreshold = 8
+	wsBuilder := wsindex.NewBuilder(casStore)
+
+	build := func(files ...wsindex.FileMetadata) wsindex.IndexRef {
+		t.Helper()
+		index, err := wsBuilder.Build(files)
+		if err != nil {
+			t.Fatalf("Build workspace failed: %v", err)
+		}
+		return index
+	}
+	base := build(
+		createTestFileMetadata("left.bin", "base content"),
+		createTestFileMetadata("both.bin", "base content"),
+	)
+	left := build(
+		createTestFileMetadata("left.bin", "left content"),
+		createTestFileMetadata("both.bin", "left content"),
+	)
+	right := build(
+		createTestFileMetadata("left.bin", "base content"),
+		createTestFileMetadata("both.bin", "right content"),
+	)
+
+	result, err := merger.MergeWorkspacesWithStrategy(base, left, right, StrategyAuto)
+	if err != nil {
+		t.Fatalf("MergeWorkspacesWithStrategy failed: %v", err)
+	}
+	if result.Success || len(result.Conflicts) != 1 || result.Conflicts[0].Path != "both.bin" {
+		t.Fatalf("Expected a single conflict on both.bin, got %+v", result.Conflicts)
+	}
+
+	result, err = merger.MergeWorkspacesWithStrategy(base, left, right, StrategyTheirs)
+	if err != nil {
+		t.Fatalf("MergeWorkspacesWithStrategy failed: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("Expected theirs to resolve every file, got %d conflicts", len(result.Conflicts))
+	}
+	files, err := wsindex.NewLoader(casStore).ListAll(*result.MergedIndex)
+	if err != nil {
+		t.Fatalf("ListAll failed: %v", err)
+	}
+	want := map[string]string{"both.bin": "right content", "left.bin": "base content"}
+	for _, file := range files {
+		if file.FileRef.Hash != cas.SumB3([]byte(want[file.Path])) {
+			t.Errorf("Expected %s to be taken whole from the right side", file.Path)
+		}
+	}
+	if len(files) != len(want) {
+		t.Errorf("Expected %d merged files, got %d", len(want), len(files))
+	}
+}
+
 func TestApplyPatch(t *testing.T) {
 	casStore := cas.NewMemoryCAS()
 	patcher := NewPatcher(casStore)
@@ -0,0 +1,599 @@
+package diffmerge
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LineOpType represents the kind of a line-level edit.
+type LineOpType uint8
+
+const (
+	LineEqual LineOpType = iota
+	LineInsert
+	LineDelete
+)
+
+// LineOp is a single line of an edit script. Text keeps its trailing
+// newline, so a final line without one is represented faithfully.
+type LineOp struct {
+	Type LineOpType
+	Text string
+}
+
+// Hunk is a contiguous group of line edits with surrounding context.
+// Starts are 1-based like unified diff headers.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Ops      []LineOp
+}
+
+// noNewlineMarker is written after a line that lacks a trailing newline.
+const noNewlineMarker = "\\ No newline at end of file"
+
+// SplitLines splits content into lines, keeping each line's trailing newline.
+func SplitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	var lines []string
+	s := string(content)
+	for len(s) > 0 {
+		idx := strings.IndexByte(s, '\n')
+		if idx < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:idx+1])
+		s = s[idx+1:]
+	}
+	return lines
+}
+
+// DiffLines computes a minimal edit script turning a into b using Myers' algorithm.
+func DiffLines(a, b []string) []LineOp {
+	// Strip the common prefix and suffix; they never take part in the edit
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []LineOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, LineOp{Type: LineEqual, Text: line})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, LineOp{Type: LineEqual, Text: line})
+	}
+	return ops
+}
+
+// myersDiff computes the edit script with the linear-space refinement of
+// Myers' algorithm: the middle snake of a shortest edit script splits it in
+// two halves that are diffed in turn, so memory stays proportional to the
+// input instead of growing with the number of edits.
+func myersDiff(a, b []string) []LineOp {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	size := 2*((len(a)+len(b)+1)/2) + 3
+	md := &myersDiffer{vf: make([]int, size), vb: make([]int, size)}
+	md.diff(a, b)
+
+	// Within each run of changes deletions come first, as Git shows them
+	ops := md.ops
+	for i := 0; i < len(ops); {
+		if ops[i].Type == LineEqual {
+			i++
+			continue
+		}
+		j := i
+		for j < len(ops) && ops[j].Type != LineEqual {
+			j++
+		}
+		run := ops[i:j]
+		sort.SliceStable(run, func(p, q int) bool {
+			return run[p].Type == LineDelete && run[q].Type == LineInsert
+		})
+		i = j
+	}
+	return ops
+}
+
+// myersDiffer holds the edit script being built and the forward and
+// backward frontiers, which every middle snake search reuses.
+type myersDiffer struct {
+	ops    []LineOp
+	vf, vb []int
+}
+
+// diff appends the edit script turning a into b.
+func (md *myersDiffer) diff(a, b []string) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	md.equal(a[:prefix])
+	tail := a[len(a)-suffix:]
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			md.ops = append(md.ops, LineOp{Type: LineInsert, Text: line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			md.ops = append(md.ops, LineOp{Type: LineDelete, Text: line})
+		}
+	default:
+		x, y, u, v := md.middleSnake(a, b)
+		md.diff(a[:x], b[:y])
+		md.equal(a[x:u])
+		md.diff(a[u:], b[v:])
+	}
+	md.equal(tail)
+}
+
+// equal appends lines common to both sides.
+func (md *myersDiffer) equal(lines []string) {
+	for _, line := range lines {
+		md.ops = append(md.ops, LineOp{Type: LineEqual, Text: line})
+	}
+}
+
+// middleSnake finds the snake in the middle of a shortest edit script from
+// a to b, running the search forward from the start and backward from the
+// end until the two meet. The snake runs from (x, y) to (u, v). a and b
+// must differ in their first and last lines, so that both halves are
+// shorter scripts.
+func (md *myersDiffer) middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	vf, vb := md.vf, md.vb
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	for d := 0; d <= maxD; d++ {
+		// Forward paths, with x counted from the start of a
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u++
+				v++
+			}
+			vf[offset+k] = u
+
+			// Diagonal k is diagonal delta-k of the backward search
+			if odd && delta-k >= -(d-1) && delta-k <= d-1 && u+vb[offset+delta-k] >= n {
+				return x, y, u, v
+			}
+		}
+
+		// Backward paths, with x counted from the end of a
+		for k := -d; k <= d; k += 2 {
+			var bx int
+			if k == -d || (k != d && vb[offset+k-1] < vb[offset+k+1]) {
+				bx = vb[offset+k+1]
+			} else {
+				bx = vb[offset+k-1] + 1
+			}
+			by := bx - k
+			ex, ey := bx, by
+			for ex < n && ey < m && a[n-1-ex] == b[m-1-ey] {
+				ex++
+				ey++
+			}
+			vb[offset+k] = ex
+
+			if !odd && delta-k >= -d && delta-k <= d && ex+vf[offset+delta-k] >= n {
+				return n - ex, m - ey, n - bx, m - by
+			}
+		}
+	}
+
+	// Unreachable: the searches meet by the time d reaches maxD
+	return 0, 0, 0, 0
+}
+
+// DefaultContext is the number of unchanged lines shown around each change
+// unless a diff asks for another number, as in Git
+const DefaultContext = 3
+
+// HunkOptions sets how much unchanged text a unified diff shows. Negative
+// numbers count as zero.
+type HunkOptions struct {
+	Context          int // Unchanged lines shown before and after each change
+	InterHunkContext int // Join hunks whose context lies at most this many lines apart
+}
+
+// MakeHunks groups an edit script into hunks with the given lines of context.
+func MakeHunks(ops []LineOp, context int) []Hunk {
+	return makeHunks(ops, HunkOptions{Context: context}, nil)
+}
+
+// makeHunks groups an edit script into hunks. Changes for which ignore
+// returns true are treated like context when deciding where hunks start and
+// end, but are kept as changes inside a hunk.
+func makeHunks(ops []LineOp, opts HunkOptions, ignore func(LineOp) bool) []Hunk {
+	var hunks []Hunk
+	context := max(opts.Context, 0)
+	join := 2*context + max(opts.InterHunkContext, 0)
+
+	changed := func(op LineOp) bool {
+		return op.Type != LineEqual && (ignore == nil || !ignore(op))
+	}
+
+	// Track positions (0-based) in old and new for every op
+	oldPos := make([]int, len(ops))
+	newPos := make([]int, len(ops))
+	o, n := 0, 0
+	for i, op := range ops {
+		oldPos[i], newPos[i] = o, n
+		if op.Type != LineInsert {
+			o++
+		}
+		if op.Type != LineDelete {
+			n++
+		}
+	}
+
+	i := 0
+	for i < len(ops) {
+		if !changed(ops[i]) {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk while changes are within join lines of each other
+		end := i
+		for end < len(ops) {
+			if changed(ops[end]) {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && !changed(ops[run]) {
+				run++
+			}
+			if run < len(ops) && run-end <= join {
+				end = run
+				continue
+			}
+			end += context
+			if end > run {
+				end = run
+			}
+			break
+		}
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		hunk := Hunk{Ops: append([]LineOp(nil), ops[start:end]...)}
+		for _, op := range hunk.Ops {
+			if op.Type != LineInsert {
+				hunk.OldLines++
+			}
+			if op.Type != LineDelete {
+				hunk.NewLines++
+			}
+		}
+		hunk.OldStart = oldPos[start]
+		if hunk.OldLines > 0 {
+			hunk.OldStart++
+		}
+		hunk.NewStart = newPos[start]
+		if hunk.NewLines > 0 {
+			hunk.NewStart++
+		}
+
+		hunks = append(hunks, hunk)
+		i = end
+	}
+
+	return hunks
+}
+
+// Header returns the unified diff header line for the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", formatRange(h.OldStart, h.OldLines), formatRange(h.NewStart, h.NewLines))
+}
+
+func formatRange(start, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// WriteUnifiedHunks writes hunks in unified diff format.
+func WriteUnifiedHunks(w io.Writer, hunks []Hunk) error {
+	for _, hunk := range hunks {
+		if _, err := fmt.Fprintln(w, hunk.Header()); err != nil {
+			return err
+		}
+		for _, op := range hunk.Ops {
+			prefix := " "
+			switch op.Type {
+			case LineInsert:
+				prefix = "+"
+			case LineDelete:
+				prefix = "-"
+			}
+
+			text := op.Text
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n" + noNewlineMarker + "\n"
+			}
+			if _, err := io.WriteString(w, prefix+text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ParseUnifiedHunks parses the hunks of a single file diff. Parsing stops at
+// the first line that is not part of a hunk; that line is returned so the
+// caller can continue reading the surrounding patch.
+func ParseUnifiedHunks(r *bufio.Reader) ([]Hunk, string, error) {
+	var hunks []Hunk
+
+	line, err := r.ReadString('\n')
+	for {
+		if line == "" && err != nil {
+			if err == io.EOF {
+				return hunks, "", nil
+			}
+			return nil, "", err
+		}
+
+		if !strings.HasPrefix(line, "@@ ") {
+			return hunks, line, nil
+		}
+
+		hunk, perr := parseHunkHeader(strings.TrimRight(line, "\r\n"))
+		if perr != nil {
+			return nil, "", perr
+		}
+
+		// Read exactly the number of lines announced by the header
+		oldSeen, newSeen := 0, 0
+		for oldSeen < hunk.OldLines || newSeen < hunk.NewLines {
+			line, err = r.ReadString('\n')
+			if line == "" {
+				return nil, "", fmt.Errorf("truncated hunk %s", hunk.Header())
+			}
+			if line == "\n" {
+				// Some mailers strip the space of empty context lines
+				line = " \n"
+			}
+			if strings.HasPrefix(line, "\\") && len(hunk.Ops) > 0 {
+				// The previous line has no trailing newline
+				last := &hunk.Ops[len(hunk.Ops)-1]
+				last.Text = strings.TrimSuffix(last.Text, "\n")
+				continue
+			}
+
+			op := LineOp{Text: line[1:]}
+			switch line[0] {
+			case ' ':
+				op.Type = LineEqual
+				oldSeen++
+				newSeen++
+			case '-':
+				op.Type = LineDelete
+				oldSeen++
+			case '+':
+				op.Type = LineInsert
+				newSeen++
+			default:
+				return nil, "", fmt.Errorf("invalid line in hunk %s: %q", hunk.Header(), strings.TrimRight(line, "\n"))
+			}
+			hunk.Ops = append(hunk.Ops, op)
+		}
+
+		line, err = r.ReadString('\n')
+		if strings.HasPrefix(line, "\\") {
+			// The previous line has no trailing newline
+			last := &hunk.Ops[len(hunk.Ops)-1]
+			last.Text = strings.TrimSuffix(last.Text, "\n")
+			line, err = r.ReadString('\n')
+		}
+
+		hunks = append(hunks, hunk)
+	}
+}
+
+// parseHunkHeader parses "@@ -a,b +c,d @@".
+func parseHunkHeader(header string) (Hunk, error) {
+	var hunk Hunk
+
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" {
+		return hunk, fmt.Errorf("invalid hunk header: %s", header)
+	}
+
+	var err error
+	hunk.OldStart, hunk.OldLines, err = parseRange(strings.TrimPrefix(fields[1], "-"))
+	if err != nil {
+		return hunk, fmt.Errorf("invalid hunk header %s: %w", header, err)
+	}
+	hunk.NewStart, hunk.NewLines, err = parseRange(strings.TrimPrefix(fields[2], "+"))
+	if err != nil {
+		return hunk, fmt.Errorf("invalid hunk header %s: %w", header, err)
+	}
+
+	return hunk, nil
+}
+
+func parseRange(s string) (int, int, error) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	count := 1
+	if hasCount {
+		count, err = strconv.Atoi(countStr)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// Reverse returns the hunk that undoes h, turning its new side back into
+// its old side. Within each run of changes the deleted lines come first, as
+// in the hunks MakeHunks builds.
+func (h Hunk) Reverse() Hunk {
+	reversed := Hunk{OldStart: h.NewStart, OldLines: h.NewLines, NewStart: h.OldStart, NewLines: h.OldLines}
+	var inserts []LineOp
+	for _, op := range h.Ops {
+		switch op.Type {
+		case LineInsert:
+			reversed.Ops = append(reversed.Ops, LineOp{Type: LineDelete, Text: op.Text})
+		case LineDelete:
+			inserts = append(inserts, LineOp{Type: LineInsert, Text: op.Text})
+		default:
+			reversed.Ops = append(reversed.Ops, inserts...)
+			reversed.Ops = append(reversed.Ops, op)
+			inserts = nil
+		}
+	}
+	reversed.Ops = append(reversed.Ops, inserts...)
+	return reversed
+}
+
+// ApplyHunks applies hunks to lines. Each hunk is first tried at the position
+// in its header and then at increasing offsets, like patch(1) without fuzz.
+// The indexes of hunks that could not be placed are returned; those hunks are
+// skipped and the remaining hunks are still applied.
+func ApplyHunks(lines []string, hunks []Hunk) ([]string, []int) {
+	return ApplyHunksFuzz(lines, hunks, 0)
+}
+
+// ApplyHunksFuzz applies hunks like ApplyHunks, but a hunk that cannot be
+// placed is tried again with up to fuzz lines of context ignored at each of
+// its ends, like the fuzz factor of patch(1). The ignored context lines are
+// left as they are.
+func ApplyHunksFuzz(lines []string, hunks []Hunk, fuzz int) ([]string, []int) {
+	var result []string
+	var failed []int
+	pos := 0   // next unconsumed line in lines
+	delta := 0 // offset between header positions and actual positions
+
+	for i, hunk := range hunks {
+		at, skipped := -1, 0
+		var expected, replacement []string
+		for f, tried := 0, -1; f <= fuzz && at < 0; f++ {
+			var ops []LineOp
+			ops, skipped = trimContext(hunk.Ops, f)
+			if len(ops) == tried {
+				break // No context left to ignore
+			}
+			tried = len(ops)
+
+			expected, replacement = nil, nil
+			for _, op := range ops {
+				if op.Type != LineInsert {
+					expected = append(expected, op.Text)
+				}
+				if op.Type != LineDelete {
+					replacement = append(replacement, op.Text)
+				}
+			}
+
+			want := hunk.OldStart - 1 + skipped + delta
+			if hunk.OldLines == 0 {
+				want = hunk.OldStart + delta
+			}
+			at = findHunk(lines, expected, want, pos)
+		}
+		if at < 0 {
+			failed = append(failed, i)
+			continue
+		}
+
+		result = append(result, lines[pos:at]...)
+		result = append(result, replacement...)
+		pos = at + len(expected)
+		delta = at - skipped - (hunk.OldStart - 1)
+		if hunk.OldLines == 0 {
+			delta = at - hunk.OldStart
+		}
+	}
+
+	result = append(result, lines[pos:]...)
+	return result, failed
+}
+
+// trimContext drops up to n context lines from each end of ops. It returns
+// the remaining ops and the number dropped from the start.
+func trimContext(ops []LineOp, n int) ([]LineOp, int) {
+	start, end := 0, len(ops)
+	for start < n && start < end && ops[start].Type == LineEqual {
+		start++
+	}
+	for len(ops)-end < n && end > start && ops[end-1].Type == LineEqual {
+		end--
+	}
+	return ops[start:end], start
+}
+
+// findHunk locates expected in lines at or after minPos, searching outward from want.
+func findHunk(lines, expected []string, want, minPos int) int {
+	matches := func(at int) bool {
+		if at < minPos || at+len(expected) > len(lines) {
+			return false
+		}
+		for j, line := range expected {
+			if lines[at+j] != line {
+				return false
+			}
+		}
+		return true
+	}
+
+	for off := 0; off <= len(lines); off++ {
+		if matches(want + off) {
+			return want + off
+		}
+		if off > 0 && matches(want-off) {
+			return want - off
+		}
+	}
+	return -1
+}
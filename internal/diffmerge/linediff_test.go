@@ -0,0 +1,258 @@
+package diffmerge
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func makeLines(n int, edit func(i int) string) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		line := fmt.Sprintf("line %d", i)
+		if edit != nil {
+			line = edit(i)
+		}
+		if line != "" {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+func roundTrip(t *testing.T, oldContent, newContent string, context int) {
+	t.Helper()
+
+	oldLines := SplitLines([]byte(oldContent))
+	newLines := SplitLines([]byte(newContent))
+
+	hunks := MakeHunks(DiffLines(oldLines, newLines), context)
+
+	var buf bytes.Buffer
+	if err := WriteUnifiedHunks(&buf, hunks); err != nil {
+		t.Fatalf("WriteUnifiedHunks failed: %v", err)
+	}
+
+	parsed, rest, err := ParseUnifiedHunks(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("ParseUnifiedHunks failed: %v\n%s", err, buf.String())
+	}
+	if rest != "" {
+		t.Fatalf("Unexpected trailing line %q", rest)
+	}
+	if len(parsed) != len(hunks) {
+		t.Fatalf("Expected %d hunks, parsed %d", len(hunks), len(parsed))
+	}
+
+	applied, failed := ApplyHunks(oldLines, parsed)
+	if len(failed) != 0 {
+		t.Fatalf("Hunks failed to apply: %v", failed)
+	}
+	if got := strings.Join(applied, ""); got != newContent {
+		t.Errorf("Round trip mismatch:\nwant %q\ngot  %q", newContent, got)
+	}
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	a := SplitLines([]byte("a\nb\nc\nd\n"))
+	b := SplitLines([]byte("a\nx\nc\nd\ne\n"))
+
+	inserts, deletes := 0, 0
+	for _, op := range DiffLines(a, b) {
+		switch op.Type {
+		case LineInsert:
+			inserts++
+		case LineDelete:
+			deletes++
+		}
+	}
+
+	if inserts != 2 || deletes != 1 {
+		t.Errorf("Expected 2 inserts and 1 delete, got %d and %d", inserts, deletes)
+	}
+}
+
+func TestUnifiedRoundTrip(t *testing.T) {
+	base := makeLines(60, nil)
+
+	cases := map[string]string{
+		"modify middle": makeLines(60, func(i int) string {
+			if i == 30 {
+				return "changed"
+			}
+			return fmt.Sprintf("line %d", i)
+		}),
+		"scattered edits": makeLines(60, func(i int) string {
+			switch {
+			case i%13 == 0:
+				return ""
+			case i%17 == 0:
+				return fmt.Sprintf("line %d\nextra %d", i, i)
+			}
+			return fmt.Sprintf("line %d", i)
+		}),
+		"prepend and append":  "first\n" + base + "last\n",
+		"no trailing newline": strings.TrimSuffix(base, "\n"),
+		"empty":               "",
+	}
+
+	for name, newContent := range cases {
+		t.Run(name, func(t *testing.T) {
+			roundTrip(t, base, newContent, 3)
+			roundTrip(t, newContent, base, 3)
+		})
+	}
+}
+
+func TestHunkHeaderForNewFile(t *testing.T) {
+	hunks := MakeHunks(DiffLines(nil, SplitLines([]byte("a\nb\n"))), 3)
+	if len(hunks) != 1 {
+		t.Fatalf("Expected 1 hunk, got %d", len(hunks))
+	}
+	if got := hunks[0].Header(); got != "@@ -0,0 +1,2 @@" {
+		t.Errorf("Unexpected header %q", got)
+	}
+}
+
+func TestApplyHunksWithOffset(t *testing.T) {
+	oldContent := makeLines(40, nil)
+	newContent := makeLines(40, func(i int) string {
+		if i == 20 {
+			return "changed"
+		}
+		return fmt.Sprintf("line %d", i)
+	})
+	hunks := MakeHunks(DiffLines(SplitLines([]byte(oldContent)), SplitLines([]byte(newContent))), 3)
+
+	// Target has extra lines before the change, shifting it down
+	target := SplitLines([]byte("extra 1\nextra 2\n" + oldContent))
+	applied, failed := ApplyHunks(target, hunks)
+	if len(failed) != 0 {
+		t.Fatalf("Expected hunk to apply with offset, failed: %v", failed)
+	}
+	if got := strings.Join(applied, ""); got != "extra 1\nextra 2\n"+newContent {
+		t.Errorf("Unexpected result after offset apply")
+	}
+}
+
+func TestApplyHunksConflict(t *testing.T) {
+	oldContent := makeLines(10, nil)
+	newContent := strings.Replace(oldContent, "line 5\n", "five\n", 1)
+	hunks := MakeHunks(DiffLines(SplitLines([]byte(oldContent)), SplitLines([]byte(newContent))), 3)
+
+	target := SplitLines([]byte(strings.Replace(oldContent, "line 5\n", "cinq\n", 1)))
+	applied, failed := ApplyHunks(target, hunks)
+	if len(failed) != 1 || failed[0] != 0 {
+		t.Fatalf("Expected hunk 0 to fail, got %v", failed)
+	}
+	if strings.Join(applied, "") != strings.Join(target, "") {
+		t.Errorf("Failed hunk should leave content unchanged")
+	}
+}
+
+func TestApplyHunksFuzz(t *testing.T) {
+	oldContent := makeLines(20, nil)
+	newContent := strings.Replace(oldContent, "line 10\n", "ten\n", 1)
+	hunks := MakeHunks(DiffLines(SplitLines([]byte(oldContent)), SplitLines([]byte(newContent))), 3)
+
+	// The target differs in the outermost context lines of the hunk
+	target := strings.Replace(oldContent, "line 7\n", "seven\n", 1)
+	target = strings.Replace(target, "line 13\n", "thirteen\n", 1)
+	if _, failed := ApplyHunksFuzz(SplitLines([]byte(target)), hunks, 0); len(failed) != 1 {
+		t.Fatalf("Expected the hunk to fail without fuzz, failed: %v", failed)
+	}
+	applied, failed := ApplyHunksFuzz(SplitLines([]byte(target)), hunks, 1)
+	if len(failed) != 0 {
+		t.Fatalf("Expected the hunk to apply with fuzz 1, failed: %v", failed)
+	}
+	if got, want := strings.Join(applied, ""), strings.Replace(target, "line 10\n", "ten\n", 1); got != want {
+		t.Errorf("Fuzzy apply should keep the mismatched context, got:\n%s", got)
+	}
+
+	// Fuzz does not reach the changed lines themselves
+	changed := strings.Replace(oldContent, "line 10\n", "dix\n", 1)
+	if _, failed := ApplyHunksFuzz(SplitLines([]byte(changed)), hunks, 3); len(failed) != 1 {
+		t.Errorf("Expected the hunk to fail on changed lines, failed: %v", failed)
+	}
+}
+
+func TestHunkReverse(t *testing.T) {
+	oldContent := makeLines(20, nil)
+	newContent := strings.Replace(oldContent, "line 4\n", "four\nand more\n", 1)
+	newContent = strings.Replace(newContent, "line 15\n", "", 1)
+	hunks := MakeHunks(DiffLines(SplitLines([]byte(oldContent)), SplitLines([]byte(newContent))), 3)
+
+	reversed := make([]Hunk, len(hunks))
+	for i, hunk := range hunks {
+		reversed[i] = hunk.Reverse()
+	}
+	applied, failed := ApplyHunks(SplitLines([]byte(newContent)), reversed)
+	if len(failed) != 0 || strings.Join(applied, "") != oldContent {
+		t.Fatalf("Reversed hunks did not restore the old content (failed: %v)", failed)
+	}
+	if got := reversed[0].Header(); got != "@@ -2,8 +2,7 @@" {
+		t.Errorf("Unexpected reversed header %q", got)
+	}
+	if ops := reversed[0].Ops; ops[3].Type != LineDelete || ops[5].Type != LineInsert {
+		t.Errorf("Expected deletions before insertions, got %+v", ops)
+	}
+}
+
+func TestHunkContext(t *testing.T) {
+	oldContent := makeLines(40, nil)
+	// Changes at lines 10, 18 and 30 (1-based 11, 19 and 31)
+	newContent := makeLines(40, func(i int) string {
+		if i == 10 || i == 18 || i == 30 {
+			return fmt.Sprintf("changed %d", i)
+		}
+		return fmt.Sprintf("line %d", i)
+	})
+	ops := DiffLines(SplitLines([]byte(oldContent)), SplitLines([]byte(newContent)))
+
+	tests := []struct {
+		name    string
+		opts    HunkOptions
+		headers []string
+	}{
+		{"no context", HunkOptions{Context: 0}, []string{"@@ -11 +11 @@", "@@ -19 +19 @@", "@@ -31 +31 @@"}},
+		{"default context", HunkOptions{Context: DefaultContext}, []string{"@@ -8,7 +8,7 @@", "@@ -16,7 +16,7 @@", "@@ -28,7 +28,7 @@"}},
+		{"context joining hunks", HunkOptions{Context: 4}, []string{"@@ -7,17 +7,17 @@", "@@ -27,9 +27,9 @@"}},
+		{"inter-hunk context", HunkOptions{Context: 3, InterHunkContext: 1}, []string{"@@ -8,15 +8,15 @@", "@@ -28,7 +28,7 @@"}},
+		{"inter-hunk context without context", HunkOptions{InterHunkContext: 7}, []string{"@@ -11,9 +11,9 @@", "@@ -31 +31 @@"}},
+		{"context beyond the file", HunkOptions{Context: 100}, []string{"@@ -1,40 +1,40 @@"}},
+		{"negative context", HunkOptions{Context: -2, InterHunkContext: -1}, []string{"@@ -11 +11 @@", "@@ -19 +19 @@", "@@ -31 +31 @@"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := WhitespaceOptions{}.MakeHunksWith(ops, tt.opts)
+			var headers []string
+			for _, hunk := range hunks {
+				headers = append(headers, hunk.Header())
+			}
+			if strings.Join(headers, " ") != strings.Join(tt.headers, " ") {
+				t.Errorf("Expected hunks %v, got %v", tt.headers, headers)
+			}
+
+			// Every grouping applies back to the new content
+			applied, failed := ApplyHunks(SplitLines([]byte(oldContent)), hunks)
+			if len(failed) != 0 || strings.Join(applied, "") != newContent {
+				t.Errorf("Hunks did not reproduce the new content (failed: %v)", failed)
+			}
+		})
+	}
+}
+
+func TestHunkContextInsertOnly(t *testing.T) {
+	ops := DiffLines(SplitLines([]byte("a\nb\nc\n")), SplitLines([]byte("a\nb\nx\ny\nc\n")))
+	hunks := MakeHunks(ops, 0)
+	if len(hunks) != 1 {
+		t.Fatalf("Expected 1 hunk, got %d", len(hunks))
+	}
+	// With no context an insertion names the line it follows
+	if got := hunks[0].Header(); got != "@@ -2,0 +3,2 @@" {
+		t.Errorf("Unexpected header %q", got)
+	}
+	roundTrip(t, "a\nb\nc\n", "a\nb\nx\ny\nc\n", 0)
+}
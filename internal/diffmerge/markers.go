@@ -0,0 +1,130 @@
+package diffmerge
+
+import "strings"
+
+// ConflictStyle selects how a conflict is written between conflict markers.
+type ConflictStyle string
+
+const (
+	// ConflictStyleMerge writes the two sides of a conflict
+	ConflictStyleMerge ConflictStyle = "merge"
+	// ConflictStyleDiff3 also writes the base content of a conflict,
+	// between ||||||| and =======
+	ConflictStyleDiff3 ConflictStyle = "diff3"
+)
+
+// MarkerLabels name the sides of a conflict after its markers.
+type MarkerLabels struct {
+	Ours   string
+	Base   string
+	Theirs string
+}
+
+// MergeText merges the line changes that ours and theirs make to base.
+// Changes to different lines are both applied; overlapping changes are
+// written between conflict markers in the given style. It returns the
+// merged content and the number of conflicts.
+func MergeText(base, ours, theirs []byte, style ConflictStyle, labels MarkerLabels) ([]byte, int) {
+	lines, conflicts := mergeLines(SplitLines(base), SplitLines(ours), SplitLines(theirs), style, labels)
+	return []byte(strings.Join(lines, "")), conflicts
+}
+
+// mergeLines is the diff3 merge of MergeText. Both sides are diffed
+// against base; base lines kept by both sides split the files into stable
+// lines and chunks changed by one side or both.
+func mergeLines(base, ours, theirs []string, style ConflictStyle, labels MarkerLabels) ([]string, int) {
+	oursMatch := matchLines(base, ours)
+	theirsMatch := matchLines(base, theirs)
+
+	var merged []string
+	conflicts := 0
+	i, a, b := 0, 0, 0
+	for i < len(base) || a < len(ours) || b < len(theirs) {
+		if i < len(base) && oursMatch[i] == a && theirsMatch[i] == b {
+			merged = append(merged, base[i])
+			i, a, b = i+1, a+1, b+1
+			continue
+		}
+
+		// The chunk ends at the next base line both sides kept
+		j := i
+		for j < len(base) && (oursMatch[j] < 0 || theirsMatch[j] < 0) {
+			j++
+		}
+		endA, endB := len(ours), len(theirs)
+		if j < len(base) {
+			endA, endB = oursMatch[j], theirsMatch[j]
+		}
+
+		baseChunk, oursChunk, theirsChunk := base[i:j], ours[a:endA], theirs[b:endB]
+		switch {
+		case equalLines(oursChunk, theirsChunk), equalLines(theirsChunk, baseChunk):
+			merged = append(merged, oursChunk...)
+		case equalLines(oursChunk, baseChunk):
+			merged = append(merged, theirsChunk...)
+		default:
+			conflicts++
+			merged = appendMarker(merged, "<<<<<<<", labels.Ours)
+			merged = appendConflictLines(merged, oursChunk)
+			if style == ConflictStyleDiff3 {
+				merged = appendMarker(merged, "|||||||", labels.Base)
+				merged = appendConflictLines(merged, baseChunk)
+			}
+			merged = appendMarker(merged, "=======", "")
+			merged = appendConflictLines(merged, theirsChunk)
+			merged = appendMarker(merged, ">>>>>>>", labels.Theirs)
+		}
+		i, a, b = j, endA, endB
+	}
+	return merged, conflicts
+}
+
+// matchLines maps each line of base to the line of other it is kept as,
+// or -1 if other deletes it
+func matchLines(base, other []string) []int {
+	match := make([]int, len(base))
+	i, j := 0, 0
+	for _, op := range DiffLines(base, other) {
+		switch op.Type {
+		case LineEqual:
+			match[i] = j
+			i, j = i+1, j+1
+		case LineDelete:
+			match[i] = -1
+			i++
+		case LineInsert:
+			j++
+		}
+	}
+	return match
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendMarker adds a conflict marker line with an optional label
+func appendMarker(lines []string, marker, label string) []string {
+	if label != "" {
+		marker += " " + label
+	}
+	return append(lines, marker+"\n")
+}
+
+// appendConflictLines adds the lines of one side of a conflict, ending the
+// last with a newline so that the next marker starts a line of its own
+func appendConflictLines(lines, side []string) []string {
+	lines = append(lines, side...)
+	if n := len(lines); len(side) > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += "\n"
+	}
+	return lines
+}
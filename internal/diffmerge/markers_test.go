@@ -0,0 +1,74 @@
+package diffmerge
+
+import "testing"
+
+func TestMergeTextConflictStyles(t *testing.T) {
+	base := []byte("package main\n\nfunc timeout() int {\n\treturn 30\n}\n")
+	ours := []byte("package main\n\nfunc timeout() int {\n\treturn 60\n}\n")
+	theirs := []byte("package main\n\nfunc timeout() int {\n\treturn 10\n}\n")
+	labels := MarkerLabels{Ours: "main", Base: "base", Theirs: "feature"}
+
+	tests := []struct {
+		style    ConflictStyle
+		expected string
+	}{
+		{ConflictStyleMerge, "package main\n\nfunc timeout() int {\n" +
+			"<<<<<<< main\n\treturn 60\n" +
+			"=======\n\treturn 10\n" +
+			">>>>>>> feature\n}\n"},
+		{ConflictStyleDiff3, "package main\n\nfunc timeout() int {\n" +
+			"<<<<<<< main\n\treturn 60\n" +
+			"||||||| base\n\treturn 30\n" +
+			"=======\n\treturn 10\n" +
+			">>>>>>> feature\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.style), func(t *testing.T) {
+			merged, conflicts := MergeText(base, ours, theirs, tt.style, labels)
+			if conflicts != 1 {
+				t.Errorf("Expected 1 conflict, got %d", conflicts)
+			}
+			if string(merged) != tt.expected {
+				t.Errorf("Unexpected merge:\n%s\nwant:\n%s", merged, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeTextClean(t *testing.T) {
+	base := []byte("a\nb\nc\nd\ne\n")
+	labels := MarkerLabels{Ours: "ours", Base: "base", Theirs: "theirs"}
+
+	tests := []struct {
+		name     string
+		ours     string
+		theirs   string
+		expected string
+	}{
+		{"separate changes", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n"},
+		{"same change", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n"},
+		{"insert and delete", "a\nb\nx\nc\nd\ne\n", "a\nb\nc\nd\n", "a\nb\nx\nc\nd\n"},
+		{"one side only", "a\nb\nc\nd\ne\n", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, style := range []ConflictStyle{ConflictStyleMerge, ConflictStyleDiff3} {
+				merged, conflicts := MergeText(base, []byte(tt.ours), []byte(tt.theirs), style, labels)
+				if conflicts != 0 {
+					t.Errorf("%s: expected no conflicts, got %d:\n%s", style, conflicts, merged)
+				}
+				if string(merged) != tt.expected {
+					t.Errorf("%s: expected %q, got %q", style, tt.expected, merged)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeTextMissingNewline(t *testing.T) {
+	merged, conflicts := MergeText([]byte("x"), []byte("ours"), []byte("theirs"), ConflictStyleDiff3, MarkerLabels{})
+	expected := "<<<<<<<\nours\n|||||||\nx\n=======\ntheirs\n>>>>>>>\n"
+	if conflicts != 1 || string(merged) != expected {
+		t.Errorf("Expected one conflict %q, got %d: %q", expected, conflicts, merged)
+	}
+}
@@ -0,0 +1,105 @@
+package diffmerge
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MinMovedChars is the number of non-whitespace characters a block needs
+// before it can count as moved. Shorter blocks, such as a lone closing
+// brace, match unrelated changes by coincidence.
+const MinMovedChars = 20
+
+// moveRun is a run of deleted or inserted lines, ops[start:end] of one
+// edit script, with blank lines trimmed from both ends
+type moveRun struct {
+	script     int
+	start, end int
+}
+
+// DetectMoves finds blocks of lines that an edit script deletes and an edit
+// script adds back elsewhere, in the same file or another one. Each maximal
+// run of deleted lines is paired with a run of inserted lines with the same
+// content, compared after normalize (nil compares lines exactly); runs that
+// match several others are paired in order. The result has one slice per
+// script, parallel to its ops, that is true for ops in a moved block.
+func DetectMoves(scripts [][]LineOp, normalize func(string) string) [][]bool {
+	moved := make([][]bool, len(scripts))
+	deleted := make(map[string][]moveRun)
+	var inserted []moveRun
+	var insertedKeys []string
+
+	for s, ops := range scripts {
+		moved[s] = make([]bool, len(ops))
+		for i := 0; i < len(ops); {
+			if ops[i].Type == LineEqual {
+				i++
+				continue
+			}
+			j := i
+			for j < len(ops) && ops[j].Type == ops[i].Type {
+				j++
+			}
+
+			run := trimBlankRun(ops, moveRun{script: s, start: i, end: j})
+			if key, ok := moveKey(ops[run.start:run.end], normalize); ok {
+				if ops[i].Type == LineDelete {
+					deleted[key] = append(deleted[key], run)
+				} else {
+					inserted = append(inserted, run)
+					insertedKeys = append(insertedKeys, key)
+				}
+			}
+			i = j
+		}
+	}
+
+	for k, run := range inserted {
+		candidates := deleted[insertedKeys[k]]
+		if len(candidates) == 0 {
+			continue
+		}
+		from := candidates[0]
+		deleted[insertedKeys[k]] = candidates[1:]
+
+		for i := from.start; i < from.end; i++ {
+			moved[from.script][i] = true
+		}
+		for i := run.start; i < run.end; i++ {
+			moved[run.script][i] = true
+		}
+	}
+	return moved
+}
+
+// trimBlankRun shrinks a run past blank lines at its ends, which diffs
+// attach to either side of a block at will
+func trimBlankRun(ops []LineOp, run moveRun) moveRun {
+	for run.start < run.end && strings.TrimSpace(ops[run.start].Text) == "" {
+		run.start++
+	}
+	for run.end > run.start && strings.TrimSpace(ops[run.end-1].Text) == "" {
+		run.end--
+	}
+	return run
+}
+
+// moveKey returns the content a run is matched on, or false if the run is
+// too short to be reported as moved
+func moveKey(ops []LineOp, normalize func(string) string) (string, bool) {
+	chars := 0
+	lines := make([]string, len(ops))
+	for i, op := range ops {
+		line := strings.TrimSuffix(op.Text, "\n")
+		if normalize != nil {
+			line = normalize(line)
+		}
+		lines[i] = line
+		for _, r := range line {
+			if !unicode.IsSpace(r) {
+				chars++
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), chars >= MinMovedChars
+}
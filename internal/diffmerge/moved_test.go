@@ -0,0 +1,91 @@
+package diffmerge
+
+import (
+	"reflect"
+	"testing"
+)
+
+// movedLines returns the text of the ops DetectMoves marked in one script
+func movedLines(ops []LineOp, moved []bool) []string {
+	var lines []string
+	for i, op := range ops {
+		if moved[i] {
+			lines = append(lines, op.Text)
+		}
+	}
+	return lines
+}
+
+func TestDetectMovesWithinFile(t *testing.T) {
+	oldContent := "package p\n\nfunc a() {\n\treturn computeSomething()\n}\n\nfunc b() { doSomethingElse() }\n"
+	newContent := "package p\n\nfunc b() { doSomethingElse() }\n\nfunc a() {\n\treturn computeSomething()\n}\n"
+
+	ops := DiffLines(SplitLines([]byte(oldContent)), SplitLines([]byte(newContent)))
+	moved := DetectMoves([][]LineOp{ops}, nil)[0]
+
+	var deleted, inserted int
+	for i, op := range ops {
+		if !moved[i] {
+			continue
+		}
+		switch op.Type {
+		case LineDelete:
+			deleted++
+		case LineInsert:
+			inserted++
+		default:
+			t.Errorf("Unchanged line %q marked as moved", op.Text)
+		}
+	}
+	if deleted == 0 || deleted != inserted {
+		t.Errorf("Expected the moved block on both sides, got %d deleted and %d inserted", deleted, inserted)
+	}
+}
+
+func TestDetectMovesAcrossFiles(t *testing.T) {
+	block := "func helper(x int) int {\n\treturn x * 2\n}\n"
+	fromOps := DiffLines(SplitLines([]byte("package a\n\n"+block+"\nvar keep = 1\n")), SplitLines([]byte("package a\n\nvar keep = 1\n")))
+	toOps := DiffLines(SplitLines([]byte("package b\n")), SplitLines([]byte("package b\n\n"+block)))
+	otherOps := DiffLines(SplitLines([]byte("x := 1\n")), SplitLines([]byte("x := 2\n")))
+
+	moved := DetectMoves([][]LineOp{fromOps, otherOps, toOps}, nil)
+
+	want := []string{"func helper(x int) int {\n", "\treturn x * 2\n", "}\n"}
+	if got := movedLines(fromOps, moved[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected moved-from lines %q, got %q", want, got)
+	}
+	if got := movedLines(toOps, moved[2]); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected moved-to lines %q, got %q", want, got)
+	}
+	if got := movedLines(otherOps, moved[1]); len(got) != 0 {
+		t.Errorf("Expected no moved lines in an unrelated change, got %q", got)
+	}
+}
+
+func TestDetectMovesIgnoresShortAndUnpairedBlocks(t *testing.T) {
+	// A lone brace moving is a coincidence, not a moved block
+	short := DiffLines([]string{"}\n", "a\n"}, []string{"a\n", "}\n"})
+	// Content that only appears on one side has nothing to pair with
+	removed := DiffLines([]string{"this line is long enough to count\n"}, nil)
+
+	for i, marks := range DetectMoves([][]LineOp{short, removed}, nil) {
+		for _, m := range marks {
+			if m {
+				t.Errorf("Script %d: expected no moved lines", i)
+			}
+		}
+	}
+}
+
+func TestDetectMovesNormalize(t *testing.T) {
+	ws := WhitespaceOptions{IgnoreChange: true}
+	from := DiffLines([]string{"if ready {\n", "\tstart(engine)\n", "}\n"}, nil)
+	to := DiffLines(nil, []string{"if ready {\n", "\t\tstart(engine)\n", "}\n"})
+
+	if moved := DetectMoves([][]LineOp{from, to}, nil); moved[0][0] {
+		t.Error("Expected reindented block not to match exactly")
+	}
+	if moved := DetectMoves([][]LineOp{from, to}, ws.Normalize); !moved[0][0] || !moved[1][0] {
+		t.Error("Expected reindented block to match with IgnoreChange")
+	}
+}
@@ -0,0 +1,231 @@
+package diffmerge
+
+import (
+	"bytes"
+	"math"
+	"sort"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+)
+
+// RenameDetection represents a detected file rename.
+type RenameDetection struct {
+	OldPath    string
+	NewPath    string
+	Similarity float64 // 0.0 to 1.0, where 1.0 is exact match
+}
+
+// DetectRenames detects if files were renamed between two workspace states.
+// Removed and added files with the same content are renames with similarity
+// 1.0. Other pairs score the share of the larger file's bytes that lie on
+// lines both have in common; binary and empty files only match exactly.
+// Each file takes part in at most one rename, the best scoring pairs being
+// chosen first, and pairs below threshold are dropped. The renames are
+// sorted by new path.
+//
+// Exact renames are paired by content hash. Only pairs whose sizes allow
+// the threshold to be reached are scored, and a pair is only diffed when
+// the lines the two files share could reach it. With RenameLimit set, a
+// diff with too many remaining files skips inexact detection altogether
+// and records the limit it needed in RenameLimitNeeded.
+func (a *Analyzer) DetectRenames(diff *WorkspaceDiff, threshold float64) []RenameDetection {
+	a.RenameLimitNeeded = 0
+
+	// Group changes by type
+	var added, removed []FileChange
+	for _, change := range diff.FileChanges {
+		switch change.Type {
+		case Added:
+			if change.NewFile != nil {
+				added = append(added, change)
+			}
+		case Removed:
+			if change.OldFile != nil {
+				removed = append(removed, change)
+			}
+		}
+	}
+	if len(added) == 0 || len(removed) == 0 {
+		return nil
+	}
+
+	var renames []RenameDetection
+	usedRemoved := make(map[int]bool)
+	usedAdded := make(map[int]bool)
+	pair := func(i, j int, similarity float64) {
+		usedRemoved[i] = true
+		usedAdded[j] = true
+		renames = append(renames, RenameDetection{
+			OldPath:    removed[i].Path,
+			NewPath:    added[j].Path,
+			Similarity: similarity,
+		})
+	}
+
+	// Exact renames first, earlier files winning ties
+	addedByHash := make(map[cas.Hash][]int)
+	for j, ad := range added {
+		hash := ad.NewFile.FileRef.Hash
+		addedByHash[hash] = append(addedByHash[hash], j)
+	}
+	for i, r := range removed {
+		for _, j := range addedByHash[r.OldFile.FileRef.Hash] {
+			if !usedAdded[j] {
+				pair(i, j, 1.0)
+				break
+			}
+		}
+	}
+
+	var restRemoved, restAdded []int
+	for i := range removed {
+		if !usedRemoved[i] {
+			restRemoved = append(restRemoved, i)
+		}
+	}
+	for j := range added {
+		if !usedAdded[j] {
+			restAdded = append(restAdded, j)
+		}
+	}
+	if threshold >= 1.0 || a.CAS == nil || len(restRemoved) == 0 || len(restAdded) == 0 {
+		return sortRenames(renames)
+	}
+	if limit := a.RenameLimit; limit > 0 && len(restRemoved)*len(restAdded) > limit*limit {
+		a.RenameLimitNeeded = max(len(restRemoved), len(restAdded))
+		return sortRenames(renames)
+	}
+
+	oldRefs := make([]filechunk.NodeRef, len(restRemoved))
+	for x, i := range restRemoved {
+		oldRefs[x] = removed[i].OldFile.FileRef
+	}
+	newRefs := make([]filechunk.NodeRef, len(restAdded))
+	for y, j := range restAdded {
+		newRefs[y] = added[j].NewFile.FileRef
+	}
+	for _, c := range a.scorePairs(oldRefs, newRefs, threshold) {
+		i, j := restRemoved[c.old], restAdded[c.new]
+		if !usedRemoved[i] && !usedAdded[j] {
+			pair(i, j, c.similarity)
+		}
+	}
+	return sortRenames(renames)
+}
+
+// scoredPair is a pair of files similar enough to be a rename or copy,
+// with the positions of both files in the lists given to scorePairs
+type scoredPair struct {
+	old, new   int
+	similarity float64
+}
+
+// scorePairs scores every pair of an old and a new file whose similarity
+// reaches threshold, as DetectRenames describes, and returns the pairs best
+// first. Ties go to the earlier old file, then the earlier new one.
+func (a *Analyzer) scorePairs(oldRefs, newRefs []filechunk.NodeRef, threshold float64) []scoredPair {
+	// Content is only read when an inexact pair can pass the threshold
+	type text struct {
+		lines []string
+		count map[string]int
+	}
+	contents := make(map[cas.Hash]*text)
+	load := func(ref filechunk.NodeRef) *text {
+		if t, ok := contents[ref.Hash]; ok {
+			return t
+		}
+		content, err := filechunk.NewLoader(a.CAS).ReadAll(ref)
+		if err != nil || len(content) == 0 || bytes.IndexByte(content, 0) >= 0 {
+			contents[ref.Hash] = nil
+			return nil
+		}
+		t := &text{lines: SplitLines(content), count: make(map[string]int)}
+		for _, line := range t.lines {
+			t.count[line]++
+		}
+		contents[ref.Hash] = t
+		return t
+	}
+
+	// Sorting the new files by size bounds the pairs worth scoring to a
+	// window around each old file's size
+	bySize := make([]int, len(newRefs))
+	for j := range bySize {
+		bySize[j] = j
+	}
+	sort.SliceStable(bySize, func(x, y int) bool {
+		return newRefs[bySize[x]].Size < newRefs[bySize[y]].Size
+	})
+
+	var pairs []scoredPair
+	for i, oldRef := range oldRefs {
+		if oldRef.Size == 0 {
+			continue
+		}
+		minSize := int64(math.Ceil(float64(oldRef.Size) * threshold))
+		first := sort.Search(len(bySize), func(x int) bool {
+			return newRefs[bySize[x]].Size >= minSize
+		})
+		for _, j := range bySize[first:] {
+			newRef := newRefs[j]
+			smaller, larger := oldRef.Size, newRef.Size
+			if smaller > larger {
+				smaller, larger = larger, smaller
+			}
+			if float64(smaller)/float64(larger) < threshold {
+				if newRef.Size > oldRef.Size {
+					break // Larger files only fall further short
+				}
+				continue
+			}
+
+			oldText := load(oldRef)
+			if oldText == nil {
+				break
+			}
+			newText := load(newRef)
+			if newText == nil {
+				continue
+			}
+			// Lines the files share, in any order, bound the score
+			shared := 0
+			for line, n := range oldText.count {
+				shared += len(line) * min(n, newText.count[line])
+			}
+			if float64(shared)/float64(larger) < threshold {
+				continue
+			}
+
+			common := 0
+			for _, op := range DiffLines(oldText.lines, newText.lines) {
+				if op.Type == LineEqual {
+					common += len(op.Text)
+				}
+			}
+			if similarity := float64(common) / float64(larger); similarity >= threshold {
+				pairs = append(pairs, scoredPair{i, j, similarity})
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(x, y int) bool {
+		px, py := pairs[x], pairs[y]
+		if px.similarity != py.similarity {
+			return px.similarity > py.similarity
+		}
+		if px.old != py.old {
+			return px.old < py.old
+		}
+		return px.new < py.new
+	})
+	return pairs
+}
+
+// sortRenames sorts renames by new path
+func sortRenames(renames []RenameDetection) []RenameDetection {
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].NewPath < renames[j].NewPath
+	})
+	return renames
+}
@@ -0,0 +1,166 @@
+package diffmerge
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+func storeTestFile(t *testing.T, casStore cas.CAS, path, content string) *wsindex.FileMetadata {
+	t.Helper()
+	ref, err := filechunk.NewBuilder(casStore, filechunk.DefaultParams()).Build([]byte(content))
+	if err != nil {
+		t.Fatalf("Failed to store %s: %v", path, err)
+	}
+	return &wsindex.FileMetadata{Path: path, FileRef: ref, Size: int64(len(content))}
+}
+
+func TestDetectRenamesSimilarContent(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	analyzer := NewAnalyzer(casStore)
+
+	lines := make([]string, 10)
+	for i := range lines {
+		lines[i] = strings.Repeat(string(rune('a'+i)), 9) + "\n"
+	}
+	original := strings.Join(lines, "")
+	edited := strings.Join(lines[:9], "") + "changed!!\n"
+
+	diff := &WorkspaceDiff{
+		FileChanges: []FileChange{
+			{Type: Removed, Path: "old.txt", OldFile: storeTestFile(t, casStore, "old.txt", original)},
+			{Type: Removed, Path: "copy.txt", OldFile: storeTestFile(t, casStore, "copy.txt", original)},
+			{Type: Added, Path: "new.txt", NewFile: storeTestFile(t, casStore, "new.txt", edited)},
+			{Type: Added, Path: "other.txt", NewFile: storeTestFile(t, casStore, "other.txt", "unrelated\n")},
+		},
+	}
+
+	// Each file takes part in one rename at most; ties go to the first one
+	want := []RenameDetection{{OldPath: "old.txt", NewPath: "new.txt", Similarity: 0.9}}
+	if renames := analyzer.DetectRenames(diff, 0.5); !reflect.DeepEqual(renames, want) {
+		t.Errorf("Expected renames %+v, got %+v", want, renames)
+	}
+	if renames := analyzer.DetectRenames(diff, 0.95); len(renames) != 0 {
+		t.Errorf("Expected no renames above 95%% similarity, got %+v", renames)
+	}
+}
+
+func TestDetectRenamesLimit(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	analyzer := NewAnalyzer(casStore)
+	analyzer.RenameLimit = 1
+
+	content := strings.Repeat("line of text\n", 20)
+	diff := &WorkspaceDiff{
+		FileChanges: []FileChange{
+			{Type: Removed, Path: "moved.txt", OldFile: storeTestFile(t, casStore, "moved.txt", "same\n")},
+			{Type: Added, Path: "dir/moved.txt", NewFile: storeTestFile(t, casStore, "dir/moved.txt", "same\n")},
+			{Type: Removed, Path: "a.txt", OldFile: storeTestFile(t, casStore, "a.txt", content+"a\n")},
+			{Type: Removed, Path: "b.txt", OldFile: storeTestFile(t, casStore, "b.txt", content+"b\n")},
+			{Type: Added, Path: "dir/a.txt", NewFile: storeTestFile(t, casStore, "dir/a.txt", content+"A\n")},
+			{Type: Added, Path: "dir/b.txt", NewFile: storeTestFile(t, casStore, "dir/b.txt", content+"B\n")},
+		},
+	}
+
+	// Exact renames do not count against the limit
+	renames := analyzer.DetectRenames(diff, 0.5)
+	want := []RenameDetection{{OldPath: "moved.txt", NewPath: "dir/moved.txt", Similarity: 1.0}}
+	if !reflect.DeepEqual(renames, want) || analyzer.RenameLimitNeeded != 2 {
+		t.Errorf("Expected only the exact rename and a needed limit of 2, got %+v and %d", renames, analyzer.RenameLimitNeeded)
+	}
+
+	analyzer.RenameLimit = 2
+	if renames := analyzer.DetectRenames(diff, 0.5); len(renames) != 3 || analyzer.RenameLimitNeeded != 0 {
+		t.Errorf("Expected 3 renames within the limit, got %+v and %d", renames, analyzer.RenameLimitNeeded)
+	}
+}
+
+// BenchmarkDetectRenames moves 1000 files of varying size into a new
+// directory, editing every other one
+func BenchmarkDetectRenames(b *testing.B) {
+	casStore := cas.NewMemoryCAS()
+	diff := &WorkspaceDiff{}
+	for i := 0; i < 1000; i++ {
+		var content strings.Builder
+		for line := 0; line < 10+i%200; line++ {
+			fmt.Fprintf(&content, "file %d line %d\n", i, line)
+		}
+		store := func(path, text string) *wsindex.FileMetadata {
+			ref, err := filechunk.NewBuilder(casStore, filechunk.DefaultParams()).Build([]byte(text))
+			if err != nil {
+				b.Fatalf("Failed to store %s: %v", path, err)
+			}
+			return &wsindex.FileMetadata{Path: path, FileRef: ref, Size: int64(len(text))}
+		}
+		oldPath, newPath := fmt.Sprintf("src/f%d.go", i), fmt.Sprintf("pkg/f%d.go", i)
+		newContent := content.String()
+		if i%2 == 0 {
+			newContent += "// edited\n"
+		}
+		diff.FileChanges = append(diff.FileChanges,
+			FileChange{Type: Removed, Path: oldPath, OldFile: store(oldPath, content.String())},
+			FileChange{Type: Added, Path: newPath, NewFile: store(newPath, newContent)})
+	}
+
+	analyzer := NewAnalyzer(casStore)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if renames := analyzer.DetectRenames(diff, 0.5); len(renames) != 1000 {
+			b.Fatalf("Expected 1000 renames, got %d", len(renames))
+		}
+	}
+}
+
+func TestDetectCopies(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	analyzer := NewAnalyzer(casStore)
+
+	lines := make([]string, 10)
+	for i := range lines {
+		lines[i] = strings.Repeat(string(rune('a'+i)), 9) + "\n"
+	}
+	original := strings.Join(lines, "")
+	edited := strings.Join(lines[:9], "") + "changed!!\n"
+
+	base := []wsindex.FileMetadata{
+		*storeTestFile(t, casStore, "kept.txt", original),
+		*storeTestFile(t, casStore, "twin.txt", original),
+		*storeTestFile(t, casStore, "gone.txt", "removed content\n"),
+		*storeTestFile(t, casStore, "moved.txt", "moved content\n"),
+	}
+	diff := &WorkspaceDiff{
+		FileChanges: []FileChange{
+			{Type: Removed, Path: "gone.txt", OldFile: &base[2]},
+			{Type: Removed, Path: "moved.txt", OldFile: &base[3]},
+			{Type: Added, Path: "exact.txt", NewFile: storeTestFile(t, casStore, "exact.txt", original)},
+			{Type: Added, Path: "near.txt", NewFile: storeTestFile(t, casStore, "near.txt", edited)},
+			{Type: Added, Path: "from-gone.txt", NewFile: storeTestFile(t, casStore, "from-gone.txt", "removed content\n")},
+			{Type: Added, Path: "dir/moved.txt", NewFile: storeTestFile(t, casStore, "dir/moved.txt", "moved content\n")},
+			{Type: Added, Path: "new.txt", NewFile: storeTestFile(t, casStore, "new.txt", "unrelated\n")},
+		},
+	}
+	renames := analyzer.DetectRenames(diff, 0.5)
+
+	// Removed files and renamed files take no part; ties go to the first source
+	want := []CopyDetection{
+		{OldPath: "kept.txt", NewPath: "exact.txt", Similarity: 1.0},
+		{OldPath: "kept.txt", NewPath: "near.txt", Similarity: 0.9},
+	}
+	if copies := analyzer.DetectCopies(diff, base, renames, 0.5); !reflect.DeepEqual(copies, want) {
+		t.Errorf("Expected copies %+v, got %+v", want, copies)
+	}
+	if copies := analyzer.DetectCopies(diff, base, renames, 0.95); !reflect.DeepEqual(copies, want[:1]) {
+		t.Errorf("Expected only the exact copy above 95%% similarity, got %+v", copies)
+	}
+
+	// Above the limit only exact copies are detected
+	analyzer.RenameLimit = 1
+	if copies := analyzer.DetectCopies(diff, base, renames, 0.5); !reflect.DeepEqual(copies, want[:1]) || analyzer.RenameLimitNeeded != 2 {
+		t.Errorf("Expected only the exact copy and a needed limit of 2, got %+v and %d", copies, analyzer.RenameLimitNeeded)
+	}
+}
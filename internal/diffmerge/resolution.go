@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
@@ -70,6 +71,8 @@ func NewResolutionStorage(ivaldiDir string) *ResolutionStorage {
 }
 
 // Save saves a merge resolution to disk.
+// The file is replaced atomically so that an interrupted save never loses
+// decisions recorded earlier.
 func (rs *ResolutionStorage) Save(resolution *MergeResolution) error {
 	resolutionPath := filepath.Join(rs.ivaldiDir, "MERGE_RESOLUTION")
 
@@ -78,14 +81,37 @@ func (rs *ResolutionStorage) Save(resolution *MergeResolution) error {
 		return fmt.Errorf("failed to marshal resolution: %w", err)
 	}
 
-	err = os.WriteFile(resolutionPath, data, 0644)
-	if err != nil {
+	tmpPath := resolutionPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write resolution: %w", err)
+	}
+	if err := os.Rename(tmpPath, resolutionPath); err != nil {
+		os.Remove(tmpPath)
 		return fmt.Errorf("failed to write resolution: %w", err)
 	}
 
 	return nil
 }
 
+// RecordFile loads the merge resolution, marks one conflicted file as
+// resolved and saves it again, so that files can be resolved one at a time
+// across several sessions.
+func (rs *ResolutionStorage) RecordFile(path string, strategy string, resultHash cas.Hash, resolvedBy string) error {
+	resolution, err := rs.Load()
+	if err != nil {
+		return err
+	}
+	if resolution == nil {
+		return fmt.Errorf("no merge resolution in progress")
+	}
+
+	if err := resolution.ResolveFile(path, strategy, resultHash, resolvedBy); err != nil {
+		return err
+	}
+
+	return rs.Save(resolution)
+}
+
 // Load loads a merge resolution from disk.
 func (rs *ResolutionStorage) Load() (*MergeResolution, error) {
 	resolutionPath := filepath.Join(rs.ivaldiDir, "MERGE_RESOLUTION")
@@ -193,6 +219,37 @@ func (mr *MergeResolution) AddFileResolution(path string, result *ChunkMergeResu
 	mr.Files[path] = resolution
 }
 
+// AddConflict records a conflicted file that still needs a resolution.
+// Files that already have an entry are left untouched.
+func (mr *MergeResolution) AddConflict(path string) {
+	if _, exists := mr.Files[path]; exists {
+		return
+	}
+	mr.Files[path] = &FileResolution{
+		Path:     path,
+		Strategy: string(mr.Strategy),
+		Resolved: false,
+	}
+}
+
+// ResolveFile records the resolution of a conflicted file: the strategy
+// used to produce it and the hash of the resolved content. Resolving a file
+// again replaces the earlier decision.
+func (mr *MergeResolution) ResolveFile(path string, strategy string, resultHash cas.Hash, resolvedBy string) error {
+	resolution, exists := mr.Files[path]
+	if !exists {
+		return fmt.Errorf("%s has no conflict in this merge", path)
+	}
+
+	resolution.Strategy = strategy
+	resolution.Resolved = true
+	resolution.ResultHash = resultHash.String()
+	resolution.ResolvedAt = time.Now()
+	resolution.ResolvedBy = resolvedBy
+
+	return nil
+}
+
 // MarkCompleted marks the resolution as completed.
 func (mr *MergeResolution) MarkCompleted() {
 	now := time.Now()
@@ -207,7 +264,7 @@ func (mr *MergeResolution) MarkAborted() {
 	mr.Status = "aborted"
 }
 
-// GetUnresolvedFiles returns all files that still have conflicts.
+// GetUnresolvedFiles returns all files that still have conflicts, sorted.
 func (mr *MergeResolution) GetUnresolvedFiles() []string {
 	var unresolved []string
 	for path, resolution := range mr.Files {
@@ -215,9 +272,22 @@ func (mr *MergeResolution) GetUnresolvedFiles() []string {
 			unresolved = append(unresolved, path)
 		}
 	}
+	sort.Strings(unresolved)
 	return unresolved
 }
 
+// ResolvedFiles returns all files with a recorded resolution, sorted.
+func (mr *MergeResolution) ResolvedFiles() []string {
+	var resolved []string
+	for path, resolution := range mr.Files {
+		if resolution.Resolved {
+			resolved = append(resolved, path)
+		}
+	}
+	sort.Strings(resolved)
+	return resolved
+}
+
 // IsFullyResolved checks if all files have been resolved.
 func (mr *MergeResolution) IsFullyResolved() bool {
 	for _, resolution := range mr.Files {
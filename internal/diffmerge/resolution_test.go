@@ -0,0 +1,74 @@
+package diffmerge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+func TestRecordFileResolutions(t *testing.T) {
+	dir := t.TempDir()
+
+	resolution := CreateResolution("feature", "main", cas.SumB3([]byte("source")), cas.SumB3([]byte("target")), StrategyAuto)
+	resolution.AddConflict("b.txt")
+	resolution.AddConflict("a.txt")
+	resolution.AddConflict("a.txt")
+
+	if resolution.IsFullyResolved() {
+		t.Fatal("Expected conflicts to be unresolved")
+	}
+	if err := NewResolutionStorage(dir).Save(resolution); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Each decision is persisted on its own, as if made in separate sessions
+	resultHash := cas.SumB3([]byte("resolved a"))
+	if err := NewResolutionStorage(dir).RecordFile("a.txt", "manual", resultHash, "Jane <jane@example.com>"); err != nil {
+		t.Fatalf("RecordFile failed: %v", err)
+	}
+
+	loaded, err := NewResolutionStorage(dir).Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := loaded.GetUnresolvedFiles(); !reflect.DeepEqual(got, []string{"b.txt"}) {
+		t.Errorf("Expected b.txt unresolved, got %v", got)
+	}
+	if got := loaded.ResolvedFiles(); !reflect.DeepEqual(got, []string{"a.txt"}) {
+		t.Errorf("Expected a.txt resolved, got %v", got)
+	}
+	if file := loaded.Files["a.txt"]; file.ResultHash != resultHash.String() || file.Strategy != "manual" {
+		t.Errorf("Unexpected recorded resolution: %+v", file)
+	}
+
+	if err := NewResolutionStorage(dir).RecordFile("b.txt", "theirs", cas.SumB3([]byte("b")), ""); err != nil {
+		t.Fatalf("RecordFile failed: %v", err)
+	}
+	loaded, err = NewResolutionStorage(dir).Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !loaded.IsFullyResolved() {
+		t.Errorf("Expected all conflicts resolved, unresolved: %v", loaded.GetUnresolvedFiles())
+	}
+}
+
+func TestRecordFileRejectsUnknownPath(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewResolutionStorage(dir)
+
+	if err := storage.RecordFile("a.txt", "manual", cas.Hash{}, ""); err == nil {
+		t.Error("Expected error without a resolution in progress")
+	}
+
+	resolution := CreateResolution("feature", "main", cas.Hash{}, cas.Hash{}, StrategyAuto)
+	resolution.AddConflict("a.txt")
+	if err := storage.Save(resolution); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if err := storage.RecordFile("other.txt", "manual", cas.Hash{}, ""); err == nil {
+		t.Error("Expected error for a file without a conflict")
+	}
+}
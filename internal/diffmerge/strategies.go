@@ -275,11 +275,12 @@ func (sr *StrategyResolver) ResolveWithFallback(primary, fallback StrategyType,
 	return result, nil
 }
 
-// BuildMergedFile reconstructs a complete file from merged chunks.
-func BuildMergedFile(casStore cas.CAS, chunks []cas.Hash, totalSize int64) (filechunk.NodeRef, error) {
+// BuildMergedFile reconstructs a complete file from merged chunks,
+// chunking it again with params.
+func BuildMergedFile(casStore cas.CAS, chunks []cas.Hash, totalSize int64, params filechunk.Params) (filechunk.NodeRef, error) {
 	if len(chunks) == 0 {
 		// Empty file
-		builder := filechunk.NewBuilder(casStore, filechunk.DefaultParams())
+		builder := filechunk.NewBuilder(casStore, params)
 		return builder.Build(nil)
 	}
 
@@ -314,6 +315,6 @@ func BuildMergedFile(casStore cas.CAS, chunks []cas.Hash, totalSize int64) (file
 	}
 
 	// Rebuild the file with proper chunking
-	builder := filechunk.NewBuilder(casStore, filechunk.DefaultParams())
+	builder := filechunk.NewBuilder(casStore, params)
 	return builder.Build(content)
 }
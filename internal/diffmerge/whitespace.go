@@ -0,0 +1,91 @@
+package diffmerge
+
+import (
+	"strings"
+	"unicode"
+)
+
+// WhitespaceOptions selects whitespace differences that a line diff
+// ignores. The zero value compares lines exactly.
+type WhitespaceOptions struct {
+	IgnoreAll        bool // Ignore all whitespace within lines
+	IgnoreChange     bool // Treat runs of whitespace as one space and ignore it at line ends
+	IgnoreBlankLines bool // Do not report changes that only add or remove blank lines
+}
+
+// DiffLines computes an edit script like the package-level DiffLines, but
+// compares lines after normalizing their whitespace. The ops keep the
+// original lines; a line that is equal on both sides is taken from a.
+func (o WhitespaceOptions) DiffLines(a, b []string) []LineOp {
+	if !o.IgnoreAll && !o.IgnoreChange {
+		return DiffLines(a, b)
+	}
+
+	ops := DiffLines(o.normalizeLines(a), o.normalizeLines(b))
+	i, j := 0, 0
+	for k := range ops {
+		switch ops[k].Type {
+		case LineEqual:
+			ops[k].Text = a[i]
+			i++
+			j++
+		case LineDelete:
+			ops[k].Text = a[i]
+			i++
+		case LineInsert:
+			ops[k].Text = b[j]
+			j++
+		}
+	}
+	return ops
+}
+
+// MakeHunks groups an edit script into hunks like the package-level
+// MakeHunks. With IgnoreBlankLines, added and removed blank lines do not
+// start a hunk of their own but are shown when they fall inside another.
+func (o WhitespaceOptions) MakeHunks(ops []LineOp, context int) []Hunk {
+	return o.MakeHunksWith(ops, HunkOptions{Context: context})
+}
+
+// MakeHunksWith groups an edit script into hunks like MakeHunks, with the
+// context and hunk joining of h
+func (o WhitespaceOptions) MakeHunksWith(ops []LineOp, h HunkOptions) []Hunk {
+	return makeHunks(ops, h, o.Ignores)
+}
+
+// Ignores reports whether op is a change the options hide
+func (o WhitespaceOptions) Ignores(op LineOp) bool {
+	return o.IgnoreBlankLines && op.Type != LineEqual && strings.TrimSpace(op.Text) == ""
+}
+
+// Normalize returns the form of line that is compared under the options
+func (o WhitespaceOptions) Normalize(line string) string {
+	switch {
+	case o.IgnoreAll:
+		return strings.Join(strings.Fields(line), "")
+	case o.IgnoreChange:
+		var b strings.Builder
+		space := false
+		for _, r := range strings.TrimRightFunc(line, unicode.IsSpace) {
+			if unicode.IsSpace(r) {
+				space = true
+				continue
+			}
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+	return line
+}
+
+func (o WhitespaceOptions) normalizeLines(lines []string) []string {
+	normalized := make([]string, len(lines))
+	for i, line := range lines {
+		normalized[i] = o.Normalize(line)
+	}
+	return normalized
+}
@@ -0,0 +1,126 @@
+package diffmerge
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// renderWhitespaceDiff diffs two texts under the options and returns the hunks
+func renderWhitespaceDiff(t *testing.T, ws WhitespaceOptions, oldContent, newContent string) string {
+	t.Helper()
+
+	ops := ws.DiffLines(SplitLines([]byte(oldContent)), SplitLines([]byte(newContent)))
+	var buf bytes.Buffer
+	if err := WriteUnifiedHunks(&buf, ws.MakeHunks(ops, 3)); err != nil {
+		t.Fatalf("WriteUnifiedHunks failed: %v", err)
+	}
+	return buf.String()
+}
+
+func TestWhitespaceNormalize(t *testing.T) {
+	cases := []struct {
+		ws   WhitespaceOptions
+		a, b string
+		same bool
+	}{
+		{WhitespaceOptions{}, "a b\n", "a  b\n", false},
+		{WhitespaceOptions{IgnoreChange: true}, "a b\n", "a \t b\n", true},
+		{WhitespaceOptions{IgnoreChange: true}, "a b\n", "a b  \r\n", true},
+		{WhitespaceOptions{IgnoreChange: true}, "a b\n", "ab\n", false},
+		{WhitespaceOptions{IgnoreChange: true}, "\tx\n", "x\n", false},
+		{WhitespaceOptions{IgnoreAll: true}, "a b\n", "ab\n", true},
+		{WhitespaceOptions{IgnoreAll: true}, "\tx\n", "x\n", true},
+		{WhitespaceOptions{IgnoreAll: true}, "a b\n", "a c\n", false},
+	}
+
+	for _, c := range cases {
+		if got := c.ws.Normalize(c.a) == c.ws.Normalize(c.b); got != c.same {
+			t.Errorf("%+v: expected %q and %q equal=%v, got %v", c.ws, c.a, c.b, c.same, got)
+		}
+	}
+}
+
+func TestWhitespaceIgnoreAll(t *testing.T) {
+	oldContent := "func f() {\n\treturn x+1\n}\n"
+	newContent := "func f()  {\n    return x + 1\n}\n"
+
+	if got := renderWhitespaceDiff(t, WhitespaceOptions{IgnoreAll: true}, oldContent, newContent); got != "" {
+		t.Errorf("Expected no hunks, got:\n%s", got)
+	}
+
+	// A real change is still reported, with the original lines
+	newContent = "func f()  {\n    return x + 2\n}\n"
+	want := "@@ -1,3 +1,3 @@\n func f() {\n-\treturn x+1\n+    return x + 2\n }\n"
+	if got := renderWhitespaceDiff(t, WhitespaceOptions{IgnoreAll: true}, oldContent, newContent); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestWhitespaceIgnoreChange(t *testing.T) {
+	oldContent := "a b\nc\n"
+
+	if got := renderWhitespaceDiff(t, WhitespaceOptions{IgnoreChange: true}, oldContent, "a   b \nc\n"); got != "" {
+		t.Errorf("Expected no hunks, got:\n%s", got)
+	}
+
+	// Removing whitespace between words is a change under -b
+	want := "@@ -1,2 +1,2 @@\n-a b\n+ab\n c\n"
+	if got := renderWhitespaceDiff(t, WhitespaceOptions{IgnoreChange: true}, oldContent, "ab\nc\n"); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestWhitespaceIgnoreBlankLines(t *testing.T) {
+	ws := WhitespaceOptions{IgnoreBlankLines: true}
+	base := makeLines(20, nil)
+
+	// Only blank lines added: nothing to show
+	blanks := makeLines(20, func(i int) string {
+		if i == 5 {
+			return "line 5\n  \n"
+		}
+		return fmt.Sprintf("line %d", i)
+	})
+	if got := renderWhitespaceDiff(t, ws, base, blanks); got != "" {
+		t.Errorf("Expected no hunks, got:\n%s", got)
+	}
+	if got := renderWhitespaceDiff(t, WhitespaceOptions{}, base, blanks); got == "" {
+		t.Error("Expected a hunk without IgnoreBlankLines")
+	}
+
+	// Only blank lines removed: nothing to show either
+	if got := renderWhitespaceDiff(t, ws, blanks, base); got != "" {
+		t.Errorf("Expected no hunks, got:\n%s", got)
+	}
+
+	// A blank line next to a real change is shown as part of its hunk
+	edited := makeLines(20, func(i int) string {
+		switch i {
+		case 10:
+			return "changed"
+		case 11:
+			return "line 11\n"
+		}
+		return fmt.Sprintf("line %d", i)
+	})
+	want := "@@ -8,6 +8,7 @@\n line 7\n line 8\n line 9\n-line 10\n+changed\n line 11\n+\n line 12\n"
+	if got := renderWhitespaceDiff(t, ws, base, edited); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	// Line numbers of later hunks account for the hidden blank line
+	both := makeLines(20, func(i int) string {
+		switch i {
+		case 2:
+			return "line 2\n"
+		case 15:
+			return "changed"
+		}
+		return fmt.Sprintf("line %d", i)
+	})
+	want = "@@ -13,7 +14,7 @@\n line 12\n line 13\n line 14\n-line 15\n+changed\n line 16\n line 17\n line 18\n"
+	if got := renderWhitespaceDiff(t, ws, base, both); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+}
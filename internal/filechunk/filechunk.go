@@ -255,65 +255,133 @@ func (l *Loader) ReadAll(root NodeRef) ([]byte, error) {
 	return result.Bytes(), nil
 }
 
-// Reader returns a streaming reader for a file tree.
+// Reader returns a streaming reader for a file tree. Errors reading the
+// tree are returned by Read.
 func (l *Loader) Reader(root NodeRef) (io.ReadCloser, error) {
-	data, err := l.ReadAll(root)
-	if err != nil {
-		return nil, err
-	}
-	return io.NopCloser(bytes.NewReader(data)), nil
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(l.WriteTo(root, pw))
+	}()
+	return pr, nil
 }
 
-// readNode recursively reads node content.
+// readNode recursively reads node content. Leaves are streamed, so that a
+// file stored as a single large leaf is never held in memory.
 func (l *Loader) readNode(node NodeRef, w io.Writer) error {
+	if node.Kind == Leaf {
+		return l.streamLeaf(node.Hash, w)
+	}
+
 	data, err := l.CAS.Get(node.Hash)
 	if err != nil {
 		return fmt.Errorf("failed to get node %s: %w", node.Hash, err)
 	}
-
-	if node.Kind == Leaf {
-		return l.readLeaf(data, w)
-	}
 	return l.readInternal(data, w)
 }
 
 // readLeaf reads content from a leaf node.
 func (l *Loader) readLeaf(data []byte, w io.Writer) error {
+	chunk, err := decodeLeaf(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(chunk)
+	return err
+}
+
+// readInternal reads content from an internal node.
+func (l *Loader) readInternal(data []byte, w io.Writer) error {
+	children, _, err := decodeInternal(data)
+	if err != nil {
+		return err
+	}
+
+	// Recursively read children
+	for _, childHash := range children {
+		// The encoding tells the child's kind
+		childData, err := l.CAS.Get(childHash)
+		if err != nil {
+			return fmt.Errorf("failed to get child %s: %w", childHash, err)
+		}
+
+		if len(childData) > 0 && childData[0] == 0x00 {
+			err = l.readLeaf(childData, w)
+		} else if len(childData) > 0 && childData[0] == 0x01 {
+			err = l.readInternal(childData, w)
+		} else {
+			return fmt.Errorf("invalid child node encoding")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DecodedNode is the content of a single stored node.
+type DecodedNode struct {
+	Kind     NodeKind
+	Chunk    []byte     // Data of a leaf
+	Children []cas.Hash // Child hashes of an internal node
+	Size     int64      // Bytes covered by the node
+}
+
+// DecodeNode decodes the canonical encoding of a single node without
+// loading its children.
+func DecodeNode(data []byte) (*DecodedNode, error) {
+	if len(data) > 0 && data[0] == 0x01 {
+		children, totalSize, err := decodeInternal(data)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedNode{Kind: Node, Children: children, Size: int64(totalSize)}, nil
+	}
+
+	chunk, err := decodeLeaf(data)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedNode{Kind: Leaf, Chunk: chunk, Size: int64(len(chunk))}, nil
+}
+
+// decodeLeaf returns the chunk data of a leaf node.
+func decodeLeaf(data []byte) ([]byte, error) {
 	if len(data) == 0 || data[0] != 0x00 {
-		return fmt.Errorf("invalid leaf node encoding")
+		return nil, fmt.Errorf("invalid leaf node encoding")
 	}
 
 	buf := bytes.NewReader(data[1:])
-	
+
 	// Read chunk length
 	chunkLen, err := binary.ReadUvarint(buf)
 	if err != nil {
-		return fmt.Errorf("failed to read chunk length: %w", err)
+		return nil, fmt.Errorf("failed to read chunk length: %w", err)
 	}
-
-	// Read chunk data
-	chunk := make([]byte, chunkLen)
-	n, err := buf.Read(chunk)
-	if err != nil || uint64(n) != chunkLen {
-		return fmt.Errorf("failed to read chunk data: expected %d, got %d", chunkLen, n)
+	if chunkLen != uint64(buf.Len()) {
+		return nil, fmt.Errorf("failed to read chunk data: expected %d, got %d", chunkLen, buf.Len())
 	}
 
-	_, err = w.Write(chunk)
-	return err
+	return data[len(data)-int(chunkLen):], nil
 }
 
-// readInternal reads content from an internal node.
-func (l *Loader) readInternal(data []byte, w io.Writer) error {
+// decodeInternal returns the child hashes and total size of an internal
+// node.
+func decodeInternal(data []byte) ([]cas.Hash, uint64, error) {
 	if len(data) == 0 || data[0] != 0x01 {
-		return fmt.Errorf("invalid internal node encoding")
+		return nil, 0, fmt.Errorf("invalid internal node encoding")
 	}
 
 	buf := bytes.NewReader(data[1:])
-	
+
 	// Read child count
 	childCount, err := binary.ReadUvarint(buf)
 	if err != nil {
-		return fmt.Errorf("failed to read child count: %w", err)
+		return nil, 0, fmt.Errorf("failed to read child count: %w", err)
+	}
+	if childCount > uint64(buf.Len())/32 {
+		return nil, 0, fmt.Errorf("child count %d exceeds node size", childCount)
 	}
 
 	// Read child hashes
@@ -321,41 +389,18 @@ func (l *Loader) readInternal(data []byte, w io.Writer) error {
 	for i := uint64(0); i < childCount; i++ {
 		n, err := buf.Read(children[i][:])
 		if err != nil || n != 32 {
-			return fmt.Errorf("failed to read child hash %d", i)
+			return nil, 0, fmt.Errorf("failed to read child hash %d", i)
 		}
 	}
 
-	// Read total size (for validation)
-	_, err = binary.ReadUvarint(buf)
+	// Read total size
+	totalSize, err := binary.ReadUvarint(buf)
 	if err != nil {
-		return fmt.Errorf("failed to read total size: %w", err)
+		return nil, 0, fmt.Errorf("failed to read total size: %w", err)
 	}
-
-	// Recursively read children
-	for _, childHash := range children {
-		// We need to determine the child's kind and size
-		// For simplicity, we'll peek at the stored data
-		childData, err := l.CAS.Get(childHash)
-		if err != nil {
-			return fmt.Errorf("failed to get child %s: %w", childHash, err)
-		}
-
-		var childNode NodeRef
-		childNode.Hash = childHash
-		
-		if len(childData) > 0 && childData[0] == 0x00 {
-			childNode.Kind = Leaf
-		} else if len(childData) > 0 && childData[0] == 0x01 {
-			childNode.Kind = Node
-		} else {
-			return fmt.Errorf("invalid child node encoding")
-		}
-
-		err = l.readNode(childNode, w)
-		if err != nil {
-			return err
-		}
+	if buf.Len() != 0 {
+		return nil, 0, fmt.Errorf("trailing data after internal node")
 	}
 
-	return nil
-}
\ No newline at end of file
+	return children, totalSize, nil
+}
@@ -334,4 +334,40 @@ func BenchmarkReadAll1MB(b *testing.B) {
 			b.Fatalf("ReadAll failed: %v", err)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestDecodeNode(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewBuilder(casStore, Params{LeafSize: 8})
+	root, err := builder.Build([]byte("hello world"))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	data, err := casStore.Get(root.Hash)
+	if err != nil {
+		t.Fatalf("Get node failed: %v", err)
+	}
+	node, err := DecodeNode(data)
+	if err != nil {
+		t.Fatalf("DecodeNode failed: %v", err)
+	}
+	if node.Kind != Node || node.Size != 11 || len(node.Children) != 2 {
+		t.Fatalf("Expected an internal node over 2 chunks of 11 bytes, got %+v", node)
+	}
+
+	leafData, err := casStore.Get(node.Children[0])
+	if err != nil {
+		t.Fatalf("Get leaf failed: %v", err)
+	}
+	leaf, err := DecodeNode(leafData)
+	if err != nil {
+		t.Fatalf("DecodeNode failed: %v", err)
+	}
+	if leaf.Kind != Leaf || string(leaf.Chunk) != "hello wo" {
+		t.Errorf("Expected leaf \"hello wo\", got %+v", leaf)
+	}
+
+	if _, err := DecodeNode(append(leafData, 0)); err == nil {
+		t.Error("Expected trailing data to be rejected")
+	}
+}
@@ -0,0 +1,169 @@
+package filechunk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Chunking profiles. Small chunks let a small edit to a source file share
+// every other chunk with the previous version; large chunks keep the object
+// count of big media files down, where edits rarely leave chunks intact.
+const (
+	ProfileDefault     = "default"
+	ProfileText        = "text"
+	ProfileBinaryLarge = "binary-large"
+)
+
+// AttributesFile is the name of the file that assigns profiles to paths.
+const AttributesFile = ".ivaldiattributes"
+
+var profileParams = map[string]Params{
+	ProfileDefault:     DefaultParams(),
+	ProfileText:        {LeafSize: 8 * 1024},
+	ProfileBinaryLarge: {LeafSize: 1024 * 1024},
+}
+
+// ProfileNames returns the names of the chunking profiles, sorted.
+func ProfileNames() []string {
+	names := make([]string, 0, len(profileParams))
+	for name := range profileParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileParams returns the parameters of a named profile.
+func ProfileParams(name string) (Params, error) {
+	params, ok := profileParams[name]
+	if !ok {
+		return Params{}, fmt.Errorf("unknown chunk profile %q (expected one of %s)", name, strings.Join(ProfileNames(), ", "))
+	}
+	return params, nil
+}
+
+// Extensions that select a profile when no attribute rule matches.
+var extensionProfiles = map[string]string{}
+
+func init() {
+	for _, ext := range []string{
+		".c", ".cc", ".cpp", ".cs", ".css", ".go", ".h", ".hpp", ".html", ".java",
+		".js", ".json", ".jsx", ".kt", ".lua", ".md", ".php", ".py", ".rb", ".rs",
+		".scss", ".sh", ".sql", ".swift", ".toml", ".ts", ".tsx", ".txt", ".xml",
+		".yaml", ".yml",
+	} {
+		extensionProfiles[ext] = ProfileText
+	}
+	for _, ext := range []string{
+		".7z", ".avi", ".bin", ".dmg", ".flac", ".gz", ".iso", ".jpeg", ".jpg",
+		".mkv", ".mov", ".mp3", ".mp4", ".ogg", ".pdf", ".png", ".psd", ".tar",
+		".tgz", ".wav", ".webm", ".xz", ".zip",
+	} {
+		extensionProfiles[ext] = ProfileBinaryLarge
+	}
+}
+
+// profileRule assigns a profile to the paths matching a pattern.
+type profileRule struct {
+	pattern string
+	profile string
+}
+
+// ProfileRules chooses the chunking profile of each file. Rules read from
+// .ivaldiattributes take precedence, the last matching line winning; other
+// files are chosen by extension. A nil *ProfileRules uses extensions only.
+type ProfileRules struct {
+	rules []profileRule
+}
+
+// LoadProfileRules reads the .ivaldiattributes file at the root of workDir.
+// A missing file yields rules that go by extension alone.
+func LoadProfileRules(workDir string) (*ProfileRules, error) {
+	file, err := os.Open(filepath.Join(workDir, AttributesFile))
+	if os.IsNotExist(err) {
+		return &ProfileRules{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules, err := ParseProfileRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", AttributesFile, err)
+	}
+	return rules, nil
+}
+
+// ParseProfileRules parses attribute lines of the form
+//
+//	<pattern> chunk=<profile>
+//
+// Blank lines and lines starting with # are skipped, as are lines without a
+// chunk attribute, so the file can carry other attributes too.
+func ParseProfileRules(r io.Reader) (*ProfileRules, error) {
+	rules := &ProfileRules{}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		for _, attr := range fields[1:] {
+			name, ok := strings.CutPrefix(attr, "chunk=")
+			if !ok {
+				continue
+			}
+			if _, err := ProfileParams(name); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			rules.rules = append(rules.rules, profileRule{pattern: fields[0], profile: name})
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// Profile returns the name of the profile for a path relative to the
+// repository root.
+func (pr *ProfileRules) Profile(filePath string) string {
+	filePath = filepath.ToSlash(filePath)
+	if pr != nil {
+		for i := len(pr.rules) - 1; i >= 0; i-- {
+			if matchAttributePattern(pr.rules[i].pattern, filePath) {
+				return pr.rules[i].profile
+			}
+		}
+	}
+	if profile, ok := extensionProfiles[strings.ToLower(path.Ext(filePath))]; ok {
+		return profile
+	}
+	return ProfileDefault
+}
+
+// Params returns the chunking parameters for a path.
+func (pr *ProfileRules) Params(filePath string) Params {
+	return profileParams[pr.Profile(filePath)]
+}
+
+// matchAttributePattern matches a pattern against a slash-separated path.
+// Patterns without a slash match the base name anywhere in the tree; a
+// trailing slash matches everything below a directory.
+func matchAttributePattern(pattern, filePath string) bool {
+	if dir, ok := strings.CutSuffix(pattern, "/"); ok {
+		dir = strings.TrimPrefix(dir, "/")
+		return strings.HasPrefix(filePath, dir+"/")
+	}
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(filePath))
+		return matched
+	}
+	matched, _ := path.Match(strings.TrimPrefix(pattern, "/"), filePath)
+	return matched
+}
@@ -0,0 +1,131 @@
+package filechunk
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+func TestProfileByExtension(t *testing.T) {
+	var rules *ProfileRules
+
+	cases := map[string]string{
+		"main.go":           ProfileText,
+		"docs/README.MD":    ProfileText,
+		"assets/intro.mp4":  ProfileBinaryLarge,
+		"photos/cat.JPG":    ProfileBinaryLarge,
+		"Makefile":          ProfileDefault,
+		"data/blob.unknown": ProfileDefault,
+	}
+	for path, want := range cases {
+		if got := rules.Profile(path); got != want {
+			t.Errorf("Profile(%s): expected %s, got %s", path, want, got)
+		}
+	}
+
+	if got := rules.Params("main.go").LeafSize; got != 8*1024 {
+		t.Errorf("Expected text leaf size 8 KiB, got %d", got)
+	}
+}
+
+func TestParseProfileRules(t *testing.T) {
+	input := `# Chunking
+*.dat          chunk=binary-large
+vendor/        chunk=default
+generated/*.go chunk=binary-large eol=lf
+*.dat          text
+special.dat    chunk=text
+`
+	rules, err := ParseProfileRules(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseProfileRules failed: %v", err)
+	}
+
+	cases := map[string]string{
+		"a/b/c.dat":            ProfileBinaryLarge,
+		"special.dat":          ProfileText, // Later lines win
+		"x/special.dat":        ProfileText,
+		"vendor/lib/lib.go":    ProfileDefault,
+		"generated/api.go":     ProfileBinaryLarge,
+		"src/generated/api.go": ProfileText, // Patterns with a slash are anchored
+		"main.go":              ProfileText,
+	}
+	for path, want := range cases {
+		if got := rules.Profile(path); got != want {
+			t.Errorf("Profile(%s): expected %s, got %s", path, want, got)
+		}
+	}
+
+	if _, err := ParseProfileRules(strings.NewReader("*.bin chunk=huge\n")); err == nil {
+		t.Error("Expected an error for an unknown profile")
+	}
+}
+
+func TestLoadProfileRules(t *testing.T) {
+	workDir := t.TempDir()
+
+	rules, err := LoadProfileRules(workDir)
+	if err != nil {
+		t.Fatalf("LoadProfileRules without a file failed: %v", err)
+	}
+	if got := rules.Profile("model.bin"); got != ProfileBinaryLarge {
+		t.Errorf("Expected %s, got %s", ProfileBinaryLarge, got)
+	}
+
+	if err := os.WriteFile(filepath.Join(workDir, AttributesFile), []byte("*.bin chunk=text\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = LoadProfileRules(workDir)
+	if err != nil {
+		t.Fatalf("LoadProfileRules failed: %v", err)
+	}
+	if got := rules.Profile("model.bin"); got != ProfileText {
+		t.Errorf("Expected %s, got %s", ProfileText, got)
+	}
+}
+
+// BenchmarkProfiles reports, for each profile, how many objects a file is
+// stored as and how many new objects a one-byte edit in its middle adds.
+func BenchmarkProfiles(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	source := make([]byte, 256*1024)
+	for i := range source {
+		source[i] = byte('a' + rng.Intn(26))
+	}
+	media := make([]byte, 16*1024*1024)
+	rng.Read(media)
+
+	for _, content := range []struct {
+		name string
+		data []byte
+	}{{"source-256KiB", source}, {"media-16MiB", media}} {
+		for _, profile := range ProfileNames() {
+			params, _ := ProfileParams(profile)
+			b.Run(fmt.Sprintf("%s/%s", content.name, profile), func(b *testing.B) {
+				edited := append([]byte(nil), content.data...)
+				edited[len(edited)/2] ^= 1
+
+				var objects, added int
+				for i := 0; i < b.N; i++ {
+					store := cas.NewMemoryCAS()
+					builder := NewBuilder(store, params)
+					if _, err := builder.Build(content.data); err != nil {
+						b.Fatalf("Build failed: %v", err)
+					}
+					objects = store.Len()
+					if _, err := builder.Build(edited); err != nil {
+						b.Fatalf("Build failed: %v", err)
+					}
+					added = store.Len() - objects
+				}
+				b.ReportMetric(float64(objects), "objects")
+				b.ReportMetric(float64(added), "new-objects/edit")
+			})
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package filechunk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"lukechampine.com/blake3"
+)
+
+// BuildWhole stores size bytes read from r as a single leaf, however large,
+// streaming them into the CAS instead of holding them in memory. r is read
+// twice, to hash the leaf and then to store it, and must be positioned at
+// the start of the content. BuildWhole also returns the BLAKE3 hash of the
+// content itself.
+func (b *Builder) BuildWhole(r io.ReadSeeker, size int64) (NodeRef, cas.Hash, error) {
+	header := leafHeader(size)
+
+	leafHasher := blake3.New(32, nil)
+	contentHasher := blake3.New(32, nil)
+	leafHasher.Write(header)
+	n, err := io.Copy(io.MultiWriter(leafHasher, contentHasher), r)
+	if err != nil {
+		return NodeRef{}, cas.Hash{}, fmt.Errorf("read error: %w", err)
+	}
+	if n != size {
+		return NodeRef{}, cas.Hash{}, fmt.Errorf("read error: expected %d bytes, got %d", size, n)
+	}
+	var hash, checksum cas.Hash
+	copy(hash[:], leafHasher.Sum(nil))
+	copy(checksum[:], contentHasher.Sum(nil))
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return NodeRef{}, cas.Hash{}, fmt.Errorf("read error: %w", err)
+	}
+	// The store hashes the content again, so a file that changed since the
+	// first read is refused rather than stored under the wrong hash
+	content := io.MultiReader(bytes.NewReader(header), io.LimitReader(r, size))
+	if err := cas.PutReader(b.CAS, hash, content); err != nil {
+		return NodeRef{}, cas.Hash{}, fmt.Errorf("failed to store leaf: %w", err)
+	}
+
+	return NodeRef{Hash: hash, Kind: Leaf, Size: size}, checksum, nil
+}
+
+// leafHeader returns the bytes that precede the chunk of a leaf node.
+func leafHeader(size int64) []byte {
+	header := make([]byte, 1, 1+binary.MaxVarintLen64)
+	return binary.AppendUvarint(header, uint64(size))
+}
+
+// WriteTo writes the entire content of a file tree to w, streaming its
+// leaves.
+func (l *Loader) WriteTo(root NodeRef, w io.Writer) error {
+	if root.Size == 0 {
+		return nil
+	}
+	return l.readNode(root, w)
+}
+
+// streamLeaf copies the chunk of a leaf node to w.
+func (l *Loader) streamLeaf(hash cas.Hash, w io.Writer) error {
+	r, err := cas.GetReader(l.CAS, hash)
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %w", hash, err)
+	}
+	defer r.Close()
+
+	br := bufio.NewReader(r)
+	if marker, err := br.ReadByte(); err != nil || marker != 0x00 {
+		return fmt.Errorf("invalid leaf node encoding")
+	}
+	chunkLen, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("failed to read chunk length: %w", err)
+	}
+	n, err := io.Copy(w, br)
+	if err != nil {
+		return err
+	}
+	if uint64(n) != chunkLen {
+		return fmt.Errorf("failed to read chunk data: expected %d, got %d", chunkLen, n)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package filechunk
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+func TestBuildWhole(t *testing.T) {
+	casStore, err := cas.NewFileCAS(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileCAS failed: %v", err)
+	}
+	builder := NewBuilder(casStore, Params{LeafSize: 16})
+
+	content := []byte(strings.Repeat("large asset content ", 100))
+	root, checksum, err := builder.BuildWhole(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("BuildWhole failed: %v", err)
+	}
+	if root.Kind != Leaf || root.Size != int64(len(content)) {
+		t.Errorf("Expected a single leaf of %d bytes, got kind %d of %d bytes", len(content), root.Kind, root.Size)
+	}
+	if checksum != cas.SumB3(content) {
+		t.Error("Expected the checksum to be the hash of the content")
+	}
+
+	// The leaf is encoded like any other, whatever the leaf size
+	expected, err := NewBuilder(casStore, Params{LeafSize: len(content)}).Build(content)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if root.Hash != expected.Hash {
+		t.Error("Expected BuildWhole to store the same leaf as Build")
+	}
+
+	loader := NewLoader(casStore)
+	var buf bytes.Buffer
+	if err := loader.WriteTo(root, &buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Error("WriteTo content mismatch")
+	}
+
+	reader, err := loader.Reader(root)
+	if err != nil {
+		t.Fatalf("Reader failed: %v", err)
+	}
+	defer reader.Close()
+	read, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(read, content) {
+		t.Error("Reader content mismatch")
+	}
+}
+
+func TestBuildWholeShortRead(t *testing.T) {
+	builder := NewBuilder(cas.NewMemoryCAS(), DefaultParams())
+	if _, _, err := builder.BuildWhole(strings.NewReader("short"), 100); err == nil {
+		t.Error("Expected an error when the content is shorter than its size")
+	}
+}
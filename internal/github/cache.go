@@ -0,0 +1,221 @@
+package github
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default limits of the cache pruning policy
+const (
+	DefaultCacheMaxAge  = 30 * 24 * time.Hour
+	DefaultCacheMaxSize = 64 << 20
+)
+
+// ResponseCache keeps GET responses together with their ETags, so repeated
+// requests can be revalidated with If-None-Match. GitHub answers unchanged
+// resources with 304 Not Modified, which does not count against the rate
+// limit. Each entry is a file whose modification time records its last use.
+type ResponseCache struct {
+	dir string
+}
+
+// cacheHeader is the first line of a cache entry file; the body follows
+type cacheHeader struct {
+	URL  string `json:"url"`
+	ETag string `json:"etag"`
+}
+
+// CacheDir returns the response cache directory of a repository
+func CacheDir(ivaldiDir string) string {
+	return filepath.Join(ivaldiDir, "github-cache")
+}
+
+// NewResponseCache returns a cache stored in dir, which is created on the
+// first write
+func NewResponseCache(dir string) *ResponseCache {
+	return &ResponseCache{dir: dir}
+}
+
+// entryPath returns the file holding the entry for a URL
+func (rc *ResponseCache) entryPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(rc.dir, hex.EncodeToString(sum[:16]))
+}
+
+// lookup returns the cached ETag and body for a URL, if any
+func (rc *ResponseCache) lookup(url string) (string, []byte, bool) {
+	data, err := os.ReadFile(rc.entryPath(url))
+	if err != nil {
+		return "", nil, false
+	}
+	line, body, ok := bytes.Cut(data, []byte{'\n'})
+	if !ok {
+		return "", nil, false
+	}
+	var header cacheHeader
+	if err := json.Unmarshal(line, &header); err != nil || header.URL != url || header.ETag == "" {
+		return "", nil, false
+	}
+	return header.ETag, body, true
+}
+
+// store saves a response body under its ETag. Failures only cost a future
+// revalidation, so they are ignored.
+func (rc *ResponseCache) store(url, etag string, body []byte) {
+	line, err := json.Marshal(cacheHeader{URL: url, ETag: etag})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(rc.dir, 0755); err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(rc.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(append(append(line, '\n'), body...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), rc.entryPath(url)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// touch records that an entry was used
+func (rc *ResponseCache) touch(url string) {
+	now := time.Now()
+	os.Chtimes(rc.entryPath(url), now, now)
+}
+
+// PrunePolicy selects the cache entries Prune evicts
+type PrunePolicy struct {
+	MaxAge  time.Duration // Entries unused for longer are evicted; 0 disables
+	MaxSize int64         // Least recently used entries are evicted above this size; 0 disables
+	Clear   bool          // Evict every entry that is not kept
+}
+
+// DefaultPrunePolicy returns the policy used without options
+func DefaultPrunePolicy() PrunePolicy {
+	return PrunePolicy{MaxAge: DefaultCacheMaxAge, MaxSize: DefaultCacheMaxSize}
+}
+
+// PruneStats reports what Prune did
+type PruneStats struct {
+	Entries        int   // Entries before pruning
+	Removed        int   // Entries evicted
+	Kept           int   // Entries protected by the keep function
+	BytesReclaimed int64 // Size of the evicted entries
+	BytesRemaining int64 // Size of the entries left
+}
+
+// cacheFile is a cache entry found on disk
+type cacheFile struct {
+	path    string
+	url     string
+	size    int64
+	lastUse time.Time
+}
+
+// Prune evicts entries according to policy. Entries whose URL keep reports
+// true are never evicted; keep may be nil.
+func (rc *ResponseCache) Prune(policy PrunePolicy, keep func(url string) bool) (PruneStats, error) {
+	var stats PruneStats
+
+	dirEntries, err := os.ReadDir(rc.dir)
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	var files []cacheFile
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		file := cacheFile{
+			path:    filepath.Join(rc.dir, dirEntry.Name()),
+			size:    info.Size(),
+			lastUse: info.ModTime(),
+		}
+		// Leftovers from interrupted writes are always removed
+		if !strings.HasPrefix(dirEntry.Name(), ".tmp-") {
+			file.url = readCacheURL(file.path)
+		}
+		files = append(files, file)
+	}
+	stats.Entries = len(files)
+
+	// Least recently used first
+	sort.Slice(files, func(i, j int) bool { return files[i].lastUse.Before(files[j].lastUse) })
+
+	var total int64
+	for _, file := range files {
+		total += file.size
+	}
+
+	cutoff := time.Now().Add(-policy.MaxAge)
+	for _, file := range files {
+		if file.url != "" && keep != nil && keep(file.url) {
+			stats.Kept++
+			continue
+		}
+
+		evict := policy.Clear || file.url == "" ||
+			(policy.MaxAge > 0 && file.lastUse.Before(cutoff)) ||
+			(policy.MaxSize > 0 && total > policy.MaxSize)
+		if !evict {
+			continue
+		}
+		if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+			return stats, fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+		stats.Removed++
+		stats.BytesReclaimed += file.size
+		total -= file.size
+	}
+	stats.BytesRemaining = total
+
+	return stats, nil
+}
+
+// readCacheURL reads the URL from an entry's header, or "" if the file is
+// not a valid entry
+func readCacheURL(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return ""
+	}
+	var header cacheHeader
+	if json.Unmarshal(bytes.TrimSpace(line), &header) != nil {
+		return ""
+	}
+	return header.URL
+}
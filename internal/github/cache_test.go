@@ -0,0 +1,91 @@
+package github
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheRevalidates(t *testing.T) {
+	requests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, `{"name":"repo"}`)
+	}))
+	defer server.Close()
+
+	client := NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token")
+	client.SetCache(NewResponseCache(t.TempDir()))
+
+	for i := 0; i < 2; i++ {
+		repo, err := client.GetRepository(context.Background(), "owner", "repo")
+		if err != nil {
+			t.Fatalf("GetRepository failed: %v", err)
+		}
+		if repo.Name != "repo" {
+			t.Errorf("Request %d: expected name repo, got %q", i, repo.Name)
+		}
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("Expected the second request to be revalidated: %d requests, %d not modified", requests, notModified)
+	}
+}
+
+func TestResponseCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewResponseCache(dir)
+
+	body := []byte(strings.Repeat("x", 1000))
+	cache.store("https://api/repos/o/r/branches/main", `"a"`, body)
+	cache.store("https://api/repos/o/r/git/trees/old", `"b"`, body)
+	cache.store("https://api/repos/o/r/git/trees/new", `"c"`, body)
+
+	// Make every entry stale; the kept one must survive anyway
+	stale := time.Now().Add(-2 * DefaultCacheMaxAge)
+	for _, url := range []string{"https://api/repos/o/r/branches/main", "https://api/repos/o/r/git/trees/old"} {
+		os.Chtimes(cache.entryPath(url), stale, stale)
+	}
+
+	keep := func(url string) bool { return strings.HasSuffix(url, "/branches/main") }
+	stats, err := cache.Prune(DefaultPrunePolicy(), keep)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if stats.Entries != 3 || stats.Removed != 1 || stats.Kept != 1 || stats.BytesReclaimed == 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if _, _, ok := cache.lookup("https://api/repos/o/r/git/trees/old"); ok {
+		t.Error("Stale entry was not evicted")
+	}
+
+	// A size limit evicts the least recently used entries first
+	stats, err = cache.Prune(PrunePolicy{MaxSize: 1}, keep)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if stats.Removed != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if _, _, ok := cache.lookup("https://api/repos/o/r/branches/main"); !ok {
+		t.Error("Kept entry was evicted")
+	}
+
+	stats, err = cache.Prune(PrunePolicy{Clear: true}, nil)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if stats.Removed != 1 || stats.BytesRemaining != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
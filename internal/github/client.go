@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/auth"
+	"github.com/javanhut/Ivaldi-vcs/internal/httpclient"
 )
 
 const (
@@ -28,9 +29,11 @@ const (
 type Client struct {
 	httpClient  *http.Client
 	baseURL     string
+	rawURL      string
 	token       string
 	username    string
 	rateLimiter *RateLimiter
+	cache       *ResponseCache // Optional ETag cache for GET requests
 }
 
 // RateLimiter tracks API rate limits
@@ -53,6 +56,14 @@ type Repository struct {
 	Size          int       `json:"size"`
 }
 
+// User represents a GitHub account
+type User struct {
+	Login  string   `json:"login"`
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Scopes []string `json:"-"` // Granted to the token, from X-OAuth-Scopes
+}
+
 // Branch represents a GitHub branch
 type Branch struct {
 	Name      string `json:"name"`
@@ -73,6 +84,22 @@ type Commit struct {
 	Message string `json:"message"`
 }
 
+// RepoCommit is an entry in the commit history of a branch
+type RepoCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Author    GitUser `json:"author"`
+		Committer GitUser `json:"committer"`
+		Message   string  `json:"message"`
+		Tree      struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+	} `json:"commit"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+}
+
 // FileContent represents a file's content from GitHub
 type FileContent struct {
 	Type        string `json:"type"`
@@ -162,59 +189,143 @@ type UpdateRefRequest struct {
 	Force bool   `json:"force,omitempty"`
 }
 
-// NewClient creates a new GitHub API client
+// GitObject identifies the object a reference or tag points at
+type GitObject struct {
+	SHA  string `json:"sha"`
+	Type string `json:"type"`
+}
+
+// Reference represents a Git reference such as refs/tags/v1.0
+type Reference struct {
+	Ref    string    `json:"ref"`
+	Object GitObject `json:"object"`
+}
+
+// CreateTagRequest represents a request to create an annotated tag object
+type CreateTagRequest struct {
+	Tag     string   `json:"tag"`
+	Message string   `json:"message"`
+	Object  string   `json:"object"`
+	Type    string   `json:"type"`
+	Tagger  *GitUser `json:"tagger,omitempty"`
+}
+
+// TagObject represents an annotated tag object
+type TagObject struct {
+	SHA     string    `json:"sha"`
+	Tag     string    `json:"tag"`
+	Message string    `json:"message"`
+	Object  GitObject `json:"object"`
+	Tagger  *GitUser  `json:"tagger,omitempty"`
+}
+
+// NewClient creates a new GitHub API client for the configured server
 func NewClient() (*Client, error) {
+	return NewClientForEndpoints(ResolveEndpoints())
+}
+
+// NewClientForEndpoints creates a new GitHub API client for a specific server
+func NewClientForEndpoints(endpoints Endpoints) (*Client, error) {
 	// Try to get authentication from various sources
-	token := getAuthToken()
-	username := getUsername()
+	token := getAuthToken(endpoints)
 
 	if token == "" {
+		if endpoints.IsEnterprise() {
+			return nil, fmt.Errorf("no authentication found for %s. Run 'ivaldi login --host %s' or set GITHUB_ENTERPRISE_TOKEN", endpoints.Host, endpoints.Host)
+		}
 		return nil, fmt.Errorf("no GitHub authentication found. Run 'ivaldi auth login' to authenticate or set GITHUB_TOKEN environment variable")
 	}
 
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		baseURL:     GitHubAPIURL,
-		token:       token,
-		username:    username,
-		rateLimiter: &RateLimiter{},
-	}, nil
+	return NewClientWithToken(endpoints, token), nil
 }
 
-// getAuthToken attempts to get GitHub auth token from various sources
-func getAuthToken() string {
-	// 1. Check Ivaldi OAuth token (highest priority)
-	if token, err := auth.GetToken(); err == nil && token != "" {
-		return token
-	}
+// NewClientWithToken creates a GitHub API client that uses a specific token
+func NewClientWithToken(endpoints Endpoints, token string) *Client {
+	username := getUsername()
 
-	// 2. Check environment variable
-	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
-		return token
+	// The same client serves API and raw content requests, so both go
+	// through the configured proxy
+	httpClient, err := httpclient.New(30 * time.Second)
+	if err != nil {
+		fmt.Printf("Warning: %v; ignoring http settings\n", err)
+		httpClient = &http.Client{Timeout: 30 * time.Second}
 	}
 
-	// 3. Check git config for github token
-	if token := getGitConfig("github.token"); token != "" {
-		return token
+	return &Client{
+		httpClient: httpClient,
+		baseURL:     endpoints.APIURL,
+		rawURL:      endpoints.RawURL,
+		token:       token,
+		username:    username,
+		rateLimiter: &RateLimiter{},
 	}
+}
 
-	// 4. Try to read from git credential helper
-	if token := getGitCredential("github.com"); token != "" {
-		return token
+// authSource is one place a token can come from
+type authSource struct {
+	name  string
+	label string
+	token func(endpoints Endpoints) string
+}
+
+// authSources lists where to look for a token, in priority order. Ivaldi's
+// own OAuth login only covers public GitHub, so Enterprise servers use
+// GITHUB_ENTERPRISE_TOKEN and host-specific credentials instead.
+func authSources(endpoints Endpoints) []authSource {
+	// Tokens saved with 'ivaldi login' always come first
+	sources := []authSource{
+		{"credential", "'ivaldi login'", func(e Endpoints) string { return auth.CredentialToken(e.Host) }},
+	}
+
+	if endpoints.IsEnterprise() {
+		sources = append(sources, authSource{"enterprise-env", "GITHUB_ENTERPRISE_TOKEN environment variable", func(Endpoints) string {
+			return os.Getenv("GITHUB_ENTERPRISE_TOKEN")
+		}})
+	} else {
+		sources = append(sources, authSource{"ivaldi", "'ivaldi auth login'", func(Endpoints) string {
+			token, _ := auth.GetToken()
+			return token
+		}})
+	}
+
+	return append(sources,
+		authSource{"env", "GITHUB_TOKEN environment variable", func(Endpoints) string { return os.Getenv("GITHUB_TOKEN") }},
+		authSource{"git-config", "git config (github.token)", func(Endpoints) string { return getGitConfig("github.token") }},
+		authSource{"git-credential", "git credential helper", func(e Endpoints) string { return getGitCredential(e.Host) }},
+		authSource{"netrc", ".netrc file", func(e Endpoints) string { return getNetrcToken(e.Host) }},
+		authSource{"gh-cli", "'gh auth login' (GitHub CLI)", func(e Endpoints) string { return auth.GHCLIToken(e.Host) }},
+	)
+}
+
+// ResolveAuth returns the first authentication source that provides a token
+// for the server, or nil if none does
+func ResolveAuth(endpoints Endpoints) *auth.AuthMethod {
+	for _, source := range authSources(endpoints) {
+		if token := source.token(endpoints); token != "" {
+			return &auth.AuthMethod{
+				Name:        source.name,
+				Description: "Authenticated via " + source.label,
+				Token:       token,
+			}
+		}
 	}
+	return nil
+}
 
-	// 5. Check .netrc file
-	if token := getNetrcToken("github.com"); token != "" {
-		return token
+// AuthSourceLabels describes the places searched for a token, in order
+func AuthSourceLabels(endpoints Endpoints) []string {
+	var labels []string
+	for _, source := range authSources(endpoints) {
+		labels = append(labels, source.label)
 	}
+	return labels
+}
 
-	// 6. Check gh CLI config
-	if token := getGHCLIToken(); token != "" {
-		return token
+// getAuthToken attempts to get GitHub auth token from various sources
+func getAuthToken(endpoints Endpoints) string {
+	if method := ResolveAuth(endpoints); method != nil {
+		return method.Token
 	}
-
 	return ""
 }
 
@@ -297,39 +408,6 @@ func getNetrcToken(machine string) string {
 	return ""
 }
 
-// getGHCLIToken reads token from GitHub CLI config
-func getGHCLIToken() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ""
-	}
-
-	// Check gh cli config file
-	ghConfigPath := filepath.Join(home, ".config", "gh", "hosts.yml")
-	content, err := os.ReadFile(ghConfigPath)
-	if err != nil {
-		return ""
-	}
-
-	// Simple extraction - proper implementation would use YAML parser
-	lines := strings.Split(string(content), "\n")
-	for i, line := range lines {
-		if strings.Contains(line, "oauth_token:") {
-			parts := strings.Split(line, ":")
-			if len(parts) >= 2 {
-				return strings.TrimSpace(parts[1])
-			}
-		} else if strings.Contains(line, "token:") && i > 0 && strings.Contains(lines[i-1], "github.com") {
-			parts := strings.Split(line, ":")
-			if len(parts) >= 2 {
-				return strings.TrimSpace(parts[1])
-			}
-		}
-	}
-
-	return ""
-}
-
 // doRequest performs an authenticated API request
 func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
 	url := fmt.Sprintf("%s%s", c.baseURL, path)
@@ -355,6 +433,16 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	// Revalidate cached responses instead of downloading them again
+	cacheable := method == "GET" && c.cache != nil
+	var cachedBody []byte
+	if cacheable {
+		if etag, body, ok := c.cache.lookup(url); ok {
+			req.Header.Set("If-None-Match", etag)
+			cachedBody = body
+		}
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -363,16 +451,49 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 	// Update rate limit info
 	c.updateRateLimits(resp)
 
+	if cachedBody != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		c.cache.touch(url)
+		resp.StatusCode = http.StatusOK
+		resp.Body = io.NopCloser(bytes.NewReader(cachedBody))
+		return resp, nil
+	}
+
 	// Check for API errors
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	if etag := resp.Header.Get("ETag"); cacheable && etag != "" {
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		c.cache.store(url, etag, data)
+		resp.Body = io.NopCloser(bytes.NewReader(data))
 	}
 
 	return resp, nil
 }
 
+// SetCache makes the client revalidate GET requests against cache
+func (c *Client) SetCache(cache *ResponseCache) {
+	c.cache = cache
+}
+
+// APIError is returned for responses with an error status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Body)
+}
+
 // updateRateLimits updates rate limit information from response headers
 func (c *Client) updateRateLimits(resp *http.Response) {
 	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
@@ -442,21 +563,49 @@ func (c *Client) GetCommit(ctx context.Context, owner, repo, sha string) (*Commi
 	return &commit, nil
 }
 
-// ListBranches fetches all branches from a repository
-func (c *Client) ListBranches(ctx context.Context, owner, repo string) ([]*Branch, error) {
-	path := fmt.Sprintf("/repos/%s/%s/branches", owner, repo)
+// ListCommits fetches one page of the history reachable from sha, newest
+// first. Pages are numbered from 1.
+func (c *Client) ListCommits(ctx context.Context, owner, repo, sha string, page, perPage int) ([]*RepoCommit, error) {
+	path := fmt.Sprintf("/repos/%s/%s/commits?sha=%s&page=%d&per_page=%d", owner, repo, sha, page, perPage)
 	resp, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var branches []*Branch
-	if err := json.NewDecoder(resp.Body).Decode(&branches); err != nil {
-		return nil, fmt.Errorf("failed to decode branches: %w", err)
+	var commits []*RepoCommit
+	if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
+		return nil, fmt.Errorf("failed to decode commits: %w", err)
 	}
 
-	return branches, nil
+	return commits, nil
+}
+
+// ListBranches fetches all branches from a repository, following
+// pagination
+func (c *Client) ListBranches(ctx context.Context, owner, repo string) ([]*Branch, error) {
+	const perPage = 100
+
+	var branches []*Branch
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("/repos/%s/%s/branches?page=%d&per_page=%d", owner, repo, page, perPage)
+		resp, err := c.doRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageBranches []*Branch
+		err = json.NewDecoder(resp.Body).Decode(&pageBranches)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode branches: %w", err)
+		}
+
+		branches = append(branches, pageBranches...)
+		if len(pageBranches) < perPage {
+			return branches, nil
+		}
+	}
 }
 
 // CreateBranch creates a new branch in a repository from a source SHA
@@ -477,6 +626,106 @@ func (c *Client) CreateBranch(ctx context.Context, owner, repo, branchName, sour
 	return nil
 }
 
+// CreateRef creates a fully qualified reference such as refs/tags/v1.0
+func (c *Client) CreateRef(ctx context.Context, owner, repo, ref, sha string) error {
+	path := fmt.Sprintf("/repos/%s/%s/git/refs", owner, repo)
+
+	requestBody := map[string]string{
+		"ref": ref,
+		"sha": sha,
+	}
+
+	resp, err := c.doRequest(ctx, "POST", path, requestBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// GetRef fetches a reference, given without the refs/ prefix (e.g. tags/v1.0)
+func (c *Client) GetRef(ctx context.Context, owner, repo, ref string) (*Reference, error) {
+	path := fmt.Sprintf("/repos/%s/%s/git/ref/%s", owner, repo, ref)
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var reference Reference
+	if err := json.NewDecoder(resp.Body).Decode(&reference); err != nil {
+		return nil, fmt.Errorf("failed to decode reference: %w", err)
+	}
+
+	return &reference, nil
+}
+
+// ListTagRefs fetches all references under refs/tags, following
+// pagination. Annotated tags point at their tag object.
+func (c *Client) ListTagRefs(ctx context.Context, owner, repo string) ([]*Reference, error) {
+	const perPage = 100
+
+	var tagRefs []*Reference
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("/repos/%s/%s/git/matching-refs/tags?page=%d&per_page=%d", owner, repo, page, perPage)
+		resp, err := c.doRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageRefs []*Reference
+		err = json.NewDecoder(resp.Body).Decode(&pageRefs)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tag references: %w", err)
+		}
+
+		tagRefs = append(tagRefs, pageRefs...)
+		if len(pageRefs) < perPage {
+			return tagRefs, nil
+		}
+	}
+}
+
+// CreateTag creates an annotated tag object. The tag is only visible once a
+// reference under refs/tags points at it.
+func (c *Client) CreateTag(ctx context.Context, owner, repo string, req CreateTagRequest) (*TagObject, error) {
+	path := fmt.Sprintf("/repos/%s/%s/git/tags", owner, repo)
+
+	resp, err := c.doRequest(ctx, "POST", path, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tag TagObject
+	if err := json.NewDecoder(resp.Body).Decode(&tag); err != nil {
+		return nil, fmt.Errorf("failed to decode tag response: %w", err)
+	}
+
+	return &tag, nil
+}
+
+// GetTag fetches an annotated tag object
+func (c *Client) GetTag(ctx context.Context, owner, repo, sha string) (*TagObject, error) {
+	path := fmt.Sprintf("/repos/%s/%s/git/tags/%s", owner, repo, sha)
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tag TagObject
+	if err := json.NewDecoder(resp.Body).Decode(&tag); err != nil {
+		return nil, fmt.Errorf("failed to decode tag: %w", err)
+	}
+
+	return &tag, nil
+}
+
 // GetFileContent fetches a file's content from a repository
 func (c *Client) GetFileContent(ctx context.Context, owner, repo, path, ref string) (*FileContent, error) {
 	apiPath := fmt.Sprintf("/repos/%s/%s/contents/%s", owner, repo, path)
@@ -521,11 +770,22 @@ func (c *Client) GetTree(ctx context.Context, owner, repo, sha string, recursive
 
 // DownloadFile downloads raw file content
 func (c *Client) DownloadFile(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	body, err := c.OpenFile(ctx, owner, repo, path, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// OpenFile opens the raw content of a file for streaming. The caller must
+// close it.
+func (c *Client) OpenFile(ctx context.Context, owner, repo, path, ref string) (io.ReadCloser, error) {
 	// Try using download_url first if available (faster, no base64 decoding needed)
 	// This is a direct raw content URL that doesn't count against API rate limits
 
 	// First try the raw content endpoint (doesn't count against API rate limit)
-	rawURL := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", owner, repo, ref, path)
+	rawURL := fmt.Sprintf("%s/%s/%s/%s/%s", c.rawURL, owner, repo, ref, path)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
 	if err == nil {
@@ -533,8 +793,7 @@ func (c *Client) DownloadFile(ctx context.Context, owner, repo, path, ref string
 
 		resp, err := c.httpClient.Do(req)
 		if err == nil && resp.StatusCode == 200 {
-			defer resp.Body.Close()
-			return io.ReadAll(resp.Body)
+			return resp.Body, nil
 		}
 		if resp != nil {
 			resp.Body.Close()
@@ -566,8 +825,7 @@ func (c *Client) DownloadFile(ctx context.Context, owner, repo, path, ref string
 
 			resp, err := c.httpClient.Do(req)
 			if err == nil {
-				defer resp.Body.Close()
-				return io.ReadAll(resp.Body)
+				return resp.Body, nil
 			}
 		}
 	}
@@ -578,10 +836,10 @@ func (c *Client) DownloadFile(ctx context.Context, owner, repo, path, ref string
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode base64 content: %w", err)
 		}
-		return decoded, nil
+		return io.NopCloser(bytes.NewReader(decoded)), nil
 	}
 
-	return []byte(content.Content), nil
+	return io.NopCloser(strings.NewReader(content.Content)), nil
 }
 
 // GetRateLimit returns current rate limit status
@@ -628,6 +886,30 @@ func (c *Client) UploadFile(ctx context.Context, owner, repo, path string, req F
 	return nil
 }
 
+// GetAuthenticatedUser returns the user the token belongs to, along with
+// the OAuth scopes granted to the token
+func (c *Client) GetAuthenticatedUser(ctx context.Context) (*User, error) {
+	resp, err := c.doRequest(ctx, "GET", "/user", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Fine-grained tokens do not report scopes
+	if scopes := resp.Header.Get("X-OAuth-Scopes"); scopes != "" {
+		for _, scope := range strings.Split(scopes, ",") {
+			user.Scopes = append(user.Scopes, strings.TrimSpace(scope))
+		}
+	}
+
+	return &user, nil
+}
+
 // TestAuth tests if authentication is working
 func (c *Client) TestAuth(ctx context.Context) error {
 	resp, err := c.doRequest(ctx, "GET", "/user", nil)
@@ -0,0 +1,78 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+// Divergence describes how a local timeline relates to its remote branch
+type Divergence struct {
+	RemoteSHA string // Current head of the remote branch
+	// LocalAhead is set when the local timeline has seals that the commit
+	// recorded for the remote branch at the last download, sync or fetch
+	// lacks: it is not that commit or one of its ancestors
+	LocalAhead bool
+	// RemoteMoved is set when the remote branch head is no longer the one
+	// recorded
+	RemoteMoved bool
+}
+
+// Diverged reports whether both sides have commits the other lacks
+func (d *Divergence) Diverged() bool {
+	return d.LocalAhead && d.RemoteMoved
+}
+
+// DivergedError stops a sync that would join a timeline and its remote
+// branch although neither contains the other
+type DivergedError struct {
+	Timeline  string
+	RemoteSHA string
+}
+
+func (e *DivergedError) Error() string {
+	return fmt.Sprintf("timeline '%s' has diverged from its remote branch (now at %s): both have seals the other lacks. Fuse the remote changes with 'ivaldi sync' (pull.rebase false) or 'ivaldi fuse', or upload the local seals first", e.Timeline, e.RemoteSHA[:min(7, len(e.RemoteSHA))])
+}
+
+// CheckDivergence compares a local timeline's tip with the remote branch
+// it syncs with. Without a recorded remote head, the local timeline is
+// taken to have no seals of its own.
+func (rs *RepoSyncer) CheckDivergence(ctx context.Context, owner, repo, branch string, local cas.Hash) (*Divergence, error) {
+	branchInfo, err := rs.client.GetBranch(ctx, owner, repo, branch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote branch info: %w", err)
+	}
+	return rs.divergence(branch, branchInfo.Commit.SHA, local)
+}
+
+// divergence compares a local timeline's tip with the remote branch head
+// remoteSHA, through the remote head recorded at the last sync
+func (rs *RepoSyncer) divergence(branch, remoteSHA string, local cas.Hash) (*Divergence, error) {
+	d := &Divergence{RemoteSHA: remoteSHA, RemoteMoved: true}
+
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	recorded, err := refsManager.GetTimeline(branch, refs.RemoteTimeline)
+	if err != nil {
+		return d, nil
+	}
+	d.RemoteMoved = recorded.GitSHA1Hash != d.RemoteSHA
+
+	recordedHash := cas.Hash(recorded.Blake3Hash)
+	if recordedHash == (cas.Hash{}) || recordedHash == local || local == (cas.Hash{}) {
+		return d, nil
+	}
+	contained, err := commit.NewCommitReader(rs.casStore).IsAncestor(local, recordedHash)
+	if err != nil {
+		return nil, err
+	}
+	d.LocalAhead = !contained
+	return d, nil
+}
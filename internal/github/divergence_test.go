@@ -0,0 +1,132 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+func TestCheckDivergence(t *testing.T) {
+	heads := map[string]string{"main": "a1a1a1a1a1", "other": "b1b1b1b1b1"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/repos/owner/repo/branches/")
+		var branch Branch
+		branch.Name = name
+		branch.Commit.SHA = heads[name]
+		json.NewEncoder(w).Encode(branch)
+	}))
+	defer server.Close()
+
+	casStore := cas.NewMemoryCAS()
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: t.TempDir(),
+		casStore:  casStore,
+	}
+
+	// main was last synced at base, and a local seal was made on top
+	base := createVerifyCommit(t, casStore, map[string]string{"a.txt": "alpha\n"})
+	builder := commit.NewCommitBuilder(casStore, history.NewMMR())
+	sealed, err := builder.CreateCommit(nil, []cas.Hash{base}, "Test <test@example.com>", "Test <test@example.com>", "Local work")
+	if err != nil {
+		t.Fatalf("CreateCommit failed: %v", err)
+	}
+	local := builder.GetCommitHash(sealed)
+	unrelated := createVerifyCommit(t, casStore, map[string]string{"b.txt": "beta\n"})
+	rs.recordRemoteHead("owner", "repo", "main", "a1a1a1a1a1", base)
+
+	tests := []struct {
+		name         string
+		branch, head string
+		local        cas.Hash
+		ahead, moved bool
+		wantDiverged bool
+	}{
+		{name: "in sync", branch: "main", head: "a1a1a1a1a1", local: base},
+		{name: "remote moved", branch: "main", head: "a2a2a2a2a2", local: base, moved: true},
+		{name: "local ahead", branch: "main", head: "a1a1a1a1a1", local: local, ahead: true},
+		{name: "diverged", branch: "main", head: "a2a2a2a2a2", local: local, ahead: true, moved: true, wantDiverged: true},
+		{name: "unrelated history", branch: "main", head: "a2a2a2a2a2", local: unrelated, ahead: true, moved: true, wantDiverged: true},
+		{name: "nothing recorded", branch: "other", head: "b1b1b1b1b1", local: local, moved: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heads[tt.branch] = tt.head
+			d, err := rs.CheckDivergence(context.Background(), "owner", "repo", tt.branch, tt.local)
+			if err != nil {
+				t.Fatalf("CheckDivergence failed: %v", err)
+			}
+			if d.RemoteSHA != tt.head || d.LocalAhead != tt.ahead || d.RemoteMoved != tt.moved || d.Diverged() != tt.wantDiverged {
+				t.Errorf("Unexpected divergence %+v (diverged: %v)", d, d.Diverged())
+			}
+		})
+	}
+}
+
+func TestSyncTimelineOnlyFastForwards(t *testing.T) {
+	head := "a1a1a1a1a1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/branches/main" {
+			t.Errorf("Unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		var branch Branch
+		branch.Name = "main"
+		branch.Commit.SHA = head
+		json.NewEncoder(w).Encode(branch)
+	}))
+	defer server.Close()
+
+	casStore := cas.NewMemoryCAS()
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: t.TempDir(),
+		workDir:   t.TempDir(),
+		casStore:  casStore,
+	}
+
+	base := createVerifyCommit(t, casStore, map[string]string{"a.txt": "alpha\n"})
+	builder := commit.NewCommitBuilder(casStore, history.NewMMR())
+	sealed, err := builder.CreateCommit(nil, []cas.Hash{base}, "Test <test@example.com>", "Test <test@example.com>", "Local work")
+	if err != nil {
+		t.Fatalf("CreateCommit failed: %v", err)
+	}
+	local := builder.GetCommitHash(sealed)
+	rs.recordRemoteHead("owner", "repo", "main", head, base)
+
+	// Local seals on an unchanged remote are left alone
+	delta, err := rs.SyncTimeline(context.Background(), "owner", "repo", "main", local)
+	if err != nil || !delta.NoChanges {
+		t.Fatalf("Expected no changes for a timeline ahead of its remote, got %+v (%v)", delta, err)
+	}
+
+	// Once the remote moves too, the sync stops instead of committing
+	head = "a2a2a2a2a2"
+	_, err = rs.SyncTimeline(context.Background(), "owner", "repo", "main", local)
+	var diverged *DivergedError
+	if !errors.As(err, &diverged) || diverged.Timeline != "main" || diverged.RemoteSHA != head {
+		t.Fatalf("Expected a divergence error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "fuse") {
+		t.Errorf("Expected the error to suggest fusing, got %q", err)
+	}
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer refsManager.Close()
+	recorded, err := refsManager.GetTimeline("main", refs.RemoteTimeline)
+	if err != nil || cas.Hash(recorded.Blake3Hash) != base {
+		t.Errorf("Expected the recorded remote head to stay at the last sync, got %v (%v)", recorded, err)
+	}
+}
@@ -0,0 +1,217 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"lukechampine.com/blake3"
+)
+
+// maxReportedDownloadErrors is how many download errors are shown; the
+// rest are only counted
+const maxReportedDownloadErrors = 3
+
+// downloadResult is the outcome of downloading one file
+type downloadResult struct {
+	path string
+	err  error
+}
+
+// downloadFiles downloads all files from a GitHub tree with optimized performance.
+//
+// Memory does not grow with the number of files: the entries to download
+// are fed to the workers through a bounded channel straight from the tree,
+// and their results are aggregated as they arrive, keeping only counts and
+// the errors that are shown. Each file goes to disk and CAS as soon as it
+// is downloaded.
+func (rs *RepoSyncer) downloadFiles(ctx context.Context, owner, repo string, tree *Tree, ref string) error {
+	// Files that already exist locally are skipped
+	needsDownload := func(entry TreeEntry) bool {
+		if entry.Type != "blob" {
+			return false
+		}
+		if entry.SHA == "" {
+			return true
+		}
+		// This is a simple optimization - could be enhanced with SHA comparison
+		info, err := os.Stat(filepath.Join(rs.workDir, entry.Path))
+		return err != nil || info.IsDir()
+	}
+
+	totalFiles, toDownload := 0, 0
+	for _, entry := range tree.Tree {
+		if entry.Type != "blob" {
+			continue
+		}
+		totalFiles++
+		if needsDownload(entry) {
+			toDownload++
+		}
+	}
+
+	if toDownload == 0 {
+		fmt.Printf("All %d files already exist locally, nothing to download\n", totalFiles)
+		return nil
+	}
+
+	fmt.Printf("Downloading %d files (%d already exist locally)...\n", toDownload, totalFiles-toDownload)
+
+	// Dynamic worker count based on number of files, capped at 32 to avoid
+	// overwhelming the API
+	workers := 8
+	if toDownload > 100 {
+		workers = 16
+	}
+	if toDownload > 500 {
+		workers = 32
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan TreeEntry, workers)
+	results := make(chan downloadResult, workers)
+
+	// Feed the workers from the tree. The skip check runs again rather than
+	// keeping the first pass's list; the files it looks at are not written
+	// until they are handed out.
+	go func() {
+		defer close(jobs)
+		for _, entry := range tree.Tree {
+			if !needsDownload(entry) {
+				continue
+			}
+			select {
+			case jobs <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				results <- downloadResult{path: entry.Path, err: rs.downloadFile(ctx, owner, repo, entry, ref)}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	downloaded, failed := 0, 0
+	var shownErrors []error
+	for result := range results {
+		if result.err != nil {
+			failed++
+			if len(shownErrors) < maxReportedDownloadErrors {
+				shownErrors = append(shownErrors, fmt.Errorf("failed to download %s: %w", result.path, result.err))
+			}
+			continue
+		}
+		downloaded++
+		// Update progress every 10 files or at completion
+		if downloaded%10 == 0 || downloaded == toDownload {
+			percentage := (downloaded * 100) / toDownload
+			fmt.Printf("\rProgress: %d/%d files (%d%%)...", downloaded, toDownload, percentage)
+		}
+	}
+	fmt.Println() // New line after progress
+
+	if failed > 0 {
+		fmt.Printf("\nWarning: %d download errors occurred\n", failed)
+		for _, err := range shownErrors {
+			fmt.Printf("  - %v\n", err)
+		}
+		if failed > len(shownErrors) {
+			fmt.Printf("  ... and %d more errors\n", failed-len(shownErrors))
+		}
+		return fmt.Errorf("failed to download %d files", failed)
+	}
+	if err := ctx.Err(); err != nil && downloaded < toDownload {
+		return err
+	}
+
+	fmt.Printf("Successfully downloaded %d files\n", downloaded)
+	return nil
+}
+
+// downloadFile downloads a single file from GitHub, streaming it to the
+// working file and CAS
+func (rs *RepoSyncer) downloadFile(ctx context.Context, owner, repo string, entry TreeEntry, ref string) error {
+	// Check rate limits
+	if rs.client.IsRateLimited() {
+		rs.client.WaitForRateLimit()
+	}
+
+	body, err := rs.client.OpenFile(ctx, owner, repo, entry.Path, ref)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// Create local file
+	localPath := filepath.Join(rs.workDir, entry.Path)
+
+	// Ensure directory exists
+	dir := filepath.Dir(localPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write the file under a temporary name first, so an interrupted clone
+	// leaves no partial file that a retry would skip as already downloaded.
+	// The content is hashed on its way to disk rather than held in memory.
+	tmp, err := os.CreateTemp(dir, ".ivaldi-download-*")
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	hasher := blake3.New(32, nil)
+	var hashers io.Writer = hasher
+	var blob *blobHasher
+	if rs.fsckObjects {
+		blob = newBlobHasher(entry)
+		hashers = io.MultiWriter(hasher, blob)
+	}
+	_, writeErr := io.Copy(tmp, io.TeeReader(body, hashers))
+	closeErr := tmp.Close()
+	if err := errors.Join(writeErr, closeErr, os.Chmod(tmp.Name(), 0644)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if blob != nil {
+		if err := blob.check(); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	var hash cas.Hash
+	copy(hash[:], hasher.Sum(nil))
+
+	// Store in CAS for deduplication, streamed from the file just written so
+	// the content is never buffered whole. Failure is non-fatal, the file is
+	// written to disk either way.
+	if content, err := os.Open(tmp.Name()); err == nil {
+		_ = cas.PutReader(rs.casStore, hash, content)
+		content.Close()
+	}
+
+	if err := os.Rename(tmp.Name(), localPath); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	// No verbose output per file
+	return nil
+}
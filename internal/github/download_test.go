@@ -0,0 +1,141 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+// fakeRawFiles serves the content of every file as its path, except for
+// files named bad*, which are missing
+func fakeRawFiles() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/owner/repo/ref/")
+		if path == r.URL.Path || strings.HasPrefix(filepath.Base(path), "bad") {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, path)
+	}))
+}
+
+func syntheticTree(files int, prefix string) *Tree {
+	tree := &Tree{Tree: make([]TreeEntry, 0, files)}
+	for i := 0; i < files; i++ {
+		tree.Tree = append(tree.Tree, TreeEntry{
+			Path: fmt.Sprintf("dir%03d/%sfile%06d.txt", i%100, prefix, i),
+			Type: "blob",
+			SHA:  fmt.Sprintf("%040d", i),
+		})
+	}
+	return tree
+}
+
+func newDownloadSyncer(serverURL, workDir string) *RepoSyncer {
+	return &RepoSyncer{
+		client:   NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: serverURL, RawURL: serverURL}, "token"),
+		workDir:  workDir,
+		casStore: cas.NewMemoryCAS(),
+	}
+}
+
+func TestDownloadFilesCapsErrors(t *testing.T) {
+	server := fakeRawFiles()
+	defer server.Close()
+
+	workDir := t.TempDir()
+	rs := newDownloadSyncer(server.URL, workDir)
+
+	tree := syntheticTree(50, "")
+	tree.Tree = append(tree.Tree, syntheticTree(5, "bad").Tree...)
+	tree.Tree = append(tree.Tree, TreeEntry{Path: "dir000", Type: "tree"})
+
+	err := rs.downloadFiles(context.Background(), "owner", "repo", tree, "ref")
+	if err == nil || err.Error() != "failed to download 5 files" {
+		t.Fatalf("Expected 5 failed downloads, got %v", err)
+	}
+
+	for _, entry := range tree.Tree[:50] {
+		content, err := os.ReadFile(filepath.Join(workDir, entry.Path))
+		if err != nil || string(content) != entry.Path {
+			t.Fatalf("Expected %s to be downloaded, got %q (%v)", entry.Path, content, err)
+		}
+		if has, _ := rs.casStore.Has(cas.SumB3(content)); !has {
+			t.Fatalf("Expected %s to be stored in CAS", entry.Path)
+		}
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(workDir, "*", ".ivaldi-download-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("Expected no temporary files, got %v", leftovers)
+	}
+
+	// A second run only fetches what is still missing
+	if err := rs.downloadFiles(context.Background(), "owner", "repo", syntheticTree(50, ""), "ref"); err != nil {
+		t.Errorf("Expected existing files to be skipped, got %v", err)
+	}
+}
+
+// BenchmarkDownloadFilesLargeTree measures a clone of a synthetic tree and
+// reports the peak heap growth during the download. Apart from the memory
+// CAS the files are stored in, nothing is sized by the number of files.
+func BenchmarkDownloadFilesLargeTree(b *testing.B) {
+	for _, files := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("files=%d", files), func(b *testing.B) {
+			server := fakeRawFiles()
+			defer server.Close()
+			tree := syntheticTree(files, "")
+
+			stdout := os.Stdout
+			os.Stdout, _ = os.Open(os.DevNull)
+			defer func() { os.Stdout = stdout }()
+
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				rs := newDownloadSyncer(server.URL, b.TempDir())
+				runtime.GC()
+				var before runtime.MemStats
+				runtime.ReadMemStats(&before)
+
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					if err := rs.downloadFiles(context.Background(), "owner", "repo", tree, "ref"); err != nil {
+						b.Error(err)
+					}
+				}()
+				if heap := samplePeakHeap(done); heap > before.HeapInuse {
+					peak = max(peak, heap-before.HeapInuse)
+				}
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+		})
+	}
+}
+
+// samplePeakHeap samples the heap in use every millisecond until done is
+// closed and returns the largest value seen
+func samplePeakHeap(done <-chan struct{}) uint64 {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	var stats runtime.MemStats
+	var peak uint64
+	for {
+		select {
+		case <-done:
+			return peak
+		case <-ticker.C:
+			runtime.ReadMemStats(&stats)
+			peak = max(peak, stats.HeapInuse)
+		}
+	}
+}
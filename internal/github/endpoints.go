@@ -0,0 +1,84 @@
+package github
+
+import (
+	"os"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+)
+
+const (
+	// DefaultHost is the web host of public GitHub
+	DefaultHost = "github.com"
+	// DefaultRawURL serves raw file content for public GitHub
+	DefaultRawURL = "https://raw.githubusercontent.com"
+)
+
+// Endpoints are the URLs Ivaldi uses to talk to a GitHub server
+type Endpoints struct {
+	Host   string // Web host, e.g. "github.com"
+	APIURL string // REST API base URL
+	RawURL string // Raw file content base URL
+}
+
+// IsEnterprise reports whether the endpoints belong to a GitHub
+// Enterprise server rather than public GitHub
+func (e Endpoints) IsEnterprise() bool {
+	return !strings.EqualFold(e.Host, DefaultHost)
+}
+
+// EndpointsForHost returns the default endpoints of a GitHub server.
+// Enterprise servers serve the API under /api/v3 and raw files under /raw.
+func EndpointsForHost(host string) Endpoints {
+	host = normalizeHost(host)
+	if host == "" || strings.EqualFold(host, DefaultHost) {
+		return Endpoints{Host: DefaultHost, APIURL: GitHubAPIURL, RawURL: DefaultRawURL}
+	}
+	return Endpoints{
+		Host:   host,
+		APIURL: "https://" + host + "/api/v3",
+		RawURL: "https://" + host + "/raw",
+	}
+}
+
+// ResolveEndpoints returns the endpoints configured for the current
+// repository. GITHUB_SERVER_URL, GITHUB_API_URL and GITHUB_RAW_URL take
+// precedence over the github.host, github.apiurl and github.rawurl config
+// keys; anything left unset defaults to public GitHub.
+func ResolveEndpoints() Endpoints {
+	var gh config.GitHubConfig
+	if cfg, err := config.LoadConfig(); err == nil {
+		gh = cfg.GitHub
+	}
+
+	host := gh.Host
+	if server := os.Getenv("GITHUB_SERVER_URL"); server != "" {
+		host = server
+	}
+
+	endpoints := EndpointsForHost(host)
+	if gh.APIURL != "" {
+		endpoints.APIURL = gh.APIURL
+	}
+	if gh.RawURL != "" {
+		endpoints.RawURL = gh.RawURL
+	}
+	if apiURL := os.Getenv("GITHUB_API_URL"); apiURL != "" {
+		endpoints.APIURL = apiURL
+	}
+	if rawURL := os.Getenv("GITHUB_RAW_URL"); rawURL != "" {
+		endpoints.RawURL = rawURL
+	}
+
+	endpoints.APIURL = strings.TrimSuffix(endpoints.APIURL, "/")
+	endpoints.RawURL = strings.TrimSuffix(endpoints.RawURL, "/")
+	return endpoints
+}
+
+// normalizeHost strips a scheme and trailing slash from a host or server URL
+func normalizeHost(host string) string {
+	host = strings.TrimSpace(host)
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	return strings.TrimSuffix(host, "/")
+}
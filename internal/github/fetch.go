@@ -0,0 +1,527 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/seals"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// fetchPageSize is the number of commits requested per page of history
+const fetchPageSize = 100
+
+// Outcomes for the local timeline after a fetch
+const (
+	LocalCreated       = "created"
+	LocalFastForwarded = "fast-forwarded"
+	LocalUpToDate      = "up-to-date"
+	LocalCheckedOut    = "checked-out" // Could fast-forward, but is checked out
+	LocalDiverged      = "diverged"
+)
+
+// FetchResult summarizes a history fetch
+type FetchResult struct {
+	TipSHA   string
+	TipHash  cas.Hash
+	Imported int  // Commits imported by this fetch
+	Shallow  bool // History was cut off at the depth limit
+	// Local describes what happened to the local timeline of the same name
+	Local string
+}
+
+// fetchedBlob is a file already stored during a fetch
+type fetchedBlob struct {
+	ref      filechunk.NodeRef
+	checksum cas.Hash
+}
+
+// blobCache holds the files stored during a fetch by Git blob SHA. Files
+// are chunked with the profile of their path, so content that appears under
+// paths with different profiles is stored once for each.
+type blobCache struct {
+	rules *filechunk.ProfileRules
+	blobs map[string]fetchedBlob
+}
+
+func (bc *blobCache) key(sha, path string) string {
+	return sha + " " + bc.rules.Profile(path)
+}
+
+// historyWalk collects the commits of a branch that still need importing.
+// Commits already imported, by this or an earlier fetch, end the walk.
+type historyWalk struct {
+	refsManager *refs.RefsManager
+	depth       int
+
+	commits     map[string]*RepoCommit // To import
+	known       map[string]cas.Hash    // Already imported, by Git SHA
+	generations map[string]int         // Distance from the tip, starting at 1
+	pending     map[string]int         // Needed but not listed yet, with generation
+	listed      map[string]*RepoCommit // Listed before anything needed them
+	shallow     bool
+}
+
+// need marks a commit as part of the history to import
+func (w *historyWalk) need(sha string, generation int) {
+	if _, ok := w.commits[sha]; ok {
+		return
+	}
+	if _, ok := w.known[sha]; ok {
+		return
+	}
+	if hash, _, err := w.refsManager.LookupByGitHash(sha); err == nil && hash != [32]byte{} {
+		w.known[sha] = cas.Hash(hash)
+		return
+	}
+	if w.depth > 0 && generation > w.depth {
+		w.shallow = true
+		return
+	}
+	if c, ok := w.listed[sha]; ok {
+		delete(w.listed, sha)
+		w.add(c, generation)
+		return
+	}
+	if g, ok := w.pending[sha]; !ok || generation < g {
+		w.pending[sha] = generation
+	}
+}
+
+// add records a needed commit and walks on to its parents
+func (w *historyWalk) add(c *RepoCommit, generation int) {
+	w.commits[c.SHA] = c
+	w.generations[c.SHA] = generation
+	for _, parent := range c.Parents {
+		w.need(parent.SHA, generation+1)
+	}
+}
+
+// FetchHistory imports the history of a remote branch commit by commit,
+// keeping authors, times, messages and parents. Commits imported earlier are
+// recognized by their Git SHA and not fetched again. A positive depth limits
+// the import to that many generations from the tip; older parents are left
+// out. The remote timeline is pointed at the tip, and the local timeline of
+// the same name is created or fast-forwarded unless it is checked out.
+func (rs *RepoSyncer) FetchHistory(ctx context.Context, owner, repo, branch string, depth int) (*FetchResult, error) {
+	branchInfo, err := rs.client.GetBranch(ctx, owner, repo, branch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branch info: %w", err)
+	}
+	return rs.importHistory(ctx, owner, repo, branch, branchInfo.Commit.SHA, depth, branch, false)
+}
+
+// importHistory imports the history of a branch up to tipSHA and advances
+// the local timeline named local. With moveCheckedOut the local timeline is
+// fast-forwarded even when it is checked out; the caller then updates the
+// workspace.
+func (rs *RepoSyncer) importHistory(ctx context.Context, owner, repo, branch, tipSHA string, depth int, local string, moveCheckedOut bool) (*FetchResult, error) {
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	walk := &historyWalk{
+		refsManager: refsManager,
+		depth:       depth,
+		commits:     make(map[string]*RepoCommit),
+		known:       make(map[string]cas.Hash),
+		generations: make(map[string]int),
+		pending:     make(map[string]int),
+		listed:      make(map[string]*RepoCommit),
+	}
+	walk.need(tipSHA, 1)
+
+	// The history listing is newest first, so parents normally show up
+	// after the commits that need them
+	scanned := false
+	for page := 1; len(walk.pending) > 0; page++ {
+		if rs.client.IsRateLimited() {
+			rs.client.WaitForRateLimit()
+		}
+		commits, err := rs.client.ListCommits(ctx, owner, repo, tipSHA, page, fetchPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commits: %w", err)
+		}
+		for _, c := range commits {
+			if rs.fsckObjects {
+				if err := checkCommit(c); err != nil {
+					return nil, err
+				}
+			}
+			if generation, ok := walk.pending[c.SHA]; ok {
+				delete(walk.pending, c.SHA)
+				walk.add(c, generation)
+			} else if _, ok := walk.commits[c.SHA]; !ok {
+				walk.listed[c.SHA] = c
+			}
+		}
+		fmt.Printf("\rScanning history: %d commit(s) to import...", len(walk.commits))
+		scanned = true
+		if len(commits) < fetchPageSize {
+			break
+		}
+	}
+	if scanned {
+		fmt.Println()
+	}
+
+	for sha := range walk.pending {
+		return nil, fmt.Errorf("commit %s is missing from the history of %s", sha[:7], branch)
+	}
+
+	result := &FetchResult{TipSHA: tipSHA, Shallow: walk.shallow}
+	imported, err := rs.importCommits(ctx, owner, repo, refsManager, walk)
+	if err != nil {
+		return nil, err
+	}
+	result.Imported = len(walk.commits)
+
+	if hash, ok := imported[tipSHA]; ok {
+		result.TipHash = hash
+	} else {
+		result.TipHash = walk.known[tipSHA]
+	}
+
+	rs.recordRemoteHead(owner, repo, branch, tipSHA, result.TipHash)
+
+	result.Local, err = rs.advanceLocalTimeline(refsManager, local, tipSHA, result.TipHash, moveCheckedOut)
+	if err != nil {
+		return nil, err
+	}
+	if result.Local == LocalCreated {
+		rs.trackUpstream(local, owner, repo, branch)
+	}
+	return result, nil
+}
+
+// importCommits creates Ivaldi commits for the walked history, parents
+// first, and returns their hashes by Git SHA
+func (rs *RepoSyncer) importCommits(ctx context.Context, owner, repo string, refsManager *refs.RefsManager, walk *historyWalk) (_ map[string]cas.Hash, err error) {
+	imported := make(map[string]cas.Hash)
+	if len(walk.commits) == 0 {
+		return imported, nil
+	}
+
+	// Order parents before children
+	var order []*RepoCommit
+	visited := make(map[string]bool)
+	var visit func(sha string)
+	visit = func(sha string) {
+		c, ok := walk.commits[sha]
+		if !ok || visited[sha] {
+			return
+		}
+		visited[sha] = true
+		for _, parent := range c.Parents {
+			visit(parent.SHA)
+		}
+		order = append(order, c)
+	}
+	shas := make([]string, 0, len(walk.commits))
+	for sha := range walk.commits {
+		shas = append(shas, sha)
+	}
+	// Oldest generation first keeps the order stable between runs
+	sort.Slice(shas, func(i, j int) bool {
+		gi, gj := walk.generations[shas[i]], walk.generations[shas[j]]
+		if gi != gj {
+			return gi > gj
+		}
+		return shas[i] < shas[j]
+	})
+	for _, sha := range shas {
+		visit(sha)
+	}
+
+	mmr, err := history.NewPersistentMMR(rs.casStore, rs.ivaldiDir)
+	if err != nil {
+		mmr = &history.PersistentMMR{MMR: history.NewMMR()}
+	}
+	defer mmr.Close()
+	// Commits are appended to the history in memory and saved in one go,
+	// also when the import stops early, since the seals made so far stay
+	commitBuilder := commit.NewCommitBuilder(rs.casStore, mmr.MMR)
+	defer func() {
+		if flushErr := mmr.Flush(); flushErr != nil && err == nil {
+			err = fmt.Errorf("failed to save history: %w", flushErr)
+		}
+	}()
+
+	chunkRules, err := filechunk.LoadProfileRules(rs.workDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chunk profiles: %w", err)
+	}
+	blobs := &blobCache{rules: chunkRules, blobs: make(map[string]fetchedBlob)}
+	for _, hash := range walk.known {
+		if err := rs.cacheCommitBlobs(hash, blobs); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, c := range order {
+		fmt.Printf("\rImporting commits: %d/%d", i+1, len(order))
+
+		files, err := rs.fetchCommitFiles(ctx, owner, repo, c, blobs)
+		if err != nil {
+			fmt.Println()
+			return nil, fmt.Errorf("failed to import commit %s: %w", c.SHA[:7], err)
+		}
+
+		var parents []cas.Hash
+		for _, parent := range c.Parents {
+			if hash, ok := imported[parent.SHA]; ok {
+				parents = append(parents, hash)
+			} else if hash, ok := walk.known[parent.SHA]; ok {
+				parents = append(parents, hash)
+			}
+		}
+
+		author := c.Commit.Author
+		committer := c.Commit.Committer
+		commitObj, err := commitBuilder.CreateCommitAt(
+			files,
+			parents,
+			fmt.Sprintf("%s <%s>", author.Name, author.Email),
+			fmt.Sprintf("%s <%s>", committer.Name, committer.Email),
+			c.Commit.Message,
+			author.Date,
+			committer.Date,
+		)
+		if err != nil {
+			fmt.Println()
+			return nil, fmt.Errorf("failed to create commit for %s: %w", c.SHA[:7], err)
+		}
+
+		hash := commitBuilder.GetCommitHash(commitObj)
+		imported[c.SHA] = hash
+
+		var hashArray [32]byte
+		copy(hashArray[:], hash[:])
+		if err := refsManager.MapGitHashToBlake3(c.SHA, hashArray, [32]byte{}); err != nil {
+			fmt.Println()
+			return nil, fmt.Errorf("failed to record commit %s: %w", c.SHA[:7], err)
+		}
+		if err := refsManager.StoreSealName(seals.GenerateSealName(hashArray), hashArray, c.Commit.Message); err != nil {
+			fmt.Printf("\nWarning: failed to store seal name for %s: %v\n", c.SHA[:7], err)
+		}
+	}
+	fmt.Println()
+
+	return imported, nil
+}
+
+// fetchCommitFiles lists the files of a remote commit, downloading only
+// blobs that have not been stored yet
+func (rs *RepoSyncer) fetchCommitFiles(ctx context.Context, owner, repo string, c *RepoCommit, blobs *blobCache) ([]wsindex.FileMetadata, error) {
+	tree, err := rs.getTree(ctx, owner, repo, c.Commit.Tree.SHA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree: %w", err)
+	}
+	if tree.Truncated {
+		return nil, fmt.Errorf("tree %s is too large to list", c.Commit.Tree.SHA[:7])
+	}
+	return rs.fetchTreeFiles(ctx, owner, repo, c.SHA, tree, c.Commit.Committer.Date, blobs)
+}
+
+// fetchTreeFiles lists the files of a tree at ref, downloading only blobs
+// that have not been stored yet. Files are stored in CAS as they arrive,
+// without going through the working tree.
+func (rs *RepoSyncer) fetchTreeFiles(ctx context.Context, owner, repo, ref string, tree *Tree, modTime time.Time, blobs *blobCache) ([]wsindex.FileMetadata, error) {
+	var missing []TreeEntry
+	queued := make(map[string]bool)
+	for _, entry := range tree.Tree {
+		if entry.Type != "blob" {
+			continue
+		}
+		key := blobs.key(entry.SHA, entry.Path)
+		if _, ok := blobs.blobs[key]; !ok && !queued[key] {
+			queued[key] = true
+			missing = append(missing, entry)
+		}
+	}
+
+	if err := rs.downloadBlobs(ctx, owner, repo, ref, missing, blobs); err != nil {
+		return nil, err
+	}
+
+	var files []wsindex.FileMetadata
+	for _, entry := range tree.Tree {
+		if entry.Type != "blob" {
+			continue
+		}
+		blob := blobs.blobs[blobs.key(entry.SHA, entry.Path)]
+		files = append(files, wsindex.FileMetadata{
+			Path:     entry.Path,
+			FileRef:  blob.ref,
+			ModTime:  modTime,
+			Mode:     0644,
+			Size:     blob.ref.Size,
+			Checksum: blob.checksum,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// downloadBlobs downloads files at a commit concurrently and stores them
+func (rs *RepoSyncer) downloadBlobs(ctx context.Context, owner, repo, ref string, entries []TreeEntry, blobs *blobCache) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	jobs := make(chan TreeEntry)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+
+	workers := min(8, len(entries))
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				if rs.client.IsRateLimited() {
+					rs.client.WaitForRateLimit()
+				}
+				content, err := rs.client.DownloadFile(ctx, owner, repo, entry.Path, ref)
+				if err == nil && rs.fsckObjects {
+					err = checkBlob(entry, content)
+				}
+				var fileRef filechunk.NodeRef
+				if err == nil {
+					builder := filechunk.NewBuilder(rs.casStore, blobs.rules.Params(entry.Path))
+					fileRef, err = builder.Build(content)
+				}
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("failed to download %s: %w", entry.Path, err)
+					}
+				} else {
+					blobs.blobs[blobs.key(entry.SHA, entry.Path)] = fetchedBlob{ref: fileRef, checksum: cas.SumB3(content)}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, entry := range entries {
+		jobs <- entry
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
+
+// cacheCommitBlobs adds the files of an imported commit to the blob cache,
+// so a fetch that continues from it only downloads what changed since
+func (rs *RepoSyncer) cacheCommitBlobs(hash cas.Hash, blobs *blobCache) error {
+	reader := commit.NewCommitReader(rs.casStore)
+	commitObj, err := reader.ReadCommit(hash)
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %w", hash.String()[:8], err)
+	}
+	fileRefs, err := reader.FileRefs(commitObj)
+	if err != nil {
+		return fmt.Errorf("failed to list files of %s: %w", hash.String()[:8], err)
+	}
+
+	loader := filechunk.NewLoader(rs.casStore)
+	for path, ref := range fileRefs {
+		content, err := loader.ReadAll(ref)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		blobs.blobs[blobs.key(computeGitBlobSHA(content), path)] = fetchedBlob{ref: ref, checksum: cas.SumB3(content)}
+	}
+	return nil
+}
+
+// advanceLocalTimeline points a local timeline at the fetched tip when that
+// is safe, and reports what it did. The checked-out timeline is only moved
+// with moveCheckedOut.
+func (rs *RepoSyncer) advanceLocalTimeline(refsManager *refs.RefsManager, name, tipSHA string, tipHash cas.Hash, moveCheckedOut bool) (string, error) {
+	var hashArray [32]byte
+	copy(hashArray[:], tipHash[:])
+
+	local, err := refsManager.GetTimeline(name, refs.LocalTimeline)
+	if err != nil {
+		description := fmt.Sprintf("Fetched from GitHub (SHA: %s)", tipSHA[:7])
+		if err := refsManager.CreateTimeline(name, refs.LocalTimeline, hashArray, [32]byte{}, tipSHA, description); err != nil {
+			return "", fmt.Errorf("failed to create timeline: %w", err)
+		}
+		return LocalCreated, nil
+	}
+
+	localHash := cas.Hash(local.Blake3Hash)
+	if localHash == tipHash {
+		return LocalUpToDate, nil
+	}
+	if localHash != (cas.Hash{}) {
+		ancestor, err := commit.NewCommitReader(rs.casStore).IsAncestor(localHash, tipHash)
+		if err != nil {
+			return "", err
+		}
+		if !ancestor {
+			return LocalDiverged, nil
+		}
+	}
+
+	if current, err := refsManager.GetCurrentTimeline(); err == nil && current == name && !moveCheckedOut {
+		return LocalCheckedOut, nil
+	}
+	if err := refsManager.UpdateTimeline(name, refs.LocalTimeline, hashArray, local.SHA256Hash, tipSHA); err != nil {
+		return "", fmt.Errorf("failed to update timeline: %w", err)
+	}
+	return LocalFastForwarded, nil
+}
+
+// PruneRemoteTimelines removes the remote timelines whose branch no longer
+// exists on GitHub and returns their names, sorted. Local timelines are left
+// alone, including ones that were created from a pruned branch.
+func (rs *RepoSyncer) PruneRemoteTimelines(ctx context.Context, owner, repo string) ([]string, error) {
+	branches, err := rs.client.ListBranches(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+	upstream := make(map[string]bool, len(branches))
+	for _, branch := range branches {
+		upstream[branch.Name] = true
+	}
+
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	remotes, err := refsManager.ListRemoteTimelines()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote timelines: %w", err)
+	}
+
+	var pruned []string
+	for _, timeline := range remotes {
+		if upstream[timeline.Name] {
+			continue
+		}
+		if err := refsManager.RemoveTimeline(timeline.Name, refs.RemoteTimeline); err != nil {
+			return pruned, fmt.Errorf("failed to remove remote timeline %s: %w", timeline.Name, err)
+		}
+		pruned = append(pruned, timeline.Name)
+	}
+	sort.Strings(pruned)
+	return pruned, nil
+}
@@ -0,0 +1,125 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+func TestPruneRemoteTimelines(t *testing.T) {
+	ivaldiDir := t.TempDir()
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	for _, name := range []string{"main", "gone", "feature/old", "branch-120"} {
+		if err := refsManager.CreateRemoteTimeline(name, "abc123", ""); err != nil {
+			t.Fatalf("CreateRemoteTimeline failed: %v", err)
+		}
+	}
+	if err := refsManager.CreateTimeline("gone", refs.LocalTimeline, [32]byte{1}, [32]byte{}, "", ""); err != nil {
+		t.Fatalf("CreateTimeline failed: %v", err)
+	}
+	refsManager.Close()
+
+	// More branches than fit on one page, so pruning must read every page
+	var branches []Branch
+	branches = append(branches, Branch{Name: "main"})
+	for i := 0; i < 150; i++ {
+		branches = append(branches, Branch{Name: fmt.Sprintf("branch-%d", i)})
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		start, end := (page-1)*perPage, page*perPage
+		if start > len(branches) {
+			start = len(branches)
+		}
+		if end > len(branches) {
+			end = len(branches)
+		}
+		json.NewEncoder(w).Encode(branches[start:end])
+	}))
+	defer server.Close()
+
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: ivaldiDir,
+	}
+
+	pruned, err := rs.PruneRemoteTimelines(context.Background(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("PruneRemoteTimelines failed: %v", err)
+	}
+	if want := []string{"feature/old", "gone"}; !reflect.DeepEqual(pruned, want) {
+		t.Errorf("Expected %v to be pruned, got %v", want, pruned)
+	}
+
+	refsManager, err = refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer refsManager.Close()
+	if !refsManager.TimelineExists("main", refs.RemoteTimeline) || !refsManager.TimelineExists("branch-120", refs.RemoteTimeline) {
+		t.Error("Expected remote timelines of existing branches to be kept")
+	}
+	if refsManager.TimelineExists("gone", refs.RemoteTimeline) {
+		t.Error("Expected remote timeline gone to be removed")
+	}
+	if !refsManager.TimelineExists("gone", refs.LocalTimeline) {
+		t.Error("Expected local timeline gone to be kept")
+	}
+}
+
+func TestTrackUpstream(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	if err := os.Mkdir(".ivaldi", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	rs := &RepoSyncer{ivaldiDir: filepath.Join(dir, ".ivaldi")}
+	upstream := func(timeline string) string {
+		remote, merge, err := config.GetUpstream(timeline)
+		if err != nil {
+			t.Fatalf("GetUpstream failed: %v", err)
+		}
+		return remote + ":" + merge
+	}
+
+	rs.trackUpstream("main", "owner", "repo", "master")
+	if got := upstream("main"); got != "owner/repo:master" {
+		t.Errorf("main tracks %q, want owner/repo:master", got)
+	}
+
+	// An upstream already set is kept
+	rs.trackUpstream("main", "other", "fork", "main")
+	if got := upstream("main"); got != "owner/repo:master" {
+		t.Errorf("main tracks %q after a second setup, want owner/repo:master", got)
+	}
+
+	// Another repository's settings are left alone
+	other := &RepoSyncer{ivaldiDir: t.TempDir()}
+	other.trackUpstream("feature", "owner", "repo", "feature")
+	if got := upstream("feature"); got != ":" {
+		t.Errorf("feature tracks %q through another repository's syncer", got)
+	}
+
+	if err := config.SetValue("branch.autoSetupMerge", "false", false); err != nil {
+		t.Fatalf("SetValue failed: %v", err)
+	}
+	rs.trackUpstream("feature", "owner", "repo", "feature")
+	if got := upstream("feature"); got != ":" {
+		t.Errorf("feature tracks %q with branch.autoSetupMerge false", got)
+	}
+}
@@ -0,0 +1,136 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+// UpstreamTarget is a local timeline and the remote branch it tracks
+type UpstreamTarget struct {
+	Timeline string
+	Owner    string
+	Repo     string
+	Branch   string
+	// MoveCheckedOut lets the fetch fast-forward the timeline even though
+	// it is checked out. The caller must have made sure the workspace has
+	// no changes and update it afterwards.
+	MoveCheckedOut bool
+}
+
+// UpstreamResult is the outcome of fetching one upstream
+type UpstreamResult struct {
+	Target UpstreamTarget
+	Fetch  *FetchResult
+	// Delta lists the files that changed on the local timeline; nil unless
+	// the timeline was fast-forwarded
+	Delta *TimelineDelta
+	Err   error
+}
+
+// FetchUpstreams fetches the upstream branch of each target into its local
+// timeline, as FetchHistory does for a single branch, with at most jobs
+// targets in flight. Branch lookups run concurrently, while imports take
+// turns so that history shared between timelines is imported only once.
+// Results are returned in the order of the targets.
+func (rs *RepoSyncer) FetchUpstreams(ctx context.Context, targets []UpstreamTarget, depth, jobs int) []UpstreamResult {
+	results := make([]UpstreamResult, len(targets))
+	if len(targets) == 0 {
+		return results
+	}
+
+	indexes := make(chan int)
+	var importMu sync.Mutex
+	var wg sync.WaitGroup
+
+	workers := min(max(jobs, 1), len(targets))
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = rs.fetchUpstream(ctx, targets[i], depth, &importMu)
+			}
+		}()
+	}
+
+	for i := range targets {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// fetchUpstream fetches one target, holding importMu while importing
+func (rs *RepoSyncer) fetchUpstream(ctx context.Context, target UpstreamTarget, depth int, importMu *sync.Mutex) UpstreamResult {
+	result := UpstreamResult{Target: target}
+
+	branchInfo, err := rs.client.GetBranch(ctx, target.Owner, target.Repo, target.Branch)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to get branch info: %w", err)
+		return result
+	}
+
+	importMu.Lock()
+	fmt.Printf("Fetching %s from %s/%s into %s...\n", target.Branch, target.Owner, target.Repo, target.Timeline)
+	previous, err := rs.localTimelineHash(target.Timeline)
+	if err == nil {
+		result.Fetch, err = rs.importHistory(ctx, target.Owner, target.Repo, target.Branch,
+			branchInfo.Commit.SHA, depth, target.Timeline, target.MoveCheckedOut)
+	}
+	importMu.Unlock()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if result.Fetch.Local == LocalFastForwarded || result.Fetch.Local == LocalCreated {
+		result.Delta, err = rs.timelineDelta(previous, result.Fetch.TipHash)
+		if err != nil {
+			result.Err = err
+		}
+	}
+	return result
+}
+
+// localTimelineHash returns the seal a local timeline points at, or the
+// zero hash when the timeline does not exist yet
+func (rs *RepoSyncer) localTimelineHash(name string) (cas.Hash, error) {
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		return cas.Hash{}, fmt.Errorf("failed to create refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	timeline, err := refsManager.GetTimeline(name, refs.LocalTimeline)
+	if err != nil {
+		return cas.Hash{}, nil
+	}
+	return cas.Hash(timeline.Blake3Hash), nil
+}
+
+// timelineDelta lists the files that differ between two seals
+func (rs *RepoSyncer) timelineDelta(from, to cas.Hash) (*TimelineDelta, error) {
+	changes, err := rs.computeFileDeltas(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute changes: %w", err)
+	}
+
+	delta := &TimelineDelta{NoChanges: len(changes) == 0}
+	for _, change := range changes {
+		switch change.Type {
+		case "added":
+			delta.AddedFiles = append(delta.AddedFiles, change.Path)
+		case "modified":
+			delta.ModifiedFiles = append(delta.ModifiedFiles, change.Path)
+		case "deleted":
+			delta.DeletedFiles = append(delta.DeletedFiles, change.Path)
+		}
+	}
+	return delta, nil
+}
@@ -0,0 +1,171 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+// fakeHistory serves a small linear history: c1 holds a.txt, and c2 adds
+// b.txt on top of it
+type fakeHistory struct {
+	mu       sync.Mutex
+	branches map[string]string // Branch name to tip SHA
+}
+
+var fakeCommits = map[string]struct {
+	parent string
+	tree   string
+	files  map[string]string
+}{
+	"c1c1c1c1c1": {tree: "t1", files: map[string]string{"a.txt": "alpha"}},
+	"c2c2c2c2c2": {parent: "c1c1c1c1c1", tree: "t2", files: map[string]string{"a.txt": "alpha", "b.txt": "beta"}},
+}
+
+func (f *fakeHistory) setBranch(name, sha string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.branches[name] = sha
+}
+
+func (f *fakeHistory) repoCommit(sha string) RepoCommit {
+	var c RepoCommit
+	c.SHA = sha
+	c.Commit.Message = "commit " + sha[:2]
+	c.Commit.Author = GitUser{Name: "Test", Email: "test@example.com"}
+	c.Commit.Committer = c.Commit.Author
+	c.Commit.Tree.SHA = fakeCommits[sha].tree
+	if parent := fakeCommits[sha].parent; parent != "" {
+		c.Parents = append(c.Parents, struct {
+			SHA string `json:"sha"`
+		}{SHA: parent})
+	}
+	return c
+}
+
+func (f *fakeHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/repos/owner/repo/branches/"):
+		name := strings.TrimPrefix(path, "/repos/owner/repo/branches/")
+		tip, ok := f.branches[name]
+		if !ok {
+			http.Error(w, `{"message":"Branch not found"}`, http.StatusNotFound)
+			return
+		}
+		var branch Branch
+		branch.Name = name
+		branch.Commit.SHA = tip
+		json.NewEncoder(w).Encode(branch)
+	case path == "/repos/owner/repo/commits":
+		var history []RepoCommit
+		for sha := r.URL.Query().Get("sha"); sha != ""; sha = fakeCommits[sha].parent {
+			history = append(history, f.repoCommit(sha))
+		}
+		json.NewEncoder(w).Encode(history)
+	case strings.HasPrefix(path, "/repos/owner/repo/git/trees/"):
+		treeSHA := strings.TrimPrefix(path, "/repos/owner/repo/git/trees/")
+		tree := Tree{SHA: treeSHA}
+		for _, c := range fakeCommits {
+			if c.tree != treeSHA {
+				continue
+			}
+			for name, content := range c.files {
+				tree.Tree = append(tree.Tree, TreeEntry{Path: name, Type: "blob", SHA: computeGitBlobSHA([]byte(content))})
+			}
+		}
+		json.NewEncoder(w).Encode(tree)
+	case strings.HasPrefix(path, "/owner/repo/"):
+		parts := strings.SplitN(strings.TrimPrefix(path, "/owner/repo/"), "/", 2)
+		content, ok := fakeCommits[parts[0]].files[parts[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestFetchUpstreams(t *testing.T) {
+	history := &fakeHistory{branches: map[string]string{"main": "c1c1c1c1c1", "feature": "c1c1c1c1c1"}}
+	server := httptest.NewServer(history)
+	defer server.Close()
+
+	ivaldiDir := t.TempDir()
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: ivaldiDir,
+		workDir:   t.TempDir(),
+		casStore:  cas.NewMemoryCAS(),
+	}
+	targets := []UpstreamTarget{
+		{Timeline: "main", Owner: "owner", Repo: "repo", Branch: "main"},
+		{Timeline: "dev", Owner: "owner", Repo: "repo", Branch: "feature"},
+		{Timeline: "gone", Owner: "owner", Repo: "repo", Branch: "deleted"},
+	}
+
+	results := rs.FetchUpstreams(context.Background(), targets, 0, 3)
+	if len(results) != len(targets) {
+		t.Fatalf("Expected %d results, got %d", len(targets), len(results))
+	}
+	for i, result := range results[:2] {
+		if result.Err != nil {
+			t.Fatalf("Fetching %s failed: %v", targets[i].Timeline, result.Err)
+		}
+		if result.Fetch.Local != LocalCreated {
+			t.Errorf("Expected %s to be created, got %s", targets[i].Timeline, result.Fetch.Local)
+		}
+	}
+	if results[2].Err == nil {
+		t.Error("Expected fetching a deleted branch to fail")
+	}
+	// History shared between the timelines is imported once
+	if results[0].Fetch.TipHash != results[1].Fetch.TipHash {
+		t.Error("Expected both timelines to point at the same imported seal")
+	}
+	if got := results[0].Fetch.Imported + results[1].Fetch.Imported; got != 1 {
+		t.Errorf("Expected the shared commit to be imported once, got %d imports", got)
+	}
+
+	history.setBranch("main", "c2c2c2c2c2")
+	results = rs.FetchUpstreams(context.Background(), targets[:2], 0, 2)
+	main, dev := results[0], results[1]
+	if main.Err != nil || dev.Err != nil {
+		t.Fatalf("Second fetch failed: %v, %v", main.Err, dev.Err)
+	}
+	if main.Fetch.Local != LocalFastForwarded {
+		t.Errorf("Expected main to be fast-forwarded, got %s", main.Fetch.Local)
+	}
+	if main.Delta == nil || !reflect.DeepEqual(main.Delta.AddedFiles, []string{"b.txt"}) || len(main.Delta.ModifiedFiles) != 0 {
+		t.Errorf("Expected main to gain b.txt, got %+v", main.Delta)
+	}
+	if dev.Fetch.Local != LocalUpToDate || dev.Delta != nil {
+		t.Errorf("Expected dev to be up to date, got %s with delta %+v", dev.Fetch.Local, dev.Delta)
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer refsManager.Close()
+	timeline, err := refsManager.GetTimeline("main", refs.LocalTimeline)
+	if err != nil {
+		t.Fatalf("Expected local timeline main: %v", err)
+	}
+	if cas.Hash(timeline.Blake3Hash) != main.Fetch.TipHash {
+		t.Error("Expected main to point at the fetched tip")
+	}
+}
@@ -0,0 +1,147 @@
+package github
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"path"
+	"strings"
+)
+
+// ObjectError reports an object received from GitHub that failed
+// transfer.fsckObjects checks. Nothing of it is stored.
+type ObjectError struct {
+	// Kind is "tree", "commit" or "blob"
+	Kind string
+	// SHA is the Git object ID the remote named the object by
+	SHA string
+	// Path is the file the object was received for, if any
+	Path   string
+	Reason string
+}
+
+func (e *ObjectError) Error() string {
+	name := e.SHA
+	if e.Path != "" {
+		name = fmt.Sprintf("%s (%s)", e.SHA, e.Path)
+	}
+	return fmt.Sprintf("remote sent a corrupt %s %s: %s (set transfer.fsckObjects to false to accept it)", e.Kind, name, e.Reason)
+}
+
+// isObjectID reports whether sha is a full hex Git object ID
+func isObjectID(sha string) bool {
+	if len(sha) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(sha)
+	return err == nil
+}
+
+// checkTree checks that every entry of a received tree names a well formed
+// object and a path that stays inside the working directory
+func checkTree(tree *Tree) error {
+	if !isObjectID(tree.SHA) {
+		return &ObjectError{Kind: "tree", SHA: tree.SHA, Reason: "malformed object ID"}
+	}
+	for _, entry := range tree.Tree {
+		reason := ""
+		switch {
+		case !isObjectID(entry.SHA):
+			reason = fmt.Sprintf("entry %q has a malformed object ID", entry.Path)
+		case entry.Type != "blob" && entry.Type != "tree" && entry.Type != "commit":
+			reason = fmt.Sprintf("entry %q has unknown type %q", entry.Path, entry.Type)
+		case !isSafeTreePath(entry.Path):
+			reason = fmt.Sprintf("entry %q has an unsafe path", entry.Path)
+		}
+		if reason != "" {
+			return &ObjectError{Kind: "tree", SHA: tree.SHA, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// isSafeTreePath reports whether a tree path is relative, clean and does
+// not reach into the repository directory
+func isSafeTreePath(p string) bool {
+	if p == "" || strings.HasPrefix(p, "/") || strings.Contains(p, "\\") || path.Clean(p) != p {
+		return false
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == "." || part == ".." || strings.EqualFold(part, ".ivaldi") {
+			return false
+		}
+	}
+	return true
+}
+
+// getTree lists a remote tree recursively, checking it when
+// transfer.fsckObjects is set
+func (rs *RepoSyncer) getTree(ctx context.Context, owner, repo, sha string) (*Tree, error) {
+	tree, err := rs.client.GetTree(ctx, owner, repo, sha, true)
+	if err != nil {
+		return nil, err
+	}
+	if rs.fsckObjects {
+		if err := checkTree(tree); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// checkCommit checks that a received commit names its tree and parents by
+// well formed object IDs
+func checkCommit(c *RepoCommit) error {
+	if !isObjectID(c.SHA) {
+		return &ObjectError{Kind: "commit", SHA: c.SHA, Reason: "malformed object ID"}
+	}
+	if !isObjectID(c.Commit.Tree.SHA) {
+		return &ObjectError{Kind: "commit", SHA: c.SHA, Reason: "malformed tree ID"}
+	}
+	for _, parent := range c.Parents {
+		if !isObjectID(parent.SHA) {
+			return &ObjectError{Kind: "commit", SHA: c.SHA, Reason: fmt.Sprintf("malformed parent ID %q", parent.SHA)}
+		}
+	}
+	return nil
+}
+
+// blobHasher computes the Git blob ID of content streamed through it. The
+// size is taken from the tree entry, since the header comes first.
+type blobHasher struct {
+	hash.Hash
+	entry TreeEntry
+	size  int64
+}
+
+func newBlobHasher(entry TreeEntry) *blobHasher {
+	h := &blobHasher{Hash: sha1.New(), entry: entry}
+	fmt.Fprintf(h.Hash, "blob %d\x00", entry.Size)
+	return h
+}
+
+func (h *blobHasher) Write(p []byte) (int, error) {
+	h.size += int64(len(p))
+	return h.Hash.Write(p)
+}
+
+// check reports whether the content written matches the entry
+func (h *blobHasher) check() error {
+	if h.size != int64(h.entry.Size) {
+		return &ObjectError{Kind: "blob", SHA: h.entry.SHA, Path: h.entry.Path, Reason: fmt.Sprintf("expected %d bytes, received %d", h.entry.Size, h.size)}
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != h.entry.SHA {
+		return &ObjectError{Kind: "blob", SHA: h.entry.SHA, Path: h.entry.Path, Reason: fmt.Sprintf("content hashes to %s", sum)}
+	}
+	return nil
+}
+
+// checkBlob checks downloaded content against the tree entry it was
+// downloaded for
+func checkBlob(entry TreeEntry, content []byte) error {
+	h := newBlobHasher(entry)
+	h.Write(content)
+	return h.check()
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+func TestDownloadFileFsckObjects(t *testing.T) {
+	server := fakeRawFiles()
+	defer server.Close()
+
+	workDir := t.TempDir()
+	rs := newDownloadSyncer(server.URL, workDir)
+	rs.fsckObjects = true
+
+	good := TreeEntry{Path: "good.txt", Type: "blob", Size: len("good.txt"), SHA: computeGitBlobSHA([]byte("good.txt"))}
+	if err := rs.downloadFile(context.Background(), "owner", "repo", good, "ref"); err != nil {
+		t.Fatalf("Expected matching content to be accepted, got %v", err)
+	}
+
+	for _, corrupt := range []TreeEntry{
+		{Path: "sha.txt", Type: "blob", Size: len("sha.txt"), SHA: computeGitBlobSHA([]byte("other"))},
+		{Path: "size.txt", Type: "blob", Size: 3, SHA: computeGitBlobSHA([]byte("size.txt"))},
+	} {
+		err := rs.downloadFile(context.Background(), "owner", "repo", corrupt, "ref")
+		var objErr *ObjectError
+		if !errors.As(err, &objErr) || objErr.Path != corrupt.Path {
+			t.Fatalf("Expected %s to be rejected, got %v", corrupt.Path, err)
+		}
+		if _, err := os.Stat(filepath.Join(workDir, corrupt.Path)); !os.IsNotExist(err) {
+			t.Errorf("Expected rejected %s not to be written", corrupt.Path)
+		}
+		if has, _ := rs.casStore.Has(cas.SumB3([]byte(corrupt.Path))); has {
+			t.Errorf("Expected rejected %s not to be stored in CAS", corrupt.Path)
+		}
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(workDir, ".ivaldi-download-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("Expected no temporary files, got %v", leftovers)
+	}
+
+	// With the check off, content is taken as sent
+	rs.fsckObjects = false
+	unchecked := TreeEntry{Path: "unchecked.txt", Type: "blob", SHA: strings.Repeat("0", 40)}
+	if err := rs.downloadFile(context.Background(), "owner", "repo", unchecked, "ref"); err != nil {
+		t.Errorf("Expected unchecked content to be accepted, got %v", err)
+	}
+}
+
+func TestCheckTree(t *testing.T) {
+	sha := strings.Repeat("a", 40)
+	tests := []struct {
+		entry TreeEntry
+		ok    bool
+	}{
+		{TreeEntry{Path: "src/main.go", Type: "blob", SHA: sha}, true},
+		{TreeEntry{Path: "vendor/lib", Type: "commit", SHA: sha}, true},
+		{TreeEntry{Path: "src", Type: "tree", SHA: "abc"}, false},
+		{TreeEntry{Path: "src", Type: "tag", SHA: sha}, false},
+		{TreeEntry{Path: "../escape", Type: "blob", SHA: sha}, false},
+		{TreeEntry{Path: "/etc/passwd", Type: "blob", SHA: sha}, false},
+		{TreeEntry{Path: "a//b", Type: "blob", SHA: sha}, false},
+		{TreeEntry{Path: "sub/.IVALDI/config", Type: "blob", SHA: sha}, false},
+	}
+	for _, tt := range tests {
+		err := checkTree(&Tree{SHA: sha, Tree: []TreeEntry{tt.entry}})
+		if (err == nil) != tt.ok {
+			t.Errorf("checkTree(%q, %q, %q) = %v, expected ok=%v", tt.entry.Path, tt.entry.Type, tt.entry.SHA, err, tt.ok)
+		}
+	}
+
+	commit := &RepoCommit{SHA: sha}
+	commit.Commit.Tree.SHA = sha
+	if err := checkCommit(commit); err != nil {
+		t.Errorf("Expected a well formed commit to pass, got %v", err)
+	}
+	commit.Parents = append(commit.Parents, struct {
+		SHA string `json:"sha"`
+	}{SHA: "main"})
+	if err := checkCommit(commit); err == nil {
+		t.Error("Expected a commit with a malformed parent to be rejected")
+	}
+}
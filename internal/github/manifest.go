@@ -0,0 +1,121 @@
+package github
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+)
+
+// SyncManifest records the files of a timeline at its last sync with
+// GitHub, so the next sync can compare blob SHAs without hashing every
+// local file again
+type SyncManifest struct {
+	RemoteCommit string            `json:"remote_commit"` // Git commit SHA that was synced
+	LocalCommit  string            `json:"local_commit"`  // Ivaldi commit the sync ended at
+	Files        map[string]string `json:"files"`         // Path -> Git blob SHA of the local commit's content
+}
+
+// SyncManifestPath returns the manifest file of a timeline
+func SyncManifestPath(ivaldiDir, timeline string) string {
+	return filepath.Join(ivaldiDir, "sync", filepath.FromSlash(timeline)+".lock")
+}
+
+// LoadSyncManifest reads the manifest of a timeline. A timeline that was
+// never synced has none and yields nil.
+func LoadSyncManifest(ivaldiDir, timeline string) (*SyncManifest, error) {
+	data, err := os.ReadFile(SyncManifestPath(ivaldiDir, timeline))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync manifest: %w", err)
+	}
+
+	var manifest SyncManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse sync manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// SaveSyncManifest replaces the manifest of a timeline atomically, so an
+// interrupted sync leaves the previous manifest in place
+func SaveSyncManifest(ivaldiDir, timeline string, manifest *SyncManifest) error {
+	path := SyncManifestPath(ivaldiDir, timeline)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create sync directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sync manifest: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write sync manifest: %w", err)
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write sync manifest: %w", err)
+	}
+	return nil
+}
+
+// syncedBlobSHAs maps each file of a local commit to its Git blob SHA. Files
+// unchanged since the commit recorded in manifest reuse its SHAs; only the
+// others are read and hashed.
+func (rs *RepoSyncer) syncedBlobSHAs(commitHash cas.Hash, manifest *SyncManifest) (map[string]string, error) {
+	commitReader := commit.NewCommitReader(rs.casStore)
+	commitObj, err := commitReader.ReadCommit(commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit: %w", err)
+	}
+	files, err := commitReader.FileRefs(commitObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	// Files of the commit the manifest describes, to tell which are unchanged
+	var synced map[string]filechunk.NodeRef
+	if manifest != nil {
+		var syncedHash cas.Hash
+		if decoded, err := hex.DecodeString(manifest.LocalCommit); err == nil && len(decoded) == len(syncedHash) {
+			copy(syncedHash[:], decoded)
+			if syncedCommit, err := commitReader.ReadCommit(syncedHash); err == nil {
+				synced, _ = commitReader.FileRefs(syncedCommit)
+			}
+		}
+	}
+
+	loader := filechunk.NewLoader(rs.casStore)
+	shas := make(map[string]string, len(files))
+	for path, ref := range files {
+		if old, ok := synced[path]; ok && old.Hash == ref.Hash {
+			if sha, ok := manifest.Files[path]; ok {
+				shas[path] = sha
+				continue
+			}
+		}
+
+		content, err := loader.ReadAll(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		shas[path] = computeGitBlobSHA(content)
+	}
+	return shas, nil
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+)
+
+func TestSyncManifestRoundTrip(t *testing.T) {
+	ivaldiDir := t.TempDir()
+
+	manifest, err := LoadSyncManifest(ivaldiDir, "feature/login")
+	if err != nil || manifest != nil {
+		t.Fatalf("Expected no manifest before the first sync, got %v, %v", manifest, err)
+	}
+
+	want := &SyncManifest{
+		RemoteCommit: "abc123",
+		LocalCommit:  "def456",
+		Files:        map[string]string{"README.md": "111", "src/main.go": "222"},
+	}
+	if err := SaveSyncManifest(ivaldiDir, "feature/login", want); err != nil {
+		t.Fatalf("SaveSyncManifest failed: %v", err)
+	}
+
+	got, err := LoadSyncManifest(ivaldiDir, "feature/login")
+	if err != nil {
+		t.Fatalf("LoadSyncManifest failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	// The temporary file the manifest was written through is gone
+	entries, err := os.ReadDir(filepath.Dir(SyncManifestPath(ivaldiDir, "feature/login")))
+	if err != nil {
+		t.Fatalf("Failed to list sync directory: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "login.lock" {
+		t.Errorf("Expected only login.lock, found %v", entries)
+	}
+}
+
+func TestSyncedBlobSHAsReusesManifest(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	rs := &RepoSyncer{casStore: casStore}
+
+	synced := createVerifyCommit(t, casStore, map[string]string{
+		"README.md":   "# Test\n",
+		"src/main.go": "package main\n",
+	})
+	current := createVerifyCommit(t, casStore, map[string]string{
+		"README.md":   "# Test\n",
+		"src/main.go": "package main\n\nfunc main() {}\n",
+		"new.txt":     "new\n",
+	})
+
+	// A recorded SHA is trusted for an unchanged file, so a made-up one shows it was reused
+	manifest := &SyncManifest{
+		LocalCommit: synced.String(),
+		Files:       map[string]string{"README.md": "recorded", "src/main.go": "stale"},
+	}
+
+	shas, err := rs.syncedBlobSHAs(current, manifest)
+	if err != nil {
+		t.Fatalf("syncedBlobSHAs failed: %v", err)
+	}
+
+	want := map[string]string{
+		"README.md":   "recorded",
+		"src/main.go": computeGitBlobSHA([]byte("package main\n\nfunc main() {}\n")),
+		"new.txt":     computeGitBlobSHA([]byte("new\n")),
+	}
+	if !reflect.DeepEqual(shas, want) {
+		t.Errorf("Expected %v, got %v", want, shas)
+	}
+
+	// Without a manifest every file is hashed
+	shas, err = rs.syncedBlobSHAs(current, nil)
+	if err != nil {
+		t.Fatalf("syncedBlobSHAs failed: %v", err)
+	}
+	if shas["README.md"] != computeGitBlobSHA([]byte("# Test\n")) {
+		t.Errorf("Expected README.md to be hashed, got %s", shas["README.md"])
+	}
+}
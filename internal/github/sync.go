@@ -6,13 +6,19 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
 	"github.com/javanhut/Ivaldi-vcs/internal/history"
 	"github.com/javanhut/Ivaldi-vcs/internal/refs"
 	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
@@ -25,6 +31,8 @@ type RepoSyncer struct {
 	ivaldiDir string
 	workDir   string
 	casStore  cas.CAS
+	// fsckObjects checks received objects before they are stored
+	fsckObjects bool
 }
 
 // NewRepoSyncer creates a new repository syncer
@@ -34,6 +42,8 @@ func NewRepoSyncer(ivaldiDir, workDir string) (*RepoSyncer, error) {
 		return nil, fmt.Errorf("failed to create GitHub client: %w", err)
 	}
 
+	client.SetCache(NewResponseCache(CacheDir(ivaldiDir)))
+
 	// Initialize CAS store
 	objectsDir := filepath.Join(ivaldiDir, "objects")
 	casStore, err := cas.NewFileCAS(objectsDir)
@@ -42,15 +52,27 @@ func NewRepoSyncer(ivaldiDir, workDir string) (*RepoSyncer, error) {
 	}
 
 	return &RepoSyncer{
-		client:    client,
-		ivaldiDir: ivaldiDir,
-		workDir:   workDir,
-		casStore:  casStore,
+		client:      client,
+		ivaldiDir:   ivaldiDir,
+		workDir:     workDir,
+		casStore:    casStore,
+		fsckObjects: config.TransferFsckObjects(),
 	}, nil
 }
 
 // CloneRepository clones a GitHub repository without using Git
 func (rs *RepoSyncer) CloneRepository(ctx context.Context, owner, repo string) error {
+	return rs.cloneRepository(ctx, owner, repo, false)
+}
+
+// CloneRepositoryBare imports objects and timelines from a GitHub repository
+// without materializing a working tree. Files are stored in CAS as they are
+// downloaded and never written out.
+func (rs *RepoSyncer) CloneRepositoryBare(ctx context.Context, owner, repo string) error {
+	return rs.cloneRepository(ctx, owner, repo, true)
+}
+
+func (rs *RepoSyncer) cloneRepository(ctx context.Context, owner, repo string, bare bool) error {
 	fmt.Printf("Cloning %s/%s from GitHub...\n", owner, repo)
 
 	// Check rate limits
@@ -70,206 +92,138 @@ func (rs *RepoSyncer) CloneRepository(ctx context.Context, owner, repo string) e
 
 	// Get the default branch
 	branch, err := rs.client.GetBranch(ctx, owner, repo, repoInfo.DefaultBranch)
+	if isEmptyRepositoryError(err) {
+		return rs.initEmptyClone(owner, repo, repoInfo.DefaultBranch)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to get branch info: %w", err)
 	}
 
 	// Get the tree for the latest commit
-	tree, err := rs.client.GetTree(ctx, owner, repo, branch.Commit.SHA, true)
+	tree, err := rs.getTree(ctx, owner, repo, branch.Commit.SHA)
 	if err != nil {
 		return fmt.Errorf("failed to get repository tree: %w", err)
 	}
 
-	// Download files concurrently
-	err = rs.downloadFiles(ctx, owner, repo, tree, branch.Commit.SHA)
-	if err != nil {
-		return fmt.Errorf("failed to download files: %w", err)
-	}
-
-	// Create initial commit in Ivaldi
-	err = rs.createIvaldiCommit(fmt.Sprintf("Import from GitHub: %s/%s", owner, repo))
-	if err != nil {
-		return fmt.Errorf("failed to create Ivaldi commit: %w", err)
-	}
-
-	fmt.Printf("Successfully cloned %s/%s\n", owner, repo)
-	return nil
-}
-
-// downloadFiles downloads all files from a GitHub tree with optimized performance
-func (rs *RepoSyncer) downloadFiles(ctx context.Context, owner, repo string, tree *Tree, ref string) error {
-	// Filter out files that already exist in CAS
-	var filesToDownload []TreeEntry
-	totalFiles := 0
-	skippedFiles := 0
-
-	for _, entry := range tree.Tree {
-		if entry.Type == "blob" {
-			totalFiles++
-			// Check if we already have this content (by SHA)
-			if entry.SHA != "" {
-				// For delta downloads, check if file already exists
-				// This is a simple optimization - could be enhanced with SHA comparison
-				localPath := filepath.Join(rs.workDir, entry.Path)
-				if info, err := os.Stat(localPath); err == nil && !info.IsDir() {
-					// File exists locally, skip download (could compare SHA for better accuracy)
-					skippedFiles++
-					continue
-				}
-			}
-			filesToDownload = append(filesToDownload, entry)
+	// Download files concurrently. A bare clone hashes them straight into
+	// CAS; otherwise they are written out and the working tree is sealed.
+	message := fmt.Sprintf("Import from GitHub: %s/%s", owner, repo)
+	head := rs.currentHead()
+	var commitHash cas.Hash
+	if bare {
+		chunkRules, err := filechunk.LoadProfileRules(rs.workDir)
+		if err != nil {
+			return fmt.Errorf("failed to load chunk profiles: %w", err)
 		}
-	}
-
-	if len(filesToDownload) == 0 {
-		fmt.Printf("All %d files already exist locally, nothing to download\n", totalFiles)
-		return nil
-	}
-
-	fmt.Printf("Downloading %d files (%d already exist locally)...\n", len(filesToDownload), skippedFiles)
-
-	// Dynamic worker count based on number of files
-	workers := 8
-	if len(filesToDownload) > 100 {
-		workers = 16
-	}
-	if len(filesToDownload) > 500 {
-		workers = 32
-	}
-	// Cap at 32 to avoid overwhelming the API
-	if workers > 32 {
-		workers = 32
-	}
-
-	jobs := make(chan TreeEntry, len(filesToDownload))
-	errors := make(chan error, len(filesToDownload))
-	progress := make(chan int, len(filesToDownload))
-
-	var wg sync.WaitGroup
-	var progressWg sync.WaitGroup
-
-	// Progress reporter
-	progressWg.Add(1)
-	go func() {
-		defer progressWg.Done()
-		downloaded := 0
-		for range progress {
-			downloaded++
-			// Update progress every 10 files or at completion
-			if downloaded%10 == 0 || downloaded == len(filesToDownload) {
-				percentage := (downloaded * 100) / len(filesToDownload)
-				fmt.Printf("\rProgress: %d/%d files (%d%%)...", downloaded, len(filesToDownload), percentage)
-			}
+		blobs := &blobCache{rules: chunkRules, blobs: make(map[string]fetchedBlob)}
+		fmt.Printf("Downloading files...\n")
+		files, err := rs.fetchTreeFiles(ctx, owner, repo, branch.Commit.SHA, tree, time.Now(), blobs)
+		if err != nil {
+			return fmt.Errorf("failed to download files: %w", err)
 		}
-		fmt.Println() // New line after progress
-	}()
-
-	// Start workers
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for entry := range jobs {
-				if err := rs.downloadFile(ctx, owner, repo, entry, ref); err != nil {
-					errors <- fmt.Errorf("failed to download %s: %w", entry.Path, err)
-				} else {
-					progress <- 1
-				}
-			}
-		}()
-	}
-
-	// Submit jobs
-	for _, entry := range filesToDownload {
-		jobs <- entry
-	}
-	close(jobs)
-
-	// Wait for completion
-	wg.Wait()
-	close(errors)
-	close(progress)
-	progressWg.Wait()
-
-	// Check for errors
-	var downloadErrors []error
-	for err := range errors {
-		downloadErrors = append(downloadErrors, err)
-	}
-
-	if len(downloadErrors) > 0 {
-		fmt.Printf("\nWarning: %d download errors occurred\n", len(downloadErrors))
-		if len(downloadErrors) <= 3 {
-			for _, err := range downloadErrors {
-				fmt.Printf("  - %v\n", err)
-			}
-		} else {
-			// Show first 3 errors
-			for i := 0; i < 3; i++ {
-				fmt.Printf("  - %v\n", downloadErrors[i])
-			}
-			fmt.Printf("  ... and %d more errors\n", len(downloadErrors)-3)
+		fmt.Printf("Downloaded %d files\n", len(files))
+		if commitHash, err = rs.createImportCommit(files, message, head); err != nil {
+			return fmt.Errorf("failed to create Ivaldi commit: %w", err)
+		}
+	} else {
+		if err := rs.downloadFiles(ctx, owner, repo, tree, branch.Commit.SHA); err != nil {
+			return fmt.Errorf("failed to download files: %w", err)
+		}
+		if commitHash, err = rs.createIvaldiCommit(message, head); err != nil {
+			return fmt.Errorf("failed to create Ivaldi commit: %w", err)
 		}
-		return fmt.Errorf("failed to download %d files", len(downloadErrors))
 	}
+	rs.recordRemoteHead(owner, repo, repoInfo.DefaultBranch, branch.Commit.SHA, commitHash)
+	rs.trackUpstream(rs.checkedOutTimeline(), owner, repo, repoInfo.DefaultBranch)
 
-	fmt.Printf("Successfully downloaded %d files\n", len(filesToDownload))
+	fmt.Printf("Successfully cloned %s/%s\n", owner, repo)
 	return nil
 }
 
-// downloadFile downloads a single file from GitHub
-func (rs *RepoSyncer) downloadFile(ctx context.Context, owner, repo string, entry TreeEntry, ref string) error {
-	// Check rate limits
-	if rs.client.IsRateLimited() {
-		rs.client.WaitForRateLimit()
+// isEmptyRepositoryError reports whether err is GitHub's answer for a
+// repository without commits: its default branch is not found, or the Git
+// data endpoints refuse with a conflict
+func isEmptyRepositoryError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
 	}
+	return apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusConflict
+}
 
-	// Download file content
-	content, err := rs.client.DownloadFile(ctx, owner, repo, entry.Path, ref)
+// initEmptyClone finishes cloning a repository that has no commits yet. The
+// current timeline is left without a seal, so the first upload creates the
+// remote branch the same way PushCommit does for any empty repository.
+func (rs *RepoSyncer) initEmptyClone(owner, repo, branch string) error {
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to create refs manager: %w", err)
 	}
+	defer refsManager.Close()
 
-	// Create local file
-	localPath := filepath.Join(rs.workDir, entry.Path)
-
-	// Ensure directory exists
-	dir := filepath.Dir(localPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+	currentTimeline, err := refsManager.GetCurrentTimeline()
+	if err != nil {
+		currentTimeline = "main"
+		if err := refsManager.SetCurrentTimeline(currentTimeline); err != nil {
+			return fmt.Errorf("failed to set current timeline: %w", err)
+		}
 	}
-
-	// Write file
-	if err := os.WriteFile(localPath, content, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	if !refsManager.TimelineExists(currentTimeline, refs.LocalTimeline) {
+		description := fmt.Sprintf("Clone from GitHub: %s/%s", owner, repo)
+		if err := refsManager.CreateTimeline(currentTimeline, refs.LocalTimeline, [32]byte{}, [32]byte{}, "", description); err != nil {
+			return fmt.Errorf("failed to create timeline: %w", err)
+		}
 	}
 
-	// Store in CAS for deduplication
-	hash := cas.SumB3(content)
-	if err := rs.casStore.Put(hash, content); err != nil {
-		// Non-fatal, file is already written to disk
-	}
+	rs.trackUpstream(currentTimeline, owner, repo, branch)
 
-	// No verbose output per file
+	fmt.Printf("Repository %s/%s is empty; nothing to download\n", owner, repo)
+	fmt.Printf("Timeline '%s' has no seals yet. Gather and seal files, then upload to publish the first commit.\n", currentTimeline)
 	return nil
 }
 
 // createIvaldiCommit creates an Ivaldi commit from the downloaded files
-func (rs *RepoSyncer) createIvaldiCommit(message string) error {
+func (rs *RepoSyncer) createIvaldiCommit(message string, expected [32]byte) (cas.Hash, error) {
 	// Scan workspace
 	materializer := workspace.NewMaterializer(rs.casStore, rs.ivaldiDir, rs.workDir)
 	wsIndex, err := materializer.ScanWorkspace()
 	if err != nil {
-		return fmt.Errorf("failed to scan workspace: %w", err)
+		return cas.Hash{}, fmt.Errorf("failed to scan workspace: %w", err)
 	}
 
 	// Get workspace files
 	wsLoader := wsindex.NewLoader(rs.casStore)
 	workspaceFiles, err := wsLoader.ListAll(wsIndex)
 	if err != nil {
-		return fmt.Errorf("failed to list workspace files: %w", err)
+		return cas.Hash{}, fmt.Errorf("failed to list workspace files: %w", err)
+	}
+
+	return rs.createImportCommit(workspaceFiles, message, expected)
+}
+
+// currentHead returns the seal the current timeline points at when an
+// import starts, or the zero hash when it has none
+func (rs *RepoSyncer) currentHead() [32]byte {
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		return [32]byte{}
+	}
+	defer refsManager.Close()
+
+	currentTimeline, err := refsManager.GetCurrentTimeline()
+	if err != nil {
+		currentTimeline = "main"
+	}
+	timeline, err := refsManager.GetTimeline(currentTimeline, refs.LocalTimeline)
+	if err != nil {
+		return [32]byte{}
 	}
+	return timeline.Blake3Hash
+}
 
+// createImportCommit creates the Ivaldi commit of an import with files and
+// points the current timeline at it, provided it still points at expected
+func (rs *RepoSyncer) createImportCommit(workspaceFiles []wsindex.FileMetadata, message string, expected [32]byte) (cas.Hash, error) {
 	// Initialize MMR
 	mmr, err := history.NewPersistentMMR(rs.casStore, rs.ivaldiDir)
 	if err != nil {
@@ -287,7 +241,7 @@ func (rs *RepoSyncer) createIvaldiCommit(message string) error {
 		message,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create commit: %w", err)
+		return cas.Hash{}, fmt.Errorf("failed to create commit: %w", err)
 	}
 
 	// Get commit hash
@@ -296,7 +250,7 @@ func (rs *RepoSyncer) createIvaldiCommit(message string) error {
 	// Update timeline
 	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
 	if err != nil {
-		return fmt.Errorf("failed to create refs manager: %w", err)
+		return cas.Hash{}, fmt.Errorf("failed to create refs manager: %w", err)
 	}
 	defer refsManager.Close()
 
@@ -310,18 +264,109 @@ func (rs *RepoSyncer) createIvaldiCommit(message string) error {
 	var hashArray [32]byte
 	copy(hashArray[:], commitHash[:])
 
-	err = refsManager.UpdateTimeline(
+	err = refsManager.UpdateTimelineIf(
 		currentTimeline,
 		refs.LocalTimeline,
+		expected,
 		hashArray,
 		[32]byte{},
 		"",
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update timeline: %w", err)
+		return cas.Hash{}, fmt.Errorf("failed to update timeline: %w", err)
 	}
 
-	return nil
+	return commitHash, nil
+}
+
+// recordRemoteHead remembers that the remote branch head gitSHA corresponds
+// to the local commit commitHash, so status can compare against it later
+// without network access. Failures only cost that comparison and are logged.
+func (rs *RepoSyncer) recordRemoteHead(owner, repo, branch, gitSHA string, commitHash cas.Hash) {
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		fmt.Printf("Warning: failed to record remote head: %v\n", err)
+		return
+	}
+	defer refsManager.Close()
+
+	var hashArray [32]byte
+	copy(hashArray[:], commitHash[:])
+
+	if err := refsManager.MapGitHashToBlake3(gitSHA, hashArray, [32]byte{}); err != nil {
+		fmt.Printf("Warning: failed to record remote head: %v\n", err)
+	}
+
+	description := fmt.Sprintf("Remote branch from %s/%s (SHA: %s)", owner, repo, gitSHA[:7])
+	if err := refsManager.CreateTimeline(branch, refs.RemoteTimeline, hashArray, [32]byte{}, gitSHA, description); err != nil {
+		fmt.Printf("Warning: failed to record remote head: %v\n", err)
+	}
+}
+
+// trackUpstream records the remote branch a timeline was created from as
+// its upstream, so that upload and sync need no arguments. Nothing changes
+// when branch.autoSetupMerge is false or the timeline has an upstream.
+func (rs *RepoSyncer) trackUpstream(timeline, owner, repo, branch string) {
+	if timeline == "" || branch == "" || !config.AutoSetupMerge() {
+		return
+	}
+	// Settings are written to the repository of the working directory,
+	// which must be the one being synced
+	syncDir, errSync := filepath.Abs(rs.ivaldiDir)
+	configDir, errConfig := filepath.Abs(config.IvaldiDir())
+	if errSync != nil || errConfig != nil || syncDir != configDir {
+		return
+	}
+	if remote, merge, err := config.GetUpstream(timeline); err != nil || remote != "" || merge != "" {
+		return
+	}
+	if err := config.SetUpstream(timeline, owner+"/"+repo, branch); err != nil {
+		fmt.Printf("Warning: failed to set the upstream of %s: %v\n", timeline, err)
+		return
+	}
+	fmt.Printf("Timeline '%s' set up to track %s/%s:%s\n", timeline, owner, repo, branch)
+}
+
+// checkedOutTimeline returns the current timeline, which a clone imports
+// into, or main when none is set
+func (rs *RepoSyncer) checkedOutTimeline() string {
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		return "main"
+	}
+	defer refsManager.Close()
+	if current, err := refsManager.GetCurrentTimeline(); err == nil {
+		return current
+	}
+	return "main"
+}
+
+// RefreshRemoteHead fetches the current head of a remote branch and updates
+// its remote timeline reference. It returns the branch head's Git SHA.
+func (rs *RepoSyncer) RefreshRemoteHead(ctx context.Context, owner, repo, branch string) (string, error) {
+	branchInfo, err := rs.client.GetBranch(ctx, owner, repo, branch)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote branch info: %w", err)
+	}
+
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to create refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	// Keep the known local commit if the head hasn't moved
+	existing, err := refsManager.GetTimeline(branch, refs.RemoteTimeline)
+	if err == nil && existing.GitSHA1Hash == branchInfo.Commit.SHA {
+		return branchInfo.Commit.SHA, nil
+	}
+
+	description := fmt.Sprintf("Remote branch from %s/%s (SHA: %s)", owner, repo, branchInfo.Commit.SHA[:7])
+	if err := refsManager.CreateRemoteTimeline(branch, branchInfo.Commit.SHA, description); err != nil {
+		return "", fmt.Errorf("failed to update remote timeline: %w", err)
+	}
+
+	return branchInfo.Commit.SHA, nil
 }
 
 // PullChanges pulls latest changes from GitHub
@@ -334,9 +379,11 @@ func (rs *RepoSyncer) PullChanges(ctx context.Context, owner, repo, branch strin
 		return fmt.Errorf("failed to get branch info: %w", err)
 	}
 
+	head := rs.currentHead()
+
 	// TODO: Compare with local state and download only changed files
 	// For now, we'll download the entire tree
-	tree, err := rs.client.GetTree(ctx, owner, repo, branchInfo.Commit.SHA, true)
+	tree, err := rs.getTree(ctx, owner, repo, branchInfo.Commit.SHA)
 	if err != nil {
 		return fmt.Errorf("failed to get tree: %w", err)
 	}
@@ -348,10 +395,11 @@ func (rs *RepoSyncer) PullChanges(ctx context.Context, owner, repo, branch strin
 	}
 
 	// Create new commit
-	err = rs.createIvaldiCommit(fmt.Sprintf("Pull from GitHub: %s", branchInfo.Commit.SHA[:7]))
+	commitHash, err := rs.createIvaldiCommit(fmt.Sprintf("Pull from GitHub: %s", branchInfo.Commit.SHA[:7]), head)
 	if err != nil {
 		return fmt.Errorf("failed to create commit: %w", err)
 	}
+	rs.recordRemoteHead(owner, repo, branch, branchInfo.Commit.SHA, commitHash)
 
 	fmt.Println("Successfully pulled changes")
 	return nil
@@ -365,108 +413,91 @@ type FileChange struct {
 	Type    string // "added", "modified", "deleted"
 }
 
-// computeFileDeltas compares two commits and returns changed files
+// computeFileDeltas compares two commits and returns changed files. Files
+// are compared by the content hashes recorded in the trees, so only added and
+// modified files are read from storage, and empty files not even those.
+// Seals do not record modes: a file's mode follows from its content (see
+// gitFileMode), so a change of mode alone cannot occur between two seals.
 func (rs *RepoSyncer) computeFileDeltas(parentHash, currentHash cas.Hash) ([]FileChange, error) {
 	commitReader := commit.NewCommitReader(rs.casStore)
 
-	// Read parent commit and tree
-	var parentFiles map[string]cas.Hash
+	// Read parent file references
+	parentFiles := make(map[string]filechunk.NodeRef)
 	if parentHash != (cas.Hash{}) {
 		parentCommit, err := commitReader.ReadCommit(parentHash)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read parent commit: %w", err)
 		}
 
-		parentTree, err := commitReader.ReadTree(parentCommit)
+		parentFiles, err = commitReader.FileRefs(parentCommit)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read parent tree: %w", err)
 		}
-
-		parentFileList, err := commitReader.ListFiles(parentTree)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list parent files: %w", err)
-		}
-
-		parentFiles = make(map[string]cas.Hash)
-		for _, filePath := range parentFileList {
-			content, err := commitReader.GetFileContent(parentTree, filePath)
-			if err != nil {
-				continue
-			}
-			parentFiles[filePath] = cas.SumB3(content)
-		}
-	} else {
-		parentFiles = make(map[string]cas.Hash)
 	}
 
-	// Read current commit and tree
+	// Read current file references
 	currentCommit, err := commitReader.ReadCommit(currentHash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read current commit: %w", err)
 	}
 
-	currentTree, err := commitReader.ReadTree(currentCommit)
+	currentFiles, err := commitReader.FileRefs(currentCommit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read current tree: %w", err)
 	}
 
-	currentFileList, err := commitReader.ListFiles(currentTree)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list current files: %w", err)
-	}
-
-	// Build map of current files
-	currentFiles := make(map[string][]byte)
-	for _, filePath := range currentFileList {
-		content, err := commitReader.GetFileContent(currentTree, filePath)
-		if err != nil {
-			continue
-		}
-		currentFiles[filePath] = content
+	paths := make([]string, 0, len(currentFiles))
+	for filePath := range currentFiles {
+		paths = append(paths, filePath)
 	}
+	sort.Strings(paths)
 
 	// Compute deltas
 	var changes []FileChange
+	loader := filechunk.NewLoader(rs.casStore)
 
 	// Check for added and modified files
-	for filePath, content := range currentFiles {
-		currentHash := cas.SumB3(content)
-		parentHash, existed := parentFiles[filePath]
+	for _, filePath := range paths {
+		currentRef := currentFiles[filePath]
+		parentRef, existed := parentFiles[filePath]
+		if existed && parentRef.Hash == currentRef.Hash {
+			continue // File unchanged - skip
+		}
 
-		mode := "100644" // regular file
-		if len(content) > 0 && content[0] == '#' && bytes.Contains(content[:min(100, len(content))], []byte("!/")) {
-			mode = "100755"
+		content := []byte{}
+		if currentRef.Size > 0 {
+			content, err = loader.ReadAll(currentRef)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+			}
 		}
 
+		changeType := "modified"
 		if !existed {
-			// File added
-			changes = append(changes, FileChange{
-				Path:    filePath,
-				Content: content,
-				Mode:    mode,
-				Type:    "added",
-			})
-		} else if currentHash != parentHash {
-			// File modified
-			changes = append(changes, FileChange{
-				Path:    filePath,
-				Content: content,
-				Mode:    mode,
-				Type:    "modified",
-			})
+			changeType = "added"
 		}
-		// If hashes match, file unchanged - skip
+		changes = append(changes, FileChange{
+			Path:    filePath,
+			Content: content,
+			Mode:    gitFileMode(content),
+			Type:    changeType,
+		})
 	}
 
 	// Check for deleted files
+	var deleted []string
 	for filePath := range parentFiles {
 		if _, exists := currentFiles[filePath]; !exists {
-			changes = append(changes, FileChange{
-				Path: filePath,
-				Type: "deleted",
-			})
+			deleted = append(deleted, filePath)
 		}
 	}
+	sort.Strings(deleted)
+	for _, filePath := range deleted {
+		changes = append(changes, FileChange{
+			Path: filePath,
+			Type: "deleted",
+		})
+	}
 
 	return changes, nil
 }
@@ -589,6 +620,35 @@ func (rs *RepoSyncer) createBlobsParallel(ctx context.Context, owner, repo strin
 	return treeEntries, nil
 }
 
+// maxTreeEntries is the most entries sent in one CreateTree request, which
+// keeps requests for huge changesets under GitHub's payload limits
+const maxTreeEntries = 2000
+
+// createTree creates a tree from entries on top of baseTree, which may be
+// empty. More than maxTreeEntries entries are sent in chunks, each based on
+// the tree the chunk before created, so the final tree is the one a single
+// request would have made: a delta still only replaces and deletes the
+// paths it lists.
+func (rs *RepoSyncer) createTree(ctx context.Context, owner, repo string, entries []GitTreeEntry, baseTree string) (*TreeResponse, error) {
+	if len(entries) <= maxTreeEntries {
+		return rs.client.CreateTree(ctx, owner, repo, CreateTreeRequest{Tree: entries, BaseTree: baseTree})
+	}
+
+	requests := (len(entries) + maxTreeEntries - 1) / maxTreeEntries
+	fmt.Printf("Creating tree of %d entries in %d requests\n", len(entries), requests)
+
+	var tree *TreeResponse
+	for start := 0; start < len(entries); start += maxTreeEntries {
+		end := min(start+maxTreeEntries, len(entries))
+		resp, err := rs.client.CreateTree(ctx, owner, repo, CreateTreeRequest{Tree: entries[start:end], BaseTree: baseTree})
+		if err != nil {
+			return nil, fmt.Errorf("entries %d-%d of %d: %w", start+1, end, len(entries), err)
+		}
+		tree, baseTree = resp, resp.SHA
+	}
+	return tree, nil
+}
+
 // UploadFile uploads a file to GitHub
 func (rs *RepoSyncer) UploadFile(ctx context.Context, owner, repo, path, branch, message string) error {
 	// Read file content
@@ -621,8 +681,10 @@ func (rs *RepoSyncer) UploadFile(ctx context.Context, owner, repo, path, branch,
 	return nil
 }
 
-// PushCommit pushes an Ivaldi commit to GitHub as a single commit with delta optimization
-func (rs *RepoSyncer) PushCommit(ctx context.Context, owner, repo, branch string, commitHash cas.Hash) error {
+// PushCommit pushes an Ivaldi commit to GitHub as a single commit with delta optimization.
+// With verify, the pushed tree is compared with the commit's files before the branch
+// is moved to it.
+func (rs *RepoSyncer) PushCommit(ctx context.Context, owner, repo, branch string, commitHash cas.Hash, verify bool) error {
 	fmt.Printf("Pushing commit %s to GitHub...\n", commitHash.String()[:8])
 
 	// Check if branch exists on GitHub
@@ -760,11 +822,27 @@ func (rs *RepoSyncer) PushCommit(ctx context.Context, owner, repo, branch string
 			// Get the branch to find the commit SHA created by Contents API
 			branchInfo, err := rs.client.GetBranch(ctx, owner, repo, branch)
 			if err != nil {
+				if verify {
+					return fmt.Errorf("failed to get branch info to verify the upload: %w", err)
+				}
 				fmt.Printf("Warning: could not get branch info after upload: %v\n", err)
 				return nil
 			}
 
+			// The Contents API moves the branch itself, so the tree can only be checked afterwards
+			if verify {
+				remoteCommit, err := rs.client.GetCommit(ctx, owner, repo, branchInfo.Commit.SHA)
+				if err != nil {
+					return fmt.Errorf("failed to get uploaded commit: %w", err)
+				}
+				if err := rs.verifyPushedTree(ctx, owner, repo, remoteCommit.TreeSHA, commitHash); err != nil {
+					return fmt.Errorf("verification failed after branch '%s' was created: %w", branch, err)
+				}
+				fmt.Printf("Verified tree %s against the local commit\n", remoteCommit.TreeSHA[:7])
+			}
+
 			// Store GitHub commit SHA in timeline
+			rs.recordRemoteHead(owner, repo, branch, branchInfo.Commit.SHA, commitHash)
 			err = rs.updateTimelineWithGitHubSHA(branch, commitHash, branchInfo.Commit.SHA)
 			if err != nil {
 				fmt.Printf("Warning: failed to update timeline with GitHub SHA: %v\n", err)
@@ -784,15 +862,10 @@ func (rs *RepoSyncer) PushCommit(ctx context.Context, owner, repo, branch string
 				return fmt.Errorf("failed to get content for %s: %w", filePath, err)
 			}
 
-			mode := "100644" // regular file
-			if len(content) > 0 && content[0] == '#' && bytes.Contains(content[:min(100, len(content))], []byte("!/")) {
-				mode = "100755"
-			}
-
 			allChanges = append(allChanges, FileChange{
 				Path:    filePath,
 				Content: content,
-				Mode:    mode,
+				Mode:    gitFileMode(content),
 				Type:    "added",
 			})
 		}
@@ -804,22 +877,26 @@ func (rs *RepoSyncer) PushCommit(ctx context.Context, owner, repo, branch string
 		}
 	}
 
-	// Create tree on GitHub
-	treeReq := CreateTreeRequest{
-		Tree: treeEntries,
-	}
-
-	// Use base_tree for delta uploads
+	// Create tree on GitHub, using base_tree for delta uploads
+	baseTree := ""
 	if useDeltaUpload && parentTreeSHA != "" {
-		treeReq.BaseTree = parentTreeSHA
+		baseTree = parentTreeSHA
 		fmt.Printf("Using base tree %s for delta upload\n", parentTreeSHA[:7])
 	}
 
-	treeResp, err := rs.client.CreateTree(ctx, owner, repo, treeReq)
+	treeResp, err := rs.createTree(ctx, owner, repo, treeEntries, baseTree)
 	if err != nil {
 		return fmt.Errorf("failed to create tree: %w", err)
 	}
 
+	// Check the tree before anything points at it
+	if verify {
+		if err := rs.verifyPushedTree(ctx, owner, repo, treeResp.SHA, commitHash); err != nil {
+			return fmt.Errorf("verification failed, branch '%s' was not updated: %w", branch, err)
+		}
+		fmt.Printf("Verified tree %s against the local commit\n", treeResp.SHA[:7])
+	}
+
 	// Create commit on GitHub
 	var parents []string
 	if parentSHA != "" {
@@ -858,6 +935,7 @@ func (rs *RepoSyncer) PushCommit(ctx context.Context, owner, repo, branch string
 	fmt.Printf("Successfully pushed commit %s to GitHub\n", commitResp.SHA[:7])
 
 	// Store GitHub commit SHA in timeline for future delta uploads
+	rs.recordRemoteHead(owner, repo, branch, commitResp.SHA, commitHash)
 	err = rs.updateTimelineWithGitHubSHA(branch, commitHash, commitResp.SHA)
 	if err != nil {
 		// Non-fatal: log but don't fail the push
@@ -867,12 +945,14 @@ func (rs *RepoSyncer) PushCommit(ctx context.Context, owner, repo, branch string
 	return nil
 }
 
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+// gitFileMode returns the Git mode to upload a file with. Seals do not
+// record modes, so scripts starting with a #! line are taken to be
+// executable and everything else is a regular file.
+func gitFileMode(content []byte) string {
+	if len(content) > 0 && content[0] == '#' && bytes.Contains(content[:min(100, len(content))], []byte("!/")) {
+		return "100755"
 	}
-	return b
+	return "100644"
 }
 
 // updateTimelineWithGitHubSHA updates the timeline with the GitHub commit SHA
@@ -884,6 +964,11 @@ func (rs *RepoSyncer) updateTimelineWithGitHubSHA(branch string, ivaldiCommitHas
 	defer refsManager.Close()
 
 	// Get the timeline
+	if !refsManager.TimelineExists(branch, refs.LocalTimeline) {
+		// Pushed from a timeline with a different name; the remote head
+		// recorded by recordRemoteHead is all there is to update
+		return nil
+	}
 	timeline, err := refsManager.GetTimeline(branch, refs.LocalTimeline)
 	if err != nil {
 		return fmt.Errorf("failed to get timeline: %w", err)
@@ -901,10 +986,11 @@ func (rs *RepoSyncer) updateTimelineWithGitHubSHA(branch string, ivaldiCommitHas
 	var blake3Hash [32]byte
 	copy(blake3Hash[:], ivaldiCommitHash[:])
 
-	err = refsManager.UpdateTimeline(
+	err = refsManager.UpdateTimelineIf(
 		branch,
 		refs.LocalTimeline,
 		blake3Hash,
+		blake3Hash,
 		timeline.SHA256Hash,
 		githubCommitSHA,
 	)
@@ -972,8 +1058,22 @@ func (rs *RepoSyncer) SyncTimeline(ctx context.Context, owner, repo, branch stri
 		}
 	}
 
+	// Only fast-forward: syncing a timeline with seals of its own would
+	// make a commit mixing both histories, and syncing one the remote has
+	// not moved past would undo its local seals
+	divergence, err := rs.divergence(branch, branchInfo.Commit.SHA, cas.Hash(localCommitHash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare with the remote branch: %w", err)
+	}
+	if divergence.Diverged() {
+		return nil, &DivergedError{Timeline: branch, RemoteSHA: branchInfo.Commit.SHA}
+	}
+	if divergence.LocalAhead {
+		return &TimelineDelta{NoChanges: true}, nil
+	}
+
 	// Get the remote tree
-	remoteTree, err := rs.client.GetTree(ctx, owner, repo, branchInfo.Commit.SHA, true)
+	remoteTree, err := rs.getTree(ctx, owner, repo, branchInfo.Commit.SHA)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get remote tree: %w", err)
 	}
@@ -986,37 +1086,18 @@ func (rs *RepoSyncer) SyncTimeline(ctx context.Context, owner, repo, branch stri
 		}
 	}
 
-	// Get local files from commit
-	var localFiles map[string][]byte
+	// Blob SHAs of the local files, reusing those recorded at the last sync
+	manifest, err := LoadSyncManifest(rs.ivaldiDir, branch)
+	if err != nil {
+		fmt.Printf("Warning: ignoring sync manifest: %v\n", err)
+		manifest = nil
+	}
+	localFiles := make(map[string]string) // path -> SHA
 	if localCommitHash != [32]byte{} {
-		// Read local commit to get file list
-		commitReader := commit.NewCommitReader(rs.casStore)
-		commitObj, err := commitReader.ReadCommit(cas.Hash(localCommitHash))
-		if err != nil {
-			// If we can't read local commit, treat as empty
-			localFiles = make(map[string][]byte)
-		} else {
-			tree, err := commitReader.ReadTree(commitObj)
-			if err != nil {
-				localFiles = make(map[string][]byte)
-			} else {
-				filePaths, err := commitReader.ListFiles(tree)
-				if err != nil {
-					localFiles = make(map[string][]byte)
-				} else {
-					localFiles = make(map[string][]byte)
-					for _, filePath := range filePaths {
-						content, err := commitReader.GetFileContent(tree, filePath)
-						if err == nil {
-							localFiles[filePath] = content
-						}
-					}
-				}
-			}
+		if shas, err := rs.syncedBlobSHAs(cas.Hash(localCommitHash), manifest); err == nil {
+			localFiles = shas
 		}
-	} else {
-		// No local commit, all remote files are new
-		localFiles = make(map[string][]byte)
+		// If we can't read the local commit, treat it as empty
 	}
 
 	// Compute delta
@@ -1028,15 +1109,12 @@ func (rs *RepoSyncer) SyncTimeline(ctx context.Context, owner, repo, branch stri
 
 	// Check for added and modified files
 	for remotePath, remoteSHA := range remoteFiles {
-		localContent, existsLocally := localFiles[remotePath]
+		localGitSHA, existsLocally := localFiles[remotePath]
 		if !existsLocally {
 			// File is new on remote
 			delta.AddedFiles = append(delta.AddedFiles, remotePath)
 		} else {
 			// File exists both locally and remotely - check if content changed
-			// Compute Git blob SHA for local content to compare with GitHub SHA
-			localGitSHA := computeGitBlobSHA(localContent)
-
 			if localGitSHA != remoteSHA {
 				// Content has changed
 				delta.ModifiedFiles = append(delta.ModifiedFiles, remotePath)
@@ -1055,6 +1133,9 @@ func (rs *RepoSyncer) SyncTimeline(ctx context.Context, owner, repo, branch stri
 	// If no changes, return early
 	if len(delta.AddedFiles) == 0 && len(delta.ModifiedFiles) == 0 && len(delta.DeletedFiles) == 0 {
 		delta.NoChanges = true
+		if localCommitHash != [32]byte{} {
+			rs.saveSyncManifest(branch, branchInfo.Commit.SHA, cas.Hash(localCommitHash), localFiles)
+		}
 		return delta, nil
 	}
 
@@ -1098,15 +1179,40 @@ func (rs *RepoSyncer) SyncTimeline(ctx context.Context, owner, repo, branch stri
 	}
 
 	// Create new commit for synced state
-	err = rs.createIvaldiCommit(fmt.Sprintf("Sync with remote %s/%s@%s",
-		owner, repo, branchInfo.Commit.SHA[:7]))
+	commitHash, err := rs.createIvaldiCommit(fmt.Sprintf("Sync with remote %s/%s@%s",
+		owner, repo, branchInfo.Commit.SHA[:7]), localCommitHash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create commit after sync: %w", err)
 	}
+	rs.recordRemoteHead(owner, repo, branch, branchInfo.Commit.SHA, commitHash)
+
+	// The new commit also holds any local edits, so its SHAs are recorded
+	// rather than the remote ones; only files that differ from the previous
+	// local commit are hashed
+	previous := &SyncManifest{LocalCommit: cas.Hash(localCommitHash).String(), Files: localFiles}
+	if files, err := rs.syncedBlobSHAs(commitHash, previous); err == nil {
+		rs.saveSyncManifest(branch, branchInfo.Commit.SHA, commitHash, files)
+	} else {
+		fmt.Printf("Warning: failed to record sync manifest: %v\n", err)
+	}
 
 	return delta, nil
 }
 
+// saveSyncManifest records the blob SHAs of the local commit a sync ended
+// at. Without a manifest the next sync hashes every file again, so a failure
+// is only reported.
+func (rs *RepoSyncer) saveSyncManifest(timeline, remoteSHA string, localHash cas.Hash, files map[string]string) {
+	manifest := &SyncManifest{
+		RemoteCommit: remoteSHA,
+		LocalCommit:  localHash.String(),
+		Files:        files,
+	}
+	if err := SaveSyncManifest(rs.ivaldiDir, timeline, manifest); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
 // FetchTimeline downloads a specific timeline (branch) from GitHub
 func (rs *RepoSyncer) FetchTimeline(ctx context.Context, owner, repo, timelineName string) error {
 	fmt.Printf("Fetching timeline '%s' from %s/%s...\n", timelineName, owner, repo)
@@ -1118,7 +1224,7 @@ func (rs *RepoSyncer) FetchTimeline(ctx context.Context, owner, repo, timelineNa
 	}
 
 	// Get the tree for this branch
-	tree, err := rs.client.GetTree(ctx, owner, repo, branchInfo.Commit.SHA, true)
+	tree, err := rs.getTree(ctx, owner, repo, branchInfo.Commit.SHA)
 	if err != nil {
 		return fmt.Errorf("failed to get tree: %w", err)
 	}
@@ -1239,6 +1345,7 @@ func (rs *RepoSyncer) FetchTimeline(ctx context.Context, owner, repo, timelineNa
 			return fmt.Errorf("failed to update timeline: %w", err)
 		}
 	}
+	rs.trackUpstream(timelineName, owner, repo, timelineName)
 
 	// Also update the remote timeline reference with the harvested content
 	err = refsManager.UpdateRemoteTimeline(timelineName, hashArray, [32]byte{}, branchInfo.Commit.SHA)
@@ -1246,6 +1353,11 @@ func (rs *RepoSyncer) FetchTimeline(ctx context.Context, owner, repo, timelineNa
 		// Remote timeline might not exist, that's okay
 	}
 
+	// Remember which local commit the remote head corresponds to
+	if err := refsManager.MapGitHashToBlake3(branchInfo.Commit.SHA, hashArray, [32]byte{}); err != nil {
+		fmt.Printf("Warning: failed to record remote head: %v\n", err)
+	}
+
 	fmt.Printf("Successfully harvested timeline '%s' (workspace preserved)\n", timelineName)
 	return nil
 }
@@ -0,0 +1,385 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+func TestCloneEmptyRepository(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/empty":
+			json.NewEncoder(w).Encode(Repository{Name: "empty", FullName: "owner/empty", DefaultBranch: "main"})
+		case "/repos/owner/empty/branches/main":
+			http.Error(w, `{"message":"Branch not found"}`, http.StatusNotFound)
+		default:
+			t.Errorf("Unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ivaldiDir := t.TempDir()
+	workDir := t.TempDir()
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: ivaldiDir,
+		workDir:   workDir,
+		casStore:  cas.NewMemoryCAS(),
+	}
+
+	if err := rs.CloneRepository(context.Background(), "owner", "empty"); err != nil {
+		t.Fatalf("CloneRepository failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected an empty working directory, got %d entries", len(entries))
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer refsManager.Close()
+
+	current, err := refsManager.GetCurrentTimeline()
+	if err != nil || current != "main" {
+		t.Fatalf("Expected current timeline main, got %q (%v)", current, err)
+	}
+	timeline, err := refsManager.GetTimeline("main", refs.LocalTimeline)
+	if err != nil {
+		t.Fatalf("Expected local timeline main: %v", err)
+	}
+	if timeline.Blake3Hash != ([32]byte{}) {
+		t.Error("Expected timeline main to have no seal")
+	}
+	if refsManager.TimelineExists("main", refs.RemoteTimeline) {
+		t.Error("Expected no remote timeline for an empty repository")
+	}
+}
+
+func TestCloneRepositoryFailsForOtherErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/owner/repo" {
+			json.NewEncoder(w).Encode(Repository{Name: "repo", FullName: "owner/repo", DefaultBranch: "main"})
+			return
+		}
+		http.Error(w, `{"message":"Server Error"}`, http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: t.TempDir(),
+		workDir:   t.TempDir(),
+		casStore:  cas.NewMemoryCAS(),
+	}
+
+	if err := rs.CloneRepository(context.Background(), "owner", "repo"); err == nil {
+		t.Error("Expected CloneRepository to fail when the branch lookup fails")
+	}
+}
+
+func TestCloneRepositoryBare(t *testing.T) {
+	files := map[string]string{"README.md": "# Test\n", "src/main.go": "package main\n"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo":
+			json.NewEncoder(w).Encode(Repository{Name: "repo", FullName: "owner/repo", DefaultBranch: "main"})
+		case "/repos/owner/repo/branches/main":
+			var branch Branch
+			branch.Name = "main"
+			branch.Commit.SHA = "1111111111111111111111111111111111111111"
+			json.NewEncoder(w).Encode(branch)
+		case "/repos/owner/repo/git/trees/1111111111111111111111111111111111111111":
+			tree := Tree{SHA: "2222222222222222222222222222222222222222"}
+			tree.Tree = append(tree.Tree, TreeEntry{Path: "src", Type: "tree", SHA: "3333333333333333333333333333333333333333"})
+			for path, content := range files {
+				tree.Tree = append(tree.Tree, TreeEntry{Path: path, Type: "blob", SHA: computeGitBlobSHA([]byte(content))})
+			}
+			json.NewEncoder(w).Encode(tree)
+		default:
+			path := strings.TrimPrefix(r.URL.Path, "/owner/repo/1111111111111111111111111111111111111111/")
+			content, ok := files[path]
+			if !ok {
+				t.Errorf("Unexpected request for %s", r.URL.Path)
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(content))
+		}
+	}))
+	defer server.Close()
+
+	workDir := t.TempDir()
+	t.Setenv("HOME", workDir)
+	t.Chdir(workDir)
+	ivaldiDir := filepath.Join(workDir, ".ivaldi")
+	if err := os.Mkdir(ivaldiDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	casStore := cas.NewMemoryCAS()
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: ivaldiDir,
+		workDir:   workDir,
+		casStore:  casStore,
+	}
+
+	if err := rs.CloneRepositoryBare(context.Background(), "owner", "repo"); err != nil {
+		t.Fatalf("CloneRepositoryBare failed: %v", err)
+	}
+
+	// Nothing is written outside the repository directory, even temporarily
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only .ivaldi in the working directory, got %d entries", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(ivaldiDir, "bare_import_temp")); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary tree, got %v", err)
+	}
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer refsManager.Close()
+	timeline, err := refsManager.GetTimeline("main", refs.LocalTimeline)
+	if err != nil {
+		t.Fatalf("Expected local timeline main: %v", err)
+	}
+	reader := commit.NewCommitReader(casStore)
+	commitObj, err := reader.ReadCommit(cas.Hash(timeline.Blake3Hash))
+	if err != nil {
+		t.Fatalf("ReadCommit failed: %v", err)
+	}
+	fileRefs, err := reader.FileRefs(commitObj)
+	if err != nil {
+		t.Fatalf("FileRefs failed: %v", err)
+	}
+	if len(fileRefs) != len(files) {
+		t.Errorf("Expected %d files in the seal, got %d", len(files), len(fileRefs))
+	}
+	loader := filechunk.NewLoader(casStore)
+	for path, want := range files {
+		content, err := loader.ReadAll(fileRefs[path])
+		if err != nil || string(content) != want {
+			t.Errorf("Expected %s to be sealed as %q, got %q, %v", path, want, content, err)
+		}
+	}
+}
+
+// countingCAS counts reads of the objects in watch
+type countingCAS struct {
+	cas.CAS
+	watch map[cas.Hash]bool
+	reads int
+}
+
+func (c *countingCAS) Get(hash cas.Hash) ([]byte, error) {
+	if c.watch[hash] {
+		c.reads++
+	}
+	return c.CAS.Get(hash)
+}
+
+func TestComputeFileDeltas(t *testing.T) {
+	store := &countingCAS{CAS: cas.NewMemoryCAS(), watch: make(map[cas.Hash]bool)}
+	chunker := filechunk.NewBuilder(store, filechunk.DefaultParams())
+	builder := commit.NewCommitBuilder(store, history.NewMMR())
+
+	seal := func(contents map[string]string, parents ...cas.Hash) cas.Hash {
+		var files []wsindex.FileMetadata
+		for path, content := range contents {
+			ref, err := chunker.Build([]byte(content))
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			if content != "" {
+				store.watch[ref.Hash] = true
+			}
+			files = append(files, wsindex.FileMetadata{Path: path, FileRef: ref, Size: ref.Size})
+		}
+		commitObj, err := builder.CreateCommit(files, parents, "a", "a", "seal")
+		if err != nil {
+			t.Fatalf("CreateCommit failed: %v", err)
+		}
+		return builder.GetCommitHash(commitObj)
+	}
+
+	tree := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		tree[fmt.Sprintf("dir%d/file%d.txt", i%10, i)] = fmt.Sprintf("content %d\n", i)
+	}
+	parent := seal(tree)
+	rs := &RepoSyncer{casStore: store}
+
+	// A large unchanged tree is compared without reading any file
+	unchanged := seal(tree, parent)
+	store.reads = 0
+	changes, err := rs.computeFileDeltas(parent, unchanged)
+	if err != nil {
+		t.Fatalf("computeFileDeltas failed: %v", err)
+	}
+	if len(changes) != 0 || store.reads != 0 {
+		t.Errorf("Unchanged tree: %d changes, %d content reads", len(changes), store.reads)
+	}
+
+	tree["dir0/file0.txt"] = "changed\n"
+	tree["run.sh"] = "#!/bin/sh\necho hi\n"
+	tree["empty.txt"] = ""
+	delete(tree, "dir1/file1.txt")
+	current := seal(tree, unchanged)
+	store.reads = 0
+	changes, err = rs.computeFileDeltas(unchanged, current)
+	if err != nil {
+		t.Fatalf("computeFileDeltas failed: %v", err)
+	}
+	// Only the two files with content were read
+	if store.reads != 2 {
+		t.Errorf("Expected 2 content reads, got %d", store.reads)
+	}
+
+	got := make(map[string]FileChange)
+	for _, change := range changes {
+		got[change.Path] = change
+	}
+	if len(got) != 4 {
+		t.Fatalf("Expected 4 changes, got %+v", changes)
+	}
+	if c := got["dir0/file0.txt"]; c.Type != "modified" || string(c.Content) != "changed\n" || c.Mode != "100644" {
+		t.Errorf("Unexpected change for the modified file: %+v", c)
+	}
+	if c := got["run.sh"]; c.Type != "added" || c.Mode != "100755" {
+		t.Errorf("Unexpected change for the script: %+v", c)
+	}
+	if c := got["empty.txt"]; c.Type != "added" || c.Content == nil || len(c.Content) != 0 {
+		t.Errorf("Unexpected change for the empty file: %+v", c)
+	}
+	if c := got["dir1/file1.txt"]; c.Type != "deleted" {
+		t.Errorf("Unexpected change for the deleted file: %+v", c)
+	}
+}
+
+// fakeTreeServer serves the Git trees API from memory. A tree is a map of
+// path to blob SHA; an entry without a SHA deletes its path from the base.
+type fakeTreeServer struct {
+	trees    map[string]map[string]string
+	requests []CreateTreeRequest
+	created  []string // SHA of the tree each request created
+}
+
+func (f *fakeTreeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/repos/owner/repo/git/trees" {
+		http.NotFound(w, r)
+		return
+	}
+	var req CreateTreeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.requests = append(f.requests, req)
+
+	tree := make(map[string]string)
+	if req.BaseTree != "" {
+		base, ok := f.trees[req.BaseTree]
+		if !ok {
+			http.Error(w, "unknown base tree", http.StatusUnprocessableEntity)
+			return
+		}
+		for path, sha := range base {
+			tree[path] = sha
+		}
+	}
+	for _, entry := range req.Tree {
+		if entry.SHA == "" {
+			delete(tree, entry.Path)
+		} else {
+			tree[entry.Path] = entry.SHA
+		}
+	}
+	sha := fmt.Sprintf("%040d", len(f.trees)+1)
+	f.trees[sha] = tree
+	f.created = append(f.created, sha)
+	json.NewEncoder(w).Encode(TreeResponse{SHA: sha})
+}
+
+func TestCreateTreeChunked(t *testing.T) {
+	fake := &fakeTreeServer{trees: map[string]map[string]string{
+		"base": {"keep.txt": "k", "gone.txt": "g", "changed.txt": "old"},
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	rs := newDownloadSyncer(server.URL, t.TempDir())
+
+	// A delta touching more files than one request may carry
+	entries := []GitTreeEntry{
+		{Path: "gone.txt", Mode: "100644", Type: "blob"},
+		{Path: "changed.txt", Mode: "100644", Type: "blob", SHA: "new"},
+	}
+	want := map[string]string{"keep.txt": "k", "changed.txt": "new"}
+	for i := 0; i < 2*maxTreeEntries+500; i++ {
+		path := fmt.Sprintf("dir%02d/file%05d.txt", i%50, i)
+		entries = append(entries, GitTreeEntry{Path: path, Mode: "100644", Type: "blob", SHA: fmt.Sprintf("%040d", i)})
+		want[path] = fmt.Sprintf("%040d", i)
+	}
+
+	resp, err := rs.createTree(context.Background(), "owner", "repo", entries, "base")
+	if err != nil {
+		t.Fatalf("createTree failed: %v", err)
+	}
+	if len(fake.requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(fake.requests))
+	}
+	for i, req := range fake.requests {
+		if len(req.Tree) > maxTreeEntries {
+			t.Errorf("Request %d carried %d entries, more than %d", i, len(req.Tree), maxTreeEntries)
+		}
+		if i > 0 && req.BaseTree != fake.created[i-1] {
+			t.Errorf("Expected request %d to build on the tree of request %d, got %q", i, i-1, req.BaseTree)
+		}
+	}
+	if fake.requests[0].BaseTree != "base" {
+		t.Errorf("Expected the first request to build on the base tree, got %q", fake.requests[0].BaseTree)
+	}
+	got := fake.trees[resp.SHA]
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d files in the final tree, got %d", len(want), len(got))
+	}
+	for path, sha := range want {
+		if got[path] != sha {
+			t.Errorf("Expected %s to be %s, got %q", path, sha, got[path])
+		}
+	}
+
+	// Small changesets still take a single request
+	fake.requests = nil
+	if _, err := rs.createTree(context.Background(), "owner", "repo", entries[:2], "base"); err != nil {
+		t.Fatalf("createTree failed: %v", err)
+	}
+	if len(fake.requests) != 1 {
+		t.Errorf("Expected 1 request, got %d", len(fake.requests))
+	}
+}
@@ -0,0 +1,259 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+// TagPush describes a local tag to create on GitHub
+type TagPush struct {
+	Name   string
+	Commit cas.Hash // Tagged Ivaldi commit
+	GitSHA string   // Git commit recorded with the tag, used when Commit has no mapping
+
+	// A non-empty Message makes the tag annotated: a tag object carrying
+	// the message is created and the reference points at it
+	Message string
+	Tagger  *GitUser
+}
+
+// ErrTagExists is returned when a tag already exists on GitHub and points
+// somewhere else. Tags are never moved.
+var ErrTagExists = errors.New("tag already exists on GitHub with a different target")
+
+// PushTag creates refs/tags/<name> on GitHub for the commit the tag points
+// at, which must already have been uploaded or fetched. It reports whether
+// the tag was created; a tag that already exists with the same target is
+// left alone.
+func (rs *RepoSyncer) PushTag(ctx context.Context, owner, repo string, tag TagPush) (bool, error) {
+	commitSHA, err := rs.remoteCommitSHA(tag)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := rs.client.GetRef(ctx, owner, repo, "tags/"+tag.Name)
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		target := existing.Object.SHA
+		if existing.Object.Type == "tag" {
+			tagObj, err := rs.client.GetTag(ctx, owner, repo, existing.Object.SHA)
+			if err != nil {
+				return false, fmt.Errorf("failed to read tag %s on GitHub: %w", tag.Name, err)
+			}
+			target = tagObj.Object.SHA
+		}
+		if target != commitSHA {
+			return false, fmt.Errorf("%s: %w", tag.Name, ErrTagExists)
+		}
+		return false, nil
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		// Not on GitHub yet
+	default:
+		return false, fmt.Errorf("failed to look up tag %s on GitHub: %w", tag.Name, err)
+	}
+
+	refSHA := commitSHA
+	if tag.Message != "" {
+		tagObj, err := rs.client.CreateTag(ctx, owner, repo, CreateTagRequest{
+			Tag:     tag.Name,
+			Message: tag.Message,
+			Object:  commitSHA,
+			Type:    "commit",
+			Tagger:  tag.Tagger,
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to create tag object for %s: %w", tag.Name, err)
+		}
+		refSHA = tagObj.SHA
+	}
+
+	if err := rs.client.CreateRef(ctx, owner, repo, "refs/tags/"+tag.Name, refSHA); err != nil {
+		return false, fmt.Errorf("failed to create tag %s: %w", tag.Name, err)
+	}
+	return true, nil
+}
+
+// remoteCommitSHA maps a tagged commit to its Git commit on GitHub
+func (rs *RepoSyncer) remoteCommitSHA(tag TagPush) (string, error) {
+	if tag.Commit != (cas.Hash{}) {
+		refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to initialize refs manager: %w", err)
+		}
+		defer refsManager.Close()
+
+		var hashArray [32]byte
+		copy(hashArray[:], tag.Commit[:])
+		if gitSHA, err := refsManager.LookupGitHashByBlake3(hashArray); err == nil {
+			return gitSHA, nil
+		}
+	}
+
+	if tag.GitSHA != "" {
+		return tag.GitSHA, nil
+	}
+	if tag.Commit == (cas.Hash{}) {
+		return "", fmt.Errorf("tag %s does not point at a commit", tag.Name)
+	}
+	return "", fmt.Errorf("commit %s of tag %s is not on GitHub; upload a timeline containing it first",
+		tag.Commit.String()[:8], tag.Name)
+}
+
+// FetchedTagDescription starts the description of tags fetched from
+// GitHub. Only tags with it are moved or pruned by later fetches; tags made
+// locally are left alone.
+const FetchedTagDescription = "Fetched from GitHub"
+
+// maxTagChain bounds how many tag objects are followed to reach a commit
+const maxTagChain = 8
+
+// TagFetchResult summarizes a tag fetch. All lists are sorted.
+type TagFetchResult struct {
+	Created []string
+	Updated []string // Fetched tags that were moved upstream
+	Pruned  []string
+	// Unfetched lists tags whose commit has not been fetched yet
+	Unfetched []string
+	// Conflicts lists local tags that point elsewhere and were left alone
+	Conflicts []string
+}
+
+// FetchTags imports the tags of a repository into local tags. A tag is
+// imported when the commit it points at has been fetched; annotated tags
+// also get a tag object holding their tagger and message. With prune,
+// fetched tags that no longer exist on GitHub are removed.
+func (rs *RepoSyncer) FetchTags(ctx context.Context, owner, repo string, prune bool) (*TagFetchResult, error) {
+	tagRefs, err := rs.client.ListTagRefs(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	result := &TagFetchResult{}
+	remote := make(map[string]bool, len(tagRefs))
+	for _, ref := range tagRefs {
+		name := strings.TrimPrefix(ref.Ref, "refs/tags/")
+		remote[name] = true
+
+		commitSHA, annotation, err := rs.peelTag(ctx, owner, repo, ref.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tag %s: %w", name, err)
+		}
+		if commitSHA == "" {
+			continue // Tags of trees and blobs have no seal to point at
+		}
+		hash, _, err := refsManager.LookupByGitHash(commitSHA)
+		if err != nil || hash == [32]byte{} {
+			result.Unfetched = append(result.Unfetched, name)
+			continue
+		}
+
+		existing, err := refsManager.GetTimeline(name, refs.TagTimeline)
+		switch {
+		case err != nil:
+		case existing.Blake3Hash == hash && existing.GitSHA1Hash == ref.Object.SHA:
+			continue
+		case !strings.HasPrefix(existing.Description, FetchedTagDescription):
+			result.Conflicts = append(result.Conflicts, name)
+			continue
+		}
+
+		description := FetchedTagDescription
+		if annotation != nil {
+			if err := rs.storeTagObject(refsManager, name, ref.Object.SHA, cas.Hash(hash), annotation); err != nil {
+				return nil, err
+			}
+			subject, _, _ := strings.Cut(strings.TrimSpace(annotation.Message), "\n")
+			if subject != "" {
+				description += ": " + subject
+			}
+		}
+		if err := refsManager.CreateTimeline(name, refs.TagTimeline, hash, [32]byte{}, ref.Object.SHA, description); err != nil {
+			return nil, fmt.Errorf("failed to create tag %s: %w", name, err)
+		}
+		if existing == nil {
+			result.Created = append(result.Created, name)
+		} else {
+			result.Updated = append(result.Updated, name)
+		}
+	}
+
+	if prune {
+		local, err := refsManager.ListTimelines(refs.TagTimeline)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list local tags: %w", err)
+		}
+		for _, tag := range local {
+			if remote[tag.Name] || !strings.HasPrefix(tag.Description, FetchedTagDescription) {
+				continue
+			}
+			if err := refsManager.RemoveTimeline(tag.Name, refs.TagTimeline); err != nil {
+				return nil, fmt.Errorf("failed to remove tag %s: %w", tag.Name, err)
+			}
+			result.Pruned = append(result.Pruned, tag.Name)
+		}
+	}
+
+	for _, list := range [][]string{result.Created, result.Updated, result.Pruned, result.Unfetched, result.Conflicts} {
+		sort.Strings(list)
+	}
+	return result, nil
+}
+
+// peelTag follows a tag reference to the commit it names. It returns the
+// commit SHA, or "" for tags of other objects, and the outermost tag object
+// for annotated tags.
+func (rs *RepoSyncer) peelTag(ctx context.Context, owner, repo string, object GitObject) (string, *TagObject, error) {
+	var annotation *TagObject
+	for i := 0; object.Type == "tag"; i++ {
+		if i == maxTagChain {
+			return "", nil, fmt.Errorf("more than %d nested tag objects", maxTagChain)
+		}
+		tagObj, err := rs.client.GetTag(ctx, owner, repo, object.SHA)
+		if err != nil {
+			return "", nil, err
+		}
+		if annotation == nil {
+			annotation = tagObj
+		}
+		object = tagObj.Object
+	}
+	if object.Type != "commit" {
+		return "", nil, nil
+	}
+	return object.SHA, annotation, nil
+}
+
+// storeTagObject stores an annotated tag as an Ivaldi tag object, recorded
+// under the Git SHA of the tag object on GitHub
+func (rs *RepoSyncer) storeTagObject(refsManager *refs.RefsManager, name, tagSHA string, target cas.Hash, annotation *TagObject) error {
+	tag := &commit.TagObject{Target: target, Name: name, Message: annotation.Message}
+	if annotation.Tagger != nil {
+		tag.Tagger = fmt.Sprintf("%s <%s>", annotation.Tagger.Name, annotation.Tagger.Email)
+		tag.TagTime = annotation.Tagger.Date
+	}
+	hash, err := commit.StoreTag(rs.casStore, tag)
+	if err != nil {
+		return err
+	}
+	var hashArray [32]byte
+	copy(hashArray[:], hash[:])
+	if err := refsManager.MapGitHashToBlake3(tagSHA, hashArray, [32]byte{}); err != nil {
+		return fmt.Errorf("failed to record tag object of %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+// fakeTagServer serves the reference and tag endpoints used by PushTag and
+// FetchTags
+type fakeTagServer struct {
+	refs    map[string]GitObject // "tags/<name>" -> target
+	tags    map[string]TagObject // tag object SHA -> tag
+	created []map[string]string
+}
+
+func (f *fakeTagServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const prefix = "/repos/owner/repo/git/"
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+
+	switch {
+	case r.Method == "GET" && path == "matching-refs/tags":
+		var tagRefs []Reference
+		for name, object := range f.refs {
+			if strings.HasPrefix(name, "tags/") {
+				tagRefs = append(tagRefs, Reference{Ref: "refs/" + name, Object: object})
+			}
+		}
+		json.NewEncoder(w).Encode(tagRefs)
+	case r.Method == "GET" && strings.HasPrefix(path, "ref/"):
+		name := strings.TrimPrefix(path, "ref/")
+		object, ok := f.refs[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(Reference{Ref: "refs/" + name, Object: object})
+	case r.Method == "GET" && strings.HasPrefix(path, "tags/"):
+		tag, ok := f.tags[strings.TrimPrefix(path, "tags/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(tag)
+	case r.Method == "POST" && path == "tags":
+		var req CreateTagRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		tag := TagObject{SHA: "tagobject", Tag: req.Tag, Message: req.Message, Object: GitObject{SHA: req.Object, Type: req.Type}}
+		f.tags[tag.SHA] = tag
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(tag)
+	case r.Method == "POST" && path == "refs":
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		f.created = append(f.created, req)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Reference{Ref: req["ref"], Object: GitObject{SHA: req["sha"]}})
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestPushTag(t *testing.T) {
+	ivaldiDir := t.TempDir()
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer refsManager.Close()
+
+	var uploaded, other cas.Hash
+	uploaded[0], other[0] = 1, 2
+	var hashArray [32]byte
+	copy(hashArray[:], uploaded[:])
+	if err := refsManager.MapGitHashToBlake3("c0ffee", hashArray, [32]byte{}); err != nil {
+		t.Fatalf("MapGitHashToBlake3 failed: %v", err)
+	}
+
+	fake := &fakeTagServer{
+		refs: map[string]GitObject{
+			"tags/same":  {SHA: "c0ffee", Type: "commit"},
+			"tags/moved": {SHA: "beef", Type: "commit"},
+		},
+		tags: map[string]TagObject{},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: ivaldiDir,
+	}
+	ctx := context.Background()
+
+	created, err := rs.PushTag(ctx, "owner", "repo", TagPush{Name: "v1.0", Commit: uploaded})
+	if err != nil || !created {
+		t.Fatalf("Expected v1.0 to be created, got created=%v err=%v", created, err)
+	}
+	if len(fake.created) != 1 || fake.created[0]["ref"] != "refs/tags/v1.0" || fake.created[0]["sha"] != "c0ffee" {
+		t.Errorf("Expected refs/tags/v1.0 at c0ffee, got %v", fake.created)
+	}
+
+	// An annotated tag's reference points at the tag object
+	created, err = rs.PushTag(ctx, "owner", "repo", TagPush{Name: "v2.0", Commit: uploaded, Message: "Release 2.0"})
+	if err != nil || !created {
+		t.Fatalf("Expected v2.0 to be created, got created=%v err=%v", created, err)
+	}
+	if last := fake.created[len(fake.created)-1]; last["sha"] != "tagobject" || fake.tags["tagobject"].Object.SHA != "c0ffee" {
+		t.Errorf("Expected refs/tags/v2.0 at a tag object for c0ffee, got %v", last)
+	}
+
+	created, err = rs.PushTag(ctx, "owner", "repo", TagPush{Name: "same", Commit: uploaded})
+	if err != nil || created {
+		t.Errorf("Expected an existing tag with the same target to be left alone, got created=%v err=%v", created, err)
+	}
+
+	if _, err := rs.PushTag(ctx, "owner", "repo", TagPush{Name: "moved", Commit: uploaded}); !errors.Is(err, ErrTagExists) {
+		t.Errorf("Expected ErrTagExists for a tag with another target, got %v", err)
+	}
+
+	if _, err := rs.PushTag(ctx, "owner", "repo", TagPush{Name: "local", Commit: other}); err == nil {
+		t.Error("Expected an error for a commit that was never uploaded")
+	}
+
+	// A tag imported from Git falls back to its recorded Git SHA
+	created, err = rs.PushTag(ctx, "owner", "repo", TagPush{Name: "imported", Commit: other, GitSHA: "abc123"})
+	if err != nil || !created {
+		t.Fatalf("Expected imported to be created, got created=%v err=%v", created, err)
+	}
+	if last := fake.created[len(fake.created)-1]; last["sha"] != "abc123" {
+		t.Errorf("Expected refs/tags/imported at abc123, got %v", last)
+	}
+}
+
+func TestFetchTags(t *testing.T) {
+	ivaldiDir := t.TempDir()
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer refsManager.Close()
+
+	var fetched, other [32]byte
+	fetched[0], other[0] = 1, 2
+	if err := refsManager.MapGitHashToBlake3("c0ffee", fetched, [32]byte{}); err != nil {
+		t.Fatalf("MapGitHashToBlake3 failed: %v", err)
+	}
+	// A tag made locally and one left over from an earlier fetch
+	if err := refsManager.CreateTimeline("local", refs.TagTimeline, other, [32]byte{}, "", "My tag"); err != nil {
+		t.Fatalf("CreateTimeline failed: %v", err)
+	}
+	if err := refsManager.CreateTimeline("deleted", refs.TagTimeline, fetched, [32]byte{}, "c0ffee", FetchedTagDescription); err != nil {
+		t.Fatalf("CreateTimeline failed: %v", err)
+	}
+
+	tagged := time.Unix(1700000000, 0)
+	fake := &fakeTagServer{
+		refs: map[string]GitObject{
+			"tags/v1.0":      {SHA: "c0ffee", Type: "commit"},
+			"tags/v2.0":      {SHA: "tagsha", Type: "tag"},
+			"tags/local":     {SHA: "c0ffee", Type: "commit"},
+			"tags/unfetched": {SHA: "beef", Type: "commit"},
+		},
+		tags: map[string]TagObject{
+			"tagsha": {SHA: "tagsha", Tag: "v2.0", Message: "Release 2.0\n\nNotes", Object: GitObject{SHA: "c0ffee", Type: "commit"},
+				Tagger: &GitUser{Name: "Jane", Email: "jane@example.com", Date: tagged}},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	casStore := cas.NewMemoryCAS()
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: ivaldiDir,
+		casStore:  casStore,
+	}
+
+	result, err := rs.FetchTags(context.Background(), "owner", "repo", true)
+	if err != nil {
+		t.Fatalf("FetchTags failed: %v", err)
+	}
+	want := &TagFetchResult{
+		Created:   []string{"v1.0", "v2.0"},
+		Pruned:    []string{"deleted"},
+		Unfetched: []string{"unfetched"},
+		Conflicts: []string{"local"},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Expected %+v, got %+v", want, result)
+	}
+
+	v2, err := refsManager.GetTimeline("v2.0", refs.TagTimeline)
+	if err != nil || v2.Blake3Hash != fetched || v2.Description != FetchedTagDescription+": Release 2.0" {
+		t.Fatalf("Expected v2.0 to point at the fetched commit, got %+v (%v)", v2, err)
+	}
+	tagHash, _, err := refsManager.LookupByGitHash("tagsha")
+	if err != nil {
+		t.Fatalf("Expected the tag object to be recorded: %v", err)
+	}
+	tagObj, err := commit.ReadTag(casStore, cas.Hash(tagHash))
+	if err != nil || tagObj.Tagger != "Jane <jane@example.com>" || !tagObj.TagTime.Equal(tagged) || tagObj.Message != "Release 2.0\n\nNotes" {
+		t.Errorf("Unexpected tag object %+v (%v)", tagObj, err)
+	}
+	if local, _ := refsManager.GetTimeline("local", refs.TagTimeline); local == nil || local.Blake3Hash != other {
+		t.Error("Expected the local tag to be left alone")
+	}
+
+	// A second fetch has nothing to do
+	result, err = rs.FetchTags(context.Background(), "owner", "repo", true)
+	if err != nil || len(result.Created)+len(result.Updated)+len(result.Pruned) != 0 {
+		t.Errorf("Expected no changes on a second fetch, got %+v (%v)", result, err)
+	}
+}
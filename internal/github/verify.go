@@ -0,0 +1,267 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/workspace"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// maxReportedTreeMismatches bounds the paths listed in a verification error
+const maxReportedTreeMismatches = 10
+
+// TreeMismatchError reports a pushed tree whose files differ from the local
+// commit, e.g. because a blob was silently dropped during upload
+type TreeMismatchError struct {
+	TreeSHA    string
+	Missing    []string // Files of the local commit absent from the remote tree
+	Unexpected []string // Files of the remote tree absent from the local commit
+	Different  []string // Files whose remote blob SHA differs from the local content
+}
+
+func (e *TreeMismatchError) Error() string {
+	var parts []string
+	describe := func(label string, paths []string) {
+		if len(paths) == 0 {
+			return
+		}
+		shown := paths
+		if len(shown) > maxReportedTreeMismatches {
+			shown = shown[:maxReportedTreeMismatches]
+		}
+		part := fmt.Sprintf("%d %s (%s", len(paths), label, strings.Join(shown, ", "))
+		if len(paths) > len(shown) {
+			part += fmt.Sprintf(", and %d more", len(paths)-len(shown))
+		}
+		parts = append(parts, part+")")
+	}
+	describe("missing", e.Missing)
+	describe("unexpected", e.Unexpected)
+	describe("different", e.Different)
+	return fmt.Sprintf("remote tree %s does not match the local commit: %s", e.TreeSHA[:min(7, len(e.TreeSHA))], strings.Join(parts, "; "))
+}
+
+// verifyPushedTree checks that a tree on GitHub holds exactly the files of a
+// local commit, comparing each path's blob SHA with the Git blob SHA of the
+// local content
+func (rs *RepoSyncer) verifyPushedTree(ctx context.Context, owner, repo, treeSHA string, commitHash cas.Hash) error {
+	local, err := rs.localBlobSHAs(commitHash)
+	if err != nil {
+		return err
+	}
+
+	remoteTree, err := rs.getTree(ctx, owner, repo, treeSHA)
+	if err != nil {
+		return fmt.Errorf("failed to get remote tree: %w", err)
+	}
+	if remoteTree.Truncated {
+		return fmt.Errorf("remote tree %s is too large to list, so it cannot be verified", treeSHA[:min(7, len(treeSHA))])
+	}
+
+	mismatch := &TreeMismatchError{TreeSHA: treeSHA}
+	seen := make(map[string]bool, len(local))
+	for _, entry := range remoteTree.Tree {
+		if entry.Type != "blob" {
+			continue
+		}
+		sha, ok := local[entry.Path]
+		if !ok {
+			mismatch.Unexpected = append(mismatch.Unexpected, entry.Path)
+			continue
+		}
+		seen[entry.Path] = true
+		if entry.SHA != sha {
+			mismatch.Different = append(mismatch.Different, entry.Path)
+		}
+	}
+	for path := range local {
+		if !seen[path] {
+			mismatch.Missing = append(mismatch.Missing, path)
+		}
+	}
+
+	if len(mismatch.Missing)+len(mismatch.Unexpected)+len(mismatch.Different) == 0 {
+		return nil
+	}
+	sort.Strings(mismatch.Missing)
+	sort.Strings(mismatch.Unexpected)
+	sort.Strings(mismatch.Different)
+	return mismatch
+}
+
+// localBlobSHAs maps each file of a local commit to its Git blob SHA
+func (rs *RepoSyncer) localBlobSHAs(commitHash cas.Hash) (map[string]string, error) {
+	commitReader := commit.NewCommitReader(rs.casStore)
+	commitObj, err := commitReader.ReadCommit(commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit: %w", err)
+	}
+	tree, err := commitReader.ReadTree(commitObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree: %w", err)
+	}
+	files, err := commitReader.ListFiles(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	shas := make(map[string]string, len(files))
+	for _, filePath := range files {
+		content, err := commitReader.GetFileContent(tree, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content for %s: %w", filePath, err)
+		}
+		shas[filePath] = computeGitBlobSHA(content)
+	}
+	return shas, nil
+}
+
+// CloneVerification is the result of comparing the working directory with
+// the tree of a remote branch
+type CloneVerification struct {
+	CommitSHA  string   // The remote commit whose tree was compared
+	Files      int      // Files in the remote tree
+	Mismatched []string // Files whose local content differs from the remote blob
+	Missing    []string // Files of the remote tree absent from the working directory
+	Extra      []string // Files of the working directory absent from the remote tree
+	Fixed      []string // Mismatched and missing files downloaded again
+	FixErrors  []error  // Files that could not be downloaded again or still differ
+}
+
+// Matches reports whether the working directory held exactly the remote
+// files before any were fixed
+func (v *CloneVerification) Matches() bool {
+	return len(v.Mismatched)+len(v.Missing)+len(v.Extra) == 0
+}
+
+// VerifyClone compares each file of the working directory with the tree of
+// a remote branch, by the Git blob SHA of its content. The tree is the one
+// of the commit last recorded for the branch's remote timeline, which is
+// what download or the last pull or fetch brought in, or of the branch head
+// when none is recorded. With fix set, mismatched and missing files are
+// downloaded again; extra files are only reported.
+func (rs *RepoSyncer) VerifyClone(ctx context.Context, owner, repo, branch string, fix bool) (*CloneVerification, error) {
+	commitSHA, err := rs.recordedRemoteHead(branch)
+	if err != nil {
+		return nil, err
+	}
+	if commitSHA == "" {
+		branchInfo, err := rs.client.GetBranch(ctx, owner, repo, branch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get remote branch info: %w", err)
+		}
+		commitSHA = branchInfo.Commit.SHA
+	}
+
+	remoteTree, err := rs.getTree(ctx, owner, repo, commitSHA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote tree: %w", err)
+	}
+	if remoteTree.Truncated {
+		return nil, fmt.Errorf("remote tree of %s is too large to list, so it cannot be verified", commitSHA[:min(7, len(commitSHA))])
+	}
+
+	local, err := rs.workingBlobSHAs()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CloneVerification{CommitSHA: commitSHA}
+	var toFix []TreeEntry
+	seen := make(map[string]bool, len(local))
+	for _, entry := range remoteTree.Tree {
+		if entry.Type != "blob" {
+			continue
+		}
+		result.Files++
+		seen[entry.Path] = true
+		sha, ok := local[entry.Path]
+		switch {
+		case !ok:
+			result.Missing = append(result.Missing, entry.Path)
+		case sha != entry.SHA:
+			result.Mismatched = append(result.Mismatched, entry.Path)
+		default:
+			continue
+		}
+		toFix = append(toFix, entry)
+	}
+	for path := range local {
+		if !seen[path] {
+			result.Extra = append(result.Extra, path)
+		}
+	}
+	sort.Strings(result.Mismatched)
+	sort.Strings(result.Missing)
+	sort.Strings(result.Extra)
+
+	if !fix {
+		return result, nil
+	}
+	sort.Slice(toFix, func(i, j int) bool { return toFix[i].Path < toFix[j].Path })
+	for _, entry := range toFix {
+		if err := rs.downloadFile(ctx, owner, repo, entry, commitSHA); err != nil {
+			result.FixErrors = append(result.FixErrors, fmt.Errorf("failed to download %s: %w", entry.Path, err))
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(rs.workDir, entry.Path))
+		if err != nil {
+			result.FixErrors = append(result.FixErrors, fmt.Errorf("failed to read %s: %w", entry.Path, err))
+			continue
+		}
+		if computeGitBlobSHA(content) != entry.SHA {
+			result.FixErrors = append(result.FixErrors, fmt.Errorf("%s still differs after downloading it again", entry.Path))
+			continue
+		}
+		result.Fixed = append(result.Fixed, entry.Path)
+	}
+	return result, nil
+}
+
+// recordedRemoteHead returns the Git SHA recorded for a remote timeline,
+// or "" when there is none
+func (rs *RepoSyncer) recordedRemoteHead(branch string) (string, error) {
+	refsManager, err := refs.NewRefsManager(rs.ivaldiDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to create refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	timeline, err := refsManager.GetTimeline(branch, refs.RemoteTimeline)
+	if err != nil {
+		return "", nil
+	}
+	return timeline.GitSHA1Hash, nil
+}
+
+// workingBlobSHAs maps each file of the working directory to the Git blob
+// SHA of its content
+func (rs *RepoSyncer) workingBlobSHAs() (map[string]string, error) {
+	materializer := workspace.NewMaterializer(rs.casStore, rs.ivaldiDir, rs.workDir)
+	wsIndex, err := materializer.ScanWorkspace()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan workspace: %w", err)
+	}
+	files, err := wsindex.NewLoader(rs.casStore).ListAll(wsIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace files: %w", err)
+	}
+
+	shas := make(map[string]string, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(rs.workDir, file.Path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		shas[file.Path] = computeGitBlobSHA(content)
+	}
+	return shas, nil
+}
@@ -0,0 +1,190 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// createVerifyCommit seals files into a commit stored in casStore
+func createVerifyCommit(t *testing.T, casStore cas.CAS, files map[string]string) cas.Hash {
+	fileBuilder := filechunk.NewBuilder(casStore, filechunk.DefaultParams())
+
+	var metadata []wsindex.FileMetadata
+	for path, content := range files {
+		fileRef, err := fileBuilder.Build([]byte(content))
+		if err != nil {
+			t.Fatalf("Failed to store %s: %v", path, err)
+		}
+		metadata = append(metadata, wsindex.FileMetadata{
+			Path:     path,
+			FileRef:  fileRef,
+			ModTime:  time.Unix(1700000000, 0),
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Checksum: cas.SumB3([]byte(content)),
+		})
+	}
+
+	builder := commit.NewCommitBuilder(casStore, history.NewMMR())
+	commitObj, err := builder.CreateCommit(metadata, nil, "Test <test@example.com>", "Test <test@example.com>", "Test commit")
+	if err != nil {
+		t.Fatalf("CreateCommit failed: %v", err)
+	}
+	return builder.GetCommitHash(commitObj)
+}
+
+func TestVerifyPushedTree(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	commitHash := createVerifyCommit(t, casStore, map[string]string{
+		"README.md":   "# Test\n",
+		"src/main.go": "package main\n",
+		"src/util.go": "package main\n\nfunc util() {}\n",
+	})
+
+	trees := map[string]Tree{
+		"good": {SHA: "good", Tree: []TreeEntry{
+			{Path: "README.md", Type: "blob", SHA: computeGitBlobSHA([]byte("# Test\n"))},
+			{Path: "src", Type: "tree", SHA: "0000000"},
+			{Path: "src/main.go", Type: "blob", SHA: computeGitBlobSHA([]byte("package main\n"))},
+			{Path: "src/util.go", Type: "blob", SHA: computeGitBlobSHA([]byte("package main\n\nfunc util() {}\n"))},
+		}},
+		"bad": {SHA: "bad", Tree: []TreeEntry{
+			{Path: "README.md", Type: "blob", SHA: computeGitBlobSHA([]byte("# Other\n"))},
+			{Path: "src/main.go", Type: "blob", SHA: computeGitBlobSHA([]byte("package main\n"))},
+			{Path: "stale.txt", Type: "blob", SHA: computeGitBlobSHA([]byte("stale\n"))},
+		}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tree, ok := trees[r.URL.Path[len("/repos/owner/repo/git/trees/"):]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(tree)
+	}))
+	defer server.Close()
+
+	rs := &RepoSyncer{
+		client:   NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		casStore: casStore,
+	}
+
+	if err := rs.verifyPushedTree(context.Background(), "owner", "repo", "good", commitHash); err != nil {
+		t.Errorf("Expected matching tree to verify, got %v", err)
+	}
+
+	err := rs.verifyPushedTree(context.Background(), "owner", "repo", "bad", commitHash)
+	var mismatch *TreeMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a TreeMismatchError, got %v", err)
+	}
+	if len(mismatch.Missing) != 1 || mismatch.Missing[0] != "src/util.go" {
+		t.Errorf("Expected src/util.go to be missing, got %v", mismatch.Missing)
+	}
+	if len(mismatch.Unexpected) != 1 || mismatch.Unexpected[0] != "stale.txt" {
+		t.Errorf("Expected stale.txt to be unexpected, got %v", mismatch.Unexpected)
+	}
+	if len(mismatch.Different) != 1 || mismatch.Different[0] != "README.md" {
+		t.Errorf("Expected README.md to differ, got %v", mismatch.Different)
+	}
+}
+
+func TestVerifyClone(t *testing.T) {
+	remote := map[string]string{"a.txt": "alpha\n", "b.txt": "beta\n", "src/c.txt": "charlie\n"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path := r.URL.Path; {
+		case path == "/repos/owner/repo/branches/main":
+			var branch Branch
+			branch.Name = "main"
+			branch.Commit.SHA = "c3c3c3c3c3"
+			json.NewEncoder(w).Encode(branch)
+		case path == "/repos/owner/repo/git/trees/c3c3c3c3c3":
+			tree := Tree{SHA: "t3", Tree: []TreeEntry{{Path: "src", Type: "tree", SHA: "0000000"}}}
+			for name, content := range remote {
+				tree.Tree = append(tree.Tree, TreeEntry{Path: name, Type: "blob", SHA: computeGitBlobSHA([]byte(content))})
+			}
+			json.NewEncoder(w).Encode(tree)
+		case strings.HasPrefix(path, "/owner/repo/c3c3c3c3c3/"):
+			content, ok := remote[strings.TrimPrefix(path, "/owner/repo/c3c3c3c3c3/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// b.txt was cut short, src/c.txt never arrived and extra.txt is local
+	workDir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "alpha\n", "b.txt": "be", "extra.txt": "extra\n"} {
+		if err := os.WriteFile(filepath.Join(workDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	rs := &RepoSyncer{
+		client:    NewClientWithToken(Endpoints{Host: "ghe.test", APIURL: server.URL, RawURL: server.URL}, "token"),
+		ivaldiDir: t.TempDir(),
+		workDir:   workDir,
+		casStore:  cas.NewMemoryCAS(),
+	}
+
+	result, err := rs.VerifyClone(context.Background(), "owner", "repo", "main", false)
+	if err != nil {
+		t.Fatalf("VerifyClone failed: %v", err)
+	}
+	if result.Matches() || result.Files != 3 || result.CommitSHA != "c3c3c3c3c3" {
+		t.Errorf("Unexpected verification: %+v", result)
+	}
+	if !reflect.DeepEqual(result.Mismatched, []string{"b.txt"}) {
+		t.Errorf("Expected b.txt to mismatch, got %v", result.Mismatched)
+	}
+	if !reflect.DeepEqual(result.Missing, []string{"src/c.txt"}) {
+		t.Errorf("Expected src/c.txt to be missing, got %v", result.Missing)
+	}
+	if !reflect.DeepEqual(result.Extra, []string{"extra.txt"}) {
+		t.Errorf("Expected extra.txt to be extra, got %v", result.Extra)
+	}
+	if len(result.Fixed) != 0 {
+		t.Errorf("Expected nothing to be fixed without fix, got %v", result.Fixed)
+	}
+
+	result, err = rs.VerifyClone(context.Background(), "owner", "repo", "main", true)
+	if err != nil {
+		t.Fatalf("VerifyClone with fix failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Fixed, []string{"b.txt", "src/c.txt"}) || len(result.FixErrors) != 0 {
+		t.Errorf("Expected b.txt and src/c.txt to be fixed, got %v (errors: %v)", result.Fixed, result.FixErrors)
+	}
+	for name, want := range remote {
+		if got, _ := os.ReadFile(filepath.Join(workDir, name)); string(got) != want {
+			t.Errorf("Expected %s to hold %q after fixing, got %q", name, want, got)
+		}
+	}
+
+	// Extra files are left alone
+	result, err = rs.VerifyClone(context.Background(), "owner", "repo", "main", false)
+	if err != nil {
+		t.Fatalf("VerifyClone failed: %v", err)
+	}
+	if len(result.Mismatched)+len(result.Missing) != 0 || !reflect.DeepEqual(result.Extra, []string{"extra.txt"}) {
+		t.Errorf("Expected only extra.txt to remain, got %+v", result)
+	}
+}
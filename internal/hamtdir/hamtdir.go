@@ -8,8 +8,17 @@
 //
 // Canonical Encoding:
 // - Leaf: 0x00 | uvarint(entryCount) | (key_len | key | value_type | value_data)*
-// - Internal: 0x01 | bitmap[4] | childHash[32] * popcount(bitmap)
+// - Internal: 0x02 | bitmap[4] | (childHash[32] | uvarint(childSize)) * popcount(bitmap)
+// - Legacy internal (read-only): 0x01 | bitmap[4] | childHash[32] * popcount(bitmap)
 // - Hash: BLAKE3(canonicalBytes)
+//
+// Internal nodes record the entry count of each child subtree so the size of
+// a directory can be computed from its root node without descending.
+//
+// Adding the child sizes changed the encoding of internal nodes, so a large
+// directory built before then hashes differently from the same directory
+// built now. Code that compares directories must use Loader.Equal rather than
+// comparing hashes when trees of both encodings can meet.
 package hamtdir
 
 import (
@@ -33,6 +42,13 @@ const (
 	SubmoduleEntry
 )
 
+// Node encoding markers.
+const (
+	leafMarker           byte = 0x00
+	legacyInternalMarker byte = 0x01 // internal node without child sizes
+	internalMarker       byte = 0x02
+)
+
 // Entry represents a single directory entry.
 type Entry struct {
 	Name      string
@@ -62,8 +78,9 @@ type Node struct {
 	Entries []Entry // Only for leaf nodes
 
 	// For internal nodes
-	Bitmap   uint32           // 32-bit bitmap indicating which children exist
-	Children map[int]cas.Hash // Map from bit position to child hash
+	Bitmap     uint32           // 32-bit bitmap indicating which children exist
+	Children   map[int]cas.Hash // Map from bit position to child hash
+	ChildSizes map[int]int      // Map from bit position to subtree entry count (nil for legacy nodes)
 }
 
 // Builder constructs directory HAMTs.
@@ -109,6 +126,7 @@ func (b *Builder) buildNode(entries []Entry, depth int) (DirRef, error) {
 	// Build children for each group
 	var bitmap uint32
 	children := make(map[int]cas.Hash)
+	childSizes := make(map[int]int)
 	totalSize := 0
 
 	for chunk, groupEntries := range groups {
@@ -120,14 +138,16 @@ func (b *Builder) buildNode(entries []Entry, depth int) (DirRef, error) {
 		bitPos := int(chunk % 32)
 		bitmap |= (1 << bitPos)
 		children[bitPos] = childRef.Hash
+		childSizes[bitPos] = childRef.Size
 		totalSize += childRef.Size
 	}
 
 	// Create internal node
 	node := &Node{
-		IsLeaf:   false,
-		Bitmap:   bitmap,
-		Children: children,
+		IsLeaf:     false,
+		Bitmap:     bitmap,
+		Children:   children,
+		ChildSizes: childSizes,
 	}
 
 	canonical := b.encodeInternal(node)
@@ -194,7 +214,7 @@ func (b *Builder) hashChunk(name string, depth int) uint32 {
 // encodeLeaf creates canonical encoding for a leaf node.
 func (b *Builder) encodeLeaf(node *Node) []byte {
 	var buf bytes.Buffer
-	buf.WriteByte(0x00) // Leaf marker
+	buf.WriteByte(leafMarker)
 
 	// Write entry count
 	lenBuf := make([]byte, binary.MaxVarintLen64)
@@ -236,16 +256,19 @@ func (b *Builder) encodeLeaf(node *Node) []byte {
 // encodeInternal creates canonical encoding for an internal node.
 func (b *Builder) encodeInternal(node *Node) []byte {
 	var buf bytes.Buffer
-	buf.WriteByte(0x01) // Internal marker
+	buf.WriteByte(internalMarker)
 
 	// Write bitmap (4 bytes, little-endian)
 	binary.Write(&buf, binary.LittleEndian, node.Bitmap)
 
-	// Write child hashes in bit position order
+	// Write child hashes and subtree sizes in bit position order
+	lenBuf := make([]byte, binary.MaxVarintLen64)
 	for bitPos := 0; bitPos < 32; bitPos++ {
 		if (node.Bitmap & (1 << bitPos)) != 0 {
 			if childHash, exists := node.Children[bitPos]; exists {
 				buf.Write(childHash[:])
+				n := binary.PutUvarint(lenBuf, uint64(node.ChildSizes[bitPos]))
+				buf.Write(lenBuf[:n])
 			}
 		}
 	}
@@ -268,42 +291,152 @@ func (l *Loader) Lookup(dir DirRef, name string) (*Entry, error) {
 	return l.lookupNode(dir.Hash, name, 0)
 }
 
-// ListAll returns all entries in the directory (recursively flattened).
-func (l *Loader) ListAll(dir DirRef) ([]Entry, error) {
-	return l.listNode(dir.Hash)
+// Count returns the number of entries in the directory without listing them.
+// For leaf nodes only the entry count header is read; for internal nodes the
+// per-child sizes stored in the encoding are summed. Nodes written in the
+// legacy encoding fall back to counting their children.
+func (l *Loader) Count(dir DirRef) (int, error) {
+	return l.countNode(dir.Hash)
 }
 
-// List returns direct entries in the directory (non-recursive).
-func (l *Loader) List(dir DirRef) ([]Entry, error) {
-	data, err := l.CAS.Get(dir.Hash)
+// countNode counts the entries below a node.
+func (l *Loader) countNode(nodeHash cas.Hash) (int, error) {
+	data, err := l.CAS.Get(nodeHash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get directory node: %w", err)
+		return 0, fmt.Errorf("failed to get node: %w", err)
+	}
+
+	if len(data) == 0 {
+		return 0, fmt.Errorf("empty node data")
+	}
+
+	if data[0] == leafMarker {
+		entryCount, n := binary.Uvarint(data[1:])
+		if n <= 0 {
+			return 0, fmt.Errorf("failed to read entry count")
+		}
+		return int(entryCount), nil
 	}
 
 	node, err := l.decodeNode(data)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	if node.IsLeaf {
-		return node.Entries, nil
+	total := 0
+	for bitPos := 0; bitPos < 32; bitPos++ {
+		if (node.Bitmap & (1 << bitPos)) == 0 {
+			continue
+		}
+
+		if node.ChildSizes != nil {
+			total += node.ChildSizes[bitPos]
+			continue
+		}
+
+		childCount, err := l.countNode(node.Children[bitPos])
+		if err != nil {
+			return 0, err
+		}
+		total += childCount
 	}
 
-	// For internal nodes, collect entries from all children
-	var allEntries []Entry
-	for bitPos := 0; bitPos < 32; bitPos++ {
-		if (node.Bitmap & (1 << bitPos)) != 0 {
-			if childHash, exists := node.Children[bitPos]; exists {
-				childEntries, err := l.listNode(childHash)
-				if err != nil {
-					return nil, err
-				}
-				allEntries = append(allEntries, childEntries...)
+	return total, nil
+}
+
+// Equal reports whether two directories hold the same entries. Equal hashes
+// mean equal directories; different hashes only mean different directories
+// when both sides use the same internal node encoding, so directories mixing
+// the legacy and current encodings are compared entry by entry.
+func (l *Loader) Equal(a, b DirRef) (bool, error) {
+	if a.Hash == b.Hash {
+		return true, nil
+	}
+
+	nodeA, err := l.loadNode(a.Hash)
+	if err != nil {
+		return false, err
+	}
+	nodeB, err := l.loadNode(b.Hash)
+	if err != nil {
+		return false, err
+	}
+
+	// The node layout only depends on the names, so a leaf and an internal
+	// node never hold the same entries
+	if nodeA.IsLeaf != nodeB.IsLeaf {
+		return false, nil
+	}
+	if !nodeA.IsLeaf && (nodeA.ChildSizes == nil) == (nodeB.ChildSizes == nil) {
+		return false, nil
+	}
+
+	entriesA, err := l.List(a)
+	if err != nil {
+		return false, err
+	}
+	entriesB, err := l.List(b)
+	if err != nil {
+		return false, err
+	}
+	if len(entriesA) != len(entriesB) {
+		return false, nil
+	}
+
+	for i := range entriesA {
+		ea, eb := entriesA[i], entriesB[i]
+		if ea.Name != eb.Name || ea.Type != eb.Type {
+			return false, nil
+		}
+		switch ea.Type {
+		case FileEntry:
+			if *ea.File != *eb.File {
+				return false, nil
+			}
+		case DirEntry:
+			equal, err := l.Equal(*ea.Dir, *eb.Dir)
+			if err != nil || !equal {
+				return false, err
+			}
+		case SubmoduleEntry:
+			if ea.Submodule.NodeHash != eb.Submodule.NodeHash {
+				return false, nil
 			}
 		}
 	}
 
-	return allEntries, nil
+	return true, nil
+}
+
+// loadNode reads and decodes a single node.
+func (l *Loader) loadNode(nodeHash cas.Hash) (*Node, error) {
+	data, err := l.CAS.Get(nodeHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node: %w", err)
+	}
+	return l.decodeNode(data)
+}
+
+// ListAll returns all entries of the directory across every HAMT node,
+// sorted by name. Like List, it does not descend into subdirectories; use
+// WalkEntries for that.
+func (l *Loader) ListAll(dir DirRef) ([]Entry, error) {
+	return l.List(dir)
+}
+
+// List returns direct entries in the directory (non-recursive), sorted by
+// name. Internal nodes spread entries over their children by name hash, so
+// the entries are sorted once they are collected.
+func (l *Loader) List(dir DirRef) ([]Entry, error) {
+	entries, err := l.listNode(dir.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sort.SliceIsSorted(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name }) {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	}
+	return entries, nil
 }
 
 // lookupNode recursively searches for an entry by name.
@@ -400,15 +533,22 @@ func (l *Loader) hashChunk(name string, depth int) uint32 {
 	return uint32(chunk)
 }
 
+// DecodeNode decodes the canonical encoding of a single directory node
+// without loading its children.
+func DecodeNode(data []byte) (*Node, error) {
+	return (&Loader{}).decodeNode(data)
+}
+
 // decodeNode decodes canonical bytes into a Node.
 func (l *Loader) decodeNode(data []byte) (*Node, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("empty node data")
 	}
 
-	if data[0] == 0x00 {
+	switch data[0] {
+	case leafMarker:
 		return l.decodeLeaf(data)
-	} else if data[0] == 0x01 {
+	case internalMarker, legacyInternalMarker:
 		return l.decodeInternal(data)
 	}
 
@@ -424,6 +564,9 @@ func (l *Loader) decodeLeaf(data []byte) (*Node, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read entry count: %w", err)
 	}
+	if entryCount > uint64(buf.Len()) {
+		return nil, fmt.Errorf("entry count %d exceeds node size", entryCount)
+	}
 
 	entries := make([]Entry, 0, entryCount)
 
@@ -433,6 +576,9 @@ func (l *Loader) decodeLeaf(data []byte) (*Node, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to read key length: %w", err)
 		}
+		if keyLen > uint64(buf.Len()) {
+			return nil, fmt.Errorf("failed to read key")
+		}
 
 		key := make([]byte, keyLen)
 		n, err := buf.Read(key)
@@ -496,6 +642,9 @@ func (l *Loader) decodeLeaf(data []byte) (*Node, error) {
 
 		entries = append(entries, entry)
 	}
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("trailing data after leaf node")
+	}
 
 	return &Node{
 		IsLeaf:  true,
@@ -522,6 +671,12 @@ func (l *Loader) decodeInternal(data []byte) (*Node, error) {
 	childCount := bits.OnesCount32(bitmap)
 	children := make(map[int]cas.Hash)
 
+	// Only the current encoding carries subtree sizes
+	var childSizes map[int]int
+	if data[0] == internalMarker {
+		childSizes = make(map[int]int)
+	}
+
 	// Read child hashes in bit position order
 	for bitPos := 0; bitPos < 32; bitPos++ {
 		if (bitmap & (1 << bitPos)) != 0 {
@@ -531,17 +686,29 @@ func (l *Loader) decodeInternal(data []byte) (*Node, error) {
 				return nil, fmt.Errorf("failed to read child hash at bit %d", bitPos)
 			}
 			children[bitPos] = hash
+
+			if childSizes != nil {
+				size, err := binary.ReadUvarint(buf)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read child size at bit %d: %w", bitPos, err)
+				}
+				childSizes[bitPos] = int(size)
+			}
 		}
 	}
 
 	if len(children) != childCount {
 		return nil, fmt.Errorf("child count mismatch: expected %d, got %d", childCount, len(children))
 	}
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("trailing data after internal node")
+	}
 
 	return &Node{
-		IsLeaf:   false,
-		Bitmap:   bitmap,
-		Children: children,
+		IsLeaf:     false,
+		Bitmap:     bitmap,
+		Children:   children,
+		ChildSizes: childSizes,
 	}, nil
 }
 
@@ -1,6 +1,7 @@
 package hamtdir
 
 import (
+	"encoding/binary"
 	"fmt"
 	"testing"
 
@@ -467,6 +468,65 @@ func TestLargeDirectory(t *testing.T) {
 	}
 }
 
+func TestListSortedAcrossInternalNodes(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewBuilder(casStore)
+
+	// Enough entries for internal nodes below the root
+	var entries []Entry
+	for i := 999; i >= 0; i-- {
+		name := fmt.Sprintf("entry%04d", i)
+		entries = append(entries, Entry{
+			Name: name,
+			Type: FileEntry,
+			File: &filechunk.NodeRef{Hash: cas.SumB3([]byte(name)), Kind: filechunk.Leaf},
+		})
+	}
+
+	dir, err := builder.Build(entries)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	loader := NewLoader(casStore)
+	data, err := casStore.Get(dir.Hash)
+	if err != nil {
+		t.Fatalf("Get root failed: %v", err)
+	}
+	root, err := loader.decodeNode(data)
+	if err != nil {
+		t.Fatalf("Decode root failed: %v", err)
+	}
+	multiLevel := false
+	for _, childHash := range root.Children {
+		childData, err := casStore.Get(childHash)
+		if err != nil {
+			t.Fatalf("Get child failed: %v", err)
+		}
+		if child, err := loader.decodeNode(childData); err == nil && !child.IsLeaf {
+			multiLevel = true
+		}
+	}
+	if root.IsLeaf || !multiLevel {
+		t.Fatal("Expected a HAMT with internal nodes below the root")
+	}
+
+	for name, list := range map[string]func(DirRef) ([]Entry, error){"List": loader.List, "ListAll": loader.ListAll} {
+		listed, err := list(dir)
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		if len(listed) != 1000 {
+			t.Fatalf("%s: expected 1000 entries, got %d", name, len(listed))
+		}
+		for i, entry := range listed {
+			if want := fmt.Sprintf("entry%04d", i); entry.Name != want {
+				t.Fatalf("%s: entry %d is %s, expected %s", name, i, entry.Name, want)
+			}
+		}
+	}
+}
+
 func TestSameContentSameHash(t *testing.T) {
 	casStore := cas.NewMemoryCAS()
 	builder := NewBuilder(casStore)
@@ -611,4 +671,207 @@ func BenchmarkLookupLargeDir(b *testing.B) {
 			b.Fatalf("Lookup failed: %v", err)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestCount(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewBuilder(casStore)
+	loader := NewLoader(casStore)
+	
+	for _, n := range []int{0, 5, 16, 17, 500} {
+		var entries []Entry
+		for i := 0; i < n; i++ {
+			content := fmt.Sprintf("content %d", i)
+			entries = append(entries, Entry{
+				Name: fmt.Sprintf("file%04d.txt", i),
+				Type: FileEntry,
+				File: &filechunk.NodeRef{
+					Hash: cas.SumB3([]byte(content)),
+					Kind: filechunk.Leaf,
+					Size: int64(len(content)),
+				},
+			})
+		}
+		
+		dir, err := builder.Build(entries)
+		if err != nil {
+			t.Fatalf("Build directory with %d entries failed: %v", n, err)
+		}
+		
+		// Count must not rely on the size carried by the DirRef
+		count, err := loader.Count(DirRef{Hash: dir.Hash})
+		if err != nil {
+			t.Fatalf("Count failed for %d entries: %v", n, err)
+		}
+		if count != n {
+			t.Errorf("Expected count %d, got %d", n, count)
+		}
+	}
+}
+
+func TestCountLegacyInternalNode(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewBuilder(casStore)
+	loader := NewLoader(casStore)
+	
+	// Build two leaves and reference them from an internal node in the legacy encoding
+	var children []cas.Hash
+	for _, names := range [][]string{{"a", "b", "c"}, {"d", "e"}} {
+		var entries []Entry
+		for _, name := range names {
+			entries = append(entries, Entry{
+				Name: name,
+				Type: FileEntry,
+				File: &filechunk.NodeRef{Hash: cas.SumB3([]byte(name)), Kind: filechunk.Leaf, Size: 1},
+			})
+		}
+		ref, err := builder.buildLeaf(entries)
+		if err != nil {
+			t.Fatalf("buildLeaf failed: %v", err)
+		}
+		children = append(children, ref.Hash)
+	}
+	
+	legacy := []byte{legacyInternalMarker, 0x03, 0x00, 0x00, 0x00} // bitmap with bits 0 and 1 set
+	legacy = append(legacy, children[0][:]...)
+	legacy = append(legacy, children[1][:]...)
+	hash := cas.SumB3(legacy)
+	if err := casStore.Put(hash, legacy); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	
+	count, err := loader.Count(DirRef{Hash: hash})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("Expected count 5, got %d", count)
+	}
+	
+	entries, err := loader.List(DirRef{Hash: hash})
+	if err != nil {
+		t.Fatalf("List legacy node failed: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("Expected 5 entries, got %d", len(entries))
+	}
+}
+
+func TestEqualAcrossEncodings(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewBuilder(casStore)
+	loader := NewLoader(casStore)
+	
+	files := func(changed string) []Entry {
+		var entries []Entry
+		for i := 0; i < 40; i++ {
+			name := fmt.Sprintf("file%02d.txt", i)
+			content := name
+			if name == changed {
+				content += " changed"
+			}
+			entries = append(entries, Entry{
+				Name: name,
+				Type: FileEntry,
+				File: &filechunk.NodeRef{Hash: cas.SumB3([]byte(content)), Kind: filechunk.Leaf, Size: int64(len(content))},
+			})
+		}
+		return entries
+	}
+	
+	current, err := builder.Build(files(""))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	changed, err := builder.Build(files("file07.txt"))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	
+	// Re-encode the root of the current tree the way it was written before
+	// internal nodes carried child sizes
+	data, err := casStore.Get(current.Hash)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	root, err := DecodeNode(data)
+	if err != nil {
+		t.Fatalf("DecodeNode failed: %v", err)
+	}
+	if root.IsLeaf {
+		t.Fatal("Expected an internal root node")
+	}
+	legacyData := []byte{legacyInternalMarker}
+	legacyData = binary.LittleEndian.AppendUint32(legacyData, root.Bitmap)
+	for bitPos := 0; bitPos < 32; bitPos++ {
+		if root.Bitmap&(1<<bitPos) != 0 {
+			child := root.Children[bitPos]
+			legacyData = append(legacyData, child[:]...)
+		}
+	}
+	legacy := DirRef{Hash: cas.SumB3(legacyData)}
+	if err := casStore.Put(legacy.Hash, legacyData); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	
+	// Parents whose subdirectory differs only in encoding
+	parent := func(sub DirRef) DirRef {
+		ref, err := builder.Build([]Entry{{Name: "sub", Type: DirEntry, Dir: &sub}})
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		return ref
+	}
+	
+	tests := []struct {
+		name     string
+		a, b     DirRef
+		expected bool
+	}{
+		{"same tree", current, current, true},
+		{"legacy encoding", current, legacy, true},
+		{"legacy subdirectory", parent(current), parent(legacy), true},
+		{"changed file", changed, legacy, false},
+		{"changed file same encoding", changed, current, false},
+		{"changed subdirectory", parent(changed), parent(legacy), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			equal, err := loader.Equal(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Equal failed: %v", err)
+			}
+			if equal != tt.expected {
+				t.Errorf("Expected Equal %v, got %v", tt.expected, equal)
+			}
+		})
+	}
+}
+
+func TestDecodeNode(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	fileRef := &filechunk.NodeRef{Hash: cas.SumB3([]byte("hello")), Kind: filechunk.Leaf, Size: 5}
+	dir, err := NewBuilder(casStore).Build([]Entry{{Name: "a.txt", Type: FileEntry, File: fileRef}})
+	if err != nil {
+		t.Fatalf("Build directory failed: %v", err)
+	}
+
+	data, err := casStore.Get(dir.Hash)
+	if err != nil {
+		t.Fatalf("Get node failed: %v", err)
+	}
+
+	node, err := DecodeNode(data)
+	if err != nil {
+		t.Fatalf("DecodeNode failed: %v", err)
+	}
+	if !node.IsLeaf || len(node.Entries) != 1 || node.Entries[0].Name != "a.txt" || *node.Entries[0].File != *fileRef {
+		t.Errorf("Unexpected decoded node: %+v", node)
+	}
+
+	if _, err := DecodeNode(append(data, 0)); err == nil {
+		t.Error("Expected trailing data to be rejected")
+	}
+	if _, err := DecodeNode([]byte{leafMarker, 0xff, 0xff, 0xff, 0xff, 0x0f}); err == nil {
+		t.Error("Expected an entry count larger than the node to be rejected")
+	}
+}
@@ -405,4 +405,43 @@ func BenchmarkMMRScaling(b *testing.B) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// BenchmarkPersistentAppend compares persisting the MMR after every leaf,
+// as seals do, with appending an imported history in memory and persisting
+// it once
+func BenchmarkPersistentAppend(b *testing.B) {
+	leaves := historyLeaves(500)
+	open := func(b *testing.B) *PersistentMMR {
+		mmr, err := NewPersistentMMR(nil, b.TempDir())
+		if err != nil {
+			b.Fatalf("NewPersistentMMR failed: %v", err)
+		}
+		return mmr
+	}
+
+	b.Run("PerLeaf", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			mmr := open(b)
+			b.StartTimer()
+			for _, leaf := range leaves {
+				if _, _, err := mmr.AppendLeaf(leaf); err != nil {
+					b.Fatalf("AppendLeaf failed: %v", err)
+				}
+			}
+			mmr.Close()
+		}
+	})
+
+	b.Run("Batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			mmr := open(b)
+			b.StartTimer()
+			if _, _, err := mmr.AppendLeaves(leaves); err != nil {
+				b.Fatalf("AppendLeaves failed: %v", err)
+			}
+			mmr.Close()
+		}
+	})
+}
@@ -613,3 +613,74 @@ func TestAutoshelving(t *testing.T) {
 	}
 }
 
+
+func historyLeaves(n int) []Leaf {
+	leaves := make([]Leaf, n)
+	for i := range leaves {
+		leaves[i] = Leaf{
+			TreeRoot:   [32]byte{byte(i), byte(i >> 8)},
+			TimelineID: "main",
+			PrevIdx:    uint64(i) - 1,
+			Author:     "Alice",
+			TimeUnix:   int64(i),
+			Message:    fmt.Sprintf("Commit %d", i),
+		}
+	}
+	leaves[0].PrevIdx = NoParent
+	return leaves
+}
+
+func TestPersistentMMRBatchAppend(t *testing.T) {
+	ivaldiDir := t.TempDir()
+	leaves := historyLeaves(75)
+
+	incremental := NewMMR()
+	for _, leaf := range leaves {
+		if _, _, err := incremental.AppendLeaf(leaf); err != nil {
+			t.Fatalf("AppendLeaf failed: %v", err)
+		}
+	}
+
+	mmr, err := NewPersistentMMR(nil, ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewPersistentMMR failed: %v", err)
+	}
+	// Persisted one at a time, as a batch, and appended in memory then flushed
+	for _, leaf := range leaves[:5] {
+		if _, _, err := mmr.AppendLeaf(leaf); err != nil {
+			t.Fatalf("AppendLeaf failed: %v", err)
+		}
+	}
+	first, _, err := mmr.AppendLeaves(leaves[5:60])
+	if err != nil {
+		t.Fatalf("AppendLeaves failed: %v", err)
+	}
+	if first != 5 {
+		t.Errorf("AppendLeaves started at %d, want 5", first)
+	}
+	for _, leaf := range leaves[60:] {
+		if _, _, err := mmr.MMR.AppendLeaf(leaf); err != nil {
+			t.Fatalf("AppendLeaf failed: %v", err)
+		}
+	}
+	if err := mmr.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	mmr.Close()
+
+	reopened, err := NewPersistentMMR(nil, ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewPersistentMMR failed: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.Size() != uint64(len(leaves)) {
+		t.Fatalf("reopened MMR has %d leaves, want %d", reopened.Size(), len(leaves))
+	}
+	if reopened.Root() != incremental.Root() {
+		t.Errorf("reopened root does not match the incremental root")
+	}
+	leaf, err := reopened.GetLeaf(70)
+	if err != nil || !reflect.DeepEqual(leaf, leaves[70]) {
+		t.Errorf("leaf 70 = %+v, %v; want %+v", leaf, err, leaves[70])
+	}
+}
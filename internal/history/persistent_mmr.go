@@ -15,6 +15,8 @@ type PersistentMMR struct {
 	*MMR
 	cas cas.CAS
 	db  *store.SharedDB
+
+	persisted uint64 // Number of leaves already in storage
 }
 
 // NewPersistentMMR creates a new MMR with persistent storage.
@@ -59,18 +61,94 @@ func (p *PersistentMMR) AppendLeaf(l Leaf) (uint64, Hash, error) {
 	if err := p.persistMMRState(); err != nil {
 		return 0, Hash{}, fmt.Errorf("failed to persist MMR state: %w", err)
 	}
+	if p.persisted == idx {
+		p.persisted = idx + 1
+	}
 
 	return idx, root, nil
 }
 
+// AppendLeaves appends leaves in memory and persists them with a single
+// Flush, rather than writing the whole MMR state once per leaf as
+// AppendLeaf does. It returns the index of the first leaf and the new root.
+func (p *PersistentMMR) AppendLeaves(leaves []Leaf) (uint64, Hash, error) {
+	first := p.Size()
+	for _, leaf := range leaves {
+		if _, _, err := p.MMR.AppendLeaf(leaf); err != nil {
+			return 0, Hash{}, err
+		}
+	}
+	if err := p.Flush(); err != nil {
+		return 0, Hash{}, err
+	}
+	return first, p.Root(), nil
+}
+
+// Flush persists the leaves appended to the embedded MMR since it was last
+// saved, together with the MMR state, in one transaction. Importers append
+// to the embedded MMR directly and flush once at the end. Before anything
+// is written, the root is checked against one computed by appending every
+// leaf again, one at a time. An MMR without storage is left alone.
+func (p *PersistentMMR) Flush() error {
+	size := p.Size()
+	if p.db == nil || p.persisted == size {
+		return nil
+	}
+
+	check := NewMMR()
+	for _, leaf := range p.leaves {
+		if _, _, err := check.AppendLeaf(leaf); err != nil {
+			return err
+		}
+	}
+	if check.Root() != p.Root() {
+		return fmt.Errorf("MMR root after appending %d leaves does not match an incremental computation", size-p.persisted)
+	}
+
+	metaData, err := p.metadata()
+	if err != nil {
+		return err
+	}
+	err = p.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("mmr"))
+		if err != nil {
+			return err
+		}
+		for idx := p.persisted; idx < size; idx++ {
+			leafData, err := json.Marshal(p.leaves[idx])
+			if err != nil {
+				return fmt.Errorf("failed to marshal leaf %d: %w", idx, err)
+			}
+			if err := bucket.Put(p.leafKey(idx), leafData); err != nil {
+				return fmt.Errorf("failed to save leaf %d: %w", idx, err)
+			}
+		}
+		if err := bucket.Put([]byte("metadata"), metaData); err != nil {
+			return fmt.Errorf("failed to save metadata: %w", err)
+		}
+		for pos, hash := range p.nodes {
+			if err := bucket.Put(p.nodeKey(pos), hash[:]); err != nil {
+				return fmt.Errorf("failed to save node %d: %w", pos, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to persist MMR: %w", err)
+	}
+	p.persisted = size
+	return nil
+}
+
 // loadFromStorage loads the MMR state from persistent storage.
 func (p *PersistentMMR) loadFromStorage() error {
 	// Load MMR metadata (size, peaks, etc.)
 	var metaData []byte
 	err := p.db.View(func(tx *bbolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists([]byte("mmr"))
-		if err != nil {
-			return err
+		// A read-only transaction cannot create the bucket
+		bucket := tx.Bucket([]byte("mmr"))
+		if bucket == nil {
+			return nil
 		}
 		metaData = bucket.Get([]byte("metadata"))
 		return nil
@@ -126,6 +204,7 @@ func (p *PersistentMMR) loadFromStorage() error {
 	}
 
 	p.peaks = metadata.Peaks
+	p.persisted = metadata.Size
 
 	return nil
 }
@@ -192,9 +271,8 @@ func (p *PersistentMMR) persistLeaf(idx uint64, leaf Leaf) error {
 	})
 }
 
-// persistMMRState persists the current MMR state.
-func (p *PersistentMMR) persistMMRState() error {
-	// Save metadata
+// metadata encodes the MMR's size and peaks for storage.
+func (p *PersistentMMR) metadata() ([]byte, error) {
 	metadata := struct {
 		Size  uint64   `json:"size"`
 		Peaks []uint64 `json:"peaks"`
@@ -205,7 +283,17 @@ func (p *PersistentMMR) persistMMRState() error {
 
 	metaData, err := json.Marshal(metadata)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return metaData, nil
+}
+
+// persistMMRState persists the current MMR state.
+func (p *PersistentMMR) persistMMRState() error {
+	// Save metadata
+	metaData, err := p.metadata()
+	if err != nil {
+		return err
 	}
 
 	return p.db.Update(func(tx *bbolt.Tx) error {
@@ -302,6 +302,29 @@ func (s *SkipTable) AddLeaf(idx uint64, acc Accumulator) {
 	}
 }
 
+// AddChain adds a leaf together with those of its ancestors that are not
+// in the table yet, oldest first, so that every lifting entry is filled.
+func (s *SkipTable) AddChain(idx uint64, acc Accumulator) {
+	var chain []uint64
+	for current := idx; current != NoParent; {
+		if _, exists := s.up[current]; exists {
+			break
+		}
+		chain = append(chain, current)
+
+		leaf, err := acc.GetLeaf(current)
+		// Parents always precede their children; anything else is corrupt
+		if err != nil || !leaf.HasParent() || leaf.PrevIdx >= current {
+			break
+		}
+		current = leaf.PrevIdx
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		s.AddLeaf(chain[i], acc)
+	}
+}
+
 // LCA computes the LCA of two indices using binary lifting.
 func (s *SkipTable) LCA(aIdx, bIdx uint64, acc Accumulator) (uint64, error) {
 	if aIdx == bIdx {
@@ -0,0 +1,163 @@
+// Package httpclient builds the HTTP clients Ivaldi uses to talk to GitHub.
+//
+// Requests go through the proxy named by the http.proxy config key, or
+// else the one named by the standard HTTPS_PROXY and HTTP_PROXY environment
+// variables. Hosts listed in NO_PROXY are always reached directly. The
+// http.sslCAInfo key names a PEM bundle of extra certificate authorities to
+// trust, for proxies that intercept TLS.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+)
+
+// New returns a client with the given timeout that follows the proxy and
+// CA settings of the current repository and user
+func New(timeout time.Duration) (*http.Client, error) {
+	var settings config.HTTPConfig
+	if cfg, err := config.LoadConfig(); err == nil {
+		settings = cfg.HTTP
+	}
+
+	transport, err := NewTransport(settings)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// NewTransport returns a transport that applies settings on top of Go's
+// default transport
+func NewTransport(settings config.HTTPConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxy, err := proxyFunc(settings.Proxy, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	if settings.SSLCAInfo != "" {
+		pool, err := loadCAPool(settings.SSLCAInfo)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return transport, nil
+}
+
+// proxyFunc picks the proxy for each request. Without a configured proxy
+// the environment decides, as for any Go program.
+func proxyFunc(proxy string, getenv func(string) string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := ParseProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	noProxy := getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = getenv("no_proxy")
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// ParseProxy parses a proxy setting. A bare "host:port" means an HTTP proxy.
+func ParseProxy(proxy string) (*url.URL, error) {
+	proxy = strings.TrimSpace(proxy)
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http.proxy %q: %w", proxy, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid http.proxy %q: unsupported scheme %s (expected http, https or socks5)", proxy, proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid http.proxy %q: missing host", proxy)
+	}
+	return proxyURL, nil
+}
+
+// bypassProxy reports whether host matches a comma-separated NO_PROXY
+// list. Entries are "*", host names, which also match their subdomains,
+// IP addresses and CIDR ranges; a port on an entry is ignored.
+func bypassProxy(host, noProxy string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		entry = strings.TrimPrefix(entry, "*")
+		entry = strings.TrimPrefix(entry, ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCAPool returns the system certificate pool extended with the
+// certificates of a PEM bundle
+func loadCAPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read http.sslCAInfo: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("http.sslCAInfo %s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
+)
+
+func TestProxyFunc(t *testing.T) {
+	env := map[string]string{"NO_PROXY": "internal.example.com, 10.0.0.0/8,localhost:8080"}
+	proxy, err := proxyFunc("proxy.example.com:3128", func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("proxyFunc failed: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.github.com/user", "http://proxy.example.com:3128"},
+		{"https://git.internal.example.com/api/v3", ""},
+		{"https://internal.example.com/raw", ""},
+		{"http://10.1.2.3/api", ""},
+		{"http://localhost/api", ""},
+		{"https://example.com/", "http://proxy.example.com:3128"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		got, err := proxy(req)
+		if err != nil {
+			t.Fatalf("proxy(%s) failed: %v", tt.url, err)
+		}
+		gotStr := ""
+		if got != nil {
+			gotStr = got.String()
+		}
+		if gotStr != tt.want {
+			t.Errorf("proxy(%s) = %q, want %q", tt.url, gotStr, tt.want)
+		}
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	for _, bad := range []string{"ftp://proxy:21", "http://", "http://bad host:80"} {
+		if _, err := ParseProxy(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+	if u, err := ParseProxy("socks5://127.0.0.1:1080"); err != nil || u.Host != "127.0.0.1:1080" {
+		t.Errorf("Expected a socks5 proxy to be accepted, got %v, %v", u, err)
+	}
+}
+
+func TestCustomCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// Without the bundle the test server's certificate is not trusted
+	plain, err := NewTransport(config.HTTPConfig{})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	if resp, err := (&http.Client{Transport: plain}).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected an untrusted certificate to be rejected")
+	}
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := server.TLS.Certificates[0]
+	if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0644); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+
+	transport, err := NewTransport(config.HTTPConfig{SSLCAInfo: bundle})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the bundle to be trusted, got %v", err)
+	}
+	resp.Body.Close()
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, []byte("not a certificate"), 0644)
+	if _, err := NewTransport(config.HTTPConfig{SSLCAInfo: empty}); err == nil {
+		t.Error("Expected a bundle without certificates to be rejected")
+	}
+}
@@ -0,0 +1,156 @@
+// Package ignore decides which files of a working tree gather picks up.
+//
+// Two files at the root of the working tree take part. .ivaldiinclude, when
+// it exists, is an allowlist: gathering all files stages only paths that
+// match one of its patterns. .ivaldiignore then subtracts from that set, and
+// its lines starting with ! add paths back. The last matching line of
+// .ivaldiignore decides, so a negation only undoes ignore lines above it,
+// and it never brings back a path the allowlist leaves out.
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the files holding the patterns
+const (
+	IgnoreFile  = ".ivaldiignore"
+	IncludeFile = ".ivaldiinclude"
+)
+
+// Rules are the include and ignore patterns of a working tree
+type Rules struct {
+	Include []string // Patterns from .ivaldiinclude; nil when the file does not exist
+	Ignore  []string // Patterns from .ivaldiignore, negations included
+}
+
+// Load reads .ivaldiinclude and .ivaldiignore from the root of workDir.
+// Missing files are not an error.
+func Load(workDir string) (*Rules, error) {
+	include, err := LoadFile(filepath.Join(workDir, IncludeFile))
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := LoadFile(filepath.Join(workDir, IgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+	return &Rules{Include: include, Ignore: patterns}, nil
+}
+
+// LoadFile reads the patterns of a pattern file, skipping blank lines and
+// comments. A missing file yields nil; an existing one a non-nil slice.
+func LoadFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// Selects reports whether gathering all files stages path: it must be
+// included and not ignored
+func (r *Rules) Selects(path string) bool {
+	return r.Included(path) && !r.Ignored(path)
+}
+
+// Included reports whether path is on the allowlist. Without an
+// .ivaldiinclude every path is; the pattern files themselves always are.
+func (r *Rules) Included(path string) bool {
+	if r == nil || r.Include == nil || isPatternFile(path) {
+		return true
+	}
+	for _, pattern := range r.Include {
+		if Match(path, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignored reports whether the last .ivaldiignore line matching path ignores
+// it rather than negating an earlier one. The pattern files are never
+// ignored.
+func (r *Rules) Ignored(path string) bool {
+	if r == nil || isPatternFile(path) {
+		return false
+	}
+	_, ignored := r.Decide(path)
+	return ignored
+}
+
+// Decide returns the index in Ignore of the last line matching path, or -1
+// if none does, and whether that line ignores the path
+func (r *Rules) Decide(path string) (int, bool) {
+	for i := len(r.Ignore) - 1; i >= 0; i-- {
+		pattern, negated := strings.CutPrefix(r.Ignore[i], "!")
+		if Match(path, pattern) {
+			return i, !negated
+		}
+	}
+	return -1, false
+}
+
+func isPatternFile(path string) bool {
+	base := filepath.Base(path)
+	return base == IgnoreFile || base == IncludeFile
+}
+
+// Match checks if a file path matches a single pattern
+func Match(path, pattern string) bool {
+	// Handle directory patterns (patterns ending with /)
+	if strings.HasSuffix(pattern, "/") {
+		dirPattern := strings.TrimSuffix(pattern, "/")
+		// Check if the path is within this directory
+		if strings.HasPrefix(path, dirPattern+"/") || path == dirPattern {
+			return true
+		}
+	}
+
+	// Try matching the full path
+	if matched, _ := filepath.Match(pattern, path); matched {
+		return true
+	}
+
+	// Try matching just the basename
+	if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+		return true
+	}
+
+	// Handle wildcards in directory paths (e.g., **/*.log)
+	if strings.Contains(pattern, "**") {
+		// Convert ** pattern to a simpler check
+		parts := strings.Split(pattern, "**")
+		if len(parts) == 2 {
+			prefix := strings.TrimPrefix(parts[0], "/")
+			suffix := strings.TrimPrefix(parts[1], "/")
+
+			if prefix != "" && !strings.HasPrefix(path, prefix) {
+				return false
+			}
+
+			if suffix != "" {
+				if matched, _ := filepath.Match(suffix, filepath.Base(path)); matched {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		path, pattern string
+		want          bool
+	}{
+		{"build/app.o", "build/", true},
+		{"build", "build/", true},
+		{"src/build.go", "build/", false},
+		{"logs/app.log", "*.log", true},
+		{"src/main.go", "src/*.go", true},
+		{"src/pkg/main.go", "src/*.go", false},
+		{"src/pkg/main.go", "src/**/*.go", true},
+		{"docs/main.go", "src/**/*.go", false},
+	}
+	for _, c := range cases {
+		if got := Match(c.path, c.pattern); got != c.want {
+			t.Errorf("Match(%q, %q): expected %v, got %v", c.path, c.pattern, c.want, got)
+		}
+	}
+}
+
+func TestIgnoreNegation(t *testing.T) {
+	rules := &Rules{Ignore: []string{"*.log", "!keep.log", "logs/"}}
+
+	cases := map[string]bool{
+		"debug.log":      true,
+		"keep.log":       false,
+		"src/keep.log":   false,
+		"logs/keep.log":  true, // A later line ignores it again
+		"main.go":        false,
+		".ivaldiignore":  false,
+		".ivaldiinclude": false,
+	}
+	for path, want := range cases {
+		if got := rules.Ignored(path); got != want {
+			t.Errorf("Ignored(%s): expected %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestIncludeIgnoreNegation(t *testing.T) {
+	workDir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(workDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(IncludeFile, "# Only sources and docs\nsrc/\n*.md\n")
+	write(IgnoreFile, "*.gen.go\n!keep.gen.go\n*.log\n!notes.log\n")
+
+	rules, err := Load(workDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	cases := map[string]bool{
+		"src/main.go":      true,
+		"src/api.gen.go":   false, // Included, then ignored
+		"src/keep.gen.go":  true,  // Included, ignored, then negated
+		"README.md":        true,
+		"bin/tool":         false, // Not on the allowlist
+		"notes.log":        false, // Negation does not reach past the allowlist
+		"src/notes.log":    true,
+		".ivaldiinclude":   true,
+		".ivaldiignore":    true,
+		"vendor/lib/x.go":  false,
+		"docs/guide.md":    true,
+		"docs/diagram.png": false,
+	}
+	for path, want := range cases {
+		if got := rules.Selects(path); got != want {
+			t.Errorf("Selects(%s): expected %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestLoadWithoutFiles(t *testing.T) {
+	rules, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if rules.Include != nil {
+		t.Errorf("Expected no allowlist, got %v", rules.Include)
+	}
+	if !rules.Selects("anything/at/all.bin") {
+		t.Error("Expected every path to be selected without pattern files")
+	}
+
+	// An empty allowlist still exists and includes nothing
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, IncludeFile), []byte("# nothing yet\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = Load(workDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if rules.Selects("main.go") {
+		t.Error("Expected an empty .ivaldiinclude to include nothing")
+	}
+}
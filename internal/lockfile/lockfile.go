@@ -0,0 +1,61 @@
+// Package lockfile provides advisory locks backed by lock files.
+//
+// A lock is held by creating <name>.lock exclusively and released by
+// removing it, which works the same on every platform and across processes.
+// Cooperating Ivaldi processes take the lock before mutating shared
+// repository state such as refs or the staging area.
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultTimeout is how long Acquire waits for a lock held by someone else.
+const DefaultTimeout = 5 * time.Second
+
+// retryInterval is the delay between attempts to create the lock file.
+const retryInterval = 10 * time.Millisecond
+
+// ErrLocked is returned when a lock could not be acquired before the timeout.
+var ErrLocked = errors.New("repository is locked")
+
+// Lock is a held lock file.
+type Lock struct {
+	path string
+}
+
+// Acquire takes the lock at path, retrying until timeout elapses.
+func Acquire(path string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			// Record the owner to help when diagnosing a stale lock
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return &Lock{path: path}, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("create lock file: %w", err)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s is held by another process (if no other ivaldi process is running, remove it)", ErrLocked, path)
+		}
+
+		time.Sleep(retryInterval)
+	}
+}
+
+// Release removes the lock file.
+func (l *Lock) Release() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove lock file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	lock, err := Acquire(path, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Lock file should exist while held: %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Lock file should be removed after release")
+	}
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	held, err := Acquire(path, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer held.Release()
+
+	start := time.Now()
+	_, err = Acquire(path, 50*time.Millisecond)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Acquire gave up after %v, before the timeout", elapsed)
+	}
+}
+
+func TestAcquireMutualExclusion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	holders, maxHolders := 0, 0
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				lock, err := Acquire(path, 10*time.Second)
+				if err != nil {
+					t.Errorf("Acquire failed: %v", err)
+					return
+				}
+
+				mu.Lock()
+				holders++
+				if holders > maxHolders {
+					maxHolders = holders
+				}
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				holders--
+				mu.Unlock()
+
+				lock.Release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Errorf("Expected at most one holder at a time, saw %d", maxHolders)
+	}
+}
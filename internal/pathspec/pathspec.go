@@ -0,0 +1,172 @@
+// Package pathspec selects working tree files by the paths given on the
+// command line, so that patterns behave the same whatever the shell.
+//
+// A plain path selects itself and everything below it. A path with *, ? or
+// [ is a glob: * and ? stay within one path segment, and a ** segment spans
+// any number of directories. A glob without a slash matches at any depth,
+// so *.go selects every Go file. A glob that matches a directory selects
+// everything below it. Arguments starting with :! or :^ exclude what they
+// select; with exclusions alone, everything else is selected.
+package pathspec
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Spec is a parsed set of path arguments
+type Spec struct {
+	include []string
+	exclude []string
+	matched []bool // Whether each include has selected a path
+}
+
+// IsPattern reports whether an argument is a glob or an exclusion rather
+// than a plain path
+func IsPattern(arg string) bool {
+	_, excluded := cutExclusion(arg)
+	return excluded || strings.ContainsAny(arg, "*?[")
+}
+
+// HasPatterns reports whether any argument is a glob or an exclusion
+func HasPatterns(args []string) bool {
+	for _, arg := range args {
+		if IsPattern(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse builds a Spec from arguments relative to the root of the working
+// tree
+func Parse(args []string) *Spec {
+	s := &Spec{}
+	for _, arg := range args {
+		if pattern, excluded := cutExclusion(arg); excluded {
+			s.exclude = append(s.exclude, clean(pattern))
+		} else {
+			s.include = append(s.include, clean(arg))
+		}
+	}
+	s.matched = make([]bool, len(s.include))
+	return s
+}
+
+// Within makes arguments given from dir, a subdirectory of the working
+// tree, relative to its root, keeping their meaning: a plain path names
+// the same file, and a glob without a slash still matches at any depth,
+// but only below dir. Absolute paths are left alone.
+func Within(dir string, args []string) []string {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	if dir == "." || dir == "" {
+		return args
+	}
+	out := make([]string, len(args))
+	for i, arg := range args {
+		pattern, excluded := cutExclusion(arg)
+		if filepath.IsAbs(pattern) {
+			out[i] = arg
+			continue
+		}
+		pattern = filepath.ToSlash(pattern)
+		if strings.ContainsAny(pattern, "*?[") && !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		out[i] = path.Join(dir, pattern)
+		if excluded {
+			out[i] = ":!" + out[i]
+		}
+	}
+	return out
+}
+
+func cutExclusion(arg string) (string, bool) {
+	if pattern, ok := strings.CutPrefix(arg, ":!"); ok {
+		return pattern, true
+	}
+	return strings.CutPrefix(arg, ":^")
+}
+
+func clean(arg string) string {
+	return filepath.ToSlash(filepath.Clean(arg))
+}
+
+// Matches reports whether the spec selects a slash-separated path: some
+// include selects it, or there are none, and no exclusion does
+func (s *Spec) Matches(path string) bool {
+	for _, pattern := range s.exclude {
+		if Match(path, pattern) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	selected := false
+	for i, pattern := range s.include {
+		if Match(path, pattern) {
+			s.matched[i] = true
+			selected = true
+		}
+	}
+	return selected
+}
+
+// Unmatched returns the includes that have not selected any path passed to
+// Matches so far
+func (s *Spec) Unmatched() []string {
+	var unmatched []string
+	for i, pattern := range s.include {
+		if !s.matched[i] {
+			unmatched = append(unmatched, pattern)
+		}
+	}
+	return unmatched
+}
+
+// Match reports whether a single path argument selects a slash-separated
+// path
+func Match(name, pattern string) bool {
+	if pattern == "." {
+		return true
+	}
+	segments := strings.Split(name, "/")
+	if !IsPattern(pattern) {
+		return name == pattern || strings.HasPrefix(name, pattern+"/")
+	}
+	if !strings.Contains(pattern, "/") {
+		for _, segment := range segments {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchSegments(strings.Split(pattern, "/"), segments)
+}
+
+// matchSegments matches path segments against pattern segments. A path
+// that continues past the end of the pattern lies below a matched
+// directory and matches too.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package pathspec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		name, pattern string
+		want          bool
+	}{
+		{"main.go", "*.go", true},
+		{"src/pkg/main.go", "*.go", true},
+		{"src/main.go.txt", "*.go", false},
+		{"src/main.go", "src/*.go", true},
+		{"src/pkg/main.go", "src/*.go", false},
+		{"src/app.js", "src/**/*.js", true},
+		{"src/ui/views/app.js", "src/**/*.js", true},
+		{"lib/ui/app.js", "src/**/*.js", false},
+		{"vendor/pkg/a.go", "vendor", true},
+		{"vendored.go", "vendor", false},
+		{"src/vendor/a.go", "vendor", false},
+		{"build/out/app.o", "b?ild", true},
+		{"docs/a.md", "docs/*", true},
+		{"docs/guide/a.md", "docs/*", true},
+		{"anything/at/all", ".", true},
+	}
+	for _, c := range cases {
+		if got := Match(c.name, c.pattern); got != c.want {
+			t.Errorf("Match(%q, %q): expected %v, got %v", c.name, c.pattern, c.want, got)
+		}
+	}
+}
+
+func TestSpec(t *testing.T) {
+	files := []string{
+		"main.go",
+		"README.md",
+		"src/app.js",
+		"src/util.go",
+		"src/ui/view.js",
+		"vendor/lib/lib.go",
+		"vendor/lib/lib.js",
+	}
+	tests := []struct {
+		name          string
+		args          []string
+		want          []string
+		wantUnmatched []string
+	}{
+		{
+			name: "glob matching many files",
+			args: []string{"*.go"},
+			want: []string{"main.go", "src/util.go", "vendor/lib/lib.go"},
+		},
+		{
+			name: "recursive glob with exclusion",
+			args: []string{"**/*.js", ":!vendor"},
+			want: []string{"src/app.js", "src/ui/view.js"},
+		},
+		{
+			name: "exclusions alone",
+			args: []string{":!src", ":^*.md"},
+			want: []string{"main.go", "vendor/lib/lib.go", "vendor/lib/lib.js"},
+		},
+		{
+			name:          "glob matching no files",
+			args:          []string{"*.rs"},
+			wantUnmatched: []string{"*.rs"},
+		},
+		{
+			name:          "some patterns matching no files",
+			args:          []string{"src/**/*.js", "docs/*.md", "./src/../README.md"},
+			want:          []string{"README.md", "src/app.js", "src/ui/view.js"},
+			wantUnmatched: []string{"docs/*.md"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := Parse(tt.args)
+			var got []string
+			for _, file := range files {
+				if spec.Matches(file) {
+					got = append(got, file)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Selected %v, want %v", got, tt.want)
+			}
+			if unmatched := spec.Unmatched(); !reflect.DeepEqual(unmatched, tt.wantUnmatched) {
+				t.Errorf("Unmatched %v, want %v", unmatched, tt.wantUnmatched)
+			}
+		})
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	for arg, want := range map[string]bool{
+		"src/main.go": false,
+		"src":         false,
+		"*.go":        true,
+		"file[12].go": true,
+		":!vendor":    true,
+		":^vendor":    true,
+	} {
+		if got := IsPattern(arg); got != want {
+			t.Errorf("IsPattern(%q): expected %v, got %v", arg, want, got)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	args := []string{"main.go", ".", "../README.md", "*.js", "views/*.js", ":!vendor", ":^*.min.js"}
+	want := []string{"src/main.go", "src", "README.md", "src/**/*.js", "src/views/*.js", ":!src/vendor", ":!src/**/*.min.js"}
+	got := Within("src", args)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Within: expected %v, got %v", want, got)
+	}
+	if got := Within(".", args); !reflect.DeepEqual(got, args) {
+		t.Errorf("Within the root: expected %v unchanged, got %v", args, got)
+	}
+
+	// A glob keeps matching at any depth, but only below the directory
+	spec := Parse(Within("src", []string{"*.js"}))
+	for name, want := range map[string]bool{
+		"src/app.js":          true,
+		"src/ui/views/app.js": true,
+		"lib/app.js":          false,
+		"app.js":              false,
+	} {
+		if got := spec.Matches(name); got != want {
+			t.Errorf("Matches(%q): expected %v, got %v", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package refs
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReflogEntry records one move of a timeline
+type ReflogEntry struct {
+	Old     [32]byte // Hash before the move, zero when the timeline was created
+	New     [32]byte // Hash after the move
+	Time    time.Time
+	Message string
+}
+
+// ReflogRef names a timeline that has a reflog
+type ReflogRef struct {
+	Name string
+	Type TimelineType
+}
+
+// ExpireOptions selects the reflog entries ExpireReflog removes
+type ExpireOptions struct {
+	// Expire removes entries older than this; zero keeps them
+	Expire time.Time
+	// ExpireUnreachable removes entries older than this whose seal is not
+	// reachable from the timeline's head; zero keeps them
+	ExpireUnreachable time.Time
+	// Reachable reports whether a hash is reachable from the timeline's
+	// head. It is only called for entries older than ExpireUnreachable;
+	// without it, no entry counts as unreachable.
+	Reachable func(hash [32]byte) bool
+	// DryRun counts the entries that would be removed without removing them
+	DryRun bool
+}
+
+// hasReflog reports whether moves of timelines of a type are logged.
+// Tags are not, as they are not expected to move.
+func hasReflog(timelineType TimelineType) bool {
+	return timelineType == LocalTimeline || timelineType == RemoteTimeline
+}
+
+// reflogPath returns the file holding the reflog of a timeline, mirroring
+// its ref under .ivaldi/logs
+func (rm *RefsManager) reflogPath(name string, timelineType TimelineType) string {
+	safeName := strings.ReplaceAll(name, "/", string(filepath.Separator))
+	return filepath.Join(rm.ivaldiDir, "logs", "refs", rm.getSubdir(timelineType), safeName)
+}
+
+// readRefHash returns the hash a ref file points at, or zero if it cannot
+// be read
+func (rm *RefsManager) readRefHash(refPath string) [32]byte {
+	var hash [32]byte
+	data, err := os.ReadFile(refPath)
+	if err != nil {
+		return hash
+	}
+	field, _, _ := strings.Cut(string(data), " ")
+	if raw, err := hex.DecodeString(field); err == nil && len(raw) == len(hash) {
+		copy(hash[:], raw)
+	}
+	return hash
+}
+
+// appendReflog records that a timeline moved from old to its current hash.
+// It must be called with the refs lock held. Writes that leave the hash
+// unchanged are not recorded.
+func (rm *RefsManager) appendReflog(timeline Timeline, old [32]byte, message string) error {
+	if !hasReflog(timeline.Type) || timeline.Blake3Hash == old || timeline.Blake3Hash == ([32]byte{}) {
+		return nil
+	}
+
+	path := rm.reflogPath(timeline.Name, timeline.Type)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create reflog dir: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open reflog: %w", err)
+	}
+	_, err = file.WriteString(formatReflogEntry(ReflogEntry{
+		Old:     old,
+		New:     timeline.Blake3Hash,
+		Time:    timeline.LastUpdated,
+		Message: message,
+	}))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write reflog: %w", err)
+	}
+	return nil
+}
+
+// Format: old_hex new_hex timestamp message
+func formatReflogEntry(entry ReflogEntry) string {
+	message := strings.ReplaceAll(entry.Message, "\n", " ")
+	return fmt.Sprintf("%s %s %d %s\n",
+		hex.EncodeToString(entry.Old[:]),
+		hex.EncodeToString(entry.New[:]),
+		entry.Time.Unix(),
+		message)
+}
+
+func parseReflogEntry(line string) (ReflogEntry, error) {
+	var entry ReflogEntry
+	parts := strings.SplitN(line, " ", 4)
+	if len(parts) < 3 {
+		return entry, fmt.Errorf("invalid reflog entry: %q", line)
+	}
+	for i, target := range []*[32]byte{&entry.Old, &entry.New} {
+		raw, err := hex.DecodeString(parts[i])
+		if err != nil || len(raw) != len(target) {
+			return entry, fmt.Errorf("invalid reflog entry: %q", line)
+		}
+		copy(target[:], raw)
+	}
+	timestamp, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return entry, fmt.Errorf("invalid reflog entry: %q", line)
+	}
+	entry.Time = time.Unix(timestamp, 0)
+	if len(parts) == 4 {
+		entry.Message = parts[3]
+	}
+	return entry, nil
+}
+
+// readReflogFile reads the entries of a reflog in the order they were
+// written
+func readReflogFile(path string) ([]ReflogEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open reflog: %w", err)
+	}
+	defer file.Close()
+
+	var entries []ReflogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		entry, err := parseReflogEntry(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read reflog: %w", err)
+	}
+	return entries, nil
+}
+
+// ReadReflog returns the reflog of a timeline, newest entry first, so that
+// entry n is <name>@{n}. A timeline without a reflog has no entries.
+func (rm *RefsManager) ReadReflog(name string, timelineType TimelineType) ([]ReflogEntry, error) {
+	entries, err := readReflogFile(rm.reflogPath(name, timelineType))
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// ListReflogs returns every timeline that has a reflog
+func (rm *RefsManager) ListReflogs() ([]ReflogRef, error) {
+	var reflogs []ReflogRef
+	for _, timelineType := range []TimelineType{LocalTimeline, RemoteTimeline} {
+		logDir := filepath.Join(rm.ivaldiDir, "logs", "refs", rm.getSubdir(timelineType))
+		err := filepath.Walk(logDir, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || strings.HasSuffix(path, tmpRefSuffix) {
+				return nil
+			}
+			relPath, err := filepath.Rel(logDir, path)
+			if err != nil {
+				return err
+			}
+			name := strings.ReplaceAll(relPath, string(filepath.Separator), "/")
+			reflogs = append(reflogs, ReflogRef{Name: name, Type: timelineType})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list reflogs: %w", err)
+		}
+	}
+	return reflogs, nil
+}
+
+// ExpireReflog removes the entries of a timeline's reflog selected by opts
+// and returns how many were removed and how many remain. The newest entry,
+// which records the timeline's current head, is always kept.
+func (rm *RefsManager) ExpireReflog(name string, timelineType TimelineType, opts ExpireOptions) (removed, kept int, err error) {
+	path := rm.reflogPath(name, timelineType)
+	err = rm.withLock(func() error {
+		entries, err := readReflogFile(path)
+		if err != nil {
+			return err
+		}
+
+		var keep []ReflogEntry
+		for i, entry := range entries {
+			newest := i == len(entries)-1
+			if newest || !expired(entry, opts) {
+				keep = append(keep, entry)
+			}
+		}
+		removed, kept = len(entries)-len(keep), len(keep)
+		if removed == 0 || opts.DryRun {
+			return nil
+		}
+
+		var content strings.Builder
+		for _, entry := range keep {
+			content.WriteString(formatReflogEntry(entry))
+		}
+		return writeFileAtomic(path, []byte(content.String()), 0644)
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("expire reflog of %s: %w", name, err)
+	}
+	return removed, kept, nil
+}
+
+// expired reports whether opts select an entry for removal
+func expired(entry ReflogEntry, opts ExpireOptions) bool {
+	if !opts.Expire.IsZero() && entry.Time.Before(opts.Expire) {
+		return true
+	}
+	if !opts.ExpireUnreachable.IsZero() && entry.Time.Before(opts.ExpireUnreachable) {
+		return opts.Reachable != nil && !opts.Reachable(entry.New)
+	}
+	return false
+}
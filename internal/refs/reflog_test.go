@@ -0,0 +1,80 @@
+package refs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReflog(t *testing.T) {
+	rm, err := NewRefsManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer rm.Close()
+
+	if err := rm.CreateTimeline("main", LocalTimeline, hashFor(0, 1), [32]byte{}, "", "initial"); err != nil {
+		t.Fatalf("CreateTimeline failed: %v", err)
+	}
+	for step := 2; step <= 4; step++ {
+		if err := rm.UpdateTimeline("main", LocalTimeline, hashFor(0, step), [32]byte{}, ""); err != nil {
+			t.Fatalf("UpdateTimeline failed: %v", err)
+		}
+	}
+	// Rewriting the same hash is not a move
+	if err := rm.UpdateTimeline("main", LocalTimeline, hashFor(0, 4), [32]byte{}, ""); err != nil {
+		t.Fatalf("UpdateTimeline failed: %v", err)
+	}
+	// Tags have no reflog
+	if err := rm.CreateTimeline("v1", TagTimeline, hashFor(0, 1), [32]byte{}, "", ""); err != nil {
+		t.Fatalf("CreateTimeline failed: %v", err)
+	}
+
+	entries, err := rm.ReadReflog("main", LocalTimeline)
+	if err != nil {
+		t.Fatalf("ReadReflog failed: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 reflog entries, got %d", len(entries))
+	}
+	if entries[0].New != hashFor(0, 4) || entries[0].Old != hashFor(0, 3) || entries[0].Message != "updated" {
+		t.Errorf("Unexpected newest entry: %+v", entries[0])
+	}
+	if entries[3].Old != ([32]byte{}) || entries[3].Message != "created: initial" {
+		t.Errorf("Unexpected oldest entry: %+v", entries[3])
+	}
+
+	reflogs, err := rm.ListReflogs()
+	if err != nil || len(reflogs) != 1 || reflogs[0] != (ReflogRef{Name: "main", Type: LocalTimeline}) {
+		t.Errorf("ListReflogs = %v, %v", reflogs, err)
+	}
+
+	// Unreachable entries go first; the newest entry always stays
+	future := time.Now().Add(time.Hour)
+	reachable := func(hash [32]byte) bool { return hash == hashFor(0, 2) }
+	removed, kept, err := rm.ExpireReflog("main", LocalTimeline, ExpireOptions{ExpireUnreachable: future, Reachable: reachable, DryRun: true})
+	if err != nil || removed != 2 || kept != 2 {
+		t.Fatalf("Dry run ExpireReflog = %d, %d, %v", removed, kept, err)
+	}
+	if entries, _ := rm.ReadReflog("main", LocalTimeline); len(entries) != 4 {
+		t.Fatalf("Dry run removed entries: %d left", len(entries))
+	}
+	if removed, _, err := rm.ExpireReflog("main", LocalTimeline, ExpireOptions{ExpireUnreachable: future, Reachable: reachable}); err != nil || removed != 2 {
+		t.Fatalf("ExpireReflog = %d, %v", removed, err)
+	}
+	entries, _ = rm.ReadReflog("main", LocalTimeline)
+	if len(entries) != 2 || entries[0].New != hashFor(0, 4) || entries[1].New != hashFor(0, 2) {
+		t.Fatalf("Unexpected entries after expiry: %+v", entries)
+	}
+
+	if removed, kept, err := rm.ExpireReflog("main", LocalTimeline, ExpireOptions{Expire: future}); err != nil || removed != 1 || kept != 1 {
+		t.Fatalf("ExpireReflog = %d, %d, %v", removed, kept, err)
+	}
+
+	// Removing a timeline removes its reflog
+	if err := rm.RemoveTimeline("main", LocalTimeline); err != nil {
+		t.Fatalf("RemoveTimeline failed: %v", err)
+	}
+	if entries, err := rm.ReadReflog("main", LocalTimeline); err != nil || len(entries) != 0 {
+		t.Errorf("Expected no reflog after removal, got %d entries, %v", len(entries), err)
+	}
+}
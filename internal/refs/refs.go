@@ -2,6 +2,7 @@ package refs
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/javanhut/Ivaldi-vcs/internal/lockfile"
 	"github.com/javanhut/Ivaldi-vcs/internal/store"
 )
 
@@ -32,11 +34,16 @@ type Timeline struct {
 	Description string       `json:"description,omitempty"`
 }
 
+// ErrTimelineMoved is returned by UpdateTimelineIf when the timeline no
+// longer points where the caller expected.
+var ErrTimelineMoved = errors.New("timeline was moved by another command")
+
 // RefsManager handles timeline and reference management
 type RefsManager struct {
-	ivaldiDir string
-	refsDir   string
-	db        *store.SharedDB
+	ivaldiDir   string
+	refsDir     string
+	db          *store.SharedDB
+	lockTimeout time.Duration // How long mutations wait for the refs lock
 }
 
 // NewRefsManager creates a new refs manager
@@ -59,12 +66,42 @@ func NewRefsManager(ivaldiDir string) (*RefsManager, error) {
 	}
 
 	return &RefsManager{
-		ivaldiDir: ivaldiDir,
-		refsDir:   refsDir,
-		db:        db,
+		ivaldiDir:   ivaldiDir,
+		refsDir:     refsDir,
+		db:          db,
+		lockTimeout: lockfile.DefaultTimeout,
 	}, nil
 }
 
+// withLock runs fn while holding the repository-wide refs lock, so that
+// concurrent Ivaldi processes never interleave ref updates.
+func (rm *RefsManager) withLock(fn func() error) error {
+	lock, err := lockfile.Acquire(filepath.Join(rm.ivaldiDir, "refs.lock"), rm.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	return fn()
+}
+
+// tmpRefSuffix marks a ref that is being written; listings skip such files
+const tmpRefSuffix = ".tmp"
+
+// writeFileAtomic writes data to a temporary file and renames it into place,
+// so readers never observe a partially written ref.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath := path + tmpRefSuffix
+	if err := os.WriteFile(tmpPath, data, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
 // Close closes the refs manager
 func (rm *RefsManager) Close() error {
 	return rm.db.Close()
@@ -82,7 +119,7 @@ func (rm *RefsManager) CreateTimeline(name string, timelineType TimelineType, bl
 		Description: description,
 	}
 
-	return rm.writeTimeline(timeline)
+	return rm.writeTimeline(timeline, description)
 }
 
 // UpdateTimeline updates an existing timeline
@@ -96,7 +133,26 @@ func (rm *RefsManager) UpdateTimeline(name string, timelineType TimelineType, bl
 		LastUpdated: time.Now(),
 	}
 
-	return rm.writeTimeline(timeline)
+	return rm.writeTimeline(timeline, "")
+}
+
+// UpdateTimelineIf updates a timeline like UpdateTimeline, but only if it
+// still points at expectedOld; a timeline without a seal, or missing, is
+// expected as the zero hash. The check is made while holding the refs lock,
+// so a command that read the head before building on it cannot overwrite a
+// seal made by another command in the meantime. The error then wraps
+// ErrTimelineMoved.
+func (rm *RefsManager) UpdateTimelineIf(name string, timelineType TimelineType, expectedOld, blake3Hash [32]byte, sha256Hash [32]byte, gitSHA1Hash string) error {
+	timeline := Timeline{
+		Name:        name,
+		Type:        timelineType,
+		Blake3Hash:  blake3Hash,
+		SHA256Hash:  sha256Hash,
+		GitSHA1Hash: gitSHA1Hash,
+		LastUpdated: time.Now(),
+	}
+
+	return rm.writeTimelineIf(timeline, "", &expectedOld)
 }
 
 // GetTimeline retrieves a timeline by name and type
@@ -107,8 +163,9 @@ func (rm *RefsManager) GetTimeline(name string, timelineType TimelineType) (*Tim
 		return nil, fmt.Errorf("read timeline %s: %w", name, err)
 	}
 
-	// Parse the ref file (format: blake3_hex sha256_hex git_sha1_hex timestamp description)
-	parts := strings.Fields(string(data))
+	// Parse the ref file (format: blake3_hex sha256_hex git_sha1_hex timestamp description).
+	// Fields are separated by single spaces because git_sha1_hex may be empty.
+	parts := strings.SplitN(strings.TrimRight(string(data), "\r\n"), " ", 5)
 	if len(parts) < 3 {
 		return nil, fmt.Errorf("invalid ref file format for %s", name)
 	}
@@ -140,7 +197,7 @@ func (rm *RefsManager) GetTimeline(name string, timelineType TimelineType) (*Tim
 		timeline.GitSHA1Hash = parts[2]
 	}
 	if len(parts) > 4 {
-		timeline.Description = strings.Join(parts[4:], " ")
+		timeline.Description = strings.TrimSpace(parts[4])
 	}
 
 	return timeline, nil
@@ -157,7 +214,7 @@ func (rm *RefsManager) ListTimelines(timelineType TimelineType) ([]Timeline, err
 			return err
 		}
 
-		if info.IsDir() {
+		if info.IsDir() || strings.HasSuffix(path, tmpRefSuffix) {
 			return nil
 		}
 
@@ -201,7 +258,9 @@ func (rm *RefsManager) GetCurrentTimeline() (string, error) {
 func (rm *RefsManager) SetCurrentTimeline(name string) error {
 	headPath := filepath.Join(rm.ivaldiDir, "HEAD")
 	content := fmt.Sprintf("ref: refs/heads/%s\n", name)
-	return os.WriteFile(headPath, []byte(content), 0644)
+	return rm.withLock(func() error {
+		return writeFileAtomic(headPath, []byte(content), 0644)
+	})
 }
 
 // MapGitHashToBlake3 creates a mapping from Git SHA1 hash to Blake3 hash
@@ -232,6 +291,12 @@ func (rm *RefsManager) LookupByGitHash(gitSHA1 string) (blake3Hash [32]byte, sha
 	return blake3Hash, sha256Hash, nil
 }
 
+// LookupGitHashByBlake3 finds the Git SHA1 hash an Ivaldi commit was
+// mapped to when it was uploaded or fetched
+func (rm *RefsManager) LookupGitHashByBlake3(blake3Hash [32]byte) (string, error) {
+	return rm.db.LookupGitHashByBlake3(blake3Hash)
+}
+
 // SetGitHubRepository stores the GitHub repository configuration
 func (rm *RefsManager) SetGitHubRepository(owner, repo string) error {
 	repoURL := fmt.Sprintf("%s/%s", owner, repo)
@@ -363,8 +428,15 @@ func (rm *RefsManager) GetTimelineSyncStatuses() ([]TimelineSyncStatus, error) {
 	return statuses, nil
 }
 
-// writeTimeline writes a timeline to disk
-func (rm *RefsManager) writeTimeline(timeline Timeline) error {
+// writeTimeline writes a timeline to disk, recording the move in its reflog
+// together with note, if any
+func (rm *RefsManager) writeTimeline(timeline Timeline, note string) error {
+	return rm.writeTimelineIf(timeline, note, nil)
+}
+
+// writeTimelineIf writes a timeline ref if expected is nil or the ref still
+// points at it
+func (rm *RefsManager) writeTimelineIf(timeline Timeline, note string, expected *[32]byte) error {
 	refPath := rm.getRefPath(timeline.Name, timeline.Type)
 
 	// Ensure parent directory exists
@@ -381,7 +453,39 @@ func (rm *RefsManager) writeTimeline(timeline Timeline) error {
 		timeline.Description,
 	)
 
-	return os.WriteFile(refPath, []byte(content), 0644)
+	return rm.withLock(func() error {
+		old := rm.readRefHash(refPath)
+		if expected != nil && old != *expected {
+			return fmt.Errorf("%w: %s is at %s, expected %s", ErrTimelineMoved, timeline.Name,
+				hex.EncodeToString(old[:4]), hex.EncodeToString(expected[:4]))
+		}
+		message := "created"
+		if _, err := os.Stat(refPath); err == nil {
+			message = "updated"
+		}
+		if note != "" {
+			message += ": " + note
+		}
+		if err := writeFileAtomic(refPath, []byte(content), 0644); err != nil {
+			return err
+		}
+		return rm.appendReflog(timeline, old, message)
+	})
+}
+
+// RemoveTimeline deletes a timeline reference
+func (rm *RefsManager) RemoveTimeline(name string, timelineType TimelineType) error {
+	refPath := rm.getRefPath(name, timelineType)
+	return rm.withLock(func() error {
+		if err := os.Remove(refPath); err != nil {
+			return err
+		}
+		// The reflog goes with the timeline, as in Git
+		if err := os.Remove(rm.reflogPath(name, timelineType)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
 }
 
 // getRefPath returns the file path for a timeline reference
@@ -529,7 +633,9 @@ func (rm *RefsManager) StoreSealName(sealName string, hash [32]byte, message str
 	// Format: hash_hex timestamp message
 	content := fmt.Sprintf("%s %d %s\n", hashHex, timestamp, message)
 
-	return os.WriteFile(sealPath, []byte(content), 0644)
+	return rm.withLock(func() error {
+		return writeFileAtomic(sealPath, []byte(content), 0644)
+	})
 }
 
 // GetSealByName retrieves seal information by name
@@ -582,6 +688,9 @@ func (rm *RefsManager) GetSealNameByHash(hash [32]byte) (string, error) {
 		if err != nil || info.IsDir() {
 			return err
 		}
+		if strings.HasSuffix(path, tmpRefSuffix) {
+			return nil
+		}
 
 		data, err := os.ReadFile(path)
 		if err != nil {
@@ -627,7 +736,7 @@ func (rm *RefsManager) ListSealNames() ([]string, error) {
 
 	var sealNames []string
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() && !strings.HasSuffix(entry.Name(), tmpRefSuffix) {
 			sealNames = append(sealNames, entry.Name())
 		}
 	}
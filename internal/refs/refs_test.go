@@ -0,0 +1,279 @@
+package refs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/lockfile"
+)
+
+func hashFor(writer, step int) [32]byte {
+	var h [32]byte
+	h[0] = byte(writer)
+	h[1] = byte(step)
+	h[31] = 0xff
+	return h
+}
+
+func TestConcurrentTimelineUpdates(t *testing.T) {
+	ivaldiDir := t.TempDir()
+
+	setup, err := NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer setup.Close()
+	if err := setup.CreateTimeline("main", LocalTimeline, hashFor(0, 0), [32]byte{}, "", "initial"); err != nil {
+		t.Fatalf("CreateTimeline failed: %v", err)
+	}
+
+	const writers = 8
+	const steps = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*steps*3)
+	stop := make(chan struct{})
+
+	// A reader must never observe a partially written ref
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if _, err := setup.GetTimeline("main", LocalTimeline); err != nil {
+				errs <- fmt.Errorf("read during updates: %w", err)
+				return
+			}
+		}
+	}()
+
+	for w := 1; w <= writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			rm, err := NewRefsManager(ivaldiDir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer rm.Close()
+
+			own := fmt.Sprintf("writer-%d", w)
+			for i := 0; i < steps; i++ {
+				if err := rm.UpdateTimeline("main", LocalTimeline, hashFor(w, i), [32]byte{}, ""); err != nil {
+					errs <- err
+				}
+				if err := rm.CreateTimeline(own, LocalTimeline, hashFor(w, i), [32]byte{}, "", "writer"); err != nil {
+					errs <- err
+				}
+				if err := rm.StoreSealName(fmt.Sprintf("seal-%d-%d", w, i), hashFor(w, i), "concurrent"); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(stop)
+	<-readerDone
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	mainTimeline, err := setup.GetTimeline("main", LocalTimeline)
+	if err != nil {
+		t.Fatalf("GetTimeline(main) failed: %v", err)
+	}
+	if mainTimeline.Blake3Hash[31] != 0xff || mainTimeline.Blake3Hash[1] != steps-1 {
+		t.Errorf("main points at an unexpected hash %x", mainTimeline.Blake3Hash)
+	}
+
+	for w := 1; w <= writers; w++ {
+		timeline, err := setup.GetTimeline(fmt.Sprintf("writer-%d", w), LocalTimeline)
+		if err != nil {
+			t.Errorf("GetTimeline(writer-%d) failed: %v", w, err)
+			continue
+		}
+		if timeline.Blake3Hash != hashFor(w, steps-1) {
+			t.Errorf("writer-%d: expected last update, got %x", w, timeline.Blake3Hash)
+		}
+	}
+
+	names, err := setup.ListSealNames()
+	if err != nil {
+		t.Fatalf("ListSealNames failed: %v", err)
+	}
+	if len(names) != writers*steps {
+		t.Errorf("Expected %d seals, got %d", writers*steps, len(names))
+	}
+
+	timelines, err := setup.ListTimelines(LocalTimeline)
+	if err != nil {
+		t.Fatalf("ListTimelines failed: %v", err)
+	}
+	if len(timelines) != writers+1 {
+		t.Errorf("Expected %d timelines, got %d", writers+1, len(timelines))
+	}
+
+	if _, err := os.Stat(filepath.Join(ivaldiDir, "refs.lock")); !os.IsNotExist(err) {
+		t.Errorf("Expected refs lock to be released")
+	}
+}
+
+func TestUpdateTimelineIf(t *testing.T) {
+	ivaldiDir := t.TempDir()
+
+	rm, err := NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer rm.Close()
+
+	// A missing timeline is expected as the zero hash
+	if err := rm.UpdateTimelineIf("main", LocalTimeline, hashFor(9, 9), hashFor(1, 0), [32]byte{}, ""); !errors.Is(err, ErrTimelineMoved) {
+		t.Errorf("Expected ErrTimelineMoved for a missing timeline, got %v", err)
+	}
+	if err := rm.UpdateTimelineIf("main", LocalTimeline, [32]byte{}, hashFor(1, 0), [32]byte{}, ""); err != nil {
+		t.Fatalf("UpdateTimelineIf failed: %v", err)
+	}
+
+	// Two writers that read the same head race to build on it; only the
+	// first one moves the timeline
+	if err := rm.UpdateTimelineIf("main", LocalTimeline, hashFor(1, 0), hashFor(1, 1), [32]byte{}, ""); err != nil {
+		t.Fatalf("UpdateTimelineIf failed: %v", err)
+	}
+	if err := rm.UpdateTimelineIf("main", LocalTimeline, hashFor(1, 0), hashFor(2, 1), [32]byte{}, ""); !errors.Is(err, ErrTimelineMoved) {
+		t.Errorf("Expected ErrTimelineMoved, got %v", err)
+	}
+	if timeline, err := rm.GetTimeline("main", LocalTimeline); err != nil || timeline.Blake3Hash != hashFor(1, 1) {
+		t.Errorf("Expected main to keep the first update, got %+v, %v", timeline, err)
+	}
+
+	// Concurrent writers that retry on a moved timeline lose no update
+	const writers = 8
+	const steps = 10
+	counter := func(hash [32]byte) int { return int(hash[2])<<8 | int(hash[3]) }
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 1; w <= writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			rm, err := NewRefsManager(ivaldiDir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer rm.Close()
+
+			for i := 0; i < steps; {
+				head, err := rm.GetTimeline("main", LocalTimeline)
+				if err != nil {
+					errs <- err
+					return
+				}
+				next := hashFor(w, i)
+				n := counter(head.Blake3Hash) + 1
+				next[2], next[3] = byte(n>>8), byte(n)
+				err = rm.UpdateTimelineIf("main", LocalTimeline, head.Blake3Hash, next, [32]byte{}, "")
+				if errors.Is(err, ErrTimelineMoved) {
+					continue
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				i++
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	timeline, err := rm.GetTimeline("main", LocalTimeline)
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	if got := counter(timeline.Blake3Hash); got != writers*steps {
+		t.Errorf("Expected %d updates, got %d", writers*steps, got)
+	}
+}
+
+func TestRefUpdateWhileLocked(t *testing.T) {
+	ivaldiDir := t.TempDir()
+
+	rm, err := NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer rm.Close()
+	rm.lockTimeout = 50 * time.Millisecond
+
+	lock, err := lockfile.Acquire(filepath.Join(ivaldiDir, "refs.lock"), time.Second)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	err = rm.UpdateTimeline("main", LocalTimeline, hashFor(1, 1), [32]byte{}, "")
+	if !errors.Is(err, lockfile.ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	if rm.TimelineExists("main", LocalTimeline) {
+		t.Error("Timeline should not be written while the lock is held")
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := rm.UpdateTimeline("main", LocalTimeline, hashFor(1, 1), [32]byte{}, ""); err != nil {
+		t.Errorf("UpdateTimeline after release failed: %v", err)
+	}
+}
+
+func TestTimelineWithoutGitSHA(t *testing.T) {
+	rm, err := NewRefsManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRefsManager failed: %v", err)
+	}
+	defer rm.Close()
+
+	if err := rm.CreateTimeline("main", LocalTimeline, hashFor(1, 1), [32]byte{}, "", "Commit: fix  spacing"); err != nil {
+		t.Fatalf("CreateTimeline failed: %v", err)
+	}
+	timeline, err := rm.GetTimeline("main", LocalTimeline)
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	if timeline.GitSHA1Hash != "" {
+		t.Errorf("Expected no Git SHA, got %q", timeline.GitSHA1Hash)
+	}
+	if timeline.Description != "Commit: fix  spacing" {
+		t.Errorf("Expected description to be kept, got %q", timeline.Description)
+	}
+
+	if err := rm.UpdateTimeline("main", LocalTimeline, hashFor(1, 2), [32]byte{}, "abc1234"); err != nil {
+		t.Fatalf("UpdateTimeline failed: %v", err)
+	}
+	timeline, err = rm.GetTimeline("main", LocalTimeline)
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	if timeline.GitSHA1Hash != "abc1234" || timeline.Blake3Hash != hashFor(1, 2) {
+		t.Errorf("Expected updated hash and Git SHA abc1234, got %x %q", timeline.Blake3Hash, timeline.GitSHA1Hash)
+	}
+}
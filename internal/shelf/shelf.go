@@ -92,7 +92,7 @@ func (sm *ShelfManager) CreateAutoShelf(timelineName string, currentIndex, baseI
 
 // GetAutoShelf retrieves the most recent auto-shelf for a timeline, if it exists.
 func (sm *ShelfManager) GetAutoShelf(timelineName string) (*Shelf, error) {
-	shelves, err := sm.listShelves()
+	shelves, err := sm.ListShelves()
 	if err != nil {
 		return nil, err
 	}
@@ -135,9 +135,8 @@ func (sm *ShelfManager) RestoreStagedFiles(shelf *Shelf) error {
 	return nil
 }
 
-// listShelves returns all shelves sorted by creation time (newest first).
-// This is used internally to find the most recent auto-shelf.
-func (sm *ShelfManager) listShelves() ([]Shelf, error) {
+// ListShelves returns all shelves sorted by creation time (newest first).
+func (sm *ShelfManager) ListShelves() ([]Shelf, error) {
 	files, err := os.ReadDir(sm.shelfDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read shelf directory: %w", err)
@@ -180,6 +179,11 @@ func (sm *ShelfManager) RemoveAutoShelf(timelineName string) error {
 	return sm.removeShelf(shelf.ID)
 }
 
+// RemoveShelf removes a shelf by ID.
+func (sm *ShelfManager) RemoveShelf(shelfID string) error {
+	return sm.removeShelf(shelfID)
+}
+
 // removeShelf removes a shelf by ID (internal method).
 func (sm *ShelfManager) removeShelf(shelfID string) error {
 	shelfPath := filepath.Join(sm.shelfDir, shelfID+".json")
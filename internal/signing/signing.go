@@ -0,0 +1,325 @@
+// Package signing signs seals and verifies their signatures with the same
+// external tools Git uses: gpg for OpenPGP keys and ssh-keygen for SSH keys.
+package signing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Signature formats
+const (
+	FormatOpenPGP = "openpgp"
+	FormatSSH     = "ssh"
+)
+
+// sshNamespace is the namespace of SSH signatures on seals. Git signs
+// commits in the same namespace, so keys set up for Git work unchanged.
+const sshNamespace = "git"
+
+// sshPrincipal names the configured key in the allowed signers file built
+// for verification
+const sshPrincipal = "ivaldi"
+
+// Status is the outcome of verifying a signature
+type Status string
+
+const (
+	// Good means the signature is valid and made by a known key
+	Good Status = "good"
+	// Bad means the signature does not match the seal
+	Bad Status = "bad"
+	// Unknown means the signature could not be checked against a known
+	// key, e.g. because the public key is missing
+	Unknown Status = "unknown"
+)
+
+// TrustConfiguredKey is the trust of SSH signatures made by the configured
+// signing key
+const TrustConfiguredKey = "configured key"
+
+// Signer signs payloads with a key
+type Signer struct {
+	// Format is FormatOpenPGP or FormatSSH
+	Format string
+	// Key is a gpg key ID or user ID, or for SSH the path of a key file or
+	// a literal public key whose private half is held by ssh-agent
+	Key string
+}
+
+// Sign returns the ASCII-armored detached signature of payload
+func (s Signer) Sign(payload []byte) (string, error) {
+	switch s.Format {
+	case FormatOpenPGP:
+		args := []string{"--status-fd=2", "-bsa"}
+		if s.Key != "" {
+			args = append(args, "-u", s.Key)
+		}
+		return run("gpg", payload, args...)
+	case FormatSSH:
+		if s.Key == "" {
+			return "", fmt.Errorf("SSH signing needs user.signingkey to name a key")
+		}
+		keyFile, cleanup, err := sshKeyFile(s.Key)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		return run("ssh-keygen", payload, "-Y", "sign", "-n", sshNamespace, "-f", keyFile)
+	default:
+		return "", fmt.Errorf("unknown signature format: %s", s.Format)
+	}
+}
+
+// FormatForKey guesses the format of a signing key: SSH for a literal
+// public key or an existing key file, OpenPGP for anything else, such as a
+// key ID or user ID
+func FormatForKey(key string) string {
+	if isLiteralSSHKey(key) {
+		return FormatSSH
+	}
+	if key != "" {
+		if info, err := os.Stat(expandHome(key)); err == nil && !info.IsDir() {
+			return FormatSSH
+		}
+	}
+	return FormatOpenPGP
+}
+
+// FormatOf returns the format of an armored signature, or "" if it is not
+// recognized
+func FormatOf(signature string) string {
+	switch {
+	case strings.HasPrefix(signature, "-----BEGIN PGP SIGNATURE-----"):
+		return FormatOpenPGP
+	case strings.HasPrefix(signature, "-----BEGIN SSH SIGNATURE-----"):
+		return FormatSSH
+	default:
+		return ""
+	}
+}
+
+// Verification is the result of verifying a signature
+type Verification struct {
+	Status Status
+	Format string
+	// Signer identifies the key or user that made the signature, when the
+	// verifier reports it
+	Signer string
+	// Trust is how far the signing key is trusted: the gpg trust level, or
+	// for SSH whether it is the configured key. It is empty when unknown.
+	Trust string
+	// Output is what the verifier printed
+	Output string
+}
+
+// Verify checks signature against payload. SSH signatures count as good
+// when they are made by sshKey, the configured signing key; a valid
+// signature by any other key is unknown.
+func Verify(payload []byte, signature, sshKey string) Verification {
+	format := FormatOf(signature)
+	result := Verification{Status: Unknown, Format: format}
+
+	sigFile, err := writeTemp("ivaldi-sig-*", signature+"\n")
+	if err != nil {
+		result.Output = err.Error()
+		return result
+	}
+	defer os.Remove(sigFile)
+
+	switch format {
+	case FormatOpenPGP:
+		output, _ := combinedOutput("gpg", payload, "--status-fd=1", "--verify", sigFile, "-")
+		result.Status, result.Signer = parseGPGStatus(output)
+		result.Trust = parseGPGTrust(output)
+		result.Output = withoutGPGStatus(output)
+	case FormatSSH:
+		if publicKey := sshPublicKey(sshKey); publicKey != "" {
+			allowed, err := writeTemp("ivaldi-allowed-signers-*",
+				fmt.Sprintf("%s namespaces=\"%s\" %s\n", sshPrincipal, sshNamespace, publicKey))
+			if err == nil {
+				defer os.Remove(allowed)
+				output, err := combinedOutput("ssh-keygen", payload, "-Y", "verify", "-f", allowed,
+					"-I", sshPrincipal, "-n", sshNamespace, "-s", sigFile)
+				if err == nil {
+					result.Status, result.Output = Good, output
+					result.Signer = sshSigner(output)
+					result.Trust = TrustConfiguredKey
+					return result
+				}
+			}
+		}
+		output, err := combinedOutput("ssh-keygen", payload, "-Y", "check-novalidate", "-n", sshNamespace, "-s", sigFile)
+		result.Output = output
+		result.Signer = sshSigner(output)
+		if err != nil {
+			result.Status = Bad
+		} else {
+			result.Output = "the signature is valid, but not made by the configured signing key"
+		}
+	default:
+		result.Output = "unrecognized signature format"
+	}
+	return result
+}
+
+// withoutGPGStatus drops the machine-readable status lines from gpg's
+// output, leaving its messages to the user
+func withoutGPGStatus(output string) string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "[GNUPG:] ") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseGPGTrust reads the trust level of the signing key from gpg's
+// --status-fd output, e.g. "full" for TRUST_FULLY
+func parseGPGTrust(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(strings.TrimPrefix(line, "[GNUPG:] "))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "TRUST_ULTIMATE":
+			return "ultimate"
+		case "TRUST_FULLY":
+			return "full"
+		case "TRUST_MARGINAL":
+			return "marginal"
+		case "TRUST_NEVER":
+			return "never"
+		case "TRUST_UNDEFINED":
+			return "undefined"
+		}
+	}
+	return ""
+}
+
+// parseGPGStatus reads the outcome from gpg's --status-fd output
+func parseGPGStatus(output string) (Status, string) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimPrefix(line, "[GNUPG:] "), " ", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		signer := fields[1]
+		if len(fields) == 3 {
+			signer = fields[2]
+		}
+		switch fields[0] {
+		case "GOODSIG":
+			return Good, signer
+		case "BADSIG":
+			return Bad, signer
+		case "EXPSIG", "EXPKEYSIG", "REVKEYSIG":
+			return Unknown, signer
+		case "ERRSIG", "NO_PUBKEY":
+			// Only the key ID is known
+			return Unknown, fields[1]
+		}
+	}
+	return Unknown, ""
+}
+
+// sshSigner extracts the key fingerprint from ssh-keygen's verify output,
+// e.g. `Good "git" signature with ED25519 key SHA256:...`
+func sshSigner(output string) string {
+	if idx := strings.Index(output, " key "); idx >= 0 {
+		return strings.TrimSpace(strings.SplitN(output[idx+len(" key "):], "\n", 2)[0])
+	}
+	return ""
+}
+
+// isLiteralSSHKey reports whether key is a public key rather than a path
+func isLiteralSSHKey(key string) bool {
+	return strings.HasPrefix(key, "ssh-") || strings.HasPrefix(key, "ecdsa-") ||
+		strings.HasPrefix(key, "sk-") || strings.HasPrefix(key, "key::")
+}
+
+// sshKeyFile returns a file holding key, writing a literal public key to a
+// temporary file as ssh-keygen only reads keys from files
+func sshKeyFile(key string) (string, func(), error) {
+	if !isLiteralSSHKey(key) {
+		return expandHome(key), func() {}, nil
+	}
+	path, err := writeTemp("ivaldi-signing-key-*", strings.TrimPrefix(key, "key::")+"\n")
+	if err != nil {
+		return "", nil, err
+	}
+	return path, func() { os.Remove(path) }, nil
+}
+
+// sshPublicKey returns the public key of the configured SSH signing key:
+// the key itself when it is literal, otherwise the content of the .pub
+// file. It returns "" when none can be found.
+func sshPublicKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if isLiteralSSHKey(key) {
+		return strings.TrimSpace(strings.TrimPrefix(key, "key::"))
+	}
+	path := expandHome(key)
+	if !strings.HasSuffix(path, ".pub") {
+		path += ".pub"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+func writeTemp(pattern, content string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	_, writeErr := f.WriteString(content)
+	if err := f.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", writeErr)
+	}
+	return f.Name(), nil
+}
+
+// run runs a signing tool with payload on stdin and returns its stdout
+func run(name string, payload []byte, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed to sign: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// combinedOutput runs a verifying tool with payload on stdin
+func combinedOutput(name string, payload []byte, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	output, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
@@ -0,0 +1,73 @@
+package signing
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGPGStatus(t *testing.T) {
+	tests := []struct {
+		output string
+		status Status
+		signer string
+	}{
+		{"[GNUPG:] NEWSIG\n[GNUPG:] GOODSIG 64C6FCB562C9EC42 A <a@b>\n[GNUPG:] VALIDSIG ...", Good, "A <a@b>"},
+		{"[GNUPG:] BADSIG 64C6FCB562C9EC42 A <a@b>", Bad, "A <a@b>"},
+		{"[GNUPG:] ERRSIG 64C6FCB562C9EC42 22 10 00 1700000000 9 -\n[GNUPG:] NO_PUBKEY 64C6FCB562C9EC42", Unknown, "64C6FCB562C9EC42"},
+		{"[GNUPG:] EXPKEYSIG 64C6FCB562C9EC42 A <a@b>", Unknown, "A <a@b>"},
+		{"gpg: no valid OpenPGP data found.", Unknown, ""},
+	}
+	for _, tt := range tests {
+		status, signer := parseGPGStatus(tt.output)
+		if status != tt.status || signer != tt.signer {
+			t.Errorf("parseGPGStatus(%q) = %s, %q; want %s, %q", tt.output, status, signer, tt.status, tt.signer)
+		}
+	}
+}
+
+func TestParseGPGTrust(t *testing.T) {
+	output := "[GNUPG:] GOODSIG 64C6FCB562C9EC42 A <a@b>\n[GNUPG:] TRUST_ULTIMATE 0 pgp"
+	if trust := parseGPGTrust(output); trust != "ultimate" {
+		t.Errorf("Expected ultimate trust, got %q", trust)
+	}
+	if trust := parseGPGTrust("[GNUPG:] GOODSIG 64C6FCB562C9EC42 A <a@b>"); trust != "" {
+		t.Errorf("Expected no trust level, got %q", trust)
+	}
+}
+
+func TestSSHSignAndVerify(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	dir := t.TempDir()
+	key := filepath.Join(dir, "id_ed25519")
+	other := filepath.Join(dir, "other")
+	for _, path := range []string{key, other} {
+		if output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", path).CombinedOutput(); err != nil {
+			t.Fatalf("Failed to generate key: %v: %s", err, output)
+		}
+	}
+
+	payload := []byte("tree abc\nauthor A <a@b>\n\nmessage\n")
+	signature, err := Signer{Format: FormatSSH, Key: key + ".pub"}.Sign(payload)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if format := FormatOf(signature); format != FormatSSH {
+		t.Fatalf("Expected an SSH signature, got format %q", format)
+	}
+	if format := FormatForKey(key); format != FormatSSH {
+		t.Errorf("Expected a key file to be an SSH key, got %q", format)
+	}
+
+	if result := Verify(payload, signature, key); result.Status != Good || result.Trust != TrustConfiguredKey {
+		t.Errorf("Expected a good signature by the configured key, got %s (%q): %s", result.Status, result.Trust, result.Output)
+	}
+	if result := Verify(payload, signature, other); result.Status != Unknown {
+		t.Errorf("Expected a signature by another key to be unknown, got %s", result.Status)
+	}
+	if result := Verify(append(payload, 'x'), signature, key); result.Status != Bad {
+		t.Errorf("Expected a bad signature for changed content, got %s", result.Status)
+	}
+}
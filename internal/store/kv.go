@@ -1,6 +1,7 @@
 package store
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
 
@@ -117,6 +118,24 @@ func (db *DB) LookupByGitHash(gitSHA1 string) (blake3Hex, sha256Hex string, err
 	return
 }
 
+// LookupGitHashByBlake3 finds the git sha1 hash mapped to a blake3 hash.
+// The git->b3 bucket is scanned, as no reverse index is kept.
+func (db *DB) LookupGitHashByBlake3(blake3_32 [32]byte) (string, error) {
+	b3hex := []byte(hex.EncodeToString(blake3_32[:]))
+	var gitSHA1 string
+	err := db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(BucketGitToB3).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if bytes.Equal(v, b3hex) {
+				gitSHA1 = string(k)
+				return nil
+			}
+		}
+		return errors.New("blake3 hash not found")
+	})
+	return gitSHA1, err
+}
+
 // GetAllGitHashes returns all stored git sha1 hashes.
 func (db *DB) GetAllGitHashes() ([]string, error) {
 	var hashes []string
@@ -0,0 +1,120 @@
+package submodule
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ModulesFile is the name of the file in the working directory that
+// declares the submodules of a repository
+const ModulesFile = ".ivaldimodules"
+
+// LocalConfigPath returns the file in the .ivaldi directory that holds the
+// submodule settings of this clone. Like the submodule entries of
+// .git/config, it starts as a copy of .ivaldimodules, may override URLs
+// locally, and is refreshed by 'submodule sync'.
+func LocalConfigPath(ivaldiDir string) string {
+	return filepath.Join(ivaldiDir, "submodules")
+}
+
+// ModuleDir returns the directory in .ivaldi that holds the converted
+// history of the submodule at path
+func ModuleDir(ivaldiDir, path string) string {
+	return filepath.Join(ivaldiDir, "modules", filepath.FromSlash(path))
+}
+
+// Load returns the submodules of a clone, sorted by path. They are declared,
+// and their commits pinned, by .ivaldimodules; the URL recorded in .ivaldi
+// for a submodule takes precedence over the declared one.
+func Load(ivaldiDir, workDir string) ([]Config, error) {
+	configs, err := ParseIvaldimodules(filepath.Join(workDir, ModulesFile))
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := ParseIvaldimodules(LocalConfigPath(ivaldiDir))
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make(map[string]string, len(recorded))
+	for _, cfg := range recorded {
+		urls[cfg.Name] = cfg.URL
+	}
+	for i, cfg := range configs {
+		if url := urls[cfg.Name]; url != "" {
+			configs[i].URL = url
+		}
+	}
+	sortConfigs(configs)
+	return configs, nil
+}
+
+// SaveLocal records the submodule settings of a clone in .ivaldi
+func SaveLocal(ivaldiDir string, configs []Config) error {
+	sortConfigs(configs)
+	if err := WriteIvaldimodules(LocalConfigPath(ivaldiDir), configs); err != nil {
+		return fmt.Errorf("write submodule config: %w", err)
+	}
+	return nil
+}
+
+// SyncChange describes how 'submodule sync' changed the settings of one
+// submodule
+type SyncChange struct {
+	Name   string
+	Path   string
+	OldURL string // Empty for a newly declared submodule
+	NewURL string // Empty for a submodule no longer declared
+}
+
+// Sync replaces the settings recorded in .ivaldi with the declarations of
+// .ivaldimodules and returns what changed, sorted by path
+func Sync(ivaldiDir, workDir string) ([]SyncChange, []Config, error) {
+	declared, err := ParseIvaldimodules(filepath.Join(workDir, ModulesFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	recorded, err := ParseIvaldimodules(LocalConfigPath(ivaldiDir))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	old := make(map[string]Config, len(recorded))
+	for _, cfg := range recorded {
+		old[cfg.Name] = cfg
+	}
+
+	var changes []SyncChange
+	for _, cfg := range declared {
+		prev, ok := old[cfg.Name]
+		delete(old, cfg.Name)
+		if !ok || prev.URL != cfg.URL || prev.Path != cfg.Path {
+			changes = append(changes, SyncChange{Name: cfg.Name, Path: cfg.Path, OldURL: prev.URL, NewURL: cfg.URL})
+		}
+	}
+	for _, prev := range old {
+		changes = append(changes, SyncChange{Name: prev.Name, Path: prev.Path, OldURL: prev.URL})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	if len(declared) == 0 {
+		if err := os.Remove(LocalConfigPath(ivaldiDir)); err != nil && !os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("remove submodule config: %w", err)
+		}
+		return changes, nil, nil
+	}
+	if err := SaveLocal(ivaldiDir, declared); err != nil {
+		return nil, nil, err
+	}
+	return changes, declared, nil
+}
+
+func sortConfigs(configs []Config) {
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Path < configs[j].Path
+	})
+}
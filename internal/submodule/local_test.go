@@ -0,0 +1,78 @@
+package submodule
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadAndSync(t *testing.T) {
+	workDir := t.TempDir()
+	ivaldiDir := filepath.Join(workDir, ".ivaldi")
+	if err := os.MkdirAll(ivaldiDir, 0755); err != nil {
+		t.Fatalf("Failed to create .ivaldi: %v", err)
+	}
+
+	declared := []Config{
+		{Name: "tools", Path: "vendor/tools", URL: "https://example.com/tools.git"},
+		{Name: "lib", Path: "vendor/lib", URL: "https://example.com/lib.git", GitCommit: "0123456789abcdef0123456789abcdef01234567"},
+	}
+	if err := WriteIvaldimodules(filepath.Join(workDir, ModulesFile), declared); err != nil {
+		t.Fatalf("Failed to write %s: %v", ModulesFile, err)
+	}
+
+	// A URL recorded for this clone overrides the declared one
+	if err := SaveLocal(ivaldiDir, []Config{
+		{Name: "lib", Path: "vendor/lib", URL: "https://mirror.example.com/lib.git"},
+		{Name: "old", Path: "vendor/old", URL: "https://example.com/old.git"},
+	}); err != nil {
+		t.Fatalf("SaveLocal failed: %v", err)
+	}
+
+	configs, err := Load(ivaldiDir, workDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(configs) != 2 || configs[0].Path != "vendor/lib" || configs[1].Path != "vendor/tools" {
+		t.Fatalf("Expected the declared submodules sorted by path, got %+v", configs)
+	}
+	if configs[0].URL != "https://mirror.example.com/lib.git" {
+		t.Errorf("Expected the recorded URL, got %s", configs[0].URL)
+	}
+	if configs[0].GitCommit != declared[1].GitCommit {
+		t.Errorf("Expected the declared pin, got %q", configs[0].GitCommit)
+	}
+
+	changes, synced, err := Sync(ivaldiDir, workDir)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	wantChanges := []SyncChange{
+		{Name: "lib", Path: "vendor/lib", OldURL: "https://mirror.example.com/lib.git", NewURL: "https://example.com/lib.git"},
+		{Name: "old", Path: "vendor/old", OldURL: "https://example.com/old.git"},
+		{Name: "tools", Path: "vendor/tools", NewURL: "https://example.com/tools.git"},
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("Expected changes %+v, got %+v", wantChanges, changes)
+	}
+	if len(synced) != 2 {
+		t.Errorf("Expected 2 synced submodules, got %d", len(synced))
+	}
+
+	configs, err = Load(ivaldiDir, workDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if configs[0].URL != "https://example.com/lib.git" {
+		t.Errorf("Expected the declared URL after sync, got %s", configs[0].URL)
+	}
+
+	changes, _, err = Sync(ivaldiDir, workDir)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected a second sync to change nothing, got %+v", changes)
+	}
+}
@@ -0,0 +1,115 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/submodule"
+)
+
+// Kinds of nested repositories
+const (
+	NestedIvaldi = "ivaldi" // Holds a .ivaldi directory
+	NestedGit    = "git"    // Holds a .git directory or file
+)
+
+// NestedRepo is a directory below the working directory that is the root of
+// a repository of its own. Its files belong to that repository, so scans
+// leave them out and record the directory like a submodule instead.
+type NestedRepo struct {
+	Path string // Relative to the working directory, with forward slashes
+	Kind string // NestedIvaldi or NestedGit
+	// Submodule reports whether .ivaldimodules declares the path
+	Submodule bool
+}
+
+// NestedRepos detects nested repositories during a walk of the working
+// directory and collects the ones it found
+type NestedRepos struct {
+	workDir  string
+	skip     bool
+	declared map[string]bool
+	found    []NestedRepo
+}
+
+// NewNestedRepos returns a detector for the working directory. With skip
+// false nothing counts as nested, and the files of nested repositories are
+// walked like any others.
+func NewNestedRepos(workDir string, skip bool) *NestedRepos {
+	n := &NestedRepos{workDir: workDir, skip: skip, declared: make(map[string]bool)}
+	if skip {
+		// A broken .ivaldimodules only loses the submodule labels
+		configs, _ := submodule.ParseIvaldimodules(filepath.Join(workDir, ".ivaldimodules"))
+		for _, cfg := range configs {
+			n.declared[filepath.ToSlash(filepath.Clean(cfg.Path))] = true
+		}
+	}
+	return n
+}
+
+// Skip reports whether dir, a directory found by the walk, is the root of a
+// nested repository that the walk should not descend into, and records it.
+// The working directory itself is never nested.
+func (n *NestedRepos) Skip(dir string) bool {
+	if !n.skip {
+		return false
+	}
+	relPath, err := filepath.Rel(n.workDir, dir)
+	if err != nil || relPath == "." {
+		return false
+	}
+	kind, ok := NestedRepoKind(dir)
+	if !ok {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+	n.found = append(n.found, NestedRepo{Path: relPath, Kind: kind, Submodule: n.declared[relPath]})
+	return true
+}
+
+// Within reports whether path, a file or directory below the working
+// directory, lies inside a nested repository, and returns that repository.
+// It checks the directories above path, and path itself, so it also serves
+// paths given on the command line, which no walk has visited.
+func (n *NestedRepos) Within(path string) (NestedRepo, bool) {
+	if !n.skip {
+		return NestedRepo{}, false
+	}
+	relPath, err := filepath.Rel(n.workDir, path)
+	if err != nil || relPath == "." || strings.HasPrefix(relPath, "..") {
+		return NestedRepo{}, false
+	}
+
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	for i := range parts {
+		rel := strings.Join(parts[:i+1], "/")
+		if kind, ok := NestedRepoKind(filepath.Join(n.workDir, filepath.FromSlash(rel))); ok {
+			return NestedRepo{Path: rel, Kind: kind, Submodule: n.declared[rel]}, true
+		}
+	}
+	return NestedRepo{}, false
+}
+
+// Found returns the nested repositories seen so far, sorted by path
+func (n *NestedRepos) Found() []NestedRepo {
+	found := append([]NestedRepo(nil), n.found...)
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Path < found[j].Path
+	})
+	return found
+}
+
+// NestedRepoKind reports whether dir is the root of a repository, and of
+// which kind. A .git file counts as well, as Git leaves one in worktrees
+// and submodules.
+func NestedRepoKind(dir string) (string, bool) {
+	if info, err := os.Stat(filepath.Join(dir, ".ivaldi")); err == nil && info.IsDir() {
+		return NestedIvaldi, true
+	}
+	if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+		return NestedGit, true
+	}
+	return "", false
+}
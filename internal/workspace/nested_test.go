@@ -0,0 +1,115 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+func writeTestFiles(t *testing.T, workDir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(workDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+}
+
+func scannedPaths(t *testing.T, m *Materializer) []string {
+	t.Helper()
+	index, err := m.ScanWorkspace()
+	if err != nil {
+		t.Fatalf("ScanWorkspace failed: %v", err)
+	}
+	files, err := wsindex.NewLoader(m.CAS).ListAll(index)
+	if err != nil {
+		t.Fatalf("ListAll failed: %v", err)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestScanWorkspaceSkipsNestedRepos(t *testing.T) {
+	_, workDir, materializer, cleanup := setupTestWorkspace(t)
+	defer cleanup()
+
+	writeTestFiles(t, workDir, map[string]string{
+		"main.go":                   "package main",
+		".ivaldimodules":            "[submodule \"lib\"]\n\tpath = vendor/lib\n\turl = https://example.com/lib\n",
+		"vendor/lib/.git/HEAD":      "ref: refs/heads/main\n",
+		"vendor/lib/lib.go":         "package lib",
+		"tools/gen/.ivaldi/HEAD":    "main",
+		"tools/gen/gen.go":          "package gen",
+		"tools/worktree/.git":       "gitdir: ../../.git/worktrees/wt\n",
+		"tools/worktree/wt.go":      "package wt",
+		"tools/notes/.ivaldi-notes": "not a repository marker",
+	})
+
+	materializer.SkipNestedRepos = true
+	paths := scannedPaths(t, materializer)
+	want := []string{".ivaldimodules", "main.go", "tools/notes/.ivaldi-notes"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Expected scanned files %v, got %v", want, paths)
+	}
+
+	wantNested := []NestedRepo{
+		{Path: "tools/gen", Kind: NestedIvaldi},
+		{Path: "tools/worktree", Kind: NestedGit},
+		{Path: "vendor/lib", Kind: NestedGit, Submodule: true},
+	}
+	if !reflect.DeepEqual(materializer.NestedRepos, wantNested) {
+		t.Errorf("Expected nested repositories %+v, got %+v", wantNested, materializer.NestedRepos)
+	}
+
+	// Including nested repositories scans their files, but never the
+	// metadata of a nested .ivaldi directory
+	materializer.SkipNestedRepos = false
+	paths = scannedPaths(t, materializer)
+	want = []string{
+		".ivaldimodules", "main.go", "tools/gen/gen.go", "tools/notes/.ivaldi-notes",
+		"tools/worktree/.git", "tools/worktree/wt.go",
+		"vendor/lib/.git/HEAD", "vendor/lib/lib.go",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Expected scanned files %v, got %v", want, paths)
+	}
+	if len(materializer.NestedRepos) != 0 {
+		t.Errorf("Expected no nested repositories, got %+v", materializer.NestedRepos)
+	}
+}
+
+func TestNestedReposWithin(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFiles(t, workDir, map[string]string{
+		"vendor/lib/.git/HEAD": "ref: refs/heads/main\n",
+		"vendor/lib/src/a.go":  "package src",
+		"vendor/other.go":      "package vendor",
+	})
+
+	nested := NewNestedRepos(workDir, true)
+	repo, ok := nested.Within(filepath.Join(workDir, "vendor", "lib", "src", "a.go"))
+	if !ok || repo.Path != "vendor/lib" || repo.Kind != NestedGit {
+		t.Errorf("Expected a.go to be inside vendor/lib, got %+v, %v", repo, ok)
+	}
+	if _, ok := nested.Within(filepath.Join(workDir, "vendor", "lib")); !ok {
+		t.Error("Expected the nested repository root to be inside itself")
+	}
+	if repo, ok := nested.Within(filepath.Join(workDir, "vendor", "other.go")); ok {
+		t.Errorf("Expected other.go to be outside nested repositories, got %+v", repo)
+	}
+	if _, ok := NewNestedRepos(workDir, false).Within(filepath.Join(workDir, "vendor", "lib", "src", "a.go")); ok {
+		t.Error("Expected no nested repositories when they are included")
+	}
+}
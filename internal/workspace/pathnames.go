@@ -0,0 +1,73 @@
+package workspace
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizePath returns a path name in precomposed (NFC) Unicode form.
+// macOS file systems hand out decomposed (NFD) names, so the same file is
+// listed differently there than on Linux or Windows.
+func NormalizePath(path string) string {
+	return norm.NFC.String(path)
+}
+
+// Kinds of path name mismatch
+const (
+	MismatchNormalization = "normalization" // Same name in another Unicode form
+	MismatchCase          = "case"          // Same name in different letter case
+)
+
+// PathMismatch is a workspace path that names a committed file with a
+// different spelling, which shows up as a phantom add/remove pair
+type PathMismatch struct {
+	Path      string // Path in the workspace
+	Committed string // Path in the committed tree
+	Kind      string // MismatchNormalization or MismatchCase
+}
+
+// pathKey folds the differences a case-insensitive or normalization-
+// insensitive file system ignores
+func pathKey(path string) string {
+	return strings.ToLower(norm.NFC.String(path))
+}
+
+// FindPathMismatches pairs workspace paths missing from the committed tree
+// with committed paths missing from the workspace that differ only in Unicode
+// normalization or letter case. Results are sorted by workspace path.
+func FindPathMismatches(workspacePaths, committedPaths []string) []PathMismatch {
+	inWorkspace := make(map[string]bool, len(workspacePaths))
+	for _, path := range workspacePaths {
+		inWorkspace[path] = true
+	}
+
+	committedByKey := make(map[string]string)
+	inCommitted := make(map[string]bool, len(committedPaths))
+	for _, path := range committedPaths {
+		inCommitted[path] = true
+		if !inWorkspace[path] {
+			committedByKey[pathKey(path)] = path
+		}
+	}
+
+	var mismatches []PathMismatch
+	for _, path := range workspacePaths {
+		if inCommitted[path] {
+			continue
+		}
+		committed, ok := committedByKey[pathKey(path)]
+		if !ok {
+			continue
+		}
+		kind := MismatchCase
+		if norm.NFC.String(path) == norm.NFC.String(committed) {
+			kind = MismatchNormalization
+		}
+		mismatches = append(mismatches, PathMismatch{Path: path, Committed: committed, Kind: kind})
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Path < mismatches[j].Path })
+	return mismatches
+}
@@ -0,0 +1,82 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// "café.txt" with a precomposed é (NFC) and with e and a combining acute
+// accent (NFD)
+const (
+	cafeNFC = "caf\u00e9.txt"
+	cafeNFD = "cafe\u0301.txt"
+)
+
+func TestNormalizePath(t *testing.T) {
+	if NormalizePath(cafeNFD) != cafeNFC {
+		t.Errorf("Expected NFD name to be precomposed, got %q", NormalizePath(cafeNFD))
+	}
+	if NormalizePath(cafeNFC) != cafeNFC {
+		t.Errorf("Expected NFC name to be unchanged, got %q", NormalizePath(cafeNFC))
+	}
+}
+
+func TestScanWorkspacePrecomposeUnicode(t *testing.T) {
+	for _, precompose := range []bool{true, false} {
+		_, workDir, materializer, cleanup := setupTestWorkspace(t)
+		materializer.PrecomposeUnicode = precompose
+
+		if err := os.WriteFile(filepath.Join(workDir, cafeNFD), []byte("content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		index, err := materializer.ScanWorkspace()
+		if err != nil {
+			t.Fatalf("ScanWorkspace failed: %v", err)
+		}
+		files, err := wsindex.NewLoader(materializer.CAS).ListAll(index)
+		if err != nil {
+			t.Fatalf("Failed to list index: %v", err)
+		}
+		if len(files) != 1 {
+			t.Fatalf("Expected 1 file in index, got %d", len(files))
+		}
+
+		want := cafeNFD
+		if precompose {
+			want = cafeNFC
+		}
+		if files[0].Path != want {
+			t.Errorf("precompose=%t: expected path %q, got %q", precompose, want, files[0].Path)
+		}
+		cleanup()
+	}
+}
+
+func TestFindPathMismatches(t *testing.T) {
+	workspacePaths := []string{cafeNFD, "README.md", "new.txt"}
+	committedPaths := []string{cafeNFC, "readme.md", "other.txt"}
+
+	mismatches := FindPathMismatches(workspacePaths, committedPaths)
+	if len(mismatches) != 2 {
+		t.Fatalf("Expected 2 mismatches, got %d: %+v", len(mismatches), mismatches)
+	}
+
+	expected := []PathMismatch{
+		{Path: "README.md", Committed: "readme.md", Kind: MismatchCase},
+		{Path: cafeNFD, Committed: cafeNFC, Kind: MismatchNormalization},
+	}
+	for i, want := range expected {
+		if mismatches[i] != want {
+			t.Errorf("Mismatch %d: expected %+v, got %+v", i, want, mismatches[i])
+		}
+	}
+
+	// Identical names are never reported
+	if mismatches := FindPathMismatches([]string{cafeNFC}, []string{cafeNFC}); len(mismatches) != 0 {
+		t.Errorf("Expected no mismatches for identical names, got %+v", mismatches)
+	}
+}
@@ -0,0 +1,51 @@
+package workspace
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// SealFiles returns the files of a new seal: the files of the parent seal,
+// with staged files taken from the workspace scan and staged removals left
+// out. A staged file that is missing from the workspace must have been
+// staged as a removal, so that a file deleted after it was gathered is not
+// dropped from the seal silently. The result is sorted by path.
+func SealFiles(parent, scanned []wsindex.FileMetadata, staged, removed []string) ([]wsindex.FileMetadata, error) {
+	removedSet := make(map[string]bool, len(removed))
+	for _, path := range removed {
+		removedSet[path] = true
+	}
+
+	scannedByPath := make(map[string]wsindex.FileMetadata, len(scanned))
+	for _, file := range scanned {
+		scannedByPath[file.Path] = file
+	}
+
+	files := make(map[string]wsindex.FileMetadata, len(parent)+len(staged))
+	for _, file := range parent {
+		if !removedSet[file.Path] {
+			files[file.Path] = file
+		}
+	}
+	for _, path := range staged {
+		if removedSet[path] {
+			continue
+		}
+		file, ok := scannedByPath[path]
+		if !ok {
+			return nil, fmt.Errorf("staged file %s no longer exists; gather it again to stage its removal", path)
+		}
+		files[path] = file
+	}
+
+	result := make([]wsindex.FileMetadata, 0, len(files))
+	for _, file := range files {
+		result = append(result, file)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// scanFiles lists the files of the workspace as ScanWorkspace sees them
+func scanFiles(t *testing.T, materializer *Materializer) []wsindex.FileMetadata {
+	index, err := materializer.ScanWorkspace()
+	if err != nil {
+		t.Fatalf("ScanWorkspace failed: %v", err)
+	}
+	files, err := wsindex.NewLoader(materializer.CAS).ListAll(index)
+	if err != nil {
+		t.Fatalf("Failed to list workspace files: %v", err)
+	}
+	return files
+}
+
+func TestSealFilesRemovesStagedDeletion(t *testing.T) {
+	_, workDir, materializer, cleanup := setupTestWorkspace(t)
+	defer cleanup()
+
+	for name, content := range map[string]string{"keep.txt": "keep", "gone.txt": "gone", "src/main.go": "package main"} {
+		path := filepath.Join(workDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	commitBuilder := commit.NewCommitBuilder(materializer.CAS, history.NewMMR())
+	first, err := commitBuilder.CreateCommit(scanFiles(t, materializer), nil, "test-author", "test-committer", "Add files")
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	firstHash := commitBuilder.GetCommitHash(first)
+
+	// Delete a tracked file and seal its removal without staging anything else
+	if err := os.Remove(filepath.Join(workDir, "gone.txt")); err != nil {
+		t.Fatalf("Failed to delete gone.txt: %v", err)
+	}
+
+	var blake3Hash [32]byte
+	copy(blake3Hash[:], firstHash[:])
+	parentIndex, err := materializer.CreateTargetIndex(refs.Timeline{Blake3Hash: blake3Hash})
+	if err != nil {
+		t.Fatalf("CreateTargetIndex failed: %v", err)
+	}
+	parentFiles, err := wsindex.NewLoader(materializer.CAS).ListAll(parentIndex)
+	if err != nil {
+		t.Fatalf("Failed to list parent files: %v", err)
+	}
+
+	files, err := SealFiles(parentFiles, scanFiles(t, materializer), nil, []string{"gone.txt"})
+	if err != nil {
+		t.Fatalf("SealFiles failed: %v", err)
+	}
+
+	second, err := commitBuilder.CreateCommit(files, []cas.Hash{firstHash}, "test-author", "test-committer", "Remove gone.txt")
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	commitReader := commit.NewCommitReader(materializer.CAS)
+	tree, err := commitReader.ReadTree(second)
+	if err != nil {
+		t.Fatalf("Failed to read tree: %v", err)
+	}
+	sealed, err := commitReader.ListFiles(tree)
+	if err != nil {
+		t.Fatalf("Failed to list sealed files: %v", err)
+	}
+
+	want := []string{"keep.txt", "src/main.go"}
+	if !reflect.DeepEqual(sealed, want) {
+		t.Errorf("Expected sealed files %v, got %v", want, sealed)
+	}
+}
+
+func TestSealFilesRequiresStagedRemoval(t *testing.T) {
+	parent := []wsindex.FileMetadata{{Path: "a.txt"}, {Path: "b.txt"}}
+	scanned := []wsindex.FileMetadata{{Path: "a.txt", Size: 2}}
+
+	// b.txt was gathered and then deleted, but its removal was not staged
+	if _, err := SealFiles(parent, scanned, []string{"a.txt", "b.txt"}, nil); err == nil {
+		t.Error("Expected an error for a staged file missing from the workspace")
+	}
+
+	// A missing file that is not staged stays in the seal
+	files, err := SealFiles(parent, scanned, []string{"a.txt"}, nil)
+	if err != nil {
+		t.Fatalf("SealFiles failed: %v", err)
+	}
+	if len(files) != 2 || files[0].Size != 2 || files[1].Path != "b.txt" {
+		t.Errorf("Expected staged a.txt and unchanged b.txt, got %+v", files)
+	}
+}
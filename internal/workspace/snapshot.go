@@ -0,0 +1,159 @@
+package workspace
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// StagedSnapshots holds the content gather stored for each staged file
+// when core.snapshotStaging is on, keyed by path. Seal commits these
+// snapshots instead of rereading the working tree, so edits made after
+// gather are left out of the seal.
+type StagedSnapshots map[string]wsindex.FileMetadata
+
+// SnapshotFiles stores the current content of workspace files in the CAS
+// and returns their metadata, as ScanWorkspace records it. Paths are
+// relative to the working directory.
+func (m *Materializer) SnapshotFiles(paths []string) ([]wsindex.FileMetadata, error) {
+	chunkRules, err := filechunk.LoadProfileRules(m.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chunk profiles: %w", err)
+	}
+
+	files := make([]wsindex.FileMetadata, 0, len(paths))
+	for _, relPath := range paths {
+		path := filepath.Join(m.WorkDir, filepath.FromSlash(relPath))
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", relPath, err)
+		}
+		file, err := m.storeFile(path, relPath, info, chunkRules)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Files returns the snapshots sorted by path
+func (s StagedSnapshots) Files() []wsindex.FileMetadata {
+	files := make([]wsindex.FileMetadata, 0, len(s))
+	for _, file := range s {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// ReadStagedSnapshots reads the snapshots recorded in path. A missing file
+// holds no snapshots.
+//
+// Each line holds one file: the hash, kind and size of its content root,
+// then its mode, modification time in nanoseconds, size and checksum, and
+// last its path, separated by single spaces.
+func ReadStagedSnapshots(path string) (StagedSnapshots, error) {
+	snapshots := make(StagedSnapshots)
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return snapshots, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		snapshot, err := parseSnapshotLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		snapshots[snapshot.Path] = snapshot
+	}
+	return snapshots, scanner.Err()
+}
+
+// WriteStagedSnapshots replaces the snapshots recorded in path. Without
+// snapshots the file is removed.
+func WriteStagedSnapshots(path string, snapshots StagedSnapshots) error {
+	if len(snapshots) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var b strings.Builder
+	for _, file := range snapshots.Files() {
+		fmt.Fprintf(&b, "%s %d %d %d %d %d %s %s\n",
+			file.FileRef.Hash, file.FileRef.Kind, file.FileRef.Size,
+			file.Mode, file.ModTime.UnixNano(), file.Size, file.Checksum, file.Path)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func parseSnapshotLine(line string) (wsindex.FileMetadata, error) {
+	fields := strings.SplitN(line, " ", 8)
+	if len(fields) != 8 || fields[7] == "" {
+		return wsindex.FileMetadata{}, fmt.Errorf("malformed snapshot %q", line)
+	}
+
+	var numbers [5]int64
+	for i := range numbers {
+		n, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			return wsindex.FileMetadata{}, fmt.Errorf("malformed snapshot %q: %w", line, err)
+		}
+		numbers[i] = n
+	}
+	rootHash, err := parseHash(fields[0])
+	if err != nil {
+		return wsindex.FileMetadata{}, err
+	}
+	checksum, err := parseHash(fields[6])
+	if err != nil {
+		return wsindex.FileMetadata{}, err
+	}
+
+	return wsindex.FileMetadata{
+		Path: fields[7],
+		FileRef: filechunk.NodeRef{
+			Hash: rootHash,
+			Kind: filechunk.NodeKind(numbers[0]),
+			Size: numbers[1],
+		},
+		Mode:     uint32(numbers[2]),
+		ModTime:  time.Unix(0, numbers[3]),
+		Size:     numbers[4],
+		Checksum: checksum,
+	}, nil
+}
+
+func parseHash(s string) (cas.Hash, error) {
+	var hash cas.Hash
+	decoded, err := hex.DecodeString(s)
+	if err != nil || len(decoded) != len(hash) {
+		return hash, fmt.Errorf("malformed hash %q", s)
+	}
+	copy(hash[:], decoded)
+	return hash, nil
+}
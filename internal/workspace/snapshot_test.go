@@ -0,0 +1,101 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+)
+
+func TestStagedSnapshotsRoundTrip(t *testing.T) {
+	_, workDir, materializer, cleanup := setupTestWorkspace(t)
+	defer cleanup()
+
+	for name, content := range map[string]string{"a.txt": "first", "dir/b c.txt": "spaces in the name"} {
+		path := filepath.Join(workDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	files, err := materializer.SnapshotFiles([]string{"a.txt", "dir/b c.txt"})
+	if err != nil {
+		t.Fatalf("SnapshotFiles failed: %v", err)
+	}
+	snapshots := make(StagedSnapshots)
+	for _, file := range files {
+		snapshots[file.Path] = file
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshots")
+	if err := WriteStagedSnapshots(path, snapshots); err != nil {
+		t.Fatalf("WriteStagedSnapshots failed: %v", err)
+	}
+	read, err := ReadStagedSnapshots(path)
+	if err != nil {
+		t.Fatalf("ReadStagedSnapshots failed: %v", err)
+	}
+	if len(read) != len(snapshots) {
+		t.Fatalf("Expected %d snapshots, got %d", len(snapshots), len(read))
+	}
+	for name, want := range snapshots {
+		got := read[name]
+		if !got.ModTime.Equal(want.ModTime) {
+			t.Errorf("%s: expected mtime %v, got %v", name, want.ModTime, got.ModTime)
+		}
+		got.ModTime = want.ModTime
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+
+	// Writing no snapshots removes the file
+	if err := WriteStagedSnapshots(path, nil); err != nil {
+		t.Fatalf("WriteStagedSnapshots failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the snapshots file to be removed, got %v", err)
+	}
+	if read, err := ReadStagedSnapshots(path); err != nil || len(read) != 0 {
+		t.Errorf("Expected no snapshots from a missing file, got %v, %v", read, err)
+	}
+}
+
+func TestSealFilesFromSnapshot(t *testing.T) {
+	_, workDir, materializer, cleanup := setupTestWorkspace(t)
+	defer cleanup()
+
+	path := filepath.Join(workDir, "notes.txt")
+	if err := os.WriteFile(path, []byte("gathered"), 0644); err != nil {
+		t.Fatalf("Failed to create notes.txt: %v", err)
+	}
+	snapshot, err := materializer.SnapshotFiles([]string{"notes.txt"})
+	if err != nil {
+		t.Fatalf("SnapshotFiles failed: %v", err)
+	}
+
+	// Editing the file after the snapshot does not change what is sealed
+	if err := os.WriteFile(path, []byte("edited after gather"), 0644); err != nil {
+		t.Fatalf("Failed to edit notes.txt: %v", err)
+	}
+
+	files, err := SealFiles(nil, snapshot, []string{"notes.txt"}, nil)
+	if err != nil {
+		t.Fatalf("SealFiles failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected one sealed file, got %d", len(files))
+	}
+	content, err := filechunk.NewLoader(materializer.CAS).ReadAll(files[0].FileRef)
+	if err != nil {
+		t.Fatalf("Failed to read sealed content: %v", err)
+	}
+	if string(content) != "gathered" {
+		t.Errorf("Expected the gathered content to be sealed, got %q", content)
+	}
+}
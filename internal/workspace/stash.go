@@ -0,0 +1,357 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// ErrNothingToStash is returned by StashChanges when no file is selected
+var ErrNothingToStash = errors.New("no local changes to stash")
+
+// stashMarker separates the description of a stash tag from the record of
+// the files the stash took
+const stashMarker = " stashed="
+
+// StashOptions selects the changes StashChanges takes. By default every
+// change to a sealed or staged file is stashed.
+type StashOptions struct {
+	// Paths limits the stash to files equal to or below these paths
+	Paths []string
+	// Staged lists the staged files, including staged removals
+	Staged []string
+	// KeepIndex leaves staged files out of the stash
+	KeepIndex bool
+	// IncludeUntracked also stashes files that are neither sealed nor
+	// staged, except those Ignored reports
+	IncludeUntracked bool
+	Ignored          func(path string) bool
+}
+
+// stashRecord is kept in the description of a selective stash's tag
+type stashRecord struct {
+	Paths   []string `json:"paths"`
+	Deleted []string `json:"deleted,omitempty"`
+	Created int64    `json:"created"`
+}
+
+// selects reports whether a path falls under the paths to stash
+func (o StashOptions) selects(path string) bool {
+	if len(o.Paths) == 0 {
+		return true
+	}
+	for _, p := range o.Paths {
+		p = filepath.ToSlash(filepath.Clean(p))
+		if p == "." || p == path || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// StashChanges stashes the local changes opts selects under name and
+// reverts them in the workspace: modified files get their sealed content
+// back, deleted ones are restored and new ones are removed. The stash
+// records which files it took so that ApplyStash restores exactly those.
+func (sm *StashManager) StashChanges(name, description string, opts StashOptions) (*Stash, error) {
+	m := sm.Materializer
+	refsManager, err := refs.NewRefsManager(m.IvaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	stashTagName := fmt.Sprintf("stash/%s", name)
+	if refsManager.TimelineExists(stashTagName, refs.TagTimeline) {
+		return nil, fmt.Errorf("stash %s already exists", name)
+	}
+
+	sealed, err := sm.sealedFiles(refsManager)
+	if err != nil {
+		return nil, err
+	}
+	currentIndex, err := m.ScanWorkspace()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan workspace for stash: %w", err)
+	}
+	currentFiles, err := wsindex.NewLoader(m.CAS).ListAll(currentIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace index: %w", err)
+	}
+
+	staged := make(map[string]bool, len(opts.Staged))
+	for _, path := range opts.Staged {
+		staged[path] = true
+	}
+	skip := func(path string) bool {
+		return !opts.selects(path) || opts.KeepIndex && staged[path]
+	}
+
+	var stashed []wsindex.FileMetadata
+	present := make(map[string]bool, len(currentFiles))
+	for _, file := range currentFiles {
+		present[file.Path] = true
+		if skip(file.Path) {
+			continue
+		}
+		if old, tracked := sealed[file.Path]; tracked {
+			if old.Checksum == file.Checksum {
+				continue
+			}
+		} else if !staged[file.Path] && (!opts.IncludeUntracked || opts.Ignored != nil && opts.Ignored(file.Path)) {
+			continue
+		}
+		stashed = append(stashed, file)
+	}
+	var deleted []string
+	for path := range sealed {
+		if !present[path] && !skip(path) {
+			deleted = append(deleted, path)
+		}
+	}
+	sort.Strings(deleted)
+	if len(stashed) == 0 && len(deleted) == 0 {
+		return nil, ErrNothingToStash
+	}
+
+	stashIndex, err := wsindex.NewBuilder(m.CAS).Build(stashed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build stash index: %w", err)
+	}
+	stash := &Stash{
+		Name:        name,
+		Description: description,
+		Index:       stashIndex,
+		Created:     time.Now(),
+		Deleted:     deleted,
+	}
+	for _, file := range stashed {
+		stash.Paths = append(stash.Paths, file.Path)
+	}
+	record, err := json.Marshal(stashRecord{Paths: stash.Paths, Deleted: deleted, Created: stash.Created.UnixNano()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode stash record: %w", err)
+	}
+	err = refsManager.CreateTimeline(
+		stashTagName,
+		refs.TagTimeline,
+		stashIndex.Hash,
+		[32]byte{}, // No SHA256
+		"",         // No Git SHA1
+		fmt.Sprintf("Stash: %s - %s%s%s", name, description, stashMarker, record),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record stash: %w", err)
+	}
+
+	// The stash is safe; now take the changes out of the workspace
+	for _, file := range stashed {
+		if old, tracked := sealed[file.Path]; tracked {
+			err = m.restoreFile(file.Path, old.FileRef, fs.FileMode(file.Mode))
+		} else {
+			err = m.removeFile(file.Path)
+		}
+		if err != nil {
+			return stash, err
+		}
+	}
+	for _, path := range deleted {
+		if err := m.restoreFile(path, sealed[path].FileRef, fs.FileMode(sealed[path].Mode)); err != nil {
+			return stash, err
+		}
+	}
+	return stash, nil
+}
+
+// PopStash applies a stash and drops it. The stash is kept if it cannot be
+// applied.
+func (sm *StashManager) PopStash(name string) error {
+	if err := sm.ApplyStash(name); err != nil {
+		return err
+	}
+	return sm.DropStash(name)
+}
+
+// Stashes returns the stashes with their details, newest first. Stashes of
+// the whole workspace do not record when they were made and come last.
+func (sm *StashManager) Stashes() ([]Stash, error) {
+	refsManager, err := refs.NewRefsManager(sm.Materializer.IvaldiDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refs manager: %w", err)
+	}
+	defer refsManager.Close()
+
+	tags, err := refsManager.ListTimelines(refs.TagTimeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	var stashes []Stash
+	for _, tag := range tags {
+		name, ok := strings.CutPrefix(tag.Name, "stash/")
+		if !ok {
+			continue
+		}
+		stash := Stash{Name: name, Index: wsindex.IndexRef{Hash: cas.Hash(tag.Blake3Hash)}}
+		var record *stashRecord
+		stash.Description, record = parseStashDescription(name, tag.Description)
+		if record != nil {
+			stash.Paths = record.Paths
+			stash.Deleted = record.Deleted
+			stash.Created = time.Unix(0, record.Created)
+		}
+		stashes = append(stashes, stash)
+	}
+	sort.SliceStable(stashes, func(i, j int) bool {
+		if !stashes[i].Created.Equal(stashes[j].Created) {
+			return stashes[i].Created.After(stashes[j].Created)
+		}
+		return stashes[i].Name < stashes[j].Name
+	})
+	return stashes, nil
+}
+
+// parseStashDescription splits the description of a stash tag into the
+// stash's own description and its record, which is nil for stashes of the
+// whole workspace
+func parseStashDescription(name, tagDescription string) (string, *stashRecord) {
+	description := strings.TrimPrefix(tagDescription, fmt.Sprintf("Stash: %s - ", name))
+	// The description itself may contain the marker, so try each one
+	for offset := 0; ; {
+		i := strings.Index(description[offset:], stashMarker)
+		if i < 0 {
+			return description, nil
+		}
+		i += offset
+		var record stashRecord
+		if err := json.Unmarshal([]byte(description[i+len(stashMarker):]), &record); err == nil {
+			return description[:i], &record
+		}
+		offset = i + len(stashMarker)
+	}
+}
+
+// applyRecorded restores the files a selective stash took. It refuses to
+// touch a file that has local changes of its own, that is, one whose
+// content is neither the sealed nor the stashed version.
+func (sm *StashManager) applyRecorded(refsManager *refs.RefsManager, name string, stashIndex wsindex.IndexRef, record *stashRecord) error {
+	m := sm.Materializer
+	sealed, err := sm.sealedFiles(refsManager)
+	if err != nil {
+		return err
+	}
+	stashed := make(map[string]wsindex.FileMetadata, len(record.Paths))
+	files, err := wsindex.NewLoader(m.CAS).ListAll(stashIndex)
+	if err != nil {
+		return fmt.Errorf("failed to read stash %s: %w", name, err)
+	}
+	for _, file := range files {
+		stashed[file.Path] = file
+	}
+
+	var conflicts []string
+	for _, path := range append(append([]string(nil), record.Paths...), record.Deleted...) {
+		content, err := ReadFileContent(filepath.Join(m.WorkDir, path))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		exists := err == nil
+		matches := func(files map[string]wsindex.FileMetadata) bool {
+			file, ok := files[path]
+			return ok == exists && (!ok || file.Checksum == cas.SumB3(content))
+		}
+		if !matches(sealed) && !matches(stashed) {
+			conflicts = append(conflicts, path)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("stash %s would overwrite local changes to: %s", name, strings.Join(conflicts, ", "))
+	}
+
+	for _, path := range record.Paths {
+		file, ok := stashed[path]
+		if !ok {
+			return fmt.Errorf("stash %s is missing %s", name, path)
+		}
+		if err := m.restoreFile(path, file.FileRef, fs.FileMode(file.Mode)); err != nil {
+			return err
+		}
+	}
+	for _, path := range record.Deleted {
+		if err := m.removeFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealedFiles returns the files of the current timeline's last seal by path
+func (sm *StashManager) sealedFiles(refsManager *refs.RefsManager) (map[string]wsindex.FileMetadata, error) {
+	timelineName, err := refsManager.GetCurrentTimeline()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current timeline: %w", err)
+	}
+	baseIndex, err := sm.Materializer.getTimelineBaseIndex(timelineName, refsManager)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sealed files: %w", err)
+	}
+	files, err := wsindex.NewLoader(sm.Materializer.CAS).ListAll(baseIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sealed files: %w", err)
+	}
+	sealed := make(map[string]wsindex.FileMetadata, len(files))
+	for _, file := range files {
+		sealed[file.Path] = file
+	}
+	return sealed, nil
+}
+
+// restoreFile writes stored content to a workspace file with the given
+// permissions, creating parent directories as needed
+func (m *Materializer) restoreFile(relPath string, fileRef filechunk.NodeRef, mode fs.FileMode) error {
+	if mode.Perm() == 0 {
+		mode = 0644
+	}
+	fullPath := filepath.Join(m.WorkDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", relPath, err)
+	}
+	if mode&fs.ModeSymlink != 0 {
+		if err := m.writeLink(fullPath, fileRef); err != nil {
+			return fmt.Errorf("failed to write link %s: %w", relPath, err)
+		}
+		return nil
+	}
+	if err := m.writeFile(fullPath, fileRef, mode.Perm()); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", relPath, err)
+	}
+	// writeFile leaves the permissions of an existing file alone
+	if err := os.Chmod(fullPath, mode.Perm()); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", relPath, err)
+	}
+	return nil
+}
+
+// removeFile removes a workspace file and any directories it leaves empty
+func (m *Materializer) removeFile(relPath string) error {
+	fullPath := filepath.Join(m.WorkDir, relPath)
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove file %s: %w", relPath, err)
+	}
+	if err := m.markPlaceholder(fullPath, false); err != nil {
+		return err
+	}
+	m.removeEmptyDirectories(filepath.Dir(fullPath))
+	return nil
+}
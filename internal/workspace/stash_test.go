@@ -0,0 +1,233 @@
+package workspace
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/history"
+	"github.com/javanhut/Ivaldi-vcs/internal/refs"
+)
+
+var stashSealed = map[string]string{"a.txt": "alpha", "b.txt": "bravo", "src/c.txt": "charlie"}
+
+// setupStashTest seals stashSealed on main and then changes the workspace:
+// a.txt and b.txt are modified, src/c.txt is deleted, added.txt is new and
+// new.txt and debug.log are untracked. b.txt and added.txt are staged.
+func setupStashTest(t *testing.T) (string, *StashManager, []string) {
+	ivaldiDir, workDir, materializer, cleanup := setupTestWorkspace(t)
+	t.Cleanup(cleanup)
+
+	writeFiles(t, workDir, stashSealed)
+	commitBuilder := commit.NewCommitBuilder(materializer.CAS, history.NewMMR())
+	sealed, err := commitBuilder.CreateCommit(scanFiles(t, materializer), nil, "test-author", "test-committer", "Add files")
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("Failed to create refs manager: %v", err)
+	}
+	defer refsManager.Close()
+	if err := refsManager.UpdateTimeline("main", refs.LocalTimeline, commitBuilder.GetCommitHash(sealed), [32]byte{}, ""); err != nil {
+		t.Fatalf("UpdateTimeline failed: %v", err)
+	}
+
+	writeFiles(t, workDir, map[string]string{
+		"a.txt":     "ALPHA",
+		"b.txt":     "BRAVO",
+		"added.txt": "added",
+		"new.txt":   "new",
+		"debug.log": "log",
+	})
+	if err := os.Remove(filepath.Join(workDir, "src", "c.txt")); err != nil {
+		t.Fatalf("Failed to delete src/c.txt: %v", err)
+	}
+	return workDir, NewStashManager(materializer), []string{"added.txt", "b.txt"}
+}
+
+func writeFiles(t *testing.T, workDir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(workDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+// readWorkspace returns the content of every workspace file by path
+func readWorkspace(t *testing.T, workDir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.WalkDir(workDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".ivaldi" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(workDir, path)
+		files[filepath.ToSlash(relPath)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read workspace: %v", err)
+	}
+	return files
+}
+
+func TestStashChanges(t *testing.T) {
+	ignored := func(path string) bool { return strings.HasSuffix(path, ".log") }
+	tests := []struct {
+		name        string
+		opts        StashOptions
+		wantPaths   []string
+		wantDeleted []string
+	}{
+		{
+			name:        "default",
+			wantPaths:   []string{"a.txt", "added.txt", "b.txt"},
+			wantDeleted: []string{"src/c.txt"},
+		},
+		{
+			name:        "keep index",
+			opts:        StashOptions{KeepIndex: true},
+			wantPaths:   []string{"a.txt"},
+			wantDeleted: []string{"src/c.txt"},
+		},
+		{
+			name:        "include untracked",
+			opts:        StashOptions{IncludeUntracked: true},
+			wantPaths:   []string{"a.txt", "added.txt", "b.txt", "new.txt"},
+			wantDeleted: []string{"src/c.txt"},
+		},
+		{
+			name:        "keep index and include untracked",
+			opts:        StashOptions{KeepIndex: true, IncludeUntracked: true},
+			wantPaths:   []string{"a.txt", "new.txt"},
+			wantDeleted: []string{"src/c.txt"},
+		},
+		{
+			name:        "directory path",
+			opts:        StashOptions{Paths: []string{"src"}},
+			wantDeleted: []string{"src/c.txt"},
+		},
+		{
+			name:      "paths without untracked",
+			opts:      StashOptions{Paths: []string{"b.txt", "new.txt"}},
+			wantPaths: []string{"b.txt"},
+		},
+		{
+			name:      "paths with untracked",
+			opts:      StashOptions{Paths: []string{"b.txt", "new.txt"}, IncludeUntracked: true},
+			wantPaths: []string{"b.txt", "new.txt"},
+		},
+		{
+			name:      "paths with keep index",
+			opts:      StashOptions{Paths: []string{"a.txt", "b.txt"}, KeepIndex: true},
+			wantPaths: []string{"a.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir, stashManager, staged := setupStashTest(t)
+			tt.opts.Staged = staged
+			tt.opts.Ignored = ignored
+			before := readWorkspace(t, workDir)
+
+			stash, err := stashManager.StashChanges("s", "work in progress", tt.opts)
+			if err != nil {
+				t.Fatalf("StashChanges failed: %v", err)
+			}
+			if !reflect.DeepEqual(stash.Paths, tt.wantPaths) || !reflect.DeepEqual(stash.Deleted, tt.wantDeleted) {
+				t.Fatalf("Stashed %v and deleted %v, want %v and %v", stash.Paths, stash.Deleted, tt.wantPaths, tt.wantDeleted)
+			}
+
+			// Exactly the stashed files are back at their sealed state
+			want := make(map[string]string)
+			for path, content := range before {
+				want[path] = content
+			}
+			for _, path := range append(append([]string(nil), tt.wantPaths...), tt.wantDeleted...) {
+				if content, ok := stashSealed[path]; ok {
+					want[path] = content
+				} else {
+					delete(want, path)
+				}
+			}
+			if got := readWorkspace(t, workDir); !reflect.DeepEqual(got, want) {
+				t.Errorf("Workspace after stash = %v, want %v", got, want)
+			}
+
+			// The record survives in the tag
+			stashes, err := stashManager.Stashes()
+			if err != nil || len(stashes) != 1 {
+				t.Fatalf("Stashes = %v, %v", stashes, err)
+			}
+			if stashes[0].Description != "work in progress" || !reflect.DeepEqual(stashes[0].Paths, tt.wantPaths) {
+				t.Errorf("Unexpected stash details: %+v", stashes[0])
+			}
+
+			if err := stashManager.PopStash("s"); err != nil {
+				t.Fatalf("PopStash failed: %v", err)
+			}
+			if got := readWorkspace(t, workDir); !reflect.DeepEqual(got, before) {
+				t.Errorf("Workspace after pop = %v, want %v", got, before)
+			}
+			if names, _ := stashManager.ListStashes(); len(names) != 0 {
+				t.Errorf("Expected pop to drop the stash, got %v", names)
+			}
+		})
+	}
+}
+
+func TestStashChangesNothingToStash(t *testing.T) {
+	_, stashManager, staged := setupStashTest(t)
+	_, err := stashManager.StashChanges("s", "", StashOptions{Paths: []string{"new.txt"}, Staged: staged})
+	if !errors.Is(err, ErrNothingToStash) {
+		t.Errorf("Expected ErrNothingToStash for an untracked path, got %v", err)
+	}
+}
+
+func TestApplyStashRefusesLocalChanges(t *testing.T) {
+	workDir, stashManager, staged := setupStashTest(t)
+	if _, err := stashManager.StashChanges("s", "", StashOptions{Staged: staged}); err != nil {
+		t.Fatalf("StashChanges failed: %v", err)
+	}
+
+	// Changing a file the stash holds blocks it; other files do not matter
+	writeFiles(t, workDir, map[string]string{"a.txt": "local", "new.txt": "newer"})
+	if err := stashManager.PopStash("s"); err == nil || !strings.Contains(err.Error(), "a.txt") {
+		t.Fatalf("Expected PopStash to refuse overwriting a.txt, got %v", err)
+	}
+	if names, _ := stashManager.ListStashes(); len(names) != 1 {
+		t.Fatalf("A refused pop should keep the stash, got %v", names)
+	}
+
+	writeFiles(t, workDir, map[string]string{"a.txt": stashSealed["a.txt"]})
+	if err := stashManager.ApplyStash("s"); err != nil {
+		t.Fatalf("ApplyStash failed: %v", err)
+	}
+	got := readWorkspace(t, workDir)
+	if got["a.txt"] != "ALPHA" || got["new.txt"] != "newer" {
+		t.Errorf("Unexpected workspace after apply: %v", got)
+	}
+	if _, ok := got["src/c.txt"]; ok {
+		t.Error("Applying the stash should delete src/c.txt again")
+	}
+}
@@ -0,0 +1,274 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/cas"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+)
+
+// racyWindow is how recently a file may have been modified and still be
+// cached. A file changed again within its timestamp granularity keeps the
+// same modification time, so recently modified files are always read.
+const racyWindow = 2 * time.Second
+
+// StatCache remembers the size and modification time each workspace file
+// had when its content was last found to match a sealed version, and which
+// content that was. A file whose size and modification time are unchanged
+// need not be read again.
+type StatCache struct {
+	path    string
+	entries map[string]StatEntry
+	used    map[string]bool
+	changed bool
+}
+
+// StatEntry is the cached state of one workspace file
+type StatEntry struct {
+	Size    int64
+	ModTime time.Time
+	FileRef cas.Hash // Content root of the sealed version the file matched
+}
+
+// LoadStatCache reads the stat cache of a repository. The cache only saves
+// work, so a missing or unreadable cache is empty.
+//
+// Each line holds one file: its size, modification time in nanoseconds and
+// content root hash, and last its path, separated by single spaces.
+func LoadStatCache(ivaldiDir string) *StatCache {
+	cache := &StatCache{
+		path:    filepath.Join(ivaldiDir, "statcache"),
+		entries: make(map[string]StatEntry),
+		used:    make(map[string]bool),
+	}
+
+	file, err := os.Open(cache.path)
+	if err != nil {
+		return cache
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		path, entry, err := parseStatCacheLine(scanner.Text())
+		if err != nil {
+			cache.entries = make(map[string]StatEntry)
+			cache.changed = true
+			return cache
+		}
+		cache.entries[path] = entry
+	}
+	return cache
+}
+
+func parseStatCacheLine(line string) (string, StatEntry, error) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 || fields[3] == "" {
+		return "", StatEntry{}, fmt.Errorf("malformed stat cache entry %q", line)
+	}
+	var numbers [2]int64
+	for i := range numbers {
+		n, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil {
+			return "", StatEntry{}, fmt.Errorf("malformed stat cache entry %q: %w", line, err)
+		}
+		numbers[i] = n
+	}
+	fileRef, err := parseHash(fields[2])
+	if err != nil {
+		return "", StatEntry{}, err
+	}
+	return fields[3], StatEntry{Size: numbers[0], ModTime: time.Unix(0, numbers[1]), FileRef: fileRef}, nil
+}
+
+// Lookup returns the content root a file matched when it was cached, if
+// its size and modification time are still the same
+func (c *StatCache) Lookup(relPath string, info fs.FileInfo) (cas.Hash, bool) {
+	entry, ok := c.entries[relPath]
+	c.used[relPath] = ok
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return cas.Hash{}, false
+	}
+	return entry.FileRef, true
+}
+
+// Has reports whether a file has an entry, current or not
+func (c *StatCache) Has(relPath string) bool {
+	_, ok := c.entries[relPath]
+	return ok
+}
+
+// Update records that a file with the given stat matches the content with
+// root fileRef. Files modified within the last two seconds are not cached.
+func (c *StatCache) Update(relPath string, info fs.FileInfo, fileRef cas.Hash) {
+	if time.Since(info.ModTime()) < racyWindow {
+		c.Remove(relPath)
+		return
+	}
+	entry := StatEntry{Size: info.Size(), ModTime: info.ModTime(), FileRef: fileRef}
+	if c.entries[relPath] != entry {
+		c.entries[relPath] = entry
+		c.changed = true
+	}
+	c.used[relPath] = true
+}
+
+// FileRefs returns the content roots of all cached files. Scans reuse
+// them without storing the content again, so they must stay stored.
+func (c *StatCache) FileRefs() []cas.Hash {
+	refs := make([]cas.Hash, 0, len(c.entries))
+	for _, entry := range c.entries {
+		refs = append(refs, entry.FileRef)
+	}
+	return refs
+}
+
+// Remove forgets a file
+func (c *StatCache) Remove(relPath string) {
+	if _, ok := c.entries[relPath]; ok {
+		delete(c.entries, relPath)
+		c.changed = true
+	}
+}
+
+// Save writes the cache back if it changed. Entries that were neither
+// looked up nor updated since the cache was loaded belong to files that no
+// longer exist and are dropped.
+func (c *StatCache) Save() error {
+	paths := make([]string, 0, len(c.entries))
+	for path := range c.entries {
+		if c.used[path] {
+			paths = append(paths, path)
+		}
+	}
+	if !c.changed && len(paths) == len(c.entries) {
+		return nil
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		entry := c.entries[path]
+		fmt.Fprintf(&b, "%d %d %s %s\n", entry.Size, entry.ModTime.UnixNano(), entry.FileRef, path)
+	}
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write stat cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write stat cache: %w", err)
+	}
+	c.changed = false
+	return nil
+}
+
+// StatChecker compares workspace files with their sealed versions through
+// a StatCache, reading a file only when its stat no longer matches the
+// cache.
+type StatChecker struct {
+	CAS    cas.CAS
+	Cache  *StatCache
+	Sealed map[string]filechunk.NodeRef // Files of the last seal by path
+
+	// Approximate counts the files that had no cache entry and were
+	// judged by their size alone
+	Approximate int
+}
+
+// NewStatChecker creates a StatChecker for the files of a seal, using the
+// repository's stat cache
+func NewStatChecker(casStore cas.CAS, ivaldiDir string, sealed map[string]filechunk.NodeRef) *StatChecker {
+	return &StatChecker{CAS: casStore, Cache: LoadStatCache(ivaldiDir), Sealed: sealed}
+}
+
+// Modified reports whether a workspace file differs from its sealed
+// version. A file whose size differs is modified, and one whose stat
+// matches the cache is decided by the cache. A file the cache has an
+// outdated entry for is read and compared. A file the cache has never seen
+// is assumed unchanged when its size matches, which Approximate records.
+//
+// Outdated entries are kept, so that a file changed and then changed back
+// is read rather than assumed unchanged.
+func (c *StatChecker) Modified(relPath, path string, info fs.FileInfo) (bool, error) {
+	sealed, ok := c.Sealed[relPath]
+	if !ok {
+		return true, nil
+	}
+	fileRef, cached := c.Cache.Lookup(relPath, info)
+	if info.Size() != sealed.Size {
+		return true, nil
+	}
+	if cached {
+		return fileRef != sealed.Hash, nil
+	}
+	if !c.Cache.Has(relPath) {
+		c.Approximate++
+		return false, nil
+	}
+
+	unchanged, err := c.sameContent(relPath, path, sealed)
+	if err != nil {
+		return false, err
+	}
+	c.Record(relPath, info, unchanged)
+	return !unchanged, nil
+}
+
+// sameContent compares a workspace file with its sealed version a block at
+// a time, so that big files are not read into memory
+func (c *StatChecker) sameContent(relPath, path string, sealed filechunk.NodeRef) (bool, error) {
+	file, err := openFileContent(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", relPath, err)
+	}
+	defer file.Close()
+	stored, err := filechunk.NewLoader(c.CAS).Reader(sealed)
+	if err != nil {
+		return false, fmt.Errorf("failed to read sealed %s: %w", relPath, err)
+	}
+	defer stored.Close()
+
+	workBuf, sealedBuf := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		n, err := io.ReadFull(file, workBuf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return false, fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+		m, err := io.ReadFull(stored, sealedBuf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return false, fmt.Errorf("failed to read sealed %s: %w", relPath, err)
+		}
+		if n != m || !bytes.Equal(workBuf[:n], sealedBuf[:m]) {
+			return false, nil
+		}
+		if n < len(workBuf) {
+			return true, nil
+		}
+	}
+}
+
+// Record notes the result of comparing a file with its sealed version in
+// full, so that a later Modified can rely on it
+func (c *StatChecker) Record(relPath string, info fs.FileInfo, unchanged bool) {
+	sealed, ok := c.Sealed[relPath]
+	switch {
+	case !ok:
+		c.Cache.Remove(relPath)
+	case unchanged:
+		c.Cache.Update(relPath, info, sealed.Hash)
+	default:
+		// Keep the outdated entry; its stat no longer matches the file
+		c.Cache.Lookup(relPath, info)
+	}
+}
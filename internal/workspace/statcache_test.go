@@ -0,0 +1,90 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+)
+
+func TestStatChecker(t *testing.T) {
+	ivaldiDir, workDir, materializer, cleanup := setupTestWorkspace(t)
+	defer cleanup()
+
+	// Seal a.txt and b.txt as they are now
+	old := time.Now().Add(-time.Hour)
+	write := func(name, content string, modTime time.Time) os.FileInfo {
+		path := filepath.Join(workDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set time of %s: %v", name, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		return info
+	}
+	write("a.txt", "alpha", old)
+	write("b.txt", "bravo", old)
+	files, err := materializer.SnapshotFiles([]string{"a.txt", "b.txt"})
+	if err != nil {
+		t.Fatalf("SnapshotFiles failed: %v", err)
+	}
+	sealed := make(map[string]filechunk.NodeRef)
+	for _, file := range files {
+		sealed[file.Path] = file.FileRef
+	}
+
+	modified := func(checker *StatChecker, name string, info os.FileInfo) bool {
+		changed, err := checker.Modified(name, filepath.Join(workDir, name), info)
+		if err != nil {
+			t.Fatalf("Modified(%s) failed: %v", name, err)
+		}
+		return changed
+	}
+
+	// A cold cache can only compare sizes
+	checker := NewStatChecker(materializer.CAS, ivaldiDir, sealed)
+	aInfo := write("a.txt", "ALPHA", old.Add(time.Minute))
+	if modified(checker, "a.txt", aInfo) || checker.Approximate != 1 {
+		t.Errorf("Uncached same-size file: expected an approximate match, got %d", checker.Approximate)
+	}
+	if !modified(checker, "b.txt", write("b.txt", "bravo!", old)) {
+		t.Error("A file whose size changed should be modified")
+	}
+
+	// A full comparison fills the cache
+	aInfo = write("a.txt", "alpha", old)
+	bInfo := write("b.txt", "bravo", old)
+	checker.Record("a.txt", aInfo, true)
+	checker.Record("b.txt", bInfo, true)
+	// Files modified moments ago might change again unnoticed
+	checker.Record("c.txt", write("c.txt", "charlie", time.Now()), true)
+	if err := checker.Cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	checker = NewStatChecker(materializer.CAS, ivaldiDir, sealed)
+	if modified(checker, "a.txt", aInfo) || checker.Approximate != 0 {
+		t.Error("Cached unchanged file should match without approximation")
+	}
+	if checker.Cache.Has("c.txt") {
+		t.Error("Recently modified files should not be cached")
+	}
+
+	// A same-size edit with a new modification time is read and found
+	bInfo = write("b.txt", "BRAVO", old.Add(time.Minute))
+	if !modified(checker, "b.txt", bInfo) {
+		t.Error("Same-size edit of a cached file should be modified")
+	}
+	// Reverting it is noticed too, as the outdated entry is kept
+	bInfo = write("b.txt", "bravo", old.Add(2*time.Minute))
+	if modified(checker, "b.txt", bInfo) || checker.Approximate != 0 {
+		t.Error("Reverted file should match without approximation")
+	}
+}
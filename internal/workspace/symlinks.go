@@ -0,0 +1,145 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+)
+
+// symlinkPlaceholders names the file in the .ivaldi directory listing, one
+// per line, the workspace paths that hold a symbolic link written as a
+// plain file, because core.symlinks is off or the link could not be made
+const symlinkPlaceholders = "symlinks"
+
+// ReadFileContent returns the content Ivaldi records for a workspace file:
+// the file's bytes, or for a symbolic link the link target with slashes,
+// rather than the content of what it points to
+func ReadFileContent(path string) ([]byte, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(filepath.ToSlash(target)), nil
+	}
+	return os.ReadFile(path)
+}
+
+// openFileContent opens the content ReadFileContent returns for reading a
+// block at a time
+func openFileContent(path string) (io.ReadCloser, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := ReadFileContent(path)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(target)), nil
+	}
+	return os.Open(path)
+}
+
+// linkMode returns the mode to record for a scanned file. A plain file the
+// workspace holds in place of a symbolic link is recorded as the link.
+func (m *Materializer) linkMode(relPath string, info fs.FileInfo) fs.FileMode {
+	if info.Mode().IsRegular() && m.placeholders()[filepath.ToSlash(relPath)] {
+		return fs.ModeSymlink | 0777
+	}
+	return info.Mode()
+}
+
+// writeLink checks out a symbolic link whose target is stored at fileRef.
+// With Symlinks off, or where the link cannot be created, the target is
+// written to a plain file instead, which is marked so that later scans
+// record it as the link again.
+func (m *Materializer) writeLink(fullPath string, fileRef filechunk.NodeRef) error {
+	var target bytes.Buffer
+	if err := filechunk.NewLoader(m.CAS).WriteTo(fileRef, &target); err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if m.Symlinks {
+		if err := os.Symlink(filepath.FromSlash(target.String()), fullPath); err == nil {
+			return m.markPlaceholder(fullPath, false)
+		}
+	}
+	if err := os.WriteFile(fullPath, target.Bytes(), 0644); err != nil {
+		return err
+	}
+	return m.markPlaceholder(fullPath, true)
+}
+
+// placeholders returns the marked placeholder paths, reading them the
+// first time they are needed
+func (m *Materializer) placeholders() map[string]bool {
+	if m.linkFiles != nil {
+		return m.linkFiles
+	}
+	m.linkFiles = make(map[string]bool)
+	file, err := os.Open(filepath.Join(m.IvaldiDir, symlinkPlaceholders))
+	if err != nil {
+		return m.linkFiles
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			m.linkFiles[line] = true
+		}
+	}
+	return m.linkFiles
+}
+
+// markPlaceholder records whether the workspace file at fullPath is a
+// placeholder for a symbolic link, saving the list when it changes
+func (m *Materializer) markPlaceholder(fullPath string, placeholder bool) error {
+	relPath, err := filepath.Rel(m.WorkDir, fullPath)
+	if err != nil {
+		return err
+	}
+	relPath = filepath.ToSlash(relPath)
+	links := m.placeholders()
+	if links[relPath] == placeholder {
+		return nil
+	}
+	if placeholder {
+		links[relPath] = true
+	} else {
+		delete(links, relPath)
+	}
+
+	path := filepath.Join(m.IvaldiDir, symlinkPlaceholders)
+	if len(links) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to update %s: %w", symlinkPlaceholders, err)
+		}
+		return nil
+	}
+	paths := make([]string, 0, len(links))
+	for p := range links {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if err := os.WriteFile(path, []byte(strings.Join(paths, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to update %s: %w", symlinkPlaceholders, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package workspace
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
+	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
+	"github.com/javanhut/Ivaldi-vcs/internal/wsindex"
+)
+
+// scanLink scans the workspace and returns the entry recorded for link,
+// checking that it is recorded as a link to target.txt
+func scanLink(t *testing.T, materializer *Materializer) wsindex.FileMetadata {
+	for _, file := range scanFiles(t, materializer) {
+		if file.Path != "link" {
+			continue
+		}
+		if fs.FileMode(file.Mode)&fs.ModeSymlink == 0 {
+			t.Fatalf("link recorded with mode %v, want a symbolic link", fs.FileMode(file.Mode))
+		}
+		content, err := filechunk.NewLoader(materializer.CAS).ReadAll(file.FileRef)
+		if err != nil {
+			t.Fatalf("Failed to read link content: %v", err)
+		}
+		if string(content) != "target.txt" {
+			t.Fatalf("link recorded as %q, want its target %q", content, "target.txt")
+		}
+		return file
+	}
+	t.Fatalf("link missing from scan")
+	return wsindex.FileMetadata{}
+}
+
+func TestSymlinkCheckout(t *testing.T) {
+	for _, symlinks := range []bool{true, false} {
+		_, workDir, materializer, cleanup := setupTestWorkspace(t)
+		defer cleanup()
+		materializer.Symlinks = symlinks
+
+		writeFiles(t, workDir, map[string]string{"target.txt": "content"})
+		linkPath := filepath.Join(workDir, "link")
+		if err := os.Symlink("target.txt", linkPath); err != nil {
+			t.Skipf("cannot create symbolic links here: %v", err)
+		}
+		link := scanLink(t, materializer)
+
+		if err := os.Remove(linkPath); err != nil {
+			t.Fatalf("Failed to remove link: %v", err)
+		}
+		diff := &diffmerge.WorkspaceDiff{FileChanges: []diffmerge.FileChange{
+			{Type: diffmerge.Added, Path: "link", NewFile: &link},
+		}}
+		if err := materializer.ApplyChangesToWorkspace(diff); err != nil {
+			t.Fatalf("ApplyChangesToWorkspace failed: %v", err)
+		}
+
+		info, err := os.Lstat(linkPath)
+		if err != nil {
+			t.Fatalf("link not checked out: %v", err)
+		}
+		_, statErr := os.Stat(filepath.Join(materializer.IvaldiDir, symlinkPlaceholders))
+		if symlinks {
+			if info.Mode()&fs.ModeSymlink == 0 {
+				t.Fatalf("symlinks=true: checked out as %v, want a symbolic link", info.Mode())
+			}
+			if target, _ := os.Readlink(linkPath); target != "target.txt" {
+				t.Fatalf("symlinks=true: link points to %q", target)
+			}
+			if !os.IsNotExist(statErr) {
+				t.Fatalf("symlinks=true: placeholder list written for a real link")
+			}
+		} else {
+			if !info.Mode().IsRegular() {
+				t.Fatalf("symlinks=false: checked out as %v, want a plain file", info.Mode())
+			}
+			if content, _ := os.ReadFile(linkPath); string(content) != "target.txt" {
+				t.Fatalf("symlinks=false: placeholder holds %q", content)
+			}
+			if statErr != nil {
+				t.Fatalf("symlinks=false: placeholder not marked: %v", statErr)
+			}
+		}
+
+		// A fresh materializer rereads the marks and records the same link
+		rescanned := NewMaterializer(materializer.CAS, materializer.IvaldiDir, workDir)
+		rescanned.Symlinks = symlinks
+		if got := scanLink(t, rescanned); got.Checksum != link.Checksum {
+			t.Fatalf("symlinks=%t: rescanned link differs from the original", symlinks)
+		}
+
+		// Checking out a plain file in its place clears the mark
+		plain := link
+		plain.Mode = 0644
+		diff = &diffmerge.WorkspaceDiff{FileChanges: []diffmerge.FileChange{
+			{Type: diffmerge.Modified, Path: "link", OldFile: &link, NewFile: &plain},
+		}}
+		if err := rescanned.ApplyChangesToWorkspace(diff); err != nil {
+			t.Fatalf("ApplyChangesToWorkspace failed: %v", err)
+		}
+		for _, file := range scanFiles(t, rescanned) {
+			if file.Path == "link" && fs.FileMode(file.Mode)&fs.ModeSymlink != 0 {
+				t.Fatalf("symlinks=%t: plain file recorded as a link", symlinks)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(materializer.IvaldiDir, symlinkPlaceholders)); !os.IsNotExist(err) {
+			t.Fatalf("symlinks=%t: placeholder mark left behind", symlinks)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/javanhut/Ivaldi-vcs/internal/cas"
 	"github.com/javanhut/Ivaldi-vcs/internal/commit"
+	"github.com/javanhut/Ivaldi-vcs/internal/config"
 	"github.com/javanhut/Ivaldi-vcs/internal/diffmerge"
 	"github.com/javanhut/Ivaldi-vcs/internal/filechunk"
 	"github.com/javanhut/Ivaldi-vcs/internal/hamtdir"
@@ -45,17 +46,59 @@ type Materializer struct {
 	CAS       cas.CAS
 	IvaldiDir string
 	WorkDir   string
+	// PrecomposeUnicode records scanned path names in NFC form
+	PrecomposeUnicode bool
+	// IgnoreModTime and IgnoreMode leave files whose content matches the
+	// target alone when the workspace is updated
+	IgnoreModTime bool
+	IgnoreMode    bool
+	// SkipNestedRepos leaves directories holding a repository of their own
+	// out of scans
+	SkipNestedRepos bool
+	// NestedRepos lists the nested repositories the last scan skipped
+	NestedRepos []NestedRepo
+	// BigFileThreshold is the size above which files are stored as a
+	// single chunk, streamed rather than read into memory; 0 means none
+	BigFileThreshold int64
+	// Symlinks checks out symbolic links as links; without it they are
+	// written as plain files holding the link target
+	Symlinks bool
+
+	linkFiles map[string]bool // Placeholder files for symbolic links
 }
 
 // NewMaterializer creates a new Materializer.
 func NewMaterializer(casStore cas.CAS, ivaldiDir, workDir string) *Materializer {
+	ignoreModTime, ignoreMode := config.WorkspaceCompare()
 	return &Materializer{
-		CAS:       casStore,
-		IvaldiDir: ivaldiDir,
-		WorkDir:   workDir,
+		CAS:               casStore,
+		IvaldiDir:         ivaldiDir,
+		WorkDir:           workDir,
+		PrecomposeUnicode: config.PrecomposeUnicode(),
+		IgnoreModTime:     ignoreModTime,
+		IgnoreMode:        ignoreMode,
+		SkipNestedRepos:   config.SkipNestedRepos(),
+		BigFileThreshold:  config.BigFileThreshold(),
+		Symlinks:          config.Symlinks(),
 	}
 }
 
+// NewDiffer returns a differ that compares workspace files the way the
+// materializer is configured to
+func (m *Materializer) NewDiffer() *diffmerge.Differ {
+	return &diffmerge.Differ{CAS: m.CAS, IgnoreModTime: m.IgnoreModTime, IgnoreMode: m.IgnoreMode}
+}
+
+// sealDiffer returns a differ that compares the workspace with the files
+// of a seal. A seal's files carry its commit time, not the time they were
+// written to the workspace, so their timestamps are ignored and files are
+// compared by content.
+func (m *Materializer) sealDiffer() *diffmerge.Differ {
+	differ := m.NewDiffer()
+	differ.IgnoreModTime = true
+	return differ
+}
+
 // GetCurrentState reads the current workspace state.
 func (m *Materializer) GetCurrentState() (*WorkspaceState, error) {
 	// Get current timeline
@@ -98,56 +141,50 @@ func (m *Materializer) GetCurrentState() (*WorkspaceState, error) {
 }
 
 // ScanWorkspace scans the current working directory and creates a workspace index.
+// With SkipNestedRepos, directories that hold a repository of their own are
+// not scanned and are listed in NestedRepos instead.
 func (m *Materializer) ScanWorkspace() (wsindex.IndexRef, error) {
 	var files []wsindex.FileMetadata
 
-	err := filepath.WalkDir(m.WorkDir, func(path string, d fs.DirEntry, err error) error {
+	// Each file is chunked with the profile .ivaldiattributes or its extension selects
+	chunkRules, err := filechunk.LoadProfileRules(m.WorkDir)
+	if err != nil {
+		return wsindex.IndexRef{}, fmt.Errorf("failed to load chunk profiles: %w", err)
+	}
+
+	nested := NewNestedRepos(m.WorkDir, m.SkipNestedRepos)
+	err = filepath.WalkDir(m.WorkDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories
-		if d.IsDir() {
-			return nil
-		}
-
-		// Skip .ivaldi directory
 		relPath, err := filepath.Rel(m.WorkDir, path)
 		if err != nil {
 			return err
 		}
 
-		if strings.HasPrefix(relPath, ".ivaldi"+string(filepath.Separator)) || relPath == ".ivaldi" {
+		// Skip the .ivaldi directory and nested repositories
+		if d.IsDir() {
+			if d.Name() == ".ivaldi" || nested.Skip(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
+		// Record the name in the same form on every platform
+		if m.PrecomposeUnicode {
+			relPath = NormalizePath(relPath)
+		}
+
 		// Get file info
 		info, err := d.Info()
 		if err != nil {
 			return err
 		}
 
-		// Read file content
-		content, err := os.ReadFile(path)
+		fileMetadata, err := m.storeFile(path, relPath, info, chunkRules)
 		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", relPath, err)
-		}
-
-		// Create file chunks
-		builder := filechunk.NewBuilder(m.CAS, filechunk.DefaultParams())
-		fileRef, err := builder.Build(content)
-		if err != nil {
-			return fmt.Errorf("failed to create file chunks for %s: %w", relPath, err)
-		}
-
-		// Create metadata
-		fileMetadata := wsindex.FileMetadata{
-			Path:     relPath,
-			FileRef:  fileRef,
-			ModTime:  info.ModTime(),
-			Mode:     uint32(info.Mode()),
-			Size:     info.Size(),
-			Checksum: cas.SumB3(content),
+			return err
 		}
 
 		files = append(files, fileMetadata)
@@ -157,12 +194,92 @@ func (m *Materializer) ScanWorkspace() (wsindex.IndexRef, error) {
 	if err != nil {
 		return wsindex.IndexRef{}, fmt.Errorf("failed to scan workspace: %w", err)
 	}
+	m.NestedRepos = nested.Found()
 
 	// Build workspace index
 	wsBuilder := wsindex.NewBuilder(m.CAS)
 	return wsBuilder.Build(files)
 }
 
+// storeFile chunks the content of a workspace file into the CAS and returns
+// its metadata. A symbolic link is stored as its target.
+func (m *Materializer) storeFile(path, relPath string, info fs.FileInfo, chunkRules *filechunk.ProfileRules) (wsindex.FileMetadata, error) {
+	if m.BigFileThreshold > 0 && info.Mode().IsRegular() && info.Size() > m.BigFileThreshold {
+		return m.storeBigFile(path, relPath, info)
+	}
+
+	// Read file content
+	content, err := ReadFileContent(path)
+	if err != nil {
+		return wsindex.FileMetadata{}, fmt.Errorf("failed to read file %s: %w", relPath, err)
+	}
+
+	// Create file chunks
+	builder := filechunk.NewBuilder(m.CAS, chunkRules.Params(relPath))
+	fileRef, err := builder.Build(content)
+	if err != nil {
+		return wsindex.FileMetadata{}, fmt.Errorf("failed to create file chunks for %s: %w", relPath, err)
+	}
+
+	return wsindex.FileMetadata{
+		Path:     relPath,
+		FileRef:  fileRef,
+		ModTime:  info.ModTime(),
+		Mode:     uint32(m.linkMode(relPath, info)),
+		Size:     int64(len(content)),
+		Checksum: cas.SumB3(content),
+	}, nil
+}
+
+// storeBigFile stores a file above the big file threshold as a single
+// chunk, streaming it into the CAS
+func (m *Materializer) storeBigFile(path, relPath string, info fs.FileInfo) (wsindex.FileMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return wsindex.FileMetadata{}, fmt.Errorf("failed to read file %s: %w", relPath, err)
+	}
+	defer file.Close()
+
+	fileRef, checksum, err := filechunk.NewBuilder(m.CAS, filechunk.DefaultParams()).BuildWhole(file, info.Size())
+	if err != nil {
+		return wsindex.FileMetadata{}, fmt.Errorf("failed to store %s: %w", relPath, err)
+	}
+
+	return wsindex.FileMetadata{
+		Path:     relPath,
+		FileRef:  fileRef,
+		ModTime:  info.ModTime(),
+		Mode:     uint32(info.Mode()),
+		Size:     info.Size(),
+		Checksum: checksum,
+	}, nil
+}
+
+// writeFile writes stored content to a file, streaming it so that big
+// files are never held in memory. Like os.WriteFile, it leaves the
+// permissions of an existing file alone. A symbolic link in the way is
+// replaced rather than written through.
+func (m *Materializer) writeFile(fullPath string, fileRef filechunk.NodeRef, perm fs.FileMode) error {
+	if info, err := os.Lstat(fullPath); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if err := os.Remove(fullPath); err != nil {
+			return err
+		}
+	}
+	if err := m.markPlaceholder(fullPath, false); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	err = filechunk.NewLoader(m.CAS).WriteTo(fileRef, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 // MaterializeTimeline materializes a timeline's state to the workspace.
 func (m *Materializer) MaterializeTimeline(timelineName string) error {
 	return m.MaterializeTimelineWithAutoShelf(timelineName, true)
@@ -224,7 +341,7 @@ func (m *Materializer) MaterializeTimelineWithAutoShelf(timelineName string, ena
 		}
 
 		// Count and report changes if any
-		differ := diffmerge.NewDiffer(m.CAS)
+		differ := m.sealDiffer()
 		diff, err := differ.DiffWorkspaces(currentTimelineBase, currentState.Index)
 		if err == nil && len(diff.FileChanges) > 0 {
 			fmt.Printf("Auto-shelved %d changes from timeline '%s' (shelf: %s)\n",
@@ -273,7 +390,7 @@ func (m *Materializer) MaterializeTimelineWithAutoShelf(timelineName string, ena
 	}
 
 	// Compute differences between current state and target
-	differ := diffmerge.NewDiffer(m.CAS)
+	differ := m.NewDiffer()
 	diff, err := differ.DiffWorkspaces(currentState.Index, targetIndex)
 	if err != nil {
 		return fmt.Errorf("failed to compute workspace diff: %w", err)
@@ -419,8 +536,6 @@ func (m *Materializer) getFileRefFromTree(tree *commit.TreeObject, filePath stri
 
 // ApplyChangesToWorkspace applies file changes to the working directory.
 func (m *Materializer) ApplyChangesToWorkspace(diff *diffmerge.WorkspaceDiff) error {
-	loader := filechunk.NewLoader(m.CAS)
-
 	for _, change := range diff.FileChanges {
 		fullPath := filepath.Join(m.WorkDir, change.Path)
 
@@ -436,14 +551,16 @@ func (m *Materializer) ApplyChangesToWorkspace(diff *diffmerge.WorkspaceDiff) er
 				return fmt.Errorf("failed to create directory %s: %w", parentDir, err)
 			}
 
-			// Read file content from chunks
-			content, err := loader.ReadAll(change.NewFile.FileRef)
-			if err != nil {
-				return fmt.Errorf("failed to read file content for %s: %w", change.Path, err)
+			// Symbolic links carry no timestamp of their own to set
+			if os.FileMode(change.NewFile.Mode)&os.ModeSymlink != 0 {
+				if err := m.writeLink(fullPath, change.NewFile.FileRef); err != nil {
+					return fmt.Errorf("failed to write link %s: %w", change.Path, err)
+				}
+				continue
 			}
 
-			// Write file
-			err = os.WriteFile(fullPath, content, os.FileMode(change.NewFile.Mode))
+			// Write file content from chunks
+			err := m.writeFile(fullPath, change.NewFile.FileRef, os.FileMode(change.NewFile.Mode))
 			if err != nil {
 				return fmt.Errorf("failed to write file %s: %w", change.Path, err)
 			}
@@ -461,6 +578,9 @@ func (m *Materializer) ApplyChangesToWorkspace(diff *diffmerge.WorkspaceDiff) er
 			if err != nil && !os.IsNotExist(err) {
 				return fmt.Errorf("failed to remove file %s: %w", change.Path, err)
 			}
+			if err := m.markPlaceholder(fullPath, false); err != nil {
+				return err
+			}
 
 			// Try to remove empty parent directories
 			parentDir := filepath.Dir(fullPath)
@@ -563,7 +683,7 @@ func (m *Materializer) RestoreWorkspace(backupName string) error {
 	}
 
 	// Compute diff
-	differ := diffmerge.NewDiffer(m.CAS)
+	differ := m.NewDiffer()
 	diff, err := differ.DiffWorkspaces(currentState.Index, backupIndex)
 	if err != nil {
 		return fmt.Errorf("failed to compute restore diff: %w", err)
@@ -589,7 +709,7 @@ func (m *Materializer) CleanWorkspace() error {
 	}
 
 	// Compute diff (everything should be removed)
-	differ := diffmerge.NewDiffer(m.CAS)
+	differ := m.NewDiffer()
 	diff, err := differ.DiffWorkspaces(currentIndex, emptyIndex)
 	if err != nil {
 		return fmt.Errorf("failed to compute clean diff: %w", err)
@@ -631,7 +751,7 @@ func (m *Materializer) GetWorkspaceStatus() (*WorkspaceStatus, error) {
 	}
 
 	// Compute differences between committed state and actual workspace
-	differ := diffmerge.NewDiffer(m.CAS)
+	differ := m.sealDiffer()
 	diff, err := differ.DiffWorkspaces(committedIndex, actualIndex)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute status diff: %w", err)
@@ -697,6 +817,11 @@ type Stash struct {
 	Description string           // Description of changes
 	Index       wsindex.IndexRef // Stashed workspace state
 	Created     time.Time        // When stash was created
+	// Paths and Deleted record the files a selective stash took: the ones
+	// Index holds and the sealed files that had been deleted. Both are
+	// empty for stashes of the whole workspace.
+	Paths   []string
+	Deleted []string
 }
 
 // StashManager handles workspace stashing operations.
@@ -765,6 +890,11 @@ func (sm *StashManager) ApplyStash(name string) error {
 		Count: 0, // Count will be determined when loading
 	}
 
+	// A selective stash restores exactly the files it took
+	if _, record := parseStashDescription(name, stash.Description); record != nil {
+		return sm.applyRecorded(refsManager, name, stashIndex, record)
+	}
+
 	// Get current state
 	currentState, err := sm.Materializer.GetCurrentState()
 	if err != nil {
@@ -772,7 +902,7 @@ func (sm *StashManager) ApplyStash(name string) error {
 	}
 
 	// Compute diff
-	differ := diffmerge.NewDiffer(sm.Materializer.CAS)
+	differ := sm.Materializer.NewDiffer()
 	diff, err := differ.DiffWorkspaces(currentState.Index, stashIndex)
 	if err != nil {
 		return fmt.Errorf("failed to compute stash diff: %w", err)
@@ -210,6 +210,53 @@ func TestMaterializeTimeline(t *testing.T) {
 	}
 }
 
+func TestStatusCleanAfterSwitch(t *testing.T) {
+	ivaldiDir, workDir, materializer, cleanup := setupTestWorkspace(t)
+	defer cleanup()
+
+	refsManager, err := refs.NewRefsManager(ivaldiDir)
+	if err != nil {
+		t.Fatalf("Failed to create refs manager: %v", err)
+	}
+	defer refsManager.Close()
+
+	// Seal main, then feature on top of it with one file changed and one added
+	commitBuilder := commit.NewCommitBuilder(materializer.CAS, history.NewMMR())
+	writeFiles(t, workDir, map[string]string{"a.txt": "main", "same.txt": "same"})
+	mainSeal, err := commitBuilder.CreateCommit(scanFiles(t, materializer), nil, "test-author", "test-committer", "Main commit")
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if err := refsManager.UpdateTimeline("main", refs.LocalTimeline, commitBuilder.GetCommitHash(mainSeal), [32]byte{}, ""); err != nil {
+		t.Fatalf("UpdateTimeline failed: %v", err)
+	}
+	writeFiles(t, workDir, map[string]string{"a.txt": "feature", "new.txt": "new"})
+	featureSeal, err := commitBuilder.CreateCommit(scanFiles(t, materializer), nil, "test-author", "test-committer", "Feature commit")
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if err := refsManager.CreateTimeline("feature", refs.LocalTimeline, commitBuilder.GetCommitHash(featureSeal), [32]byte{}, "", "Feature timeline"); err != nil {
+		t.Fatalf("Failed to create feature timeline: %v", err)
+	}
+	if err := refsManager.SetCurrentTimeline("feature"); err != nil {
+		t.Fatalf("Failed to set current timeline: %v", err)
+	}
+
+	// Files written at checkout carry other times than the seals record
+	for _, timeline := range []string{"main", "feature", "main"} {
+		if err := materializer.MaterializeTimeline(timeline); err != nil {
+			t.Fatalf("MaterializeTimeline(%s) failed: %v", timeline, err)
+		}
+		status, err := materializer.GetWorkspaceStatus()
+		if err != nil {
+			t.Fatalf("GetWorkspaceStatus failed: %v", err)
+		}
+		if !status.Clean {
+			t.Errorf("Expected a clean workspace after switching to %s, got %v", timeline, status.ListChanges())
+		}
+	}
+}
+
 func TestGetWorkspaceStatus(t *testing.T) {
 	_, workDir, materializer, cleanup := setupTestWorkspace(t)
 	defer cleanup()
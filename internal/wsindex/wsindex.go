@@ -247,6 +247,11 @@ func (b *Builder) encodeInternal(node *Node) []byte {
 // Loader reads flat key-value Merkle indexes.
 type Loader struct {
 	CAS cas.CAS
+	// IgnoreModTime and IgnoreMode make Diff treat files with the same
+	// content as unchanged regardless of those fields. The zero value
+	// compares all metadata, as integrity checks need.
+	IgnoreModTime bool
+	IgnoreMode    bool
 }
 
 // NewLoader creates a new Loader with the given CAS.
@@ -378,6 +383,12 @@ func (l *Loader) findChildIndex(node *Node, path string) int {
 	return len(node.Separators) // Last child
 }
 
+// DecodeNode decodes the canonical encoding of a single index node without
+// loading its children.
+func DecodeNode(data []byte) (*Node, error) {
+	return (&Loader{}).decodeNode(data)
+}
+
 // decodeNode decodes canonical bytes into a Node.
 func (l *Loader) decodeNode(data []byte) (*Node, error) {
 	if len(data) == 0 {
@@ -402,6 +413,9 @@ func (l *Loader) decodeLeaf(data []byte) (*Node, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read entry count: %w", err)
 	}
+	if entryCount > uint64(buf.Len()) {
+		return nil, fmt.Errorf("entry count %d exceeds node size", entryCount)
+	}
 
 	entries := make([]FileMetadata, 0, entryCount)
 
@@ -411,6 +425,9 @@ func (l *Loader) decodeLeaf(data []byte) (*Node, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to read path length: %w", err)
 		}
+		if pathLen > uint64(buf.Len()) {
+			return nil, fmt.Errorf("failed to read path")
+		}
 
 		pathBytes := make([]byte, pathLen)
 		n, err := buf.Read(pathBytes)
@@ -476,6 +493,9 @@ func (l *Loader) decodeLeaf(data []byte) (*Node, error) {
 
 		entries = append(entries, entry)
 	}
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("trailing data after leaf node")
+	}
 
 	return &Node{
 		IsLeaf:  true,
@@ -492,6 +512,9 @@ func (l *Loader) decodeInternal(data []byte) (*Node, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read child count: %w", err)
 	}
+	if childCount > uint64(buf.Len())/32 {
+		return nil, fmt.Errorf("child count %d exceeds node size", childCount)
+	}
 
 	// Read child hashes
 	children := make([]cas.Hash, childCount)
@@ -511,6 +534,9 @@ func (l *Loader) decodeInternal(data []byte) (*Node, error) {
 			if err != nil {
 				return nil, fmt.Errorf("failed to read separator length: %w", err)
 			}
+			if sepLen > uint64(buf.Len()) {
+				return nil, fmt.Errorf("failed to read separator")
+			}
 
 			sepBytes := make([]byte, sepLen)
 			n, err := buf.Read(sepBytes)
@@ -521,6 +547,9 @@ func (l *Loader) decodeInternal(data []byte) (*Node, error) {
 			separators = append(separators, string(sepBytes))
 		}
 	}
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("trailing data after internal node")
+	}
 
 	return &Node{
 		IsLeaf:     false,
@@ -584,14 +613,15 @@ func (l *Loader) Diff(oldIndex, newIndex IndexRef) (*DiffResult, error) {
 	return result, nil
 }
 
-// filesEqual checks if two file metadata entries are equal.
+// filesEqual checks if two file metadata entries are equal, skipping the
+// fields the loader is set to ignore.
 func (l *Loader) filesEqual(a, b FileMetadata) bool {
 	return a.Path == b.Path &&
 		a.FileRef.Hash == b.FileRef.Hash &&
 		a.FileRef.Kind == b.FileRef.Kind &&
 		a.FileRef.Size == b.FileRef.Size &&
-		a.ModTime.Equal(b.ModTime) &&
-		a.Mode == b.Mode &&
+		(l.IgnoreModTime || a.ModTime.Equal(b.ModTime)) &&
+		(l.IgnoreMode || a.Mode == b.Mode) &&
 		a.Size == b.Size &&
 		a.Checksum == b.Checksum
 }
\ No newline at end of file
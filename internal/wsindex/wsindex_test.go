@@ -400,6 +400,46 @@ func TestDiff(t *testing.T) {
 	}
 }
 
+func TestDiffIgnoreMetadata(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	builder := NewBuilder(casStore)
+
+	touched := createTestFile("touched.txt", "same")
+	touched.ModTime = touched.ModTime.Add(time.Hour)
+	chmodded := createTestFile("chmodded.sh", "same")
+	chmodded.Mode = 0755
+
+	oldIndex, err := builder.Build([]FileMetadata{createTestFile("chmodded.sh", "same"), createTestFile("touched.txt", "same")})
+	if err != nil {
+		t.Fatalf("Build old index failed: %v", err)
+	}
+	newIndex, err := builder.Build([]FileMetadata{chmodded, touched})
+	if err != nil {
+		t.Fatalf("Build new index failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		loader   *Loader
+		modified int
+	}{
+		{"strict", NewLoader(casStore), 2},
+		{"ignore mtime", &Loader{CAS: casStore, IgnoreModTime: true}, 1},
+		{"content only", &Loader{CAS: casStore, IgnoreModTime: true, IgnoreMode: true}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := tt.loader.Diff(oldIndex, newIndex)
+			if err != nil {
+				t.Fatalf("Diff failed: %v", err)
+			}
+			if len(diff.Modified) != tt.modified {
+				t.Errorf("Expected %d modified files, got %d", tt.modified, len(diff.Modified))
+			}
+		})
+	}
+}
+
 func TestSameContentSameHash(t *testing.T) {
 	casStore := cas.NewMemoryCAS()
 	builder := NewBuilder(casStore)
@@ -524,4 +564,29 @@ func BenchmarkListAllLargeIndex(b *testing.B) {
 			b.Fatalf("ListAll failed: %v", err)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestDecodeNode(t *testing.T) {
+	casStore := cas.NewMemoryCAS()
+	file := createTestFile("src/main.go", "package main")
+	index, err := NewBuilder(casStore).Build([]FileMetadata{file})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	data, err := casStore.Get(index.Hash)
+	if err != nil {
+		t.Fatalf("Get node failed: %v", err)
+	}
+
+	node, err := DecodeNode(data)
+	if err != nil {
+		t.Fatalf("DecodeNode failed: %v", err)
+	}
+	if !node.IsLeaf || len(node.Entries) != 1 || node.Entries[0].Path != "src/main.go" || node.Entries[0].Checksum != file.Checksum {
+		t.Errorf("Unexpected decoded node: %+v", node)
+	}
+
+	if _, err := DecodeNode(append(data, 0)); err == nil {
+		t.Error("Expected trailing data to be rejected")
+	}
+}